@@ -4,15 +4,20 @@ package main
 
 import (
 	v1 "basaltpass-backend/internal/api/v1"
+	common "basaltpass-backend/internal/common"
 	config "basaltpass-backend/internal/config"
 	middleware "basaltpass-backend/internal/middleware"
 	migration "basaltpass-backend/internal/migration"
+	authsvc "basaltpass-backend/internal/service/auth"
+	ldapauth "basaltpass-backend/internal/service/ldapauth"
 	usersettings "basaltpass-backend/internal/service/settings"
+	signingkey "basaltpass-backend/internal/service/signingkey"
 	utils "basaltpass-backend/internal/utils"
 
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -56,6 +61,25 @@ func main() {
 	// Run DB migrations
 	migration.RunMigrations()
 
+	// Ensure an active signing key exists and schedule JWKS rotation
+	signingKeys := signingkey.NewService(common.DB())
+	if _, err := signingKeys.ActiveKey(); err != nil {
+		log.Fatalf("[main][error] Failed to initialize signing keys: %v", err)
+	}
+	signingkey.StartRotationScheduler(signingKeys, time.Hour)
+
+	// 控制台令牌使用非对称签名时预先生成密钥；轮换检查始终运行，未生成过密钥时为空操作
+	consoleKeys := authsvc.ConsoleSigningKeys()
+	if authsvc.ConsoleAlgorithm() != authsvc.AlgorithmHS256 {
+		if _, err := consoleKeys.ActiveKey(); err != nil {
+			log.Fatalf("[main][error] Failed to initialize console signing keys: %v", err)
+		}
+	}
+	signingkey.StartRotationScheduler(consoleKeys, time.Hour)
+
+	// 按各租户配置的间隔同步 LDAP 目录的组角色
+	ldapauth.StartSyncScheduler(ldapauth.NewService(common.DB()), time.Minute)
+
 	// Register API routes
 	v1.RegisterRoutes(app)
 
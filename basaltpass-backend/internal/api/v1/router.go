@@ -40,4 +40,7 @@ func RegisterRoutes(app *fiber.App) {
 
 	// 注册手动 API（API Key）
 	routes2.RegisterManualAPIRoutes(v1)
+
+	// 注册 SCIM 2.0 预配路由（租户 API Key）
+	routes2.RegisterSCIMRoutes(v1)
 }
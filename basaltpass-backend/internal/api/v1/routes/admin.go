@@ -71,6 +71,10 @@ func RegisterAdminRoutes(v1 fiber.Router) {
 	adminUserGroup.Put("/:id", adminUser.UpdateUserHandler)                         // /tenant/users/:id
 	adminUserGroup.Delete("/:id", adminUser.DeleteUserHandler)                      // /tenant/users/:id
 	adminUserGroup.Post("/:id/ban", adminUser.BanUserHandler)                       // /tenant/users/:id/ban
+	adminUserGroup.Get("/:id/sessions", adminUser.ListUserSessionsHandler)          // /tenant/users/:id/sessions
+	adminUserGroup.Delete("/:id/sessions", adminUser.RevokeUserSessionsHandler)     // /tenant/users/:id/sessions
+	adminUserGroup.Post("/:id/mfa/reset", adminUser.ResetUserMFAHandler)            // /tenant/users/:id/mfa/reset
+	adminUserGroup.Post("/:id/unlock", adminUser.UnlockUserHandler)                 // /tenant/users/:id/unlock
 	adminUserGroup.Post("/:id/roles", adminUser.AssignGlobalRoleHandler)            // /tenant/users/:id/roles
 	adminUserGroup.Delete("/:id/roles/:role_id", adminUser.RemoveGlobalRoleHandler) // /tenant/users/:id/roles/:role_id
 
@@ -84,6 +88,10 @@ func RegisterAdminRoutes(v1 fiber.Router) {
 	aliasUserGroup.Put("/:id", adminUser.UpdateUserHandler)
 	aliasUserGroup.Delete("/:id", adminUser.DeleteUserHandler)
 	aliasUserGroup.Post("/:id/ban", adminUser.BanUserHandler)
+	aliasUserGroup.Get("/:id/sessions", adminUser.ListUserSessionsHandler)
+	aliasUserGroup.Delete("/:id/sessions", adminUser.RevokeUserSessionsHandler)
+	aliasUserGroup.Post("/:id/mfa/reset", adminUser.ResetUserMFAHandler)
+	aliasUserGroup.Post("/:id/unlock", adminUser.UnlockUserHandler)
 	aliasUserGroup.Post("/:id/roles", adminUser.AssignGlobalRoleHandler)
 	aliasUserGroup.Delete("/:id/roles/:role_id", adminUser.RemoveGlobalRoleHandler)
 
@@ -197,6 +205,12 @@ func RegisterAdminRoutes(v1 fiber.Router) {
 	aliasOAuthClients.Get("/:client_id/tokens", oauth.GetTokensHandler)
 	aliasOAuthClients.Post("/:client_id/revoke-tokens", oauth.RevokeClientTokensHandler)
 
+	// OAuth 签名密钥
+	aliasSigningKeys := adminAliasGroup.Group("/oauth/signing-keys")
+	aliasSigningKeys.Get("/", oauth.ListSigningKeysHandler)
+	aliasSigningKeys.Post("/rotate", oauth.RotateSigningKeyHandler)
+	aliasSigningKeys.Post("/:kid/revoke", oauth.RevokeSigningKeyHandler)
+
 	// Apps （系统级 + 应用用户管理）
 	aliasApps := adminAliasGroup.Group("/apps")
 	aliasApps.Post("/", appHandler.AdminCreateAppHandler)
@@ -340,6 +354,12 @@ func RegisterAdminRoutes(v1 fiber.Router) {
 	oauthClientGroup.Get("/:client_id/tokens", oauth.GetTokensHandler)                    // /tenant/oauth/clients/:client_id/tokens
 	oauthClientGroup.Post("/:client_id/revoke-tokens", oauth.RevokeClientTokensHandler)   // /tenant/oauth/clients/:client_id/revoke-tokens
 
+	// OAuth签名密钥管理（JWKS轮换/吊销）
+	signingKeyGroup := adminGroup.Group("/oauth/signing-keys")          // /tenant/oauth/signing-keys
+	signingKeyGroup.Get("/", oauth.ListSigningKeysHandler)              // /tenant/oauth/signing-keys
+	signingKeyGroup.Post("/rotate", oauth.RotateSigningKeyHandler)      // /tenant/oauth/signing-keys/rotate
+	signingKeyGroup.Post("/:kid/revoke", oauth.RevokeSigningKeyHandler) // /tenant/oauth/signing-keys/:kid/revoke
+
 	// 系统级应用管理
 	adminAppGroup := adminGroup.Group("/apps")
 	adminAppGroup.Post("/", appHandler.AdminCreateAppHandler)      // /tenant/apps
@@ -22,6 +22,7 @@ func RegisterOAuthRoutes(v1 fiber.Router) {
 	// OIDC Discovery和会话管理端点
 	v1.Get("/check_session_iframe", oauth.CheckSessionIframeHandler)
 	v1.Get("/end_session", oauth.EndSessionHandler)
+	v1.Post("/end_session", oauth.EndSessionHandler)
 
 	/*
 	 * OAuth2和OIDC相关端点
@@ -31,18 +32,35 @@ func RegisterOAuthRoutes(v1 fiber.Router) {
 
 	oauthServerGroup := v1.Group("/oauth")
 	oauthServerGroup.Get("/authorize", oauth.AuthorizeHandler)
+	oauthServerGroup.Post("/par", oauth.PushedAuthorizationRequestHandler)
 	oauthServerGroup.Post("/consent", oauth.ConsentHandler)
 	oauthServerGroup.Post("/token", oauth.TokenHandler)
+	oauthServerGroup.Post("/device_authorization", oauth.DeviceAuthorizationHandler)
 	oauthServerGroup.Get("/userinfo", oauth.UserInfoHandler)
 	oauthServerGroup.Post("/introspect", oauth.OAuthClientAuthMiddleware(), oauth.IntrospectHandler)
 	oauthServerGroup.Post("/revoke", oauth.OAuthClientAuthMiddleware(), oauth.RevokeHandler)
 	oauthServerGroup.Get("/jwks", oauth.JWKSHandler)
 
+	// 动态客户端注册（RFC 7591/7592）
+	oauthServerGroup.Post("/register", oauth.RegisterClientHandler)
+	oauthServerGroup.Get("/register/:client_id", oauth.GetRegisteredClientHandler)
+	oauthServerGroup.Put("/register/:client_id", oauth.UpdateRegisteredClientHandler)
+	oauthServerGroup.Delete("/register/:client_id", oauth.DeleteRegisteredClientHandler)
+
 	// TODO ⬇️ One-Tap Auth和Silent Auth端点
 	oauthServerGroup.Post("/one-tap/login", oauth.OneTapLoginHandler)
 	oauthServerGroup.Get("/silent-auth", oauth.SilentAuthHandler)
 	oauthServerGroup.Get("/check-session", oauth.CheckSessionHandler)
 
+	// SAML 2.0 IdP：每个租户一组端点，SP 登记在租户应用下
+	samlGroup := v1.Group("/saml/:tenant_code")
+	samlGroup.Get("/metadata", oauth.SAMLMetadataHandler)
+	samlGroup.Get("/sso", oauth.SAMLSSOHandler)
+	samlGroup.Post("/sso", oauth.SAMLSSOHandler)
+	samlGroup.Get("/apps/:app_id/sso", oauth.SAMLIdPInitiatedHandler)
+	samlGroup.Get("/slo", oauth.SAMLSLOHandler)
+	samlGroup.Post("/slo", oauth.SAMLSLOHandler)
+
 	// 认证相关路由
 	authGroup := v1.Group("/auth")
 	authGroup.Post("/login",
@@ -50,6 +68,7 @@ func RegisterOAuthRoutes(v1 fiber.Router) {
 		timeout.NewWithContext(auth2.LoginHandler, authRouteTimeout),
 	)
 	authGroup.Post("/refresh", auth2.RefreshHandler)
+	authGroup.Post("/logout", middleware.JWTMiddleware(), auth2.LogoutHandler)
 	authGroup.Post("/verify-2fa",
 		ratelimit.Verify2FARateLimit(),
 		timeout.NewWithContext(auth2.Verify2FAHandler, authRouteTimeout),
@@ -57,6 +76,26 @@ func RegisterOAuthRoutes(v1 fiber.Router) {
 	authGroup.Post("/identity/switch", middleware.JWTMiddleware(), auth2.SwitchUserTenantIdentityHandler)
 	authGroup.Post("/console/authorize", middleware.JWTMiddleware(), auth2.ConsoleAuthorizeHandler)
 	authGroup.Post("/console/exchange", auth2.ConsoleExchangeHandler)
+	authGroup.Get("/jwks", auth2.ConsoleJWKSHandler)
+
+	// 外部身份提供方联合登录
+	authGroup.Get("/federation/providers", auth2.FederationProvidersHandler)
+	authGroup.Get("/federation/callback", auth2.FederationCallbackHandler)
+	authGroup.Get("/federation/:provider_id/authorize", ratelimit.LoginRateLimit(), auth2.FederationAuthorizeHandler)
+
+	// 免密登录：邮箱登录链接/验证码或短信验证码
+	authGroup.Get("/passwordless/methods", auth2.PasswordlessMethodsHandler)
+	authGroup.Post("/passwordless/start",
+		ratelimit.LoginRateLimit(),
+		timeout.NewWithContext(auth2.PasswordlessStartHandler, authRouteTimeout),
+	)
+	authGroup.Post("/passwordless/verify",
+		ratelimit.Verify2FARateLimit(),
+		timeout.NewWithContext(auth2.PasswordlessVerifyHandler, authRouteTimeout),
+	)
+
+	// 登录失败锁定：邮件链接解锁
+	authGroup.Post("/unlock", ratelimit.LoginRateLimit(), auth2.UnlockAccountHandler)
 
 	// Passkey authentication routes
 	passkeyGroup := v1.Group("/passkey")
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"basaltpass-backend/internal/handler/scim"
+	"basaltpass-backend/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterSCIMRoutes SCIM 2.0 预配端点（租户 API Key 认证）
+func RegisterSCIMRoutes(v1 fiber.Router) {
+	group := v1.Group("/scim/v2", middleware.SCIMAuthMiddleware())
+	group.Get("/ServiceProviderConfig", scim.ServiceProviderConfigHandler)
+	group.Get("/ResourceTypes", scim.ResourceTypesHandler)
+	group.Get("/ResourceTypes/:id", scim.ResourceTypeHandler)
+	group.Get("/Schemas", scim.SchemasHandler)
+	group.Get("/Schemas/:id", scim.SchemaHandler)
+
+	group.Get("/Users", scim.ListUsersHandler)
+	group.Post("/Users", scim.CreateUserHandler)
+	group.Get("/Users/:id", scim.GetUserHandler)
+	group.Put("/Users/:id", scim.ReplaceUserHandler)
+	group.Patch("/Users/:id", scim.PatchUserHandler)
+	group.Delete("/Users/:id", scim.DeleteUserHandler)
+
+	group.Get("/Groups", scim.ListGroupsHandler)
+	group.Post("/Groups", scim.CreateGroupHandler)
+	group.Get("/Groups/:id", scim.GetGroupHandler)
+	group.Put("/Groups/:id", scim.ReplaceGroupHandler)
+	group.Patch("/Groups/:id", scim.PatchGroupHandler)
+	group.Delete("/Groups/:id", scim.DeleteGroupHandler)
+
+	group.Post("/Bulk", scim.BulkHandler)
+}
@@ -61,6 +61,9 @@ func RegisterTenantRoutes(v1 fiber.Router) {
 	tenantUserGroup.Get("/:id", tenant2.GetTenantUserHandler)
 	tenantUserGroup.Put("/:id", tenant2.UpdateTenantUserHandler)
 	tenantUserGroup.Delete("/:id", tenant2.RemoveTenantUserHandler)
+	tenantUserGroup.Get("/:id/sessions", tenant2.GetTenantUserSessionsHandler)
+	tenantUserGroup.Delete("/:id/sessions", tenant2.RevokeTenantUserSessionsHandler)
+	tenantUserGroup.Post("/:id/mfa/reset", tenant2.ResetTenantUserMFAHandler)
 	tenantUserGroup.Post("/invite", tenant2.InviteTenantUserHandler) // /tenant/users/invite
 	tenantUserGroup.Post("/:id/resend-invitation", tenant2.ResendInvitationHandler)
 
@@ -145,6 +148,12 @@ func RegisterTenantRoutes(v1 fiber.Router) {
 	tenantOAuthGroup.Delete("/:client_id", oauth.TenantDeleteOAuthClientHandler)
 	tenantOAuthGroup.Post("/:client_id/regenerate-secret", oauth.TenantRegenerateClientSecretHandler)
 
+	// 动态客户端注册的初始访问令牌
+	tenantIATGroup := tenantAdminGroup.Group("/oauth/initial-access-tokens")
+	tenantIATGroup.Get("/", oauth.TenantListInitialAccessTokensHandler)
+	tenantIATGroup.Post("/", oauth.TenantCreateInitialAccessTokenHandler)
+	tenantIATGroup.Delete("/:id", oauth.TenantDeleteInitialAccessTokenHandler)
+
 	// 租户OAuth scope 选项（用于控制台创建/编辑客户端）
 	tenantGroup.Get("/oauth/scopes", oauth.TenantListOAuthScopesHandler)
 
@@ -162,6 +171,22 @@ func RegisterTenantRoutes(v1 fiber.Router) {
 	crossAppTrustGroup.Delete("/:id", tenant2.DeleteCrossAppTrustHandler)
 	crossAppTrustGroup.Get("/logs", tenant2.ListTokenExchangeLogsHandler)
 
+	// 外部身份提供方（联合登录）
+	identityProviderGroup := tenantAdminGroup.Group("/identity-providers")
+	identityProviderGroup.Get("/", tenant2.ListIdentityProvidersHandler)
+	identityProviderGroup.Post("/", tenant2.CreateIdentityProviderHandler)
+	identityProviderGroup.Patch("/:id", tenant2.UpdateIdentityProviderHandler)
+	identityProviderGroup.Delete("/:id", tenant2.DeleteIdentityProviderHandler)
+
+	// LDAP / Active Directory 认证后端
+	ldapDirectoryGroup := tenantAdminGroup.Group("/ldap-directory")
+	ldapDirectoryGroup.Get("/", tenant2.GetLDAPDirectoryHandler)
+	ldapDirectoryGroup.Put("/", tenant2.SaveLDAPDirectoryHandler)
+	ldapDirectoryGroup.Delete("/", tenant2.DeleteLDAPDirectoryHandler)
+	ldapDirectoryGroup.Post("/test", tenant2.TestLDAPDirectoryHandler)
+	ldapDirectoryGroup.Post("/sync", tenant2.SyncLDAPDirectoryHandler)
+	ldapDirectoryGroup.Post("/links", tenant2.LinkLDAPUserHandler)
+
 	// 租户订阅管理（读写，所有租户成员可访问）
 	tenantSubscriptionMgmtGroup := tenantAdminGroup.Group("/subscription")
 
@@ -257,6 +282,17 @@ func RegisterTenantRoutes(v1 fiber.Router) {
 	tenantAppGroup.Put("/:app_id/roles/:role_id", app_rbac2.UpdateAppRole)
 	tenantAppGroup.Delete("/:app_id/roles/:role_id", app_rbac2.DeleteAppRole)
 
+	// 应用声明映射路由
+	tenantAppGroup.Get("/:app_id/claim-mappings", app_rbac2.GetAppClaimMappings)
+	tenantAppGroup.Post("/:app_id/claim-mappings", app_rbac2.CreateAppClaimMapping)
+	tenantAppGroup.Put("/:app_id/claim-mappings/:mapping_id", app_rbac2.UpdateAppClaimMapping)
+	tenantAppGroup.Delete("/:app_id/claim-mappings/:mapping_id", app_rbac2.DeleteAppClaimMapping)
+
+	// 应用 SAML 服务提供方
+	tenantAppGroup.Get("/:app_id/saml", tenant2.GetAppSAMLHandler)
+	tenantAppGroup.Put("/:app_id/saml", tenant2.SaveAppSAMLHandler)
+	tenantAppGroup.Delete("/:app_id/saml", tenant2.DeleteAppSAMLHandler)
+
 	// 应用用户管理路由（包含权限）
 	tenantAppGroup.Get("/:app_id/users", app_rbac2.GetAppUsers)
 	tenantAppGroup.Get("/:app_id/users/by-status", app_user.GetAppUsersByStatusHandler)
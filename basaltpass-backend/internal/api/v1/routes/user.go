@@ -2,6 +2,7 @@ package routes
 
 import (
 	"basaltpass-backend/internal/handler/public/app/app_user"
+	"basaltpass-backend/internal/handler/public/oauth"
 	"basaltpass-backend/internal/handler/public/order"
 	"basaltpass-backend/internal/handler/public/payment"
 	"basaltpass-backend/internal/handler/public/subscription"
@@ -11,6 +12,7 @@ import (
 	userSecurity "basaltpass-backend/internal/handler/user/security"
 	userTeam "basaltpass-backend/internal/handler/user/team"
 	"basaltpass-backend/internal/middleware"
+	"basaltpass-backend/internal/middleware/ratelimit"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -45,6 +47,10 @@ func RegisterUserRoutes(v1 fiber.Router) {
 	userGroup.Get("/apps", app_user.GetUserAppsHandler)
 	userGroup.Delete("/apps/:app_id", app_user.RevokeUserAppHandler)
 
+	// OAuth2 设备授权确认（RFC 8628 用户码验证）
+	userGroup.Get("/oauth/device", ratelimit.DeviceVerificationRateLimit(), oauth.DeviceVerificationInfoHandler)
+	userGroup.Post("/oauth/device", ratelimit.DeviceVerificationRateLimit(), oauth.DeviceVerificationHandler)
+
 	// 用户搜索路由 (需要JWT认证)
 	usersGroup := v1.Group("/users", middleware.JWTMiddleware())
 	usersGroup.Get("/search", user.SearchHandler)
@@ -55,6 +61,7 @@ func RegisterUserRoutes(v1 fiber.Router) {
 	securityGroup.Post("/2fa/setup", userSecurity.SetupHandler)
 	securityGroup.Post("/2fa/verify", userSecurity.VerifyHandler)
 	securityGroup.Post("/2fa/disable", userSecurity.Disable2FAHandler)
+	securityGroup.Post("/2fa/recovery-codes", userSecurity.RegenerateRecoveryCodesHandler)
 	securityGroup.Post("/email/verify", userSecurity.VerifyEmailHandler)
 	securityGroup.Post("/email/resend", userSecurity.SendEmailVerificationHandler)
 	securityGroup.Post("/phone/verify", userSecurity.VerifyPhoneHandler)
@@ -69,6 +76,16 @@ func RegisterUserRoutes(v1 fiber.Router) {
 	// 登录历史（需要认证）
 	securityGroup.Get("/login-history", userSecurity.GetLoginHistoryHandler)
 
+	// 登录会话（查看在线设备、远程退出）
+	securityGroup.Get("/sessions", userSecurity.ListSessionsHandler)
+	securityGroup.Delete("/sessions", userSecurity.RevokeAllSessionsHandler)
+	securityGroup.Delete("/sessions/:session_id", userSecurity.RevokeSessionHandler)
+
+	// 外部身份绑定
+	securityGroup.Get("/identities", userSecurity.ListIdentitiesHandler)
+	securityGroup.Post("/identities/:provider_id/link", userSecurity.LinkIdentityHandler)
+	securityGroup.Delete("/identities/:id", userSecurity.UnlinkIdentityHandler)
+
 	// 通知路由
 	notifGroup := v1.Group("/notifications", middleware.JWTMiddleware())
 	notifGroup.Get("/", userNotif.ListHandler)
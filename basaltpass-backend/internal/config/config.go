@@ -18,6 +18,10 @@ type Config struct {
 	Server struct {
 		// Address in host:port or :port format, e.g. ":8101"
 		Address string `mapstructure:"address"`
+		// PublicURL is the externally visible base URL of the API, e.g. "https://auth.example.com".
+		// The OIDC issuer, endpoint URLs and client assertion audiences are derived from it;
+		// the request Host header is used only when it is empty.
+		PublicURL string `mapstructure:"public_url"`
 	} `mapstructure:"server"`
 
 	Database struct {
@@ -129,6 +133,11 @@ func Load(path string) (*Config, error) {
 	// Defaults
 	v.SetDefault("env", "develop")
 	v.SetDefault("server.address", ":8101")
+	if strings.EqualFold(v.GetString("env"), "develop") {
+		v.SetDefault("server.public_url", "http://localhost:8101")
+	} else {
+		v.SetDefault("server.public_url", "")
+	}
 	v.SetDefault("seeding.enabled", false)
 	v.SetDefault("database.driver", "sqlite")
 	v.SetDefault("database.path", "basaltpass.db")
@@ -236,6 +245,11 @@ func Get() *Config {
 	return &cfg
 }
 
+// PublicURL returns the configured public base URL of the API without a trailing slash.
+func PublicURL() string {
+	return strings.TrimRight(strings.TrimSpace(Get().Server.PublicURL), "/")
+}
+
 // IsDevelop returns true when env is "develop" (default)
 func IsDevelop() bool { return strings.EqualFold(Get().Env, "develop") }
 
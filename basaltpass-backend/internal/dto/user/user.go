@@ -6,20 +6,22 @@ import (
 
 // AdminUserListResponse 管理员用户列表响应
 type AdminUserListResponse struct {
-	ID                uint               `json:"id"`
-	Email             string             `json:"email"`
-	Phone             string             `json:"phone"`
-	Nickname          string             `json:"nickname"`
-	AvatarURL         string             `json:"avatar_url"`
-	EmailVerified     bool               `json:"email_verified"`
-	PhoneVerified     bool               `json:"phone_verified"`
-	TwoFAEnabled      bool               `json:"two_fa_enabled"`
-	Banned            bool               `json:"banned"`
-	LastLoginAt       *time.Time         `json:"last_login_at"`
-	CreatedAt         time.Time          `json:"created_at"`
-	UpdatedAt         time.Time          `json:"updated_at"`
-	TenantMemberships []TenantMembership `json:"tenant_memberships"`
-	GlobalRoles       []GlobalRole       `json:"global_roles"`
+	ID                  uint               `json:"id"`
+	Email               string             `json:"email"`
+	Phone               string             `json:"phone"`
+	Nickname            string             `json:"nickname"`
+	AvatarURL           string             `json:"avatar_url"`
+	EmailVerified       bool               `json:"email_verified"`
+	PhoneVerified       bool               `json:"phone_verified"`
+	TwoFAEnabled        bool               `json:"two_fa_enabled"`
+	Banned              bool               `json:"banned"`
+	LockedUntil         *time.Time         `json:"locked_until,omitempty"` // 登录失败锁定截止时间，未锁定时为空
+	FailedLoginAttempts int                `json:"failed_login_attempts"`  // 当前窗口内的连续登录失败次数
+	LastLoginAt         *time.Time         `json:"last_login_at"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+	TenantMemberships   []TenantMembership `json:"tenant_memberships"`
+	GlobalRoles         []GlobalRole       `json:"global_roles"`
 }
 
 // TenantMembership 租户成员信息
@@ -102,6 +104,16 @@ type BanUserRequest struct {
 	Comment  string     `json:"comment,omitempty"`
 }
 
+// ResetUserMFARequest 管理员重置用户两步验证请求。
+// 操作者需重新验证身份：有本地密码时提交 Password，否则提交自己的 TOTP 验证码 Code
+type ResetUserMFARequest struct {
+	Password       string `json:"password,omitempty"`
+	Code           string `json:"code,omitempty"`
+	Reason         string `json:"reason"`
+	RemovePasskeys bool   `json:"remove_passkeys,omitempty"`
+	TenantID       *uint  `json:"tenant_id,omitempty"` // 仅重置指定租户；为空时重置全部租户（仅平台管理员）
+}
+
 // AdminUserListRequest 管理员用户列表查询请求
 type AdminUserListRequest struct {
 	Page           int        `query:"page"`
@@ -122,6 +134,7 @@ type UserStatsResponse struct {
 	TotalUsers        int64 `json:"total_users"`
 	ActiveUsers       int64 `json:"active_users"`
 	BannedUsers       int64 `json:"banned_users"`
+	LockedUsers       int64 `json:"locked_users"`
 	VerifiedUsers     int64 `json:"verified_users"`
 	TwoFAEnabledUsers int64 `json:"two_fa_enabled_users"`
 	NewUsersToday     int64 `json:"new_users_today"`
@@ -3,6 +3,12 @@ package user
 import (
 	"basaltpass-backend/internal/common"
 	userdto "basaltpass-backend/internal/dto/user"
+	"basaltpass-backend/internal/handler/public/oauth"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/consolesession"
+	"basaltpass-backend/internal/service/mfa"
+	"errors"
+	"log"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -158,6 +164,15 @@ func BanUserHandler(c *fiber.Ctx) error {
 	action := "解封"
 	if req.Banned {
 		action = "封禁"
+
+		// 封禁后撤销该用户的控制台会话与OAuth令牌，并通知其授权过的应用登出
+		if _, err := consolesession.NewService(common.DB()).RevokeAll(uint(userID), "", model.ConsoleSessionRevokedAdmin); err != nil {
+			log.Printf("[BanUser] revoke console sessions for user %d failed: %v", userID, err)
+		}
+		if err := oauth.RevokeUserTokens(uint(userID)); err != nil {
+			log.Printf("[BanUser] revoke oauth tokens for user %d failed: %v", userID, err)
+		}
+		oauth.LogoutUser(c, uint(userID))
 	}
 
 	return c.JSON(fiber.Map{
@@ -165,6 +180,148 @@ func BanUserHandler(c *fiber.Ctx) error {
 	})
 }
 
+// ListUserSessionsHandler 获取用户的有效控制台登录会话
+// GET /tenant/users/:id/sessions
+func ListUserSessionsHandler(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的用户ID",
+		})
+	}
+
+	sessions, err := adminUserService.ListUserSessions(uint(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": sessions,
+	})
+}
+
+// RevokeUserSessionsHandler 撤销用户的全部控制台登录会话（强制下线）
+// DELETE /tenant/users/:id/sessions
+func RevokeUserSessionsHandler(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的用户ID",
+		})
+	}
+
+	operatorID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权的操作者",
+		})
+	}
+
+	revoked, err := adminUserService.RevokeUserSessions(uint(userID), operatorID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "用户会话已撤销",
+		"revoked": revoked,
+	})
+}
+
+// ResetUserMFAHandler 重置用户的两步验证（用户丢失身份验证器时），需操作者重新验证身份
+// POST /tenant/users/:id/mfa/reset
+func ResetUserMFAHandler(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的用户ID",
+		})
+	}
+
+	var req userdto.ResetUserMFARequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请求数据",
+		})
+	}
+
+	operatorID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权的操作者",
+		})
+	}
+
+	mfaSvc := mfa.NewService(common.DB())
+	if err := mfaSvc.VerifyOperator(operatorID, 0, req.Password, req.Code); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	result, err := mfaSvc.ResetMFA(mfa.ResetParams{
+		UserID:         uint(userID),
+		OperatorID:     operatorID,
+		TenantID:       req.TenantID,
+		RemovePasskeys: req.RemovePasskeys,
+		Reason:         req.Reason,
+		IP:             c.IP(),
+		UserAgent:      c.Get("User-Agent"),
+	})
+	if err != nil {
+		if errors.Is(err, mfa.ErrUserNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, mfa.ErrReasonRequired) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "重置两步验证失败",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "两步验证已重置",
+		"data":    result,
+	})
+}
+
+// UnlockUserHandler 解除用户因登录失败次数过多触发的锁定
+// POST /tenant/users/:id/unlock
+func UnlockUserHandler(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的用户ID",
+		})
+	}
+
+	operatorID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权的操作者",
+		})
+	}
+
+	if err := adminUserService.UnlockUser(uint(userID), operatorID, c.IP(), c.Get("User-Agent")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "用户已解锁",
+	})
+}
+
 // DeleteUserHandler 删除用户
 // DELETE /tenant/users/:id
 func DeleteUserHandler(c *fiber.Ctx) error {
@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	userdto "basaltpass-backend/internal/dto/user"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/consolesession"
+	"basaltpass-backend/internal/service/lockout"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -78,6 +81,8 @@ func (s *AdminUserService) GetUserList(req userdto.AdminUserListRequest) (*userd
 		query = query.Where("banned = ?", false)
 	case "banned":
 		query = query.Where("banned = ?", true)
+	case "locked":
+		query = query.Where("id IN (SELECT user_id FROM system_account_lockouts WHERE locked_until > ?)", time.Now())
 	case "verified":
 		query = query.Where("email_verified = ? AND phone_verified = ?", true, true)
 	case "unverified":
@@ -116,6 +121,7 @@ func (s *AdminUserService) GetUserList(req userdto.AdminUserListRequest) (*userd
 	for i, user := range users {
 		userResponses[i] = s.convertToAdminUserListResponse(user)
 	}
+	s.applyLockoutStates(userResponses)
 
 	totalPages := int((total + int64(req.Limit) - 1) / int64(req.Limit))
 
@@ -147,8 +153,10 @@ func (s *AdminUserService) GetUserDetail(userID uint) (*userdto.AdminUserDetailR
 		return nil, err
 	}
 
+	listResponse := []userdto.AdminUserListResponse{s.convertToAdminUserListResponse(user)}
+	s.applyLockoutStates(listResponse)
 	response := &userdto.AdminUserDetailResponse{
-		AdminUserListResponse: s.convertToAdminUserListResponse(user),
+		AdminUserListResponse: listResponse[0],
 	}
 
 	// 获取应用授权信息
@@ -294,6 +302,53 @@ func (s *AdminUserService) DeleteUser(userID uint) error {
 	return s.db.Delete(&user).Error
 }
 
+// ListUserSessions 获取用户的有效控制台登录会话
+func (s *AdminUserService) ListUserSessions(userID uint) ([]model.ConsoleSession, error) {
+	var user model.User
+	if err := s.db.Select("id").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	return consolesession.NewService(s.db).ListActive(userID)
+}
+
+// RevokeUserSessions 撤销用户的全部控制台登录会话并记录审计日志，返回撤销的数量
+func (s *AdminUserService) RevokeUserSessions(userID uint, operatorID uint) (int64, error) {
+	var user model.User
+	if err := s.db.Select("id").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("用户不存在")
+		}
+		return 0, err
+	}
+
+	var revoked int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		revoked, err = consolesession.NewService(tx).RevokeAll(userID, "", model.ConsoleSessionRevokedAdmin)
+		if err != nil {
+			return err
+		}
+
+		payloadBytes, err := json.Marshal(map[string]interface{}{
+			"target_user_id": userID,
+			"revoked":        revoked,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.AuditLog{
+			UserID: operatorID,
+			Action: "admin_revoke_user_sessions",
+			Data:   string(payloadBytes),
+		}).Error
+	})
+	return revoked, err
+}
+
 // GetUserStats 获取用户统计数据
 func (s *AdminUserService) GetUserStats() (*userdto.UserStatsResponse, error) {
 	var stats userdto.UserStatsResponse
@@ -307,6 +362,9 @@ func (s *AdminUserService) GetUserStats() (*userdto.UserStatsResponse, error) {
 	// 被封禁用户数
 	s.db.Model(&model.User{}).Where("banned = ?", true).Count(&stats.BannedUsers)
 
+	// 登录失败锁定中的用户数
+	s.db.Model(&model.AccountLockout{}).Where("locked_until > ?", time.Now()).Count(&stats.LockedUsers)
+
 	// 已验证用户数（邮箱或手机验证）
 	s.db.Model(&model.User{}).Where("email_verified = ? OR phone_verified = ?", true, true).Count(&stats.VerifiedUsers)
 
@@ -328,6 +386,18 @@ func (s *AdminUserService) GetUserStats() (*userdto.UserStatsResponse, error) {
 	return &stats, nil
 }
 
+// UnlockUser 解除用户因登录失败触发的锁定，并记录登录历史与审计日志
+func (s *AdminUserService) UnlockUser(userID uint, operatorID uint, ip, userAgent string) error {
+	var user model.User
+	if err := s.db.Select("id").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+	return lockout.NewService(s.db).Unlock(userID, operatorID, ip, userAgent)
+}
+
 // AssignGlobalRole 分配全局角色
 func (s *AdminUserService) AssignGlobalRole(userID uint, roleID uint) error {
 	// 检查用户是否存在
@@ -408,6 +478,29 @@ func (s *AdminUserService) convertToAdminUserListResponse(user model.User) userd
 	return response
 }
 
+// applyLockoutStates 批量填充登录失败锁定状态
+func (s *AdminUserService) applyLockoutStates(responses []userdto.AdminUserListResponse) {
+	userIDs := make([]uint, len(responses))
+	for i := range responses {
+		userIDs[i] = responses[i].ID
+	}
+	states, err := lockout.NewService(s.db).States(userIDs)
+	if err != nil {
+		log.Printf("[admin][warn] load account lockout states failed: %v", err)
+		return
+	}
+	for i := range responses {
+		state, ok := states[responses[i].ID]
+		if !ok {
+			continue
+		}
+		responses[i].FailedLoginAttempts = state.FailedCount
+		if state.IsLocked() {
+			responses[i].LockedUntil = state.LockedUntil
+		}
+	}
+}
+
 // getGlobalRoles 获取用户的全局角色
 func (s *AdminUserService) getGlobalRoles(userID uint) []userdto.GlobalRole {
 	var roles []model.Role
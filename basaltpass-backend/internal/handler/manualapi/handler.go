@@ -5,6 +5,7 @@ import (
 	oauthhandler "basaltpass-backend/internal/handler/public/oauth"
 	"basaltpass-backend/internal/model"
 	appsvc "basaltpass-backend/internal/service/app"
+	"encoding/json"
 	"errors"
 	"strconv"
 	"strings"
@@ -100,23 +101,53 @@ type ManualToggleAppStatusRequest struct {
 }
 
 type ManualOAuthClientRequest struct {
-	TenantID       *uint    `json:"tenant_id,omitempty"`
-	AppID          uint     `json:"app_id"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	RedirectURIs   []string `json:"redirect_uris"`
-	Scopes         []string `json:"scopes"`
-	AllowedOrigins []string `json:"allowed_origins"`
+	TenantID          *uint    `json:"tenant_id,omitempty"`
+	AppID             uint     `json:"app_id"`
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	RedirectURIs      []string `json:"redirect_uris"`
+	Scopes            []string `json:"scopes"`
+	GrantTypes        []string `json:"grant_types"`
+	AllowedOrigins    []string `json:"allowed_origins"`
+	AccessTokenFormat string   `json:"access_token_format"`
+
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   string   `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  string   `json:"frontchannel_logout_uri"`
+
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+	JWKS                    json.RawMessage `json:"jwks"`
+	JWKSURI                 string          `json:"jwks_uri"`
+
+	RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests"`
+
+	SubjectType         string `json:"subject_type"`
+	SectorIdentifierURI string `json:"sector_identifier_uri"`
 }
 
 type ManualUpdateOAuthClientRequest struct {
-	TenantID       *uint    `json:"tenant_id,omitempty"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	RedirectURIs   []string `json:"redirect_uris"`
-	Scopes         []string `json:"scopes"`
-	AllowedOrigins []string `json:"allowed_origins"`
-	IsActive       *bool    `json:"is_active"`
+	TenantID          *uint    `json:"tenant_id,omitempty"`
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	RedirectURIs      []string `json:"redirect_uris"`
+	Scopes            []string `json:"scopes"`
+	GrantTypes        []string `json:"grant_types"`
+	AllowedOrigins    []string `json:"allowed_origins"`
+	AccessTokenFormat string   `json:"access_token_format"`
+	IsActive          *bool    `json:"is_active"`
+
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   *string  `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  *string  `json:"frontchannel_logout_uri"`
+
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+	JWKS                    json.RawMessage `json:"jwks"`
+	JWKSURI                 *string         `json:"jwks_uri"`
+
+	RequirePushedAuthorizationRequests *bool `json:"require_pushed_authorization_requests"`
+
+	SubjectType         string  `json:"subject_type"`
+	SectorIdentifierURI *string `json:"sector_identifier_uri"`
 }
 
 func TenantCreateManualAPIKeyHandler(c *fiber.Ctx) error {
@@ -816,11 +847,26 @@ func ManualCreateOAuthClientHandler(c *fiber.Ctx) error {
 	}
 
 	createReq := oauthhandler.CreateClientRequest{
-		Name:           strings.TrimSpace(req.Name),
-		Description:    req.Description,
-		RedirectURIs:   req.RedirectURIs,
-		Scopes:         req.Scopes,
-		AllowedOrigins: req.AllowedOrigins,
+		Name:              strings.TrimSpace(req.Name),
+		Description:       req.Description,
+		RedirectURIs:      req.RedirectURIs,
+		Scopes:            req.Scopes,
+		GrantTypes:        req.GrantTypes,
+		AllowedOrigins:    req.AllowedOrigins,
+		AccessTokenFormat: req.AccessTokenFormat,
+
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   req.BackchannelLogoutURI,
+		FrontchannelLogoutURI:  req.FrontchannelLogoutURI,
+
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		JWKS:                    req.JWKS,
+		JWKSURI:                 req.JWKSURI,
+
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+
+		SubjectType:         req.SubjectType,
+		SectorIdentifierURI: req.SectorIdentifierURI,
 	}
 
 	client, createErr := clientService.CreateClientForApp(req.AppID, creatorID, &createReq)
@@ -855,12 +901,27 @@ func ManualUpdateOAuthClientHandler(c *fiber.Ctx) error {
 	}
 
 	updateReq := oauthhandler.UpdateClientRequest{
-		Name:           strings.TrimSpace(req.Name),
-		Description:    req.Description,
-		RedirectURIs:   req.RedirectURIs,
-		Scopes:         req.Scopes,
-		AllowedOrigins: req.AllowedOrigins,
-		IsActive:       req.IsActive,
+		Name:              strings.TrimSpace(req.Name),
+		Description:       req.Description,
+		RedirectURIs:      req.RedirectURIs,
+		Scopes:            req.Scopes,
+		GrantTypes:        req.GrantTypes,
+		AllowedOrigins:    req.AllowedOrigins,
+		AccessTokenFormat: req.AccessTokenFormat,
+		IsActive:          req.IsActive,
+
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   req.BackchannelLogoutURI,
+		FrontchannelLogoutURI:  req.FrontchannelLogoutURI,
+
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		JWKS:                    req.JWKS,
+		JWKSURI:                 req.JWKSURI,
+
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+
+		SubjectType:         req.SubjectType,
+		SectorIdentifierURI: req.SectorIdentifierURI,
 	}
 
 	client, err := clientService.UpdateClient(clientID, &updateReq)
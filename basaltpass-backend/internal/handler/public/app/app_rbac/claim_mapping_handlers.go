@@ -0,0 +1,178 @@
+package app_rbac
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/claimmapping"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ClaimMappingRequest 创建/更新声明映射请求
+type ClaimMappingRequest struct {
+	ClaimName string `json:"claim_name"`
+	Source    string `json:"source"`
+	Value     string `json:"value"`
+	Scope     string `json:"scope"`
+	Targets   string `json:"targets"`
+}
+
+// GetAppClaimMappings 获取应用声明映射列表
+func GetAppClaimMappings(c *fiber.Ctx) error {
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	tenantID := c.Locals("tenantID").(uint)
+
+	// 验证应用属于当前租户
+	var app model.App
+	err = common.DB().Where("id = ? AND tenant_id = ?", appID, tenantID).First(&app).Error
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "应用不存在"})
+	}
+
+	var mappings []model.AppClaimMapping
+	err = common.DB().Where("app_id = ?", appID).Order("id").Find(&mappings).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取声明映射失败"})
+	}
+
+	return c.JSON(fiber.Map{"claim_mappings": mappings})
+}
+
+// CreateAppClaimMapping 创建应用声明映射
+func CreateAppClaimMapping(c *fiber.Ctx) error {
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	var req ClaimMappingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	tenantID := c.Locals("tenantID").(uint)
+
+	// 验证应用属于当前租户
+	var app model.App
+	err = common.DB().Where("id = ? AND tenant_id = ?", appID, tenantID).First(&app).Error
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "应用不存在"})
+	}
+
+	mapping := model.AppClaimMapping{
+		AppID:     uint(appID),
+		TenantID:  tenantID,
+		ClaimName: req.ClaimName,
+		Source:    req.Source,
+		Value:     req.Value,
+		Scope:     req.Scope,
+		Targets:   req.Targets,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := claimmapping.ValidateMapping(&mapping); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// 检查声明名称是否重复
+	var existing model.AppClaimMapping
+	err = common.DB().Where("app_id = ? AND claim_name = ?", appID, mapping.ClaimName).First(&existing).Error
+	if err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "声明名称已存在"})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "检查声明名称失败"})
+	}
+
+	if err := common.DB().Create(&mapping).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "创建声明映射失败"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(mapping)
+}
+
+// UpdateAppClaimMapping 更新应用声明映射
+func UpdateAppClaimMapping(c *fiber.Ctx) error {
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	mappingID, err := strconv.ParseUint(c.Params("mapping_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的声明映射ID"})
+	}
+
+	var req ClaimMappingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	tenantID := c.Locals("tenantID").(uint)
+
+	// 验证声明映射存在且属于当前租户和应用
+	var mapping model.AppClaimMapping
+	err = common.DB().Where("id = ? AND app_id = ? AND tenant_id = ?", mappingID, appID, tenantID).First(&mapping).Error
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "声明映射不存在"})
+	}
+
+	mapping.ClaimName = req.ClaimName
+	mapping.Source = req.Source
+	mapping.Value = req.Value
+	mapping.Scope = req.Scope
+	mapping.Targets = req.Targets
+	if err := claimmapping.ValidateMapping(&mapping); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// 改名时检查声明名称是否重复
+	var count int64
+	common.DB().Model(&model.AppClaimMapping{}).
+		Where("app_id = ? AND claim_name = ? AND id <> ?", appID, mapping.ClaimName, mapping.ID).
+		Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "声明名称已存在"})
+	}
+
+	mapping.UpdatedAt = time.Now()
+	if err := common.DB().Save(&mapping).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "更新声明映射失败"})
+	}
+
+	return c.JSON(mapping)
+}
+
+// DeleteAppClaimMapping 删除应用声明映射
+func DeleteAppClaimMapping(c *fiber.Ctx) error {
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	mappingID, err := strconv.ParseUint(c.Params("mapping_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的声明映射ID"})
+	}
+
+	tenantID := c.Locals("tenantID").(uint)
+
+	result := common.DB().Where("id = ? AND app_id = ? AND tenant_id = ?", mappingID, appID, tenantID).Delete(&model.AppClaimMapping{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "删除声明映射失败"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "声明映射不存在"})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
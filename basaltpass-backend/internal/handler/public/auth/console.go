@@ -13,6 +13,7 @@ import (
 	"basaltpass-backend/internal/config"
 	"basaltpass-backend/internal/model"
 	authsvc "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/consolesession"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -169,6 +170,16 @@ func mustGetUserID(c *fiber.Ctx) (uint, error) {
 	return uid, nil
 }
 
+// currentAuthContext 读取当前访问令牌（由 JWTMiddleware 放入 Locals）中的 auth_time / amr
+func currentAuthContext(c *fiber.Ctx) authsvc.AuthContext {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok || token == nil {
+		return authsvc.AuthContext{}
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	return authsvc.AuthContextFromClaims(claims)
+}
+
 // ConsoleAuthorizeHandler issues a short-lived console switch code.
 //
 // POST /api/v1/auth/console/authorize (JWT required)
@@ -234,6 +245,8 @@ func ConsoleAuthorizeHandler(c *fiber.Ctx) error {
 	}
 	claims["exp"] = exp
 	claims["jti"] = jti
+	// 切换控制台不是重新认证，沿用当前登录的认证上下文
+	currentAuthContext(c).SetClaims(claims)
 
 	code, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(common.MustJWTSecret())
 	if err != nil {
@@ -326,7 +339,7 @@ func ConsoleExchangeHandler(c *fiber.Ctx) error {
 		tenantID = 0
 	}
 
-	tokens, err := authsvc.GenerateTokenPairWithTenantAndScope(uid, tenantID, scope)
+	tokens, err := authsvc.GenerateTokenPairWithAuthContext(uid, tenantID, scope, authsvc.AuthContextFromClaims(claims))
 	if err != nil {
 		log.Printf("[console][error] Failed to generate tokens in exchange handler: userID=%d, tenantID=%d, scope=%s, error=%v", uid, tenantID, scope, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to generate tokens"})
@@ -357,6 +370,10 @@ func ConsoleExchangeHandler(c *fiber.Ctx) error {
 		Domain:   "",
 	})
 
+	if err := consolesession.NewService(common.DB()).RecordClient(tokens.SessionID, c.IP(), c.Get("User-Agent")); err != nil {
+		log.Printf("[console][warn] Failed to record session client: sid=%s, error=%v", tokens.SessionID, err)
+	}
+
 	return c.JSON(fiber.Map{
 		"access_token": tokens.AccessToken,
 		"scope":        scope,
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/config"
+	security "basaltpass-backend/internal/handler/user/security"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	auth2 "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/federation"
+	"basaltpass-backend/internal/service/lockout"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// federationErrorCode 将联合登录错误映射为前端可识别的错误码（通过 federation_error 查询参数传递）
+func federationErrorCode(err error) string {
+	switch {
+	case errors.Is(err, federation.ErrLinkRequired):
+		return "link_required"
+	case errors.Is(err, federation.ErrRegistrationDisabled):
+		return "registration_disabled"
+	case errors.Is(err, federation.ErrTenantLoginDisabled):
+		return "login_disabled"
+	case errors.Is(err, federation.ErrEmailRequired):
+		return "email_required"
+	case errors.Is(err, federation.ErrEmailNotVerified):
+		return "email_unverified"
+	case errors.Is(err, federation.ErrIdentityLinkedElsewhere):
+		return "linked_elsewhere"
+	case errors.Is(err, federation.ErrProviderAlreadyLinked):
+		return "already_linked"
+	case errors.Is(err, federation.ErrUserBanned):
+		return "banned"
+	case errors.Is(err, federation.ErrInvalidState), errors.Is(err, federation.ErrProviderNotFound):
+		return "invalid_state"
+	case errors.Is(err, federation.ErrUpstream):
+		return "upstream_error"
+	default:
+		return "server_error"
+	}
+}
+
+// federationLoginPage 返回提供方所属租户的登录页地址
+// federatedLoginErrorCode 登录阶段（锁定、租户成员关系等）的失败原因
+func federatedLoginErrorCode(err error) string {
+	var lockedErr *lockout.LockedError
+	switch {
+	case errors.As(err, &lockedErr):
+		return "account_locked"
+	case errors.Is(err, auth2.ErrAccountDisabled):
+		return "banned"
+	case errors.Is(err, auth2.ErrTenantLoginDisabled):
+		return "login_disabled"
+	case errors.Is(err, auth2.ErrInvalidCredentials):
+		return "not_member"
+	default:
+		return "server_error"
+	}
+}
+
+func federationLoginPage(provider *model.IdentityProvider) string {
+	if provider != nil && provider.TenantID > 0 {
+		var tenant model.Tenant
+		if err := common.DB().Select("id", "code").First(&tenant, provider.TenantID).Error; err == nil && tenant.Code != "" {
+			return "/auth/tenant/" + url.PathEscape(tenant.Code) + "/login"
+		}
+	}
+	return "/login"
+}
+
+func uiURL(path string, params url.Values) string {
+	u := strings.TrimRight(config.Get().UI.BaseURL, "/") + path
+	if len(params) > 0 {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		u += sep + params.Encode()
+	}
+	return u
+}
+
+// FederationProvidersHandler 列出租户登录页可用的外部身份提供方
+// GET /api/v1/auth/federation/providers?tenant_id=
+func FederationProvidersHandler(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseUint(c.Query("tenant_id", "0"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid tenant_id"})
+	}
+
+	providers, err := federation.NewService(common.DB()).ListEnabledProviders(uint(tenantID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load identity providers"})
+	}
+
+	items := make([]fiber.Map, 0, len(providers))
+	for _, p := range providers {
+		items = append(items, fiber.Map{
+			"id":     p.ID,
+			"slug":   p.Slug,
+			"name":   p.Name,
+			"preset": p.Preset,
+		})
+	}
+	return c.JSON(fiber.Map{"data": items})
+}
+
+// FederationAuthorizeHandler 跳转到上游身份提供方登录
+// GET /api/v1/auth/federation/:provider_id/authorize?return_to=
+func FederationAuthorizeHandler(c *fiber.Ctx) error {
+	providerID, err := strconv.ParseUint(c.Params("provider_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid provider id"})
+	}
+
+	fedSvc := federation.NewService(common.DB())
+	provider, err := fedSvc.EnabledProvider(uint(providerID))
+	if err != nil {
+		if errors.Is(err, federation.ErrProviderNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load identity provider"})
+	}
+
+	authorization, err := fedSvc.AuthorizationURL(provider, federation.AuthorizeParams{
+		Intent:      model.FederatedIntentLogin,
+		RedirectURI: federation.CallbackURL(c.BaseURL()),
+		ReturnTo:    federation.SafeReturnTo(c.Query("return_to")),
+	})
+	if err != nil {
+		log.Printf("[federation][error] build authorization url for provider %d failed: %v", provider.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "identity provider unavailable"})
+	}
+	security.SetFederationBindingCookie(c, authorization.Binding)
+	return c.Redirect(authorization.URL, fiber.StatusFound)
+}
+
+// FederationCallbackHandler 上游身份提供方回调：登录（必要时即时创建账号）或完成绑定，然后跳回前端
+// GET /api/v1/auth/federation/callback
+func FederationCallbackHandler(c *fiber.Ctx) error {
+	if upstreamErr := c.Query("error"); upstreamErr != "" {
+		log.Printf("[federation][warn] upstream returned error=%s description=%s", upstreamErr, c.Query("error_description"))
+	}
+
+	// 绑定 Cookie 一次性使用，读取后立即清除
+	binding := c.Cookies(federation.BindingCookieName)
+	c.Cookie(&fiber.Cookie{
+		Name:     federation.BindingCookieName,
+		Value:    "",
+		Path:     federation.CallbackPath,
+		MaxAge:   -1,
+		HTTPOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: "Lax",
+	})
+
+	result, err := federation.NewService(common.DB()).Complete(c.Query("state"), c.Query("code"), binding)
+	if err != nil {
+		log.Printf("[federation][warn] callback failed: %v", err)
+		params := url.Values{"federation_error": {federationErrorCode(err)}}
+		if result != nil && result.Intent == model.FederatedIntentLink {
+			return c.Redirect(uiURL("/security", params), fiber.StatusFound)
+		}
+		var provider *model.IdentityProvider
+		if result != nil {
+			provider = result.Provider
+			if result.ReturnTo != "" {
+				params.Set("redirect", result.ReturnTo)
+			}
+		}
+		return c.Redirect(uiURL(federationLoginPage(provider), params), fiber.StatusFound)
+	}
+
+	provider, user := result.Provider, result.User
+	providerRef := strconv.FormatUint(uint64(provider.ID), 10)
+
+	// 绑定：返回安全设置页
+	if result.Intent == model.FederatedIntentLink {
+		aduit.LogAudit(user.ID, "绑定外部身份", "identity_provider", providerRef, c.IP(), c.Get("User-Agent"))
+		returnTo := result.ReturnTo
+		if returnTo == "" {
+			returnTo = "/security"
+		}
+		return c.Redirect(uiURL(returnTo, url.Values{"federation": {"linked"}}), fiber.StatusFound)
+	}
+
+	// 登录：与其他登录方式一样经过锁定、租户成员关系与二次验证判定
+	loginPage := federationLoginPage(provider)
+	login, err := svc.FederatedLogin(user.ID, provider.TenantID)
+	if err != nil {
+		log.Printf("[federation][warn] login for userID=%d via provider %d rejected: %v", user.ID, provider.ID, err)
+		return c.Redirect(uiURL(loginPage, url.Values{"federation_error": {federatedLoginErrorCode(err)}}), fiber.StatusFound)
+	}
+	if result.Provisioned {
+		aduit.LogAudit(user.ID, "外部身份注册", "identity_provider", providerRef, c.IP(), c.Get("User-Agent"))
+	}
+	if login.Need2FA {
+		// pre_auth_token 放在片段中，不进入服务器日志与 Referer；登录页读取后进入二次验证步骤
+		var params url.Values
+		if result.ReturnTo != "" {
+			params = url.Values{"redirect": {result.ReturnTo}}
+		}
+		fragment := url.Values{
+			"pre_auth_token":        {login.PreAuthToken},
+			"2fa_type":              {login.TwoFAType},
+			"available_2fa_methods": {strings.Join(login.Available2FAMethods, ",")},
+		}
+		return c.Redirect(uiURL(loginPage, params)+"#"+fragment.Encode(), fiber.StatusFound)
+	}
+
+	// 签发控制台令牌并写入 Cookie，前端 /oauth-success 通过刷新令牌换取访问令牌
+	setAuthCookies(c, auth2.ConsoleScopeUser, login.TokenPair)
+
+	userID, clientIP, userAgent := user.ID, c.IP(), c.Get("User-Agent")
+	go func() {
+		if err := security.RecordLoginSuccess(userID, clientIP, userAgent); err != nil {
+			log.Printf("failed to record login history: %v", err)
+		}
+	}()
+
+	var params url.Values
+	if result.ReturnTo != "" {
+		params = url.Values{"redirect": {result.ReturnTo}}
+	}
+	return c.Redirect(uiURL("/oauth-success", params), fiber.StatusFound)
+}
@@ -3,12 +3,17 @@ package auth
 import (
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/handler/public/oauth"
 	security "basaltpass-backend/internal/handler/user/security"
 	"basaltpass-backend/internal/model"
 	auth2 "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/consolesession"
+	"basaltpass-backend/internal/service/lockout"
 	"errors"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -73,9 +78,11 @@ func setCookie(c *fiber.Ctx, name, value string, maxAge int, isProd bool) {
 	})
 }
 
-func setAuthCookies(c *fiber.Ctx, scope, accessToken, refreshToken string) {
+// setAuthCookies 写入控制台令牌Cookie，并记录会话当前的客户端信息（IP / User-Agent）
+func setAuthCookies(c *fiber.Ctx, scope string, tokens auth2.TokenPair) {
 	isProd := config.IsProduction()
 	normalizedScope := normalizeScope(scope)
+	accessToken, refreshToken := tokens.AccessToken, tokens.RefreshToken
 
 	// Keep unscoped cookies for OAuth hosted login compatibility.
 	setCookie(c, "refresh_token", refreshToken, 7*24*60*60, isProd)
@@ -86,6 +93,10 @@ func setAuthCookies(c *fiber.Ctx, scope, accessToken, refreshToken string) {
 		setCookie(c, "refresh_token_"+normalizedScope, refreshToken, 7*24*60*60, isProd)
 		setCookie(c, "access_token_"+normalizedScope, accessToken, 15*60, isProd)
 	}
+
+	if err := consolesession.NewService(common.DB()).RecordClient(tokens.SessionID, c.IP(), c.Get("User-Agent")); err != nil {
+		log.Printf("failed to record session client: %v", err)
+	}
 }
 
 type switchUserTenantIdentityRequest struct {
@@ -141,15 +152,18 @@ func SwitchUserTenantIdentityHandler(c *fiber.Ctx) error {
 		}
 	}
 
-	tokens, err := auth2.GenerateTokenPairWithTenantAndScope(uid, targetTenantID, auth2.ConsoleScopeUser)
+	// 切换身份沿用当前会话，只轮换刷新令牌
+	authCtx := currentAuthContext(c)
+	authCtx.SessionID, _ = c.Locals("sessionID").(string)
+	tokens, err := auth2.GenerateTokenPairWithAuthContext(uid, targetTenantID, auth2.ConsoleScopeUser, authCtx)
 	if err != nil {
-		if errors.Is(err, auth2.ErrTenantLoginDisabled) {
+		if errors.Is(err, auth2.ErrTenantLoginDisabled) || errors.Is(err, auth2.ErrAccountDisabled) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to switch identity"})
 	}
 
-	setAuthCookies(c, auth2.ConsoleScopeUser, tokens.AccessToken, tokens.RefreshToken)
+	setAuthCookies(c, auth2.ConsoleScopeUser, tokens)
 	return c.JSON(fiber.Map{
 		"access_token": tokens.AccessToken,
 		"tenant_id":    targetTenantID,
@@ -166,12 +180,18 @@ func LoginHandler(c *fiber.Ctx) error {
 	req.Scope = normalizeScope(c.Get("X-Auth-Scope"))
 	// Hydrate legacy fields for backward compatibility with old clients.
 	hydrateLegacyLoginFields(c, &req)
+	req.IP = c.IP()
+	req.UserAgent = c.Get("User-Agent")
 
 	result, err := svc.LoginV2(req)
 	if err != nil {
 		if errors.Is(err, auth2.ErrMissingCredentials) {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 		}
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			return respondAccountLocked(c, lockedErr)
+		}
 		if errors.Is(err, auth2.ErrServiceUnavailable) {
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
 				"error": auth2.ErrServiceUnavailable.Error(),
@@ -183,7 +203,7 @@ func LoginHandler(c *fiber.Ctx) error {
 		if errors.Is(err, auth2.ErrTenantAccountOnly) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 		}
-		if errors.Is(err, auth2.ErrTenantLoginDisabled) {
+		if errors.Is(err, auth2.ErrTenantLoginDisabled) || errors.Is(err, auth2.ErrAccountDisabled) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
@@ -198,7 +218,7 @@ func LoginHandler(c *fiber.Ctx) error {
 			"available_2fa_methods": result.Available2FAMethods,
 		})
 	}
-	setAuthCookies(c, c.Get("X-Auth-Scope"), result.TokenPair.AccessToken, result.TokenPair.RefreshToken)
+	setAuthCookies(c, c.Get("X-Auth-Scope"), result.TokenPair)
 
 	userID := result.UserID
 	clientIP := c.IP()
@@ -220,6 +240,19 @@ func LoginHandler(c *fiber.Ctx) error {
 	})
 }
 
+// respondAccountLocked 账号锁定期内返回 423，并通过 Retry-After 告知剩余秒数
+func respondAccountLocked(c *fiber.Ctx, lockedErr *lockout.LockedError) error {
+	retryAfter := int(time.Until(lockedErr.Until).Seconds()) + 1
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+		"error":        lockedErr.Error(),
+		"locked_until": lockedErr.Until.UTC().Format(time.RFC3339),
+	})
+}
+
 // RefreshHandler handles POST /auth/refresh
 func RefreshHandler(c *fiber.Ctx) error {
 	// Scope-aware refresh: for tenant/admin consoles, use dedicated refresh cookies.
@@ -239,16 +272,51 @@ func RefreshHandler(c *fiber.Ctx) error {
 	}
 	tokens, err := svc.Refresh(rt)
 	if err != nil {
-		if errors.Is(err, auth2.ErrTenantLoginDisabled) {
+		if errors.Is(err, auth2.ErrTenantLoginDisabled) || errors.Is(err, auth2.ErrAccountDisabled) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 	}
-	setAuthCookies(c, scope, tokens.AccessToken, tokens.RefreshToken)
+	setAuthCookies(c, scope, tokens)
 
 	return c.JSON(fiber.Map{"access_token": tokens.AccessToken})
 }
 
+// LogoutHandler handles POST /auth/logout
+// 清除当前控制台的会话Cookie并撤销当前会话，通知用户授权过的应用登出（OIDC Back-Channel / Front-Channel Logout）。
+// 返回的 frontchannel_logout_uris 需由前端以隐藏 iframe 加载。
+func LogoutHandler(c *fiber.Ctx) error {
+	scope := normalizeScope(c.Get("X-Auth-Scope"))
+	isProd := config.IsProduction()
+
+	setCookie(c, "refresh_token", "", -1, isProd)
+	setCookie(c, "access_token", "", -1, isProd)
+	if scope != "user" {
+		setCookie(c, "refresh_token_"+scope, "", -1, isProd)
+		setCookie(c, "access_token_"+scope, "", -1, isProd)
+	}
+
+	var frontchannelURIs []string
+	if userID, ok := c.Locals("userID").(uint); ok {
+		if sessionID, ok := c.Locals("sessionID").(string); ok {
+			if err := consolesession.NewService(common.DB()).Revoke(userID, sessionID, model.ConsoleSessionRevokedLogout); err != nil && !errors.Is(err, consolesession.ErrSessionInactive) {
+				log.Printf("failed to revoke console session: %v", err)
+			}
+		}
+		frontchannelURIs = oauth.LogoutUser(c, userID)
+	}
+	if frontchannelURIs == nil {
+		frontchannelURIs = []string{}
+	}
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"frontchannel_logout_uris": frontchannelURIs,
+		},
+		"message": "已退出登录",
+	})
+}
+
 // Verify2FAHandler handles POST /auth/verify-2fa
 func Verify2FAHandler(c *fiber.Ctx) error {
 	var req auth2.Verify2FARequest
@@ -257,12 +325,12 @@ func Verify2FAHandler(c *fiber.Ctx) error {
 	}
 	tokens, err := svc.Verify2FA(req)
 	if err != nil {
-		if errors.Is(err, auth2.ErrTenantLoginDisabled) {
+		if errors.Is(err, auth2.ErrTenantLoginDisabled) || errors.Is(err, auth2.ErrAccountDisabled) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
 		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
 	}
-	setAuthCookies(c, c.Get("X-Auth-Scope"), tokens.AccessToken, tokens.RefreshToken)
+	setAuthCookies(c, c.Get("X-Auth-Scope"), tokens)
 
 	// Extract user identity from the pre_auth_token (already validated inside Verify2FA).
 	// ParsePreAuthToken will not fail here because Verify2FA already succeeded.
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	authsvc "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/signingkey"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConsoleJWKSHandler 发布控制台令牌的验证公钥（active、next 以及保留期内的 retired），
+// 供内部服务离线验证控制台访问令牌。仍使用 HS256 且从未生成过密钥时返回空集合。
+//
+// GET /api/v1/auth/jwks
+func ConsoleJWKSHandler(c *fiber.Ctx) error {
+	keys := authsvc.ConsoleSigningKeys()
+
+	var published []*signingkey.Key
+	var err error
+	if authsvc.ConsoleAlgorithm() == authsvc.AlgorithmHS256 {
+		// 不主动生成密钥，只发布切换回 HS256 之前仍在有效期内的公钥
+		if since, firstErr := keys.FirstCreatedAt(); firstErr != nil {
+			err = firstErr
+		} else if !since.IsZero() {
+			published, err = keys.PublishedKeys()
+		}
+	} else {
+		published, err = keys.PublishedKeys()
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load signing keys"})
+	}
+
+	jwks := make([]interface{}, 0, len(published))
+	for _, k := range published {
+		jwks = append(jwks, signingkey.JWK(k))
+	}
+
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(fiber.Map{"keys": jwks})
+}
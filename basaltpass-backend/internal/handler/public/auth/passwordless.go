@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+
+	security "basaltpass-backend/internal/handler/user/security"
+	"basaltpass-backend/internal/model"
+	auth2 "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/federation"
+	"basaltpass-backend/internal/service/lockout"
+	"basaltpass-backend/internal/service/verification"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	passwordlessOnce sync.Once
+	passwordlessSvc  *verification.Service
+)
+
+// passwordlessService 延迟创建验证码服务，确保邮件配置已加载
+func passwordlessService() *verification.Service {
+	passwordlessOnce.Do(func() {
+		passwordlessSvc = verification.NewService()
+	})
+	return passwordlessSvc
+}
+
+// PasswordlessMethodsHandler 返回登录入口可用的免密登录渠道
+// GET /api/v1/auth/passwordless/methods?tenant_id=
+func PasswordlessMethodsHandler(c *fiber.Ctx) error {
+	tenantID, err := strconv.ParseUint(c.Query("tenant_id", "0"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid tenant_id"})
+	}
+
+	methods := fiber.Map{}
+	for _, channel := range []model.ChallengeChannel{model.ChallengeChannelEmail, model.ChallengeChannelSMS} {
+		enabled, err := verification.PasswordlessEnabled(uint(tenantID), channel)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load login settings"})
+		}
+		methods[string(channel)] = enabled
+	}
+	return c.JSON(fiber.Map{"data": methods})
+}
+
+// PasswordlessStartHandler 发送免密登录链接或验证码
+// POST /api/v1/auth/passwordless/start
+func PasswordlessStartHandler(c *fiber.Ctx) error {
+	var req verification.StartLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request format"})
+	}
+	req.IP = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+	req.LinkURL = uiURL("/auth/magic-link", nil)
+	req.ReturnTo = federation.SafeReturnTo(req.ReturnTo)
+
+	resp, err := passwordlessService().StartLogin(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, verification.ErrPasswordlessDisabled), errors.Is(err, verification.ErrLoginDisabled):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, verification.ErrDeliveryFailed):
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	return c.JSON(resp)
+}
+
+// PasswordlessVerifyHandler 校验免密登录链接令牌或验证码并完成登录；
+// 账号启用了二次验证时与密码登录一样返回 pre_auth_token
+// POST /api/v1/auth/passwordless/verify
+func PasswordlessVerifyHandler(c *fiber.Ctx) error {
+	var req verification.VerifyLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request format"})
+	}
+
+	verified, err := passwordlessService().VerifyLogin(req)
+	if err != nil {
+		if errors.Is(err, verification.ErrLoginLocked) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, verification.ErrInvalidLoginCode) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": auth2.ErrServiceUnavailable.Error()})
+	}
+
+	amr := auth2.AMROTP
+	if verified.Channel == model.ChallengeChannelSMS {
+		amr = auth2.AMRSMS
+	}
+	result, err := svc.PasswordlessLogin(verified.UserID, verified.TenantID, normalizeScope(c.Get("X-Auth-Scope")), amr)
+	if err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			return respondAccountLocked(c, lockedErr)
+		}
+		if errors.Is(err, auth2.ErrServiceUnavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": auth2.ErrServiceUnavailable.Error()})
+		}
+		if errors.Is(err, auth2.ErrTenantLoginDisabled) || errors.Is(err, auth2.ErrAccountDisabled) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	if result.Need2FA {
+		return c.JSON(fiber.Map{
+			"need_2fa":              true,
+			"2fa_type":              result.TwoFAType,
+			"pre_auth_token":        result.PreAuthToken,
+			"available_2fa_methods": result.Available2FAMethods,
+			"redirect":              verified.ReturnTo,
+		})
+	}
+	setAuthCookies(c, c.Get("X-Auth-Scope"), result.TokenPair)
+
+	userID := result.UserID
+	clientIP := c.IP()
+	userAgent := c.Get("User-Agent")
+	go func() {
+		if err := security.RecordLoginSuccess(userID, clientIP, userAgent); err != nil {
+			log.Printf("failed to record login history: %v", err)
+		}
+	}()
+
+	return c.JSON(fiber.Map{
+		"access_token": result.TokenPair.AccessToken,
+		"redirect":     verified.ReturnTo,
+		"data": fiber.Map{
+			"token": result.TokenPair.AccessToken,
+			"user": fiber.Map{
+				"id": result.UserID,
+			},
+		},
+	})
+}
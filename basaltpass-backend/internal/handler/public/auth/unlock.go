@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"errors"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/service/lockout"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type unlockAccountRequest struct {
+	Token string `json:"token"`
+}
+
+// UnlockAccountHandler 通过锁定通知邮件中的链接解锁账号
+// POST /api/v1/auth/unlock
+func UnlockAccountHandler(c *fiber.Ctx) error {
+	var req unlockAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request format"})
+	}
+
+	if err := lockout.NewService(common.DB()).UnlockWithToken(req.Token, c.IP(), c.Get("User-Agent")); err != nil {
+		if errors.Is(err, lockout.ErrInvalidUnlockToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to unlock account"})
+	}
+	return c.JSON(fiber.Map{"message": "account unlocked"})
+}
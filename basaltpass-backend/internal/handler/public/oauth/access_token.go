@@ -0,0 +1,75 @@
+package oauth
+
+import (
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/accesstoken"
+	"basaltpass-backend/internal/utils"
+)
+
+// accessTokenContext 签发 JWT 访问令牌时附带的认证上下文
+type accessTokenContext struct {
+	Issuer   string
+	AuthTime *time.Time
+	ACR      string
+	AMR      []string
+}
+
+// storeAccessToken 保存访问令牌记录并返回下发给客户端的令牌值。
+// 客户端配置为 jwt 格式时，record.Token 中的随机值作为 jti 保存，返回签名后的 JWT（RFC 9068）；
+// 否则直接返回不透明令牌。数据库中只保存随机值的摘要和明文前缀。
+func (s *OAuthServerService) storeAccessToken(client *model.OAuthClient, record *model.OAuthAccessToken, ctx *accessTokenContext) (string, error) {
+	issued := record.Token
+
+	if client != nil && client.UsesJWTAccessTokens() {
+		if ctx == nil {
+			ctx = &accessTokenContext{}
+		}
+
+		// client_credentials 令牌的主体为客户端本身（RFC 9068 §2.2）
+		subject := record.ClientID
+		var extra map[string]interface{}
+		if !record.IsClientToken() {
+			sub, err := s.subjects.Subject(client, record.UserID)
+			if err != nil {
+				return "", err
+			}
+			subject = sub
+
+			// 应用声明映射中输出到访问令牌的声明
+			extra, err = s.customClaims(record.TenantID, record.AppID, record.UserID, record.Scopes, record.Claims, model.ClaimTargetAccessToken)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		signed, err := s.tokens.Sign(&accesstoken.Claims{
+			Issuer:    ctx.Issuer,
+			Subject:   subject,
+			Audience:  record.ClientID,
+			ClientID:  record.ClientID,
+			Scope:     record.Scopes,
+			TenantID:  record.TenantID,
+			AppID:     record.AppID,
+			JTI:       record.Token,
+			IssuedAt:  time.Now(),
+			ExpiresAt: record.ExpiresAt,
+			AuthTime:  ctx.AuthTime,
+			ACR:       ctx.ACR,
+			AMR:       ctx.AMR,
+			Extra:     extra,
+		})
+		if err != nil {
+			return "", err
+		}
+		issued = signed
+	}
+
+	record.TokenPrefix = utils.OAuthTokenDisplayPrefix(issued)
+	record.Token = utils.HashOAuthToken(record.Token)
+	if err := s.db.Create(record).Error; err != nil {
+		return "", err
+	}
+	return issued, nil
+}
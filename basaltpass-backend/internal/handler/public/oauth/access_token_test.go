@@ -0,0 +1,151 @@
+package oauth
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+)
+
+func TestJWTAccessTokenLifecycle(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-at-jwt", "openid,profile")
+	if err := db.Model(client).Update("access_token_format", model.AccessTokenFormatJWT).Error; err != nil {
+		t.Fatalf("update access token format failed: %v", err)
+	}
+	svc := NewOAuthServerService()
+
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "openid profile",
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+		Issuer:      "https://id.example.com/api/v1",
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+
+	parsed, err := svc.tokens.Parse(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("access token is not a valid at+jwt: %v", err)
+	}
+	if parsed["sub"] != strconv.FormatUint(uint64(user.ID), 10) || parsed["client_id"] != client.ClientID || parsed["scope"] != "openid profile" {
+		t.Fatalf("unexpected access token claims %v", parsed)
+	}
+	if parsed["iss"] != "https://id.example.com/api/v1" || parsed["aud"] != client.ClientID || parsed["tid"] == nil || parsed["app_id"] == nil {
+		t.Fatalf("unexpected access token claims %v", parsed)
+	}
+	jti, _ := parsed["jti"].(string)
+
+	// 存储的是 jti 的摘要，而不是完整的 JWT
+	var stored model.OAuthAccessToken
+	if err := db.Where("token = ?", utils.HashOAuthToken(jti)).First(&stored).Error; err != nil {
+		t.Fatalf("expected token row keyed by jti: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(resp.AccessToken); err != nil {
+		t.Fatalf("validate jwt access token failed: %v", err)
+	}
+	info, err := svc.GetUserInfo(resp.AccessToken)
+	if err != nil || info.Name != "Alice" {
+		t.Fatalf("userinfo with jwt access token failed: %v %+v", err, info)
+	}
+
+	// 篡改签名后必须被拒绝
+	parts := strings.Split(resp.AccessToken, ".")
+	tampered := parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))
+	if _, err := svc.ValidateAccessToken(tampered); err == nil {
+		t.Fatalf("tampered jwt access token must be rejected")
+	}
+
+	if err := svc.RevokeToken(resp.AccessToken); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(resp.AccessToken); err == nil {
+		t.Fatalf("revoked jwt access token must be rejected")
+	}
+}
+
+func TestOpaqueAccessTokenIsDefault(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	_, client := setupIDTokenTestData(t, db, "client-at-opaque", "s2s.user.read")
+	if err := db.Model(client).Update("grant_types", "client_credentials").Error; err != nil {
+		t.Fatalf("update grant types failed: %v", err)
+	}
+	svc := NewOAuthServerService()
+
+	resp, err := svc.IssueClientCredentialsToken(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "", "")
+	if err != nil {
+		t.Fatalf("issue token failed: %v", err)
+	}
+	if strings.Count(resp.AccessToken, ".") != 0 {
+		t.Fatalf("expected opaque access token, got %q", resp.AccessToken)
+	}
+}
+
+func TestTokensAndCodesStoredHashed(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-at-hashed", "profile")
+	svc := NewOAuthServerService()
+
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "profile",
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	var plainCodes int64
+	db.Model(&model.OAuthAuthorizationCode{}).Where("code = ?", code).Count(&plainCodes)
+	if plainCodes != 0 {
+		t.Fatalf("authorization code must not be stored in plaintext")
+	}
+
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+
+	var stored model.OAuthAccessToken
+	if err := db.Where("token = ?", utils.HashOAuthToken(resp.AccessToken)).First(&stored).Error; err != nil {
+		t.Fatalf("expected access token stored as digest: %v", err)
+	}
+	if stored.TokenPrefix == "" || !strings.HasPrefix(resp.AccessToken, stored.TokenPrefix) {
+		t.Fatalf("unexpected token prefix %q", stored.TokenPrefix)
+	}
+	var refreshRows int64
+	db.Model(&model.OAuthRefreshToken{}).Where("token = ?", resp.RefreshToken).Count(&refreshRows)
+	if refreshRows != 0 {
+		t.Fatalf("refresh token must not be stored in plaintext")
+	}
+
+	items, total, err := NewClientService().ListActiveTokens(client.ClientID, 1, 10)
+	if err != nil || total != 1 || len(items) != 1 || items[0].TokenPrefix != stored.TokenPrefix {
+		t.Fatalf("unexpected token listing %v %d %+v", err, total, items)
+	}
+
+	if err := svc.RevokeToken(resp.AccessToken); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(resp.AccessToken); err == nil {
+		t.Fatalf("revoked access token must be rejected")
+	}
+}
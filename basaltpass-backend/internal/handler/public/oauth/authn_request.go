@@ -0,0 +1,257 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	serviceauth "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/claimmapping"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDC prompt 取值（OIDC Core §3.1.2.1）
+const (
+	promptNone          = "none"
+	promptLogin         = "login"
+	promptConsent       = "consent"
+	promptSelectAccount = "select_account"
+)
+
+// 认证上下文等级（acr_values），由低到高
+const (
+	ACRPassword = "urn:basaltpass:acr:pwd"     // 已登录即可
+	ACRMFA      = "urn:basaltpass:acr:mfa"     // 二次验证（TOTP 或 Passkey）
+	ACRPasskey  = "urn:basaltpass:acr:passkey" // Passkey（抗钓鱼）
+)
+
+// supportedACRValues Discovery 中公布的 acr_values_supported
+var supportedACRValues = []string{ACRPassword, ACRMFA, ACRPasskey}
+
+// reauthCookieName 强制重新登录时签发的一次性标记 Cookie。回到授权端点时消费该标记，
+// 确认用户已在签发之后重新认证，避免 prompt=login 循环跳转；标记由服务端签发并存储，不能由请求参数伪造。
+const reauthCookieName = "oauth_reauth"
+
+// reauthWindow 重新登录标记的有效期，过期后重新要求登录
+const reauthWindow = 10 * time.Minute
+
+// authSession 浏览器会话中的登录态
+type authSession struct {
+	UserID   uint
+	AuthTime time.Time // 用户完成登录的时间；旧令牌未记录时为零值
+	AMR      []string
+}
+
+// currentAuthSession 读取请求中的登录会话（JWTMiddleware 写入的 Locals 或托管登录 Cookie）
+func currentAuthSession(c *fiber.Ctx) *authSession {
+	if uid, ok := c.Locals("userID").(uint); ok && uid > 0 {
+		session := &authSession{UserID: uid}
+		if token, ok := c.Locals("user").(*jwt.Token); ok && token != nil {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				ac := serviceauth.AuthContextFromClaims(claims)
+				session.AuthTime, session.AMR = ac.AuthTime, ac.AMR
+			}
+		}
+		return session
+	}
+
+	if session, ok := trySessionFromAccessTokenCookie(c); ok {
+		c.Locals("userID", session.UserID)
+		return session
+	}
+	return nil
+}
+
+// parseMaxAge 解析 max_age（秒）；空值返回 nil
+func parseMaxAge(raw string) (*int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return nil, errors.New("invalid_request")
+	}
+	return &n, nil
+}
+
+// hasPrompt 判断授权请求是否携带指定的 prompt 值
+func (r *AuthorizeRequest) hasPrompt(value string) bool {
+	for _, p := range strings.Fields(r.Prompt) {
+		if p == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOIDCParameters 校验 prompt、max_age 与 claims
+func validateOIDCParameters(req *AuthorizeRequest) error {
+	prompts := strings.Fields(req.Prompt)
+	for _, p := range prompts {
+		switch p {
+		case promptNone, promptLogin, promptConsent, promptSelectAccount:
+		default:
+			return errors.New("invalid_request")
+		}
+	}
+	// none 不能与其他取值同时出现
+	if req.hasPrompt(promptNone) && len(prompts) > 1 {
+		return errors.New("invalid_request")
+	}
+	if req.MaxAge != nil && *req.MaxAge < 0 {
+		return errors.New("invalid_request")
+	}
+	claimsReq, err := claimmapping.ParseRequest(req.Claims)
+	if err != nil {
+		return errors.New("invalid_request")
+	}
+	// claims 参数中 id_token.acr 请求的等级等同于 acr_values（OIDC Core §5.5.1.1）
+	if strings.TrimSpace(req.ACRValues) == "" {
+		req.ACRValues = claimsReq.ACRValues()
+	}
+	return nil
+}
+
+// issueReauthMarker 签发重新登录标记：库中保存摘要与签发时间，明文写入 HttpOnly Cookie
+func issueReauthMarker(c *fiber.Ctx) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	marker := hex.EncodeToString(raw)
+	now := time.Now()
+	if err := common.DB().Create(&model.OAuthReauthMarker{
+		Marker:    utils.HashOAuthToken(marker),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(reauthWindow),
+	}).Error; err != nil {
+		return err
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     reauthCookieName,
+		Value:    marker,
+		HTTPOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: "Lax",
+		Path:     "/",
+		MaxAge:   int(reauthWindow.Seconds()),
+	})
+	return nil
+}
+
+// consumeReauthMarker 消费浏览器持有的重新登录标记；标记有效且会话在签发之后完成认证时返回 true。
+// 标记无论是否满足条件都只能使用一次。
+func consumeReauthMarker(c *fiber.Ctx, session *authSession) bool {
+	marker := c.Cookies(reauthCookieName)
+	if marker == "" {
+		return false
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     reauthCookieName,
+		Value:    "",
+		HTTPOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: "Lax",
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+
+	var record model.OAuthReauthMarker
+	if err := common.DB().Where("marker = ?", utils.HashOAuthToken(marker)).First(&record).Error; err != nil {
+		return false
+	}
+	// 以删除结果判定归属，并发请求只有一个能消费成功
+	res := common.DB().Delete(&model.OAuthReauthMarker{}, record.ID)
+	if res.Error != nil || res.RowsAffected == 0 || record.IsExpired() || session.AuthTime.IsZero() {
+		return false
+	}
+	// auth_time 以秒记录
+	return !session.AuthTime.Before(record.IssuedAt.Truncate(time.Second))
+}
+
+// needsReauthentication 判断会话是否不满足 prompt=login、max_age 或 acr_values 的要求。
+// reauthenticated 表示会话刚按重新登录标记完成认证，此时不再要求 prompt=login 与 max_age，但仍校验认证等级。
+func needsReauthentication(req *AuthorizeRequest, session *authSession, reauthenticated bool) bool {
+	if !reauthenticated {
+		if req.hasPrompt(promptLogin) {
+			return true
+		}
+		if req.MaxAge != nil {
+			if session.AuthTime.IsZero() || time.Since(session.AuthTime) > time.Duration(*req.MaxAge)*time.Second {
+				return true
+			}
+		}
+	}
+	// 会话达不到请求的认证等级时要求重新登录（登录流程会对已绑定 TOTP / Passkey 的用户要求二次验证）
+	if strings.TrimSpace(req.ACRValues) != "" && resolveACR(req.ACRValues, session.AMR) == "" {
+		return true
+	}
+	return false
+}
+
+// acrSatisfied 判断会话的认证方式是否满足指定等级
+func acrSatisfied(acr string, amr []string) bool {
+	has := func(method string) bool {
+		for _, m := range amr {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	switch acr {
+	case ACRPassword:
+		return true
+	case ACRMFA:
+		return has(serviceauth.AMRMultiFactor) || has(serviceauth.AMRHardwareKey)
+	case ACRPasskey:
+		return has(serviceauth.AMRHardwareKey)
+	default:
+		return false
+	}
+}
+
+// resolveACR 返回会话满足的第一个请求等级（按客户端给出的优先顺序）；
+// 未请求 acr_values 时返回会话实际达到的最高等级，无法满足任何请求等级时返回空串。
+func resolveACR(acrValues string, amr []string) string {
+	requested := strings.Fields(acrValues)
+	if len(requested) == 0 {
+		if len(amr) == 0 {
+			return ""
+		}
+		requested = []string{ACRPasskey, ACRMFA, ACRPassword}
+	}
+	for _, acr := range requested {
+		if acrSatisfied(acr, amr) {
+			return acr
+		}
+	}
+	return ""
+}
+
+// reauthLoginURL 签发重新登录标记并构建强制重新登录的地址，登录页以 prompt=login 跳过已有会话
+func reauthLoginURL(c *fiber.Ctx, loginURL string) (string, error) {
+	if err := issueReauthMarker(c); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return loginURL, nil
+	}
+	q := u.Query()
+	q.Set("prompt", promptLogin)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,231 @@
+package oauth
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	serviceauth "basaltpass-backend/internal/service/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func sessionCookieForTest(t *testing.T, userID uint, authTime time.Time, amr ...string) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub": float64(userID),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	serviceauth.AuthContext{AuthTime: authTime, AMR: amr}.SetClaims(claims)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(common.MustJWTSecret())
+	if err != nil {
+		t.Fatalf("sign token failed: %v", err)
+	}
+	return signed
+}
+
+func TestAuthorizePromptAndMaxAge(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-prompt", "openid,profile")
+	if err := db.AutoMigrate(&model.OAuthReauthMarker{}); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	original := oauthServerService
+	oauthServerService = NewOAuthServerService()
+	t.Cleanup(func() { oauthServerService = original })
+
+	app := fiber.New()
+	app.Get("/api/v1/oauth/authorize", AuthorizeHandler)
+
+	// marker 记录最近一次响应签发的重新登录标记
+	var marker string
+	authorizeWithMarker := func(extra url.Values, cookie, reauth string) (int, *url.URL) {
+		q := url.Values{}
+		q.Set("client_id", client.ClientID)
+		q.Set("redirect_uri", "https://rp.example.com/callback")
+		q.Set("response_type", "code")
+		q.Set("scope", "openid")
+		q.Set("state", "xyz")
+		q.Set("code_challenge", s256ChallengeForTest("prompt-verifier-0123456789-abcdefghijklmnopqrstu"))
+		q.Set("code_challenge_method", "S256")
+		for k, v := range extra {
+			q[k] = v
+		}
+		req := httptest.NewRequest("GET", "/api/v1/oauth/authorize?"+q.Encode(), nil)
+		var cookies []string
+		if cookie != "" {
+			cookies = append(cookies, "access_token="+cookie)
+		}
+		if reauth != "" {
+			cookies = append(cookies, reauthCookieName+"="+reauth)
+		}
+		if len(cookies) > 0 {
+			req.Header.Set("Cookie", strings.Join(cookies, "; "))
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		marker = ""
+		for _, c := range resp.Cookies() {
+			if c.Name == reauthCookieName && c.Value != "" {
+				marker = c.Value
+			}
+		}
+		location, _ := url.Parse(resp.Header.Get("Location"))
+		return resp.StatusCode, location
+	}
+	authorize := func(extra url.Values, cookie string) (int, *url.URL) {
+		return authorizeWithMarker(extra, cookie, "")
+	}
+
+	// prompt=none 且未登录：直接返回 login_required
+	status, location := authorize(url.Values{"prompt": {"none"}}, "")
+	if status != fiber.StatusFound || location.Query().Get("error") != "login_required" || location.Query().Get("state") != "xyz" {
+		t.Fatalf("expected login_required redirect, got %d %v", status, location)
+	}
+
+	// prompt 取值非法或 none 与其他取值组合
+	status, location = authorize(url.Values{"prompt": {"none login"}}, "")
+	if status != fiber.StatusFound || location.Query().Get("error") != "invalid_request" {
+		t.Fatalf("expected invalid_request for prompt=none login, got %d %v", status, location)
+	}
+
+	// 会话超过 max_age：静默请求返回 login_required
+	stale := sessionCookieForTest(t, user.ID, time.Now().Add(-time.Hour), serviceauth.AMRPassword)
+	status, location = authorize(url.Values{"prompt": {"none"}, "max_age": {"60"}}, stale)
+	if status != fiber.StatusFound || location.Query().Get("error") != "login_required" {
+		t.Fatalf("expected login_required for stale session, got %d %v", status, location)
+	}
+
+	// 交互式请求跳转登录页，要求重新登录并预填 login_hint
+	status, location = authorize(url.Values{"max_age": {"60"}, "login_hint": {"alice@example.com"}}, stale)
+	if status != fiber.StatusFound || location.Query().Get("prompt") != "login" || location.Query().Get("login_hint") != "alice@example.com" {
+		t.Fatalf("expected re-login redirect, got %d %v", status, location)
+	}
+	if marker == "" {
+		t.Fatalf("expected re-login redirect to issue a marker cookie")
+	}
+	issued := marker
+
+	// 请求参数不能伪造重新登录：prompt=login 仍要求登录
+	fresh := sessionCookieForTest(t, user.ID, time.Now().Add(time.Second), serviceauth.AMRPassword)
+	status, location = authorize(url.Values{"prompt": {"login"}, "reauth_at": {"9999999999"}}, fresh)
+	if status != fiber.StatusFound || location.Query().Get("prompt") != "login" {
+		t.Fatalf("expected crafted reauth_at to be ignored, got %d %v", status, location)
+	}
+
+	// 签发后重新登录的会话携带标记回到授权端点：不再要求登录
+	status, location = authorizeWithMarker(url.Values{"prompt": {"login"}}, fresh, issued)
+	if status != fiber.StatusFound || location.Query().Get("prompt") == "login" {
+		t.Fatalf("expected marker to satisfy prompt=login, got %d %v", status, location)
+	}
+
+	// 标记只能使用一次
+	status, location = authorizeWithMarker(url.Values{"prompt": {"login"}}, fresh, issued)
+	if status != fiber.StatusFound || location.Query().Get("prompt") != "login" {
+		t.Fatalf("expected reused marker to require login, got %d %v", status, location)
+	}
+
+	// 标记签发前的会话不能借标记跳过重新登录
+	authorize(url.Values{"prompt": {"login"}}, stale)
+	status, location = authorizeWithMarker(url.Values{"prompt": {"login"}}, stale, marker)
+	if status != fiber.StatusFound || location.Query().Get("prompt") != "login" {
+		t.Fatalf("expected marker to require a login after issuance, got %d %v", status, location)
+	}
+
+	// 重新登录后仍达不到 acr_values 时返回授权错误，不再循环跳转登录页
+	authorize(url.Values{"acr_values": {ACRMFA}}, fresh)
+	if marker == "" {
+		t.Fatalf("expected unmet acr to issue a re-login marker")
+	}
+	relogin := sessionCookieForTest(t, user.ID, time.Now().Add(time.Second), serviceauth.AMRPassword)
+	status, location = authorizeWithMarker(url.Values{"acr_values": {ACRMFA}}, relogin, marker)
+	if status != fiber.StatusFound || location.Query().Get("error") != "unmet_authentication_requirements" || location.Query().Get("state") != "xyz" {
+		t.Fatalf("expected unmet_authentication_requirements after re-login, got %d %v", status, location)
+	}
+
+	// 会话达不到 acr_values 要求的等级时同样要求重新登录
+	status, location = authorize(url.Values{"prompt": {"none"}, "acr_values": {ACRMFA}}, fresh)
+	if status != fiber.StatusFound || location.Query().Get("error") != "login_required" {
+		t.Fatalf("expected login_required for insufficient acr, got %d %v", status, location)
+	}
+}
+
+func TestNeedsReauthentication(t *testing.T) {
+	maxAge := 300
+	fresh := &authSession{UserID: 1, AuthTime: time.Now().Add(-time.Minute), AMR: []string{serviceauth.AMRPassword}}
+	legacy := &authSession{UserID: 1}
+
+	cases := []struct {
+		name            string
+		req             *AuthorizeRequest
+		session         *authSession
+		reauthenticated bool
+		want            bool
+	}{
+		{"no constraints", &AuthorizeRequest{}, legacy, false, false},
+		{"prompt login", &AuthorizeRequest{Prompt: promptLogin}, fresh, false, true},
+		{"prompt login after re-login", &AuthorizeRequest{Prompt: promptLogin}, fresh, true, false},
+		{"acr not met after re-login", &AuthorizeRequest{Prompt: promptLogin, ACRValues: ACRPasskey}, fresh, true, true},
+		{"within max_age", &AuthorizeRequest{MaxAge: &maxAge}, fresh, false, false},
+		{"legacy token with max_age", &AuthorizeRequest{MaxAge: &maxAge}, legacy, false, true},
+		{"acr not met", &AuthorizeRequest{ACRValues: ACRPasskey}, fresh, false, true},
+		{"acr met", &AuthorizeRequest{ACRValues: ACRPasskey + " " + ACRPassword}, fresh, false, false},
+	}
+	for _, tc := range cases {
+		if got := needsReauthentication(tc.req, tc.session, tc.reauthenticated); got != tc.want {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestAuthorizationCodeRecordsACRAndAMR(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-acr", "openid,profile")
+	svc := NewOAuthServerService()
+
+	authTime := time.Now().Add(-2 * time.Minute).Truncate(time.Second)
+	session := &authSession{
+		UserID:   user.ID,
+		AuthTime: authTime,
+		AMR:      []string{serviceauth.AMRPassword, serviceauth.AMROTP, serviceauth.AMRMultiFactor},
+	}
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "openid",
+		ACRValues:    ACRPasskey + " " + ACRMFA,
+	}
+	code, err := svc.issueAuthorizationCode(session, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+		Issuer:      "https://id.example.com/api/v1",
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+
+	claims := parseIDTokenForTest(t, svc, resp.IDToken)
+	if claims["acr"] != ACRMFA {
+		t.Fatalf("expected acr %s, got %v", ACRMFA, claims["acr"])
+	}
+	if amr, _ := claims["amr"].([]interface{}); len(amr) != 3 || amr[1] != serviceauth.AMROTP {
+		t.Fatalf("expected amr to be recorded, got %v", claims["amr"])
+	}
+	if claims["auth_time"] != float64(authTime.Unix()) {
+		t.Fatalf("expected auth_time %d, got %v", authTime.Unix(), claims["auth_time"])
+	}
+}
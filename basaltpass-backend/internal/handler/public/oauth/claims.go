@@ -0,0 +1,60 @@
+package oauth
+
+import (
+	"encoding/json"
+
+	"basaltpass-backend/internal/service/claimmapping"
+)
+
+// customClaims 按应用的声明映射解析 target（id_token / userinfo / access_token）中应附加的声明。
+// claimsParam 为授权时保存的 claims 请求参数，已在授权端点校验，此处解析失败按未携带处理。
+func (s *OAuthServerService) customClaims(tenantID, appID, userID uint, scopes, claimsParam, target string) (map[string]interface{}, error) {
+	req, _ := claimmapping.ParseRequest(claimsParam)
+	return s.claims.Resolve(&claimmapping.Context{
+		TenantID: tenantID,
+		AppID:    appID,
+		UserID:   userID,
+		Scopes:   scopes,
+		Request:  req,
+	}, target)
+}
+
+// mergeClaims 将自定义声明并入 dst，不覆盖已有的标准声明
+func mergeClaims(dst map[string]interface{}, extra map[string]interface{}) {
+	for name, value := range extra {
+		if _, exists := dst[name]; !exists {
+			dst[name] = value
+		}
+	}
+}
+
+// requestObjectClaimsParam 请求对象中的 claims 为 JSON 对象，授权请求中以字符串保存
+func requestObjectClaimsParam(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(raw), nil
+	}
+}
+
+// MarshalJSON 输出标准声明并附加应用映射的自定义声明
+func (r UserInfoResponse) MarshalJSON() ([]byte, error) {
+	type standard UserInfoResponse
+	raw, err := json.Marshal(standard(r))
+	if err != nil || len(r.Claims) == 0 {
+		return raw, err
+	}
+	out := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	mergeClaims(out, r.Claims)
+	return json.Marshal(out)
+}
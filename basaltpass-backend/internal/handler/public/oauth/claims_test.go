@@ -0,0 +1,88 @@
+package oauth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+)
+
+func TestClaimMappingsAndClaimsParameter(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-claims", "openid,profile")
+	if err := db.Model(client).Update("access_token_format", model.AccessTokenFormatJWT).Error; err != nil {
+		t.Fatalf("update client failed: %v", err)
+	}
+	for _, m := range []model.AppClaimMapping{
+		{ClaimName: "department", Source: model.ClaimSourceStatic, Value: `"r&d"`, Targets: "userinfo"},
+		{ClaimName: "plan", Source: model.ClaimSourceStatic, Value: `"pro"`, Targets: "id_token,access_token"},
+		{ClaimName: "internal", Source: model.ClaimSourceStatic, Value: `true`, Scope: "admin"},
+	} {
+		m.AppID = client.AppID
+		if err := db.Create(&m).Error; err != nil {
+			t.Fatalf("create mapping failed: %v", err)
+		}
+	}
+	svc := NewOAuthServerService()
+
+	// claims 参数必须是 JSON 对象
+	if _, err := svc.ValidateAuthorizeRequest(&AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "openid",
+		Claims:       "department",
+	}); err == nil || err.Error() != "invalid_request" {
+		t.Fatalf("expected invalid_request for malformed claims, got %v", err)
+	}
+
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "openid profile",
+		Claims:       `{"id_token":{"department":null}}`,
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+		Issuer:      "https://id.example.com/api/v1",
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+
+	// id_token：配置的声明加上 claims 参数请求的声明；未授权 scope 的映射不输出
+	idClaims := parseIDTokenForTest(t, svc, resp.IDToken)
+	if idClaims["plan"] != "pro" || idClaims["department"] != "r&d" || idClaims["name"] != "Alice" {
+		t.Fatalf("unexpected id_token claims: %v", idClaims)
+	}
+	if _, ok := idClaims["internal"]; ok {
+		t.Fatalf("expected scope-gated claim to be omitted")
+	}
+
+	// JWT 访问令牌只包含指向 access_token 的声明
+	atClaims, err := svc.tokens.Parse(resp.AccessToken)
+	if err != nil || atClaims["plan"] != "pro" || atClaims["department"] != nil {
+		t.Fatalf("unexpected access token claims: %v %v", atClaims, err)
+	}
+
+	// userinfo 与标准声明平铺输出
+	info, err := svc.GetUserInfo(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("userinfo failed: %v", err)
+	}
+	raw, _ := json.Marshal(info)
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode userinfo failed: %v", err)
+	}
+	if body["department"] != "r&d" || body["sub"] != info.Sub || body["plan"] != nil {
+		t.Fatalf("unexpected userinfo body: %s", raw)
+	}
+}
@@ -0,0 +1,157 @@
+package oauth
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/clientassertion"
+	"basaltpass-backend/internal/service/settings"
+	tenantservice "basaltpass-backend/internal/service/tenant"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ClientCredentials 请求携带的客户端凭证：共享密钥，或 private_key_jwt 客户端的 JWT 断言（RFC 7523）
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+
+	ClientAssertion     string
+	ClientAssertionType string
+	// AssertionAudiences 断言可接受的 aud：按配置的对外地址构建的 issuer、令牌端点及当前端点地址
+	AssertionAudiences []string
+}
+
+// extractClientCredentials 提取客户端凭证（支持Basic Auth、自定义头、表单参数及 client_assertion）
+func extractClientCredentials(c *fiber.Ctx) ClientCredentials {
+	if assertion := strings.TrimSpace(c.FormValue("client_assertion")); assertion != "" {
+		creds := ClientCredentials{
+			ClientID:            strings.TrimSpace(c.FormValue("client_id")),
+			ClientAssertion:     assertion,
+			ClientAssertionType: strings.TrimSpace(c.FormValue("client_assertion_type")),
+			AssertionAudiences:  clientassertion.Audiences(requestBaseURL(c), c.Path()),
+		}
+		// client_id 可省略，此时以断言的 iss 为准（RFC 7521 §4.2）
+		if creds.ClientID == "" {
+			creds.ClientID = clientassertion.Issuer(assertion)
+		}
+		// 同时提交多种认证方式视为认证失败（RFC 6749 §2.3）
+		_, creds.ClientSecret = extractSecretCredentials(c)
+		return creds
+	}
+
+	clientID, clientSecret := extractSecretCredentials(c)
+	return ClientCredentials{ClientID: clientID, ClientSecret: clientSecret}
+}
+
+// extractSecretCredentials 提取 client_id / client_secret
+func extractSecretCredentials(c *fiber.Ctx) (clientID, clientSecret string) {
+	// 优先从 Authorization: Basic 获取（OAuth2 标准方式）
+	if basicID, basicSecret, ok := parseBasicAuthCredentials(c.Get("Authorization")); ok {
+		return basicID, basicSecret
+	}
+
+	// 向后兼容：支持自定义头 client_id/client_secret
+	clientID = strings.TrimSpace(c.Get("client_id"))
+	clientSecret = c.Get("client_secret")
+
+	if clientID != "" && clientSecret != "" {
+		return clientID, clientSecret
+	}
+
+	// 尝试从表单参数获取
+	clientID = strings.TrimSpace(c.FormValue("client_id"))
+	clientSecret = c.FormValue("client_secret")
+
+	return clientID, clientSecret
+}
+
+// authenticateClient 按客户端登记的 token_endpoint_auth_method 认证令牌端点请求：
+// 公共客户端只提交 client_id（携带密钥视为认证失败），private_key_jwt 客户端提交签名断言，
+// 其余机密客户端须提供正确的 client_secret。
+func (s *OAuthServerService) authenticateClient(creds ClientCredentials) (*model.OAuthClient, error) {
+	if creds.ClientAssertion != "" {
+		return s.authenticateClientAssertion(creds)
+	}
+
+	clientID := strings.TrimSpace(creds.ClientID)
+	if clientID == "" {
+		return nil, errors.New("invalid_client")
+	}
+
+	var client model.OAuthClient
+	if err := s.db.Where("client_id = ? AND is_active = ?", clientID, true).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid_client")
+		}
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		if creds.ClientSecret != "" {
+			return nil, errors.New("invalid_client")
+		}
+		return &client, nil
+	}
+
+	if !client.UsesClientSecret() || creds.ClientSecret == "" || !client.VerifyClientSecret(creds.ClientSecret) {
+		return nil, errors.New("invalid_client")
+	}
+	return &client, nil
+}
+
+// authenticateClientAssertion 校验 client_assertion（签名、aud、exp 及 jti 重放）
+func (s *OAuthServerService) authenticateClientAssertion(creds ClientCredentials) (*model.OAuthClient, error) {
+	if creds.ClientAssertionType != clientassertion.AssertionType || creds.ClientSecret != "" {
+		return nil, errors.New("invalid_client")
+	}
+
+	client, err := clientassertion.NewService(s.db).Verify(creds.ClientAssertion, creds.AssertionAudiences)
+	if err != nil {
+		if errors.Is(err, clientassertion.ErrInvalidClient) {
+			log.Printf("[OAuth] client assertion rejected: client=%s err=%v", creds.ClientID, err)
+			return nil, errors.New("invalid_client")
+		}
+		return nil, err
+	}
+	if creds.ClientID != client.ClientID {
+		return nil, errors.New("invalid_client")
+	}
+	return client, nil
+}
+
+// pkceRequired 授权码流程是否必须使用 PKCE：公共客户端、全局 oauth.pkce_required 或租户设置
+func (s *OAuthServerService) pkceRequired(client *model.OAuthClient) bool {
+	if client.IsPublic() || settings.GetBool("oauth.pkce_required", true) {
+		return true
+	}
+	tenantID := client.App.TenantID
+	if tenantID == 0 {
+		tenantID = s.resolveClientTenantID(client)
+	}
+	return tenantservice.IsTenantPKCERequired(tenantID)
+}
+
+// validatePKCEChallenge 校验授权请求中的 PKCE 参数；公共客户端只允许 S256
+func (s *OAuthServerService) validatePKCEChallenge(client *model.OAuthClient, req *AuthorizeRequest) error {
+	switch req.CodeChallengeMethod {
+	case "", "S256", "plain":
+	default:
+		return errors.New("invalid_request")
+	}
+
+	if req.CodeChallenge == "" {
+		if req.CodeChallengeMethod != "" || s.pkceRequired(client) {
+			return errors.New("invalid_request")
+		}
+		return nil
+	}
+
+	if client.IsPublic() && req.CodeChallengeMethod != "S256" {
+		return errors.New("invalid_request")
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// clientCredentialsTokenTTL 客户端令牌有效期，不签发刷新令牌（RFC 6749 §4.4.3）
+const clientCredentialsTokenTTL = time.Hour
+
+// userScopes 需要用户主体的scope，不能通过 client_credentials 获取
+var userScopes = map[string]bool{
+	"openid":         true,
+	"profile":        true,
+	"email":          true,
+	"phone":          true,
+	"offline_access": true,
+}
+
+// clientAllowsGrant 判断客户端是否配置了指定的授权类型
+func clientAllowsGrant(client *model.OAuthClient, grantType string) bool {
+	for _, g := range client.GetGrantTypeList() {
+		if strings.TrimSpace(g) == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveClientCredentialsScopes 计算客户端令牌的scope：
+// 未指定时授予客户端全部非用户scope，指定时必须是客户端允许scope的子集。
+func resolveClientCredentialsScopes(client *model.OAuthClient, requested string) (string, error) {
+	allowed := make(map[string]bool)
+	var defaults []string
+	for _, scope := range client.GetScopeList() {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || userScopes[scope] {
+			continue
+		}
+		if !allowed[scope] {
+			allowed[scope] = true
+			defaults = append(defaults, scope)
+		}
+	}
+
+	requestedScopes := parseScopeList(requested)
+	if len(requestedScopes) == 0 {
+		return strings.Join(defaults, " "), nil
+	}
+
+	for _, scope := range requestedScopes {
+		if !allowed[scope] {
+			return "", errors.New("invalid_scope")
+		}
+	}
+	return strings.Join(requestedScopes, " "), nil
+}
+
+// IssueClientCredentialsToken 使用客户端凭证签发访问令牌（client_credentials 授权）
+// issuer 用于客户端配置为 JWT 访问令牌时的 iss 声明。
+func (s *OAuthServerService) IssueClientCredentialsToken(creds ClientCredentials, scope string, issuer string) (*TokenResponse, error) {
+	client, err := s.ValidateClientCredentials(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if !clientAllowsGrant(client, "client_credentials") {
+		return nil, errors.New("unauthorized_client")
+	}
+
+	scopes, err := resolveClientCredentialsScopes(client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := model.GenerateAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	oauthToken := &model.OAuthAccessToken{
+		Token:     accessToken,
+		ClientID:  client.ClientID,
+		UserID:    0, // 客户端令牌没有用户主体
+		TenantID:  s.resolveClientTenantID(client),
+		AppID:     client.AppID,
+		Scopes:    scopes,
+		ExpiresAt: now.Add(clientCredentialsTokenTTL),
+	}
+	accessToken, err = s.storeAccessToken(client, oauthToken, &accessTokenContext{Issuer: issuer})
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.Model(client).Update("last_used_at", &now)
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(clientCredentialsTokenTTL.Seconds()),
+		Scope:       scopes,
+	}, nil
+}
+
+// handleClientCredentialsGrant 处理客户端凭证授权
+func handleClientCredentialsGrant(c *fiber.Ctx) error {
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" || (creds.ClientSecret == "" && creds.ClientAssertion == "") {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":             "invalid_client",
+			"error_description": "Client authentication failed",
+		})
+	}
+
+	tokenResponse, err := oauthServerService.IssueClientCredentialsToken(creds, c.FormValue("scope"), oidcIssuer(c))
+	if err != nil {
+		switch err.Error() {
+		case "invalid_client":
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":             "invalid_client",
+				"error_description": "Client authentication failed",
+			})
+		case "unauthorized_client":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":             "unauthorized_client",
+				"error_description": "Client is not allowed to use the client_credentials grant",
+			})
+		case "invalid_scope":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":             "invalid_scope",
+				"error_description": "Requested scope exceeds the client's allowed scopes",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":             "server_error",
+				"error_description": "Failed to issue access token",
+			})
+		}
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(tokenResponse)
+}
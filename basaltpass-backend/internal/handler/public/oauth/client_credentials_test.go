@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"testing"
+
+	"basaltpass-backend/internal/model"
+)
+
+func TestIssueClientCredentialsToken(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	_, client := setupIDTokenTestData(t, db, "client-cc", "openid,s2s.user.read,s2s.wallet.read")
+	if err := db.Model(client).Update("grant_types", "client_credentials").Error; err != nil {
+		t.Fatalf("update grant types failed: %v", err)
+	}
+	svc := NewOAuthServerService()
+
+	resp, err := svc.IssueClientCredentialsToken(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "", "")
+	if err != nil {
+		t.Fatalf("issue token failed: %v", err)
+	}
+	if resp.RefreshToken != "" || resp.IDToken != "" {
+		t.Fatalf("client_credentials must not return refresh_token or id_token")
+	}
+	if resp.Scope != "s2s.user.read s2s.wallet.read" {
+		t.Fatalf("expected default scopes without user scopes, got %q", resp.Scope)
+	}
+
+	stored, err := svc.ValidateAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("validate token failed: %v", err)
+	}
+	if !stored.IsClientToken() || stored.AppID != client.AppID {
+		t.Fatalf("unexpected stored token %+v", stored)
+	}
+	if _, err := svc.GetUserInfo(resp.AccessToken); err == nil {
+		t.Fatalf("client token must not be accepted by userinfo")
+	}
+
+	narrowed, err := svc.IssueClientCredentialsToken(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "s2s.user.read", "")
+	if err != nil {
+		t.Fatalf("issue narrowed token failed: %v", err)
+	}
+	if narrowed.Scope != "s2s.user.read" {
+		t.Fatalf("expected narrowed scope, got %q", narrowed.Scope)
+	}
+
+	for _, scope := range []string{"openid", "s2s.user.write"} {
+		if _, err := svc.IssueClientCredentialsToken(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, scope, ""); err == nil || err.Error() != "invalid_scope" {
+			t.Fatalf("expected invalid_scope for %q, got %v", scope, err)
+		}
+	}
+	if _, err := svc.IssueClientCredentialsToken(ClientCredentials{ClientID: client.ClientID, ClientSecret: "wrong"}, "", ""); err == nil || err.Error() != "invalid_client" {
+		t.Fatalf("expected invalid_client, got %v", err)
+	}
+}
+
+func TestIssueClientCredentialsTokenRequiresGrantType(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	_, client := setupIDTokenTestData(t, db, "client-cc-denied", "s2s.user.read")
+	svc := NewOAuthServerService()
+
+	// 默认授权类型为 authorization_code,refresh_token
+	_, err := svc.IssueClientCredentialsToken(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "", "")
+	if err == nil || err.Error() != "unauthorized_client" {
+		t.Fatalf("expected unauthorized_client, got %v", err)
+	}
+
+	var count int64
+	db.Model(&model.OAuthAccessToken{}).Where("client_id = ?", client.ClientID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no token to be stored, got %d", count)
+	}
+}
@@ -176,12 +176,18 @@ func GetTokensHandler(c *fiber.Ctx) error {
 		pageSize = 10
 	}
 
-	// 这里可以实现获取令牌列表的逻辑
-	// 暂时返回空列表
+	// 令牌以摘要存储，列表中只返回明文前缀
+	tokens, total, err := clientService.ListActiveTokens(c.Params("client_id"), page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "获取令牌列表失败",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"data": fiber.Map{
-			"tokens":    []interface{}{},
-			"total":     0,
+			"tokens":    tokens,
+			"total":     total,
 			"page":      page,
 			"page_size": pageSize,
 		},
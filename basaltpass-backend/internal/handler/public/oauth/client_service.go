@@ -2,66 +2,132 @@ package oauth
 
 import (
 	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/clientassertion"
 	"basaltpass-backend/internal/service/scope"
 	"basaltpass-backend/internal/service/settings"
+	"basaltpass-backend/internal/service/subject"
+	"bytes"
+	"encoding/json"
 	"errors"
+	"net"
+	"net/url"
 	"strings"
 	"time"
 
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
 
 	"gorm.io/gorm"
 )
 
 // ClientService OAuth2客户端管理服务
 type ClientService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	subjects *subject.Service
 }
 
 // NewClientService 创建新的客户端服务
 func NewClientService() *ClientService {
+	db := common.DB()
 	return &ClientService{
-		db: common.DB(),
+		db:       db,
+		subjects: subject.NewService(db),
 	}
 }
 
 // CreateClientRequest 创建客户端请求
 type CreateClientRequest struct {
-	Name           string   `json:"name" validate:"required,min=1,max=100"`
-	Description    string   `json:"description" validate:"max=500"`
-	RedirectURIs   []string `json:"redirect_uris" validate:"required,min=1"`
-	Scopes         []string `json:"scopes"`
-	AllowedOrigins []string `json:"allowed_origins"`
+	Name              string   `json:"name" validate:"required,min=1,max=100"`
+	Description       string   `json:"description" validate:"max=500"`
+	RedirectURIs      []string `json:"redirect_uris" validate:"required,min=1"`
+	Scopes            []string `json:"scopes"`
+	GrantTypes        []string `json:"grant_types"`
+	AllowedOrigins    []string `json:"allowed_origins"`
+	AccessTokenFormat string   `json:"access_token_format"`
+
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   string   `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  string   `json:"frontchannel_logout_uri"`
+
+	// 令牌端点认证方式，为空时默认 client_secret_basic；none 表示公共客户端，不签发密钥
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method"`
+
+	// 客户端公钥（JWK Set 文档或 jwks_uri，二选一），private_key_jwt 必填
+	JWKS    json.RawMessage `json:"jwks"`
+	JWKSURI string          `json:"jwks_uri"`
+
+	// 授权端点只接受经 /oauth/par 推送的请求
+	RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests"`
+
+	// 主体标识类型：public（默认）或 pairwise；pairwise 的扇区取 sector_identifier_uri 或重定向地址的主机名
+	SubjectType         string `json:"subject_type"`
+	SectorIdentifierURI string `json:"sector_identifier_uri"`
 }
 
 // UpdateClientRequest 更新客户端请求
 type UpdateClientRequest struct {
-	Name           string   `json:"name" validate:"omitempty,min=1,max=100"`
-	Description    string   `json:"description" validate:"omitempty,max=500"`
-	RedirectURIs   []string `json:"redirect_uris" validate:"omitempty,min=1"`
-	Scopes         []string `json:"scopes"`
-	AllowedOrigins []string `json:"allowed_origins"`
-	IsActive       *bool    `json:"is_active"`
+	Name              string   `json:"name" validate:"omitempty,min=1,max=100"`
+	Description       string   `json:"description" validate:"omitempty,max=500"`
+	RedirectURIs      []string `json:"redirect_uris" validate:"omitempty,min=1"`
+	Scopes            []string `json:"scopes"`
+	GrantTypes        []string `json:"grant_types"`
+	AllowedOrigins    []string `json:"allowed_origins"`
+	AccessTokenFormat string   `json:"access_token_format"`
+	IsActive          *bool    `json:"is_active"`
+
+	// 登出配置：nil 表示不修改，空值表示清除
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   *string  `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  *string  `json:"frontchannel_logout_uri"`
+
+	// 切换为 none 或 private_key_jwt 时清除密钥；切换回密钥认证后需重新生成密钥
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method"`
+
+	// 客户端公钥：nil 表示不修改，JSON null / 空串表示清除
+	JWKS    json.RawMessage `json:"jwks"`
+	JWKSURI *string         `json:"jwks_uri"`
+
+	RequirePushedAuthorizationRequests *bool `json:"require_pushed_authorization_requests"`
+
+	// 主体标识：空值表示不修改；sector_identifier_uri 为 nil 表示不修改，空串表示清除
+	SubjectType         string  `json:"subject_type"`
+	SectorIdentifierURI *string `json:"sector_identifier_uri"`
 }
 
 // ClientResponse 客户端响应
 type ClientResponse struct {
-	ID             uint      `json:"id"`
-	Name           string    `json:"name"`
-	Description    string    `json:"description"`
-	ClientID       string    `json:"client_id"`
-	ClientSecret   string    `json:"client_secret,omitempty"` // 只在创建时返回
-	RedirectURIs   []string  `json:"redirect_uris"`
-	Scopes         []string  `json:"scopes"`
-	AllowedOrigins []string  `json:"allowed_origins"`
-	IsActive       bool      `json:"is_active"`
-	LastUsedAt     *string   `json:"last_used_at"`
-	CreatedBy      uint      `json:"created_by"`
-	CreatedAt      string    `json:"created_at"`
-	UpdatedAt      string    `json:"updated_at"`
-	Creator        *UserInfo `json:"creator,omitempty"`
-	App            *AppInfo  `json:"app,omitempty"`
+	ID                uint      `json:"id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	ClientID          string    `json:"client_id"`
+	ClientSecret      string    `json:"client_secret,omitempty"` // 只在创建时返回
+	RedirectURIs      []string  `json:"redirect_uris"`
+	Scopes            []string  `json:"scopes"`
+	GrantTypes        []string  `json:"grant_types"`
+	AllowedOrigins    []string  `json:"allowed_origins"`
+	AccessTokenFormat string    `json:"access_token_format"`
+	IsActive          bool      `json:"is_active"`
+	LastUsedAt        *string   `json:"last_used_at"`
+	CreatedBy         uint      `json:"created_by"`
+	CreatedAt         string    `json:"created_at"`
+	UpdatedAt         string    `json:"updated_at"`
+	Creator           *UserInfo `json:"creator,omitempty"`
+	App               *AppInfo  `json:"app,omitempty"`
+
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   string   `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  string   `json:"frontchannel_logout_uri"`
+
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+	IsPublic                bool            `json:"is_public"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty"`
+
+	RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests"`
+
+	SubjectType         string `json:"subject_type"`
+	SectorIdentifierURI string `json:"sector_identifier_uri,omitempty"`
 }
 
 // UserInfo 用户信息
@@ -104,11 +170,16 @@ func (s *ClientService) CreateClient(creatorID uint, req *CreateClientRequest) (
 		client.SetScopeList([]string{"openid", "profile", "email"})
 	}
 
+	applyClientOptions(client, req)
+
 	if len(req.AllowedOrigins) > 0 {
 		client.AllowedOrigins = strings.Join(req.AllowedOrigins, ",")
 	}
 
-	// 哈希客户端密钥
+	// 哈希客户端密钥（公共客户端与 private_key_jwt 客户端不持有密钥）
+	if !client.UsesClientSecret() {
+		client.ClientSecret = ""
+	}
 	plainSecret := client.ClientSecret
 	client.HashClientSecret()
 
@@ -161,11 +232,16 @@ func (s *ClientService) CreateClientForApp(appID, creatorID uint, req *CreateCli
 		client.SetScopeList([]string{"openid", "profile", "email"})
 	}
 
+	applyClientOptions(client, req)
+
 	if len(req.AllowedOrigins) > 0 {
 		client.SetAllowedOriginList(req.AllowedOrigins)
 	}
 
-	// 哈希客户端密钥
+	// 哈希客户端密钥（公共客户端与 private_key_jwt 客户端不持有密钥）
+	if !client.UsesClientSecret() {
+		client.ClientSecret = ""
+	}
 	plainSecret := client.ClientSecret
 	client.HashClientSecret()
 
@@ -261,12 +337,65 @@ func (s *ClientService) UpdateClient(clientID string, req *UpdateClientRequest)
 		client.SetScopeList(req.Scopes)
 		updates["scopes"] = client.Scopes
 	}
+	if len(req.GrantTypes) > 0 {
+		client.SetGrantTypeList(req.GrantTypes)
+		updates["grant_types"] = client.GrantTypes
+	}
+	if req.AccessTokenFormat != "" {
+		updates["access_token_format"] = req.AccessTokenFormat
+	}
 	if len(req.AllowedOrigins) > 0 {
 		updates["allowed_origins"] = strings.Join(req.AllowedOrigins, ",")
 	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
+	if req.PostLogoutRedirectURIs != nil {
+		client.SetPostLogoutRedirectURIList(req.PostLogoutRedirectURIs)
+		updates["post_logout_redirect_uris"] = client.PostLogoutRedirectURIs
+	}
+	if req.BackchannelLogoutURI != nil {
+		updates["backchannel_logout_uri"] = strings.TrimSpace(*req.BackchannelLogoutURI)
+	}
+	if req.FrontchannelLogoutURI != nil {
+		updates["frontchannel_logout_uri"] = strings.TrimSpace(*req.FrontchannelLogoutURI)
+	}
+	if req.TokenEndpointAuthMethod != "" {
+		grantTypes := client.GetGrantTypeList()
+		if err := validateTokenEndpointAuthMethod(req.TokenEndpointAuthMethod, grantTypes); err != nil {
+			return nil, err
+		}
+		client.TokenEndpointAuthMethod = req.TokenEndpointAuthMethod
+		updates["token_endpoint_auth_method"] = req.TokenEndpointAuthMethod
+		if !client.UsesClientSecret() {
+			updates["client_secret"] = ""
+		}
+	}
+	if req.JWKS != nil {
+		client.JWKS = compactJWKS(req.JWKS)
+		updates["jwks"] = client.JWKS
+	}
+	if req.JWKSURI != nil {
+		client.JWKSURI = strings.TrimSpace(*req.JWKSURI)
+		updates["jwks_uri"] = client.JWKSURI
+	}
+	if err := validateClientKeys(client.TokenEndpointAuthMethod, client.JWKS, client.JWKSURI); err != nil {
+		return nil, err
+	}
+	if req.RequirePushedAuthorizationRequests != nil {
+		updates["require_pushed_authorization_requests"] = *req.RequirePushedAuthorizationRequests
+	}
+	if req.SubjectType != "" {
+		client.SubjectType = req.SubjectType
+		updates["subject_type"] = req.SubjectType
+	}
+	if req.SectorIdentifierURI != nil {
+		client.SectorIdentifierURI = strings.TrimSpace(*req.SectorIdentifierURI)
+		updates["sector_identifier_uri"] = client.SectorIdentifierURI
+	}
+	if err := s.subjects.ValidateClient(client.SubjectType, client.SectorIdentifierURI, client.GetRedirectURIList()); err != nil {
+		return nil, errors.New("无效的主体标识配置: " + err.Error())
+	}
 
 	// 更新数据库
 	if err := s.db.Model(&client).Updates(updates).Error; err != nil {
@@ -321,6 +450,10 @@ func (s *ClientService) RegenerateSecret(clientID string) (string, error) {
 		return "", err
 	}
 
+	if !client.UsesClientSecret() {
+		return "", errors.New("该客户端的认证方式不使用客户端密钥")
+	}
+
 	// 生成新密钥
 	if err := client.GenerateClientCredentials(); err != nil {
 		return "", err
@@ -373,6 +506,46 @@ func (s *ClientService) GetClientStats(clientID string) (map[string]interface{},
 	return stats, nil
 }
 
+// TokenListItem 令牌列表项，只包含令牌明文前缀
+type TokenListItem struct {
+	ID          uint      `json:"id"`
+	TokenPrefix string    `json:"token_prefix"`
+	UserID      uint      `json:"user_id"`
+	Scopes      string    `json:"scopes"`
+	IsExchanged bool      `json:"is_exchanged"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListActiveTokens 分页获取客户端未过期的访问令牌
+func (s *ClientService) ListActiveTokens(clientID string, page, pageSize int) ([]TokenListItem, int64, error) {
+	query := s.db.Model(&model.OAuthAccessToken{}).Where("client_id = ? AND expires_at > ?", clientID, time.Now())
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var tokens []model.OAuthAccessToken
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&tokens).Error; err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]TokenListItem, 0, len(tokens))
+	for _, t := range tokens {
+		items = append(items, TokenListItem{
+			ID:          t.ID,
+			TokenPrefix: t.TokenPrefix,
+			UserID:      t.UserID,
+			Scopes:      t.Scopes,
+			IsExchanged: t.IsExchanged,
+			ExpiresAt:   t.ExpiresAt,
+			CreatedAt:   t.CreatedAt,
+		})
+	}
+	return items, total, nil
+}
+
 // RevokeClientTokens 撤销客户端的所有令牌
 func (s *ClientService) RevokeClientTokens(clientID string) error {
 	// 删除所有相关的访问令牌和刷新令牌
@@ -413,27 +586,25 @@ func (s *ClientService) validateCreateRequest(req *CreateClientRequest) error {
 			return errors.New("无效的重定向URI: " + uri)
 		}
 	}
-	if len(req.Scopes) > 0 {
-		allowed := settings.GetStringSlice("oauth.allowed_scopes", scope.DefaultAllowedScopes())
-		allowedSet := map[string]struct{}{}
-		for _, s := range allowed {
-			s = strings.TrimSpace(s)
-			if s == "" {
-				continue
-			}
-			allowedSet[s] = struct{}{}
-		}
-		for _, sc := range req.Scopes {
-			sc = strings.TrimSpace(sc)
-			if sc == "" {
-				continue
-			}
-			if _, ok := allowedSet[sc]; !ok {
-				return errors.New("无效的 scope: " + sc)
-			}
-		}
+	if err := validateClientScopes(req.Scopes); err != nil {
+		return err
 	}
-	return nil
+	if err := validateAccessTokenFormat(req.AccessTokenFormat); err != nil {
+		return err
+	}
+	if err := validateLogoutURIs(req.PostLogoutRedirectURIs, req.BackchannelLogoutURI, req.FrontchannelLogoutURI); err != nil {
+		return err
+	}
+	if err := validateTokenEndpointAuthMethod(req.TokenEndpointAuthMethod, req.GrantTypes); err != nil {
+		return err
+	}
+	if err := validateClientKeys(req.TokenEndpointAuthMethod, compactJWKS(req.JWKS), strings.TrimSpace(req.JWKSURI)); err != nil {
+		return err
+	}
+	if err := s.subjects.ValidateClient(req.SubjectType, strings.TrimSpace(req.SectorIdentifierURI), req.RedirectURIs); err != nil {
+		return errors.New("无效的主体标识配置: " + err.Error())
+	}
+	return validateGrantTypes(req.GrantTypes)
 }
 
 func (s *ClientService) validateUpdateRequest(req *UpdateClientRequest) error {
@@ -447,25 +618,172 @@ func (s *ClientService) validateUpdateRequest(req *UpdateClientRequest) error {
 			}
 		}
 	}
-	if len(req.Scopes) > 0 {
-		allowed := settings.GetStringSlice("oauth.allowed_scopes", scope.DefaultAllowedScopes())
-		allowedSet := map[string]struct{}{}
-		for _, s := range allowed {
-			s = strings.TrimSpace(s)
-			if s == "" {
-				continue
-			}
-			allowedSet[s] = struct{}{}
+	if err := validateClientScopes(req.Scopes); err != nil {
+		return err
+	}
+	if err := validateAccessTokenFormat(req.AccessTokenFormat); err != nil {
+		return err
+	}
+	backchannel, frontchannel := "", ""
+	if req.BackchannelLogoutURI != nil {
+		backchannel = *req.BackchannelLogoutURI
+	}
+	if req.FrontchannelLogoutURI != nil {
+		frontchannel = *req.FrontchannelLogoutURI
+	}
+	if err := validateLogoutURIs(req.PostLogoutRedirectURIs, backchannel, frontchannel); err != nil {
+		return err
+	}
+	return validateGrantTypes(req.GrantTypes)
+}
+
+// applyClientOptions 将创建请求中的可选配置写入客户端
+func applyClientOptions(client *model.OAuthClient, req *CreateClientRequest) {
+	if len(req.GrantTypes) > 0 {
+		client.SetGrantTypeList(req.GrantTypes)
+	}
+	if req.AccessTokenFormat != "" {
+		client.AccessTokenFormat = req.AccessTokenFormat
+	}
+	if req.TokenEndpointAuthMethod != "" {
+		client.TokenEndpointAuthMethod = req.TokenEndpointAuthMethod
+	} else {
+		client.TokenEndpointAuthMethod = model.TokenEndpointAuthClientSecretBasic
+	}
+	client.JWKS = compactJWKS(req.JWKS)
+	client.JWKSURI = strings.TrimSpace(req.JWKSURI)
+	client.RequirePushedAuthorizationRequests = req.RequirePushedAuthorizationRequests
+	client.SubjectType = model.SubjectTypePublic
+	if req.SubjectType != "" {
+		client.SubjectType = req.SubjectType
+	}
+	client.SectorIdentifierURI = strings.TrimSpace(req.SectorIdentifierURI)
+	if len(req.PostLogoutRedirectURIs) > 0 {
+		client.SetPostLogoutRedirectURIList(req.PostLogoutRedirectURIs)
+	}
+	client.BackchannelLogoutURI = strings.TrimSpace(req.BackchannelLogoutURI)
+	client.FrontchannelLogoutURI = strings.TrimSpace(req.FrontchannelLogoutURI)
+}
+
+// supportedGrantTypes 客户端可配置的授权类型
+var supportedGrantTypes = map[string]bool{
+	"authorization_code": true,
+	"refresh_token":      true,
+	"client_credentials": true,
+	deviceCodeGrantType:  true,
+	"urn:ietf:params:oauth:grant-type:token-exchange": true,
+}
+
+func validateGrantTypes(grantTypes []string) error {
+	for _, gt := range grantTypes {
+		gt = strings.TrimSpace(gt)
+		if gt == "" {
+			continue
 		}
-		for _, sc := range req.Scopes {
-			sc = strings.TrimSpace(sc)
-			if sc == "" {
-				continue
-			}
-			if _, ok := allowedSet[sc]; !ok {
-				return errors.New("无效的 scope: " + sc)
+		if !supportedGrantTypes[gt] {
+			return errors.New("不支持的授权类型: " + gt)
+		}
+	}
+	return nil
+}
+
+// validateClientScopes 校验客户端可申请的 scope（oauth.allowed_scopes）
+func validateClientScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+	allowed := settings.GetStringSlice("oauth.allowed_scopes", scope.DefaultAllowedScopes())
+	allowedSet := map[string]struct{}{}
+	for _, s := range allowed {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		allowedSet[s] = struct{}{}
+	}
+	for _, sc := range scopes {
+		sc = strings.TrimSpace(sc)
+		if sc == "" {
+			continue
+		}
+		if _, ok := allowedSet[sc]; !ok {
+			return errors.New("无效的 scope: " + sc)
+		}
+	}
+	return nil
+}
+
+func validateAccessTokenFormat(format string) error {
+	switch format {
+	case "", model.AccessTokenFormatOpaque, model.AccessTokenFormatJWT:
+		return nil
+	default:
+		return errors.New("不支持的访问令牌格式: " + format)
+	}
+}
+
+// validateTokenEndpointAuthMethod 校验令牌端点认证方式；公共客户端无法使用 client_credentials
+func validateTokenEndpointAuthMethod(method string, grantTypes []string) error {
+	switch method {
+	case "", model.TokenEndpointAuthClientSecretBasic, model.TokenEndpointAuthClientSecretPost, model.TokenEndpointAuthPrivateKeyJWT:
+		return nil
+	case model.TokenEndpointAuthNone:
+		for _, gt := range grantTypes {
+			if strings.TrimSpace(gt) == "client_credentials" {
+				return errors.New("公共客户端不能使用 client_credentials 授权类型")
 			}
 		}
+		return nil
+	default:
+		return errors.New("不支持的令牌端点认证方式: " + method)
+	}
+}
+
+// validateClientKeys 校验客户端公钥：private_key_jwt 必须且只能登记 jwks 或 jwks_uri 之一
+func validateClientKeys(method, jwks, jwksURI string) error {
+	if jwks != "" && jwksURI != "" {
+		return errors.New("jwks 与 jwks_uri 不能同时设置")
+	}
+	if method == model.TokenEndpointAuthPrivateKeyJWT && jwks == "" && jwksURI == "" {
+		return errors.New("private_key_jwt 客户端必须配置 jwks 或 jwks_uri")
+	}
+	if jwks != "" {
+		if _, err := clientassertion.ParseJWKS([]byte(jwks)); err != nil {
+			return errors.New("无效的 jwks: " + err.Error())
+		}
+	}
+	// jwks_uri 与重定向地址相同，要求 https（本机回环地址除外）
+	if jwksURI != "" && !isValidRegisteredRedirectURI(jwksURI) {
+		return errors.New("无效的 jwks_uri: " + jwksURI)
+	}
+	return nil
+}
+
+// compactJWKS 将请求中的 JWK Set 压缩为紧凑 JSON 保存；JSON null 视为未设置
+func compactJWKS(raw json.RawMessage) string {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, trimmed); err != nil {
+		return string(trimmed)
+	}
+	return buf.String()
+}
+
+// validateLogoutURIs 校验登出相关地址，空值表示未配置
+func validateLogoutURIs(postLogoutRedirectURIs []string, backchannel, frontchannel string) error {
+	for _, uri := range postLogoutRedirectURIs {
+		if !isValidURL(uri) {
+			return errors.New("无效的登出重定向URI: " + uri)
+		}
+	}
+	if backchannel = strings.TrimSpace(backchannel); backchannel != "" && !isValidBackchannelURL(backchannel) {
+		return errors.New("无效的后端登出URI: " + backchannel)
+	}
+	if frontchannel = strings.TrimSpace(frontchannel); frontchannel != "" && !isValidURL(frontchannel) {
+		return errors.New("无效的前端登出URI: " + frontchannel)
 	}
 	return nil
 }
@@ -474,20 +792,55 @@ func isValidURL(urlStr string) bool {
 	return strings.HasPrefix(urlStr, "http://") || strings.HasPrefix(urlStr, "https://")
 }
 
+// isValidBackchannelURL 后端登出地址由服务端直接请求，须为 https 且不能指向本机或内网；
+// 域名解析后的地址在每次投递时由出站客户端再次校验
+func isValidBackchannelURL(urlStr string) bool {
+	u, err := url.Parse(urlStr)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && !utils.IsPublicIP(ip) {
+		return false
+	}
+	return true
+}
+
 // 转换函数
 func (s *ClientService) clientToResponse(client *model.OAuthClient, plainSecret string) *ClientResponse {
 	resp := &ClientResponse{
-		ID:             client.ID,
-		Name:           client.App.Name,
-		Description:    client.App.Description,
-		ClientID:       client.ClientID,
-		RedirectURIs:   client.GetRedirectURIList(),
-		Scopes:         client.GetScopeList(),
-		AllowedOrigins: client.GetAllowedOriginList(),
-		IsActive:       client.IsActive,
-		CreatedBy:      client.CreatedBy,
-		CreatedAt:      client.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:      client.UpdatedAt.Format(time.RFC3339),
+		ID:                client.ID,
+		Name:              client.App.Name,
+		Description:       client.App.Description,
+		ClientID:          client.ClientID,
+		RedirectURIs:      client.GetRedirectURIList(),
+		Scopes:            client.GetScopeList(),
+		GrantTypes:        client.GetGrantTypeList(),
+		AllowedOrigins:    client.GetAllowedOriginList(),
+		AccessTokenFormat: client.AccessTokenFormat,
+		IsActive:          client.IsActive,
+
+		PostLogoutRedirectURIs: client.GetPostLogoutRedirectURIList(),
+		BackchannelLogoutURI:   client.BackchannelLogoutURI,
+		FrontchannelLogoutURI:  client.FrontchannelLogoutURI,
+		CreatedBy:              client.CreatedBy,
+		CreatedAt:              client.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:              client.UpdatedAt.Format(time.RFC3339),
+
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		IsPublic:                client.IsPublic(),
+		JWKSURI:                 client.JWKSURI,
+
+		RequirePushedAuthorizationRequests: client.RequirePushedAuthorizationRequests,
+
+		SubjectType:         client.SubjectType,
+		SectorIdentifierURI: client.SectorIdentifierURI,
+	}
+	if client.JWKS != "" {
+		resp.JWKS = json.RawMessage(client.JWKS)
 	}
 
 	if plainSecret != "" {
@@ -0,0 +1,396 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// deviceCodeGrantType 设备授权类型（RFC 8628 §3.4）
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeTTL             = 10 * time.Minute
+	deviceCodeDefaultInterval = 5 // 秒
+	deviceCodeSlowDownStep    = 5 // 每次 slow_down 增加的间隔（秒）
+	userCodeMaxAttempts       = 5
+)
+
+// CreateDeviceAuthorization 创建设备授权请求，返回授权记录与设备码明文；数据库只保存设备码摘要
+func (s *OAuthServerService) CreateDeviceAuthorization(creds ClientCredentials, scope string) (*model.OAuthDeviceCode, string, error) {
+	client, err := s.authenticateClient(creds)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !clientAllowsGrant(client, deviceCodeGrantType) {
+		return nil, "", errors.New("unauthorized_client")
+	}
+
+	scope = strings.Join(parseScopeList(scope), " ")
+	if err := validateRequestedScopes(client, scope); err != nil {
+		return nil, "", err
+	}
+
+	deviceCode, err := model.GenerateDeviceCode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	record := &model.OAuthDeviceCode{
+		DeviceCode: utils.HashOAuthToken(deviceCode),
+		ClientID:   client.ClientID,
+		AppID:      client.AppID,
+		TenantID:   s.resolveClientTenantID(client),
+		Scopes:     scope,
+		Status:     model.DeviceCodeStatusPending,
+		Interval:   deviceCodeDefaultInterval,
+		ExpiresAt:  time.Now().Add(deviceCodeTTL),
+	}
+
+	// 用户码空间较小，冲突时重新生成
+	for attempt := 0; attempt < userCodeMaxAttempts; attempt++ {
+		userCode, err := model.GenerateUserCode()
+		if err != nil {
+			return nil, "", err
+		}
+		var count int64
+		if err := s.db.Model(&model.OAuthDeviceCode{}).Where("user_code = ?", userCode).Count(&count).Error; err != nil {
+			return nil, "", err
+		}
+		if count == 0 {
+			record.UserCode = userCode
+			break
+		}
+	}
+	if record.UserCode == "" {
+		return nil, "", errors.New("server_error")
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, "", err
+	}
+	return record, deviceCode, nil
+}
+
+// GetPendingDeviceCode 按用户码查找待确认的设备授权
+func (s *OAuthServerService) GetPendingDeviceCode(userCode string) (*model.OAuthDeviceCode, error) {
+	userCode = model.NormalizeUserCode(userCode)
+	if userCode == "" {
+		return nil, errors.New("invalid_user_code")
+	}
+
+	var record model.OAuthDeviceCode
+	if err := s.db.Preload("Client").Preload("Client.App").
+		Where("user_code = ? AND status = ?", userCode, model.DeviceCodeStatusPending).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid_user_code")
+		}
+		return nil, err
+	}
+	if record.IsExpired() {
+		return nil, errors.New("expired_token")
+	}
+	if !record.Client.IsActive {
+		return nil, errors.New("invalid_client")
+	}
+	return &record, nil
+}
+
+// ApproveDeviceCode 用户同意设备授权
+func (s *OAuthServerService) ApproveDeviceCode(userID uint, record *model.OAuthDeviceCode) error {
+	now := time.Now()
+	return s.transitionDeviceCode(record, model.DeviceCodeStatusPending, map[string]interface{}{
+		"status":    model.DeviceCodeStatusApproved,
+		"user_id":   userID,
+		"auth_time": now,
+	})
+}
+
+// DenyDeviceCode 用户拒绝设备授权
+func (s *OAuthServerService) DenyDeviceCode(record *model.OAuthDeviceCode) error {
+	return s.transitionDeviceCode(record, model.DeviceCodeStatusPending, map[string]interface{}{
+		"status": model.DeviceCodeStatusDenied,
+	})
+}
+
+// transitionDeviceCode 仅当设备码仍处于 from 状态时更新，防止并发确认或重复领取
+func (s *OAuthServerService) transitionDeviceCode(record *model.OAuthDeviceCode, from model.DeviceCodeStatus, updates map[string]interface{}) error {
+	result := s.db.Model(&model.OAuthDeviceCode{}).
+		Where("id = ? AND status = ?", record.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("invalid_grant")
+	}
+	return nil
+}
+
+// ExchangeDeviceCode 设备轮询令牌端点（RFC 8628 §3.4-3.5）
+func (s *OAuthServerService) ExchangeDeviceCode(deviceCode string, creds ClientCredentials, issuer string) (*TokenResponse, error) {
+	client, err := s.authenticateClient(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	var record model.OAuthDeviceCode
+	if err := s.db.Where("device_code = ? AND client_id = ?", utils.HashOAuthToken(deviceCode), client.ClientID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid_grant")
+		}
+		return nil, err
+	}
+
+	if record.IsExpired() {
+		return nil, errors.New("expired_token")
+	}
+
+	// 轮询过快时要求客户端放慢速度
+	now := time.Now()
+	interval := time.Duration(record.Interval) * time.Second
+	if record.LastPolledAt != nil && now.Sub(*record.LastPolledAt) < interval {
+		s.db.Model(&record).Updates(map[string]interface{}{
+			"interval":       record.Interval + deviceCodeSlowDownStep,
+			"last_polled_at": now,
+		})
+		return nil, errors.New("slow_down")
+	}
+	s.db.Model(&record).Update("last_polled_at", now)
+
+	switch record.Status {
+	case model.DeviceCodeStatusPending:
+		return nil, errors.New("authorization_pending")
+	case model.DeviceCodeStatusDenied:
+		return nil, errors.New("access_denied")
+	case model.DeviceCodeStatusApproved:
+	default:
+		return nil, errors.New("invalid_grant")
+	}
+
+	if err := s.transitionDeviceCode(&record, model.DeviceCodeStatusApproved, map[string]interface{}{
+		"status": model.DeviceCodeStatusConsumed,
+	}); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.issueUserTokens(client, &userTokenGrant{
+		ClientID: record.ClientID,
+		UserID:   record.UserID,
+		TenantID: record.TenantID,
+		AppID:    record.AppID,
+		Scopes:   record.Scopes,
+		AuthTime: record.AuthTime,
+	}, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.Model(client).Update("last_used_at", &now)
+	return resp, nil
+}
+
+// deviceVerificationURI 用户输入用户码的前端页面地址
+func deviceVerificationURI(c *fiber.Ctx) string {
+	base := strings.TrimRight(config.Get().UI.BaseURL, "/")
+	if base == "" {
+		base = requestBaseURL(c)
+	}
+	return base + "/device"
+}
+
+// DeviceAuthorizationHandler 设备授权端点
+// POST /oauth/device_authorization
+func DeviceAuthorizationHandler(c *fiber.Ctx) error {
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" {
+		return oauthInvalidClient(c)
+	}
+
+	record, deviceCode, err := oauthServerService.CreateDeviceAuthorization(creds, c.FormValue("scope"))
+	if err != nil {
+		switch err.Error() {
+		case "invalid_client":
+			return oauthInvalidClient(c)
+		case "unauthorized_client":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":             "unauthorized_client",
+				"error_description": "Client is not allowed to use the device_code grant",
+			})
+		case "invalid_scope":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":             "invalid_scope",
+				"error_description": "Requested scope exceeds the client's allowed scopes",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":             "server_error",
+				"error_description": "Failed to create device authorization",
+			})
+		}
+	}
+
+	verificationURI := deviceVerificationURI(c)
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(fiber.Map{
+		"device_code":               deviceCode,
+		"user_code":                 record.UserCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + record.UserCode,
+		"expires_in":                int(deviceCodeTTL.Seconds()),
+		"interval":                  record.Interval,
+	})
+}
+
+// handleDeviceCodeGrant 处理设备码授权轮询
+func handleDeviceCodeGrant(c *fiber.Ctx) error {
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" {
+		return oauthInvalidClient(c)
+	}
+
+	deviceCode := strings.TrimSpace(c.FormValue("device_code"))
+	if deviceCode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":             "invalid_request",
+			"error_description": "device_code is required",
+		})
+	}
+
+	tokenResponse, err := oauthServerService.ExchangeDeviceCode(deviceCode, creds, oidcIssuer(c))
+	if err != nil {
+		switch err.Error() {
+		case "invalid_client":
+			return oauthInvalidClient(c)
+		case "authorization_pending", "slow_down", "access_denied", "expired_token", "invalid_grant":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":             "server_error",
+				"error_description": "Failed to issue access token",
+			})
+		}
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(tokenResponse)
+}
+
+// deviceVerificationError 用户码校验失败的响应
+func deviceVerificationError(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case "invalid_user_code", "invalid_grant":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":             "invalid_user_code",
+			"error_description": "User code is invalid or has already been used",
+		})
+	case "expired_token":
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error":             "expired_token",
+			"error_description": "User code has expired",
+		})
+	case "invalid_client":
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":             "invalid_client",
+			"error_description": "Client is inactive",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "server_error",
+		})
+	}
+}
+
+// DeviceVerificationInfoHandler 获取用户码对应的授权信息，供用户控制台展示确认页
+// GET /user/oauth/device?user_code=XXXX-XXXX
+func DeviceVerificationInfoHandler(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	record, err := oauthServerService.GetPendingDeviceCode(c.Query("user_code"))
+	if err != nil {
+		return deviceVerificationError(c, err)
+	}
+
+	decision, err := oauthServerService.EvaluateUserTenantAuthorization(userID, &record.Client)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":             "tenant_context_error",
+			"error_description": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"user_code":          record.UserCode,
+			"client_id":          record.ClientID,
+			"client_name":        record.Client.App.Name,
+			"client_description": record.Client.App.Description,
+			"is_verified":        record.Client.App.IsVerified,
+			"scopes":             parseScopeList(record.Scopes),
+			"expires_at":         record.ExpiresAt,
+			"allowed":            decision.Allowed,
+			"join_required":      decision.JoinRequired,
+			"decision_tenant_id": decision.TenantID,
+		},
+	})
+}
+
+// DeviceVerificationRequest 用户确认设备授权请求
+type DeviceVerificationRequest struct {
+	UserCode   string `json:"user_code" form:"user_code"`
+	Action     string `json:"action" form:"action"` // "allow" 或 "deny"
+	JoinTenant bool   `json:"join_tenant" form:"join_tenant"`
+}
+
+// DeviceVerificationHandler 用户在控制台确认或拒绝设备授权
+// POST /user/oauth/device
+func DeviceVerificationHandler(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req DeviceVerificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请求参数错误",
+		})
+	}
+
+	record, err := oauthServerService.GetPendingDeviceCode(req.UserCode)
+	if err != nil {
+		return deviceVerificationError(c, err)
+	}
+
+	if req.Action != "allow" {
+		if err := oauthServerService.DenyDeviceCode(record); err != nil {
+			return deviceVerificationError(c, err)
+		}
+		aduit.LogAudit(userID, "拒绝OAuth2设备授权", "oauth_client", record.ClientID, c.IP(), c.Get("User-Agent"))
+		return c.JSON(fiber.Map{
+			"message": "已拒绝设备授权",
+		})
+	}
+
+	if ok, resp := enforceConsentTenant(c, userID, &record.Client, req.JoinTenant); !ok {
+		return resp
+	}
+
+	if err := oauthServerService.ApproveDeviceCode(userID, record); err != nil {
+		return deviceVerificationError(c, err)
+	}
+
+	aduit.LogAudit(userID, "OAuth2设备授权", "oauth_client", record.ClientID, c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{
+		"message": "设备授权成功，请返回设备继续操作",
+	})
+}
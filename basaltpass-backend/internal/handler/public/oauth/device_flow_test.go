@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"strconv"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+func setupDeviceFlowTest(t *testing.T, clientID string) (*gorm.DB, *OAuthServerService, *model.User, *model.OAuthClient) {
+	t.Helper()
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, clientID, "openid,profile")
+	if err := db.AutoMigrate(&model.OAuthDeviceCode{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	if err := db.Model(client).Update("grant_types", deviceCodeGrantType+",refresh_token").Error; err != nil {
+		t.Fatalf("update grant types failed: %v", err)
+	}
+	return db, NewOAuthServerService(), user, client
+}
+
+// resetDevicePoll 清除上次轮询时间，模拟客户端按间隔等待后再次轮询
+func resetDevicePoll(t *testing.T, db *gorm.DB, deviceCode string) {
+	t.Helper()
+	if err := db.Model(&model.OAuthDeviceCode{}).Where("device_code = ?", utils.HashOAuthToken(deviceCode)).Update("last_polled_at", nil).Error; err != nil {
+		t.Fatalf("reset poll failed: %v", err)
+	}
+}
+
+func TestDeviceFlowApprove(t *testing.T) {
+	db, svc, user, client := setupDeviceFlowTest(t, "client-device")
+
+	record, deviceCode, err := svc.CreateDeviceAuthorization(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "openid profile")
+	if err != nil {
+		t.Fatalf("create device authorization failed: %v", err)
+	}
+	if len(record.UserCode) != 9 || record.UserCode[4] != '-' {
+		t.Fatalf("unexpected user code format %q", record.UserCode)
+	}
+	// 数据库只保存设备码摘要
+	if record.DeviceCode != utils.HashOAuthToken(deviceCode) {
+		t.Fatalf("expected device code to be stored hashed, got %q", record.DeviceCode)
+	}
+
+	if _, err := svc.ExchangeDeviceCode(deviceCode, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "https://id.example.com/api/v1"); err == nil || err.Error() != "authorization_pending" {
+		t.Fatalf("expected authorization_pending, got %v", err)
+	}
+	if _, err := svc.ExchangeDeviceCode(deviceCode, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "https://id.example.com/api/v1"); err == nil || err.Error() != "slow_down" {
+		t.Fatalf("expected slow_down on fast poll, got %v", err)
+	}
+
+	// 用户输入时大小写与分隔符不敏感
+	pending, err := svc.GetPendingDeviceCode(" " + record.UserCode[:4] + record.UserCode[5:] + " ")
+	if err != nil {
+		t.Fatalf("lookup user code failed: %v", err)
+	}
+	if err := svc.ApproveDeviceCode(user.ID, pending); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	resetDevicePoll(t, db, deviceCode)
+	resp, err := svc.ExchangeDeviceCode(deviceCode, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "https://id.example.com/api/v1")
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" || resp.IDToken == "" {
+		t.Fatalf("expected access, refresh and id tokens, got %+v", resp)
+	}
+	claims := parseIDTokenForTest(t, svc, resp.IDToken)
+	if claims["sub"] != strconv.FormatUint(uint64(user.ID), 10) || claims["aud"] != client.ClientID {
+		t.Fatalf("unexpected id_token claims %v", claims)
+	}
+
+	resetDevicePoll(t, db, deviceCode)
+	if _, err := svc.ExchangeDeviceCode(deviceCode, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "https://id.example.com/api/v1"); err == nil || err.Error() != "invalid_grant" {
+		t.Fatalf("expected device code to be single use, got %v", err)
+	}
+}
+
+func TestDeviceFlowDeny(t *testing.T) {
+	db, svc, _, client := setupDeviceFlowTest(t, "client-device-deny")
+
+	record, deviceCode, err := svc.CreateDeviceAuthorization(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "openid")
+	if err != nil {
+		t.Fatalf("create device authorization failed: %v", err)
+	}
+	pending, err := svc.GetPendingDeviceCode(record.UserCode)
+	if err != nil {
+		t.Fatalf("lookup user code failed: %v", err)
+	}
+	if err := svc.DenyDeviceCode(pending); err != nil {
+		t.Fatalf("deny failed: %v", err)
+	}
+	if _, err := svc.GetPendingDeviceCode(record.UserCode); err == nil {
+		t.Fatalf("denied user code must not be pending")
+	}
+
+	resetDevicePoll(t, db, deviceCode)
+	if _, err := svc.ExchangeDeviceCode(deviceCode, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, ""); err == nil || err.Error() != "access_denied" {
+		t.Fatalf("expected access_denied, got %v", err)
+	}
+}
+
+func TestDeviceAuthorizationRequiresGrantType(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	_, client := setupIDTokenTestData(t, db, "client-device-none", "openid")
+	if err := db.AutoMigrate(&model.OAuthDeviceCode{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	svc := NewOAuthServerService()
+
+	if _, _, err := svc.CreateDeviceAuthorization(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "openid"); err == nil || err.Error() != "unauthorized_client" {
+		t.Fatalf("expected unauthorized_client, got %v", err)
+	}
+}
@@ -1,24 +1,14 @@
 package oauth
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"encoding/base64"
-	"fmt"
-	"math/big"
-	"sync"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/clientassertion"
+	"basaltpass-backend/internal/service/signingkey"
+	"basaltpass-backend/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-var (
-	// 全局RSA密钥对
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
-	keyID      = "basaltpass-rsa-2024"
-	keyMutex   sync.RWMutex
-)
-
 // OIDCDiscoveryResponse OIDC Discovery响应
 type OIDCDiscoveryResponse struct {
 	Issuer                            string   `json:"issuer"`
@@ -27,6 +17,7 @@ type OIDCDiscoveryResponse struct {
 	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
 	JwksURI                           string   `json:"jwks_uri"`
 	RegistrationEndpoint              string   `json:"registration_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint,omitempty"`
 	ScopesSupported                   []string `json:"scopes_supported"`
 	ResponseTypesSupported            []string `json:"response_types_supported"`
 	ResponseModesSupported            []string `json:"response_modes_supported"`
@@ -37,27 +28,68 @@ type OIDCDiscoveryResponse struct {
 	ClaimsSupported                   []string `json:"claims_supported"`
 	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
 
-	// TODO ⬇️ One-Tap Auth支持
-	CheckSessionIframe string `json:"check_session_iframe,omitempty"`
-	EndSessionEndpoint string `json:"end_session_endpoint,omitempty"`
+	// 客户端认证（RFC 8414 §2）
+	TokenEndpointAuthSigningAlgValuesSupported         []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	IntrospectionEndpoint                              string   `json:"introspection_endpoint,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported          []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthSigningAlgValuesSupported []string `json:"introspection_endpoint_auth_signing_alg_values_supported,omitempty"`
+	RevocationEndpoint                                 string   `json:"revocation_endpoint,omitempty"`
+	RevocationEndpointAuthMethodsSupported             []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointAuthSigningAlgValuesSupported    []string `json:"revocation_endpoint_auth_signing_alg_values_supported,omitempty"`
+
+	// 推送授权请求与签名请求对象（RFC 9126 / RFC 9101）
+	PushedAuthorizationRequestEndpoint     string   `json:"pushed_authorization_request_endpoint,omitempty"`
+	RequirePushedAuthorizationRequests     bool     `json:"require_pushed_authorization_requests"`
+	RequestParameterSupported              bool     `json:"request_parameter_supported"`
+	RequestURIParameterSupported           bool     `json:"request_uri_parameter_supported"`
+	RequestObjectSigningAlgValuesSupported []string `json:"request_object_signing_alg_values_supported"`
+	ClaimsParameterSupported               bool     `json:"claims_parameter_supported"`
+
+	// 认证请求（OIDC Core §3.1.2.1）
+	ACRValuesSupported    []string `json:"acr_values_supported"`
+	PromptValuesSupported []string `json:"prompt_values_supported"`
+
+	// 会话管理与登出
+	CheckSessionIframe                 string `json:"check_session_iframe,omitempty"`
+	EndSessionEndpoint                 string `json:"end_session_endpoint,omitempty"`
+	BackchannelLogoutSupported         bool   `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported  bool   `json:"backchannel_logout_session_supported"`
+	FrontchannelLogoutSupported        bool   `json:"frontchannel_logout_supported"`
+	FrontchannelLogoutSessionSupported bool   `json:"frontchannel_logout_session_supported"`
+}
+
+// confidentialClientAuthMethods 内省、撤销端点接受的客户端认证方式（不接受公共客户端）
+var confidentialClientAuthMethods = []string{
+	model.TokenEndpointAuthClientSecretBasic,
+	model.TokenEndpointAuthClientSecretPost,
+	model.TokenEndpointAuthPrivateKeyJWT,
+}
+
+// requestBaseURL 返回对外基础URL：已配置 server.public_url 时使用配置值，否则按当前请求构建
+func requestBaseURL(c *fiber.Ctx) string {
+	return utils.RequestBaseURL(c)
+}
+
+// oidcIssuer 返回OIDC issuer，需与Discovery文档及id_token中的iss保持一致
+func oidcIssuer(c *fiber.Ctx) string {
+	return requestBaseURL(c) + "/api/v1"
 }
 
 // DiscoveryHandler OIDC Discovery端点
 // GET /.well-known/openid-configuration
 func DiscoveryHandler(c *fiber.Ctx) error {
 	// 构建基础URL
-	scheme := "http"
-	if c.Secure() {
-		scheme = "https"
-	}
-	baseURL := scheme + "://" + c.Get("Host")
+	baseURL := requestBaseURL(c)
 
 	discovery := &OIDCDiscoveryResponse{
-		Issuer:                baseURL + "/api/v1",
+		Issuer:                oidcIssuer(c),
 		AuthorizationEndpoint: baseURL + "/api/v1/oauth/authorize",
 		TokenEndpoint:         baseURL + "/api/v1/oauth/token",
 		UserinfoEndpoint:      baseURL + "/api/v1/oauth/userinfo",
 		JwksURI:               baseURL + "/api/v1/oauth/jwks",
+		RegistrationEndpoint:  baseURL + "/api/v1/oauth/register",
+
+		DeviceAuthorizationEndpoint: baseURL + "/api/v1/oauth/device_authorization",
 
 		ScopesSupported: []string{
 			"openid",
@@ -70,44 +102,72 @@ func DiscoveryHandler(c *fiber.Ctx) error {
 			"id_token",
 			"code id_token",
 		},
-		ResponseModesSupported: []string{
-			"query",
-			"fragment",
-			// TODO ⬇️ "web_message", // One-Tap Auth支持
-		},
+		ResponseModesSupported: supportedResponseModes,
 		GrantTypesSupported: []string{
 			"authorization_code",
 			"refresh_token",
+			"client_credentials",
+			deviceCodeGrantType,
 			"urn:ietf:params:oauth:grant-type:token-exchange",
 		},
 		TokenEndpointAuthMethodsSupported: []string{
-			"client_secret_basic",
-			"client_secret_post",
+			model.TokenEndpointAuthClientSecretBasic,
+			model.TokenEndpointAuthClientSecretPost,
+			model.TokenEndpointAuthNone,
+			model.TokenEndpointAuthPrivateKeyJWT,
 		},
 		SubjectTypesSupported: []string{
-			"public",
+			model.SubjectTypePublic,
+			model.SubjectTypePairwise,
 		},
 		IDTokenSigningAlgValuesSupported: []string{
-			"HS256",
 			"RS256",
 		},
 		ClaimsSupported: []string{
+			"iss",
 			"sub",
+			"aud",
+			"exp",
+			"iat",
+			"auth_time",
+			"nonce",
+			"acr",
+			"amr",
+			"at_hash",
 			"email",
 			"email_verified",
 			"name",
 			"nickname",
 			"picture",
 			"preferred_username",
+			"phone_number",
+			"phone_number_verified",
 		},
 		CodeChallengeMethodsSupported: []string{
 			"plain",
 			"S256",
 		},
 
-		// TODO ⬇️ One-Tap Auth和Silent Auth端点
-		CheckSessionIframe: baseURL + "/api/v1/check_session_iframe",
-		EndSessionEndpoint: baseURL + "/api/v1/end_session",
+		TokenEndpointAuthSigningAlgValuesSupported:         clientassertion.SigningAlgorithms,
+		IntrospectionEndpoint:                              baseURL + "/api/v1/oauth/introspect",
+		IntrospectionEndpointAuthMethodsSupported:          confidentialClientAuthMethods,
+		IntrospectionEndpointAuthSigningAlgValuesSupported: clientassertion.SigningAlgorithms,
+		RevocationEndpoint:                                 baseURL + "/api/v1/oauth/revoke",
+		RevocationEndpointAuthMethodsSupported:             confidentialClientAuthMethods,
+		RevocationEndpointAuthSigningAlgValuesSupported:    clientassertion.SigningAlgorithms,
+
+		PushedAuthorizationRequestEndpoint:     baseURL + "/api/v1/oauth/par",
+		RequestParameterSupported:              true,
+		RequestObjectSigningAlgValuesSupported: clientassertion.SigningAlgorithms,
+		ClaimsParameterSupported:               true,
+
+		ACRValuesSupported:    supportedACRValues,
+		PromptValuesSupported: []string{promptNone, promptLogin, promptConsent, promptSelectAccount},
+
+		CheckSessionIframe:          baseURL + "/api/v1/check_session_iframe",
+		EndSessionEndpoint:          baseURL + "/api/v1/end_session",
+		BackchannelLogoutSupported:  true,
+		FrontchannelLogoutSupported: true,
 	}
 
 	return c.JSON(discovery)
@@ -115,99 +175,25 @@ func DiscoveryHandler(c *fiber.Ctx) error {
 
 // JWKSHandler JWKS端点
 // GET /oauth/jwks
+// 发布全部可用于验证的公钥（active、next 以及保留期内的 retired），便于依赖方平滑轮换
 func JWKSHandler(c *fiber.Ctx) error {
-	keyMutex.RLock()
-	defer keyMutex.RUnlock()
-
-	// 确保密钥已初始化
-	if publicKey == nil {
-		if err := initKeys(); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":             "key_generation_failed",
-				"error_description": "Failed to generate RSA keys",
-			})
-		}
-	}
-
-	// 生成RSA公钥的JWK
-	jwk, err := generateRSAJWK(publicKey, keyID)
+	keys, err := oauthServerService.keys.PublishedKeys()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":             "jwk_generation_failed",
-			"error_description": "Failed to generate JWK",
+			"error":             "jwks_unavailable",
+			"error_description": "Failed to load signing keys",
 		})
 	}
 
-	jwks := map[string]interface{}{
-		"keys": []interface{}{jwk},
+	jwks := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		jwks = append(jwks, signingkey.JWK(k))
 	}
 
-	return c.JSON(jwks)
-}
-
-// initKeys 初始化RSA密钥对
-func initKeys() error {
-	if privateKey != nil {
-		return nil // 已经初始化
-	}
-
-	// 生成2048位RSA密钥对
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return fmt.Errorf("failed to generate RSA key: %w", err)
-	}
-
-	privateKey = key
-	publicKey = &key.PublicKey
-	return nil
-}
-
-// generateRSAJWK 生成RSA公钥的JWK表示
-func generateRSAJWK(pubKey *rsa.PublicKey, kid string) (map[string]interface{}, error) {
-	// 将大整数转换为base64url编码
-	nBytes := pubKey.N.Bytes()
-	eBytes := big.NewInt(int64(pubKey.E)).Bytes()
-
-	// Base64URL编码（无填充）
-	n := base64.RawURLEncoding.EncodeToString(nBytes)
-	e := base64.RawURLEncoding.EncodeToString(eBytes)
-
-	jwk := map[string]interface{}{
-		"kty": "RSA",   // 密钥类型
-		"use": "sig",   // 用途：签名
-		"alg": "RS256", // 算法
-		"kid": kid,     // 密钥ID
-		"n":   n,       // RSA模数
-		"e":   e,       // RSA指数
-	}
-
-	return jwk, nil
-}
-
-// GetPrivateKey 获取私钥（用于JWT签名）
-func GetPrivateKey() (*rsa.PrivateKey, error) {
-	keyMutex.RLock()
-	if privateKey != nil {
-		defer keyMutex.RUnlock()
-		return privateKey, nil
-	}
-	keyMutex.RUnlock()
-
-	keyMutex.Lock()
-	defer keyMutex.Unlock()
-
-	if privateKey == nil {
-		if err := initKeys(); err != nil {
-			return nil, err
-		}
-	}
-
-	return privateKey, nil
-}
-
-// GetKeyID 获取密钥ID
-func GetKeyID() string {
-	return keyID
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(fiber.Map{
+		"keys": jwks,
+	})
 }
 
 // CheckSessionIframeHandler 会话检查iframe端点
@@ -233,13 +219,3 @@ func CheckSessionIframeHandler(c *fiber.Ctx) error {
 	c.Set("Content-Type", "text/html")
 	return c.SendString(html)
 }
-
-// EndSessionHandler 结束会话端点
-// GET /end_session
-func EndSessionHandler(c *fiber.Ctx) error {
-	// TODO ⬇️ 实现会话注销逻辑
-	return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
-		"error":             "not_implemented",
-		"error_description": "End session endpoint - TODO ⬇️",
-	})
-}
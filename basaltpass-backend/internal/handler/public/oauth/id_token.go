@@ -0,0 +1,135 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// idTokenTTL ID Token 有效期，与访问令牌保持一致
+const idTokenTTL = time.Hour
+
+// idTokenParams 签发ID Token所需的上下文
+type idTokenParams struct {
+	Issuer      string
+	ClientID    string
+	UserID      uint
+	Scopes      string
+	Nonce       string
+	AuthTime    *time.Time
+	ACR         string
+	AMR         []string
+	AccessToken string
+	Claims      string // claims 请求参数
+}
+
+// scopeListContains 判断空格分隔的scope中是否包含指定值
+func scopeListContains(scopes string, target string) bool {
+	for _, s := range parseScopeList(scopes) {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
+
+// computeAtHash 计算 at_hash：访问令牌 SHA-256 摘要左半部分的 base64url 编码（OIDC Core §3.1.3.6）
+func computeAtHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// buildIDTokenClaims 根据授权的scope组装ID Token声明
+// subject 为用户在该客户端下的 sub（public 或 pairwise）
+func buildIDTokenClaims(user *model.User, subject string, p *idTokenParams, now time.Time) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss": p.Issuer,
+		"sub": subject,
+		"aud": p.ClientID,
+		"azp": p.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(idTokenTTL).Unix(),
+	}
+
+	if p.AuthTime != nil && !p.AuthTime.IsZero() {
+		claims["auth_time"] = p.AuthTime.Unix()
+	}
+	if p.Nonce != "" {
+		claims["nonce"] = p.Nonce
+	}
+	if p.ACR != "" {
+		claims["acr"] = p.ACR
+	}
+	if len(p.AMR) > 0 {
+		claims["amr"] = p.AMR
+	}
+	if p.AccessToken != "" {
+		claims["at_hash"] = computeAtHash(p.AccessToken)
+	}
+
+	if scopeListContains(p.Scopes, "profile") {
+		if user.Nickname != "" {
+			claims["name"] = user.Nickname
+			claims["nickname"] = user.Nickname
+			claims["preferred_username"] = user.Nickname
+		} else if user.Email != "" {
+			claims["preferred_username"] = user.Email
+		}
+		if user.AvatarURL != "" {
+			claims["picture"] = user.AvatarURL
+		}
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if scopeListContains(p.Scopes, "email") && user.Email != "" {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if scopeListContains(p.Scopes, "phone") && user.Phone != "" {
+		claims["phone_number"] = user.Phone
+		claims["phone_number_verified"] = user.PhoneVerified
+	}
+
+	return claims
+}
+
+// IssueIDToken 签发 RS256 ID Token（仅当授权scope包含openid时调用）
+func (s *OAuthServerService) IssueIDToken(p *idTokenParams) (string, error) {
+	if p == nil || strings.TrimSpace(p.ClientID) == "" || p.UserID == 0 {
+		return "", fmt.Errorf("invalid id_token parameters")
+	}
+
+	var user model.User
+	if err := s.db.First(&user, p.UserID).Error; err != nil {
+		return "", err
+	}
+	var client model.OAuthClient
+	if err := s.db.Where("client_id = ?", p.ClientID).First(&client).Error; err != nil {
+		return "", err
+	}
+	subject, err := s.subjects.Subject(&client, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := s.keys.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+
+	claims := buildIDTokenClaims(&user, subject, p, time.Now())
+	extra, err := s.customClaims(s.resolveClientTenantID(&client), client.AppID, user.ID, p.Scopes, p.Claims, model.ClaimTargetIDToken)
+	if err != nil {
+		return "", err
+	}
+	mergeClaims(claims, extra)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.PrivateKey)
+}
@@ -0,0 +1,156 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+func setupIDTokenTestData(t *testing.T, db *gorm.DB, clientID string, scopes string) (*model.User, *model.OAuthClient) {
+	t.Helper()
+
+	if err := db.AutoMigrate(&model.AppUser{}, &model.OAuthAuthorizationCode{}, &model.OAuthAccessToken{}, &model.OAuthRefreshToken{}, &model.SigningKey{}, &model.AppClaimMapping{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+
+	tenant := model.Tenant{Name: "OIDC Tenant " + clientID, Code: "oidc-" + clientID, Status: model.TenantStatusActive}
+	if err := db.Create(&tenant).Error; err != nil {
+		t.Fatalf("create tenant failed: %v", err)
+	}
+	user := model.User{TenantID: tenant.ID, Email: clientID + "@example.com", Nickname: "Alice", EmailVerified: true, PasswordHash: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	app := model.App{TenantID: tenant.ID, Name: "OIDC App", Status: model.AppStatusActive}
+	if err := db.Create(&app).Error; err != nil {
+		t.Fatalf("create app failed: %v", err)
+	}
+
+	secretHash := sha256.Sum256([]byte("secret"))
+	client := model.OAuthClient{
+		AppID:        app.ID,
+		ClientID:     clientID,
+		ClientSecret: hex.EncodeToString(secretHash[:]),
+		RedirectURIs: "https://rp.example.com/callback",
+		Scopes:       scopes,
+		IsActive:     true,
+		CreatedBy:    user.ID,
+	}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatalf("create client failed: %v", err)
+	}
+	return &user, &client
+}
+
+func parseIDTokenForTest(t *testing.T, svc *OAuthServerService, raw string) jwt.MapClaims {
+	t.Helper()
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := svc.keys.LookupKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil || !token.Valid {
+		t.Fatalf("id_token verification failed: %v", err)
+	}
+	return token.Claims.(jwt.MapClaims)
+}
+
+func TestExchangeCodeForTokenIssuesIDToken(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-oidc", "openid,profile,email")
+	svc := NewOAuthServerService()
+
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "openid profile email",
+		Nonce:        "n-0S6_WzA2Mj",
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+		Issuer:      "https://id.example.com/api/v1",
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if resp.IDToken == "" {
+		t.Fatalf("expected id_token for openid scope")
+	}
+
+	claims := parseIDTokenForTest(t, svc, resp.IDToken)
+	if claims["iss"] != "https://id.example.com/api/v1" {
+		t.Fatalf("unexpected iss %v", claims["iss"])
+	}
+	if claims["aud"] != client.ClientID {
+		t.Fatalf("unexpected aud %v", claims["aud"])
+	}
+	if claims["nonce"] != authReq.Nonce {
+		t.Fatalf("expected nonce to round-trip, got %v", claims["nonce"])
+	}
+	if claims["at_hash"] != computeAtHash(resp.AccessToken) {
+		t.Fatalf("at_hash does not match access token")
+	}
+	if claims["email"] != user.Email || claims["name"] != "Alice" {
+		t.Fatalf("expected profile/email claims, got %v", claims)
+	}
+	if _, ok := claims["auth_time"]; !ok {
+		t.Fatalf("expected auth_time claim")
+	}
+
+	refreshed, err := svc.RefreshAccessToken(resp.RefreshToken, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "https://id.example.com/api/v1")
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	refreshedClaims := parseIDTokenForTest(t, svc, refreshed.IDToken)
+	if _, ok := refreshedClaims["nonce"]; ok {
+		t.Fatalf("refreshed id_token must not carry nonce")
+	}
+	if refreshedClaims["auth_time"] != claims["auth_time"] {
+		t.Fatalf("expected auth_time to be preserved across refresh")
+	}
+}
+
+func TestExchangeCodeForTokenWithoutOpenIDScope(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-plain", "profile,email")
+	svc := NewOAuthServerService()
+
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "email",
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if resp.IDToken != "" {
+		t.Fatalf("expected no id_token without openid scope")
+	}
+}
@@ -0,0 +1,220 @@
+package oauth
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/oidclogout"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newLogoutService 创建登出通知服务，测试中可替换以拦截后端通知
+var newLogoutService = func() *oidclogout.Service {
+	return oidclogout.NewService(common.DB())
+}
+
+// sessionCookieNames 控制台登录写入的全部会话Cookie
+var sessionCookieNames = []string{
+	"access_token",
+	"refresh_token",
+	"access_token_user",
+	"refresh_token_user",
+	"access_token_tenant",
+	"refresh_token_tenant",
+	"access_token_admin",
+	"refresh_token_admin",
+}
+
+// LogoutUser 通知用户授权过的全部应用该用户已登出：
+// 异步投递后端登出令牌，结束用户的 SAML 会话，并返回需由浏览器加载的前端登出地址（含 SAML LogoutRequest）。
+func LogoutUser(c *fiber.Ctx, userID uint) []string {
+	if userID == 0 {
+		return nil
+	}
+	issuer := oidcIssuer(c)
+	svc := newLogoutService()
+
+	urls, err := svc.FrontchannelURLs(issuer, userID)
+	if err != nil {
+		log.Printf("[OIDCLogout] load front-channel logout uris for user %d failed: %v", userID, err)
+	}
+	samlURLs, err := newSAMLService().FrontchannelLogoutURLs(requestBaseURL(c), userID, 0)
+	if err != nil {
+		log.Printf("[SAML] end sessions for user %d failed: %v", userID, err)
+	}
+	urls = append(urls, samlURLs...)
+
+	go svc.NotifyBackchannel(issuer, userID)
+	return urls
+}
+
+// RevokeUserTokens 撤销用户持有的全部OAuth访问令牌与刷新令牌（如账号被封禁）
+func RevokeUserTokens(userID uint) error {
+	return oauthServerService.revokeUserTokens(userID)
+}
+
+func (s *OAuthServerService) revokeUserTokens(userID uint) error {
+	if err := s.db.Where("user_id = ?", userID).Delete(&model.OAuthRefreshToken{}).Error; err != nil {
+		return err
+	}
+	return s.db.Where("user_id = ?", userID).Delete(&model.OAuthAccessToken{}).Error
+}
+
+// parseIDTokenHint 校验 id_token_hint 的签名并返回其中的 aud 与 sub。
+// 按 RP-Initiated Logout 规范，已过期的 ID Token 仍可作为提示使用。
+func (s *OAuthServerService) parseIDTokenHint(raw string) (clientID string, userID uint, err error) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := s.keys.LookupKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.SigningMethod().Alg() != token.Method.Alg() {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+		// next / retired 密钥不加载私钥，只能使用公钥验证
+		return key.VerifyKey(), nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return "", 0, fmt.Errorf("invalid id_token_hint")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", 0, fmt.Errorf("invalid id_token_hint")
+	}
+	aud, err := claims.GetAudience()
+	if err != nil || len(aud) != 1 {
+		return "", 0, fmt.Errorf("invalid id_token_hint")
+	}
+	// pairwise 客户端的 sub 需按其扇区还原为用户ID
+	var client model.OAuthClient
+	if err := s.db.Where("client_id = ?", aud[0]).First(&client).Error; err != nil {
+		return "", 0, fmt.Errorf("invalid id_token_hint")
+	}
+	sub, _ := claims["sub"].(string)
+	uid, err := s.subjects.ResolveUserID(&client, sub)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid id_token_hint")
+	}
+	return aud[0], uid, nil
+}
+
+// clearSessionCookies 清除控制台会话Cookie
+func clearSessionCookies(c *fiber.Ctx) {
+	isProd := config.IsProduction()
+	for _, name := range sessionCookieNames {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Value:    "",
+			HTTPOnly: true,
+			Secure:   isProd,
+			SameSite: "Lax",
+			Path:     "/",
+			MaxAge:   -1,
+			Expires:  time.Unix(0, 0),
+		})
+	}
+}
+
+// EndSessionHandler 结束会话端点（OIDC RP-Initiated Logout）
+// GET/POST /end_session
+func EndSessionHandler(c *fiber.Ctx) error {
+	idTokenHint := strings.TrimSpace(c.FormValue("id_token_hint"))
+	postLogoutRedirectURI := strings.TrimSpace(c.FormValue("post_logout_redirect_uri"))
+	state := c.FormValue("state")
+	clientID := strings.TrimSpace(c.FormValue("client_id"))
+
+	// 1. 校验 id_token_hint，并确定发起登出的客户端
+	var hintUserID uint
+	if idTokenHint != "" {
+		hintClientID, uid, err := oauthServerService.parseIDTokenHint(idTokenHint)
+		if err != nil {
+			return endSessionError(c, "id_token_hint is invalid")
+		}
+		if clientID != "" && clientID != hintClientID {
+			return endSessionError(c, "client_id does not match id_token_hint")
+		}
+		clientID = hintClientID
+		hintUserID = uid
+	}
+
+	// 2. post_logout_redirect_uri 必须与客户端登记的地址完全一致
+	if postLogoutRedirectURI != "" {
+		if clientID == "" {
+			return endSessionError(c, "post_logout_redirect_uri requires id_token_hint or client_id")
+		}
+		var client model.OAuthClient
+		if err := common.DB().Where("client_id = ? AND is_active = ?", clientID, true).First(&client).Error; err != nil {
+			return endSessionError(c, "Unknown client")
+		}
+		if !client.ValidatePostLogoutRedirectURI(postLogoutRedirectURI) {
+			return endSessionError(c, "post_logout_redirect_uri is not registered for this client")
+		}
+	}
+
+	// 3. 结束当前浏览器会话并通知已授权的应用；
+	//    id_token_hint 属于其他用户时不登出当前会话
+	var frontchannelURLs []string
+	if sessionUserID, ok := tryUserIDFromAccessTokenCookie(c); ok {
+		if hintUserID == 0 || hintUserID == sessionUserID {
+			frontchannelURLs = LogoutUser(c, sessionUserID)
+			clearSessionCookies(c)
+			aduit.LogAudit(sessionUserID, "OIDC登出", "oauth_client", clientID, c.IP(), c.Get("User-Agent"))
+		}
+	}
+
+	// 4. 构建最终跳转地址
+	redirectTo := ""
+	if postLogoutRedirectURI != "" {
+		redirectTo = postLogoutRedirectURI
+		if state != "" {
+			u, err := url.Parse(postLogoutRedirectURI)
+			if err == nil {
+				q := u.Query()
+				q.Set("state", state)
+				u.RawQuery = q.Encode()
+				redirectTo = u.String()
+			}
+		}
+	}
+
+	if len(frontchannelURLs) == 0 && redirectTo != "" {
+		return c.Redirect(redirectTo, fiber.StatusFound)
+	}
+
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.SendString(renderLogoutPage(frontchannelURLs, redirectTo))
+}
+
+func endSessionError(c *fiber.Ctx, description string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":             "invalid_request",
+		"error_description": description,
+	})
+}
+
+// renderLogoutPage 渲染登出页：以隐藏 iframe 加载各应用的前端登出地址，随后跳转
+func renderLogoutPage(frontchannelURLs []string, redirectTo string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n    <meta charset=\"utf-8\">\n    <title>BasaltPass Logout</title>\n")
+	if redirectTo != "" {
+		b.WriteString("    <meta http-equiv=\"refresh\" content=\"2;url=" + html.EscapeString(redirectTo) + "\">\n")
+	}
+	b.WriteString("</head>\n<body>\n    <p>You have been signed out.</p>\n")
+	for _, u := range frontchannelURLs {
+		b.WriteString("    <iframe src=\"" + html.EscapeString(u) + "\" style=\"display:none\" width=\"0\" height=\"0\"></iframe>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
@@ -0,0 +1,239 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/oidclogout"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type deliveredLogout struct {
+	uri   string
+	token string
+}
+
+func captureBackchannelLogout(t *testing.T) chan deliveredLogout {
+	t.Helper()
+	delivered := make(chan deliveredLogout, 8)
+	original := newLogoutService
+	newLogoutService = func() *oidclogout.Service {
+		return oidclogout.NewService(common.DB()).WithSender(func(uri, logoutToken string) error {
+			delivered <- deliveredLogout{uri: uri, token: logoutToken}
+			return nil
+		})
+	}
+	t.Cleanup(func() { newLogoutService = original })
+	return delivered
+}
+
+func setupLogoutTestClient(t *testing.T, clientID string) (*model.User, *model.OAuthClient) {
+	t.Helper()
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, clientID, "openid,profile")
+	client.SetPostLogoutRedirectURIList([]string{"https://rp.example.com/logged-out"})
+	client.BackchannelLogoutURI = "https://rp.example.com/backchannel"
+	client.FrontchannelLogoutURI = "https://rp.example.com/frontchannel"
+	if err := db.Save(client).Error; err != nil {
+		t.Fatalf("update client failed: %v", err)
+	}
+	if err := db.Create(&model.AppUser{AppID: client.AppID, UserID: user.ID, Status: model.AppUserStatusActive}).Error; err != nil {
+		t.Fatalf("create app user failed: %v", err)
+	}
+
+	// 处理器使用包级服务实例，切换到测试数据库
+	original := oauthServerService
+	oauthServerService = NewOAuthServerService()
+	t.Cleanup(func() { oauthServerService = original })
+	return user, client
+}
+
+func TestEndSessionValidatesPostLogoutRedirectURI(t *testing.T) {
+	user, client := setupLogoutTestClient(t, "client-logout-redirect")
+	captureBackchannelLogout(t)
+
+	idToken, err := oauthServerService.IssueIDToken(&idTokenParams{Issuer: "https://id.example.com/api/v1", ClientID: client.ClientID, UserID: user.ID})
+	if err != nil {
+		t.Fatalf("issue id token failed: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/end_session", EndSessionHandler)
+
+	query := url.Values{}
+	query.Set("id_token_hint", idToken)
+	query.Set("post_logout_redirect_uri", "https://evil.example.com/")
+	resp, err := app.Test(httptest.NewRequest("GET", "/end_session?"+query.Encode(), nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected unregistered post_logout_redirect_uri to be rejected, got %d", resp.StatusCode)
+	}
+
+	query.Set("post_logout_redirect_uri", "https://rp.example.com/logged-out")
+	query.Set("state", "xyz")
+	resp, err = app.Test(httptest.NewRequest("GET", "/end_session?"+query.Encode(), nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusFound || resp.Header.Get("Location") != "https://rp.example.com/logged-out?state=xyz" {
+		t.Fatalf("expected redirect to registered uri, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	query.Set("id_token_hint", idToken[:len(idToken)-4]+"AAAA")
+	resp, err = app.Test(httptest.NewRequest("GET", "/end_session?"+query.Encode(), nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected tampered id_token_hint to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestEndSessionAcceptsHintFromRotatedKeys(t *testing.T) {
+	user, client := setupLogoutTestClient(t, "client-logout-rotated")
+	captureBackchannelLogout(t)
+
+	idToken, err := oauthServerService.IssueIDToken(&idTokenParams{Issuer: "https://id.example.com/api/v1", ClientID: client.ClientID, UserID: user.ID})
+	if err != nil {
+		t.Fatalf("issue id token failed: %v", err)
+	}
+	// 轮换后签发该 ID Token 的密钥退役，内存中不再持有其私钥
+	if _, err := oauthServerService.keys.Rotate(); err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if _, userID, err := oauthServerService.parseIDTokenHint(idToken); err != nil || userID != user.ID {
+		t.Fatalf("expected hint signed by retired key to verify, got %d %v", userID, err)
+	}
+
+	// 使用已发布的 next 密钥 kid 伪造的提示需被拒绝而非导致崩溃
+	keys, err := oauthServerService.keys.PublishedKeys()
+	if err != nil {
+		t.Fatalf("load published keys failed: %v", err)
+	}
+	nextKID := ""
+	for _, key := range keys {
+		if key.Status == model.SigningKeyStatusNext {
+			nextKID = key.KID
+		}
+	}
+	if nextKID == "" {
+		t.Fatalf("expected a published next key")
+	}
+	forgedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"aud": client.ClientID, "sub": "1"})
+	forged.Header["kid"] = nextKID
+	forgedHint, err := forged.SignedString(forgedKey)
+	if err != nil {
+		t.Fatalf("sign forged hint failed: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/end_session", EndSessionHandler)
+	query := url.Values{}
+	query.Set("id_token_hint", forgedHint)
+	resp, err := app.Test(httptest.NewRequest("GET", "/end_session?"+query.Encode(), nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected forged hint to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestEndSessionNotifiesAuthorizedApps(t *testing.T) {
+	user, client := setupLogoutTestClient(t, "client-logout-notify")
+	delivered := captureBackchannelLogout(t)
+	// 注销令牌的 iss 取自配置的对外地址，而不是请求的 Host 头
+	setPublicURLForTest(t, "https://id.example.com")
+
+	app := fiber.New()
+	app.Get("/end_session", EndSessionHandler)
+
+	req := httptest.NewRequest("GET", "/end_session", nil)
+	req.Host = "internal:8101"
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: createOAuthJWTForTest(t, jwt.MapClaims{
+		"sub": float64(user.ID),
+		"typ": "access",
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	})})
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected logout page, got %d", resp.StatusCode)
+	}
+	cleared := false
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "access_token" && cookie.Value == "" {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Fatalf("expected session cookie to be cleared")
+	}
+
+	var got deliveredLogout
+	select {
+	case got = <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected back-channel logout to be delivered")
+	}
+	if got.uri != "https://rp.example.com/backchannel" {
+		t.Fatalf("unexpected back-channel uri %q", got.uri)
+	}
+
+	token, err := jwt.Parse(got.token, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := oauthServerService.keys.LookupKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PrivateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil {
+		t.Fatalf("logout token is not verifiable: %v", err)
+	}
+	if token.Header["typ"] != oidclogout.LogoutTokenType {
+		t.Fatalf("unexpected logout token typ %v", token.Header["typ"])
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["iss"] != "https://id.example.com/api/v1" || claims["aud"] != client.ClientID || claims["sub"] != strconv.FormatUint(uint64(user.ID), 10) {
+		t.Fatalf("unexpected logout token claims %v", claims)
+	}
+	if _, ok := claims["nonce"]; ok || claims["jti"] == nil {
+		t.Fatalf("logout token must carry jti and no nonce: %v", claims)
+	}
+	events, _ := claims["events"].(map[string]interface{})
+	if _, ok := events[oidclogout.BackchannelLogoutEvent]; !ok {
+		t.Fatalf("missing back-channel logout event: %v", claims)
+	}
+}
+
+func TestFrontchannelLogoutURLsIncludeIssuer(t *testing.T) {
+	user, _ := setupLogoutTestClient(t, "client-logout-front")
+
+	urls, err := oidclogout.NewService(common.DB()).FrontchannelURLs("https://id.example.com/api/v1", user.ID)
+	if err != nil {
+		t.Fatalf("load front-channel uris failed: %v", err)
+	}
+	if len(urls) != 1 || !strings.HasPrefix(urls[0], "https://rp.example.com/frontchannel?iss=") {
+		t.Fatalf("unexpected front-channel uris %v", urls)
+	}
+}
@@ -27,6 +27,10 @@ type OneTapAuthRequest struct {
 	State        string `json:"state,omitempty"`
 	RedirectURI  string `json:"redirect_uri,omitempty"`
 	ResponseType string `json:"response_type,omitempty"` // must be "code" when provided
+	Nonce        string `json:"nonce,omitempty"`
+
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
 }
 
 // OneTapAuthResponse One-Tap认证响应
@@ -85,11 +89,14 @@ func OneTapLoginHandler(c *fiber.Ctx) error {
 
 	scope := normalizeRequestedScope(req.Scope, client)
 	authReq := &AuthorizeRequest{
-		ClientID:     req.ClientID,
-		RedirectURI:  req.RedirectURI,
-		ResponseType: "code",
-		Scope:        scope,
-		State:        req.State,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		ResponseType:        "code",
+		Scope:               scope,
+		State:               req.State,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
 	}
 	validatedClient, err := oauthServerService.ValidateAuthorizeRequest(authReq)
 	if err != nil {
@@ -147,6 +154,7 @@ func SilentAuthHandler(c *fiber.Ctx) error {
 	redirectURI := c.Query("redirect_uri")
 	state := c.Query("state")
 	scope := c.Query("scope")
+	nonce := c.Query("nonce")
 
 	if prompt != "none" {
 		return renderSilentAuthError(c, fiber.StatusBadRequest, clientID, redirectURI, state, "invalid_request")
@@ -168,11 +176,14 @@ func SilentAuthHandler(c *fiber.Ctx) error {
 		return renderSilentAuthError(c, fiber.StatusForbidden, clientID, redirectURI, state, "access_denied")
 	}
 	authReq := &AuthorizeRequest{
-		ClientID:     clientID,
-		RedirectURI:  redirectURI,
-		ResponseType: "code",
-		Scope:        normalizeRequestedScope(scope, client),
-		State:        state,
+		ClientID:            clientID,
+		RedirectURI:         redirectURI,
+		ResponseType:        "code",
+		Scope:               normalizeRequestedScope(scope, client),
+		State:               state,
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+		Nonce:               nonce,
 	}
 	validatedClient, err := oauthServerService.ValidateAuthorizeRequest(authReq)
 	if err != nil {
@@ -0,0 +1,249 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/clientassertion"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// pushedRequestTTL request_uri 有效期，需覆盖授权端点跳转登录再返回的时间
+const pushedRequestTTL = 5 * time.Minute
+
+// PushAuthorizationRequest 校验客户端推送的授权请求并返回 request_uri（RFC 9126 §2）。
+// requestObject 非空时以签名请求对象中的参数为准（RFC 9126 §3）。
+func (s *OAuthServerService) PushAuthorizationRequest(creds ClientCredentials, req *AuthorizeRequest, requestObject, issuer string) (string, error) {
+	// 1. 认证客户端；公共客户端只需提交 client_id
+	client, err := s.authenticateClient(creds)
+	if err != nil {
+		return "", err
+	}
+	if req.RequestURI != "" {
+		return "", errors.New("invalid_request")
+	}
+
+	// 2. 解析签名请求对象
+	if requestObject != "" {
+		req, err = s.parseRequestObject(client, requestObject, issuer)
+		if err != nil {
+			return "", err
+		}
+	}
+	if req.ClientID != "" && req.ClientID != client.ClientID {
+		return "", errors.New("invalid_request")
+	}
+	req.ClientID = client.ClientID
+	req.pushed = true
+
+	// 3. 推送时即完成与授权端点相同的校验，错误直接返回给客户端
+	if _, err := s.ValidateAuthorizeRequest(req); err != nil {
+		return "", err
+	}
+
+	// 4. 保存参数，request_uri 只存摘要
+	params, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	requestURI, err := model.GeneratePushedRequestURI()
+	if err != nil {
+		return "", err
+	}
+	s.db.Where("expires_at < ?", time.Now()).Delete(&model.OAuthPushedAuthorizationRequest{})
+	if err := s.db.Create(&model.OAuthPushedAuthorizationRequest{
+		RequestURI: utils.HashOAuthToken(requestURI),
+		ClientID:   client.ClientID,
+		Parameters: string(params),
+		ExpiresAt:  time.Now().Add(pushedRequestTTL),
+	}).Error; err != nil {
+		return "", err
+	}
+	return requestURI, nil
+}
+
+// ResolveAuthorizeRequest 将授权端点收到的 request_uri 或 request 参数展开为实际的授权请求；
+// 两者均未携带时原样返回。
+func (s *OAuthServerService) ResolveAuthorizeRequest(req *AuthorizeRequest, requestObject, issuer string) (*AuthorizeRequest, error) {
+	if req.RequestURI != "" && requestObject != "" {
+		return nil, errors.New("invalid_request")
+	}
+
+	// 1. request_uri：取回推送的请求，client_id 必须一致（RFC 9126 §4）
+	if req.RequestURI != "" {
+		if !strings.HasPrefix(req.RequestURI, model.PushedRequestURIPrefix) {
+			return nil, errors.New("invalid_request_uri")
+		}
+		var pushed model.OAuthPushedAuthorizationRequest
+		if err := s.db.Where("request_uri = ?", utils.HashOAuthToken(req.RequestURI)).First(&pushed).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("invalid_request_uri")
+			}
+			return nil, err
+		}
+		if pushed.IsExpired() || pushed.ClientID != req.ClientID {
+			return nil, errors.New("invalid_request_uri")
+		}
+
+		resolved := &AuthorizeRequest{}
+		if err := json.Unmarshal([]byte(pushed.Parameters), resolved); err != nil {
+			return nil, err
+		}
+		resolved.ClientID = pushed.ClientID
+		resolved.RequestURI = req.RequestURI
+		resolved.pushed = true
+		return resolved, nil
+	}
+
+	// 2. request：按值传递的签名请求对象（RFC 9101 §5.1），client_id 参数必须存在
+	if requestObject != "" {
+		if req.ClientID == "" {
+			return nil, errors.New("invalid_request")
+		}
+		var client model.OAuthClient
+		if err := s.db.Where("client_id = ? AND is_active = ?", req.ClientID, true).First(&client).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("invalid_client")
+			}
+			return nil, err
+		}
+		return s.parseRequestObject(&client, requestObject, issuer)
+	}
+
+	return req, nil
+}
+
+// consumePushedRequest 签发授权码后作废 request_uri，避免重复使用
+func (s *OAuthServerService) consumePushedRequest(requestURI string) {
+	if requestURI == "" {
+		return
+	}
+	s.db.Where("request_uri = ?", utils.HashOAuthToken(requestURI)).Delete(&model.OAuthPushedAuthorizationRequest{})
+}
+
+// parseRequestObject 验证签名请求对象，授权参数只取自其中的声明（RFC 9101 §6.3）
+func (s *OAuthServerService) parseRequestObject(client *model.OAuthClient, requestObject, issuer string) (*AuthorizeRequest, error) {
+	claims, err := clientassertion.NewService(s.db).VerifyRequestObject(client, requestObject, []string{issuer})
+	if err != nil {
+		if errors.Is(err, clientassertion.ErrInvalidRequestObject) {
+			log.Printf("[OAuth] request object rejected: client=%s err=%v", client.ClientID, err)
+			return nil, errors.New("invalid_request_object")
+		}
+		return nil, err
+	}
+
+	claim := func(name string) string {
+		value, _ := claims[name].(string)
+		return value
+	}
+	req := &AuthorizeRequest{
+		ClientID:            client.ClientID,
+		RedirectURI:         claim("redirect_uri"),
+		ResponseType:        claim("response_type"),
+		Scope:               claim("scope"),
+		State:               claim("state"),
+		CodeChallenge:       claim("code_challenge"),
+		CodeChallengeMethod: claim("code_challenge_method"),
+		Nonce:               claim("nonce"),
+		ResponseMode:        claim("response_mode"),
+		Prompt:              claim("prompt"),
+		LoginHint:           claim("login_hint"),
+		ACRValues:           claim("acr_values"),
+	}
+	if req.Claims, err = requestObjectClaimsParam(claims["claims"]); err != nil {
+		return nil, errors.New("invalid_request_object")
+	}
+	// max_age 在请求对象中为数字，兼容字符串形式
+	switch v := claims["max_age"].(type) {
+	case float64:
+		maxAge := int(v)
+		req.MaxAge = &maxAge
+	case string:
+		maxAge, err := parseMaxAge(v)
+		if err != nil {
+			return nil, errors.New("invalid_request_object")
+		}
+		req.MaxAge = maxAge
+	}
+	return req, nil
+}
+
+// PushedAuthorizationRequestHandler 推送授权请求端点（RFC 9126）
+// POST /oauth/par
+func PushedAuthorizationRequestHandler(c *fiber.Ctx) error {
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" {
+		return oauthInvalidClient(c)
+	}
+
+	req := &AuthorizeRequest{
+		ClientID:            c.FormValue("client_id"),
+		RedirectURI:         c.FormValue("redirect_uri"),
+		ResponseType:        c.FormValue("response_type"),
+		Scope:               c.FormValue("scope"),
+		State:               c.FormValue("state"),
+		CodeChallenge:       c.FormValue("code_challenge"),
+		CodeChallengeMethod: c.FormValue("code_challenge_method"),
+		Nonce:               c.FormValue("nonce"),
+		ResponseMode:        c.FormValue("response_mode"),
+		RequestURI:          c.FormValue("request_uri"),
+		Prompt:              c.FormValue("prompt"),
+		LoginHint:           c.FormValue("login_hint"),
+		ACRValues:           c.FormValue("acr_values"),
+		Claims:              c.FormValue("claims"),
+	}
+	maxAge, err := parseMaxAge(c.FormValue("max_age"))
+	if err != nil {
+		return authorizeRequestError(c, err)
+	}
+	req.MaxAge = maxAge
+
+	requestURI, err := oauthServerService.PushAuthorizationRequest(creds, req, c.FormValue("request"), oidcIssuer(c))
+	if err != nil {
+		if err.Error() == "invalid_client" {
+			return oauthInvalidClient(c)
+		}
+		return authorizeRequestError(c, err)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"request_uri": requestURI,
+		"expires_in":  int(pushedRequestTTL.Seconds()),
+	})
+}
+
+// authorizeRequestError 以 JSON 返回无法安全重定向的授权请求错误
+func authorizeRequestError(c *fiber.Ctx, err error) error {
+	descriptions := map[string]string{
+		"invalid_request":           "The authorization request is malformed",
+		"invalid_request_uri":       "request_uri is invalid, expired or was issued to another client",
+		"invalid_request_object":    "The request object is invalid",
+		"invalid_client":            "Unknown client",
+		"invalid_redirect_uri":      "redirect_uri is not registered for this client",
+		"invalid_scope":             "Requested scope exceeds the client's allowed scopes",
+		"unsupported_response_type": "Only response_type=code is supported",
+	}
+	description, ok := descriptions[err.Error()]
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":             "server_error",
+			"error_description": "Failed to process authorization request",
+		})
+	}
+	code := err.Error()
+	if code == "invalid_redirect_uri" {
+		code = "invalid_request"
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":             code,
+		"error_description": description,
+	})
+}
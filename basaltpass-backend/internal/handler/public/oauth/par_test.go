@@ -0,0 +1,171 @@
+package oauth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPushedAuthorizationRequestFlow(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	if err := db.AutoMigrate(&model.OAuthPushedAuthorizationRequest{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	user, client := setupIDTokenTestData(t, db, "client-par", "openid,profile")
+	original := oauthServerService
+	oauthServerService = NewOAuthServerService()
+	t.Cleanup(func() { oauthServerService = original })
+
+	app := fiber.New()
+	app.Post("/api/v1/oauth/par", PushedAuthorizationRequestHandler)
+
+	form := url.Values{}
+	form.Set("client_id", client.ClientID)
+	form.Set("client_secret", "secret")
+	form.Set("response_type", "code")
+	form.Set("redirect_uri", "https://rp.example.com/callback")
+	form.Set("scope", "openid")
+	form.Set("state", "pushed-state")
+	form.Set("code_challenge", s256ChallengeForTest("par-verifier-0123456789-abcdefghijklmnopqrstu"))
+	form.Set("code_challenge_method", "S256")
+
+	// 推送的请求在推送时即校验，未登记的 redirect_uri 直接返回错误
+	bad := url.Values{}
+	for k, v := range form {
+		bad[k] = v
+	}
+	bad.Set("redirect_uri", "https://evil.example.com/callback")
+	if status, body := postFormForTest(t, app, "/api/v1/oauth/par", bad); status != fiber.StatusBadRequest || body["error"] != "invalid_request" {
+		t.Fatalf("expected invalid_request for unregistered redirect_uri, got %d %v", status, body)
+	}
+
+	status, body := postFormForTest(t, app, "/api/v1/oauth/par", form)
+	if status != fiber.StatusCreated {
+		t.Fatalf("expected 201 from PAR endpoint, got %d %v", status, body)
+	}
+	requestURI, _ := body["request_uri"].(string)
+	if !strings.HasPrefix(requestURI, model.PushedRequestURIPrefix) || body["expires_in"] == nil {
+		t.Fatalf("unexpected PAR response %v", body)
+	}
+
+	// request_uri 只能由推送它的客户端使用
+	if _, err := oauthServerService.ResolveAuthorizeRequest(&AuthorizeRequest{ClientID: "someone-else", RequestURI: requestURI}, "", "http://example.com/api/v1"); err == nil || err.Error() != "invalid_request_uri" {
+		t.Fatalf("expected invalid_request_uri for another client, got %v", err)
+	}
+
+	// 授权端点只携带 client_id 与 request_uri，参数取自推送的请求
+	req, err := oauthServerService.ResolveAuthorizeRequest(&AuthorizeRequest{ClientID: client.ClientID, RequestURI: requestURI, Scope: "openid profile"}, "", "http://example.com/api/v1")
+	if err != nil {
+		t.Fatalf("resolve request_uri failed: %v", err)
+	}
+	if req.State != "pushed-state" || req.Scope != "openid" || req.RedirectURI != "https://rp.example.com/callback" {
+		t.Fatalf("unexpected resolved request %+v", req)
+	}
+	resolvedClient, err := oauthServerService.ValidateAuthorizeRequest(req)
+	if err != nil {
+		t.Fatalf("validate resolved request failed: %v", err)
+	}
+
+	// 签发授权码后 request_uri 失效
+	if _, err := oauthServerService.GenerateAuthorizationCode(user.ID, req, resolvedClient); err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	if _, err := oauthServerService.ResolveAuthorizeRequest(&AuthorizeRequest{ClientID: client.ClientID, RequestURI: requestURI}, "", "http://example.com/api/v1"); err == nil || err.Error() != "invalid_request_uri" {
+		t.Fatalf("expected consumed request_uri to be rejected, got %v", err)
+	}
+}
+
+func TestRequirePushedAuthorizationRequests(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	if err := db.AutoMigrate(&model.OAuthPushedAuthorizationRequest{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	_, client := setupIDTokenTestData(t, db, "client-par-required", "profile")
+	db.Model(client).Update("require_pushed_authorization_requests", true)
+	svc := NewOAuthServerService()
+
+	authReq := func() *AuthorizeRequest {
+		return &AuthorizeRequest{
+			ClientID:            client.ClientID,
+			RedirectURI:         "https://rp.example.com/callback",
+			ResponseType:        "code",
+			CodeChallenge:       s256ChallengeForTest("par-required-verifier-0123456789-abcdefghijk"),
+			CodeChallengeMethod: "S256",
+		}
+	}
+
+	if _, err := svc.ValidateAuthorizeRequest(authReq()); err == nil || err.Error() != "invalid_request" {
+		t.Fatalf("expected direct authorization request to be rejected, got %v", err)
+	}
+
+	requestURI, err := svc.PushAuthorizationRequest(ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, authReq(), "", "http://example.com/api/v1")
+	if err != nil {
+		t.Fatalf("push authorization request failed: %v", err)
+	}
+	req, err := svc.ResolveAuthorizeRequest(&AuthorizeRequest{ClientID: client.ClientID, RequestURI: requestURI}, "", "http://example.com/api/v1")
+	if err != nil {
+		t.Fatalf("resolve request_uri failed: %v", err)
+	}
+	if _, err := svc.ValidateAuthorizeRequest(req); err != nil {
+		t.Fatalf("expected pushed request to be accepted: %v", err)
+	}
+}
+
+func TestSignedRequestObject(t *testing.T) {
+	client, key := setupPrivateKeyJWTClient(t, "client-jar")
+	const issuer = "http://example.com/api/v1"
+
+	requestObject := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = "rp-key"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign request object failed: %v", err)
+		}
+		return signed
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                   client.ClientID,
+			"aud":                   issuer,
+			"exp":                   time.Now().Add(5 * time.Minute).Unix(),
+			"client_id":             client.ClientID,
+			"response_type":         "code",
+			"redirect_uri":          "https://rp.example.com/callback",
+			"scope":                 "s2s.user.read",
+			"state":                 "signed-state",
+			"code_challenge":        s256ChallengeForTest("jar-verifier-0123456789-abcdefghijklmnopqrstu"),
+			"code_challenge_method": "S256",
+		}
+	}
+
+	// 请求对象中的参数优先于查询参数
+	req, err := oauthServerService.ResolveAuthorizeRequest(&AuthorizeRequest{ClientID: client.ClientID, State: "query-state"}, requestObject(claims()), issuer)
+	if err != nil {
+		t.Fatalf("resolve request object failed: %v", err)
+	}
+	if req.State != "signed-state" || req.RedirectURI != "https://rp.example.com/callback" {
+		t.Fatalf("unexpected resolved request %+v", req)
+	}
+	if _, err := oauthServerService.ValidateAuthorizeRequest(req); err != nil {
+		t.Fatalf("validate signed request failed: %v", err)
+	}
+
+	wrongAudience := claims()
+	wrongAudience["aud"] = "https://other.example.com"
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	for name, raw := range map[string]string{
+		"wrong audience": requestObject(wrongAudience),
+		"unsigned":       unsigned,
+	} {
+		if _, err := oauthServerService.ResolveAuthorizeRequest(&AuthorizeRequest{ClientID: client.ClientID}, raw, issuer); err == nil || err.Error() != "invalid_request_object" {
+			t.Fatalf("%s: expected invalid_request_object, got %v", name, err)
+		}
+	}
+}
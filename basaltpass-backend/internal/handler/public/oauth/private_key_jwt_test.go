@@ -0,0 +1,158 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/clientassertion"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func setupPrivateKeyJWTClient(t *testing.T, clientID string) (*model.OAuthClient, *ecdsa.PrivateKey) {
+	t.Helper()
+	db := setupOAuthTenantTestDB(t)
+	if err := db.AutoMigrate(&model.OAuthClientAssertionJTI{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	_, client := setupIDTokenTestData(t, db, clientID, "s2s.user.read")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"crv": "P-256",
+			"kid": "rp-key",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}},
+	})
+	if err := db.Model(client).Updates(map[string]interface{}{
+		"token_endpoint_auth_method": model.TokenEndpointAuthPrivateKeyJWT,
+		"client_secret":              "",
+		"jwks":                       string(jwks),
+		"grant_types":                "client_credentials",
+	}).Error; err != nil {
+		t.Fatalf("update client failed: %v", err)
+	}
+
+	original := oauthServerService
+	oauthServerService = NewOAuthServerService()
+	t.Cleanup(func() { oauthServerService = original })
+	return client, key
+}
+
+func clientAssertionForTest(t *testing.T, key *ecdsa.PrivateKey, clientID, audience, jti string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": audience,
+		"jti": jti,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "rp-key"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign assertion failed: %v", err)
+	}
+	return signed
+}
+
+func postFormForTest(t *testing.T, app *fiber.App, path string, form url.Values) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	payload := map[string]interface{}{}
+	_ = json.NewDecoder(resp.Body).Decode(&payload)
+	return resp.StatusCode, payload
+}
+
+// setPublicURLForTest 设置配置的对外地址，测试结束后恢复
+func setPublicURLForTest(t *testing.T, publicURL string) {
+	t.Helper()
+	previous := config.Get().Server.PublicURL
+	config.Get().Server.PublicURL = publicURL
+	t.Cleanup(func() { config.Get().Server.PublicURL = previous })
+}
+
+func TestPrivateKeyJWTClientAuthentication(t *testing.T) {
+	client, key := setupPrivateKeyJWTClient(t, "client-pkjwt")
+	setPublicURLForTest(t, "https://auth.example.com")
+
+	app := fiber.New()
+	app.Post("/api/v1/oauth/token", TokenHandler)
+	app.Post("/api/v1/oauth/introspect", OAuthClientAuthMiddleware(), IntrospectHandler)
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_assertion_type", clientassertion.AssertionType)
+	form.Set("client_assertion", clientAssertionForTest(t, key, client.ClientID, "https://auth.example.com/api/v1/oauth/token", "jti-token"))
+	status, body := postFormForTest(t, app, "/api/v1/oauth/token", form)
+	if status != fiber.StatusOK || body["access_token"] == nil {
+		t.Fatalf("expected token via private_key_jwt, got %d %v", status, body)
+	}
+	accessToken := body["access_token"].(string)
+
+	// 同一断言不能重放
+	if status, _ := postFormForTest(t, app, "/api/v1/oauth/token", form); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected replayed assertion to be rejected, got %d", status)
+	}
+
+	// 断言的 aud 可以是 issuer；内省端点同样接受断言
+	introspect := url.Values{}
+	introspect.Set("token", accessToken)
+	introspect.Set("client_assertion_type", clientassertion.AssertionType)
+	introspect.Set("client_assertion", clientAssertionForTest(t, key, client.ClientID, "https://auth.example.com/api/v1", "jti-introspect"))
+	status, body = postFormForTest(t, app, "/api/v1/oauth/introspect", introspect)
+	if status != fiber.StatusOK || body["active"] != true {
+		t.Fatalf("expected active introspection, got %d %v", status, body)
+	}
+
+	// 指向其他授权服务器的断言被拒绝
+	introspect.Set("client_assertion", clientAssertionForTest(t, key, client.ClientID, "https://other.example.com/token", "jti-other"))
+	if status, _ := postFormForTest(t, app, "/api/v1/oauth/introspect", introspect); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected foreign audience to be rejected, got %d", status)
+	}
+
+	// aud 不从请求的 Host 头推导
+	introspect.Set("client_assertion", clientAssertionForTest(t, key, client.ClientID, "http://example.com/api/v1", "jti-host"))
+	if status, _ := postFormForTest(t, app, "/api/v1/oauth/introspect", introspect); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected audience derived from the Host header to be rejected, got %d", status)
+	}
+
+	// 未配置对外地址时 aud 与 issuer 一样按请求的 Host 构建
+	setPublicURLForTest(t, "")
+	introspect.Set("client_assertion", clientAssertionForTest(t, key, client.ClientID, "http://example.com/api/v1", "jti-host-fallback"))
+	if status, body := postFormForTest(t, app, "/api/v1/oauth/introspect", introspect); status != fiber.StatusOK || body["active"] != true {
+		t.Fatalf("expected Host-derived audience without public_url, got %d %v", status, body)
+	}
+	setPublicURLForTest(t, "https://auth.example.com")
+
+	// private_key_jwt 客户端不能退回共享密钥认证
+	secretForm := url.Values{}
+	secretForm.Set("grant_type", "client_credentials")
+	secretForm.Set("client_id", client.ClientID)
+	secretForm.Set("client_secret", "secret")
+	if status, _ := postFormForTest(t, app, "/api/v1/oauth/token", secretForm); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected client_secret to be rejected for a private_key_jwt client, got %d", status)
+	}
+}
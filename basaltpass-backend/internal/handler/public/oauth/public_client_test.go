@@ -0,0 +1,142 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+func s256ChallengeForTest(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func setupPublicClientForTest(t *testing.T, db *gorm.DB, client *model.OAuthClient) {
+	t.Helper()
+	client.TokenEndpointAuthMethod = model.TokenEndpointAuthNone
+	client.ClientSecret = ""
+	if err := db.Save(client).Error; err != nil {
+		t.Fatalf("update client failed: %v", err)
+	}
+}
+
+func TestPublicClientExchangesCodeWithPKCE(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-public-pkce", "openid,profile")
+	setupPublicClientForTest(t, db, client)
+	svc := NewOAuthServerService()
+
+	verifier := "public-client-verifier-0123456789-abcdefghijklmnop"
+	authReq := &AuthorizeRequest{
+		ClientID:            client.ClientID,
+		RedirectURI:         "https://rp.example.com/callback",
+		ResponseType:        "code",
+		Scope:               "openid",
+		CodeChallenge:       s256ChallengeForTest(verifier),
+		CodeChallengeMethod: "S256",
+	}
+	if _, err := svc.ValidateAuthorizeRequest(authReq); err != nil {
+		t.Fatalf("expected S256 authorize request to be accepted: %v", err)
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+
+	tokenReq := &TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  authReq.RedirectURI,
+		CodeVerifier: verifier,
+	}
+	// 公共客户端携带密钥视为认证失败，且不消耗授权码
+	if _, err := svc.ExchangeCodeForToken(tokenReq, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}); err == nil || err.Error() != "invalid_client" {
+		t.Fatalf("expected invalid_client when a public client sends a secret, got %v", err)
+	}
+
+	resp, err := svc.ExchangeCodeForToken(tokenReq, ClientCredentials{ClientID: client.ClientID})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if resp.AccessToken == "" || resp.IDToken == "" {
+		t.Fatalf("expected access token and id token, got %+v", resp)
+	}
+}
+
+func TestPublicClientRequiresS256(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-public-no-pkce", "profile")
+	setupPublicClientForTest(t, db, client)
+	svc := NewOAuthServerService()
+	setSettingForTest(t, "oauth.pkce_required", false, true)
+
+	for _, req := range []*AuthorizeRequest{
+		{},
+		{CodeChallenge: "plain-challenge", CodeChallengeMethod: "plain"},
+	} {
+		req.ClientID = client.ClientID
+		req.RedirectURI = "https://rp.example.com/callback"
+		req.ResponseType = "code"
+		if _, err := svc.ValidateAuthorizeRequest(req); err == nil || err.Error() != "invalid_request" {
+			t.Fatalf("expected invalid_request for %+v, got %v", req, err)
+		}
+	}
+
+	// 绕过授权端点生成的无 PKCE 授权码在令牌端点同样被拒绝
+	code, err := svc.GenerateAuthorizationCode(user.ID, &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+	}, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	if _, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: "https://rp.example.com/callback",
+	}, ClientCredentials{ClientID: client.ClientID}); err == nil {
+		t.Fatalf("expected code without PKCE to be rejected for a public client")
+	}
+}
+
+func TestConfidentialClientPKCEPolicy(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	if err := db.AutoMigrate(&model.TenantAuthSetting{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	_, client := setupIDTokenTestData(t, db, "client-confidential-pkce", "profile")
+	svc := NewOAuthServerService()
+
+	authReq := func() *AuthorizeRequest {
+		return &AuthorizeRequest{
+			ClientID:     client.ClientID,
+			RedirectURI:  "https://rp.example.com/callback",
+			ResponseType: "code",
+		}
+	}
+
+	setSettingForTest(t, "oauth.pkce_required", true, true)
+	if _, err := svc.ValidateAuthorizeRequest(authReq()); err == nil || err.Error() != "invalid_request" {
+		t.Fatalf("expected PKCE to be required globally, got %v", err)
+	}
+
+	setSettingForTest(t, "oauth.pkce_required", false, true)
+	if _, err := svc.ValidateAuthorizeRequest(authReq()); err != nil {
+		t.Fatalf("expected confidential client without PKCE to be accepted: %v", err)
+	}
+
+	// 租户设置可以在全局关闭时单独要求 PKCE
+	var app model.App
+	db.First(&app, client.AppID)
+	if err := db.Create(&model.TenantAuthSetting{TenantID: app.TenantID, PKCERequired: true}).Error; err != nil {
+		t.Fatalf("create tenant auth setting failed: %v", err)
+	}
+	if _, err := svc.ValidateAuthorizeRequest(authReq()); err == nil || err.Error() != "invalid_request" {
+		t.Fatalf("expected tenant setting to require PKCE, got %v", err)
+	}
+}
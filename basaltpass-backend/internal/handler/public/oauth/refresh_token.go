@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/settings"
+	"basaltpass-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// RefreshTokenReuseError 已轮换的刷新令牌被再次使用。
+// 对客户端仍表现为 invalid_grant，处理器据此记录安全审计。
+type RefreshTokenReuseError struct {
+	UserID   uint
+	ClientID string
+	FamilyID string
+}
+
+func (e *RefreshTokenReuseError) Error() string {
+	return "invalid_grant"
+}
+
+// refreshTokensEnabled 是否签发刷新令牌（oauth.enable_refresh_tokens）
+func refreshTokensEnabled() bool {
+	return settings.GetBool("oauth.enable_refresh_tokens", true)
+}
+
+// refreshTokenTTL 刷新令牌有效期（jwt.refresh_exp_minutes）
+func refreshTokenTTL() time.Duration {
+	minutes := settings.GetInt("jwt.refresh_exp_minutes", 60*24*7)
+	if minutes <= 0 {
+		minutes = 60 * 24 * 7
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// createRefreshToken 保存刷新令牌并返回明文；parent 为空时开启新的令牌族
+func (s *OAuthServerService) createRefreshToken(record *model.OAuthRefreshToken, parent *model.OAuthRefreshToken) (string, error) {
+	token, err := model.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	record.Token = utils.HashOAuthToken(token)
+	record.TokenPrefix = utils.OAuthTokenDisplayPrefix(token)
+	record.ExpiresAt = time.Now().Add(refreshTokenTTL())
+
+	if parent != nil && parent.FamilyID != "" {
+		record.FamilyID = parent.FamilyID
+	} else {
+		familyID, err := model.GenerateTokenFamilyID()
+		if err != nil {
+			return "", err
+		}
+		record.FamilyID = familyID
+	}
+	if parent != nil {
+		record.ParentID = &parent.ID
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// markRefreshTokenRotated 原子地将刷新令牌标记为已轮换；
+// 返回 false 表示该令牌已被并发请求轮换过。
+func (s *OAuthServerService) markRefreshTokenRotated(record *model.OAuthRefreshToken) (bool, error) {
+	now := time.Now()
+	result := s.db.Model(&model.OAuthRefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL", record.ID).
+		Update("rotated_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// rejectReusedRefreshToken 撤销被重放令牌所在的令牌族，返回 RefreshTokenReuseError
+func (s *OAuthServerService) rejectReusedRefreshToken(record *model.OAuthRefreshToken) error {
+	if err := s.revokeRefreshTokenFamily(record); err != nil {
+		return err
+	}
+	return &RefreshTokenReuseError{UserID: record.UserID, ClientID: record.ClientID, FamilyID: record.FamilyID}
+}
+
+// revokeRefreshTokenFamily 撤销整个令牌族：删除族内全部刷新令牌及其关联的访问令牌
+func (s *OAuthServerService) revokeRefreshTokenFamily(record *model.OAuthRefreshToken) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		family := tx.Model(&model.OAuthRefreshToken{})
+		if record.FamilyID != "" {
+			family = family.Where("family_id = ?", record.FamilyID)
+		} else {
+			family = family.Where("id = ?", record.ID)
+		}
+
+		var members []model.OAuthRefreshToken
+		if err := family.Select("id", "access_token_id").Find(&members).Error; err != nil {
+			return err
+		}
+
+		var refreshIDs, accessIDs []uint
+		for _, m := range members {
+			refreshIDs = append(refreshIDs, m.ID)
+			if m.AccessTokenID != nil {
+				accessIDs = append(accessIDs, *m.AccessTokenID)
+			}
+		}
+		if len(accessIDs) > 0 {
+			if err := tx.Delete(&model.OAuthAccessToken{}, accessIDs).Error; err != nil {
+				return err
+			}
+		}
+		if len(refreshIDs) > 0 {
+			if err := tx.Delete(&model.OAuthRefreshToken{}, refreshIDs).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
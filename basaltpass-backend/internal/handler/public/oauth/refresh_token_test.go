@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/settings"
+	"basaltpass-backend/internal/utils"
+)
+
+func setSettingForTest(t *testing.T, key string, value interface{}, restore interface{}) {
+	t.Helper()
+	if err := settings.Upsert(key, value, "", ""); err != nil {
+		t.Fatalf("set %s failed: %v", key, err)
+	}
+	t.Cleanup(func() { _ = settings.Upsert(key, restore, "", "") })
+}
+
+func issueRefreshTokenForTest(t *testing.T, svc *OAuthServerService, user *model.User, client *model.OAuthClient) *TokenResponse {
+	t.Helper()
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "profile",
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	return resp
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-rt-rotate", "profile")
+	svc := NewOAuthServerService()
+	setSettingForTest(t, "jwt.refresh_exp_minutes", 30, 60*24*7)
+
+	first := issueRefreshTokenForTest(t, svc, user, client)
+	if first.RefreshToken == "" {
+		t.Fatalf("expected refresh token")
+	}
+
+	second, err := svc.RefreshAccessToken(first.RefreshToken, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "")
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected a rotated refresh token")
+	}
+
+	var parent, child model.OAuthRefreshToken
+	db.Where("token = ?", utils.HashOAuthToken(first.RefreshToken)).First(&parent)
+	db.Where("token = ?", utils.HashOAuthToken(second.RefreshToken)).First(&child)
+	if !parent.IsRotated() || child.IsRotated() {
+		t.Fatalf("expected parent rotated and child active")
+	}
+	if child.FamilyID == "" || child.FamilyID != parent.FamilyID || child.ParentID == nil || *child.ParentID != parent.ID {
+		t.Fatalf("expected child linked into parent's family, got %+v", child)
+	}
+	if ttl := time.Until(child.ExpiresAt); ttl > 31*time.Minute || ttl < 29*time.Minute {
+		t.Fatalf("expected lifetime from jwt.refresh_exp_minutes, got %v", ttl)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-rt-reuse", "profile")
+	svc := NewOAuthServerService()
+
+	first := issueRefreshTokenForTest(t, svc, user, client)
+	second, err := svc.RefreshAccessToken(first.RefreshToken, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "")
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	// 重放已轮换的令牌
+	_, err = svc.RefreshAccessToken(first.RefreshToken, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, "")
+	var reuse *RefreshTokenReuseError
+	if !errors.As(err, &reuse) || err.Error() != "invalid_grant" || reuse.UserID != user.ID {
+		t.Fatalf("expected refresh token reuse error, got %v", err)
+	}
+
+	if _, err := svc.RefreshAccessToken(second.RefreshToken, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, ""); err == nil || err.Error() != "invalid_grant" {
+		t.Fatalf("expected whole family to be revoked, got %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(second.AccessToken); err == nil {
+		t.Fatalf("access token issued within the family must be revoked")
+	}
+	var remaining int64
+	db.Model(&model.OAuthRefreshToken{}).Where("family_id = ?", reuse.FamilyID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("expected family rows to be deleted, got %d", remaining)
+	}
+}
+
+func TestRefreshTokensDisabled(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-rt-disabled", "profile")
+	svc := NewOAuthServerService()
+
+	legacy := issueRefreshTokenForTest(t, svc, user, client)
+	setSettingForTest(t, "oauth.enable_refresh_tokens", false, true)
+
+	resp := issueRefreshTokenForTest(t, svc, user, client)
+	if resp.RefreshToken != "" {
+		t.Fatalf("refresh token must not be issued when disabled")
+	}
+	if _, err := svc.RefreshAccessToken(legacy.RefreshToken, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"}, ""); err == nil || err.Error() != "unsupported_grant_type" {
+		t.Fatalf("expected unsupported_grant_type, got %v", err)
+	}
+}
@@ -0,0 +1,526 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/subject"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// registrationTokenEndpointAuthMethods 动态注册允许声明的令牌端点认证方式
+var registrationTokenEndpointAuthMethods = map[string]bool{
+	model.TokenEndpointAuthNone:              true,
+	model.TokenEndpointAuthClientSecretBasic: true,
+	model.TokenEndpointAuthClientSecretPost:  true,
+	model.TokenEndpointAuthPrivateKeyJWT:     true,
+}
+
+// RegistrationError 动态注册错误（RFC 7591 §3.2.2 / RFC 7592 §2）
+type RegistrationError struct {
+	Code        string
+	Description string
+}
+
+func (e *RegistrationError) Error() string {
+	return e.Code
+}
+
+func invalidClientMetadata(description string) *RegistrationError {
+	return &RegistrationError{Code: "invalid_client_metadata", Description: description}
+}
+
+// errInvalidRegistrationToken 初始访问令牌或注册访问令牌无效
+var errInvalidRegistrationToken = &RegistrationError{Code: "invalid_token", Description: "The access token is invalid or expired"}
+
+// ClientMetadata 客户端元数据（RFC 7591 §2）
+type ClientMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types"`
+	ClientName              string   `json:"client_name,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string   `json:"backchannel_logout_uri,omitempty"`
+	FrontchannelLogoutURI   string   `json:"frontchannel_logout_uri,omitempty"`
+
+	JWKS    json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI string          `json:"jwks_uri,omitempty"`
+
+	RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests,omitempty"` // RFC 9126 §6
+
+	SubjectType         string `json:"subject_type,omitempty"`          // OIDC Registration §2
+	SectorIdentifierURI string `json:"sector_identifier_uri,omitempty"` // pairwise 扇区，内容须列出全部 redirect_uris
+}
+
+// ClientRegistrationResponse 客户端信息响应（RFC 7591 §3.2.1）
+type ClientRegistrationResponse struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"` // 0 表示永不过期
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+	ClientMetadata
+}
+
+// CreateInitialAccessTokenRequest 签发初始访问令牌请求
+type CreateInitialAccessTokenRequest struct {
+	AppID          uint   `json:"app_id"`
+	Description    string `json:"description"`
+	MaxUses        int    `json:"max_uses"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+}
+
+// ClientRegistrationService 动态客户端注册与管理服务
+type ClientRegistrationService struct {
+	db *gorm.DB
+}
+
+// NewClientRegistrationService 创建动态客户端注册服务
+func NewClientRegistrationService() *ClientRegistrationService {
+	return &ClientRegistrationService{
+		db: common.DB(),
+	}
+}
+
+// IssueInitialAccessToken 为租户下的应用签发初始访问令牌，返回明文（仅此一次）
+func (s *ClientRegistrationService) IssueInitialAccessToken(tenantID, creatorID uint, req *CreateInitialAccessTokenRequest) (string, *model.OAuthInitialAccessToken, error) {
+	var app model.App
+	if err := s.db.Where("id = ? AND tenant_id = ?", req.AppID, tenantID).First(&app).Error; err != nil {
+		return "", nil, errors.New("应用不存在或无权限访问")
+	}
+	if req.MaxUses < 0 || req.ExpiresInHours < 0 {
+		return "", nil, errors.New("无效的使用次数或有效期")
+	}
+
+	raw, err := model.GenerateInitialAccessToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := &model.OAuthInitialAccessToken{
+		TenantID:    tenantID,
+		AppID:       app.ID,
+		Token:       utils.HashOAuthToken(raw),
+		TokenPrefix: utils.OAuthTokenDisplayPrefix(raw),
+		Description: strings.TrimSpace(req.Description),
+		MaxUses:     req.MaxUses,
+		CreatedBy:   creatorID,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		record.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return "", nil, err
+	}
+
+	aduit.LogAudit(creatorID, "签发OAuth初始访问令牌", "oauth_initial_access_token", record.TokenPrefix, "", "")
+	return raw, record, nil
+}
+
+// ListInitialAccessTokens 列出租户的初始访问令牌
+func (s *ClientRegistrationService) ListInitialAccessTokens(tenantID uint) ([]model.OAuthInitialAccessToken, error) {
+	var tokens []model.OAuthInitialAccessToken
+	err := s.db.Where("tenant_id = ?", tenantID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeInitialAccessToken 撤销租户的初始访问令牌，不影响已注册的客户端
+func (s *ClientRegistrationService) RevokeInitialAccessToken(tenantID, id uint) error {
+	result := s.db.Where("id = ? AND tenant_id = ?", id, tenantID).Delete(&model.OAuthInitialAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("初始访问令牌不存在")
+	}
+	return nil
+}
+
+// RegisterClient 使用初始访问令牌注册客户端（RFC 7591 §3）
+func (s *ClientRegistrationService) RegisterClient(initialAccessToken string, meta *ClientMetadata) (*model.OAuthClient, string, string, error) {
+	// 1. 校验初始访问令牌
+	var iat model.OAuthInitialAccessToken
+	if initialAccessToken == "" {
+		return nil, "", "", errInvalidRegistrationToken
+	}
+	if err := s.db.Where("token = ?", utils.HashOAuthToken(initialAccessToken)).First(&iat).Error; err != nil {
+		return nil, "", "", errInvalidRegistrationToken
+	}
+	if !iat.IsUsable() {
+		return nil, "", "", errInvalidRegistrationToken
+	}
+
+	var app model.App
+	if err := s.db.Where("id = ? AND tenant_id = ?", iat.AppID, iat.TenantID).First(&app).Error; err != nil {
+		return nil, "", "", errInvalidRegistrationToken
+	}
+
+	// 2. 校验元数据
+	if err := normalizeClientMetadata(meta); err != nil {
+		return nil, "", "", err
+	}
+
+	// 3. 原子地占用一次使用次数
+	result := s.db.Model(&model.OAuthInitialAccessToken{}).
+		Where("id = ? AND (max_uses = 0 OR use_count < max_uses)", iat.ID).
+		Update("use_count", gorm.Expr("use_count + 1"))
+	if result.Error != nil {
+		return nil, "", "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", "", errInvalidRegistrationToken
+	}
+
+	// 4. 创建客户端及注册访问令牌
+	client := &model.OAuthClient{
+		AppID:                app.ID,
+		IsActive:             true,
+		CreatedBy:            iat.CreatedBy,
+		InitialAccessTokenID: &iat.ID,
+	}
+	if err := client.GenerateClientCredentials(); err != nil {
+		return nil, "", "", err
+	}
+	applyClientMetadata(client, meta)
+
+	registrationToken, err := model.GenerateRegistrationAccessToken()
+	if err != nil {
+		return nil, "", "", err
+	}
+	client.RegistrationAccessToken = utils.HashOAuthToken(registrationToken)
+
+	// 公共客户端与 private_key_jwt 客户端不下发密钥
+	plainSecret := client.ClientSecret
+	if !client.UsesClientSecret() {
+		plainSecret = ""
+		client.ClientSecret = ""
+	} else {
+		client.HashClientSecret()
+	}
+
+	if err := s.db.Create(client).Error; err != nil {
+		return nil, "", "", err
+	}
+
+	aduit.LogAudit(iat.CreatedBy, "动态注册OAuth2客户端", "oauth_client", client.ClientID, "", "")
+	return client, plainSecret, registrationToken, nil
+}
+
+// AuthenticateRegistration 通过注册访问令牌定位客户端（RFC 7592 §2）
+func (s *ClientRegistrationService) AuthenticateRegistration(clientID, registrationToken string) (*model.OAuthClient, error) {
+	if clientID == "" || registrationToken == "" {
+		return nil, errInvalidRegistrationToken
+	}
+	var client model.OAuthClient
+	err := s.db.Where("client_id = ? AND registration_access_token = ?", clientID, utils.HashOAuthToken(registrationToken)).
+		First(&client).Error
+	if err != nil {
+		return nil, errInvalidRegistrationToken
+	}
+	return &client, nil
+}
+
+// UpdateRegisteredClient 以请求中的元数据整体替换客户端配置（RFC 7592 §2.2）
+func (s *ClientRegistrationService) UpdateRegisteredClient(client *model.OAuthClient, meta *ClientMetadata) error {
+	if err := normalizeClientMetadata(meta); err != nil {
+		return err
+	}
+	// 密钥认证与无密钥认证之间不能互相转换（密钥无法补发）
+	updated := model.OAuthClient{TokenEndpointAuthMethod: meta.TokenEndpointAuthMethod}
+	if updated.UsesClientSecret() != client.UsesClientSecret() {
+		return invalidClientMetadata("token_endpoint_auth_method cannot switch between secret and secretless authentication")
+	}
+
+	applyClientMetadata(client, meta)
+	if err := s.db.Model(client).Select(
+		"redirect_uris", "grant_types", "scopes", "client_name", "token_endpoint_auth_method", "jwks", "jwks_uri",
+		"require_pushed_authorization_requests", "subject_type", "sector_identifier_uri",
+		"post_logout_redirect_uris", "backchannel_logout_uri", "frontchannel_logout_uri",
+	).Updates(client).Error; err != nil {
+		return err
+	}
+
+	aduit.LogAudit(client.CreatedBy, "更新动态注册的OAuth2客户端", "oauth_client", client.ClientID, "", "")
+	return nil
+}
+
+// DeleteRegisteredClient 注销客户端（RFC 7592 §2.3）
+func (s *ClientRegistrationService) DeleteRegisteredClient(client *model.OAuthClient) error {
+	return NewClientService().DeleteClient(client.ClientID)
+}
+
+// normalizeClientMetadata 校验元数据并补齐默认值
+func normalizeClientMetadata(meta *ClientMetadata) error {
+	meta.ClientName = strings.TrimSpace(meta.ClientName)
+	if len(meta.ClientName) > 100 {
+		return invalidClientMetadata("client_name is too long")
+	}
+
+	if meta.TokenEndpointAuthMethod == "" {
+		meta.TokenEndpointAuthMethod = model.TokenEndpointAuthClientSecretBasic
+	}
+	if !registrationTokenEndpointAuthMethods[meta.TokenEndpointAuthMethod] {
+		return invalidClientMetadata("Unsupported token_endpoint_auth_method: " + meta.TokenEndpointAuthMethod)
+	}
+
+	if len(meta.GrantTypes) == 0 {
+		meta.GrantTypes = []string{"authorization_code", "refresh_token"}
+	}
+	if err := validateGrantTypes(meta.GrantTypes); err != nil {
+		return invalidClientMetadata("Unsupported grant_types")
+	}
+	if err := validateTokenEndpointAuthMethod(meta.TokenEndpointAuthMethod, meta.GrantTypes); err != nil {
+		return invalidClientMetadata("Public clients cannot use the client_credentials grant")
+	}
+	meta.JWKSURI = strings.TrimSpace(meta.JWKSURI)
+	if err := validateClientKeys(meta.TokenEndpointAuthMethod, compactJWKS(meta.JWKS), meta.JWKSURI); err != nil {
+		return invalidClientMetadata("Invalid jwks or jwks_uri")
+	}
+	usesAuthorizationCode := false
+	for _, gt := range meta.GrantTypes {
+		if gt == "authorization_code" {
+			usesAuthorizationCode = true
+		}
+	}
+
+	if len(meta.ResponseTypes) == 0 && usesAuthorizationCode {
+		meta.ResponseTypes = []string{"code"}
+	}
+	for _, rt := range meta.ResponseTypes {
+		if rt != "code" {
+			return invalidClientMetadata("Unsupported response_types: " + rt)
+		}
+		if !usesAuthorizationCode {
+			return invalidClientMetadata("response_types code requires the authorization_code grant")
+		}
+	}
+
+	if usesAuthorizationCode && len(meta.RedirectURIs) == 0 {
+		return &RegistrationError{Code: "invalid_redirect_uri", Description: "redirect_uris is required for the authorization_code grant"}
+	}
+	for _, uri := range meta.RedirectURIs {
+		if !isValidRegisteredRedirectURI(uri) {
+			return &RegistrationError{Code: "invalid_redirect_uri", Description: "Invalid redirect_uri: " + uri}
+		}
+	}
+
+	scopes := parseScopeList(meta.Scope)
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	if err := validateClientScopes(scopes); err != nil {
+		return invalidClientMetadata("scope contains values not allowed for clients")
+	}
+	meta.Scope = strings.Join(scopes, " ")
+
+	if err := validateLogoutURIs(meta.PostLogoutRedirectURIs, meta.BackchannelLogoutURI, meta.FrontchannelLogoutURI); err != nil {
+		return invalidClientMetadata("Invalid post_logout_redirect_uris or logout uri")
+	}
+
+	if meta.SubjectType == "" {
+		meta.SubjectType = model.SubjectTypePublic
+	}
+	meta.SectorIdentifierURI = strings.TrimSpace(meta.SectorIdentifierURI)
+	if err := subject.NewService(common.DB()).ValidateClient(meta.SubjectType, meta.SectorIdentifierURI, meta.RedirectURIs); err != nil {
+		return invalidClientMetadata("Invalid subject_type or sector_identifier_uri: " + err.Error())
+	}
+	return nil
+}
+
+// isValidRegisteredRedirectURI 重定向URI须为不带片段的绝对地址；仅本机回环地址允许使用 http
+func isValidRegisteredRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+// applyClientMetadata 将已校验的元数据写入客户端
+func applyClientMetadata(client *model.OAuthClient, meta *ClientMetadata) {
+	client.ClientName = meta.ClientName
+	client.TokenEndpointAuthMethod = meta.TokenEndpointAuthMethod
+	client.JWKS = compactJWKS(meta.JWKS)
+	client.JWKSURI = meta.JWKSURI
+	client.RequirePushedAuthorizationRequests = meta.RequirePushedAuthorizationRequests
+	client.SubjectType = meta.SubjectType
+	client.SectorIdentifierURI = meta.SectorIdentifierURI
+	client.SetRedirectURIList(meta.RedirectURIs)
+	client.SetGrantTypeList(meta.GrantTypes)
+	client.SetScopeList(parseScopeList(meta.Scope))
+	client.SetPostLogoutRedirectURIList(meta.PostLogoutRedirectURIs)
+	client.BackchannelLogoutURI = strings.TrimSpace(meta.BackchannelLogoutURI)
+	client.FrontchannelLogoutURI = strings.TrimSpace(meta.FrontchannelLogoutURI)
+}
+
+// clientMetadataFromModel 从客户端配置还原元数据
+func clientMetadataFromModel(client *model.OAuthClient) ClientMetadata {
+	meta := ClientMetadata{
+		RedirectURIs:            client.GetRedirectURIList(),
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		GrantTypes:              client.GetGrantTypeList(),
+		ClientName:              client.ClientName,
+		Scope:                   strings.Join(client.GetScopeList(), " "),
+		PostLogoutRedirectURIs:  client.GetPostLogoutRedirectURIList(),
+		BackchannelLogoutURI:    client.BackchannelLogoutURI,
+		FrontchannelLogoutURI:   client.FrontchannelLogoutURI,
+		JWKSURI:                 client.JWKSURI,
+
+		RequirePushedAuthorizationRequests: client.RequirePushedAuthorizationRequests,
+
+		SubjectType:         client.SubjectType,
+		SectorIdentifierURI: client.SectorIdentifierURI,
+	}
+	if client.JWKS != "" {
+		meta.JWKS = json.RawMessage(client.JWKS)
+	}
+	if clientAllowsGrant(client, "authorization_code") {
+		meta.ResponseTypes = []string{"code"}
+	} else {
+		meta.ResponseTypes = []string{}
+	}
+	return meta
+}
+
+var clientRegistrationService = NewClientRegistrationService()
+
+// registrationClientURI 客户端配置端点地址
+func registrationClientURI(c *fiber.Ctx, clientID string) string {
+	return requestBaseURL(c) + "/api/v1/oauth/register/" + clientID
+}
+
+// bearerToken 提取 Authorization: Bearer 令牌
+func bearerToken(c *fiber.Ctx) string {
+	authHeader := c.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+}
+
+func registrationError(c *fiber.Ctx, err error) error {
+	var regErr *RegistrationError
+	if !errors.As(err, &regErr) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":             "server_error",
+			"error_description": "Failed to process client registration",
+		})
+	}
+	status := fiber.StatusBadRequest
+	if regErr.Code == "invalid_token" {
+		status = fiber.StatusUnauthorized
+		c.Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":             regErr.Code,
+		"error_description": regErr.Description,
+	})
+}
+
+func registrationResponse(c *fiber.Ctx, client *model.OAuthClient, plainSecret, registrationToken string) *ClientRegistrationResponse {
+	return &ClientRegistrationResponse{
+		ClientID:                client.ClientID,
+		ClientSecret:            plainSecret,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+		ClientSecretExpiresAt:   0,
+		RegistrationAccessToken: registrationToken,
+		RegistrationClientURI:   registrationClientURI(c, client.ClientID),
+		ClientMetadata:          clientMetadataFromModel(client),
+	}
+}
+
+// RegisterClientHandler 动态客户端注册端点
+// POST /oauth/register
+func RegisterClientHandler(c *fiber.Ctx) error {
+	var meta ClientMetadata
+	if err := c.BodyParser(&meta); err != nil {
+		return registrationError(c, invalidClientMetadata("Request body must be a JSON client metadata document"))
+	}
+
+	client, plainSecret, registrationToken, err := clientRegistrationService.RegisterClient(bearerToken(c), &meta)
+	if err != nil {
+		return registrationError(c, err)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.Status(fiber.StatusCreated).JSON(registrationResponse(c, client, plainSecret, registrationToken))
+}
+
+// GetRegisteredClientHandler 读取客户端配置
+// GET /oauth/register/:client_id
+func GetRegisteredClientHandler(c *fiber.Ctx) error {
+	client, err := clientRegistrationService.AuthenticateRegistration(c.Params("client_id"), bearerToken(c))
+	if err != nil {
+		return registrationError(c, err)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(registrationResponse(c, client, "", ""))
+}
+
+// UpdateRegisteredClientHandler 更新客户端配置
+// PUT /oauth/register/:client_id
+func UpdateRegisteredClientHandler(c *fiber.Ctx) error {
+	client, err := clientRegistrationService.AuthenticateRegistration(c.Params("client_id"), bearerToken(c))
+	if err != nil {
+		return registrationError(c, err)
+	}
+
+	var req struct {
+		ClientMetadata
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return registrationError(c, invalidClientMetadata("Request body must be a JSON client metadata document"))
+	}
+	if req.ClientID != client.ClientID {
+		return registrationError(c, invalidClientMetadata("client_id does not match the registration"))
+	}
+	if req.ClientSecret != "" && !client.VerifyClientSecret(req.ClientSecret) {
+		return registrationError(c, invalidClientMetadata("client_secret does not match the registration"))
+	}
+
+	if err := clientRegistrationService.UpdateRegisteredClient(client, &req.ClientMetadata); err != nil {
+		return registrationError(c, err)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(registrationResponse(c, client, "", ""))
+}
+
+// DeleteRegisteredClientHandler 注销客户端
+// DELETE /oauth/register/:client_id
+func DeleteRegisteredClientHandler(c *fiber.Ctx) error {
+	client, err := clientRegistrationService.AuthenticateRegistration(c.Params("client_id"), bearerToken(c))
+	if err != nil {
+		return registrationError(c, err)
+	}
+
+	if err := clientRegistrationService.DeleteRegisteredClient(client); err != nil {
+		return registrationError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupRegistrationTest(t *testing.T, clientID string, maxUses int) (string, *model.OAuthClient) {
+	t.Helper()
+	db := setupOAuthTenantTestDB(t)
+	user, seed := setupIDTokenTestData(t, db, clientID, "openid,profile")
+	if err := db.AutoMigrate(&model.OAuthInitialAccessToken{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+
+	var app model.App
+	db.First(&app, seed.AppID)
+
+	original := clientRegistrationService
+	clientRegistrationService = NewClientRegistrationService()
+	t.Cleanup(func() { clientRegistrationService = original })
+
+	raw, _, err := clientRegistrationService.IssueInitialAccessToken(app.TenantID, user.ID, &CreateInitialAccessTokenRequest{AppID: app.ID, MaxUses: maxUses})
+	if err != nil {
+		t.Fatalf("issue initial access token failed: %v", err)
+	}
+	return raw, seed
+}
+
+func newRegistrationTestApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/oauth/register", RegisterClientHandler)
+	app.Get("/oauth/register/:client_id", GetRegisteredClientHandler)
+	app.Put("/oauth/register/:client_id", UpdateRegisteredClientHandler)
+	app.Delete("/oauth/register/:client_id", DeleteRegisteredClientHandler)
+	return app
+}
+
+func registrationRequest(t *testing.T, app *fiber.App, method, path, token, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	payload := map[string]interface{}{}
+	_ = json.NewDecoder(resp.Body).Decode(&payload)
+	return resp.StatusCode, payload
+}
+
+func TestDynamicClientRegistrationLifecycle(t *testing.T) {
+	initialToken, seed := setupRegistrationTest(t, "client-dcr-seed", 0)
+	// 未配置对外地址时按请求的 Host 构建
+	setPublicURLForTest(t, "")
+	app := newRegistrationTestApp()
+
+	status, body := registrationRequest(t, app, "POST", "/oauth/register", initialToken,
+		`{"client_name":"CLI","redirect_uris":["https://cli.example.com/cb","http://127.0.0.1:8080/cb"],"scope":"openid profile"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("expected 201, got %d %v", status, body)
+	}
+	clientID, _ := body["client_id"].(string)
+	registrationToken, _ := body["registration_access_token"].(string)
+	if clientID == "" || body["client_secret"] == "" || registrationToken == "" {
+		t.Fatalf("expected credentials in registration response, got %v", body)
+	}
+	if body["registration_client_uri"] != "http://example.com/api/v1/oauth/register/"+clientID || body["scope"] != "openid profile" {
+		t.Fatalf("unexpected registration response %v", body)
+	}
+
+	var stored model.OAuthClient
+	if err := clientRegistrationService.db.Where("client_id = ?", clientID).First(&stored).Error; err != nil {
+		t.Fatalf("registered client not stored: %v", err)
+	}
+	if stored.AppID != seed.AppID || stored.RegistrationAccessToken == registrationToken || !strings.HasPrefix(stored.RegistrationAccessToken, "h1:") {
+		t.Fatalf("unexpected stored client %+v", stored)
+	}
+
+	// 注册访问令牌只对自己的客户端有效
+	if status, _ := registrationRequest(t, app, "GET", "/oauth/register/"+clientID, "rat_wrong", ""); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong registration token, got %d", status)
+	}
+	if status, _ := registrationRequest(t, app, "GET", "/oauth/register/"+seed.ClientID, registrationToken, ""); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected 401 for another client, got %d", status)
+	}
+
+	status, body = registrationRequest(t, app, "GET", "/oauth/register/"+clientID, registrationToken, "")
+	if status != fiber.StatusOK || body["client_name"] != "CLI" || body["client_secret"] != nil {
+		t.Fatalf("unexpected read response %d %v", status, body)
+	}
+
+	status, body = registrationRequest(t, app, "PUT", "/oauth/register/"+clientID, registrationToken,
+		`{"client_id":"`+clientID+`","client_name":"CLI v2","redirect_uris":["https://cli.example.com/v2"]}`)
+	if status != fiber.StatusOK || body["client_name"] != "CLI v2" {
+		t.Fatalf("unexpected update response %d %v", status, body)
+	}
+	clientRegistrationService.db.Where("client_id = ?", clientID).First(&stored)
+	if stored.RedirectURIs != "https://cli.example.com/v2" {
+		t.Fatalf("expected redirect uris to be replaced, got %q", stored.RedirectURIs)
+	}
+
+	if status, _ := registrationRequest(t, app, "DELETE", "/oauth/register/"+clientID, registrationToken, ""); status != fiber.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", status)
+	}
+	if status, _ := registrationRequest(t, app, "GET", "/oauth/register/"+clientID, registrationToken, ""); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected deleted client to be inaccessible, got %d", status)
+	}
+}
+
+func TestDynamicClientRegistrationRejectsInvalidRequests(t *testing.T) {
+	initialToken, _ := setupRegistrationTest(t, "client-dcr-invalid", 1)
+	app := newRegistrationTestApp()
+
+	if status, _ := registrationRequest(t, app, "POST", "/oauth/register", "", `{"redirect_uris":["https://a.example.com/cb"]}`); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected 401 without initial access token, got %d", status)
+	}
+
+	for _, uri := range []string{"http://a.example.com/cb", "https://a.example.com/cb#frag", "custom:/cb"} {
+		status, body := registrationRequest(t, app, "POST", "/oauth/register", initialToken, `{"redirect_uris":["`+uri+`"]}`)
+		if status != fiber.StatusBadRequest || body["error"] != "invalid_redirect_uri" {
+			t.Fatalf("expected invalid_redirect_uri for %q, got %d %v", uri, status, body)
+		}
+	}
+	status, body := registrationRequest(t, app, "POST", "/oauth/register", initialToken, `{"redirect_uris":["https://a.example.com/cb"],"token_endpoint_auth_method":"tls_client_auth"}`)
+	if status != fiber.StatusBadRequest || body["error"] != "invalid_client_metadata" {
+		t.Fatalf("expected invalid_client_metadata, got %d %v", status, body)
+	}
+
+	status, body = registrationRequest(t, app, "POST", "/oauth/register", initialToken, `{"redirect_uris":["https://a.example.com/cb"],"token_endpoint_auth_method":"none","grant_types":["client_credentials"]}`)
+	if status != fiber.StatusBadRequest || body["error"] != "invalid_client_metadata" {
+		t.Fatalf("expected public client_credentials client to be rejected, got %d %v", status, body)
+	}
+
+	// 后端登出地址须为 https 且不能指向本机或内网
+	for _, uri := range []string{"http://rp.example.com/logout", "https://127.0.0.1/logout", "https://169.254.169.254/latest", "https://localhost/logout"} {
+		status, body := registrationRequest(t, app, "POST", "/oauth/register", initialToken, `{"redirect_uris":["https://a.example.com/cb"],"backchannel_logout_uri":"`+uri+`"}`)
+		if status != fiber.StatusBadRequest || body["error"] != "invalid_client_metadata" {
+			t.Fatalf("expected backchannel_logout_uri %q to be rejected, got %d %v", uri, status, body)
+		}
+	}
+
+	// 失败的请求不消耗使用次数；max_uses=1 时第二次注册被拒绝
+	if status, _ := registrationRequest(t, app, "POST", "/oauth/register", initialToken, `{"redirect_uris":["https://a.example.com/cb"]}`); status != fiber.StatusCreated {
+		t.Fatalf("expected first registration to succeed, got %d", status)
+	}
+	if status, _ := registrationRequest(t, app, "POST", "/oauth/register", initialToken, `{"redirect_uris":["https://b.example.com/cb"]}`); status != fiber.StatusUnauthorized {
+		t.Fatalf("expected exhausted initial access token to be rejected, got %d", status)
+	}
+}
+
+func TestDynamicClientRegistrationPublicClient(t *testing.T) {
+	initialToken, _ := setupRegistrationTest(t, "client-dcr-public", 0)
+	app := newRegistrationTestApp()
+
+	status, body := registrationRequest(t, app, "POST", "/oauth/register", initialToken,
+		`{"redirect_uris":["http://127.0.0.1:9000/cb"],"token_endpoint_auth_method":"none"}`)
+	if status != fiber.StatusCreated {
+		t.Fatalf("expected 201, got %d %v", status, body)
+	}
+	if _, ok := body["client_secret"]; ok || body["token_endpoint_auth_method"] != "none" {
+		t.Fatalf("public client must not receive a secret: %v", body)
+	}
+
+	clientID, _ := body["client_id"].(string)
+	registrationToken, _ := body["registration_access_token"].(string)
+	status, body = registrationRequest(t, app, "PUT", "/oauth/register/"+clientID, registrationToken,
+		`{"client_id":"`+clientID+`","redirect_uris":["http://127.0.0.1:9000/cb"],"token_endpoint_auth_method":"client_secret_basic"}`)
+	if status != fiber.StatusBadRequest || body["error"] != "invalid_client_metadata" {
+		t.Fatalf("expected switch to a confidential client to be rejected, got %d %v", status, body)
+	}
+}
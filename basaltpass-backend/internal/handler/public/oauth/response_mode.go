@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 授权响应的返回方式（OAuth 2.0 Multiple Response Type Encoding Practices / Form Post Response Mode）
+const (
+	responseModeQuery      = "query"
+	responseModeFragment   = "fragment"
+	responseModeFormPost   = "form_post"
+	responseModeWebMessage = "web_message"
+)
+
+// supportedResponseModes Discovery 中公布的 response_modes_supported
+var supportedResponseModes = []string{responseModeQuery, responseModeFragment, responseModeFormPost, responseModeWebMessage}
+
+// validateResponseMode 校验 response_mode；web_message 只能投递到客户端 AllowedOrigins 中登记的源
+func validateResponseMode(client *model.OAuthClient, req *AuthorizeRequest) error {
+	switch req.ResponseMode {
+	case "", responseModeQuery, responseModeFragment, responseModeFormPost:
+		return nil
+	case responseModeWebMessage:
+		if !webMessageOriginAllowed(client, req.RedirectURI) {
+			return errors.New("invalid_request")
+		}
+		return nil
+	default:
+		return errors.New("invalid_request")
+	}
+}
+
+// webMessageOriginAllowed 判断 redirect_uri 的源是否在客户端的 AllowedOrigins 中
+func webMessageOriginAllowed(client *model.OAuthClient, redirectURI string) bool {
+	origin, err := resolveOriginFromRedirectURI(redirectURI)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range client.GetAllowedOriginList() {
+		if strings.EqualFold(strings.TrimRight(strings.TrimSpace(allowed), "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeCodeResponse 按请求的 response_mode 返回授权码
+func authorizeCodeResponse(c *fiber.Ctx, req *AuthorizeRequest, code string) error {
+	params := url.Values{}
+	params.Set("code", code)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return writeAuthorizeResponse(c, req.ResponseMode, req.RedirectURI, params)
+}
+
+// authorizeErrorResponse 按请求的 response_mode 返回授权错误（请求已通过校验）
+func authorizeErrorResponse(c *fiber.Ctx, req *AuthorizeRequest, errorCode string) error {
+	params := url.Values{}
+	params.Set("error", errorCode)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return writeAuthorizeResponse(c, req.ResponseMode, req.RedirectURI, params)
+}
+
+// authorizeErrorIfAllowed 只在 redirect_uri 已登记时返回授权错误，否则直接返回 JSON；
+// 按 OAuth2 安全建议，client 或 redirect_uri 无效时不得重定向。response_mode 本身不合法时退回 query 方式。
+func authorizeErrorIfAllowed(c *fiber.Ctx, req *AuthorizeRequest, errorCode string) error {
+	var client model.OAuthClient
+	if err := oauthServerService.db.Select("client_id", "redirect_uris", "allowed_origins").
+		Where("client_id = ?", strings.TrimSpace(req.ClientID)).
+		First(&client).Error; err != nil || !client.ValidateRedirectURI(strings.TrimSpace(req.RedirectURI)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":             errorCode,
+			"error_description": "invalid redirect_uri",
+		})
+	}
+
+	mode := req.ResponseMode
+	if validateResponseMode(&client, req) != nil {
+		mode = responseModeQuery
+	}
+	params := url.Values{}
+	params.Set("error", errorCode)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return writeAuthorizeResponse(c, mode, req.RedirectURI, params)
+}
+
+// writeAuthorizeResponse 将授权响应参数按 response_mode 送回客户端
+func writeAuthorizeResponse(c *fiber.Ctx, mode, redirectURI string, params url.Values) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_redirect_uri"})
+	}
+
+	switch mode {
+	case responseModeFormPost:
+		return renderFormPost(c, u.String(), params)
+	case responseModeWebMessage:
+		return renderWebMessage(c, redirectURI, params)
+	case responseModeFragment:
+		u.Fragment = ""
+		return c.Redirect(u.String()+"#"+params.Encode(), http.StatusFound)
+	default:
+		q := u.Query()
+		for key := range params {
+			q.Set(key, params.Get(key))
+		}
+		u.RawQuery = q.Encode()
+		return c.Redirect(u.String(), http.StatusFound)
+	}
+}
+
+// renderFormPost 渲染自动提交的表单，以 POST 将授权响应发送到 redirect_uri（OAuth 2.0 Form Post Response Mode §2）
+func renderFormPost(c *fiber.Ctx, action string, params url.Values) error {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var inputs strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&inputs, "\n        <input type=\"hidden\" name=\"%s\" value=\"%s\"/>", html.EscapeString(key), html.EscapeString(params.Get(key)))
+	}
+
+	page := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <title>BasaltPass</title>
+</head>
+<body onload="document.forms[0].submit()">
+    <form method="post" action="%s">%s
+        <noscript><button type="submit">Continue</button></noscript>
+    </form>
+</body>
+</html>
+        `, html.EscapeString(action), inputs.String())
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Content-Type", "text/html")
+	return c.SendString(page)
+}
+
+// renderWebMessage 以 postMessage 将授权响应发送给打开授权页的窗口（弹窗的 opener 或 iframe 的 parent），
+// 目标源固定为 redirect_uri 的源，不进行页面跳转
+func renderWebMessage(c *fiber.Ctx, redirectURI string, params url.Values) error {
+	targetOrigin, err := resolveOriginFromRedirectURI(redirectURI)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_redirect_uri"})
+	}
+
+	response := make(map[string]string, len(params))
+	for key := range params {
+		response[key] = params.Get(key)
+	}
+	message, err := json.Marshal(map[string]interface{}{
+		"type":     "authorization_response",
+		"response": response,
+	})
+	if err != nil {
+		return err
+	}
+
+	page := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <title>BasaltPass</title>
+</head>
+<body>
+    <script>
+        // 向打开授权页的窗口发送授权结果
+        const message = %s;
+        const target = window.opener || (window.parent !== window ? window.parent : null);
+        if (target) {
+            target.postMessage(message, %s);
+        }
+        if (window.opener) {
+            window.close();
+        }
+    </script>
+</body>
+</html>
+        `, message, jsonStringLiteral(targetOrigin))
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Content-Type", "text/html")
+	return c.SendString(page)
+}
@@ -0,0 +1,95 @@
+package oauth
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	serviceauth "basaltpass-backend/internal/service/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuthorizeResponseModes(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	user, client := setupIDTokenTestData(t, db, "client-response-mode", "openid,profile")
+	if err := db.Create(&model.AppUser{AppID: client.AppID, UserID: user.ID, Status: model.AppUserStatusActive}).Error; err != nil {
+		t.Fatalf("create app user failed: %v", err)
+	}
+	original := oauthServerService
+	oauthServerService = NewOAuthServerService()
+	t.Cleanup(func() { oauthServerService = original })
+
+	app := fiber.New()
+	app.Get("/api/v1/oauth/authorize", AuthorizeHandler)
+	session := sessionCookieForTest(t, user.ID, time.Now(), serviceauth.AMRPassword)
+
+	authorize := func(mode, cookie string, extra url.Values) (int, string, string) {
+		q := url.Values{}
+		q.Set("client_id", client.ClientID)
+		q.Set("redirect_uri", "https://rp.example.com/callback")
+		q.Set("response_type", "code")
+		q.Set("scope", "openid")
+		q.Set("state", "s<1>")
+		q.Set("response_mode", mode)
+		q.Set("code_challenge", s256ChallengeForTest("response-mode-verifier-0123456789-abcdefghijkl"))
+		q.Set("code_challenge_method", "S256")
+		for k, v := range extra {
+			q[k] = v
+		}
+		req := httptest.NewRequest("GET", "/api/v1/oauth/authorize?"+q.Encode(), nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", "access_token="+cookie)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Location"), string(body)
+	}
+
+	// form_post：自动提交的表单，参数经过 HTML 转义
+	status, _, body := authorize(responseModeFormPost, session, nil)
+	if status != fiber.StatusOK || !strings.Contains(body, `action="https://rp.example.com/callback"`) ||
+		!strings.Contains(body, `name="code"`) || !strings.Contains(body, `value="s&lt;1&gt;"`) {
+		t.Fatalf("unexpected form_post response: %d %s", status, body)
+	}
+
+	// 错误同样按 form_post 返回
+	status, _, body = authorize(responseModeFormPost, "", url.Values{"prompt": {"none"}})
+	if status != fiber.StatusOK || !strings.Contains(body, `value="login_required"`) {
+		t.Fatalf("expected login_required via form_post, got %d %s", status, body)
+	}
+
+	// fragment：参数放在 # 之后
+	status, location, _ := authorize(responseModeFragment, session, nil)
+	if u, _ := url.Parse(location); status != fiber.StatusFound || u.RawQuery != "" || !strings.HasPrefix(u.Fragment, "code=") {
+		t.Fatalf("unexpected fragment response: %d %s", status, location)
+	}
+
+	// web_message：redirect_uri 的源未登记在 AllowedOrigins 时拒绝，并退回 query 返回错误
+	status, location, _ = authorize(responseModeWebMessage, session, nil)
+	if u, _ := url.Parse(location); status != fiber.StatusFound || u.Query().Get("error") != "invalid_request" {
+		t.Fatalf("expected invalid_request for unregistered origin, got %d %s", status, location)
+	}
+
+	if err := db.Model(client).Update("allowed_origins", "https://rp.example.com/").Error; err != nil {
+		t.Fatalf("update client failed: %v", err)
+	}
+	status, _, body = authorize(responseModeWebMessage, session, nil)
+	if status != fiber.StatusOK || !strings.Contains(body, `"type":"authorization_response"`) ||
+		!strings.Contains(body, `postMessage(message, "https://rp.example.com")`) {
+		t.Fatalf("unexpected web_message response: %d %s", status, body)
+	}
+
+	// 未知的 response_mode
+	status, location, _ = authorize("jwt", session, nil)
+	if u, _ := url.Parse(location); status != fiber.StatusFound || u.Query().Get("error") != "invalid_request" {
+		t.Fatalf("expected invalid_request for unsupported response_mode, got %d %s", status, location)
+	}
+}
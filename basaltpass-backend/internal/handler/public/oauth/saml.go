@@ -0,0 +1,252 @@
+package oauth
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/handler/public/app/app_user"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	samlsvc "basaltpass-backend/internal/service/saml"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newSAMLService 创建 SAML IdP 服务
+var newSAMLService = func() *samlsvc.Service {
+	return samlsvc.NewService(common.DB())
+}
+
+// SAMLMetadataHandler 租户 IdP 元数据
+// GET /saml/:tenant_code/metadata
+func SAMLMetadataHandler(c *fiber.Ctx) error {
+	svc := newSAMLService()
+	idp, err := svc.IdentityProvider(requestBaseURL(c), c.Params("tenant_code"))
+	if err != nil {
+		return samlError(c, err)
+	}
+	metadata, err := svc.Metadata(idp)
+	if err != nil {
+		log.Printf("[SAML] build metadata for tenant %s failed: %v", idp.TenantCode, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+	}
+	c.Set("Content-Type", "application/samlmetadata+xml")
+	c.Set("Cache-Control", "public, max-age=3600")
+	return c.SendString(metadata)
+}
+
+// SAMLSSOHandler SP 发起的单点登录（HTTP-Redirect / HTTP-POST 绑定）。
+// 用户未登录时跳转到租户登录页，登录后携带 pending 回到本端点继续签发断言。
+// GET/POST /saml/:tenant_code/sso
+func SAMLSSOHandler(c *fiber.Ctx) error {
+	svc := newSAMLService()
+	idp, err := svc.IdentityProvider(requestBaseURL(c), c.Params("tenant_code"))
+	if err != nil {
+		return samlError(c, err)
+	}
+
+	var req *samlsvc.AuthnRequest
+	pending := c.Query("pending")
+	if pending != "" {
+		if req, err = svc.DecodePending(idp, pending); err != nil {
+			return samlError(c, err)
+		}
+	} else {
+		in, ok := samlInbound(c, "SAMLRequest")
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":             "invalid_request",
+				"error_description": "SAMLRequest is required",
+			})
+		}
+		req, err = svc.ParseAuthnRequest(idp, in)
+		if errors.Is(err, samlsvc.ErrNameIDUnavailable) && req != nil {
+			return samlFailure(c, svc, idp, req, samlsvc.StatusInvalidNameIDPolicy)
+		}
+		if err != nil {
+			return samlError(c, err)
+		}
+		if pending, err = svc.EncodePending(idp, req); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+		}
+	}
+	return continueSAMLLogin(c, svc, idp, req, pending)
+}
+
+// SAMLIdPInitiatedHandler IdP 发起的单点登录，应用的 SP 需开启 allow_idp_initiated
+// GET /saml/:tenant_code/apps/:app_id/sso
+func SAMLIdPInitiatedHandler(c *fiber.Ctx) error {
+	svc := newSAMLService()
+	idp, err := svc.IdentityProvider(requestBaseURL(c), c.Params("tenant_code"))
+	if err != nil {
+		return samlError(c, err)
+	}
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return samlError(c, samlsvc.ErrServiceProviderNotFound)
+	}
+
+	req, err := svc.IdPInitiatedRequest(idp, uint(appID), c.Query("RelayState"))
+	if err != nil {
+		return samlError(c, err)
+	}
+	pending, err := svc.EncodePending(idp, req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+	}
+	return continueSAMLLogin(c, svc, idp, req, pending)
+}
+
+// continueSAMLLogin 确认登录状态与租户授权后签发断言，以自动提交的表单 POST 到 SP
+func continueSAMLLogin(c *fiber.Ctx, svc *samlsvc.Service, idp *samlsvc.IdentityProvider, req *samlsvc.AuthnRequest, pending string) error {
+	// 1. 未登录或 ForceAuthn 要求重新认证时跳转登录页；IsPassive 请求不能与用户交互
+	returnPath := samlsvc.PathPrefix + idp.TenantCode + "/sso?pending=" + url.QueryEscape(pending)
+	session := currentAuthSession(c)
+	if session == nil {
+		if req.IsPassive {
+			return samlFailure(c, svc, idp, req, samlsvc.StatusNoPassive)
+		}
+		return c.Redirect(tenantLoginURL(idp.TenantID, returnPath), fiber.StatusFound)
+	}
+	if req.ForceAuthn && !consumeReauthMarker(c, session) {
+		if req.IsPassive {
+			return samlFailure(c, svc, idp, req, samlsvc.StatusNoPassive)
+		}
+		loginURL, err := reauthLoginURL(c, tenantLoginURL(idp.TenantID, returnPath))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+		}
+		return c.Redirect(loginURL, fiber.StatusFound)
+	}
+
+	// 2. 复用 OAuth 授权的租户判定：用户必须属于应用所在租户（SAML 没有同意页，需加入租户时直接拒绝）
+	uid := session.UserID
+	app := req.ServiceProvider.App
+	decision, err := oauthServerService.EvaluateUserTenantAuthorization(uid, &model.OAuthClient{AppID: app.ID, App: app})
+	if err != nil || !decision.Allowed {
+		aduit.LogAudit(uid, "SAML单点登录被拒绝", "saml_service_provider", req.ServiceProvider.EntityID, c.IP(), c.Get("User-Agent"))
+		return samlFailure(c, svc, idp, req, samlsvc.StatusRequestDenied)
+	}
+
+	// 3. 签发断言并记录应用授权
+	resp, err := svc.IssueResponse(idp, req, uid, session.AuthTime)
+	if errors.Is(err, samlsvc.ErrNameIDUnavailable) {
+		return samlFailure(c, svc, idp, req, samlsvc.StatusInvalidNameIDPolicy)
+	}
+	if err != nil {
+		log.Printf("[SAML] issue response for user %d failed: %v", uid, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+	}
+	if err := app_user.NewAppUserService(common.DB()).RecordUserAppAuthorization(app.ID, uid, ""); err != nil {
+		log.Printf("[SAML] record app authorization failed: %v", err)
+	}
+	aduit.LogAudit(uid, "SAML单点登录", "saml_service_provider", req.ServiceProvider.EntityID, c.IP(), c.Get("User-Agent"))
+
+	return renderSAMLPost(c, resp)
+}
+
+// SAMLSLOHandler 单点登出端点。
+// 收到 LogoutRequest 时只结束该用户在发起方 SP 的会话；仅当当前浏览器持有同一用户的控制台会话时，
+// 才登出浏览器并通知其它 SP 与 OIDC 应用。随后向发起方回复 LogoutResponse；
+// 收到 LogoutResponse（IdP 发起的登出在 iframe 中的回执）时仅确认。
+// GET/POST /saml/:tenant_code/slo
+func SAMLSLOHandler(c *fiber.Ctx) error {
+	svc := newSAMLService()
+	idp, err := svc.IdentityProvider(requestBaseURL(c), c.Params("tenant_code"))
+	if err != nil {
+		return samlError(c, err)
+	}
+
+	if _, ok := samlInbound(c, "SAMLResponse"); ok {
+		c.Set("Content-Type", "text/html; charset=utf-8")
+		return c.SendString(renderLogoutPage(nil, ""))
+	}
+	in, ok := samlInbound(c, "SAMLRequest")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":             "invalid_request",
+			"error_description": "SAMLRequest or SAMLResponse is required",
+		})
+	}
+	req, err := svc.ParseLogoutRequest(idp, in)
+	if err != nil {
+		return samlError(c, err)
+	}
+
+	// 仅结束发起登出的 SP 的会话；其它 SP 的会话只在当前浏览器持有同一用户的控制台会话时随之登出
+	if err := svc.EndSessions(req); err != nil {
+		log.Printf("[SAML] end sessions for user %d failed: %v", req.UserID, err)
+	}
+	var frontchannelURLs []string
+	if sessionUserID, ok := tryUserIDFromAccessTokenCookie(c); ok && req.UserID != 0 && sessionUserID == req.UserID {
+		frontchannelURLs = append(frontchannelURLs, LogoutUser(c, sessionUserID)...)
+		clearSessionCookies(c)
+		aduit.LogAudit(sessionUserID, "SAML单点登出", "saml_service_provider", req.ServiceProvider.EntityID, c.IP(), c.Get("User-Agent"))
+	}
+
+	redirectTo, err := svc.LogoutResponseURL(idp, req)
+	if err != nil {
+		log.Printf("[SAML] build logout response failed: %v", err)
+	}
+	if len(frontchannelURLs) == 0 && redirectTo != "" {
+		return c.Redirect(redirectTo, fiber.StatusFound)
+	}
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.SendString(renderLogoutPage(frontchannelURLs, redirectTo))
+}
+
+// samlInbound 读取 HTTP-POST（表单）或 HTTP-Redirect（查询串）绑定的协议消息
+func samlInbound(c *fiber.Ctx, param string) (*samlsvc.Inbound, bool) {
+	if c.Method() == fiber.MethodPost {
+		in := &samlsvc.Inbound{Binding: samlsvc.BindingPOST, Message: c.FormValue(param), RelayState: c.FormValue("RelayState")}
+		return in, in.Message != ""
+	}
+	in := &samlsvc.Inbound{
+		Binding:    samlsvc.BindingRedirect,
+		Message:    c.Query(param),
+		RelayState: c.Query("RelayState"),
+		RawQuery:   string(c.Request().URI().QueryString()),
+	}
+	return in, in.Message != ""
+}
+
+// samlFailure 向 SP 返回不含断言的失败响应
+func samlFailure(c *fiber.Ctx, svc *samlsvc.Service, idp *samlsvc.IdentityProvider, req *samlsvc.AuthnRequest, status string) error {
+	resp, err := svc.ErrorResponse(idp, req, status)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+	}
+	return renderSAMLPost(c, resp)
+}
+
+func renderSAMLPost(c *fiber.Ctx, resp *samlsvc.Response) error {
+	params := url.Values{}
+	params.Set("SAMLResponse", resp.SAMLResponse)
+	if resp.RelayState != "" {
+		params.Set("RelayState", resp.RelayState)
+	}
+	return renderFormPost(c, resp.ACSURL, params)
+}
+
+// samlError 无法安全回复 SP 时（SP 未知、签名无效、消费地址未登记等）直接返回错误
+func samlError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	switch {
+	case errors.Is(err, samlsvc.ErrTenantNotFound), errors.Is(err, samlsvc.ErrServiceProviderNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, samlsvc.ErrIdPInitiatedDisabled):
+		status = fiber.StatusForbidden
+	case errors.Is(err, samlsvc.ErrInvalidRequest), errors.Is(err, samlsvc.ErrRequestSignature),
+		errors.Is(err, samlsvc.ErrUnknownACS), errors.Is(err, samlsvc.ErrPendingRequest):
+	default:
+		log.Printf("[SAML] request failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server_error"})
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":             "invalid_request",
+		"error_description": err.Error(),
+	})
+}
@@ -5,7 +5,10 @@ import (
 	"basaltpass-backend/internal/config"
 	"basaltpass-backend/internal/service/aduit"
 	serviceauth "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/consolesession"
 	"encoding/base64"
+	"errors"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -23,6 +26,7 @@ var oauthServerService = NewOAuthServerService()
 // GET /oauth/authorize
 func AuthorizeHandler(c *fiber.Ctx) error {
 	// 解析授权请求参数
+	maxAge, maxAgeErr := parseMaxAge(c.Query("max_age"))
 	req := &AuthorizeRequest{
 		ClientID:            c.Query("client_id"),
 		RedirectURI:         c.Query("redirect_uri"),
@@ -31,32 +35,64 @@ func AuthorizeHandler(c *fiber.Ctx) error {
 		State:               c.Query("state"),
 		CodeChallenge:       c.Query("code_challenge"),
 		CodeChallengeMethod: c.Query("code_challenge_method"),
+		Nonce:               c.Query("nonce"),
+		ResponseMode:        c.Query("response_mode"),
+		RequestURI:          c.Query("request_uri"),
+		Prompt:              c.Query("prompt"),
+		MaxAge:              maxAge,
+		LoginHint:           c.Query("login_hint"),
+		ACRValues:           c.Query("acr_values"),
+		Claims:              c.Query("claims"),
+	}
+
+	// 展开 request_uri（PAR）或签名请求对象（JAR）；此时 redirect_uri 尚不可信，错误直接返回
+	req, err := oauthServerService.ResolveAuthorizeRequest(req, c.Query("request"), oidcIssuer(c))
+	if err != nil {
+		return authorizeRequestError(c, err)
 	}
 
 	// 验证授权请求
 	client, err := oauthServerService.ValidateAuthorizeRequest(req)
+	if err == nil && maxAgeErr != nil {
+		err = maxAgeErr
+	}
 	if err != nil {
-		return redirectWithErrorIfAllowed(c, req.ClientID, req.RedirectURI, err.Error(), req.State)
+		return authorizeErrorIfAllowed(c, req, err.Error())
 	}
+	silent := req.hasPrompt(promptNone)
 
 	// 检查用户是否已登录
-	userID := c.Locals("userID")
-	if userID == nil {
-		// Hosted login flow uses an HttpOnly cookie; browser redirects can't attach Authorization headers.
-		if uid, ok := tryUserIDFromAccessTokenCookie(c); ok {
-			c.Locals("userID", uid)
-			userID = uid
+	// Hosted login flow uses an HttpOnly cookie; browser redirects can't attach Authorization headers.
+	session := currentAuthSession(c)
+	if session == nil {
+		if silent {
+			return authorizeErrorResponse(c, req, "login_required")
 		}
-	}
-	if userID == nil {
 		// 用户未登录，重定向到登录页面
 		// 构建租户特定的登录URL
 		loginURL := buildLoginURLWithTenant(c, req, client)
 		return c.Redirect(loginURL, http.StatusFound)
 	}
 
+	// prompt=login、max_age 超时或会话达不到 acr_values 时要求重新登录
+	reauthenticated := consumeReauthMarker(c, session)
+	if needsReauthentication(req, session, reauthenticated) {
+		// 刚重新登录仍达不到 acr_values（如账号未绑定 TOTP / Passkey），再次跳转只会循环
+		if reauthenticated {
+			return authorizeErrorResponse(c, req, "unmet_authentication_requirements")
+		}
+		if silent {
+			return authorizeErrorResponse(c, req, "login_required")
+		}
+		loginURL, err := reauthLoginURL(c, buildLoginURLWithTenant(c, req, client))
+		if err != nil {
+			return authorizeErrorResponse(c, req, "server_error")
+		}
+		return c.Redirect(loginURL, http.StatusFound)
+	}
+
 	// 用户已登录，验证用户是否属于该租户
-	uid := userID.(uint)
+	uid := session.UserID
 	decision, err := oauthServerService.EvaluateUserTenantAuthorization(uid, client)
 	if err != nil {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
@@ -66,25 +102,35 @@ func AuthorizeHandler(c *fiber.Ctx) error {
 	}
 
 	// 用户已登录且属于正确的租户，重定向到前端托管的授权同意页面
+	alreadyAuthorized := false
 	if decision.Allowed {
-		alreadyAuthorized, err := oauthServerService.HasAppUserAuthorization(client.AppID, uid)
+		alreadyAuthorized, err = oauthServerService.HasAppUserAuthorization(client.AppID, uid)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error":             "server_error",
 				"error_description": "Failed to check existing app authorization",
 			})
 		}
+	}
+
+	// Skip consent when the user has already authorized this app,
+	// unless the client explicitly asks for consent or account selection.
+	if alreadyAuthorized && !req.hasPrompt(promptConsent) && !req.hasPrompt(promptSelectAccount) {
+		code, err := oauthServerService.issueAuthorizationCode(session, req, client)
+		if err != nil {
+			return authorizeErrorResponse(c, req, "server_error")
+		}
 
-		// Skip consent when the user has already authorized this app.
-		if alreadyAuthorized {
-			code, err := oauthServerService.GenerateAuthorizationCode(uid, req, client)
-			if err != nil {
-				return redirectWithError(c, req.RedirectURI, "server_error", req.State)
-			}
+		aduit.LogAudit(uid, "OAuth2授权(免再次确认)", "oauth_client", req.ClientID, c.IP(), c.Get("User-Agent"))
+		return authorizeCodeResponse(c, req, code)
+	}
 
-			aduit.LogAudit(uid, "OAuth2授权(免再次确认)", "oauth_client", req.ClientID, c.IP(), c.Get("User-Agent"))
-			return redirectWithCode(c, req.RedirectURI, code, req.State)
+	// prompt=none 不能展示同意页或加入租户确认
+	if silent {
+		if !decision.Allowed {
+			return authorizeErrorResponse(c, req, "interaction_required")
 		}
+		return authorizeErrorResponse(c, req, "consent_required")
 	}
 
 	consentURL := buildConsentURL(req, client, decision)
@@ -92,6 +138,14 @@ func AuthorizeHandler(c *fiber.Ctx) error {
 }
 
 func tryUserIDFromAccessTokenCookie(c *fiber.Ctx) (uint, bool) {
+	session, ok := trySessionFromAccessTokenCookie(c)
+	if !ok {
+		return 0, false
+	}
+	return session.UserID, true
+}
+
+func trySessionFromAccessTokenCookie(c *fiber.Ctx) (*authSession, bool) {
 	// OAuth hosted flow can come from different consoles (user/tenant/admin),
 	// so we need to accept scoped cookie names as well.
 	cookieNames := []string{
@@ -106,51 +160,61 @@ func tryUserIDFromAccessTokenCookie(c *fiber.Ctx) (uint, bool) {
 		if tokenStr == "" {
 			continue
 		}
-		if uid, ok := parseUserIDFromJWT(tokenStr); ok {
-			return uid, true
+		if session, ok := parseSessionFromJWT(tokenStr); ok {
+			return session, true
 		}
 	}
 
-	return 0, false
+	return nil, false
 }
 
 func parseUserIDFromJWT(tokenStr string) (uint, bool) {
-	secret, err := common.JWTSecret()
-	if err != nil {
+	session, ok := parseSessionFromJWT(tokenStr)
+	if !ok {
 		return 0, false
 	}
+	return session.UserID, true
+}
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrTokenSignatureInvalid
-		}
-		return secret, nil
-	})
+// parseSessionFromJWT 校验控制台访问令牌并读取用户及其认证上下文（auth_time / amr）
+func parseSessionFromJWT(tokenStr string) (*authSession, bool) {
+	token, err := serviceauth.ParseToken(tokenStr)
 	if err != nil || token == nil || !token.Valid {
-		return 0, false
+		return nil, false
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, false
+		return nil, false
 	}
 	if err := serviceauth.ValidateAccessTokenType(claims); err != nil {
-		return 0, false
+		return nil, false
+	}
+	// 已撤销的控制台会话不能再用于授权
+	if sessionID := serviceauth.SessionIDFromClaims(claims); sessionID != "" {
+		if err := consolesession.NewService(common.DB()).Validate(sessionID); err != nil {
+			return nil, false
+		}
 	}
 	sub, exists := claims["sub"]
 	if !exists {
-		return 0, false
+		return nil, false
 	}
+	var uid uint
 	if subFloat, ok := sub.(float64); ok {
-		return uint(subFloat), true
-	}
-	if subStr, ok := sub.(string); ok {
-		uid, err := strconv.ParseUint(subStr, 10, 64)
-		if err == nil {
-			return uint(uid), true
+		uid = uint(subFloat)
+	} else if subStr, ok := sub.(string); ok {
+		parsed, err := strconv.ParseUint(subStr, 10, 64)
+		if err != nil {
+			return nil, false
 		}
+		uid = uint(parsed)
+	} else {
+		return nil, false
 	}
-	return 0, false
+
+	ac := serviceauth.AuthContextFromClaims(claims)
+	return &authSession{UserID: uid, AuthTime: ac.AuthTime, AMR: ac.AMR}, true
 }
 
 func buildConsentURL(req *AuthorizeRequest, client *model.OAuthClient, decision *UserTenantAuthorizationDecision) string {
@@ -176,6 +240,21 @@ func buildConsentURL(req *AuthorizeRequest, client *model.OAuthClient, decision
 	if req.CodeChallengeMethod != "" {
 		q.Set("code_challenge_method", req.CodeChallengeMethod)
 	}
+	if req.Nonce != "" {
+		q.Set("nonce", req.Nonce)
+	}
+	if req.ResponseMode != "" {
+		q.Set("response_mode", req.ResponseMode)
+	}
+	if req.RequestURI != "" {
+		q.Set("request_uri", req.RequestURI)
+	}
+	if req.ACRValues != "" {
+		q.Set("acr_values", req.ACRValues)
+	}
+	if req.Claims != "" {
+		q.Set("claims", req.Claims)
+	}
 	if client != nil {
 		appTenantID := oauthServerService.resolveClientTenantID(client)
 		if appTenantID > 0 {
@@ -216,17 +295,10 @@ func buildConsentURL(req *AuthorizeRequest, client *model.OAuthClient, decision
 // POST /oauth/consent
 func ConsentHandler(c *fiber.Ctx) error {
 	// 检查用户是否已登录
-	userIDVal := c.Locals("userID")
-	if userIDVal == nil {
-		if uid, ok := tryUserIDFromAccessTokenCookie(c); ok {
-			c.Locals("userID", uid)
-			userIDVal = uid
-		}
-	}
-	if userIDVal == nil {
+	session := currentAuthSession(c)
+	if session == nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 	}
-	userID := userIDVal.(uint)
 
 	// 解析表单数据
 	clientID := c.FormValue("client_id")
@@ -235,25 +307,32 @@ func ConsentHandler(c *fiber.Ctx) error {
 	scope := c.FormValue("scope")
 	codeChallenge := c.FormValue("code_challenge")
 	codeChallengeMethod := c.FormValue("code_challenge_method")
+	nonce := c.FormValue("nonce")
 	selectedAccessToken := strings.TrimSpace(c.FormValue("selected_access_token"))
 	joinTenant := strings.EqualFold(strings.TrimSpace(c.FormValue("join_tenant")), "true") || c.FormValue("join_tenant") == "1"
 	action := c.FormValue("action") // "allow" 或 "deny"
 
 	if action != "allow" {
 		// 用户拒绝授权
-		return redirectWithErrorIfAllowed(c, clientID, redirectURI, "access_denied", state)
+		return authorizeErrorIfAllowed(c, &AuthorizeRequest{
+			ClientID:     clientID,
+			RedirectURI:  redirectURI,
+			State:        state,
+			ResponseMode: c.FormValue("response_mode"),
+		}, "access_denied")
 	}
 
 	if selectedAccessToken != "" {
-		selectedUserID, ok := parseUserIDFromJWT(selectedAccessToken)
-		if !ok || selectedUserID == 0 {
+		selected, ok := parseSessionFromJWT(selectedAccessToken)
+		if !ok || selected.UserID == 0 {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error":             "invalid_selected_session",
 				"error_description": "Selected account session is invalid or expired",
 			})
 		}
-		userID = selectedUserID
+		session = selected
 	}
+	userID := session.UserID
 
 	// 构建授权请求
 	req := &AuthorizeRequest{
@@ -264,55 +343,76 @@ func ConsentHandler(c *fiber.Ctx) error {
 		State:               state,
 		CodeChallenge:       codeChallenge,
 		CodeChallengeMethod: codeChallengeMethod,
+		Nonce:               nonce,
+		ResponseMode:        c.FormValue("response_mode"),
+		RequestURI:          c.FormValue("request_uri"),
+		ACRValues:           c.FormValue("acr_values"),
+		Claims:              c.FormValue("claims"),
 	}
 
-	// 验证请求
-	client, err := oauthServerService.ValidateAuthorizeRequest(req)
+	// 经 PAR 发起的授权以推送时的参数为准，忽略表单中的同名字段
+	req, err := oauthServerService.ResolveAuthorizeRequest(req, "", oidcIssuer(c))
 	if err != nil {
-		return redirectWithErrorIfAllowed(c, clientID, redirectURI, err.Error(), state)
+		return authorizeRequestError(c, err)
 	}
 
-	decision, err := oauthServerService.EvaluateUserTenantAuthorization(userID, client)
+	// 验证请求
+	client, err := oauthServerService.ValidateAuthorizeRequest(req)
 	if err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error":             "tenant_context_error",
-			"error_description": err.Error(),
-		})
+		return authorizeErrorIfAllowed(c, req, err.Error())
 	}
 
-	if !decision.Allowed {
-		if decision.JoinRequired {
-			if !joinTenant {
-				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-					"error":             "join_confirmation_required",
-					"error_description": "Global account must confirm tenant join before authorization",
-				})
-			}
-			if err := oauthServerService.EnsureUserTenantIdentity(userID, decision.TenantID, model.TenantRoleMember); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error":             "identity_join_failed",
-					"error_description": "Failed to join tenant identity",
-				})
-			}
-		} else {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error":             "tenant_mismatch",
-				"error_description": "User does not belong to the tenant of this application",
-			})
-		}
+	if ok, resp := enforceConsentTenant(c, userID, client, joinTenant); !ok {
+		return resp
 	}
 
 	// 生成授权码
-	code, err := oauthServerService.GenerateAuthorizationCode(userID, req, client)
+	code, err := oauthServerService.issueAuthorizationCode(session, req, client)
 	if err != nil {
-		return redirectWithError(c, redirectURI, "server_error", state)
+		return authorizeErrorResponse(c, req, "server_error")
 	}
 
 	// 记录审计日志
-	aduit.LogAudit(userID, "OAuth2授权", "oauth_client", clientID, c.IP(), c.Get("User-Agent"))
+	aduit.LogAudit(userID, "OAuth2授权", "oauth_client", req.ClientID, c.IP(), c.Get("User-Agent"))
 
 	// 重定向回客户端
-	return redirectWithCode(c, redirectURI, code, state)
+	return authorizeCodeResponse(c, req, code)
+}
+
+// enforceConsentTenant 校验用户是否可授权该客户端所属租户的应用；
+// 全局账号在确认加入（joinTenant）后自动加入租户。校验失败时返回 false 及已写入的错误响应。
+func enforceConsentTenant(c *fiber.Ctx, userID uint, client *model.OAuthClient, joinTenant bool) (bool, error) {
+	decision, err := oauthServerService.EvaluateUserTenantAuthorization(userID, client)
+	if err != nil {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":             "tenant_context_error",
+			"error_description": err.Error(),
+		})
+	}
+
+	if decision.Allowed {
+		return true, nil
+	}
+
+	if !decision.JoinRequired {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":             "tenant_mismatch",
+			"error_description": "User does not belong to the tenant of this application",
+		})
+	}
+	if !joinTenant {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":             "join_confirmation_required",
+			"error_description": "Global account must confirm tenant join before authorization",
+		})
+	}
+	if err := oauthServerService.EnsureUserTenantIdentity(userID, decision.TenantID, model.TenantRoleMember); err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":             "identity_join_failed",
+			"error_description": "Failed to join tenant identity",
+		})
+	}
+	return true, nil
 }
 
 // TokenHandler 处理OAuth2令牌请求
@@ -325,6 +425,10 @@ func TokenHandler(c *fiber.Ctx) error {
 		return handleAuthorizationCodeGrant(c)
 	case "refresh_token":
 		return handleRefreshTokenGrant(c)
+	case "client_credentials":
+		return handleClientCredentialsGrant(c)
+	case deviceCodeGrantType:
+		return handleDeviceCodeGrant(c)
 	case "urn:ietf:params:oauth:grant-type:token-exchange":
 		return handleTokenExchangeGrant(c)
 	default:
@@ -344,15 +448,16 @@ func handleAuthorizationCodeGrant(c *fiber.Ctx) error {
 		RedirectURI:  c.FormValue("redirect_uri"),
 		ClientID:     c.FormValue("client_id"),
 		CodeVerifier: c.FormValue("code_verifier"),
+		Issuer:       oidcIssuer(c),
 	}
 
 	// 获取客户端认证信息（Basic Auth或表单参数）
-	clientID, clientSecret := extractClientCredentials(c)
-	if clientID == "" {
-		clientID = req.ClientID
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" {
+		creds.ClientID = req.ClientID
 	}
 
-	if clientID == "" || clientSecret == "" {
+	if creds.ClientID == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":             "invalid_client",
 			"error_description": "Client authentication failed",
@@ -360,8 +465,11 @@ func handleAuthorizationCodeGrant(c *fiber.Ctx) error {
 	}
 
 	// 交换令牌
-	tokenResponse, err := oauthServerService.ExchangeCodeForToken(req, clientID, clientSecret)
+	tokenResponse, err := oauthServerService.ExchangeCodeForToken(req, creds)
 	if err != nil {
+		if err.Error() == "invalid_client" {
+			return oauthInvalidClient(c)
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":             err.Error(),
 			"error_description": "Authorization code exchange failed",
@@ -376,8 +484,8 @@ func handleRefreshTokenGrant(c *fiber.Ctx) error {
 	refreshToken := c.FormValue("refresh_token")
 
 	// 获取客户端认证信息
-	clientID, clientSecret := extractClientCredentials(c)
-	if clientID == "" || clientSecret == "" {
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":             "invalid_client",
 			"error_description": "Client authentication failed",
@@ -385,8 +493,16 @@ func handleRefreshTokenGrant(c *fiber.Ctx) error {
 	}
 
 	// 刷新令牌
-	tokenResponse, err := oauthServerService.RefreshAccessToken(refreshToken, clientID, clientSecret)
+	tokenResponse, err := oauthServerService.RefreshAccessToken(refreshToken, creds, oidcIssuer(c))
 	if err != nil {
+		if err.Error() == "invalid_client" {
+			return oauthInvalidClient(c)
+		}
+		var reuse *RefreshTokenReuseError
+		if errors.As(err, &reuse) {
+			log.Printf("[OAuth] refresh token reuse detected: client=%s user=%d family=%s", reuse.ClientID, reuse.UserID, reuse.FamilyID)
+			aduit.LogAudit(reuse.UserID, "OAuth刷新令牌重放(令牌族已撤销)", "oauth_refresh_token_family", reuse.FamilyID, c.IP(), c.Get("User-Agent"))
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":             err.Error(),
 			"error_description": "Refresh token failed",
@@ -461,7 +577,20 @@ func IntrospectHandler(c *fiber.Ctx) error {
 		"scope":     oauthToken.Scopes,
 		"exp":       oauthToken.ExpiresAt.Unix(),
 		"iat":       oauthToken.CreatedAt.Unix(),
-		"sub":       oauthToken.UserID,
+	}
+
+	// client_credentials 令牌的主体是客户端本身；用户令牌的 sub 与 ID Token、UserInfo 一致
+	if oauthToken.IsClientToken() {
+		delete(resp, "username")
+		resp["sub"] = oauthToken.ClientID
+	} else {
+		sub, err := oauthServerService.subjects.Subject(&oauthToken.Client, oauthToken.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "server_error",
+			})
+		}
+		resp["sub"] = sub
 	}
 
 	// RFC 8693 §4.1 — include actor information for exchanged tokens
@@ -523,8 +652,21 @@ func RevokeHandler(c *fiber.Ctx) error {
 
 // buildLoginURLWithTenant 构建租户特定的登录URL，包含OAuth2参数
 func buildLoginURLWithTenant(c *fiber.Ctx, req *AuthorizeRequest, client *model.OAuthClient) string {
+	// 构建原始OAuth2授权URL作为重定向参数
+	originalURL := "/api/v1/oauth/authorize?" + c.Context().QueryArgs().String()
+
+	loginURL := tenantLoginURL(oauthServerService.resolveClientTenantID(client), originalURL)
+	// login_hint 用于预填登录页的账号
+	if hint := strings.TrimSpace(req.LoginHint); hint != "" {
+		loginURL += "&login_hint=" + url.QueryEscape(hint)
+	}
+	return loginURL
+}
+
+// tenantLoginURL 构建租户登录页地址 /auth/tenant/{tenant_code}/login，登录后返回 returnPath；
+// 找不到租户 code 时使用平台登录页
+func tenantLoginURL(tenantID uint, returnPath string) string {
 	var tenantCode string
-	tenantID := oauthServerService.resolveClientTenantID(client)
 	if tenantID > 0 {
 		var tenant model.Tenant
 		if err := oauthServerService.db.Select("id", "code").First(&tenant, tenantID).Error; err == nil {
@@ -534,7 +676,6 @@ func buildLoginURLWithTenant(c *fiber.Ctx, req *AuthorizeRequest, client *model.
 
 	uiBaseURL := strings.TrimRight(config.Get().UI.BaseURL, "/")
 
-	// 构建租户登录URL：/auth/tenant/{tenant_code}/login
 	var loginURL string
 	if tenantCode != "" {
 		loginURL = uiBaseURL + "/auth/tenant/" + tenantCode + "/login"
@@ -542,11 +683,7 @@ func buildLoginURLWithTenant(c *fiber.Ctx, req *AuthorizeRequest, client *model.
 		// 如果没有租户code，使用平台登录
 		loginURL = uiBaseURL + "/login"
 	}
-
-	// 构建原始OAuth2授权URL作为重定向参数
-	originalURL := "/api/v1/oauth/authorize?" + c.Context().QueryArgs().String()
-
-	return loginURL + "?redirect=" + url.QueryEscape(originalURL)
+	return loginURL + "?redirect=" + url.QueryEscape(returnPath)
 }
 
 // buildLoginURL 构建登录URL，包含OAuth2参数（保留向后兼容性）
@@ -563,35 +700,6 @@ func buildLoginURL(c *fiber.Ctx, req *AuthorizeRequest) string {
 	return loginURL + "?redirect=" + url.QueryEscape(originalURL)
 }
 
-// redirectWithError 带错误信息重定向
-func redirectWithError(c *fiber.Ctx, redirectURI, errorCode, state string) error {
-	u, err := url.Parse(redirectURI)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_redirect_uri"})
-	}
-
-	q := u.Query()
-	q.Set("error", errorCode)
-	if state != "" {
-		q.Set("state", state)
-	}
-
-	u.RawQuery = q.Encode()
-	return c.Redirect(u.String(), http.StatusFound)
-}
-
-// redirectWithErrorIfAllowed redirects only when redirect_uri is registered on the client.
-// Per OAuth2 security recommendations, invalid client/redirect_uri errors must not redirect.
-func redirectWithErrorIfAllowed(c *fiber.Ctx, clientID, redirectURI, errorCode, state string) error {
-	if !isRedirectURIAllowedForClient(clientID, redirectURI) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":             errorCode,
-			"error_description": "invalid redirect_uri",
-		})
-	}
-	return redirectWithError(c, redirectURI, errorCode, state)
-}
-
 func isRedirectURIAllowedForClient(clientID, redirectURI string) bool {
 	clientID = strings.TrimSpace(clientID)
 	redirectURI = strings.TrimSpace(redirectURI)
@@ -609,45 +717,6 @@ func isRedirectURIAllowedForClient(clientID, redirectURI string) bool {
 	return client.ValidateRedirectURI(redirectURI)
 }
 
-// redirectWithCode 带授权码重定向
-func redirectWithCode(c *fiber.Ctx, redirectURI, code, state string) error {
-	u, err := url.Parse(redirectURI)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_redirect_uri"})
-	}
-
-	q := u.Query()
-	q.Set("code", code)
-	if state != "" {
-		q.Set("state", state)
-	}
-
-	u.RawQuery = q.Encode()
-	return c.Redirect(u.String(), http.StatusFound)
-}
-
-// extractClientCredentials 提取客户端凭证（支持Basic Auth和表单参数）
-func extractClientCredentials(c *fiber.Ctx) (clientID, clientSecret string) {
-	// 优先从 Authorization: Basic 获取（OAuth2 标准方式）
-	if basicID, basicSecret, ok := parseBasicAuthCredentials(c.Get("Authorization")); ok {
-		return basicID, basicSecret
-	}
-
-	// 向后兼容：支持自定义头 client_id/client_secret
-	clientID = strings.TrimSpace(c.Get("client_id"))
-	clientSecret = c.Get("client_secret")
-
-	if clientID != "" && clientSecret != "" {
-		return clientID, clientSecret
-	}
-
-	// 尝试从表单参数获取
-	clientID = strings.TrimSpace(c.FormValue("client_id"))
-	clientSecret = c.FormValue("client_secret")
-
-	return clientID, clientSecret
-}
-
 func parseBasicAuthCredentials(authHeader string) (clientID, clientSecret string, ok bool) {
 	parts := strings.SplitN(strings.TrimSpace(authHeader), " ", 2)
 	if len(parts) != 2 || !strings.EqualFold(parts[0], "Basic") {
@@ -7,20 +7,28 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/accesstoken"
+	"basaltpass-backend/internal/service/claimmapping"
+	"basaltpass-backend/internal/service/signingkey"
+	"basaltpass-backend/internal/service/subject"
+	"basaltpass-backend/internal/utils"
 
 	"gorm.io/gorm"
 )
 
 // OAuthServerService OAuth2授权服务器服务
 type OAuthServerService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	keys     *signingkey.Service
+	tokens   *accesstoken.Service
+	subjects *subject.Service
+	claims   *claimmapping.Service
 }
 
 type UserTenantAuthorizationDecision struct {
@@ -32,8 +40,14 @@ type UserTenantAuthorizationDecision struct {
 
 // NewOAuthServerService 创建新的OAuth2服务器服务
 func NewOAuthServerService() *OAuthServerService {
+	db := common.DB()
+	keys := signingkey.NewService(db)
 	return &OAuthServerService{
-		db: common.DB(),
+		db:       db,
+		keys:     keys,
+		tokens:   accesstoken.NewService(keys),
+		subjects: subject.NewService(db),
+		claims:   claimmapping.NewService(db),
 	}
 }
 
@@ -46,6 +60,18 @@ type AuthorizeRequest struct {
 	State               string `form:"state"`
 	CodeChallenge       string `form:"code_challenge"`        // PKCE
 	CodeChallengeMethod string `form:"code_challenge_method"` // PKCE
+	Nonce               string `form:"nonce"`                 // OIDC
+	ResponseMode        string `form:"response_mode"`         // query / fragment / form_post / web_message
+	RequestURI          string `form:"request_uri"`           // PAR（RFC 9126）
+
+	// OIDC 认证请求参数（OIDC Core §3.1.2.1）
+	Prompt    string `form:"prompt"`     // none / login / consent / select_account，空格分隔
+	MaxAge    *int   `form:"max_age"`    // 距上次认证的最长秒数，超过则要求重新登录
+	LoginHint string `form:"login_hint"` // 预填托管登录页的账号
+	ACRValues string `form:"acr_values"` // 请求的认证等级，按优先顺序空格分隔
+	Claims    string `form:"claims"`     // 按声明请求返回内容（OIDC Core §5.5），JSON 对象
+
+	pushed bool // 参数取自 /oauth/par 推送的请求
 }
 
 // TokenRequest 令牌请求结构
@@ -55,6 +81,9 @@ type TokenRequest struct {
 	RedirectURI  string `form:"redirect_uri"`
 	ClientID     string `form:"client_id"`
 	CodeVerifier string `form:"code_verifier"` // PKCE
+
+	// Issuer 签发id_token时使用的iss，由处理器根据请求地址填充
+	Issuer string `form:"-"`
 }
 
 // TokenResponse 令牌响应结构
@@ -64,6 +93,7 @@ type TokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
 }
 
 // AuthorizeResponse 授权响应结构
@@ -97,47 +127,71 @@ func (s *OAuthServerService) ValidateAuthorizeRequest(req *AuthorizeRequest) (*m
 	}
 
 	// 4. 验证scope（可选）
-	if req.Scope != "" {
-		requestedScopes := strings.Split(req.Scope, " ")
-		allowedScopes := client.GetScopeList()
-
-		for _, reqScope := range requestedScopes {
-			found := false
-			for _, allowedScope := range allowedScopes {
-				if strings.TrimSpace(reqScope) == strings.TrimSpace(allowedScope) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, errors.New("invalid_scope")
-			}
-		}
+	if err := validateRequestedScopes(&client, req.Scope); err != nil {
+		return nil, err
+	}
+
+	// 5. 验证PKCE：公共客户端必须使用 S256，oauth.pkce_required 或租户设置要求携带 code_challenge
+	if err := s.validatePKCEChallenge(&client, req); err != nil {
+		return nil, err
+	}
+
+	// 6. 客户端要求 PAR 时，只接受经 /oauth/par 推送的请求
+	if client.RequirePushedAuthorizationRequests && !req.pushed {
+		return nil, errors.New("invalid_request")
+	}
+
+	// 7. 校验 prompt 与 max_age
+	if err := validateOIDCParameters(req); err != nil {
+		return nil, err
+	}
+
+	// 8. 校验 response_mode
+	if err := validateResponseMode(&client, req); err != nil {
+		return nil, err
 	}
 
 	return &client, nil
 }
 
-// ValidateClientCredentials verifies OAuth client_id/client_secret.
-func (s *OAuthServerService) ValidateClientCredentials(clientID string, clientSecret string) (*model.OAuthClient, error) {
-	clientID = strings.TrimSpace(clientID)
-	if clientID == "" || clientSecret == "" {
-		return nil, errors.New("invalid_client")
+// validateRequestedScopes 校验请求的scope（空格分隔）均在客户端允许范围内
+func validateRequestedScopes(client *model.OAuthClient, scope string) error {
+	if scope == "" {
+		return nil
 	}
 
-	var client model.OAuthClient
-	if err := s.db.Where("client_id = ? AND is_active = ?", clientID, true).First(&client).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid_client")
+	requestedScopes := strings.Split(scope, " ")
+	allowedScopes := client.GetScopeList()
+
+	for _, reqScope := range requestedScopes {
+		found := false
+		for _, allowedScope := range allowedScopes {
+			if strings.TrimSpace(reqScope) == strings.TrimSpace(allowedScope) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("invalid_scope")
 		}
+	}
+	return nil
+}
+
+// ValidateClientCredentials authenticates a confidential client by client_secret or
+// private_key_jwt assertion. Public clients cannot authenticate here.
+func (s *OAuthServerService) ValidateClientCredentials(creds ClientCredentials) (*model.OAuthClient, error) {
+	client, err := s.authenticateClient(creds)
+	if err != nil {
 		return nil, err
 	}
 
-	if !client.VerifyClientSecret(clientSecret) {
+	// 公共客户端没有凭证，不能调用需要客户端认证的端点
+	if client.IsPublic() {
 		return nil, errors.New("invalid_client")
 	}
 
-	return &client, nil
+	return client, nil
 }
 
 // resolveClientTenantID resolves tenant ownership for an OAuth client.
@@ -187,6 +241,11 @@ func (s *OAuthServerService) resolveClientTenantID(client *model.OAuthClient) ui
 
 // GenerateAuthorizationCode 生成授权码
 func (s *OAuthServerService) GenerateAuthorizationCode(userID uint, req *AuthorizeRequest, client *model.OAuthClient) (string, error) {
+	return s.issueAuthorizationCode(&authSession{UserID: userID}, req, client)
+}
+
+// issueAuthorizationCode 为登录会话生成授权码，并记录会话的 auth_time、acr 与 amr
+func (s *OAuthServerService) issueAuthorizationCode(session *authSession, req *AuthorizeRequest, client *model.OAuthClient) (string, error) {
 	// 生成授权码
 	codeBytes := make([]byte, 32)
 	if _, err := rand.Read(codeBytes); err != nil {
@@ -197,11 +256,22 @@ func (s *OAuthServerService) GenerateAuthorizationCode(userID uint, req *Authori
 	// 获取租户ID（支持历史脏数据的兜底恢复）
 	tenantID := s.resolveClientTenantID(client)
 
+	// 会话未记录认证时间（旧令牌）时以当前时间为准
+	authTime := session.AuthTime
+	if authTime.IsZero() {
+		authTime = time.Now()
+	}
+	// 无法满足请求的 acr_values 时记录会话实际达到的等级，由客户端自行判断
+	acr := resolveACR(req.ACRValues, session.AMR)
+	if acr == "" {
+		acr = resolveACR("", session.AMR)
+	}
+
 	// 创建授权码记录（包含AppID和TenantID）
 	authCode := &model.OAuthAuthorizationCode{
-		Code:                code,
+		Code:                utils.HashOAuthToken(code),
 		ClientID:            req.ClientID,
-		UserID:              userID,
+		UserID:              session.UserID,
 		TenantID:            tenantID,
 		AppID:               client.AppID,
 		RedirectURI:         req.RedirectURI,
@@ -210,12 +280,20 @@ func (s *OAuthServerService) GenerateAuthorizationCode(userID uint, req *Authori
 		CodeChallengeMethod: req.CodeChallengeMethod,
 		ExpiresAt:           time.Now().Add(10 * time.Minute), // 授权码10分钟有效期
 		Used:                false,
+		Nonce:               req.Nonce,
+		AuthTime:            &authTime,
+		ACR:                 acr,
+		AMR:                 strings.Join(session.AMR, " "),
+		Claims:              req.Claims,
 	}
 
 	if err := s.db.Create(authCode).Error; err != nil {
 		return "", err
 	}
 
+	// request_uri 只能兑换一次授权码（RFC 9126 §4）
+	s.consumePushedRequest(req.RequestURI)
+
 	return code, nil
 }
 
@@ -236,7 +314,7 @@ func (s *OAuthServerService) HasAppUserAuthorization(appID, userID uint) (bool,
 }
 
 // ExchangeCodeForToken 用授权码换取访问令牌
-func (s *OAuthServerService) ExchangeCodeForToken(req *TokenRequest, clientID string, clientSecret string) (*TokenResponse, error) {
+func (s *OAuthServerService) ExchangeCodeForToken(req *TokenRequest, creds ClientCredentials) (*TokenResponse, error) {
 	// 1. 验证grant_type
 	if req.GrantType != "authorization_code" {
 		return nil, errors.New("unsupported_grant_type")
@@ -244,7 +322,7 @@ func (s *OAuthServerService) ExchangeCodeForToken(req *TokenRequest, clientID st
 
 	// 2. 查找授权码
 	var authCode model.OAuthAuthorizationCode
-	if err := s.db.Where("code = ? AND used = ?", req.Code, false).First(&authCode).Error; err != nil {
+	if err := s.db.Where("code = ? AND used = ?", utils.HashOAuthToken(req.Code), false).First(&authCode).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("invalid_grant")
 		}
@@ -257,19 +335,19 @@ func (s *OAuthServerService) ExchangeCodeForToken(req *TokenRequest, clientID st
 	}
 
 	// 4. 验证客户端ID匹配
-	if authCode.ClientID != clientID {
+	if authCode.ClientID != creds.ClientID {
 		return nil, errors.New("invalid_client")
 	}
 
-	// 5. 查找并验证客户端
-	var client model.OAuthClient
-	if err := s.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
-		return nil, errors.New("invalid_client")
+	// 5. 按登记的认证方式验证客户端（公共客户端无密钥）
+	client, err := s.authenticateClient(creds)
+	if err != nil {
+		return nil, err
 	}
 
-	// 6. 验证客户端密钥
-	if !client.VerifyClientSecret(clientSecret) {
-		return nil, errors.New("invalid_client")
+	// 6. 公共客户端的授权码必须绑定 S256 PKCE
+	if client.IsPublic() && authCode.CodeChallengeMethod != "S256" {
+		return nil, errors.New("invalid_grant")
 	}
 
 	// 7. 验证redirect_uri
@@ -300,118 +378,189 @@ func (s *OAuthServerService) ExchangeCodeForToken(req *TokenRequest, clientID st
 		}
 	}
 
-	// 9. 生成访问令牌
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
+	// 9. 标记授权码为已使用
+	if err := s.db.Model(&authCode).Update("used", true).Error; err != nil {
 		return nil, err
 	}
-	accessToken := hex.EncodeToString(tokenBytes)
 
-	// 10. 生成刷新令牌
-	refreshTokenBytes := make([]byte, 32)
-	if _, err := rand.Read(refreshTokenBytes); err != nil {
+	// 10. 签发令牌
+	resp, err := s.issueUserTokens(client, &userTokenGrant{
+		ClientID: authCode.ClientID,
+		UserID:   authCode.UserID,
+		TenantID: authCode.TenantID,
+		AppID:    authCode.AppID,
+		Scopes:   authCode.Scopes,
+		Nonce:    authCode.Nonce,
+		AuthTime: authCode.AuthTime,
+		ACR:      authCode.ACR,
+		AMR:      authCode.AMR,
+		Claims:   authCode.Claims,
+	}, req.Issuer)
+	if err != nil {
 		return nil, err
 	}
-	refreshToken := hex.EncodeToString(refreshTokenBytes)
 
-	// 11. 保存访问令牌
-	oauthToken := &model.OAuthAccessToken{
-		Token:     accessToken,
-		ClientID:  authCode.ClientID,
-		UserID:    authCode.UserID,
-		TenantID:  authCode.TenantID,
-		AppID:     authCode.AppID,
-		Scopes:    authCode.Scopes,
-		ExpiresAt: time.Now().Add(1 * time.Hour), // 访问令牌1小时有效期
-	}
+	// 11. 更新客户端最后使用时间
+	now := time.Now()
+	s.db.Model(&client).Update("last_used_at", &now)
+
+	return resp, nil
+}
+
+// userTokenGrant 用户授权后签发令牌所需的上下文（授权码、设备码等授权类型共用）
+type userTokenGrant struct {
+	ClientID string
+	UserID   uint
+	TenantID uint
+	AppID    uint
+	Scopes   string
+	Nonce    string
+	AuthTime *time.Time
+	ACR      string
+	AMR      string
+	Claims   string // claims 请求参数
+}
 
-	if err := s.db.Create(oauthToken).Error; err != nil {
+// issueUserTokens 为已获用户授权的请求签发访问令牌、刷新令牌，并在scope包含openid时签发id_token
+func (s *OAuthServerService) issueUserTokens(client *model.OAuthClient, g *userTokenGrant, issuer string) (*TokenResponse, error) {
+	// 1. 生成访问令牌
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
 		return nil, err
 	}
+	accessToken := hex.EncodeToString(tokenBytes)
 
-	// 11.1 保存刷新令牌
-	refreshTokenModel := &model.OAuthRefreshToken{
-		Token:         refreshToken,
-		ClientID:      authCode.ClientID,
-		UserID:        authCode.UserID,
-		TenantID:      authCode.TenantID,
-		AppID:         authCode.AppID,
-		Scopes:        authCode.Scopes,
-		ExpiresAt:     time.Now().Add(7 * 24 * time.Hour), // 刷新令牌7天有效期
-		AccessTokenID: &oauthToken.ID,
+	// 2. 保存访问令牌
+	oauthToken := &model.OAuthAccessToken{
+		Token:     accessToken,
+		ClientID:  g.ClientID,
+		UserID:    g.UserID,
+		TenantID:  g.TenantID,
+		AppID:     g.AppID,
+		Scopes:    g.Scopes,
+		Claims:    g.Claims,
+		ExpiresAt: time.Now().Add(1 * time.Hour), // 访问令牌1小时有效期
 	}
 
-	if err := s.db.Create(refreshTokenModel).Error; err != nil {
+	accessToken, err := s.storeAccessToken(client, oauthToken, &accessTokenContext{
+		Issuer:   issuer,
+		AuthTime: g.AuthTime,
+		ACR:      g.ACR,
+		AMR:      strings.Fields(g.AMR),
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// 12. 标记授权码为已使用
-	if err := s.db.Model(&authCode).Update("used", true).Error; err != nil {
-		return nil, err
+	// 3. 保存刷新令牌（开启新的令牌族），未启用刷新令牌时跳过
+	var refreshToken string
+	if refreshTokensEnabled() {
+		refreshToken, err = s.createRefreshToken(&model.OAuthRefreshToken{
+			ClientID:      g.ClientID,
+			UserID:        g.UserID,
+			TenantID:      g.TenantID,
+			AppID:         g.AppID,
+			Scopes:        g.Scopes,
+			AccessTokenID: &oauthToken.ID,
+			AuthTime:      g.AuthTime,
+			ACR:           g.ACR,
+			AMR:           g.AMR,
+			Claims:        g.Claims,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// 13. 记录用户对应用的授权关系
-	if authCode.AppID > 0 {
+	// 4. 记录用户对应用的授权关系
+	if g.AppID > 0 {
 		appUserService := app_user.NewAppUserService(s.db)
-		if err := appUserService.RecordUserAppAuthorization(authCode.AppID, authCode.UserID, authCode.Scopes); err != nil {
+		if err := appUserService.RecordUserAppAuthorization(g.AppID, g.UserID, g.Scopes); err != nil {
 			// 记录日志但不失败，因为这不是关键功能
 			// TODO: 可以考虑添加日志记录
 			_ = err
 		}
 	}
 
-	// 14. 更新客户端最后使用时间
-	now := time.Now()
-	s.db.Model(&client).Update("last_used_at", &now)
-
-	return &TokenResponse{
+	resp := &TokenResponse{
 		AccessToken:  accessToken,
 		TokenType:    "Bearer",
 		ExpiresIn:    3600, // 1小时
 		RefreshToken: refreshToken,
-		Scope:        authCode.Scopes,
-	}, nil
+		Scope:        g.Scopes,
+	}
+
+	// 5. OIDC：授权范围包含openid时签发id_token
+	if scopeListContains(g.Scopes, "openid") {
+		idToken, err := s.IssueIDToken(&idTokenParams{
+			Issuer:      issuer,
+			ClientID:    g.ClientID,
+			UserID:      g.UserID,
+			Scopes:      g.Scopes,
+			Nonce:       g.Nonce,
+			AuthTime:    g.AuthTime,
+			ACR:         g.ACR,
+			AMR:         strings.Fields(g.AMR),
+			AccessToken: accessToken,
+			Claims:      g.Claims,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.IDToken = idToken
+	}
+
+	return resp, nil
 }
 
 // RefreshAccessToken 刷新访问令牌
-func (s *OAuthServerService) RefreshAccessToken(refreshToken string, clientID string, clientSecret string) (*TokenResponse, error) {
+// 每次刷新都会轮换刷新令牌；已轮换的令牌再次出现时撤销整个令牌族并返回 RefreshTokenReuseError。
+// issuer 用于在scope包含openid时重新签发id_token。
+func (s *OAuthServerService) RefreshAccessToken(refreshToken string, creds ClientCredentials, issuer string) (*TokenResponse, error) {
+	if !refreshTokensEnabled() {
+		return nil, errors.New("unsupported_grant_type")
+	}
+
 	// 1. 查找刷新令牌
 	var refreshTokenModel model.OAuthRefreshToken
-	if err := s.db.Where("token = ? AND client_id = ?", refreshToken, clientID).First(&refreshTokenModel).Error; err != nil {
+	if err := s.db.Where("token = ? AND client_id = ?", utils.HashOAuthToken(refreshToken), creds.ClientID).First(&refreshTokenModel).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("invalid_grant")
 		}
 		return nil, err
 	}
 
-	// 1.1 检查刷新令牌是否过期
-	if refreshTokenModel.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("expired_token")
+	// 2. 验证客户端
+	client, err := s.authenticateClient(creds)
+	if err != nil {
+		return nil, err
 	}
 
-	// 2. 验证客户端
-	var client model.OAuthClient
-	if err := s.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
-		return nil, errors.New("invalid_client")
+	// 3. 重放检测：已轮换的令牌再次使用，说明令牌可能已泄露
+	if refreshTokenModel.IsRotated() {
+		return nil, s.rejectReusedRefreshToken(&refreshTokenModel)
 	}
 
-	if !client.VerifyClientSecret(clientSecret) {
-		return nil, errors.New("invalid_client")
+	// 3.1 检查刷新令牌是否过期
+	if refreshTokenModel.IsExpired() {
+		return nil, errors.New("expired_token")
 	}
 
-	// 3. 生成新的访问令牌
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
+	// 3.2 标记旧令牌已轮换；并发请求中只有一个能成功，其余按重放处理
+	rotated, err := s.markRefreshTokenRotated(&refreshTokenModel)
+	if err != nil {
 		return nil, err
 	}
-	newAccessToken := hex.EncodeToString(tokenBytes)
+	if !rotated {
+		return nil, s.rejectReusedRefreshToken(&refreshTokenModel)
+	}
 
-	// 4. 生成新的刷新令牌
-	refreshTokenBytes := make([]byte, 32)
-	if _, err := rand.Read(refreshTokenBytes); err != nil {
+	// 4. 生成新的访问令牌
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
 		return nil, err
 	}
-	newRefreshTokenStr := hex.EncodeToString(refreshTokenBytes)
+	newAccessToken := hex.EncodeToString(tokenBytes)
 
 	// 5. 仅删除与本 refresh token 直接关联的那一个 access token，
 	//    避免误杀同一用户在其他浏览器/设备上的并发会话。
@@ -422,51 +571,81 @@ func (s *OAuthServerService) RefreshAccessToken(refreshToken string, clientID st
 	// 6. 创建新的访问令牌
 	newToken := model.OAuthAccessToken{
 		Token:     newAccessToken,
-		ClientID:  clientID,
+		ClientID:  client.ClientID,
 		UserID:    refreshTokenModel.UserID,
 		TenantID:  refreshTokenModel.TenantID,
 		AppID:     refreshTokenModel.AppID,
 		Scopes:    refreshTokenModel.Scopes,
+		Claims:    refreshTokenModel.Claims,
 		ExpiresAt: time.Now().Add(time.Hour),
 	}
 
-	if err := s.db.Create(&newToken).Error; err != nil {
-		return nil, err
-	}
-
-	// 7. 删除旧的刷新令牌
-	if err := s.db.Delete(&refreshTokenModel).Error; err != nil {
+	newAccessToken, err = s.storeAccessToken(client, &newToken, &accessTokenContext{
+		Issuer:   issuer,
+		AuthTime: refreshTokenModel.AuthTime,
+		ACR:      refreshTokenModel.ACR,
+		AMR:      refreshTokenModel.GetAMRList(),
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// 8. 创建新的刷新令牌
-	newRefreshTokenModel := model.OAuthRefreshToken{
-		Token:     newRefreshTokenStr,
-		ClientID:  clientID,
-		UserID:    refreshTokenModel.UserID,
-		TenantID:  refreshTokenModel.TenantID,
-		AppID:     refreshTokenModel.AppID,
-		Scopes:    refreshTokenModel.Scopes,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 30), // 30天
-	}
-
-	if err := s.db.Create(&newRefreshTokenModel).Error; err != nil {
+	// 7. 在同一令牌族中签发新的刷新令牌
+	newRefreshTokenStr, err := s.createRefreshToken(&model.OAuthRefreshToken{
+		ClientID:      client.ClientID,
+		UserID:        refreshTokenModel.UserID,
+		TenantID:      refreshTokenModel.TenantID,
+		AppID:         refreshTokenModel.AppID,
+		Scopes:        refreshTokenModel.Scopes,
+		AccessTokenID: &newToken.ID,
+		AuthTime:      refreshTokenModel.AuthTime,
+		ACR:           refreshTokenModel.ACR,
+		AMR:           refreshTokenModel.AMR,
+		Claims:        refreshTokenModel.Claims,
+	}, &refreshTokenModel)
+	if err != nil {
 		return nil, err
 	}
 
-	return &TokenResponse{
+	resp := &TokenResponse{
 		AccessToken:  newAccessToken,
 		TokenType:    "Bearer",
 		ExpiresIn:    3600,
 		RefreshToken: newRefreshTokenStr,
 		Scope:        refreshTokenModel.Scopes,
-	}, nil
+	}
+
+	// 8. OIDC：刷新时重新签发id_token（不携带nonce，OIDC Core §12.2）
+	if scopeListContains(refreshTokenModel.Scopes, "openid") {
+		idToken, err := s.IssueIDToken(&idTokenParams{
+			Issuer:      issuer,
+			ClientID:    client.ClientID,
+			UserID:      refreshTokenModel.UserID,
+			Scopes:      refreshTokenModel.Scopes,
+			AuthTime:    refreshTokenModel.AuthTime,
+			ACR:         refreshTokenModel.ACR,
+			AMR:         refreshTokenModel.GetAMRList(),
+			AccessToken: newAccessToken,
+			Claims:      refreshTokenModel.Claims,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.IDToken = idToken
+	}
+
+	return resp, nil
 }
 
 // ValidateAccessToken 验证访问令牌
 func (s *OAuthServerService) ValidateAccessToken(token string) (*model.OAuthAccessToken, error) {
+	key := s.tokens.StorageKey(token)
+	if key == "" {
+		return nil, errors.New("invalid_token")
+	}
+
 	var oauthToken model.OAuthAccessToken
-	if err := s.db.Preload("User").Preload("Client").Where("token = ?", token).First(&oauthToken).Error; err != nil {
+	if err := s.db.Preload("User").Preload("Client").Where("token = ?", key).First(&oauthToken).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("invalid_token")
 		}
@@ -487,6 +666,11 @@ func (s *OAuthServerService) GetUserInfo(token string) (*UserInfoResponse, error
 		return nil, err
 	}
 
+	// 客户端令牌没有用户主体
+	if oauthToken.IsClientToken() {
+		return nil, errors.New("invalid_token")
+	}
+
 	// 检查scope是否包含openid或profile
 	scopes := oauthToken.GetScopeList()
 	hasProfileScope := false
@@ -511,8 +695,12 @@ func (s *OAuthServerService) GetUserInfo(token string) (*UserInfoResponse, error
 	}
 
 	user := oauthToken.User
+	sub, err := s.subjects.Subject(&oauthToken.Client, user.ID)
+	if err != nil {
+		return nil, err
+	}
 	response := &UserInfoResponse{
-		Sub:           fmt.Sprintf("%d", user.ID),
+		Sub:           sub,
 		Name:          user.Nickname,
 		Email:         user.Email,
 		EmailVerified: user.EmailVerified,
@@ -522,6 +710,12 @@ func (s *OAuthServerService) GetUserInfo(token string) (*UserInfoResponse, error
 		UpdatedAt:     user.UpdatedAt.Unix(),
 	}
 
+	// 附加应用声明映射中输出到 userinfo 的声明
+	response.Claims, err = s.customClaims(oauthToken.TenantID, oauthToken.AppID, user.ID, oauthToken.Scopes, oauthToken.Claims, model.ClaimTargetUserInfo)
+	if err != nil {
+		return nil, err
+	}
+
 	return response, nil
 }
 
@@ -535,6 +729,9 @@ type UserInfoResponse struct {
 	PhoneVerified bool   `json:"phone_number_verified"`  // 手机号是否验证
 	Picture       string `json:"picture,omitempty"`      // 头像
 	UpdatedAt     int64  `json:"updated_at"`             // 更新时间
+
+	// Claims 应用声明映射附加的自定义声明，序列化时与标准声明平铺输出
+	Claims map[string]interface{} `json:"-"`
 }
 
 // BuildAuthorizeURL 构建授权URL
@@ -558,6 +755,9 @@ func (s *OAuthServerService) BuildAuthorizeURL(baseURL string, req *AuthorizeReq
 		q.Set("code_challenge", req.CodeChallenge)
 		q.Set("code_challenge_method", req.CodeChallengeMethod)
 	}
+	if req.Nonce != "" {
+		q.Set("nonce", req.Nonce)
+	}
 
 	u.RawQuery = q.Encode()
 	return u.String(), nil
@@ -572,14 +772,14 @@ func (s *OAuthServerService) ResolveTokenClientID(token string) (clientID string
 	}
 
 	var accessToken model.OAuthAccessToken
-	if err := s.db.Select("client_id").Where("token = ?", token).First(&accessToken).Error; err == nil {
+	if err := s.db.Select("client_id").Where("token = ?", s.tokens.StorageKey(token)).First(&accessToken).Error; err == nil {
 		return accessToken.ClientID, true, nil
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return "", false, err
 	}
 
 	var refreshToken model.OAuthRefreshToken
-	if err := s.db.Select("client_id").Where("token = ?", token).First(&refreshToken).Error; err == nil {
+	if err := s.db.Select("client_id").Where("token = ?", utils.HashOAuthToken(token)).First(&refreshToken).Error; err == nil {
 		return refreshToken.ClientID, true, nil
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return "", false, err
@@ -591,10 +791,16 @@ func (s *OAuthServerService) ResolveTokenClientID(token string) (clientID string
 // RevokeToken 撤销令牌
 func (s *OAuthServerService) RevokeToken(token string) error {
 	// 删除访问令牌
-	accessTokenErr := s.db.Where("token = ?", token).Delete(&model.OAuthAccessToken{}).Error
+	accessTokenErr := s.db.Where("token = ?", s.tokens.StorageKey(token)).Delete(&model.OAuthAccessToken{}).Error
 
-	// 删除刷新令牌
-	refreshTokenErr := s.db.Where("token = ?", token).Delete(&model.OAuthRefreshToken{}).Error
+	// 撤销刷新令牌时一并撤销其令牌族（RFC 7009 §2.1）
+	var refreshTokenErr error
+	var refreshToken model.OAuthRefreshToken
+	if err := s.db.Where("token = ?", utils.HashOAuthToken(token)).First(&refreshToken).Error; err == nil {
+		refreshTokenErr = s.revokeRefreshTokenFamily(&refreshToken)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		refreshTokenErr = err
+	}
 
 	// 只要有一个删除成功就认为操作成功
 	if accessTokenErr != nil && refreshTokenErr != nil {
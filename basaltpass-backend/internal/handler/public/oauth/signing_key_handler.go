@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"errors"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	serviceauth "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/signingkey"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// signingKeysFor 按 ?purpose= 选择密钥集合：console 为控制台令牌密钥，默认为 OAuth/OIDC 令牌密钥
+func signingKeysFor(c *fiber.Ctx) *signingkey.Service {
+	if c.Query("purpose") == model.SigningKeyPurposeConsole {
+		return serviceauth.ConsoleSigningKeys()
+	}
+	return oauthServerService.keys
+}
+
+// ListSigningKeysHandler 获取签名密钥列表（不含私钥）
+// GET /tenant/oauth/signing-keys?purpose=oauth|console
+func ListSigningKeysHandler(c *fiber.Ctx) error {
+	keys, err := signingKeysFor(c).List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": keys,
+	})
+}
+
+// RotateSigningKeyHandler 立即轮换签名密钥
+// POST /tenant/oauth/signing-keys/rotate?purpose=oauth|console
+func RotateSigningKeyHandler(c *fiber.Ctx) error {
+	key, err := signingKeysFor(c).Rotate()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	userID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(userID, "轮换签名密钥", "signing_key", key.KID, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(fiber.Map{
+		"data":    key,
+		"message": "签名密钥已轮换",
+	})
+}
+
+// RevokeSigningKeyHandler 吊销指定签名密钥（如密钥泄露），吊销active密钥会同时触发轮换
+// POST /tenant/oauth/signing-keys/:kid/revoke?purpose=oauth|console
+func RevokeSigningKeyHandler(c *fiber.Ctx) error {
+	kid := c.Params("kid")
+
+	if err := signingKeysFor(c).Revoke(kid); err != nil {
+		switch {
+		case errors.Is(err, signingkey.ErrKeyNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "签名密钥不存在",
+			})
+		case errors.Is(err, signingkey.ErrAlreadyRevoked):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "签名密钥已被吊销",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	userID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(userID, "吊销签名密钥", "signing_key", kid, c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(fiber.Map{
+		"message": "签名密钥已吊销",
+	})
+}
@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPairwiseSubjectAcrossEndpoints(t *testing.T) {
+	db := setupOAuthTenantTestDB(t)
+	if err := db.AutoMigrate(&model.OAuthPairwiseSubject{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	user, client := setupIDTokenTestData(t, db, "client-pairwise", "openid,profile")
+	if err := db.Model(client).Updates(map[string]interface{}{
+		"subject_type":        model.SubjectTypePairwise,
+		"access_token_format": model.AccessTokenFormatJWT,
+	}).Error; err != nil {
+		t.Fatalf("update client failed: %v", err)
+	}
+	original := oauthServerService
+	oauthServerService = NewOAuthServerService()
+	t.Cleanup(func() { oauthServerService = original })
+	svc := oauthServerService
+
+	authReq := &AuthorizeRequest{
+		ClientID:     client.ClientID,
+		RedirectURI:  "https://rp.example.com/callback",
+		ResponseType: "code",
+		Scope:        "openid profile",
+	}
+	code, err := svc.GenerateAuthorizationCode(user.ID, authReq, client)
+	if err != nil {
+		t.Fatalf("generate code failed: %v", err)
+	}
+	resp, err := svc.ExchangeCodeForToken(&TokenRequest{
+		GrantType:   "authorization_code",
+		Code:        code,
+		RedirectURI: authReq.RedirectURI,
+		Issuer:      "https://id.example.com/api/v1",
+	}, ClientCredentials{ClientID: client.ClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+
+	sub, _ := parseIDTokenForTest(t, svc, resp.IDToken)["sub"].(string)
+	if sub == "" || sub == strconv.FormatUint(uint64(user.ID), 10) {
+		t.Fatalf("expected pairwise subject in id_token, got %q", sub)
+	}
+
+	// UserInfo、JWT 访问令牌与内省返回相同的 sub
+	info, err := svc.GetUserInfo(resp.AccessToken)
+	if err != nil || info.Sub != sub {
+		t.Fatalf("expected userinfo sub %q, got %+v %v", sub, info, err)
+	}
+	if claims, err := svc.tokens.Parse(resp.AccessToken); err != nil || claims["sub"] != sub {
+		t.Fatalf("expected access token sub %q, got %v %v", sub, claims, err)
+	}
+
+	app := fiber.New()
+	app.Post("/api/v1/oauth/introspect", OAuthClientAuthMiddleware(), IntrospectHandler)
+	form := url.Values{}
+	form.Set("token", resp.AccessToken)
+	form.Set("client_id", client.ClientID)
+	form.Set("client_secret", "secret")
+	if status, body := postFormForTest(t, app, "/api/v1/oauth/introspect", form); status != fiber.StatusOK || body["sub"] != sub {
+		t.Fatalf("expected introspection sub %q, got %d %v", sub, status, body)
+	}
+
+	// id_token_hint 中的 pairwise sub 能还原为用户
+	hintClientID, hintUserID, err := svc.parseIDTokenHint(resp.IDToken)
+	if err != nil || hintClientID != client.ClientID || hintUserID != user.ID {
+		t.Fatalf("expected id_token_hint to resolve to user %d, got %s %d %v", user.ID, hintClientID, hintUserID, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"basaltpass-backend/internal/service/app"
 	"basaltpass-backend/internal/service/scope"
 	"basaltpass-backend/internal/service/settings"
+	"encoding/json"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -65,12 +66,27 @@ func TenantCreateOAuthClientHandler(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		AppID          uint     `json:"app_id" validate:"required"`
-		Name           string   `json:"name" validate:"required,min=1,max=100"`
-		Description    string   `json:"description" validate:"max=500"`
-		RedirectURIs   []string `json:"redirect_uris" validate:"required,min=1"`
-		Scopes         []string `json:"scopes"`
-		AllowedOrigins []string `json:"allowed_origins"`
+		AppID             uint     `json:"app_id" validate:"required"`
+		Name              string   `json:"name" validate:"required,min=1,max=100"`
+		Description       string   `json:"description" validate:"max=500"`
+		RedirectURIs      []string `json:"redirect_uris" validate:"required,min=1"`
+		Scopes            []string `json:"scopes"`
+		GrantTypes        []string `json:"grant_types"`
+		AllowedOrigins    []string `json:"allowed_origins"`
+		AccessTokenFormat string   `json:"access_token_format"`
+
+		PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+		BackchannelLogoutURI   string   `json:"backchannel_logout_uri"`
+		FrontchannelLogoutURI  string   `json:"frontchannel_logout_uri"`
+
+		TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+		JWKS                    json.RawMessage `json:"jwks"`
+		JWKSURI                 string          `json:"jwks_uri"`
+
+		RequirePushedAuthorizationRequests bool `json:"require_pushed_authorization_requests"`
+
+		SubjectType         string `json:"subject_type"`
+		SectorIdentifierURI string `json:"sector_identifier_uri"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -90,11 +106,26 @@ func TenantCreateOAuthClientHandler(c *fiber.Ctx) error {
 
 	// 创建OAuth客户端请求
 	createReq := &CreateClientRequest{
-		Name:           req.Name,
-		Description:    req.Description,
-		RedirectURIs:   req.RedirectURIs,
-		Scopes:         req.Scopes,
-		AllowedOrigins: req.AllowedOrigins,
+		Name:              req.Name,
+		Description:       req.Description,
+		RedirectURIs:      req.RedirectURIs,
+		Scopes:            req.Scopes,
+		GrantTypes:        req.GrantTypes,
+		AllowedOrigins:    req.AllowedOrigins,
+		AccessTokenFormat: req.AccessTokenFormat,
+
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   req.BackchannelLogoutURI,
+		FrontchannelLogoutURI:  req.FrontchannelLogoutURI,
+
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		JWKS:                    req.JWKS,
+		JWKSURI:                 req.JWKSURI,
+
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+
+		SubjectType:         req.SubjectType,
+		SectorIdentifierURI: req.SectorIdentifierURI,
 	}
 
 	// 获取当前用户ID
@@ -220,6 +251,91 @@ func TenantRegenerateClientSecretHandler(c *fiber.Ctx) error {
 	})
 }
 
+// TenantCreateInitialAccessTokenHandler 签发动态客户端注册使用的初始访问令牌
+// POST /api/v1/tenant/oauth/initial-access-tokens
+func TenantCreateInitialAccessTokenHandler(c *fiber.Ctx) error {
+	tenantID := getTenantIDFromContext(c)
+	if tenantID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "无效的租户上下文",
+		})
+	}
+
+	var req CreateInitialAccessTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请求参数错误",
+		})
+	}
+
+	userID := c.Locals("userID").(uint)
+
+	raw, record, err := clientRegistrationService.IssueInitialAccessToken(tenantID, userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": fiber.Map{
+			"token":        raw,
+			"access_token": record,
+		},
+		"message": "初始访问令牌创建成功，请妥善保存，该令牌只显示一次",
+	})
+}
+
+// TenantListInitialAccessTokensHandler 获取租户的初始访问令牌列表
+// GET /api/v1/tenant/oauth/initial-access-tokens
+func TenantListInitialAccessTokensHandler(c *fiber.Ctx) error {
+	tenantID := getTenantIDFromContext(c)
+	if tenantID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "无效的租户上下文",
+		})
+	}
+
+	tokens, err := clientRegistrationService.ListInitialAccessTokens(tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "获取初始访问令牌列表失败",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": tokens,
+	})
+}
+
+// TenantDeleteInitialAccessTokenHandler 撤销初始访问令牌
+// DELETE /api/v1/tenant/oauth/initial-access-tokens/:id
+func TenantDeleteInitialAccessTokenHandler(c *fiber.Ctx) error {
+	tenantID := getTenantIDFromContext(c)
+	if tenantID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "无效的租户上下文",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil || id == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的令牌ID",
+		})
+	}
+
+	if err := clientRegistrationService.RevokeInitialAccessToken(tenantID, uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "初始访问令牌已撤销",
+	})
+}
+
 // TenantListOAuthScopesHandler returns available scope options for tenant console UI.
 // GET /api/v1/tenant/oauth/scopes
 func TenantListOAuthScopesHandler(c *fiber.Ctx) error {
@@ -0,0 +1,23 @@
+package oauth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("JWT_SECRET") == "" {
+		_ = os.Setenv("JWT_SECRET", "test-secret-for-unit-tests")
+	}
+	// 测试中修改的设置写入临时文件，避免在包目录下生成 config/settings.yaml
+	dir, err := os.MkdirTemp("", "oauth-settings")
+	if err == nil {
+		_ = os.Setenv("BASALTPASS_SETTINGS_FILE", filepath.Join(dir, "settings.yaml"))
+	}
+	code := m.Run()
+	if dir != "" {
+		_ = os.RemoveAll(dir)
+	}
+	os.Exit(code)
+}
@@ -24,15 +24,15 @@ var tokenExchangeSvc = txsvc.NewService()
 //   - Headers client_id / client_secret
 func handleTokenExchangeGrant(c *fiber.Ctx) error {
 	// 1. Authenticate the client
-	clientID, clientSecret := extractClientCredentials(c)
-	if clientID == "" || clientSecret == "" {
+	creds := extractClientCredentials(c)
+	if creds.ClientID == "" || (creds.ClientSecret == "" && creds.ClientAssertion == "") {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":             "invalid_client",
 			"error_description": "Client authentication failed",
 		})
 	}
 
-	client, err := oauthServerService.ValidateClientCredentials(clientID, clientSecret)
+	client, err := oauthServerService.ValidateClientCredentials(creds)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":             "invalid_client",
@@ -77,7 +77,7 @@ func handleTokenExchangeGrant(c *fiber.Ctx) error {
 
 	// 4. Perform the exchange
 	result, err := tokenExchangeSvc.Exchange(
-		clientID,
+		client.ClientID,
 		clientAppID,
 		clientTenantID,
 		txsvc.ExchangeRequest{
@@ -85,6 +85,7 @@ func handleTokenExchangeGrant(c *fiber.Ctx) error {
 			SubjectTokenType: subjectTokenType,
 			Resource:         resource,
 			Scope:            scope,
+			Issuer:           oidcIssuer(c),
 		},
 		c.IP(),
 	)
@@ -235,7 +235,7 @@ func FinishLoginHandler(c *fiber.Ctx) error {
 
 	scope := normalizeScope(c.Get("X-Auth-Scope"))
 	ctx := newRequestContext(c, map[string]interface{}{"email": req.Email, "tenant_id": tenantID})
-	tokens, err := svc.GenerateTokensForUser(user.ID, tenantID, scope, ctx)
+	tokens, err := svc.GenerateTokensForUser(user.ID, tenantID, scope, authsvc.NewAuthContext(authsvc.AMRHardwareKey), ctx)
 	if err != nil {
 		if errors.Is(err, authsvc.ErrTenantLoginDisabled) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
@@ -355,7 +355,8 @@ func Finish2FAHandler(c *fiber.Ctx) error {
 		"2fa_type":  "passkey",
 	})
 	scope := normalizeScope(c.Get("X-Auth-Scope"))
-	tokens, err := svc.GenerateTokensForUser(user.ID, tenantID, scope, ctx)
+	authCtx := authsvc.NewAuthContext(authsvc.AMRPassword, authsvc.AMRHardwareKey, authsvc.AMRMultiFactor)
+	tokens, err := svc.GenerateTokensForUser(user.ID, tenantID, scope, authCtx, ctx)
 	if err != nil {
 		if errors.Is(err, authsvc.ErrTenantLoginDisabled) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
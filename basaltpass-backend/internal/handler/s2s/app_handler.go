@@ -15,27 +15,27 @@ import (
 )
 
 type s2sCreateTeamRequest struct {
-	Name          string `json:"name"`
-	Description   string `json:"description"`
-	AvatarURL     string `json:"avatar_url"`
-	OwnerUserID   uint   `json:"owner_user_id"`
-	MemberUserIDs []uint `json:"member_user_ids"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	AvatarURL     string       `json:"avatar_url"`
+	OwnerUserID   s2sUserKey   `json:"owner_user_id"`
+	MemberUserIDs []s2sUserKey `json:"member_user_ids"`
 }
 
 type s2sSendNotificationsRequest struct {
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	Type       string `json:"type"`
-	UserIDs    []uint `json:"user_ids"`
-	Broadcast  bool   `json:"broadcast"`
-	SenderName string `json:"sender_name"`
+	Title      string       `json:"title"`
+	Content    string       `json:"content"`
+	Type       string       `json:"type"`
+	UserIDs    []s2sUserKey `json:"user_ids"`
+	Broadcast  bool         `json:"broadcast"`
+	SenderName string       `json:"sender_name"`
 }
 
 type s2sSendEmailsRequest struct {
 	Subject   string            `json:"subject"`
 	TextBody  string            `json:"text_body"`
 	HTMLBody  string            `json:"html_body"`
-	UserIDs   []uint            `json:"user_ids"`
+	UserIDs   []s2sUserKey      `json:"user_ids"`
 	Broadcast bool              `json:"broadcast"`
 	ReplyTo   string            `json:"reply_to"`
 	Headers   map[string]string `json:"headers"`
@@ -62,6 +62,14 @@ func uniqueUint(values []uint) []uint {
 	return out
 }
 
+// s2sUserKeyError 请求体中的用户标识无法识别时返回 400，其余为服务端错误
+func s2sUserKeyError(c *fiber.Ctx, err error) error {
+	if ferr, ok := err.(*fiber.Error); ok {
+		return unifiedResponse(c, ferr.Code, nil, fiber.Map{"code": "invalid_parameter", "message": ferr.Message})
+	}
+	return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+}
+
 func currentS2SApp(c *fiber.Ctx) (*model.App, error) {
 	appIDAny := c.Locals("s2s_app_id")
 	appID, ok := appIDAny.(uint)
@@ -118,27 +126,36 @@ func loadAuthorizedAppUsers(appID uint, requested []uint) ([]model.User, error)
 	return users, nil
 }
 
-func teamMemberResponse(m model.TeamMember) fiber.Map {
+// teamMemberResponse 成员的用户标识按 s2sUserRef 返回，pairwise 客户端看不到内部用户ID
+func teamMemberResponse(c *fiber.Ctx, m model.TeamMember) (fiber.Map, error) {
+	ref, err := s2sUserRef(c, m.UserID)
+	if err != nil {
+		return nil, err
+	}
 	return fiber.Map{
 		"id":         m.ID,
-		"user_id":    m.UserID,
+		"user_id":    ref,
 		"role":       m.Role,
 		"status":     m.Status,
 		"joined_at":  time.Unix(m.JoinedAt, 0).UTC().Format(time.RFC3339),
 		"created_at": m.CreatedAt,
 		"user": fiber.Map{
-			"id":         m.User.ID,
+			"id":         ref,
 			"email":      m.User.Email,
 			"nickname":   m.User.Nickname,
 			"avatar_url": m.User.AvatarURL,
 		},
-	}
+	}, nil
 }
 
-func teamDetailResponse(t model.Team) fiber.Map {
+func teamDetailResponse(c *fiber.Ctx, t model.Team) (fiber.Map, error) {
 	members := make([]fiber.Map, 0, len(t.Members))
 	for _, m := range t.Members {
-		members = append(members, teamMemberResponse(m))
+		member, err := teamMemberResponse(c, m)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
 	}
 	return fiber.Map{
 		"id":           t.ID,
@@ -151,7 +168,7 @@ func teamDetailResponse(t model.Team) fiber.Map {
 		"created_at":   t.CreatedAt,
 		"updated_at":   t.UpdatedAt,
 		"members":      members,
-	}
+	}, nil
 }
 
 // GET /api/v1/s2s/teams
@@ -178,7 +195,7 @@ func ListTeamsHandler(c *fiber.Ctx) error {
 	userIDStr := strings.TrimSpace(c.Query("user_id"))
 	var userID uint
 	if userIDStr != "" {
-		uid64, parseErr := strconv.ParseUint(userIDStr, 10, 64)
+		uid64, parseErr := s2sUserID(c, userIDStr)
 		if parseErr != nil || uid64 == 0 {
 			return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 		}
@@ -259,7 +276,6 @@ func CreateTeamHandler(c *fiber.Ctx) error {
 	req.Name = strings.TrimSpace(req.Name)
 	req.Description = strings.TrimSpace(req.Description)
 	req.AvatarURL = strings.TrimSpace(req.AvatarURL)
-	req.MemberUserIDs = uniqueUint(req.MemberUserIDs)
 
 	if req.Name == "" {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "name is required"})
@@ -271,11 +287,24 @@ func CreateTeamHandler(c *fiber.Ctx) error {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "description too long"})
 	}
 
-	userIDs := make([]uint, 0, len(req.MemberUserIDs)+1)
-	if req.OwnerUserID > 0 {
-		userIDs = append(userIDs, req.OwnerUserID)
+	owners, err := s2sResolveUserIDs(c, []s2sUserKey{req.OwnerUserID})
+	if err != nil {
+		return s2sUserKeyError(c, err)
+	}
+	memberIDs, err := s2sResolveUserIDs(c, req.MemberUserIDs)
+	if err != nil {
+		return s2sUserKeyError(c, err)
+	}
+	var ownerID uint
+	if len(owners) > 0 {
+		ownerID = owners[0]
+	}
+
+	userIDs := make([]uint, 0, len(memberIDs)+1)
+	if ownerID > 0 {
+		userIDs = append(userIDs, ownerID)
 	}
-	userIDs = append(userIDs, req.MemberUserIDs...)
+	userIDs = append(userIDs, memberIDs...)
 	if err := ensureUsersInTenant(tenantID, userIDs); err != nil {
 		status := fiber.StatusBadRequest
 		if ferr, ok := err.(*fiber.Error); ok {
@@ -299,17 +328,17 @@ func CreateTeamHandler(c *fiber.Ctx) error {
 
 		members := make([]model.TeamMember, 0, len(userIDs))
 		seen := make(map[uint]struct{}, len(userIDs))
-		if req.OwnerUserID > 0 {
+		if ownerID > 0 {
 			members = append(members, model.TeamMember{
 				TeamID:   team.ID,
-				UserID:   req.OwnerUserID,
+				UserID:   ownerID,
 				Role:     model.TeamRoleOwner,
 				Status:   "active",
 				JoinedAt: time.Now().Unix(),
 			})
-			seen[req.OwnerUserID] = struct{}{}
+			seen[ownerID] = struct{}{}
 		}
-		for _, uid := range req.MemberUserIDs {
+		for _, uid := range memberIDs {
 			if _, ok := seen[uid]; ok {
 				continue
 			}
@@ -337,7 +366,11 @@ func CreateTeamHandler(c *fiber.Ctx) error {
 	if err := common.DB().Preload("Members.User").First(&created, team.ID).Error; err != nil {
 		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
 	}
-	return unifiedResponse(c, fiber.StatusCreated, teamDetailResponse(created), nil)
+	detail, err := teamDetailResponse(c, created)
+	if err != nil {
+		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+	}
+	return unifiedResponse(c, fiber.StatusCreated, detail, nil)
 }
 
 // GET /api/v1/s2s/teams/:id
@@ -361,13 +394,17 @@ func GetTeamHandler(c *fiber.Ctx) error {
 	if err := common.DB().Preload("Members.User").Where("id = ? AND tenant_id = ?", uint(tid64), tenantID).First(&team).Error; err != nil {
 		return unifiedResponse(c, fiber.StatusNotFound, nil, fiber.Map{"code": "not_found", "message": "team not found"})
 	}
-	return unifiedResponse(c, fiber.StatusOK, teamDetailResponse(team), nil)
+	detail, err := teamDetailResponse(c, team)
+	if err != nil {
+		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+	}
+	return unifiedResponse(c, fiber.StatusOK, detail, nil)
 }
 
 // GET /api/v1/s2s/users/:id/teams
 func GetUserTeamsHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -439,7 +476,10 @@ func SendNotificationsHandler(c *fiber.Ctx) error {
 	req.Content = strings.TrimSpace(req.Content)
 	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
 	req.SenderName = strings.TrimSpace(req.SenderName)
-	req.UserIDs = uniqueUint(req.UserIDs)
+	requestedIDs, err := s2sResolveUserIDs(c, req.UserIDs)
+	if err != nil {
+		return s2sUserKeyError(c, err)
+	}
 
 	if req.Title == "" || req.Content == "" {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "title and content are required"})
@@ -452,11 +492,11 @@ func SendNotificationsHandler(c *fiber.Ctx) error {
 	default:
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid notification type"})
 	}
-	if !req.Broadcast && len(req.UserIDs) == 0 {
+	if !req.Broadcast && len(requestedIDs) == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "user_ids is required unless broadcast=true"})
 	}
 
-	targetUsers, err := loadAuthorizedAppUsers(app.ID, req.UserIDs)
+	targetUsers, err := loadAuthorizedAppUsers(app.ID, requestedIDs)
 	if err != nil {
 		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
 	}
@@ -477,9 +517,13 @@ func SendNotificationsHandler(c *fiber.Ctx) error {
 
 	now := time.Now()
 	notifs := make([]model.Notification, 0, len(targetUsers))
-	userIDs := make([]uint, 0, len(targetUsers))
+	userRefs := make([]interface{}, 0, len(targetUsers))
 	for _, user := range targetUsers {
-		userIDs = append(userIDs, user.ID)
+		ref, err := s2sUserRef(c, user.ID)
+		if err != nil {
+			return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+		}
+		userRefs = append(userRefs, ref)
 		notifs = append(notifs, model.Notification{
 			Title:      req.Title,
 			Content:    req.Content,
@@ -495,7 +539,7 @@ func SendNotificationsHandler(c *fiber.Ctx) error {
 
 	return unifiedResponse(c, fiber.StatusCreated, fiber.Map{
 		"sent_count":        len(notifs),
-		"target_user_ids":   userIDs,
+		"target_user_ids":   userRefs,
 		"notification_type": req.Type,
 		"broadcast":         req.Broadcast,
 	}, nil)
@@ -537,7 +581,10 @@ func SendEmailsHandler(c *fiber.Ctx) error {
 	req.ReplyTo = strings.TrimSpace(req.ReplyTo)
 	req.From = strings.TrimSpace(req.From)
 	req.FromName = strings.TrimSpace(req.FromName)
-	req.UserIDs = uniqueUint(req.UserIDs)
+	requestedIDs, err := s2sResolveUserIDs(c, req.UserIDs)
+	if err != nil {
+		return s2sUserKeyError(c, err)
+	}
 
 	if req.Subject == "" {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "subject is required"})
@@ -545,11 +592,11 @@ func SendEmailsHandler(c *fiber.Ctx) error {
 	if req.TextBody == "" && req.HTMLBody == "" {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "text_body or html_body is required"})
 	}
-	if !req.Broadcast && len(req.UserIDs) == 0 {
+	if !req.Broadcast && len(requestedIDs) == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "user_ids is required unless broadcast=true"})
 	}
 
-	targetUsers, err := loadAuthorizedAppUsers(app.ID, req.UserIDs)
+	targetUsers, err := loadAuthorizedAppUsers(app.ID, requestedIDs)
 	if err != nil {
 		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
 	}
@@ -572,10 +619,14 @@ func SendEmailsHandler(c *fiber.Ctx) error {
 	sentCount := 0
 	failedCount := 0
 	for _, user := range targetUsers {
+		userRef, err := s2sUserRef(c, user.ID)
+		if err != nil {
+			return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+		}
 		if strings.TrimSpace(user.Email) == "" {
 			failedCount++
 			results = append(results, fiber.Map{
-				"user_id": user.ID,
+				"user_id": userRef,
 				"email":   "",
 				"status":  model.EmailStatusFailed,
 				"error":   "user email is empty",
@@ -609,7 +660,7 @@ func SendEmailsHandler(c *fiber.Ctx) error {
 		}
 
 		item := fiber.Map{
-			"user_id": user.ID,
+			"user_id": userRef,
 			"email":   user.Email,
 			"status":  status,
 		}
@@ -12,7 +12,7 @@ import (
 // 可选参数：status=unread|all（默认all），page=1，page_size=20
 func GetUserMessagesHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -67,7 +67,7 @@ func GetUserMessagesHandler(c *fiber.Ctx) error {
 // 返回用户通过活跃订阅或已支付订单拥有的产品列表
 func GetUserPurchasedProductsHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -141,7 +141,7 @@ func GetUserPurchasedProductsHandler(c *fiber.Ctx) error {
 // 返回 { has_ownership: bool, via: ["subscription","order"] }
 func CheckUserProductOwnershipHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -3,7 +3,10 @@ package s2s
 import (
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/subject"
 	"basaltpass-backend/internal/service/wallet"
+	"encoding/json"
+	"errors"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,9 +24,17 @@ func unifiedResponse(c *fiber.Ctx, status int, data interface{}, errObj interfac
 	return c.Status(status).JSON(fiber.Map{"data": data, "error": nil, "request_id": requestID})
 }
 
-func userSummary(u model.User) fiber.Map {
-	return fiber.Map{
+// userSummary 返回用户摘要，sub 与该客户端收到的 ID Token 一致；
+// pairwise 客户端不返回可跨应用关联的内部ID与 user_uuid
+func userSummary(c *fiber.Ctx, u model.User) (fiber.Map, error) {
+	client := s2sClient(c)
+	sub, err := subject.NewService(common.DB()).Subject(client, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary := fiber.Map{
 		"id":             u.ID,
+		"sub":            sub,
 		"user_uuid":      u.UserUUID,
 		"email":          u.Email,
 		"nickname":       u.Nickname,
@@ -34,6 +45,78 @@ func userSummary(u model.User) fiber.Map {
 		"created_at":     u.CreatedAt,
 		"updated_at":     u.UpdatedAt,
 	}
+	if client != nil && client.UsesPairwiseSubjects() {
+		delete(summary, "id")
+		delete(summary, "user_uuid")
+	}
+	return summary, nil
+}
+
+// s2sClient 返回已认证的 S2S 客户端
+func s2sClient(c *fiber.Ctx) *model.OAuthClient {
+	client, _ := c.Locals("s2s_client").(*model.OAuthClient)
+	return client
+}
+
+// s2sUserID 解析路径中的用户标识：pairwise 客户端传入其看到的 sub，public 客户端传入用户ID
+func s2sUserID(c *fiber.Ctx, idStr string) (uint64, error) {
+	uid, err := subject.NewService(common.DB()).ResolveUserID(s2sClient(c), idStr)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(uid), nil
+}
+
+// s2sUserKey 请求体中的用户标识，与路径参数规则相同：public 客户端为用户ID（数字或字符串），pairwise 客户端为 sub
+type s2sUserKey string
+
+func (k *s2sUserKey) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*k = s2sUserKey(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*k = s2sUserKey(n.String())
+	return nil
+}
+
+// s2sResolveUserIDs 将请求体中的用户标识还原为用户ID，去重并忽略空值
+func s2sResolveUserIDs(c *fiber.Ctx, keys []s2sUserKey) ([]uint, error) {
+	svc := subject.NewService(common.DB())
+	client := s2sClient(c)
+	userIDs := make([]uint, 0, len(keys))
+	for _, k := range keys {
+		key := strings.TrimSpace(string(k))
+		if key == "" || key == "0" {
+			continue
+		}
+		uid, err := svc.ResolveUserID(client, key)
+		if err != nil {
+			if errors.Is(err, subject.ErrUnknownSubject) {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "unknown user id "+key)
+			}
+			return nil, err
+		}
+		userIDs = append(userIDs, uid)
+	}
+	return uniqueUint(userIDs), nil
+}
+
+// s2sUserRef 返回响应中的用户标识，与 s2sUserID 接受的形式一致：pairwise 客户端为 sub，public 客户端为用户ID
+func s2sUserRef(c *fiber.Ctx, userID uint) (interface{}, error) {
+	client := s2sClient(c)
+	if client == nil || !client.UsesPairwiseSubjects() {
+		return userID, nil
+	}
+	sub, err := subject.NewService(common.DB()).Subject(client, userID)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
 }
 
 func s2sTenantID(c *fiber.Ctx) (uint, error) {
@@ -81,7 +164,7 @@ func s2sAppID(c *fiber.Ctx) (uint, error) {
 // GET /api/v1/s2s/users/:id
 func GetUserByIDHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -109,13 +192,17 @@ func GetUserByIDHandler(c *fiber.Ctx) error {
 	}
 
 	// 精简返回，避免隐私字段泄露
-	return unifiedResponse(c, fiber.StatusOK, userSummary(user), nil)
+	summary, err := userSummary(c, user)
+	if err != nil {
+		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+	}
+	return unifiedResponse(c, fiber.StatusOK, summary, nil)
 }
 
 // GET /api/v1/s2s/users/:id/roles?tenant_id=xxx
 func GetUserRolesHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -166,7 +253,7 @@ func GetUserRolesHandler(c *fiber.Ctx) error {
 // GET /api/v1/s2s/users/:id/permissions?tenant_id=xxx
 func GetUserPermissionsHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -256,7 +343,7 @@ func GetUserPermissionsHandler(c *fiber.Ctx) error {
 // GET /api/v1/s2s/users/:id/role-codes?tenant_id=xxx
 func GetUserRoleCodesHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -350,14 +437,22 @@ func LookupUsersHandler(c *fiber.Ctx) error {
 		if err := db.Where("lower(system_auth_users.email) = lower(?)", email).First(&u).Error; err != nil {
 			return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": []fiber.Map{}}, nil)
 		}
-		return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": []fiber.Map{userSummary(u)}}, nil)
+		summary, err := userSummary(c, u)
+		if err != nil {
+			return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+		}
+		return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": []fiber.Map{summary}}, nil)
 	}
 	if phone != "" {
 		var u model.User
 		if err := db.Where("system_auth_users.phone = ?", phone).First(&u).Error; err != nil {
 			return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": []fiber.Map{}}, nil)
 		}
-		return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": []fiber.Map{userSummary(u)}}, nil)
+		summary, err := userSummary(c, u)
+		if err != nil {
+			return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+		}
+		return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": []fiber.Map{summary}}, nil)
 	}
 
 	like := "%" + strings.ToLower(q) + "%"
@@ -376,7 +471,11 @@ func LookupUsersHandler(c *fiber.Ctx) error {
 	}
 	resp := make([]fiber.Map, 0, len(users))
 	for _, u := range users {
-		resp = append(resp, userSummary(u))
+		summary, err := userSummary(c, u)
+		if err != nil {
+			return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+		}
+		resp = append(resp, summary)
 	}
 	return unifiedResponse(c, fiber.StatusOK, fiber.Map{"users": resp, "total": total, "page": page, "page_size": pageSize}, nil)
 }
@@ -397,7 +496,7 @@ type adjustUserWalletRequest struct {
 // Body: {"nickname": "..."} (or legacy alias "username")
 func PatchUserHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -443,14 +542,18 @@ func PatchUserHandler(c *fiber.Ctx) error {
 	if err := common.DB().First(&user, userID).Error; err != nil {
 		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
 	}
-	return unifiedResponse(c, fiber.StatusOK, userSummary(user), nil)
+	summary, err := userSummary(c, user)
+	if err != nil {
+		return unifiedResponse(c, fiber.StatusInternalServerError, nil, fiber.Map{"code": "server_error", "message": err.Error()})
+	}
+	return unifiedResponse(c, fiber.StatusOK, summary, nil)
 }
 
 // GET /api/v1/s2s/users/:id/wallets
 // 支持可选参数：currency=CODE（如 CNY, USD），limit=20（交易记录条数）
 func GetUserWalletHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
@@ -506,7 +609,7 @@ func GetUserWalletHandler(c *fiber.Ctx) error {
 // Body: {"operation":"increase|decrease","amount":100,"currency":"USD","reference":"invoice_123"}
 func AdjustUserWalletHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
-	uid64, err := strconv.ParseUint(idStr, 10, 64)
+	uid64, err := s2sUserID(c, idStr)
 	if err != nil || uid64 == 0 {
 		return unifiedResponse(c, fiber.StatusBadRequest, nil, fiber.Map{"code": "invalid_parameter", "message": "invalid user id"})
 	}
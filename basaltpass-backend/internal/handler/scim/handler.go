@@ -0,0 +1,284 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"basaltpass-backend/internal/common"
+	oauthhandler "basaltpass-backend/internal/handler/public/oauth"
+	"basaltpass-backend/internal/service/aduit"
+	scimsvc "basaltpass-backend/internal/service/scim"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// basePath SCIM 端点相对站点根的路径
+const basePath = "/api/v1/scim/v2"
+
+// newService 按 API Key 的租户创建 SCIM 服务；停用或删除用户后通知其授权过的应用登出
+func newService(c *fiber.Ctx) *scimsvc.Service {
+	tenantID, _ := c.Locals("manualAPIKeyTenantID").(uint)
+	actorID, _ := c.Locals("manualAPIKeyCreatorUserID").(uint)
+	svc := scimsvc.NewService(common.DB(), tenantID, utils.RequestBaseURL(c)+basePath, actorID)
+	svc.RevokeTokens = oauthhandler.RevokeUserTokens
+	svc.OnDeprovision = func(userID uint) {
+		oauthhandler.LogoutUser(c, userID)
+	}
+	return svc
+}
+
+func respond(c *fiber.Ctx, status int, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	c.Set(fiber.HeaderContentType, scimsvc.ContentType)
+	return c.Status(status).Send(payload)
+}
+
+// respondError 以 SCIM 错误格式返回；非 SCIM 错误按 500 处理
+func respondError(c *fiber.Ctx, err error) error {
+	var scimErr *scimsvc.Error
+	if !errors.As(err, &scimErr) {
+		log.Printf("[SCIM] %s %s failed: %v", c.Method(), c.Path(), err)
+		scimErr = &scimsvc.Error{Status: http.StatusInternalServerError, Detail: "internal error"}
+	}
+	c.Set(fiber.HeaderContentType, scimsvc.ContentType)
+	return c.Status(scimErr.Status).JSON(scimErr.Body())
+}
+
+func parseBody(c *fiber.Ctx, out interface{}) error {
+	if err := json.Unmarshal(c.Body(), out); err != nil {
+		return &scimsvc.Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: "invalid JSON body"}
+	}
+	return nil
+}
+
+func listParams(c *fiber.Ctx) scimsvc.ListParams {
+	return scimsvc.NewListParams(c.Query("filter"), c.Query("startIndex"), c.Query("count"), c.Query("attributes"), c.Query("excludedAttributes"))
+}
+
+// respondResource 返回单个资源，支持 attributes / excludedAttributes 裁剪
+func respondResource(c *fiber.Ctx, status int, r scimsvc.Resource) error {
+	params := listParams(c)
+	if status == http.StatusCreated {
+		if m, ok := r["meta"].(map[string]interface{}); ok {
+			if location, ok := m["location"].(string); ok {
+				c.Set(fiber.HeaderLocation, location)
+			}
+		}
+	}
+	return respond(c, status, scimsvc.Project(r, params.Attributes, params.ExcludedAttributes))
+}
+
+func audit(c *fiber.Ctx, action, resourceType, resourceID string) {
+	actorID, _ := c.Locals("manualAPIKeyCreatorUserID").(uint)
+	aduit.LogAudit(actorID, action, resourceType, resourceID, c.IP(), c.Get("User-Agent"))
+}
+
+func resourceID(r scimsvc.Resource) string {
+	id, _ := r["id"].(string)
+	return id
+}
+
+// ListUsersHandler GET /scim/v2/Users
+func ListUsersHandler(c *fiber.Ctx) error {
+	resp, err := newService(c).ListUsers(listParams(c))
+	if err != nil {
+		return respondError(c, err)
+	}
+	return respond(c, http.StatusOK, resp)
+}
+
+// GetUserHandler GET /scim/v2/Users/:id
+func GetUserHandler(c *fiber.Ctx) error {
+	r, err := newService(c).GetUser(c.Params("id"))
+	if err != nil {
+		return respondError(c, err)
+	}
+	return respondResource(c, http.StatusOK, r)
+}
+
+// CreateUserHandler POST /scim/v2/Users
+func CreateUserHandler(c *fiber.Ctx) error {
+	var body scimsvc.Resource
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	r, err := newService(c).CreateUser(body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM创建用户", "scim_user", resourceID(r))
+	return respondResource(c, http.StatusCreated, r)
+}
+
+// ReplaceUserHandler PUT /scim/v2/Users/:id
+func ReplaceUserHandler(c *fiber.Ctx) error {
+	var body scimsvc.Resource
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	r, err := newService(c).ReplaceUser(c.Params("id"), body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM更新用户", "scim_user", resourceID(r))
+	return respondResource(c, http.StatusOK, r)
+}
+
+// PatchUserHandler PATCH /scim/v2/Users/:id
+func PatchUserHandler(c *fiber.Ctx) error {
+	var body scimsvc.PatchRequest
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	r, err := newService(c).PatchUser(c.Params("id"), &body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM更新用户", "scim_user", resourceID(r))
+	return respondResource(c, http.StatusOK, r)
+}
+
+// DeleteUserHandler DELETE /scim/v2/Users/:id
+func DeleteUserHandler(c *fiber.Ctx) error {
+	if err := newService(c).DeleteUser(c.Params("id")); err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM删除用户", "scim_user", c.Params("id"))
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ListGroupsHandler GET /scim/v2/Groups
+func ListGroupsHandler(c *fiber.Ctx) error {
+	resp, err := newService(c).ListGroups(listParams(c))
+	if err != nil {
+		return respondError(c, err)
+	}
+	return respond(c, http.StatusOK, resp)
+}
+
+// GetGroupHandler GET /scim/v2/Groups/:id
+func GetGroupHandler(c *fiber.Ctx) error {
+	r, err := newService(c).GetGroup(c.Params("id"))
+	if err != nil {
+		return respondError(c, err)
+	}
+	return respondResource(c, http.StatusOK, r)
+}
+
+// CreateGroupHandler POST /scim/v2/Groups
+func CreateGroupHandler(c *fiber.Ctx) error {
+	var body scimsvc.Resource
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	r, err := newService(c).CreateGroup(body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM创建组", "scim_group", resourceID(r))
+	return respondResource(c, http.StatusCreated, r)
+}
+
+// ReplaceGroupHandler PUT /scim/v2/Groups/:id
+func ReplaceGroupHandler(c *fiber.Ctx) error {
+	var body scimsvc.Resource
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	r, err := newService(c).ReplaceGroup(c.Params("id"), body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM更新组", "scim_group", resourceID(r))
+	return respondResource(c, http.StatusOK, r)
+}
+
+// PatchGroupHandler PATCH /scim/v2/Groups/:id
+func PatchGroupHandler(c *fiber.Ctx) error {
+	var body scimsvc.PatchRequest
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	r, err := newService(c).PatchGroup(c.Params("id"), &body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM更新组", "scim_group", resourceID(r))
+	return respondResource(c, http.StatusOK, r)
+}
+
+// DeleteGroupHandler DELETE /scim/v2/Groups/:id
+func DeleteGroupHandler(c *fiber.Ctx) error {
+	if err := newService(c).DeleteGroup(c.Params("id")); err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM删除组", "scim_group", c.Params("id"))
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// BulkHandler POST /scim/v2/Bulk
+func BulkHandler(c *fiber.Ctx) error {
+	if len(c.Body()) > scimsvc.MaxBulkPayload {
+		return respondError(c, &scimsvc.Error{Status: http.StatusRequestEntityTooLarge, Detail: "bulk payload too large"})
+	}
+	var body scimsvc.BulkRequest
+	if err := parseBody(c, &body); err != nil {
+		return respondError(c, err)
+	}
+	resp, err := newService(c).Bulk(&body)
+	if err != nil {
+		return respondError(c, err)
+	}
+	audit(c, "SCIM批量操作", "scim_bulk", "")
+	return respond(c, http.StatusOK, resp)
+}
+
+// ServiceProviderConfigHandler GET /scim/v2/ServiceProviderConfig
+func ServiceProviderConfigHandler(c *fiber.Ctx) error {
+	return respond(c, http.StatusOK, newService(c).ServiceProviderConfig())
+}
+
+// ResourceTypesHandler GET /scim/v2/ResourceTypes
+func ResourceTypesHandler(c *fiber.Ctx) error {
+	types := newService(c).ResourceTypes()
+	return respond(c, http.StatusOK, discoveryList(types))
+}
+
+// ResourceTypeHandler GET /scim/v2/ResourceTypes/:id
+func ResourceTypeHandler(c *fiber.Ctx) error {
+	r, err := newService(c).ResourceType(c.Params("id"))
+	if err != nil {
+		return respondError(c, err)
+	}
+	return respond(c, http.StatusOK, r)
+}
+
+// SchemasHandler GET /scim/v2/Schemas
+func SchemasHandler(c *fiber.Ctx) error {
+	return respond(c, http.StatusOK, discoveryList(newService(c).Schemas()))
+}
+
+// SchemaHandler GET /scim/v2/Schemas/:id
+func SchemaHandler(c *fiber.Ctx) error {
+	r, err := newService(c).Schema(c.Params("id"))
+	if err != nil {
+		return respondError(c, err)
+	}
+	return respond(c, http.StatusOK, r)
+}
+
+func discoveryList(resources []scimsvc.Resource) *scimsvc.ListResponse {
+	return &scimsvc.ListResponse{
+		Schemas:      []string{scimsvc.SchemaListResponse},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
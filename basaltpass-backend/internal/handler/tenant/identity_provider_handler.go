@@ -0,0 +1,132 @@
+package tenant
+
+import (
+	"errors"
+	"strconv"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/federation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func identityProviderResponse(p *model.IdentityProvider) fiber.Map {
+	return fiber.Map{
+		"id":                     p.ID,
+		"tenant_id":              p.TenantID,
+		"slug":                   p.Slug,
+		"name":                   p.Name,
+		"type":                   p.Type,
+		"preset":                 p.Preset,
+		"issuer":                 p.Issuer,
+		"authorization_endpoint": p.AuthorizationEndpoint,
+		"token_endpoint":         p.TokenEndpoint,
+		"userinfo_endpoint":      p.UserinfoEndpoint,
+		"jwks_uri":               p.JWKSURI,
+		"client_id":              p.ClientID,
+		"has_client_secret":      p.ClientSecretEnc != "",
+		"scopes":                 p.Scopes,
+		"subject_field":          p.SubjectField,
+		"email_field":            p.EmailField,
+		"name_field":             p.NameField,
+		"allow_provisioning":     p.AllowProvisioning,
+		"enabled":                p.Enabled,
+		"created_at":             p.CreatedAt,
+		"updated_at":             p.UpdatedAt,
+	}
+}
+
+func identityProviderError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, federation.ErrProviderNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "身份提供方不存在"})
+	case errors.Is(err, federation.ErrInvalidProvider):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "保存身份提供方失败"})
+	}
+}
+
+// ListIdentityProvidersHandler 列出租户的外部身份提供方
+// GET /api/v1/tenant/identity-providers
+func ListIdentityProvidersHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	providers, err := federation.NewService(common.DB()).ListProviders(tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取身份提供方失败"})
+	}
+	items := make([]fiber.Map, 0, len(providers))
+	for i := range providers {
+		items = append(items, identityProviderResponse(&providers[i]))
+	}
+	return c.JSON(fiber.Map{
+		"data":         items,
+		"presets":      federation.PresetNames(),
+		"callback_url": federation.CallbackURL(c.BaseURL()),
+	})
+}
+
+// CreateIdentityProviderHandler 创建外部身份提供方
+// POST /api/v1/tenant/identity-providers
+func CreateIdentityProviderHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	var req federation.ProviderInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数错误"})
+	}
+
+	provider, err := federation.NewService(common.DB()).CreateProvider(tenantID, req)
+	if err != nil {
+		return identityProviderError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "创建身份提供方", "identity_provider", strconv.FormatUint(uint64(provider.ID), 10), c.IP(), c.Get("User-Agent"))
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"data": identityProviderResponse(provider)})
+}
+
+// UpdateIdentityProviderHandler 更新外部身份提供方
+// PATCH /api/v1/tenant/identity-providers/:id
+func UpdateIdentityProviderHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的ID"})
+	}
+
+	var req federation.ProviderUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数错误"})
+	}
+
+	provider, err := federation.NewService(common.DB()).UpdateProvider(tenantID, uint(id), req)
+	if err != nil {
+		return identityProviderError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "更新身份提供方", "identity_provider", c.Params("id"), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"data": identityProviderResponse(provider)})
+}
+
+// DeleteIdentityProviderHandler 删除外部身份提供方，已绑定的外部身份一并解除
+// DELETE /api/v1/tenant/identity-providers/:id
+func DeleteIdentityProviderHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的ID"})
+	}
+
+	if err := federation.NewService(common.DB()).DeleteProvider(tenantID, uint(id)); err != nil {
+		return identityProviderError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "删除身份提供方", "identity_provider", c.Params("id"), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"message": "身份提供方已删除"})
+}
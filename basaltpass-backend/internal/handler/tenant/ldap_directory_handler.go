@@ -0,0 +1,161 @@
+package tenant
+
+import (
+	"errors"
+	"strconv"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/ldapauth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ldapDirectoryResponse(d *model.LDAPDirectory) fiber.Map {
+	return fiber.Map{
+		"id":                    d.ID,
+		"tenant_id":             d.TenantID,
+		"url":                   d.URL,
+		"start_tls":             d.StartTLS,
+		"insecure_skip_verify":  d.InsecureSkipVerify,
+		"bind_dn":               d.BindDN,
+		"has_bind_password":     d.BindPasswordEnc != "",
+		"base_dn":               d.BaseDN,
+		"user_filter":           d.UserFilter,
+		"uid_attribute":         d.UIDAttribute,
+		"email_attribute":       d.EmailAttribute,
+		"name_attribute":        d.NameAttribute,
+		"phone_attribute":       d.PhoneAttribute,
+		"group_attribute":       d.GroupAttribute,
+		"group_base_dn":         d.GroupBaseDN,
+		"group_filter":          d.GroupFilter,
+		"group_role_mappings":   ldapauth.GroupRoleMappings(d),
+		"allow_provisioning":    d.AllowProvisioning,
+		"sync_interval_minutes": d.SyncIntervalMinutes,
+		"last_sync_at":          d.LastSyncAt,
+		"last_sync_error":       d.LastSyncError,
+		"enabled":               d.Enabled,
+		"created_at":            d.CreatedAt,
+		"updated_at":            d.UpdatedAt,
+	}
+}
+
+func ldapDirectoryError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ldapauth.ErrDirectoryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "未配置 LDAP 目录"})
+	case errors.Is(err, ldapauth.ErrInvalidDirectory):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ldapauth.ErrAccountNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "用户不存在"})
+	case errors.Is(err, ldapauth.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "目录中未找到该用户"})
+	case errors.Is(err, ldapauth.ErrAlreadyLinked):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "账号或目录条目已绑定"})
+	case errors.Is(err, ldapauth.ErrEmailConflict):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "目录条目的邮箱已被其他账号使用"})
+	case errors.Is(err, ldapauth.ErrUserBanned):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "账号已被封禁"})
+	case errors.Is(err, ldapauth.ErrDirectoryUnavailable):
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "操作 LDAP 目录失败"})
+	}
+}
+
+// GetLDAPDirectoryHandler 获取租户的 LDAP 目录配置
+// GET /api/v1/tenant/ldap-directory
+func GetLDAPDirectoryHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	dir, err := ldapauth.NewService(common.DB()).GetDirectory(tenantID)
+	if err != nil {
+		return ldapDirectoryError(c, err)
+	}
+	return c.JSON(fiber.Map{"data": ldapDirectoryResponse(dir)})
+}
+
+// SaveLDAPDirectoryHandler 创建或更新租户的 LDAP 目录配置
+// PUT /api/v1/tenant/ldap-directory
+func SaveLDAPDirectoryHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	var req ldapauth.DirectoryInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数错误"})
+	}
+
+	dir, err := ldapauth.NewService(common.DB()).SaveDirectory(tenantID, req)
+	if err != nil {
+		return ldapDirectoryError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "保存LDAP目录配置", "ldap_directory", strconv.FormatUint(uint64(dir.ID), 10), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"data": ldapDirectoryResponse(dir)})
+}
+
+// DeleteLDAPDirectoryHandler 删除租户的 LDAP 目录配置，已绑定的账号解除绑定
+// DELETE /api/v1/tenant/ldap-directory
+func DeleteLDAPDirectoryHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	if err := ldapauth.NewService(common.DB()).DeleteDirectory(tenantID); err != nil {
+		return ldapDirectoryError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "删除LDAP目录配置", "ldap_directory", "", c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"message": "LDAP 目录已删除"})
+}
+
+// TestLDAPDirectoryHandler 测试目录连接与服务账号绑定
+// POST /api/v1/tenant/ldap-directory/test
+func TestLDAPDirectoryHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	if err := ldapauth.NewService(common.DB()).TestConnection(tenantID); err != nil {
+		return ldapDirectoryError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "连接成功"})
+}
+
+// SyncLDAPDirectoryHandler 立即按目录同步已绑定账号的资料与组角色
+// POST /api/v1/tenant/ldap-directory/sync
+func SyncLDAPDirectoryHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	result, err := ldapauth.NewService(common.DB()).SyncDirectory(tenantID)
+	if err != nil {
+		return ldapDirectoryError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "同步LDAP目录", "ldap_directory", "", c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"data": result})
+}
+
+// LinkLDAPUserHandler 将租户账号显式绑定到目录条目。
+// 已有本地密码或担任所有者、管理员的账号不会在目录登录时自动绑定，须由管理员在此确认。
+// POST /api/v1/tenant/ldap-directory/links
+func LinkLDAPUserHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+
+	var req struct {
+		UserID   uint   `json:"user_id"`
+		Username string `json:"username"` // 目录登录名，按用户过滤器查找条目
+	}
+	if err := c.BodyParser(&req); err != nil || req.UserID == 0 || req.Username == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数错误"})
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	user, err := ldapauth.NewService(common.DB()).LinkUser(tenantID, req.UserID, req.Username, operatorID)
+	if err != nil {
+		return ldapDirectoryError(c, err)
+	}
+
+	aduit.LogAudit(operatorID, "绑定LDAP目录账号", "user", strconv.FormatUint(uint64(user.ID), 10), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"message": "账号已绑定到目录"})
+}
@@ -0,0 +1,104 @@
+package tenant
+
+import (
+	"errors"
+	"strconv"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/service/aduit"
+	samlsvc "basaltpass-backend/internal/service/saml"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func samlServiceProviderError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, samlsvc.ErrServiceProviderNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "应用或 SAML 配置不存在"})
+	case errors.Is(err, samlsvc.ErrInvalidServiceProvider):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "保存 SAML 配置失败"})
+	}
+}
+
+// samlIdentityProviderInfo 返回租户 IdP 的端点，供管理员填写到 SP 一侧
+func samlIdentityProviderInfo(c *fiber.Ctx, svc *samlsvc.Service, tenantID uint, appID string) (fiber.Map, error) {
+	idp, err := svc.IdentityProviderForTenant(c.BaseURL(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"entity_id":         idp.EntityID,
+		"metadata_url":      idp.EntityID,
+		"sso_url":           idp.SSOURL,
+		"slo_url":           idp.SLOURL,
+		"idp_initiated_url": c.BaseURL() + samlsvc.PathPrefix + idp.TenantCode + "/apps/" + appID + "/sso",
+	}, nil
+}
+
+// GetAppSAMLHandler 获取应用登记的 SAML SP 及租户 IdP 端点；未登记时 data 为 null
+// GET /api/v1/tenant/apps/:app_id/saml
+func GetAppSAMLHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	svc := samlsvc.NewService(common.DB())
+	idp, err := samlIdentityProviderInfo(c, svc, tenantID, c.Params("app_id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取 SAML 配置失败"})
+	}
+	sp, err := svc.GetServiceProvider(tenantID, uint(appID))
+	if errors.Is(err, samlsvc.ErrServiceProviderNotFound) {
+		return c.JSON(fiber.Map{"data": nil, "identity_provider": idp})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取 SAML 配置失败"})
+	}
+	return c.JSON(fiber.Map{"data": sp, "identity_provider": idp})
+}
+
+// SaveAppSAMLHandler 登记或更新应用的 SAML SP
+// PUT /api/v1/tenant/apps/:app_id/saml
+func SaveAppSAMLHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	var req samlsvc.ServiceProviderInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求参数错误"})
+	}
+
+	sp, err := samlsvc.NewService(common.DB()).SaveServiceProvider(tenantID, uint(appID), req)
+	if err != nil {
+		return samlServiceProviderError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "保存应用SAML配置", "saml_service_provider", strconv.FormatUint(uint64(sp.ID), 10), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"data": sp})
+}
+
+// DeleteAppSAMLHandler 删除应用的 SAML SP，已签发的 SAML 会话一并清除
+// DELETE /api/v1/tenant/apps/:app_id/saml
+func DeleteAppSAMLHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	appID, err := strconv.ParseUint(c.Params("app_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的应用ID"})
+	}
+
+	if err := samlsvc.NewService(common.DB()).DeleteServiceProvider(tenantID, uint(appID)); err != nil {
+		return samlServiceProviderError(c, err)
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "删除应用SAML配置", "saml_service_provider", c.Params("app_id"), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"message": "SAML 配置已删除"})
+}
@@ -0,0 +1,72 @@
+package tenant
+
+import (
+	"errors"
+
+	"basaltpass-backend/internal/common"
+	userdto "basaltpass-backend/internal/dto/user"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/mfa"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ResetTenantUserMFAHandler 重置租户用户在本租户下的两步验证（用户丢失身份验证器时），需操作者重新验证身份
+// POST /tenant/users/:id/mfa/reset
+func ResetTenantUserMFAHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	userID, tenantUser, status, message := loadTenantSessionTarget(c, tenantID)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// 租户管理员不能重置租户所有者的两步验证
+	if tenantUser != nil && tenantUser.Role == model.TenantRoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "不能重置租户所有者的两步验证",
+		})
+	}
+
+	var req userdto.ResetUserMFARequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请求参数错误",
+		})
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	mfaSvc := mfa.NewService(common.DB())
+	if err := mfaSvc.VerifyOperator(operatorID, tenantID, req.Password, req.Code); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// 租户管理员只能重置本租户下的配置，忽略请求中的 tenant_id
+	result, err := mfaSvc.ResetMFA(mfa.ResetParams{
+		UserID:         userID,
+		OperatorID:     operatorID,
+		TenantID:       &tenantID,
+		RemovePasskeys: req.RemovePasskeys,
+		Reason:         req.Reason,
+		IP:             c.IP(),
+		UserAgent:      c.Get("User-Agent"),
+	})
+	if err != nil {
+		if errors.Is(err, mfa.ErrReasonRequired) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "重置两步验证失败",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "两步验证已重置",
+		"data":    result,
+	})
+}
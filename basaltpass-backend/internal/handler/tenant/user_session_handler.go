@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"strconv"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/consolesession"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// loadTenantSessionTarget 解析路径中的用户ID并确认用户属于当前租户；失败时返回状态码与错误信息
+func loadTenantSessionTarget(c *fiber.Ctx, tenantID uint) (uint, *model.TenantUser, int, string) {
+	userIDUint64, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, nil, fiber.StatusBadRequest, "无效的用户ID"
+	}
+	userID := uint(userIDUint64)
+
+	_, tenantUser, belongs, err := loadTenantMembership(userID, tenantID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil, fiber.StatusNotFound, "用户不存在"
+		}
+		return 0, nil, fiber.StatusInternalServerError, "查询用户失败"
+	}
+	if !belongs {
+		return 0, nil, fiber.StatusNotFound, "用户不属于该租户"
+	}
+	return userID, tenantUser, 0, ""
+}
+
+// GetTenantUserSessionsHandler 获取租户用户在本租户下的有效控制台登录会话
+// GET /tenant/users/:id/sessions
+func GetTenantUserSessionsHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	userID, _, status, message := loadTenantSessionTarget(c, tenantID)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	sessions, err := consolesession.NewService(common.DB()).ListActiveInTenant(userID, tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "获取登录会话失败",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": sessions,
+	})
+}
+
+// RevokeTenantUserSessionsHandler 撤销租户用户在本租户下的全部控制台登录会话（强制下线）
+// DELETE /tenant/users/:id/sessions
+func RevokeTenantUserSessionsHandler(c *fiber.Ctx) error {
+	tenantID := c.Locals("tenantID").(uint)
+	userID, tenantUser, status, message := loadTenantSessionTarget(c, tenantID)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// 不能强制租户所有者下线
+	if tenantUser != nil && tenantUser.Role == model.TenantRoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "不能撤销租户所有者的会话",
+		})
+	}
+
+	revoked, err := consolesession.NewService(common.DB()).RevokeAllInTenant(userID, tenantID, model.ConsoleSessionRevokedAdmin)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "撤销会话失败",
+		})
+	}
+
+	operatorID, _ := c.Locals("userID").(uint)
+	aduit.LogAudit(operatorID, "撤销用户登录会话", "user", strconv.FormatUint(uint64(userID), 10), c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(fiber.Map{
+		"message": "用户会话已撤销",
+		"revoked": revoked,
+	})
+}
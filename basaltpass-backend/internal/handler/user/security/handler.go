@@ -4,6 +4,7 @@ import (
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
 	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/mfa"
 	notif "basaltpass-backend/internal/service/notification"
 	securityservice "basaltpass-backend/internal/service/security"
 	"basaltpass-backend/internal/utils"
@@ -22,13 +23,14 @@ import (
 
 // SecurityStatus represents user's security status
 type SecurityStatus struct {
-	PasswordSet   bool   `json:"password_set"`
-	TwoFAEnabled  bool   `json:"two_fa_enabled"`
-	PasskeysCount int    `json:"passkeys_count"`
-	Email         string `json:"email"`
-	Phone         string `json:"phone,omitempty"`
-	EmailVerified bool   `json:"email_verified"`
-	PhoneVerified bool   `json:"phone_verified"`
+	PasswordSet            bool   `json:"password_set"`
+	TwoFAEnabled           bool   `json:"two_fa_enabled"`
+	RecoveryCodesRemaining int64  `json:"recovery_codes_remaining"`
+	PasskeysCount          int    `json:"passkeys_count"`
+	Email                  string `json:"email"`
+	Phone                  string `json:"phone,omitempty"`
+	EmailVerified          bool   `json:"email_verified"`
+	PhoneVerified          bool   `json:"phone_verified"`
 }
 
 // ChangePasswordRequest for password change
@@ -76,15 +78,20 @@ func GetSecurityStatusHandler(c *fiber.Ctx) error {
 	if err := common.DB().Where("user_id = ? AND tenant_id = ?", uid, tenantID).First(&totpCfg).Error; err == nil {
 		totpEnabled = totpCfg.Enabled
 	}
+	var recoveryCodesRemaining int64
+	if totpEnabled {
+		recoveryCodesRemaining, _ = mfa.NewService(common.DB()).RemainingRecoveryCodes(uid, tenantID)
+	}
 
 	status := SecurityStatus{
-		PasswordSet:   user.PasswordHash != "",
-		TwoFAEnabled:  totpEnabled,
-		PasskeysCount: int(passkeysCount),
-		Email:         user.Email,
-		Phone:         user.Phone,
-		EmailVerified: user.EmailVerified,
-		PhoneVerified: user.PhoneVerified,
+		PasswordSet:            user.PasswordHash != "",
+		TwoFAEnabled:           totpEnabled,
+		RecoveryCodesRemaining: recoveryCodesRemaining,
+		PasskeysCount:          int(passkeysCount),
+		Email:                  user.Email,
+		Phone:                  user.Phone,
+		EmailVerified:          user.EmailVerified,
+		PhoneVerified:          user.PhoneVerified,
 	}
 
 	return c.JSON(status)
@@ -243,6 +250,9 @@ func Disable2FAHandler(c *fiber.Ctx) error {
 	if err := common.DB().Model(&totpCfg).Updates(updates).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "禁用失败"})
 	}
+	if err := mfa.NewService(common.DB()).DeleteRecoveryCodes(uid, tenantID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "删除恢复码失败"})
+	}
 
 	aduit.LogAudit(uid, "禁用两步验证", "", "", c.IP(), c.Get("User-Agent"))
 
@@ -419,9 +429,51 @@ func VerifyHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "启用失败"})
 	}
 
+	// 启用时生成一组恢复码，明文只返回这一次
+	codes, err := mfa.NewService(common.DB()).GenerateRecoveryCodes(uid, tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "生成恢复码失败"})
+	}
+
 	aduit.LogAudit(uid, "启用两步验证", "", "", c.IP(), c.Get("User-Agent"))
 
-	return c.SendStatus(fiber.StatusNoContent)
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodesHandler 校验 TOTP 码后重新生成当前租户的恢复码，旧恢复码全部失效
+func RegenerateRecoveryCodesHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+	tenantID := getTenantID(c)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请求格式错误"})
+	}
+
+	var totpCfg model.UserTenantTOTP
+	if err := common.DB().Where("user_id = ? AND tenant_id = ? AND enabled = ?", uid, tenantID, true).First(&totpCfg).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "两步验证未启用"})
+	}
+
+	rawSecret, err := utils.DecryptTOTPSecret(totpCfg.Secret)
+	if err != nil || rawSecret == "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "TOTP 密钥解密失败"})
+	}
+
+	if !totp.Validate(body.Code, rawSecret) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "验证码无效"})
+	}
+
+	codes, err := mfa.NewService(common.DB()).GenerateRecoveryCodes(uid, tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "生成恢复码失败"})
+	}
+
+	aduit.LogAudit(uid, "重新生成恢复码", "", "", c.IP(), c.Get("User-Agent"))
+
+	return c.JSON(fiber.Map{"recovery_codes": codes})
 }
 
 // generateEmailVerificationCode 生成6位纯数字验证码（加密安全随机）
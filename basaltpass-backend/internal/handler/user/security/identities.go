@@ -0,0 +1,127 @@
+package security
+
+import (
+	"errors"
+	"strconv"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/federation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListIdentitiesHandler 返回当前用户已绑定的外部身份，以及当前租户下可绑定的身份提供方
+// GET /api/v1/security/identities
+func ListIdentitiesHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+	tenantID, _ := c.Locals("tenantID").(uint)
+	fedSvc := federation.NewService(common.DB())
+
+	links, err := fedSvc.ListLinks(uid)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取外部身份失败"})
+	}
+	providers, err := fedSvc.ListEnabledProviders(tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取身份提供方失败"})
+	}
+
+	linked := make(map[uint]bool, len(links))
+	linkItems := make([]fiber.Map, 0, len(links))
+	for _, link := range links {
+		linked[link.ProviderID] = true
+		linkItems = append(linkItems, fiber.Map{
+			"id":            link.ID,
+			"provider_id":   link.ProviderID,
+			"provider_name": link.Provider.Name,
+			"preset":        link.Provider.Preset,
+			"email":         link.Email,
+			"display_name":  link.DisplayName,
+			"last_login_at": link.LastLoginAt,
+			"created_at":    link.CreatedAt,
+		})
+	}
+	providerItems := make([]fiber.Map, 0, len(providers))
+	for _, p := range providers {
+		providerItems = append(providerItems, fiber.Map{
+			"id":     p.ID,
+			"slug":   p.Slug,
+			"name":   p.Name,
+			"preset": p.Preset,
+			"linked": linked[p.ID],
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": fiber.Map{
+		"links":     linkItems,
+		"providers": providerItems,
+	}})
+}
+
+// LinkIdentityHandler 发起外部身份绑定，返回上游授权地址，由前端跳转；完成后回到安全设置页
+// POST /api/v1/security/identities/:provider_id/link
+func LinkIdentityHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+	tenantID, _ := c.Locals("tenantID").(uint)
+	providerID, err := strconv.ParseUint(c.Params("provider_id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的身份提供方ID"})
+	}
+
+	fedSvc := federation.NewService(common.DB())
+	provider, err := fedSvc.GetProvider(tenantID, uint(providerID))
+	if err != nil || !provider.Enabled {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "身份提供方不存在或未启用"})
+	}
+
+	authorization, err := fedSvc.AuthorizationURL(provider, federation.AuthorizeParams{
+		Intent:      model.FederatedIntentLink,
+		UserID:      uid,
+		RedirectURI: federation.CallbackURL(c.BaseURL()),
+		ReturnTo:    federation.SafeReturnTo(c.Query("return_to")),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "身份提供方暂不可用"})
+	}
+	SetFederationBindingCookie(c, authorization.Binding)
+	return c.JSON(fiber.Map{"data": fiber.Map{"authorization_url": authorization.URL}})
+}
+
+// SetFederationBindingCookie 将联合登录的 state 绑定到当前浏览器，回调时校验
+func SetFederationBindingCookie(c *fiber.Ctx, binding string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     federation.BindingCookieName,
+		Value:    binding,
+		Path:     federation.CallbackPath,
+		MaxAge:   federation.BindingCookieMaxAge,
+		HTTPOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: "Lax",
+	})
+}
+
+// UnlinkIdentityHandler 解除外部身份绑定
+// DELETE /api/v1/security/identities/:id
+func UnlinkIdentityHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+	linkID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的绑定ID"})
+	}
+
+	link, err := federation.NewService(common.DB()).Unlink(uid, uint(linkID))
+	switch {
+	case errors.Is(err, federation.ErrLinkNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "绑定不存在"})
+	case errors.Is(err, federation.ErrLastLoginMethod):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "这是账号唯一的登录方式，请先设置密码或绑定其它登录方式"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "解除绑定失败"})
+	}
+
+	aduit.LogAudit(uid, "解绑外部身份", "identity_provider", strconv.FormatUint(uint64(link.ProviderID), 10), c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"message": "已解除绑定"})
+}
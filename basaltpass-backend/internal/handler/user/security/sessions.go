@@ -0,0 +1,83 @@
+package security
+
+import (
+	"errors"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/consolesession"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListSessionsHandler 返回当前用户的有效控制台登录会话
+// GET /api/v1/security/sessions
+func ListSessionsHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+	currentSessionID, _ := c.Locals("sessionID").(string)
+
+	sessions, err := consolesession.NewService(common.DB()).ListActive(uid)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "获取登录会话失败"})
+	}
+
+	items := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, fiber.Map{
+			"session_id":   session.SessionID,
+			"scope":        session.Scope,
+			"tenant_id":    session.TenantID,
+			"device":       session.Device,
+			"ip_address":   session.IPAddress,
+			"user_agent":   session.UserAgent,
+			"auth_time":    session.AuthTime,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"created_at":   session.CreatedAt,
+			"current":      session.SessionID == currentSessionID,
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": items})
+}
+
+// RevokeSessionHandler 撤销当前用户的指定会话，该会话的访问令牌与刷新令牌立即失效
+// DELETE /api/v1/security/sessions/:session_id
+func RevokeSessionHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+	sessionID := c.Params("session_id")
+
+	err := consolesession.NewService(common.DB()).Revoke(uid, sessionID, model.ConsoleSessionRevokedUser)
+	if errors.Is(err, consolesession.ErrSessionInactive) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "会话不存在或已失效"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "撤销会话失败"})
+	}
+
+	aduit.LogAudit(uid, "撤销登录会话", "console_session", sessionID, c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{"message": "会话已撤销"})
+}
+
+// RevokeAllSessionsHandler 撤销当前用户的其它会话（退出其它设备）；include_current=true 时连同当前会话一起撤销
+// DELETE /api/v1/security/sessions
+func RevokeAllSessionsHandler(c *fiber.Ctx) error {
+	uid := c.Locals("userID").(uint)
+
+	exceptSessionID := ""
+	if !c.QueryBool("include_current") {
+		exceptSessionID, _ = c.Locals("sessionID").(string)
+	}
+
+	revoked, err := consolesession.NewService(common.DB()).RevokeAll(uid, exceptSessionID, model.ConsoleSessionRevokedUser)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "撤销会话失败"})
+	}
+
+	aduit.LogAudit(uid, "撤销全部登录会话", "console_session", "", c.IP(), c.Get("User-Agent"))
+	return c.JSON(fiber.Map{
+		"message": "会话已撤销",
+		"revoked": revoked,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/middleware/transport"
 	serviceauth "basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/consolesession"
 	"errors"
 	"strconv"
 	"strings"
@@ -33,13 +34,7 @@ func ParseJWTToken(tokenStr string, ignoreClaimsValidation bool) (*jwt.Token, jw
 		parserOpts = append(parserOpts, jwt.WithoutClaimsValidation())
 	}
 
-	parser := jwt.NewParser(parserOpts...)
-	token, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrTokenSignatureInvalid
-		}
-		return common.MustJWTSecret(), nil
-	})
+	token, err := serviceauth.ParseConsoleToken(tokenStr, parserOpts...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -73,6 +68,14 @@ func JWTMiddleware() fiber.Handler {
 			return transport.APIErrorResponse(c, fiber.StatusUnauthorized, "auth_invalid_token", "[Basalt Auth] invalid token")
 		}
 
+		// 携带 sid 的令牌需对应未撤销的控制台会话；会话登记之前签发的旧令牌在其有效期内仍可使用
+		if sessionID := serviceauth.SessionIDFromClaims(claims); sessionID != "" {
+			if err := consolesession.NewService(common.DB()).Validate(sessionID); err != nil {
+				return transport.APIErrorResponse(c, fiber.StatusUnauthorized, "auth_session_revoked", "[Basalt Auth] session revoked")
+			}
+			c.Locals("sessionID", sessionID)
+		}
+
 		if userID, exists := claims["sub"]; exists {
 			switch typed := userID.(type) {
 			case float64:
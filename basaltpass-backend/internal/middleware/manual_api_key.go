@@ -18,56 +18,62 @@ func ManualAPIKeyAuthMiddleware() fiber.Handler {
 			})
 		}
 
-		prefix := model.ManualAPIKeyPrefix(plain)
-		if prefix == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid api key format",
+		matched, status, msg := lookupManualAPIKey(plain)
+		if matched == nil {
+			return c.Status(status).JSON(fiber.Map{
+				"error": msg,
 			})
 		}
 
-		var candidates []model.ManualAPIKey
-		if err := common.DB().
-			Where("key_prefix = ? AND is_active = ?", prefix, true).
-			Find(&candidates).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "manual api key lookup failed",
-			})
-		}
-		if len(candidates) == 0 {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid api key",
-			})
-		}
+		setManualAPIKeyLocals(c, matched)
+		return c.Next()
+	}
+}
 
-		now := time.Now()
-		var matched *model.ManualAPIKey
-		for i := range candidates {
-			key := &candidates[i]
-			if key.ExpiresAt != nil && key.ExpiresAt.Before(now) {
-				continue
-			}
-			if key.VerifyPlainKey(plain) {
-				matched = key
-				break
-			}
-		}
-		if matched == nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid api key",
-			})
-		}
+// lookupManualAPIKey 校验明文 API Key 并更新最近使用时间；失败时返回 HTTP 状态码与错误信息
+func lookupManualAPIKey(plain string) (*model.ManualAPIKey, int, string) {
+	prefix := model.ManualAPIKeyPrefix(plain)
+	if prefix == "" {
+		return nil, fiber.StatusUnauthorized, "invalid api key format"
+	}
 
-		_ = common.DB().Model(&model.ManualAPIKey{}).
-			Where("id = ?", matched.ID).
-			Update("last_used_at", now).Error
+	var candidates []model.ManualAPIKey
+	if err := common.DB().
+		Where("key_prefix = ? AND is_active = ?", prefix, true).
+		Find(&candidates).Error; err != nil {
+		return nil, fiber.StatusInternalServerError, "manual api key lookup failed"
+	}
+	if len(candidates) == 0 {
+		return nil, fiber.StatusUnauthorized, "invalid api key"
+	}
 
-		c.Locals("manualAPIKeyID", matched.ID)
-		c.Locals("manualAPIKeyScope", string(matched.Scope))
-		if matched.TenantID != nil {
-			c.Locals("manualAPIKeyTenantID", *matched.TenantID)
+	now := time.Now()
+	var matched *model.ManualAPIKey
+	for i := range candidates {
+		key := &candidates[i]
+		if key.ExpiresAt != nil && key.ExpiresAt.Before(now) {
+			continue
 		}
-		c.Locals("manualAPIKeyCreatorUserID", matched.CreatedByUserID)
+		if key.VerifyPlainKey(plain) {
+			matched = key
+			break
+		}
+	}
+	if matched == nil {
+		return nil, fiber.StatusUnauthorized, "invalid api key"
+	}
 
-		return c.Next()
+	_ = common.DB().Model(&model.ManualAPIKey{}).
+		Where("id = ?", matched.ID).
+		Update("last_used_at", now).Error
+	return matched, fiber.StatusOK, ""
+}
+
+func setManualAPIKeyLocals(c *fiber.Ctx, key *model.ManualAPIKey) {
+	c.Locals("manualAPIKeyID", key.ID)
+	c.Locals("manualAPIKeyScope", string(key.Scope))
+	if key.TenantID != nil {
+		c.Locals("manualAPIKeyTenantID", *key.TenantID)
 	}
+	c.Locals("manualAPIKeyCreatorUserID", key.CreatedByUserID)
 }
@@ -26,6 +26,10 @@ const (
 	// 第三层：verify-2fa IP + user_id，防 TOTP/SMS 枚举
 	twoFAComboLimit  = 5
 	twoFAComboWindow = 15 * time.Minute
+
+	// 第四层：设备授权 user_code 查询与确认，按用户计数，防用户码枚举（RFC 8628 §5.1）
+	deviceUserLimit  = 10
+	deviceUserWindow = 15 * time.Minute
 )
 
 // checkRateLimitDetailed 扩展版限速检查，额外返回剩余封锁时间（秒）。
@@ -165,6 +169,50 @@ func Verify2FARateLimit() fiber.Handler {
 	}
 }
 
+// DeviceVerificationRateLimit 为设备授权的用户码查询与确认（/user/oauth/device）添加频率限制：
+//   - 第一层：同一 IP 每分钟最多 20 次
+//   - 第二层：同一用户在 15 分钟内最多 10 次（防 user_code 枚举）
+//
+// 需挂在 JWTMiddleware 之后，以读取当前用户。
+func DeviceVerificationRateLimit() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ip := normalizeIP(c.IP())
+
+		// ── 第一层：IP 维度 ──────────────────────────────────────
+		ipKey := fmt.Sprintf("device_ip:%s", ip)
+		allowed, retryAfter, err := checkRateLimitDetailed(ipKey, RateLimitConfig{
+			Limit:    loginIPLimit,
+			Window:   loginIPWindow,
+			Category: "device_ip",
+		})
+		if err != nil {
+			return c.Next()
+		}
+		if !allowed {
+			return rateLimitResponse(c, retryAfter, "请求过于频繁，请稍后再试")
+		}
+
+		// ── 第二层：用户维度 ─────────────────────────────────────
+		if userID, ok := c.Locals("userID").(uint); ok && userID > 0 {
+			userKey := fmt.Sprintf("device_user:%d", userID)
+			allowed2, retryAfter2, err2 := checkRateLimitDetailed(userKey, RateLimitConfig{
+				Limit:    deviceUserLimit,
+				Window:   deviceUserWindow,
+				Category: "device_user",
+			})
+			if err2 != nil {
+				return c.Next()
+			}
+			if !allowed2 {
+				return rateLimitResponse(c, retryAfter2,
+					fmt.Sprintf("用户码尝试次数过多，已暂时锁定，请 %d 秒后再试", retryAfter2))
+			}
+		}
+
+		return c.Next()
+	}
+}
+
 // ── 内部辅助函数 ──────────────────────────────────────────────────────────────
 
 // normalizeIP 去除 IPv6 链路本地地址的 zone ID（如 fe80::1%eth0 → fe80::1）
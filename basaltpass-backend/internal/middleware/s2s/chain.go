@@ -5,7 +5,11 @@ import (
 	"basaltpass-backend/internal/config"
 	"basaltpass-backend/internal/middleware/transport"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/accesstoken"
+	"basaltpass-backend/internal/service/clientassertion"
 	scopesvc "basaltpass-backend/internal/service/scope"
+	"basaltpass-backend/internal/service/signingkey"
+	"basaltpass-backend/internal/utils"
 	"log"
 	"strings"
 	"sync"
@@ -20,6 +24,16 @@ func s2sEnvelopeError(c *fiber.Ctx, status int, code, message string) error {
 
 func ClientAuthMiddleware(requiredScopes ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// 优先使用 client_credentials 签发的 Bearer 令牌
+		if token, ok := bearerToken(c.Get("Authorization")); ok {
+			return authenticateBearer(c, token, requiredScopes)
+		}
+
+		// private_key_jwt 客户端以 JWT 断言代替密钥（RFC 7523）
+		if assertion := strings.TrimSpace(c.FormValue("client_assertion")); assertion != "" {
+			return authenticateAssertion(c, assertion, requiredScopes)
+		}
+
 		clientID := strings.TrimSpace(c.Get("client_id"))
 		clientSecret := strings.TrimSpace(c.Get("client_secret"))
 
@@ -53,27 +67,86 @@ func ClientAuthMiddleware(requiredScopes ...string) fiber.Handler {
 			return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_client", "Client not found or inactive")
 		}
 
-		if !client.VerifyClientSecret(clientSecret) {
+		if !client.UsesClientSecret() || !client.VerifyClientSecret(clientSecret) {
 			return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_client", "Client secret mismatch")
 		}
 
-		clientScopes := client.GetScopeList()
-		c.Locals("s2s_scopes", clientScopes)
+		return authorizeClient(c, &client, client.GetScopeList(), requiredScopes)
+	}
+}
 
-		if len(requiredScopes) > 0 {
-			if !scopesvc.SatisfiesAll(clientScopes, requiredScopes) {
-				return s2sEnvelopeError(c, fiber.StatusForbidden, "insufficient_scope", "Client lacks required scope")
-			}
-		}
+// authenticateBearer 校验 client_credentials 令牌，令牌scope即为本次调用可用的scope
+func authenticateBearer(c *fiber.Ctx, token string, requiredScopes []string) error {
+	db := common.DB()
+	// 数据库只保存令牌摘要；JWT 访问令牌（RFC 9068）先验签，再以 jti 计算摘要
+	key := accesstoken.NewService(signingkey.NewService(db)).StorageKey(token)
+	if key == "" {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_token", "Access token signature is invalid")
+	}
+	var accessToken model.OAuthAccessToken
+	if err := db.Where("token = ?", key).First(&accessToken).Error; err != nil {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_token", "Access token not found")
+	}
+	if accessToken.IsExpired() {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_token", "Access token expired")
+	}
+	if !accessToken.IsClientToken() {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_token", "Access token was not issued via client_credentials")
+	}
+
+	var client model.OAuthClient
+	if err := db.Preload("App").Where("client_id = ? AND is_active = ?", accessToken.ClientID, true).First(&client).Error; err != nil {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_client", "Client not found or inactive")
+	}
+
+	return authorizeClient(c, &client, accessToken.GetScopeList(), requiredScopes)
+}
 
-		c.Locals("s2s_client_id", client.ClientID)
-		c.Locals("s2s_app_id", client.AppID)
-		if client.App.ID != 0 {
-			c.Locals("s2s_tenant_id", client.App.TenantID)
+// authenticateAssertion 校验 client_assertion；aud 可为 issuer、令牌端点或当前端点地址
+func authenticateAssertion(c *fiber.Ctx, assertion string, requiredScopes []string) error {
+	if strings.TrimSpace(c.FormValue("client_assertion_type")) != clientassertion.AssertionType {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_client", "Unsupported client_assertion_type")
+	}
+
+	client, err := clientassertion.NewService(common.DB()).Verify(assertion, clientassertion.Audiences(utils.RequestBaseURL(c), c.Path()))
+	if err != nil {
+		log.Printf("[S2S] client assertion rejected: %v", err)
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_client", "Client assertion is invalid")
+	}
+	if clientID := strings.TrimSpace(c.FormValue("client_id")); clientID != "" && clientID != client.ClientID {
+		return s2sEnvelopeError(c, fiber.StatusUnauthorized, "invalid_client", "client_id does not match the assertion")
+	}
+
+	return authorizeClient(c, client, client.GetScopeList(), requiredScopes)
+}
+
+// authorizeClient 校验scope并向上下文注入客户端信息
+func authorizeClient(c *fiber.Ctx, client *model.OAuthClient, scopes []string, requiredScopes []string) error {
+	c.Locals("s2s_scopes", scopes)
+
+	if len(requiredScopes) > 0 {
+		if !scopesvc.SatisfiesAll(scopes, requiredScopes) {
+			return s2sEnvelopeError(c, fiber.StatusForbidden, "insufficient_scope", "Client lacks required scope")
 		}
+	}
 
-		return c.Next()
+	c.Locals("s2s_client", client)
+	c.Locals("s2s_client_id", client.ClientID)
+	c.Locals("s2s_app_id", client.AppID)
+	if client.App.ID != 0 {
+		c.Locals("s2s_tenant_id", client.App.TenantID)
+	}
+
+	return c.Next()
+}
+
+func bearerToken(authHeader string) (string, bool) {
+	parts := strings.SplitN(strings.TrimSpace(authHeader), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
 	}
+	token := strings.TrimSpace(parts[1])
+	return token, token != ""
 }
 
 func ClientScopeMiddleware(requiredScopes ...string) fiber.Handler {
@@ -8,13 +8,15 @@ import (
 
 // ClientAuthMiddleware 验证服务间调用（S2S）客户端身份。
 // 支持以下凭证方式（按优先级）：
-// 1) Header: client_id / client_secret
-// 2) Form: client_id / client_secret（POST 表单）
-// 3) Query: client_id / client_secret（不推荐，仅用于内部）
+// 1) Header: Authorization: Bearer <access_token>（通过 client_credentials 授权获取）
+// 2) Form: client_assertion / client_assertion_type（private_key_jwt，RFC 7523）
+// 3) Header: client_id / client_secret
+// 4) Form: client_id / client_secret（POST 表单）
+// 5) Query: client_id / client_secret（不推荐，仅用于内部）
 // 校验项：
 // - OAuthClient 是否存在且激活
-// - client_secret 验证
-// - 可选：GrantTypes 包含 client_credentials（若配置）
+// - client_secret 验证、client_assertion 验签（aud、exp、jti 防重放），或 Bearer 令牌有效、未过期且为客户端令牌
+// - 使用 Bearer 令牌时按令牌scope（而非客户端全部scope）鉴权
 // 认证成功后，向上下文注入：c.Locals("s2s_client_id"), c.Locals("s2s_app_id"), c.Locals("s2s_tenant_id")
 func ClientAuthMiddleware(requiredScopes ...string) fiber.Handler {
 	return s2smw.ClientAuthMiddleware(requiredScopes...)
@@ -1,11 +1,20 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/glebarez/sqlite"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 func TestClientScopeMiddleware_RejectsInsufficientScope(t *testing.T) {
@@ -48,3 +57,106 @@ func TestClientScopeMiddleware_AllowsRequiredScope(t *testing.T) {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
 }
+
+func setupS2SBearerTestDB(t *testing.T) (*gorm.DB, model.OAuthClient) {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.App{}, &model.OAuthClient{}, &model.OAuthAccessToken{}); err != nil {
+		t.Fatalf("failed to migrate test schema: %v", err)
+	}
+	common.SetDBForTest(db)
+
+	app := model.App{TenantID: 7, Name: "S2S App", Status: model.AppStatusActive}
+	if err := db.Create(&app).Error; err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+	client := model.OAuthClient{
+		AppID:        app.ID,
+		ClientID:     "s2s-bearer-client",
+		ClientSecret: "unused",
+		RedirectURIs: "https://svc.example.com/cb",
+		Scopes:       "s2s.user.read,s2s.user.write",
+		GrantTypes:   "client_credentials",
+		IsActive:     true,
+		CreatedBy:    1,
+	}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return db, client
+}
+
+func TestClientAuthMiddleware_AcceptsClientCredentialsBearer(t *testing.T) {
+	db, client := setupS2SBearerTestDB(t)
+
+	// 数据库只保存令牌摘要，请求中携带明文
+	const rawClientToken, rawUserToken = "bp_at_client", "bp_at_user"
+	clientToken := model.OAuthAccessToken{
+		Token:     utils.HashOAuthToken(rawClientToken),
+		ClientID:  client.ClientID,
+		TenantID:  7,
+		AppID:     client.AppID,
+		Scopes:    "s2s.user.read",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	userToken := model.OAuthAccessToken{
+		Token:     utils.HashOAuthToken(rawUserToken),
+		ClientID:  client.ClientID,
+		UserID:    42,
+		TenantID:  7,
+		AppID:     client.AppID,
+		Scopes:    "s2s.user.read",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := db.Create(&clientToken).Error; err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if err := db.Create(&userToken).Error; err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	newApp := func(required ...string) *fiber.App {
+		app := fiber.New()
+		app.Use(ClientAuthMiddleware(required...))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{
+				"client_id": c.Locals("s2s_client_id"),
+				"tenant_id": c.Locals("s2s_tenant_id"),
+			})
+		})
+		return app
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+rawClientToken)
+	resp, body := doRequestAndDecode(t, newApp("s2s.user.read"), req)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d (%v)", resp.StatusCode, body)
+	}
+	if body["client_id"] != client.ClientID || body["tenant_id"] != float64(7) {
+		t.Fatalf("unexpected client context %v", body)
+	}
+
+	// 令牌scope小于客户端scope时按令牌scope鉴权
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+rawClientToken)
+	resp, _ = doRequestAndDecode(t, newApp("s2s.user.write"), req)
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("expected 403 for scope outside token, got %d", resp.StatusCode)
+	}
+
+	// 用户令牌不能用于 S2S 调用
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+rawUserToken)
+	resp, _ = doRequestAndDecode(t, newApp(), req)
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("expected 401 for user token, got %d", resp.StatusCode)
+	}
+}
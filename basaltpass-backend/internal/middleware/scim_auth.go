@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/scim"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SCIMAuthMiddleware SCIM 端点认证：租户 API Key 以 Bearer 令牌（或 X-API-Key）传入，
+// 租户由 Key 决定；错误按 SCIM 格式返回
+func SCIMAuthMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		plain := strings.TrimSpace(c.Get("X-API-Key"))
+		if auth := strings.TrimSpace(c.Get(fiber.HeaderAuthorization)); plain == "" && len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			plain = strings.TrimSpace(auth[7:])
+		}
+		if plain == "" {
+			return scimAuthError(c, fiber.StatusUnauthorized, "missing api key")
+		}
+
+		matched, status, msg := lookupManualAPIKey(plain)
+		if matched == nil {
+			return scimAuthError(c, status, msg)
+		}
+		// 仅接受绑定租户的 Key：租户 Key，或显式绑定租户的管理员 Key
+		if matched.TenantID == nil || *matched.TenantID == 0 {
+			return scimAuthError(c, fiber.StatusForbidden, "api key is not bound to a tenant")
+		}
+		if matched.Scope != model.ManualAPIKeyScopeTenant && matched.Scope != model.ManualAPIKeyScopeAdmin {
+			return scimAuthError(c, fiber.StatusForbidden, "api key scope is not allowed")
+		}
+
+		setManualAPIKeyLocals(c, matched)
+		return c.Next()
+	}
+}
+
+func scimAuthError(c *fiber.Ctx, status int, detail string) error {
+	if status == fiber.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="scim"`)
+	}
+	c.Set(fiber.HeaderContentType, scim.ContentType)
+	return c.Status(status).JSON((&scim.Error{Status: status, Detail: detail}).Body())
+}
@@ -154,10 +154,22 @@ func RunMigrations() {
 		&model.AuditLog{},
 		&model.LoginLog{},
 		&model.LoginHistory{},
+		&model.ConsoleSession{},
+		&model.IdentityProvider{},
+		&model.UserIdentityLink{},
+		&model.FederatedAuthState{},
+		&model.SAMLServiceProvider{},
+		&model.SAMLSession{},
+		&model.SCIMUser{},
+		&model.SCIMGroup{},
+		&model.LDAPDirectory{},
+		&model.LDAPUserLink{},
 		&model.PasswordReset{},
 		&model.Passkey{},
 		&model.TenantWebAuthnConfig{},
 		&model.UserTenantTOTP{},
+		&model.UserRecoveryCode{},
+		&model.AccountLockout{},
 		&model.SystemApp{},
 		&model.Notification{},
 		&model.UserNotificationSettings{},
@@ -195,6 +207,14 @@ func RunMigrations() {
 		&model.OAuthAuthorizationCode{},
 		&model.OAuthAccessToken{},
 		&model.OAuthRefreshToken{},
+		&model.OAuthDeviceCode{},
+		&model.OAuthInitialAccessToken{},
+		&model.OAuthClientAssertionJTI{},
+		&model.OAuthPushedAuthorizationRequest{},
+		&model.OAuthReauthMarker{},
+		&model.OAuthPairwiseSubject{},
+		&model.AppClaimMapping{},
+		&model.SigningKey{},
 		&model.RolePermission{},
 
 		// 订阅系统模型
@@ -282,6 +302,9 @@ func RunMigrations() {
 	migrateLegacyTOTPToTenantTable()
 	encryptExistingTOTPSecrets() // 对已存在的明文 TOTP 密钥补加密
 
+	// OAuth 令牌与授权码只保存摘要
+	hashExistingOAuthTokens()
+
 	createdWallets, err := wallet.EnsureCreditWalletsForAllUsers()
 	if err != nil {
 		log.Printf("[Migration] Failed to backfill credit wallets for all users: %v", err)
@@ -1124,6 +1147,58 @@ func encryptExistingTOTPSecrets() {
 	}
 }
 
+// hashExistingOAuthTokens 将历史明文保存的 OAuth 访问令牌、刷新令牌、授权码和设备码替换为摘要。
+// 已处理的记录带有 utils.OAuthTokenHashPrefix 前缀，重复执行是安全的。
+func hashExistingOAuthTokens() {
+	hashPlaintextColumn("access tokens", &model.OAuthAccessToken{}, "token", true)
+	hashPlaintextColumn("refresh tokens", &model.OAuthRefreshToken{}, "token", true)
+	hashPlaintextColumn("authorization codes", &model.OAuthAuthorizationCode{}, "code", false)
+	hashPlaintextColumn("device codes", &model.OAuthDeviceCode{}, "device_code", false)
+}
+
+// hashPlaintextColumn 按批次哈希指定表中尚未处理的列值，withPrefix 时同时回填 token_prefix
+func hashPlaintextColumn(name string, table interface{}, column string, withPrefix bool) {
+	db := common.DB()
+
+	type row struct {
+		ID    uint
+		Value string
+	}
+
+	hashed := 0
+	var lastID uint
+	for {
+		var rows []row
+		if err := db.Model(table).
+			Select("id, "+column+" AS value").
+			Where("id > ? AND "+column+" NOT LIKE ?", lastID, utils.OAuthTokenHashPrefix+"%").
+			Order("id ASC").Limit(500).
+			Scan(&rows).Error; err != nil {
+			log.Printf("[Migration] hashExistingOAuthTokens: query %s failed: %v", name, err)
+			return
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, r := range rows {
+			lastID = r.ID
+			updates := map[string]interface{}{column: utils.HashOAuthToken(r.Value)}
+			if withPrefix {
+				updates["token_prefix"] = utils.OAuthTokenDisplayPrefix(r.Value)
+			}
+			if err := db.Model(table).Where("id = ?", r.ID).Updates(updates).Error; err != nil {
+				log.Printf("[Migration] hashExistingOAuthTokens: %s id=%d failed: %v", name, r.ID, err)
+				continue
+			}
+			hashed++
+		}
+	}
+
+	if hashed > 0 {
+		log.Printf("[Migration] Hashed %d existing plaintext OAuth %s", hashed, name)
+	}
+}
+
 // (legacy) seedDefaultSystemSettings removed - settings are now stored in file.
 
 // seedDevData 在开发环境为全新库注入模拟数据
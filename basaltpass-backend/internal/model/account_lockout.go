@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// AccountLockout 账号登录失败计数与锁定状态，每个账号一条。
+// LockCount 为连续锁定次数，决定下一次锁定时长（指数退避），登录成功或解锁后清零。
+type AccountLockout struct {
+	ID                   uint       `gorm:"primaryKey" json:"id"`
+	UserID               uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	FailedCount          int        `gorm:"not null;default:0" json:"failed_count"`
+	WindowStartedAt      *time.Time `json:"window_started_at,omitempty"`
+	LastFailedAt         *time.Time `json:"last_failed_at,omitempty"`
+	LastFailedIP         string     `gorm:"size:64" json:"last_failed_ip,omitempty"`
+	LockCount            int        `gorm:"not null;default:0" json:"lock_count"`
+	LockedUntil          *time.Time `gorm:"index" json:"locked_until,omitempty"`
+	UnlockTokenHash      string     `gorm:"size:64;index" json:"-"`
+	UnlockTokenExpiresAt *time.Time `json:"-"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+func (AccountLockout) TableName() string {
+	return "system_account_lockouts"
+}
+
+// IsLocked 是否处于锁定期
+func (l *AccountLockout) IsLocked() bool {
+	return l.LockedUntil != nil && time.Now().Before(*l.LockedUntil)
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// 声明映射的取值来源
+const (
+	ClaimSourceTenantRoles    = "tenant_roles"    // 用户在租户下的角色代码
+	ClaimSourceAppRoles       = "app_roles"       // 用户在应用下的角色代码
+	ClaimSourceAppPermissions = "app_permissions" // 用户在应用下的权限代码（直接授予与角色继承）
+	ClaimSourceTeams          = "teams"           // 用户所在团队，Value 为 name（默认）或 id
+	ClaimSourceProfile        = "profile"         // 用户资料字段，Value 为字段名
+	ClaimSourceStatic         = "static"          // 固定值，Value 为 JSON 字面量
+)
+
+// 声明注入位置
+const (
+	ClaimTargetIDToken     = "id_token"
+	ClaimTargetUserInfo    = "userinfo"
+	ClaimTargetAccessToken = "access_token"
+	ClaimTargetSAML        = "saml" // SAML 断言的 AttributeStatement
+)
+
+// AppClaimMapping 应用自定义声明映射。
+// 授权范围包含 Scope（为空则不限）时，将 Source 解析出的值以 ClaimName 写入 Targets 指定的
+// id_token、userinfo、JWT 访问令牌或 SAML 断言属性中；Targets 为空表示全部位置。
+type AppClaimMapping struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AppID     uint      `gorm:"not null;uniqueIndex:idx_app_claim_name" json:"app_id"`
+	TenantID  uint      `gorm:"not null;index" json:"tenant_id"`
+	ClaimName string    `gorm:"size:100;not null;uniqueIndex:idx_app_claim_name" json:"claim_name"`
+	Source    string    `gorm:"size:32;not null" json:"source"`
+	Value     string    `gorm:"type:text" json:"value"`
+	Scope     string    `gorm:"size:100" json:"scope"`
+	Targets   string    `gorm:"size:100" json:"targets"` // 逗号分隔
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	App App `gorm:"foreignKey:AppID" json:"-"`
+}
+
+// GetTargetList 获取注入位置列表
+func (m *AppClaimMapping) GetTargetList() []string {
+	if strings.TrimSpace(m.Targets) == "" {
+		return nil
+	}
+	var targets []string
+	for _, t := range strings.Split(m.Targets, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// HasTarget 判断声明是否注入到指定位置
+func (m *AppClaimMapping) HasTarget(target string) bool {
+	targets := m.GetTargetList()
+	if len(targets) == 0 {
+		return true
+	}
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
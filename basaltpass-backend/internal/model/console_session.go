@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// 会话撤销原因
+const (
+	ConsoleSessionRevokedLogout       = "logout"
+	ConsoleSessionRevokedUser         = "user_revoked"
+	ConsoleSessionRevokedAdmin        = "admin_revoked"
+	ConsoleSessionRevokedRefreshReuse = "refresh_reuse"
+)
+
+// ConsoleSession 控制台登录会话。每次登录创建一条记录，访问令牌与刷新令牌通过 sid 声明关联到会话；
+// 刷新时轮换 RefreshJTI，会话被撤销后其下所有令牌立即失效。
+type ConsoleSession struct {
+	ID                 uint       `gorm:"primaryKey" json:"-"`
+	SessionID          string     `gorm:"size:64;not null;uniqueIndex" json:"session_id"`
+	UserID             uint       `gorm:"not null;index" json:"user_id"`
+	TenantID           uint       `gorm:"index" json:"tenant_id"`
+	Scope              string     `gorm:"size:16;not null" json:"scope"`
+	RefreshJTI         string     `gorm:"size:64" json:"-"`
+	PreviousRefreshJTI string     `gorm:"size:64" json:"-"`
+	RotatedAt          *time.Time `json:"-"`
+	IPAddress          string     `gorm:"size:64" json:"ip_address"`
+	UserAgent          string     `gorm:"size:255" json:"user_agent"`
+	Device             string     `gorm:"size:128" json:"device"`
+	AuthTime           time.Time  `json:"auth_time"`
+	LastSeenAt         time.Time  `json:"last_seen_at"`
+	ExpiresAt          time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt          *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedReason      string     `gorm:"size:32" json:"revoked_reason,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// IsActive 会话未撤销且未过期
+func (s *ConsoleSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+// 上游身份提供方协议类型
+const (
+	IdentityProviderTypeOIDC   = "oidc"   // OpenID Connect：校验 id_token，支持 Discovery
+	IdentityProviderTypeOAuth2 = "oauth2" // 纯 OAuth2：通过 userinfo 端点读取用户资料
+)
+
+// 联合登录发起意图
+const (
+	FederatedIntentLogin = "login"
+	FederatedIntentLink  = "link"
+)
+
+// IdentityProvider 租户配置的上游身份提供方（如 Google、GitHub、Microsoft、企业 Keycloak）。
+// OIDC 类型在未填写端点时通过 Issuer 的 Discovery 文档解析；OAuth2 类型需填写全部端点及字段映射。
+type IdentityProvider struct {
+	ID                    uint      `gorm:"primaryKey" json:"id"`
+	TenantID              uint      `gorm:"not null;uniqueIndex:idx_identity_provider_tenant_slug" json:"tenant_id"`
+	Slug                  string    `gorm:"size:64;not null;uniqueIndex:idx_identity_provider_tenant_slug" json:"slug"`
+	Name                  string    `gorm:"size:128;not null" json:"name"`
+	Type                  string    `gorm:"size:16;not null" json:"type"`
+	Preset                string    `gorm:"size:32" json:"preset"`
+	Issuer                string    `gorm:"size:255" json:"issuer"`
+	AuthorizationEndpoint string    `gorm:"size:512" json:"authorization_endpoint"`
+	TokenEndpoint         string    `gorm:"size:512" json:"token_endpoint"`
+	UserinfoEndpoint      string    `gorm:"size:512" json:"userinfo_endpoint"`
+	JWKSURI               string    `gorm:"column:jwks_uri;size:512" json:"jwks_uri"`
+	ClientID              string    `gorm:"size:255;not null" json:"client_id"`
+	ClientSecretEnc       string    `gorm:"size:1024" json:"-"` // 加密保存的客户端密钥
+	Scopes                string    `gorm:"size:512" json:"scopes"`
+	SubjectField          string    `gorm:"size:64" json:"subject_field"` // OAuth2 userinfo 中的用户标识字段
+	EmailField            string    `gorm:"size:64" json:"email_field"`
+	NameField             string    `gorm:"size:64" json:"name_field"`
+	AllowProvisioning     bool      `gorm:"not null;default:true" json:"allow_provisioning"` // 首次登录时自动创建租户账号（仍受 AllowRegistration 限制）
+	Enabled               bool      `gorm:"not null;default:true" json:"enabled"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// UserIdentityLink 用户与上游身份（提供方 + 上游 sub）的绑定关系
+type UserIdentityLink struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index;uniqueIndex:idx_identity_link_user_provider" json:"user_id"`
+	TenantID    uint       `gorm:"not null;index" json:"tenant_id"`
+	ProviderID  uint       `gorm:"not null;uniqueIndex:idx_identity_link_subject;uniqueIndex:idx_identity_link_user_provider" json:"provider_id"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_identity_link_subject" json:"subject"`
+	Email       string     `gorm:"size:128" json:"email"`
+	DisplayName string     `gorm:"size:128" json:"display_name"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Provider IdentityProvider `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
+}
+
+// FederatedAuthState 跳转上游授权时保存的一次性状态，回调时凭 state 取回并删除
+type FederatedAuthState struct {
+	ID           uint      `gorm:"primaryKey"`
+	State        string    `gorm:"size:64;not null;uniqueIndex"`
+	ProviderID   uint      `gorm:"not null"`
+	Intent       string    `gorm:"size:16;not null"`
+	UserID       uint      // 绑定意图下发起绑定的用户
+	Nonce        string    `gorm:"size:64"`
+	CodeVerifier string    `gorm:"size:128"`
+	RedirectURI  string    `gorm:"size:512"` // 提交给上游的回调地址，换取令牌时原样回传
+	ReturnTo     string    `gorm:"size:512"` // 完成后返回的前端路径
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// LDAPDirectory 租户配置的 LDAP / Active Directory 认证后端（每个租户一个）。
+// 登录时先以服务账号绑定并按 UserFilter 查找用户条目，再以该条目的 DN 和提交的密码绑定校验，密码不保存在本地。
+type LDAPDirectory struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	TenantID            uint       `gorm:"not null;uniqueIndex" json:"tenant_id"`
+	URL                 string     `gorm:"size:255;not null" json:"url"` // ldap://host:389 或 ldaps://host:636
+	StartTLS            bool       `gorm:"not null;default:false" json:"start_tls"`
+	InsecureSkipVerify  bool       `gorm:"not null;default:false" json:"insecure_skip_verify"`
+	BindDN              string     `gorm:"size:255" json:"bind_dn"` // 服务账号，为空时匿名查找
+	BindPasswordEnc     string     `gorm:"size:1024" json:"-"`      // 加密保存的服务账号密码
+	BaseDN              string     `gorm:"size:255;not null" json:"base_dn"`
+	UserFilter          string     `gorm:"size:512;not null" json:"user_filter"` // 含 {username} 占位符，如 (&(objectClass=person)(uid={username}))
+	UIDAttribute        string     `gorm:"size:64" json:"uid_attribute"`         // 稳定标识属性（entryUUID / objectGUID），为空时使用 DN
+	EmailAttribute      string     `gorm:"size:64" json:"email_attribute"`
+	NameAttribute       string     `gorm:"size:64" json:"name_attribute"`
+	PhoneAttribute      string     `gorm:"size:64" json:"phone_attribute"`
+	GroupAttribute      string     `gorm:"size:64" json:"group_attribute"` // 用户条目上的组属性，如 memberOf
+	GroupBaseDN         string     `gorm:"size:255" json:"group_base_dn"`
+	GroupFilter         string     `gorm:"size:512" json:"group_filter"`                    // 含 {dn} 占位符，如 (member={dn})；设置后按组查找代替 GroupAttribute
+	GroupRoleMappings   string     `gorm:"type:text" json:"-"`                              // JSON：[{"group":"cn=admins,ou=groups,dc=example,dc=com","role":"admin"}]
+	AllowProvisioning   bool       `gorm:"not null;default:true" json:"allow_provisioning"` // 目录用户首次登录时自动创建租户账号
+	SyncIntervalMinutes int        `gorm:"not null;default:0" json:"sync_interval_minutes"` // 定时同步组成员关系的间隔，0 表示关闭
+	LastSyncAt          *time.Time `json:"last_sync_at,omitempty"`
+	LastSyncError       string     `gorm:"size:512" json:"last_sync_error"`
+	Enabled             bool       `gorm:"not null;default:true" json:"enabled"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// LDAPUserLink 用户与目录条目的绑定关系；绑定后用户只能通过目录密码登录
+type LDAPUserLink struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	TenantID    uint       `gorm:"not null;index" json:"tenant_id"`
+	DirectoryID uint       `gorm:"not null;uniqueIndex:idx_ldap_link_subject" json:"directory_id"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_ldap_link_subject" json:"subject"`
+	DN          string     `gorm:"column:dn;size:512;not null" json:"dn"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	LastSyncAt  *time.Time `json:"last_sync_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
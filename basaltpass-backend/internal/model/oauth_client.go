@@ -11,6 +11,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// 访问令牌格式
+const (
+	AccessTokenFormatOpaque = "opaque"
+	AccessTokenFormatJWT    = "jwt"
+)
+
+// 令牌端点客户端认证方式（RFC 7591 §2 token_endpoint_auth_method）
+const (
+	TokenEndpointAuthNone              = "none" // 公共客户端（SPA、移动端），不持有密钥，必须使用 PKCE S256
+	TokenEndpointAuthClientSecretBasic = "client_secret_basic"
+	TokenEndpointAuthClientSecretPost  = "client_secret_post"
+	TokenEndpointAuthPrivateKeyJWT     = "private_key_jwt" // 以登记的公钥验证 client_assertion（RFC 7523），不持有共享密钥
+)
+
+// 主体标识类型（OIDC Core §8 subject_type）
+const (
+	SubjectTypePublic   = "public"   // 所有客户端看到相同的 sub（用户ID）
+	SubjectTypePairwise = "pairwise" // 按扇区派生不同的 sub，防止跨应用关联用户
+)
+
 // OAuthClient 表示一个OAuth2客户端（业务应用）
 type OAuthClient struct {
 	gorm.Model
@@ -22,9 +42,36 @@ type OAuthClient struct {
 	GrantTypes   string `gorm:"size:255;default:'authorization_code,refresh_token'" json:"grant_types"` // 支持的授权类型
 	IsActive     bool   `gorm:"default:true" json:"is_active"`                                          // 是否激活
 
+	// AccessTokenFormat 访问令牌格式：opaque（默认，需内省）或 jwt（RFC 9068 自包含令牌）
+	AccessTokenFormat string `gorm:"size:16;default:'opaque'" json:"access_token_format"`
+
+	// TokenEndpointAuthMethod 令牌端点认证方式，none 表示公共客户端
+	TokenEndpointAuthMethod string `gorm:"size:32;default:'client_secret_basic'" json:"token_endpoint_auth_method"`
+
+	// private_key_jwt 客户端公钥，JWKS 与 jwks_uri 二选一
+	JWKS    string `gorm:"type:text" json:"jwks,omitempty"`    // 内联 JWK Set 文档
+	JWKSURI string `gorm:"size:500" json:"jwks_uri,omitempty"` // 客户端托管的 JWK Set 地址
+
+	// RequirePushedAuthorizationRequests 授权端点只接受经 /oauth/par 推送的请求（RFC 9126 §6）
+	RequirePushedAuthorizationRequests bool `gorm:"default:false" json:"require_pushed_authorization_requests"`
+
+	// 主体标识：pairwise 客户端按 sector_identifier_uri（未配置时取重定向地址）的主机名派生 sub
+	SubjectType         string `gorm:"size:16;default:'public'" json:"subject_type"`
+	SectorIdentifierURI string `gorm:"size:500" json:"sector_identifier_uri,omitempty"`
+
 	// 应用配置
 	AllowedOrigins string `gorm:"type:text" json:"allowed_origins"` // 允许的CORS源（用逗号分隔）
 
+	// 登出配置（OIDC RP-Initiated / Back-Channel / Front-Channel Logout）
+	PostLogoutRedirectURIs string `gorm:"type:text" json:"post_logout_redirect_uris"` // 登出后允许跳转的URI（用逗号分隔）
+	BackchannelLogoutURI   string `gorm:"size:500" json:"backchannel_logout_uri"`     // 接收登出令牌的后端地址
+	FrontchannelLogoutURI  string `gorm:"size:500" json:"frontchannel_logout_uri"`    // 浏览器中以 iframe 加载的登出地址
+
+	// 动态注册（RFC 7591/7592）
+	ClientName              string `gorm:"size:100" json:"client_name"`          // 注册时提交的客户端名称
+	RegistrationAccessToken string `gorm:"size:128;index" json:"-"`              // 注册访问令牌摘要，空表示非动态注册
+	InitialAccessTokenID    *uint  `gorm:"index" json:"initial_access_token_id"` // 注册时使用的初始访问令牌
+
 	// 统计信息
 	LastUsedAt *time.Time `json:"last_used_at"`               // 最后使用时间
 	CreatedBy  uint       `gorm:"not null" json:"created_by"` // 创建者用户ID
@@ -54,6 +101,11 @@ func (c *OAuthClient) GenerateClientCredentials() error {
 	return nil
 }
 
+// UsesPairwiseSubjects 客户端是否使用 pairwise 主体标识
+func (c *OAuthClient) UsesPairwiseSubjects() bool {
+	return c.SubjectType == SubjectTypePairwise
+}
+
 // GetRedirectURIList 获取重定向URI列表
 func (c *OAuthClient) GetRedirectURIList() []string {
 	if c.RedirectURIs == "" {
@@ -101,6 +153,31 @@ func (c *OAuthClient) GetGrantTypeList() []string {
 	return strings.Split(c.GrantTypes, ",")
 }
 
+// SetGrantTypeList 设置授权类型列表
+func (c *OAuthClient) SetGrantTypeList(grantTypes []string) {
+	c.GrantTypes = strings.Join(grantTypes, ",")
+}
+
+// UsesJWTAccessTokens 判断客户端是否签发 JWT 格式的访问令牌
+func (c *OAuthClient) UsesJWTAccessTokens() bool {
+	return c.AccessTokenFormat == AccessTokenFormatJWT
+}
+
+// IsPublic 判断是否为公共客户端（不持有密钥）
+func (c *OAuthClient) IsPublic() bool {
+	return c.TokenEndpointAuthMethod == TokenEndpointAuthNone
+}
+
+// UsesClientSecret 判断客户端是否以共享密钥认证；公共客户端与 private_key_jwt 客户端不持有密钥
+func (c *OAuthClient) UsesClientSecret() bool {
+	switch c.TokenEndpointAuthMethod {
+	case TokenEndpointAuthNone, TokenEndpointAuthPrivateKeyJWT:
+		return false
+	default:
+		return true
+	}
+}
+
 // ValidateRedirectURI 验证重定向URI是否被允许
 func (c *OAuthClient) ValidateRedirectURI(uri string) bool {
 	allowedURIs := c.GetRedirectURIList()
@@ -112,6 +189,29 @@ func (c *OAuthClient) ValidateRedirectURI(uri string) bool {
 	return false
 }
 
+// GetPostLogoutRedirectURIList 获取登出后重定向URI列表
+func (c *OAuthClient) GetPostLogoutRedirectURIList() []string {
+	if c.PostLogoutRedirectURIs == "" {
+		return []string{}
+	}
+	return strings.Split(c.PostLogoutRedirectURIs, ",")
+}
+
+// SetPostLogoutRedirectURIList 设置登出后重定向URI列表
+func (c *OAuthClient) SetPostLogoutRedirectURIList(uris []string) {
+	c.PostLogoutRedirectURIs = strings.Join(uris, ",")
+}
+
+// ValidatePostLogoutRedirectURI 验证登出后重定向URI是否已注册（精确匹配）
+func (c *OAuthClient) ValidatePostLogoutRedirectURI(uri string) bool {
+	for _, allowedURI := range c.GetPostLogoutRedirectURIList() {
+		if strings.TrimSpace(allowedURI) == uri {
+			return true
+		}
+	}
+	return false
+}
+
 // HashClientSecret 对客户端密钥进行哈希处理，使用 bcrypt（cost=12）。
 // 调用后 c.ClientSecret 变为 bcrypt hash，原始明文不再保存。
 func (c *OAuthClient) HashClientSecret() {
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// OAuthClientAssertionJTI 已使用的客户端断言 jti（RFC 7523 §3 第 7 条），在断言过期前拒绝重放
+type OAuthClientAssertionJTI struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ClientID  string    `gorm:"size:64;not null;uniqueIndex:idx_client_assertion_jti" json:"client_id"`
+	JTI       string    `gorm:"size:255;not null;uniqueIndex:idx_client_assertion_jti" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DeviceCodeStatus 设备授权状态
+type DeviceCodeStatus string
+
+const (
+	DeviceCodeStatusPending  DeviceCodeStatus = "pending"  // 等待用户在浏览器中确认
+	DeviceCodeStatusApproved DeviceCodeStatus = "approved" // 用户已同意，等待设备轮询领取令牌
+	DeviceCodeStatusDenied   DeviceCodeStatus = "denied"   // 用户已拒绝
+	DeviceCodeStatusConsumed DeviceCodeStatus = "consumed" // 令牌已签发
+)
+
+// userCodeAlphabet 用户码字符集：去除元音与易混淆字符（RFC 8628 §6.1）
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// OAuthDeviceCode OAuth2设备授权（RFC 8628）
+type OAuthDeviceCode struct {
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	DeviceCode   string           `gorm:"size:128;uniqueIndex;not null" json:"-"`
+	UserCode     string           `gorm:"size:16;uniqueIndex;not null" json:"user_code"`
+	ClientID     string           `gorm:"size:64;not null;index" json:"client_id"`
+	AppID        uint             `gorm:"not null;index" json:"app_id"`
+	TenantID     uint             `gorm:"not null;index" json:"tenant_id"`
+	Scopes       string           `gorm:"type:text" json:"scopes"`
+	Status       DeviceCodeStatus `gorm:"type:varchar(16);not null;default:'pending';index" json:"status"`
+	UserID       uint             `gorm:"index" json:"user_id"`     // 确认授权的用户，未确认前为0
+	Interval     int              `gorm:"not null" json:"interval"` // 最小轮询间隔（秒），slow_down 时递增
+	LastPolledAt *time.Time       `json:"last_polled_at,omitempty"`
+	AuthTime     *time.Time       `json:"auth_time,omitempty"`
+	ExpiresAt    time.Time        `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+
+	// 关联
+	Client OAuthClient `gorm:"foreignKey:ClientID;references:ClientID" json:"client,omitempty"`
+}
+
+// IsExpired 检查设备码是否过期
+func (d *OAuthDeviceCode) IsExpired() bool {
+	return time.Now().After(d.ExpiresAt)
+}
+
+// GenerateDeviceCode 生成设备码
+func GenerateDeviceCode() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "bp_dc_" + hex.EncodeToString(bytes), nil
+}
+
+// GenerateUserCode 生成形如 "BCDF-GHJK" 的用户码
+func GenerateUserCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			sb.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// NormalizeUserCode 规范化用户输入的用户码（忽略大小写、空格与连字符）
+func NormalizeUserCode(input string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			sb.WriteRune(r)
+		}
+	}
+	code := sb.String()
+	if len(code) != 8 {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// OAuthPairwiseSubject 用户在某个扇区下的 pairwise 主体标识（OIDC Core §8.1）。
+// 同一扇区（主机名）下的客户端看到相同的 sub，不同扇区之间无法关联。
+type OAuthPairwiseSubject struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Sector    string    `gorm:"size:255;not null;uniqueIndex:idx_pairwise_sector_user;uniqueIndex:idx_pairwise_sector_subject" json:"sector"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_pairwise_sector_user;index" json:"user_id"`
+	Subject   string    `gorm:"size:64;not null;uniqueIndex:idx_pairwise_sector_subject" json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
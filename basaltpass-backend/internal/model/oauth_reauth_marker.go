@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// OAuthReauthMarker 授权端点（或 SAML ForceAuthn）要求重新登录时签发的一次性标记。
+// 浏览器以 HttpOnly Cookie 持有明文，库中只保存摘要；回到授权端点时消费，会话须在签发之后完成认证。
+type OAuthReauthMarker struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Marker    string    `gorm:"size:128;uniqueIndex;not null" json:"-"` // 标记摘要
+	IssuedAt  time.Time `gorm:"not null" json:"issued_at"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsExpired 检查标记是否过期
+func (m *OAuthReauthMarker) IsExpired() bool {
+	return time.Now().After(m.ExpiresAt)
+}
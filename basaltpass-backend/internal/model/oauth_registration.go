@@ -0,0 +1,50 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// OAuthInitialAccessToken 租户签发的初始访问令牌，用于动态客户端注册（RFC 7591 §3）。
+// 注册得到的客户端归属于令牌绑定的应用。
+type OAuthInitialAccessToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	TenantID    uint       `gorm:"not null;index" json:"tenant_id"`
+	AppID       uint       `gorm:"not null;index" json:"app_id"`
+	Token       string     `gorm:"size:128;uniqueIndex;not null" json:"-"` // 令牌摘要
+	TokenPrefix string     `gorm:"size:16" json:"token_prefix"`
+	Description string     `gorm:"size:255" json:"description"`
+	MaxUses     int        `gorm:"not null;default:0" json:"max_uses"` // 0 表示不限次数
+	UseCount    int        `gorm:"not null;default:0" json:"use_count"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	CreatedBy   uint       `gorm:"not null" json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// IsUsable 令牌未过期且未用尽
+func (t *OAuthInitialAccessToken) IsUsable() bool {
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return false
+	}
+	return t.MaxUses == 0 || t.UseCount < t.MaxUses
+}
+
+// GenerateInitialAccessToken 生成初始访问令牌
+func GenerateInitialAccessToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "iat_" + hex.EncodeToString(bytes), nil
+}
+
+// GenerateRegistrationAccessToken 生成客户端配置端点使用的注册访问令牌（RFC 7592）
+func GenerateRegistrationAccessToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "rat_" + hex.EncodeToString(bytes), nil
+}
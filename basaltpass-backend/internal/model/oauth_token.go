@@ -8,16 +8,19 @@ import (
 )
 
 // OAuthAccessToken OAuth2访问令牌
+// Token 保存令牌的 HMAC 摘要（utils.HashOAuthToken），不保存明文。
 type OAuthAccessToken struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Token     string    `gorm:"size:128;uniqueIndex;not null" json:"token"`
-	ClientID  string    `gorm:"size:64;not null;index" json:"client_id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	TenantID  uint      `gorm:"not null;index" json:"tenant_id"`
-	AppID     uint      `gorm:"not null;index" json:"app_id"`
-	Scopes    string    `gorm:"type:text" json:"scopes"`
-	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Token       string    `gorm:"size:128;uniqueIndex;not null" json:"-"`
+	TokenPrefix string    `gorm:"size:16" json:"token_prefix"` // 明文前缀，仅用于展示
+	ClientID    string    `gorm:"size:64;not null;index" json:"client_id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	TenantID    uint      `gorm:"not null;index" json:"tenant_id"`
+	AppID       uint      `gorm:"not null;index" json:"app_id"`
+	Scopes      string    `gorm:"type:text" json:"scopes"`
+	Claims      string    `gorm:"type:text" json:"claims,omitempty"` // OIDC claims 请求参数（JSON），UserInfo 按其返回声明
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
 
 	// Token Exchange (RFC 8693) actor context — populated when this token was
 	// created via a token-exchange grant.
@@ -33,9 +36,11 @@ type OAuthAccessToken struct {
 }
 
 // OAuthRefreshToken OAuth2刷新令牌
+// Token 保存令牌的 HMAC 摘要（utils.HashOAuthToken），不保存明文。
 type OAuthRefreshToken struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
-	Token         string    `gorm:"size:128;uniqueIndex;not null" json:"token"`
+	Token         string    `gorm:"size:128;uniqueIndex;not null" json:"-"`
+	TokenPrefix   string    `gorm:"size:16" json:"token_prefix"` // 明文前缀，仅用于展示
 	ClientID      string    `gorm:"size:64;not null;index" json:"client_id"`
 	UserID        uint      `gorm:"not null;index" json:"user_id"`
 	TenantID      uint      `gorm:"not null;index" json:"tenant_id"`
@@ -45,6 +50,18 @@ type OAuthRefreshToken struct {
 	AccessTokenID *uint     `gorm:"index" json:"access_token_id,omitempty"` // 关联的访问令牌ID
 	CreatedAt     time.Time `json:"created_at"`
 
+	// OIDC 认证上下文（刷新时重新签发 id_token 需要沿用）
+	AuthTime *time.Time `json:"auth_time,omitempty"`
+	ACR      string     `gorm:"size:128" json:"acr,omitempty"`
+	AMR      string     `gorm:"size:128" json:"amr,omitempty"`
+	Claims   string     `gorm:"type:text" json:"claims,omitempty"`
+
+	// 轮换链：同一次授权派生出的刷新令牌共享 FamilyID；
+	// 已轮换的令牌保留 RotatedAt，用于识别重放（RFC 9700 §4.14.2）
+	FamilyID  string     `gorm:"size:64;index" json:"family_id"`
+	ParentID  *uint      `gorm:"index" json:"parent_id,omitempty"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+
 	// 关联
 	User        User              `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Tenant      Tenant            `gorm:"foreignKey:TenantID" json:"tenant,omitempty"`
@@ -54,9 +71,10 @@ type OAuthRefreshToken struct {
 }
 
 // OAuthAuthorizationCode OAuth2授权码（增强版）
+// Code 保存授权码的 HMAC 摘要（utils.HashOAuthToken），不保存明文。
 type OAuthAuthorizationCode struct {
 	ID                  uint      `gorm:"primaryKey" json:"id"`
-	Code                string    `gorm:"size:128;uniqueIndex;not null" json:"code"`
+	Code                string    `gorm:"size:128;uniqueIndex;not null" json:"-"`
 	ClientID            string    `gorm:"size:64;not null;index" json:"client_id"`
 	UserID              uint      `gorm:"not null;index" json:"user_id"`
 	TenantID            uint      `gorm:"not null;index" json:"tenant_id"`
@@ -69,6 +87,13 @@ type OAuthAuthorizationCode struct {
 	Used                bool      `gorm:"default:false;index" json:"used"` // 是否已使用
 	CreatedAt           time.Time `json:"created_at"`
 
+	// OIDC 认证上下文
+	Nonce    string     `gorm:"size:255" json:"nonce,omitempty"`   // 授权请求中的 nonce，原样写入 id_token
+	AuthTime *time.Time `json:"auth_time,omitempty"`               // 用户完成认证的时间
+	ACR      string     `gorm:"size:128" json:"acr,omitempty"`     // 认证上下文等级
+	AMR      string     `gorm:"size:128" json:"amr,omitempty"`     // 认证方式（空格分隔，如 "pwd otp"）
+	Claims   string     `gorm:"type:text" json:"claims,omitempty"` // OIDC claims 请求参数（JSON）
+
 	// 关联
 	User   User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Tenant Tenant      `gorm:"foreignKey:TenantID" json:"tenant,omitempty"`
@@ -96,6 +121,15 @@ func GenerateRefreshToken() (string, error) {
 	return "bp_rt_" + hex.EncodeToString(bytes), nil
 }
 
+// GenerateTokenFamilyID 生成刷新令牌族ID
+func GenerateTokenFamilyID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "rtf_" + hex.EncodeToString(bytes), nil
+}
+
 // GenerateAuthCode 生成授权码
 func GenerateAuthCode() (string, error) {
 	bytes := make([]byte, 16)
@@ -119,11 +153,21 @@ func (t *OAuthAccessToken) IsExpired() bool {
 	return time.Now().After(t.ExpiresAt)
 }
 
+// IsClientToken 判断是否为 client_credentials 签发的客户端令牌（无用户主体）
+func (t *OAuthAccessToken) IsClientToken() bool {
+	return t.UserID == 0
+}
+
 // IsExpired 检查刷新令牌是否过期
 func (t *OAuthRefreshToken) IsExpired() bool {
 	return time.Now().After(t.ExpiresAt)
 }
 
+// IsRotated 检查刷新令牌是否已被轮换（再次使用即为重放）
+func (t *OAuthRefreshToken) IsRotated() bool {
+	return t.RotatedAt != nil
+}
+
 // IsExpired 检查授权码是否过期
 func (c *OAuthAuthorizationCode) IsExpired() bool {
 	return time.Now().After(c.ExpiresAt)
@@ -144,3 +188,13 @@ func (c *OAuthAuthorizationCode) GetScopeList() []string {
 	}
 	return strings.Split(c.Scopes, " ")
 }
+
+// GetAMRList 获取认证方式列表
+func (c *OAuthAuthorizationCode) GetAMRList() []string {
+	return strings.Fields(c.AMR)
+}
+
+// GetAMRList 获取认证方式列表
+func (t *OAuthRefreshToken) GetAMRList() []string {
+	return strings.Fields(t.AMR)
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// SAML NameID 格式
+const (
+	SAMLNameIDFormatEmail       = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	SAMLNameIDFormatPersistent  = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent" // 用户 UUID，不随邮箱变化
+	SAMLNameIDFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+// SAMLServiceProvider 应用登记的 SAML 2.0 服务提供方（SP），BasaltPass 以应用所在租户的身份充当 IdP。
+// 每个应用最多登记一个 SP；断言以 OAuth 签名密钥集合中的当前密钥签名。
+type SAMLServiceProvider struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	AppID               uint      `gorm:"not null;uniqueIndex" json:"app_id"`
+	TenantID            uint      `gorm:"not null;uniqueIndex:idx_saml_sp_tenant_entity" json:"tenant_id"`
+	EntityID            string    `gorm:"size:255;not null;uniqueIndex:idx_saml_sp_tenant_entity" json:"entity_id"`
+	ACSURL              string    `gorm:"column:acs_url;size:500;not null" json:"acs_url"` // 断言消费地址（HTTP-POST 绑定）
+	SLOURL              string    `gorm:"column:slo_url;size:500" json:"slo_url"`          // 单点登出地址（HTTP-Redirect 绑定），为空表示不支持 SLO
+	NameIDFormat        string    `gorm:"size:128;not null" json:"name_id_format"`
+	Certificate         string    `gorm:"type:text" json:"certificate"` // SP 签名证书（PEM），用于校验 HTTP-Redirect 绑定的请求签名
+	WantRequestsSigned  bool      `gorm:"not null;default:false" json:"want_requests_signed"`
+	SignResponse        bool      `gorm:"not null;default:false" json:"sign_response"` // 除断言外同时签名整个 Response
+	AllowIdPInitiated   bool      `gorm:"column:allow_idp_initiated;not null;default:false" json:"allow_idp_initiated"`
+	DefaultRelayState   string    `gorm:"size:500" json:"default_relay_state"` // IdP 发起登录时附带的 RelayState
+	SessionLifetimeMins int       `gorm:"not null;default:480" json:"session_lifetime_minutes"`
+	Enabled             bool      `gorm:"not null;default:true" json:"enabled"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+
+	App App `gorm:"foreignKey:AppID" json:"-"`
+}
+
+// SAMLSession 向 SP 签发断言时登记的会话（SessionIndex），用于单点登出
+type SAMLSession struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	SessionIndex      string     `gorm:"size:64;not null;uniqueIndex" json:"session_index"`
+	ServiceProviderID uint       `gorm:"not null;index" json:"service_provider_id"`
+	UserID            uint       `gorm:"not null;index" json:"user_id"`
+	NameID            string     `gorm:"size:255;not null" json:"name_id"`
+	NameIDFormat      string     `gorm:"size:128;not null" json:"name_id_format"`
+	ExpiresAt         time.Time  `gorm:"index" json:"expires_at"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+
+	ServiceProvider SAMLServiceProvider `gorm:"foreignKey:ServiceProviderID" json:"-"`
+}
+
+// IsActive 会话未登出且未过期
+func (s *SAMLSession) IsActive(now time.Time) bool {
+	return s.EndedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// SCIM 组对应的租户资源类型
+const (
+	SCIMGroupKindTeam = "team" // 团队（Team）
+	SCIMGroupKindRole = "role" // 租户角色（TenantRbacRole）
+)
+
+// SCIMUser SCIM 客户端为租户用户维护的属性。用户本身仍保存在 system_auth_users，
+// 这里仅记录 BasaltPass 用户模型中没有的 externalId、userName 与姓名
+type SCIMUser struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TenantID   uint      `gorm:"not null;uniqueIndex:idx_scim_user_tenant_user" json:"tenant_id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_scim_user_tenant_user;index" json:"user_id"`
+	ExternalID string    `gorm:"size:255;index" json:"external_id"`
+	UserName   string    `gorm:"size:255;index" json:"user_name"`
+	GivenName  string    `gorm:"size:64" json:"given_name"`
+	FamilyName string    `gorm:"size:64" json:"family_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (SCIMUser) TableName() string {
+	return "scim_users"
+}
+
+// SCIMGroup SCIM 组的 externalId；组本身是租户下的团队或角色
+type SCIMGroup struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TenantID   uint      `gorm:"not null;uniqueIndex:idx_scim_group_resource" json:"tenant_id"`
+	Kind       string    `gorm:"size:16;not null;uniqueIndex:idx_scim_group_resource" json:"kind"` // team / role
+	ResourceID uint      `gorm:"not null;uniqueIndex:idx_scim_group_resource" json:"resource_id"`
+	ExternalID string    `gorm:"size:255;index" json:"external_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (SCIMGroup) TableName() string {
+	return "scim_groups"
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// SigningKeyStatus 签名密钥状态
+type SigningKeyStatus string
+
+const (
+	SigningKeyStatusNext    SigningKeyStatus = "next"    // 已发布到 JWKS，等待下一次轮换时启用
+	SigningKeyStatusActive  SigningKeyStatus = "active"  // 当前用于签名
+	SigningKeyStatusRetired SigningKeyStatus = "retired" // 已停止签名，保留到 ExpiresAt 以便验证存量令牌
+	SigningKeyStatusRevoked SigningKeyStatus = "revoked" // 已吊销，立即从 JWKS 移除
+)
+
+// 签名密钥用途：OAuth/OIDC 令牌与控制台令牌使用各自独立轮换的密钥集合
+const (
+	SigningKeyPurposeOAuth   = "oauth"
+	SigningKeyPurposeConsole = "console"
+)
+
+// SigningKey 持久化的非对称签名密钥（id_token / JWT 访问令牌 / 控制台令牌等）。
+// 私钥以 AES-256-GCM 加密后存储，公钥以 PEM 明文存储供 JWKS 发布。
+type SigningKey struct {
+	ID            uint             `gorm:"primaryKey" json:"id"`
+	KID           string           `gorm:"column:kid;size:64;uniqueIndex;not null" json:"kid"`
+	Purpose       string           `gorm:"size:16;not null;default:oauth;index" json:"purpose"`
+	Algorithm     string           `gorm:"size:16;not null;default:RS256" json:"alg"`
+	Status        SigningKeyStatus `gorm:"type:varchar(16);not null;index" json:"status"`
+	PublicKeyPEM  string           `gorm:"type:text;not null" json:"-"`
+	PrivateKeyEnc string           `gorm:"type:text;not null" json:"-"`
+	ActivatedAt   *time.Time       `json:"activated_at,omitempty"`
+	RetiredAt     *time.Time       `json:"retired_at,omitempty"`
+	RevokedAt     *time.Time       `json:"revoked_at,omitempty"`
+	ExpiresAt     *time.Time       `gorm:"index" json:"expires_at,omitempty"` // 退役密钥从 JWKS 移除的时间
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "system_signing_keys"
+}
+
+// IsPublished 判断密钥是否应出现在 JWKS 中
+func (k *SigningKey) IsPublished(now time.Time) bool {
+	switch k.Status {
+	case SigningKeyStatusNext, SigningKeyStatusActive:
+		return true
+	case SigningKeyStatusRetired:
+		return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+	default:
+		return false
+	}
+}
@@ -4,16 +4,29 @@ import "time"
 
 // TenantAuthSetting stores tenant-level auth switches.
 type TenantAuthSetting struct {
-	ID                uint      `gorm:"primaryKey" json:"id"`
-	TenantID          uint      `gorm:"not null;uniqueIndex" json:"tenant_id"`
-	AllowRegistration bool      `gorm:"not null;default:true" json:"allow_registration"`
-	AllowLogin        bool      `gorm:"not null;default:true" json:"allow_login"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                   uint      `gorm:"primaryKey" json:"id"`
+	TenantID             uint      `gorm:"not null;uniqueIndex" json:"tenant_id"`
+	AllowRegistration    bool      `gorm:"not null;default:true" json:"allow_registration"`
+	AllowLogin           bool      `gorm:"not null;default:true" json:"allow_login"`
+	PKCERequired         bool      `gorm:"not null;default:false" json:"pkce_required"`                     // 租户强制 PKCE，与全局 oauth.pkce_required 取或
+	PasswordlessEmail    bool      `gorm:"not null;default:false" json:"passwordless_email"`                // 允许邮箱登录链接/验证码免密登录
+	PasswordlessSMS      bool      `gorm:"not null;default:false" json:"passwordless_sms"`                  // 允许短信验证码免密登录
+	LockoutMode          string    `gorm:"type:varchar(16);not null;default:'inherit'" json:"lockout_mode"` // 登录失败锁定：inherit 沿用系统设置，enabled/disabled 覆盖
+	LockoutMaxAttempts   int       `gorm:"not null;default:0" json:"lockout_max_attempts"`                  // 锁定前允许的失败次数，0 沿用系统设置
+	LockoutWindowMinutes int       `gorm:"not null;default:0" json:"lockout_window_minutes"`                // 统计窗口及首次锁定时长（分钟），0 沿用系统设置
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
 
 	Tenant Tenant `gorm:"foreignKey:TenantID" json:"tenant,omitempty"`
 }
 
+// 租户登录失败锁定模式
+const (
+	LockoutModeInherit  = "inherit"
+	LockoutModeEnabled  = "enabled"
+	LockoutModeDisabled = "disabled"
+)
+
 func (TenantAuthSetting) TableName() string {
 	return "system_tenant_auth_settings"
 }
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// UserRecoveryCode 两步验证的一次性恢复码，按 (user_id, tenant_id) 与 UserTenantTOTP 对应。
+// 仅保存 SHA-256 哈希；UsedAt 非空表示已使用。重新生成时整组替换。
+type UserRecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index:idx_recovery_codes_user_tenant" json:"user_id"`
+	TenantID  uint       `gorm:"not null;index:idx_recovery_codes_user_tenant;default:0" json:"tenant_id"`
+	CodeHash  string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "system_user_recovery_codes"
+}
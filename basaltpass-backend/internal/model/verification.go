@@ -60,11 +60,20 @@ const (
 	ChallengeChannelSMS   ChallengeChannel = "sms"
 )
 
+// ChallengePurpose 验证码用途
+type ChallengePurpose string
+
+const (
+	ChallengePurposeSignup ChallengePurpose = "signup" // 注册时验证邮箱/手机号
+	ChallengePurposeLogin  ChallengePurpose = "login"  // 免密登录（邮箱登录链接或邮箱/短信验证码）
+)
+
 // VerificationChallenge 验证码挑战表
 type VerificationChallenge struct {
 	gorm.Model
 	ID        uint             `gorm:"primaryKey"`
-	SignupID  string           `gorm:"type:varchar(32);index"`  // 关联的注册会话ID
+	SignupID  string           `gorm:"type:varchar(32);index"` // 关联的注册会话ID；免密登录时为登录会话ID
+	Purpose   ChallengePurpose `gorm:"type:varchar(10);index;default:'signup'"`
 	Channel   ChallengeChannel `gorm:"type:varchar(10);index"`  // email/sms
 	Target    string           `gorm:"type:varchar(255);index"` // 邮箱或手机号（规范化后）
 	CodeHash  string           `gorm:"type:varchar(255)"`       // 验证码哈希
@@ -80,6 +89,12 @@ type VerificationChallenge struct {
 	ResendCount int       `gorm:"default:0"` // 重发次数
 	NextSendAt  time.Time `gorm:"index"`     // 最早下次重发时间
 
+	// 免密登录
+	UserID   uint   `gorm:"index;default:0"`   // 登录的账号
+	TenantID uint   `gorm:"index;default:0"`   // 登录入口所属租户，0 表示平台
+	LinkHash string `gorm:"type:varchar(255)"` // 邮箱登录链接令牌哈希，与验证码共用盐值
+	ReturnTo string `gorm:"type:varchar(512)"` // 登录完成后返回的站内地址（如 OAuth 授权地址）
+
 	// 状态
 	Status ChallengeStatus `gorm:"type:varchar(15);default:'ACTIVE'"`
 
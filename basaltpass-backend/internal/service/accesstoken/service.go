@@ -0,0 +1,153 @@
+package accesstoken
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/service/signingkey"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTType JWT 访问令牌的 typ 头（RFC 9068 §2.1）
+const JWTType = "at+jwt"
+
+// ErrInvalidToken 令牌签名、类型或有效期校验失败
+var ErrInvalidToken = errors.New("invalid_token")
+
+// Claims 签发 JWT 访问令牌所需的声明
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  string
+	ClientID  string
+	Scope     string
+	TenantID  uint
+	AppID     uint
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	AuthTime  *time.Time
+	ACR       string
+	AMR       []string
+
+	// ActorClientID 令牌交换（RFC 8693）时发起交换的客户端，写入 act 声明
+	ActorClientID string
+
+	// Extra 应用声明映射附加的自定义声明，不覆盖上述标准声明
+	Extra map[string]interface{}
+}
+
+// Service 签发与校验 RFC 9068 JWT 访问令牌。
+// JWT 令牌在数据库中以 jti 作为令牌记录的 Token 值保存，以便内省、撤销和令牌交换沿用同一套存储。
+type Service struct {
+	keys *signingkey.Service
+}
+
+// NewService creates a new access token service.
+func NewService(keys *signingkey.Service) *Service {
+	return &Service{keys: keys}
+}
+
+// Sign 使用当前 active 签名密钥签发 JWT 访问令牌
+func (s *Service) Sign(c *Claims) (string, error) {
+	if c == nil || c.JTI == "" || c.Subject == "" {
+		return "", fmt.Errorf("invalid access token claims")
+	}
+
+	key, err := s.keys.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"iss":       c.Issuer,
+		"sub":       c.Subject,
+		"aud":       c.Audience,
+		"client_id": c.ClientID,
+		"iat":       c.IssuedAt.Unix(),
+		"exp":       c.ExpiresAt.Unix(),
+		"jti":       c.JTI,
+		"tid":       c.TenantID,
+		"app_id":    c.AppID,
+	}
+	if c.Scope != "" {
+		claims["scope"] = c.Scope
+	}
+	if c.AuthTime != nil && !c.AuthTime.IsZero() {
+		claims["auth_time"] = c.AuthTime.Unix()
+	}
+	if c.ACR != "" {
+		claims["acr"] = c.ACR
+	}
+	if len(c.AMR) > 0 {
+		claims["amr"] = c.AMR
+	}
+	if c.ActorClientID != "" {
+		claims["act"] = map[string]interface{}{"client_id": c.ActorClientID}
+	}
+	for name, value := range c.Extra {
+		if _, exists := claims[name]; !exists {
+			claims[name] = value
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = JWTType
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.PrivateKey)
+}
+
+// Parse 校验 JWT 访问令牌的签名、typ 与有效期，返回其声明
+func (s *Service) Parse(raw string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, JWTType) && !strings.EqualFold(typ, "application/"+JWTType) {
+			return nil, ErrInvalidToken
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, err := s.keys.LookupKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{signingkey.AlgorithmRS256}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// StorageKey 返回令牌在数据库中的查找值：JWT 令牌校验通过后取 jti，不透明令牌取原值，
+// 再经 utils.HashOAuthToken 得到存储摘要。无效的 JWT 返回空字符串，调用方应视为令牌不存在。
+func (s *Service) StorageKey(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if !IsJWT(raw) {
+		return utils.HashOAuthToken(raw)
+	}
+
+	claims, err := s.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return ""
+	}
+	return utils.HashOAuthToken(jti)
+}
+
+// IsJWT 粗略判断令牌是否为 JWT 格式（三段式）
+func IsJWT(raw string) bool {
+	return strings.Count(raw, ".") == 2
+}
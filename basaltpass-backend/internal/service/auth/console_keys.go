@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	settingssvc "basaltpass-backend/internal/service/settings"
+	"basaltpass-backend/internal/service/signingkey"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 控制台令牌签名：jwt.algorithm 为 RS256 / EdDSA 时使用独立轮换的非对称密钥签名（头部携带 kid），
+// 公钥通过 /auth/jwks 发布，内部服务可离线验证；为 HS256 时沿用 JWT_SECRET。
+// 切换到非对称签名后，HS256 令牌在 jwt.hmac_grace_minutes 宽限期内仍被接受。
+
+// AlgorithmHS256 控制台令牌的对称签名算法（迁移前的默认值）
+const AlgorithmHS256 = "HS256"
+
+const defaultHMACGraceMinutes = 60 * 24 * 7
+
+var (
+	// ErrLegacyHMACToken HS256 令牌的迁移宽限期已过
+	ErrLegacyHMACToken = errors.New("hmac signed token no longer accepted")
+	// ErrInvalidTokenIssuer 令牌的 iss / aud 与配置不符
+	ErrInvalidTokenIssuer = errors.New("invalid token issuer or audience")
+)
+
+var (
+	consoleKeysMu  sync.Mutex
+	consoleKeysDB  *gorm.DB
+	consoleKeysSvc *signingkey.Service
+)
+
+// ConsoleAlgorithm 返回 jwt.algorithm 配置的控制台令牌签名算法；无法识别的取值按 HS256 处理
+func ConsoleAlgorithm() string {
+	switch strings.TrimSpace(settingssvc.GetString("jwt.algorithm", AlgorithmHS256)) {
+	case signingkey.AlgorithmRS256:
+		return signingkey.AlgorithmRS256
+	case signingkey.AlgorithmEdDSA, "Ed25519":
+		return signingkey.AlgorithmEdDSA
+	default:
+		return AlgorithmHS256
+	}
+}
+
+// ConsoleSigningKeys 返回控制台令牌的签名密钥服务（签名、JWKS 发布、轮换与吊销共用，以共享密钥缓存）
+func ConsoleSigningKeys() *signingkey.Service {
+	db := common.DB()
+	consoleKeysMu.Lock()
+	defer consoleKeysMu.Unlock()
+	if consoleKeysSvc == nil || consoleKeysDB != db {
+		consoleKeysSvc = signingkey.NewConsoleService(db, ConsoleAlgorithm)
+		consoleKeysDB = db
+	}
+	return consoleKeysSvc
+}
+
+func consoleIssuer() string {
+	return strings.TrimSpace(settingssvc.GetString("jwt.issuer", "basaltpass"))
+}
+
+func consoleAudience() string {
+	return strings.TrimSpace(settingssvc.GetString("jwt.audience", ""))
+}
+
+// signConsoleToken 补充 iss / aud / iat 后按当前算法签名控制台令牌
+func signConsoleToken(claims jwt.MapClaims) (string, error) {
+	if iss := consoleIssuer(); iss != "" {
+		claims["iss"] = iss
+	}
+	if aud := consoleAudience(); aud != "" {
+		claims["aud"] = aud
+	}
+	claims["iat"] = time.Now().Unix()
+
+	if ConsoleAlgorithm() == AlgorithmHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(common.MustJWTSecret())
+	}
+
+	key, err := ConsoleSigningKeys().ActiveKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.SignKey())
+}
+
+// ConsoleKeyFunc 返回控制台令牌的验证密钥：非对称令牌按 kid 从控制台密钥集合中查找，
+// HS256 令牌仅在仍使用 HS256 或处于迁移宽限期内时接受
+func ConsoleKeyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if token.Method.Alg() != AlgorithmHS256 {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+		if !hmacAccepted() {
+			return nil, ErrLegacyHMACToken
+		}
+		return common.JWTSecret()
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		key, err := ConsoleSigningKeys().LookupKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.SigningMethod().Alg() != token.Method.Alg() {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+		return key.VerifyKey(), nil
+	default:
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+}
+
+// hmacAccepted 判断是否仍接受 HS256 令牌：当前算法为 HS256，或距首把控制台密钥生成未超过宽限期
+func hmacAccepted() bool {
+	if ConsoleAlgorithm() == AlgorithmHS256 {
+		return true
+	}
+	since, err := ConsoleSigningKeys().FirstCreatedAt()
+	if err != nil {
+		return false
+	}
+	if since.IsZero() {
+		// 尚未签发过非对称令牌
+		return true
+	}
+	minutes := settingssvc.GetInt("jwt.hmac_grace_minutes", defaultHMACGraceMinutes)
+	return time.Since(since) < time.Duration(minutes)*time.Minute
+}
+
+// ParseConsoleToken 验证控制台令牌的签名与有效期，并校验 iss / aud。
+// 非对称令牌必须携带与配置一致的 iss（以及配置了受众时的 aud）；迁移前签发的 HS256 令牌未携带时不校验。
+func ParseConsoleToken(tokenStr string, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	token, err := jwt.NewParser(opts...).Parse(tokenStr, ConsoleKeyFunc)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	_, legacy := token.Method.(*jwt.SigningMethodHMAC)
+	iss, hasIss := claims["iss"]
+	if hasIss || !legacy {
+		if s, _ := iss.(string); s != consoleIssuer() {
+			return nil, ErrInvalidTokenIssuer
+		}
+	}
+	if aud := consoleAudience(); aud != "" {
+		_, hasAud := claims["aud"]
+		if hasAud || !legacy {
+			audiences, err := claims.GetAudience()
+			if err != nil || !containsString(audiences, aud) {
+				return nil, ErrInvalidTokenIssuer
+			}
+		}
+	}
+	return token, nil
+}
+
+func containsString(list []string, target string) bool {
+	for _, v := range list {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/settings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func setSettingForTest(t *testing.T, key string, value interface{}, restore interface{}) {
+	t.Helper()
+	if err := settings.Upsert(key, value, "", ""); err != nil {
+		t.Fatalf("set %s failed: %v", key, err)
+	}
+	t.Cleanup(func() { _ = settings.Upsert(key, restore, "", "") })
+}
+
+func TestConsoleTokensSignedWithRotatingKeys(t *testing.T) {
+	db := setupAuthLoginTestDB(t)
+	if err := db.AutoMigrate(&model.SigningKey{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	db.Where("1 = 1").Delete(&model.SigningKey{})
+	setSettingForTest(t, "jwt.audience", "basaltpass-api", "")
+
+	// 切换前签发的 HS256 令牌
+	legacy, err := GenerateTokenPairWithTenantAndScope(1, 0, ConsoleScopeUser)
+	if err != nil {
+		t.Fatalf("token pair invalid %v", err)
+	}
+
+	setSettingForTest(t, "jwt.algorithm", "RS256", AlgorithmHS256)
+	pair, err := GenerateTokenPairWithTenantAndScope(1, 0, ConsoleScopeUser)
+	if err != nil {
+		t.Fatalf("token pair invalid %v", err)
+	}
+	token, err := ParseToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("RS256 token must verify: %v", err)
+	}
+	if token.Method.Alg() != "RS256" || token.Header["kid"] == "" {
+		t.Fatalf("expected RS256 token with kid, got %v", token.Header)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["iss"] != "basaltpass" || claims["aud"] != "basaltpass-api" {
+		t.Fatalf("expected iss and aud claims, got %v", claims)
+	}
+
+	// 宽限期内仍接受 HS256 令牌
+	if _, err := ParseToken(legacy.AccessToken); err != nil {
+		t.Fatalf("legacy token must verify during grace period: %v", err)
+	}
+	setSettingForTest(t, "jwt.hmac_grace_minutes", 0, 60*24*7)
+	if _, err := ParseToken(legacy.AccessToken); !errors.Is(err, ErrLegacyHMACToken) {
+		t.Fatalf("expected legacy token rejected after grace period, got %v", err)
+	}
+}
+
+func TestParseConsoleTokenRejectsForeignIssuer(t *testing.T) {
+	db := setupAuthLoginTestDB(t)
+	if err := db.AutoMigrate(&model.SigningKey{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	setSettingForTest(t, "jwt.algorithm", "RS256", AlgorithmHS256)
+
+	key, err := ConsoleSigningKeys().ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	token := jwt.NewWithClaims(key.SigningMethod(), jwt.MapClaims{
+		"sub": 1,
+		"iss": "someone-else",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = key.KID
+	signed, err := token.SignedString(key.SignKey())
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	if _, err := ParseConsoleToken(signed); !errors.Is(err, ErrInvalidTokenIssuer) {
+		t.Fatalf("expected foreign issuer rejected, got %v", err)
+	}
+}
@@ -14,6 +14,8 @@ type LoginRequest struct {
 	Password     string `json:"password"`
 	TenantID     uint   `json:"tenant_id"` // 租户ID，用于识别用户属于哪个租户
 	Scope        string `json:"-"`
+	IP           string `json:"-"` // 用于登录失败锁定记录
+	UserAgent    string `json:"-"`
 }
 
 // Verify2FARequest defines input for 2FA verification.
@@ -8,6 +8,7 @@ import (
 
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/consolesession"
 	tenantservice "basaltpass-backend/internal/service/tenant"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -35,6 +36,7 @@ func getEnv(key, def string) string {
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	SessionID    string `json:"-"`
 }
 
 const (
@@ -47,6 +49,64 @@ const (
 	TokenTypePreAuth = "pre_auth"
 )
 
+// 认证方式（RFC 8176 amr 取值）
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk" // Passkey / WebAuthn
+	AMRMultiFactor = "mfa"
+	AMRFederated   = "fed" // 由上游身份提供方完成认证（非 RFC 8176 注册值）
+	AMRSMS         = "sms"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// AuthContext 用户完成登录时的认证上下文，随控制台令牌传递，
+// 供 OIDC 授权端点判断 max_age、prompt=login 与 acr_values。
+//
+// SessionID 为空时签发令牌会登记新的控制台会话；非空时沿用该会话并轮换刷新令牌。
+// RefreshJTI 为刷新时客户端出示的刷新令牌 jti，用于识别重放。
+type AuthContext struct {
+	AuthTime   time.Time
+	AMR        []string
+	SessionID  string
+	RefreshJTI string
+}
+
+// NewAuthContext 以当前时间创建认证上下文
+func NewAuthContext(amr ...string) AuthContext {
+	return AuthContext{AuthTime: time.Now(), AMR: amr}
+}
+
+// AuthContextFromClaims 读取令牌中的 auth_time / amr；旧令牌未携带时返回零值
+func AuthContextFromClaims(claims jwt.MapClaims) AuthContext {
+	var ac AuthContext
+	if authTime, ok := claims["auth_time"].(float64); ok && authTime > 0 {
+		ac.AuthTime = time.Unix(int64(authTime), 0)
+	}
+	if amr, ok := claims["amr"].([]interface{}); ok {
+		for _, v := range amr {
+			if s, ok := v.(string); ok && s != "" {
+				ac.AMR = append(ac.AMR, s)
+			}
+		}
+	}
+	return ac
+}
+
+// SetClaims 将认证上下文写入令牌声明；零值不写入
+func (a AuthContext) SetClaims(claims jwt.MapClaims) {
+	if !a.AuthTime.IsZero() {
+		claims["auth_time"] = a.AuthTime.Unix()
+	}
+	if len(a.AMR) > 0 {
+		claims["amr"] = a.AMR
+	}
+}
+
 // GenerateTokenPair creates JWT access and refresh tokens for a user id.
 // 自动从用户记录中获取tenant_id
 func GenerateTokenPair(userID uint) (TokenPair, error) {
@@ -74,10 +134,27 @@ func GenerateTokenPairWithTenant(userID uint, tenantID uint) (TokenPair, error)
 // - tenant: tenant console
 // - admin: global admin console
 func GenerateTokenPairWithTenantAndScope(userID uint, tenantID uint, scope string) (TokenPair, error) {
+	return GenerateTokenPairWithAuthContext(userID, tenantID, scope, AuthContext{})
+}
+
+// GenerateTokenPairWithAuthContext creates JWT tokens carrying the login's auth_time / amr.
+// Refresh and identity switches pass the original context through so that auth_time
+// keeps pointing at the actual authentication event.
+func GenerateTokenPairWithAuthContext(userID uint, tenantID uint, scope string, authCtx AuthContext) (TokenPair, error) {
 	if scope == "" {
 		scope = ConsoleScopeUser
 	}
 
+	// 被封禁或经 SCIM 停用的账号不再签发令牌
+	var banned int64
+	if err := common.DB().Model(&model.User{}).Where("id = ? AND banned = ?", userID, true).Count(&banned).Error; err != nil {
+		log.Printf("[auth][error] banned check failed for userID=%d: %v", userID, err)
+		return TokenPair{}, err
+	}
+	if banned > 0 {
+		return TokenPair{}, ErrAccountDisabled
+	}
+
 	if scope != ConsoleScopeAdmin && tenantID > 0 {
 		allowed, err := tenantservice.IsTenantLoginAllowed(tenantID)
 		if err != nil {
@@ -90,14 +167,41 @@ func GenerateTokenPairWithTenantAndScope(userID uint, tenantID uint, scope strin
 		}
 	}
 
+	// 登记或轮换控制台会话：访问令牌与刷新令牌都携带 sid，刷新令牌的 jti 记录在会话上
+	refreshJTI := consolesession.NewID()
+	refreshExpiresAt := time.Now().Add(refreshTokenTTL)
+	sessions := consolesession.NewService(common.DB())
+	sessionID := authCtx.SessionID
+	if sessionID == "" {
+		session, err := sessions.Create(consolesession.CreateParams{
+			UserID:     userID,
+			TenantID:   tenantID,
+			Scope:      scope,
+			AuthTime:   authCtx.AuthTime,
+			RefreshJTI: refreshJTI,
+			ExpiresAt:  refreshExpiresAt,
+		})
+		if err != nil {
+			log.Printf("[auth][error] Failed to create console session for userID=%d, scope=%s: %v", userID, scope, err)
+			return TokenPair{}, err
+		}
+		sessionID = session.SessionID
+	} else if err := sessions.Rotate(sessionID, authCtx.RefreshJTI, refreshJTI, tenantID, refreshExpiresAt); err != nil {
+		log.Printf("[auth][warn] Console session rotation rejected for userID=%d, sid=%s: %v", userID, sessionID, err)
+		return TokenPair{}, err
+	}
+
 	accessClaims := jwt.MapClaims{
 		"sub": userID,
 		"tid": tenantID, // 租户ID - 现在直接使用user.tenant_id
 		"scp": scope,    // console scope
 		"typ": TokenTypeAccess,
-		"exp": time.Now().Add(15 * time.Minute).Unix(),
+		"sid": sessionID,
+		"jti": consolesession.NewID(),
+		"exp": time.Now().Add(accessTokenTTL).Unix(),
 	}
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString(common.MustJWTSecret())
+	authCtx.SetClaims(accessClaims)
+	accessToken, err := signConsoleToken(accessClaims)
 	if err != nil {
 		log.Printf("[auth][error] Failed to sign access token for userID=%d, tenantID=%d, scope=%s: %v", userID, tenantID, scope, err)
 		return TokenPair{}, err
@@ -107,17 +211,20 @@ func GenerateTokenPairWithTenantAndScope(userID uint, tenantID uint, scope strin
 		"sub": userID,
 		"tid": tenantID,
 		"scp": scope,
-		"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"exp": refreshExpiresAt.Unix(),
 		"typ": TokenTypeRefresh,
+		"sid": sessionID,
+		"jti": refreshJTI,
 	}
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString(common.MustJWTSecret())
+	authCtx.SetClaims(refreshClaims)
+	refreshToken, err := signConsoleToken(refreshClaims)
 	if err != nil {
 		log.Printf("[auth][error] Failed to sign refresh token for userID=%d, tenantID=%d, scope=%s: %v", userID, tenantID, scope, err)
 		return TokenPair{}, err
 	}
 
 	log.Printf("[auth][debug] Tokens generated successfully for userID=%d, tenantID=%d, scope=%s", userID, tenantID, scope)
-	return TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+	return TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, SessionID: sessionID}, nil
 }
 
 func resolveTokenTenantID(userID uint, claimedTenantID uint, scope string) (uint, error) {
@@ -155,14 +262,15 @@ func resolveTokenTenantID(userID uint, claimedTenantID uint, scope string) (uint
 	return 0, nil
 }
 
-// ParseToken validates a JWT and returns claims.
+// SessionIDFromClaims 读取令牌中的控制台会话ID；会话登记之前签发的旧令牌返回空串
+func SessionIDFromClaims(claims jwt.MapClaims) string {
+	sid, _ := claims["sid"].(string)
+	return sid
+}
+
+// ParseToken validates a console JWT (asymmetric, or HS256 during the migration window) and returns claims.
 func ParseToken(tokenStr string) (*jwt.Token, error) {
-	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrTokenSignatureInvalid
-		}
-		return common.MustJWTSecret(), nil
-	})
+	return ParseConsoleToken(tokenStr)
 }
 
 // ValidateAccessTokenType accepts explicit access tokens and, during the
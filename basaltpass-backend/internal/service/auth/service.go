@@ -3,12 +3,16 @@ package auth
 import (
 	"basaltpass-backend/internal/handler/user/security"
 	"basaltpass-backend/internal/service/aduit"
+	"basaltpass-backend/internal/service/ldapauth"
+	"basaltpass-backend/internal/service/lockout"
+	"basaltpass-backend/internal/service/mfa"
 	settingssvc "basaltpass-backend/internal/service/settings"
 	tenantservice "basaltpass-backend/internal/service/tenant"
 	"basaltpass-backend/internal/utils"
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -29,6 +33,7 @@ var (
 	ErrPlatformAdminOnly   = errors.New("only administrators can login to platform")
 	ErrTenantAccountOnly   = errors.New("tenant account must login via tenant portal")
 	ErrTenantLoginDisabled = errors.New("tenant login is disabled")
+	ErrAccountDisabled     = errors.New("account is disabled")
 	ErrServiceUnavailable  = errors.New("authentication service temporarily unavailable")
 )
 
@@ -206,6 +211,34 @@ func (s Service) LoginV2(req LoginRequest) (LoginResult, error) {
 		}
 	}
 
+	lockoutSvc := lockout.NewService(common.DB())
+
+	// 租户配置了 LDAP 目录时先由目录校验密码；目录中没有该用户或目录不可用时回落到本地密码。
+	// 已绑定目录的账号本地密码已清除，无法通过回落登录。目录登录同样受账号锁定策略约束。
+	var directoryUser *model.User
+	if req.TenantID > 0 {
+		guard := directoryLockout{svc: lockoutSvc, ip: req.IP, userAgent: req.UserAgent}
+		u, err := ldapauth.NewService(common.DB()).Authenticate(req.TenantID, identifier, req.Password, guard)
+		switch {
+		case err == nil:
+			directoryUser = u
+		case errors.Is(err, lockout.ErrAccountLocked), errors.Is(err, ErrServiceUnavailable):
+			return LoginResult{}, err
+		case errors.Is(err, ldapauth.ErrDirectoryNotFound), errors.Is(err, ldapauth.ErrUserNotFound):
+		case errors.Is(err, ldapauth.ErrUserBanned):
+			return LoginResult{}, ErrAccountDisabled
+		case errors.Is(err, ldapauth.ErrInvalidCredentials),
+			errors.Is(err, ldapauth.ErrProvisioningDisabled),
+			errors.Is(err, ldapauth.ErrEmailRequired),
+			errors.Is(err, ldapauth.ErrEmailConflict),
+			errors.Is(err, ldapauth.ErrLinkRequired):
+			log.Printf("[auth][warn] ldap login for tenant %d rejected: %v", req.TenantID, err)
+			return LoginResult{}, ErrInvalidCredentials
+		default:
+			log.Printf("[auth][warn] ldap directory of tenant %d unavailable, falling back to local password: %v", req.TenantID, err)
+		}
+	}
+
 	var user model.User
 	ctx, cancel := context.WithTimeout(context.Background(), loginQueryTimeout)
 	defer cancel()
@@ -215,7 +248,11 @@ func (s Service) LoginV2(req LoginRequest) (LoginResult, error) {
 	// 构建查询条件：email/phone + tenant_id
 	query := db.Preload("Passkeys").Where("email = ? OR phone = ?", identifier, identifier)
 
-	if req.TenantID == 0 {
+	if directoryUser != nil {
+		if err := db.Preload("Passkeys").First(&user, directoryUser.ID).Error; err != nil {
+			return LoginResult{}, normalizeLoginQueryError(err)
+		}
+	} else if req.TenantID == 0 {
 		// 全局登录：仅允许 tenant_id=0 账户。
 		query = query.Where("tenant_id = 0")
 		if err := query.First(&user).Error; err != nil {
@@ -275,10 +312,117 @@ func (s Service) LoginV2(req LoginRequest) (LoginResult, error) {
 
 LOGIN_USER_FOUND:
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return LoginResult{}, ErrInvalidCredentials
+	// 锁定期内拒绝登录，且不再校验密码
+	if err := lockoutSvc.Check(user.ID); err != nil {
+		return LoginResult{}, lockoutError(err)
+	}
+
+	if directoryUser == nil {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+			if lockErr := lockoutSvc.RecordFailure(user.ID, req.IP, req.UserAgent); lockErr != nil {
+				return LoginResult{}, lockoutError(lockErr)
+			}
+			return LoginResult{}, ErrInvalidCredentials
+		}
+	}
+	if err := lockoutSvc.RecordSuccess(user.ID); err != nil {
+		log.Printf("[auth][warn] reset lockout counters for user %d failed: %v", user.ID, err)
+	}
+
+	return completeLogin(db, &user, req.TenantID, req.Scope, AMRPassword)
+}
+
+// PasswordlessLogin 为已通过免密登录校验（邮箱链接/验证码或短信验证码）的账号完成登录，
+// 与密码登录共用二次验证判定与令牌签发。amr 为第一因素的认证方式。
+func (s Service) PasswordlessLogin(userID, tenantID uint, scope, amr string) (LoginResult, error) {
+	return s.loginVerifiedUser(userID, tenantID, scope, amr)
+}
+
+// FederatedLogin 为已通过外部身份提供方认证的账号完成登录；tenantID 为身份提供方所属租户。
+// 与其他登录方式一样检查锁定、租户成员关系，并在启用二次验证时返回 pre_auth_token。
+func (s Service) FederatedLogin(userID, tenantID uint) (LoginResult, error) {
+	return s.loginVerifiedUser(userID, tenantID, ConsoleScopeUser, AMRFederated)
+}
+
+// loginVerifiedUser 第一因素已在别处校验的登录：检查租户入口、账号状态、锁定与成员关系后进入 completeLogin
+func (s Service) loginVerifiedUser(userID, tenantID uint, scope, amr string) (LoginResult, error) {
+	if scope == "" {
+		scope = ConsoleScopeUser
+	}
+	if tenantID > 0 {
+		allowed, err := tenantservice.IsTenantLoginAllowed(tenantID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return LoginResult{}, ErrInvalidCredentials
+			}
+			return LoginResult{}, fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
+		}
+		if !allowed {
+			return LoginResult{}, ErrTenantLoginDisabled
+		}
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), loginQueryTimeout)
+	defer cancel()
+	db := common.DB().WithContext(ctx)
+
+	var user model.User
+	if err := db.Preload("Passkeys").First(&user, userID).Error; err != nil {
+		return LoginResult{}, normalizeLoginQueryError(err)
+	}
+	if user.Banned {
+		return LoginResult{}, ErrAccountDisabled
+	}
+	if err := lockout.NewService(common.DB()).Check(user.ID); err != nil {
+		return LoginResult{}, lockoutError(err)
+	}
+
+	// 与 LoginV2 一致：租户入口只接受该租户的本地账号或仍具备成员关系的全局账号
+	if tenantID > 0 && user.TenantID != tenantID {
+		var membershipCount int64
+		if err := db.Model(&model.TenantUser{}).
+			Where("user_id = ? AND tenant_id = ?", user.ID, tenantID).
+			Count(&membershipCount).Error; err != nil {
+			return LoginResult{}, fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
+		}
+		if membershipCount == 0 {
+			return LoginResult{}, ErrInvalidCredentials
+		}
+	}
+	return completeLogin(db, &user, tenantID, scope, amr)
+}
+
+// directoryLockout 让目录登录的失败计入发起请求的客户端信息
+type directoryLockout struct {
+	svc       *lockout.Service
+	ip        string
+	userAgent string
+}
+
+func (g directoryLockout) Check(userID uint) error {
+	if err := g.svc.Check(userID); err != nil {
+		return lockoutError(err)
+	}
+	return nil
+}
+
+func (g directoryLockout) RecordFailure(userID uint) error {
+	if err := g.svc.RecordFailure(userID, g.ip, g.userAgent); err != nil {
+		return lockoutError(err)
+	}
+	return nil
+}
+
+// lockoutError 锁定错误原样返回（携带截止时间），其余视为服务不可用
+func lockoutError(err error) error {
+	if errors.Is(err, lockout.ErrAccountLocked) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
+}
+
+// completeLogin 第一因素校验通过后判断是否需要二次验证，不需要时直接签发令牌
+func completeLogin(db *gorm.DB, user *model.User, tenantID uint, scope, amr string) (LoginResult, error) {
 	// 读取管理员配置的 2FA 方式开关
 	totpMethodEnabled := settingssvc.GetBool("auth.2fa.totp_enabled", true)
 	passkeyMethodEnabled := settingssvc.GetBool("auth.2fa.passkey_enabled", true)
@@ -292,7 +436,7 @@ LOGIN_USER_FOUND:
 	var totpCfg model.UserTenantTOTP
 	totpEnabled := false
 	if totpMethodEnabled {
-		if err := db.Where("user_id = ? AND tenant_id = ? AND enabled = ?", user.ID, req.TenantID, true).
+		if err := db.Where("user_id = ? AND tenant_id = ? AND enabled = ?", user.ID, tenantID, true).
 			First(&totpCfg).Error; err == nil {
 			totpEnabled = true
 		}
@@ -306,6 +450,10 @@ LOGIN_USER_FOUND:
 		if totpEnabled {
 			availableMethods = append(availableMethods, "totp")
 			defaultMethod = "totp"
+			// 丢失身份验证器时可使用一次性恢复码
+			if remaining, err := mfa.NewService(db).RemainingRecoveryCodes(user.ID, tenantID); err == nil && remaining > 0 {
+				availableMethods = append(availableMethods, "recovery_code")
+			}
 		}
 		if passkeyMethodEnabled && len(user.Passkeys) > 0 {
 			availableMethods = append(availableMethods, "passkey")
@@ -336,7 +484,7 @@ LOGIN_USER_FOUND:
 	// pre_auth_token 5 分钟内有效，客户端回传到 /auth/verify-2fa，
 	// 服务端从 token 中读取 user_id，不再信任客户端提交的 user_id。
 	if len(availableMethods) > 0 {
-		preAuthToken, err := GeneratePreAuthToken(user.ID, req.TenantID)
+		preAuthToken, err := GeneratePreAuthToken(user.ID, tenantID)
 		if err != nil {
 			return LoginResult{}, fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
 		}
@@ -351,12 +499,12 @@ LOGIN_USER_FOUND:
 
 	// 不需要二次验证，直接登录
 	tokenScope := ConsoleScopeUser
-	tokenTenantID := req.TenantID
-	if req.Scope == ConsoleScopeAdmin {
+	tokenTenantID := tenantID
+	if scope == ConsoleScopeAdmin {
 		tokenScope = ConsoleScopeAdmin
 		tokenTenantID = 0
 	}
-	tokens, err := GenerateTokenPairWithTenantAndScope(user.ID, tokenTenantID, tokenScope)
+	tokens, err := GenerateTokenPairWithAuthContext(user.ID, tokenTenantID, tokenScope, NewAuthContext(amr))
 	if err != nil {
 		return LoginResult{}, fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
 	}
@@ -390,7 +538,17 @@ func (s Service) Refresh(refreshToken string) (TokenPair, error) {
 	if err != nil {
 		return TokenPair{}, err
 	}
-	return GenerateTokenPairWithTenantAndScope(uint(userIDFloat), tenantID, scope)
+	// 刷新不是重新认证，沿用原登录的 auth_time / amr；
+	// 携带 sid 的令牌必须对应有效会话且 jti 为会话当前的刷新令牌，旧令牌（无 sid）刷新时登记新会话
+	authCtx := AuthContextFromClaims(claims)
+	authCtx.SessionID = SessionIDFromClaims(claims)
+	if authCtx.SessionID != "" {
+		authCtx.RefreshJTI, _ = claims["jti"].(string)
+		if authCtx.RefreshJTI == "" {
+			return TokenPair{}, errors.New("invalid token")
+		}
+	}
+	return GenerateTokenPairWithAuthContext(uint(userIDFloat), tenantID, scope, authCtx)
 }
 
 // Verify2FA 校验二次验证信息，成功返回token。
@@ -407,6 +565,7 @@ func (s Service) Verify2FA(req Verify2FARequest) (TokenPair, error) {
 	if err := db.First(&user, userID).Error; err != nil {
 		return TokenPair{}, errors.New("user not found")
 	}
+	authCtx := NewAuthContext(AMRPassword)
 	switch req.TwoFAType {
 	case "totp":
 		if !settingssvc.GetBool("auth.2fa.totp_enabled", true) {
@@ -425,6 +584,26 @@ func (s Service) Verify2FA(req Verify2FARequest) (TokenPair, error) {
 		if !security.ValidateTOTP(rawSecret, req.Code) {
 			return TokenPair{}, errors.New("invalid TOTP code")
 		}
+		authCtx.AMR = append(authCtx.AMR, AMROTP, AMRMultiFactor)
+	case "recovery_code":
+		if !settingssvc.GetBool("auth.2fa.totp_enabled", true) {
+			return TokenPair{}, errors.New("TOTP 2FA is disabled by administrator")
+		}
+		// 恢复码只对已启用 TOTP 的租户有效，使用后立即失效
+		var totpCfg model.UserTenantTOTP
+		if err := db.Where("user_id = ? AND tenant_id = ? AND enabled = ?", userID, tenantID, true).
+			First(&totpCfg).Error; err != nil {
+			return TokenPair{}, errors.New("TOTP 2FA not enabled for this tenant")
+		}
+		ok, err := mfa.NewService(db).ConsumeRecoveryCode(userID, tenantID, req.Code)
+		if err != nil {
+			return TokenPair{}, fmt.Errorf("%w: %v", ErrServiceUnavailable, err)
+		}
+		if !ok {
+			return TokenPair{}, errors.New("invalid recovery code")
+		}
+		aduit.LogAudit(userID, "使用恢复码登录", "user", fmt.Sprint(userID), "", "")
+		authCtx.AMR = append(authCtx.AMR, AMROTP, AMRMultiFactor)
 	case "passkey":
 		if !settingssvc.GetBool("auth.2fa.passkey_enabled", true) {
 			return TokenPair{}, errors.New("Passkey 2FA is disabled by administrator")
@@ -452,7 +631,7 @@ func (s Service) Verify2FA(req Verify2FARequest) (TokenPair, error) {
 	default:
 		return TokenPair{}, errors.New("unsupported 2FA type")
 	}
-	return GenerateTokenPairWithTenantAndScope(user.ID, tenantID, ConsoleScopeUser)
+	return GenerateTokenPairWithAuthContext(user.ID, tenantID, ConsoleScopeUser, authCtx)
 }
 
 // setupFirstUserAsGlobalAdmin 设置第一个用户为全局管理员。
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/consolesession"
+	"basaltpass-backend/internal/service/lockout"
 
 	"github.com/glebarez/sqlite"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,8 +18,8 @@ import (
 )
 
 func TestGenerateTokenPair(t *testing.T) {
-	// 设置测试环境变量
-	os.Setenv("JWT_SECRET", "test-secret-for-unit-tests")
+	// 令牌签发会登记控制台会话，需要数据库
+	setupAuthLoginTestDB(t)
 
 	// Platform-scoped token generation does not need tenant auth settings.
 	p, err := GenerateTokenPairWithTenantAndScope(1, 0, ConsoleScopeUser)
@@ -34,7 +37,7 @@ func setupAuthLoginTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("open sqlite failed: %v", err)
 	}
 
-	if err := db.AutoMigrate(&model.User{}, &model.Tenant{}, &model.TenantAuthSetting{}, &model.Passkey{}, &model.TenantUser{}, &model.UserTenantTOTP{}); err != nil {
+	if err := db.AutoMigrate(&model.User{}, &model.Tenant{}, &model.TenantAuthSetting{}, &model.Passkey{}, &model.TenantUser{}, &model.UserTenantTOTP{}, &model.ConsoleSession{}, &model.AccountLockout{}, &model.LoginHistory{}, &model.AuditLog{}); err != nil {
 		t.Fatalf("auto migrate failed: %v", err)
 	}
 
@@ -147,3 +150,84 @@ func TestLoginV2GlobalPortalAllowsRegularUserInAdminScope(t *testing.T) {
 		t.Fatalf("expected tenant id 0, got %v", claims["tid"])
 	}
 }
+
+func TestRefreshRotatesConsoleSession(t *testing.T) {
+	db := setupAuthLoginTestDB(t)
+	user := model.User{Email: "session@example.com", PasswordHash: "x", Nickname: "session-user"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+
+	pair, err := GenerateTokenPairWithAuthContext(user.ID, 0, ConsoleScopeUser, NewAuthContext(AMRPassword))
+	if err != nil || pair.SessionID == "" {
+		t.Fatalf("token pair invalid %v", err)
+	}
+	token, _ := ParseToken(pair.AccessToken)
+	if SessionIDFromClaims(token.Claims.(jwt.MapClaims)) != pair.SessionID {
+		t.Fatalf("expected access token to carry sid")
+	}
+
+	// 刷新沿用同一会话并轮换 jti
+	refreshed, err := Service{}.Refresh(pair.RefreshToken)
+	if err != nil || refreshed.SessionID != pair.SessionID {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	// 宽限期内重复使用旧刷新令牌：拒绝但不撤销会话
+	if _, err := (Service{}).Refresh(pair.RefreshToken); !errors.Is(err, consolesession.ErrRefreshRaced) {
+		t.Fatalf("expected ErrRefreshRaced, got %v", err)
+	}
+
+	// 宽限期过后重放旧刷新令牌：撤销整个会话，新令牌也随之失效
+	db.Model(&model.ConsoleSession{}).Where("session_id = ?", pair.SessionID).
+		Update("rotated_at", time.Now().Add(-time.Hour))
+	if _, err := (Service{}).Refresh(pair.RefreshToken); !errors.Is(err, consolesession.ErrRefreshReused) {
+		t.Fatalf("expected ErrRefreshReused, got %v", err)
+	}
+	if _, err := (Service{}).Refresh(refreshed.RefreshToken); !errors.Is(err, consolesession.ErrSessionInactive) {
+		t.Fatalf("expected revoked session to reject refresh, got %v", err)
+	}
+}
+
+func TestFederatedLoginAppliesLoginChecks(t *testing.T) {
+	db := setupAuthLoginTestDB(t)
+
+	tenant := model.Tenant{Name: "Federated", Code: "federated-login"}
+	if err := db.Create(&tenant).Error; err != nil {
+		t.Fatalf("create tenant failed: %v", err)
+	}
+	user := model.User{Email: "federated@example.com", Nickname: "federated", EmailVerified: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+
+	// 全局账号不再是租户成员时不能获得租户令牌
+	if _, err := (Service{}).FederatedLogin(user.ID, tenant.ID); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected non-member to be rejected, got %v", err)
+	}
+	if err := db.Create(&model.TenantUser{UserID: user.ID, TenantID: tenant.ID, Role: model.TenantRoleMember}).Error; err != nil {
+		t.Fatalf("create membership failed: %v", err)
+	}
+	res, err := (Service{}).FederatedLogin(user.ID, tenant.ID)
+	if err != nil || res.Need2FA || res.AccessToken == "" {
+		t.Fatalf("expected member to log in, got %+v %v", res, err)
+	}
+
+	// 启用 TOTP 后要求二次验证
+	if err := db.Create(&model.UserTenantTOTP{UserID: user.ID, TenantID: tenant.ID, Secret: "secret", Enabled: true}).Error; err != nil {
+		t.Fatalf("enable totp failed: %v", err)
+	}
+	res, err = (Service{}).FederatedLogin(user.ID, tenant.ID)
+	if err != nil || !res.Need2FA || res.PreAuthToken == "" || res.AccessToken != "" {
+		t.Fatalf("expected second factor to be required, got %+v %v", res, err)
+	}
+
+	// 锁定的账号不能登录
+	lockedUntil := time.Now().Add(time.Hour)
+	if err := db.Create(&model.AccountLockout{UserID: user.ID, LockedUntil: &lockedUntil}).Error; err != nil {
+		t.Fatalf("lock account failed: %v", err)
+	}
+	if _, err := (Service{}).FederatedLogin(user.ID, tenant.ID); !errors.Is(err, lockout.ErrAccountLocked) {
+		t.Fatalf("expected locked account to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,427 @@
+package claimmapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRequest claims 请求参数不是合法的 JSON 对象
+var ErrInvalidRequest = errors.New("invalid claims request")
+
+var claimNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_:.\-/]{0,99}$`)
+
+// reservedClaims 协议声明由签发方生成，映射不可占用
+var reservedClaims = map[string]struct{}{
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "iat": {}, "nbf": {}, "jti": {},
+	"azp": {}, "nonce": {}, "auth_time": {}, "acr": {}, "amr": {}, "at_hash": {}, "c_hash": {},
+	"sid": {}, "client_id": {}, "scope": {}, "tid": {}, "app_id": {}, "act": {}, "cnf": {},
+}
+
+// profileFields profile 来源可引用的用户字段
+var profileFields = map[string]func(u *model.User, p *model.UserProfile) interface{}{
+	"user_uuid":      func(u *model.User, _ *model.UserProfile) interface{} { return u.UserUUID },
+	"nickname":       func(u *model.User, _ *model.UserProfile) interface{} { return u.Nickname },
+	"email":          func(u *model.User, _ *model.UserProfile) interface{} { return u.Email },
+	"email_verified": func(u *model.User, _ *model.UserProfile) interface{} { return u.EmailVerified },
+	"phone":          func(u *model.User, _ *model.UserProfile) interface{} { return u.Phone },
+	"phone_verified": func(u *model.User, _ *model.UserProfile) interface{} { return u.PhoneVerified },
+	"avatar_url":     func(u *model.User, _ *model.UserProfile) interface{} { return u.AvatarURL },
+	"mfa_enabled":    func(u *model.User, _ *model.UserProfile) interface{} { return u.TwoFAEnabled || u.MFAEnabled },
+	"timezone":       func(_ *model.User, p *model.UserProfile) interface{} { return p.Timezone },
+	"bio":            func(_ *model.User, p *model.UserProfile) interface{} { return p.Bio },
+	"location":       func(_ *model.User, p *model.UserProfile) interface{} { return p.Location },
+	"website":        func(_ *model.User, p *model.UserProfile) interface{} { return p.Website },
+	"company":        func(_ *model.User, p *model.UserProfile) interface{} { return p.Company },
+	"job_title":      func(_ *model.User, p *model.UserProfile) interface{} { return p.JobTitle },
+	"birthdate": func(_ *model.User, p *model.UserProfile) interface{} {
+		if p.BirthDate == nil {
+			return nil
+		}
+		return p.BirthDate.Format("2006-01-02")
+	},
+	"gender": func(_ *model.User, p *model.UserProfile) interface{} {
+		if p.Gender == nil {
+			return nil
+		}
+		return p.Gender.Code
+	},
+	"locale": func(_ *model.User, p *model.UserProfile) interface{} {
+		if p.Language == nil {
+			return nil
+		}
+		return p.Language.Code
+	},
+}
+
+// Requested claims 请求参数中单个声明的请求方式；值为 null 时按默认方式请求
+type Requested struct {
+	Essential bool          `json:"essential,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// Request OIDC claims 请求参数（OIDC Core §5.5）
+type Request struct {
+	UserInfo map[string]*Requested `json:"userinfo,omitempty"`
+	IDToken  map[string]*Requested `json:"id_token,omitempty"`
+}
+
+// ParseRequest 解析 claims 请求参数，空字符串返回 nil
+func ParseRequest(raw string) (*Request, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var req Request
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		return nil, ErrInvalidRequest
+	}
+	return &req, nil
+}
+
+// members 返回指定位置请求的声明；访问令牌不在 claims 参数的范围内
+func (r *Request) members(target string) map[string]*Requested {
+	if r == nil {
+		return nil
+	}
+	switch target {
+	case model.ClaimTargetIDToken:
+		return r.IDToken
+	case model.ClaimTargetUserInfo:
+		return r.UserInfo
+	}
+	return nil
+}
+
+// Requests 判断指定位置是否显式请求了该声明
+func (r *Request) Requests(target, name string) bool {
+	_, ok := r.members(target)[name]
+	return ok
+}
+
+// ACRValues 返回 id_token.acr 请求的认证等级（空格分隔），未请求时返回空字符串
+func (r *Request) ACRValues() string {
+	acr := r.members(model.ClaimTargetIDToken)["acr"]
+	if acr == nil {
+		return ""
+	}
+	var values []string
+	if v, ok := acr.Value.(string); ok && v != "" {
+		values = append(values, v)
+	}
+	for _, item := range acr.Values {
+		if v, ok := item.(string); ok && v != "" {
+			values = append(values, v)
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// Context 解析声明所需的授权上下文
+type Context struct {
+	TenantID uint
+	AppID    uint
+	UserID   uint
+	Scopes   string   // 已授权的 scope，空格分隔
+	Request  *Request // claims 请求参数，可为空
+}
+
+// Service 按应用配置的声明映射生成自定义声明
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建声明映射服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// ValidateMapping 校验声明映射配置
+func ValidateMapping(m *model.AppClaimMapping) error {
+	m.ClaimName = strings.TrimSpace(m.ClaimName)
+	m.Scope = strings.TrimSpace(m.Scope)
+	if !claimNamePattern.MatchString(m.ClaimName) {
+		return fmt.Errorf("声明名称不合法")
+	}
+	if _, ok := reservedClaims[m.ClaimName]; ok {
+		return fmt.Errorf("声明名称 %s 为协议保留声明", m.ClaimName)
+	}
+	if strings.ContainsAny(m.Scope, " ,") {
+		return fmt.Errorf("scope 只能填写单个值")
+	}
+
+	switch m.Source {
+	case model.ClaimSourceTenantRoles, model.ClaimSourceAppRoles, model.ClaimSourceAppPermissions:
+	case model.ClaimSourceTeams:
+		if m.Value != "" && m.Value != "name" && m.Value != "id" {
+			return fmt.Errorf("团队声明的取值只能是 name 或 id")
+		}
+	case model.ClaimSourceProfile:
+		if _, ok := profileFields[m.Value]; !ok {
+			return fmt.Errorf("不支持的用户资料字段: %s", m.Value)
+		}
+	case model.ClaimSourceStatic:
+		if !json.Valid([]byte(m.Value)) {
+			return fmt.Errorf("固定值必须是合法的 JSON")
+		}
+	default:
+		return fmt.Errorf("不支持的声明来源: %s", m.Source)
+	}
+
+	var targets []string
+	for _, t := range m.GetTargetList() {
+		switch t {
+		case model.ClaimTargetIDToken, model.ClaimTargetUserInfo, model.ClaimTargetAccessToken, model.ClaimTargetSAML:
+			targets = append(targets, t)
+		default:
+			return fmt.Errorf("不支持的注入位置: %s", t)
+		}
+	}
+	m.Targets = strings.Join(targets, ",")
+	return nil
+}
+
+// IsReserved 判断声明名称是否为协议保留声明
+func IsReserved(name string) bool {
+	_, ok := reservedClaims[name]
+	return ok
+}
+
+// Resolve 返回指定位置应注入的自定义声明。
+// 映射的 Scope 未被授权时不输出（SAML 断言没有 scope，不做此限制）；
+// claims 参数显式请求的声明即使不在映射的 Targets 中也会输出到对应位置。
+// 没有取值的声明不输出（OIDC Core §5.3.2）。
+func (s *Service) Resolve(ctx *Context, target string) (map[string]interface{}, error) {
+	if ctx == nil || ctx.AppID == 0 || ctx.UserID == 0 {
+		return nil, nil
+	}
+
+	var mappings []model.AppClaimMapping
+	if err := s.db.Where("app_id = ?", ctx.AppID).Order("id ASC").Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+	if len(mappings) == 0 {
+		return nil, nil
+	}
+
+	granted := make(map[string]struct{})
+	for _, scope := range strings.Fields(ctx.Scopes) {
+		granted[scope] = struct{}{}
+	}
+
+	selected := make([]model.AppClaimMapping, 0, len(mappings))
+	for _, m := range mappings {
+		if m.Scope != "" && target != model.ClaimTargetSAML {
+			if _, ok := granted[m.Scope]; !ok {
+				continue
+			}
+		}
+		if !m.HasTarget(target) && !ctx.Request.Requests(target, m.ClaimName) {
+			continue
+		}
+		selected = append(selected, m)
+	}
+	return s.Evaluate(ctx, selected)
+}
+
+// Evaluate 按给定的映射解析取值（不做 scope 与位置过滤），没有取值的声明不输出
+func (s *Service) Evaluate(ctx *Context, mappings []model.AppClaimMapping) (map[string]interface{}, error) {
+	if ctx == nil || ctx.UserID == 0 {
+		return nil, nil
+	}
+	r := &resolver{db: s.db, ctx: ctx, cache: make(map[string]interface{})}
+	out := make(map[string]interface{})
+	for i := range mappings {
+		m := &mappings[i]
+		value, err := r.value(m)
+		if err != nil {
+			return nil, err
+		}
+		if isEmpty(value) {
+			continue
+		}
+		out[m.ClaimName] = value
+	}
+	return out, nil
+}
+
+// resolver 在一次解析中缓存各来源的查询结果
+type resolver struct {
+	db      *gorm.DB
+	ctx     *Context
+	cache   map[string]interface{}
+	user    *model.User
+	profile *model.UserProfile
+}
+
+func (r *resolver) value(m *model.AppClaimMapping) (interface{}, error) {
+	switch m.Source {
+	case model.ClaimSourceStatic:
+		var v interface{}
+		if err := json.Unmarshal([]byte(m.Value), &v); err != nil {
+			// 非 JSON 的历史数据按字符串输出
+			return m.Value, nil
+		}
+		return v, nil
+	case model.ClaimSourceProfile:
+		field, ok := profileFields[m.Value]
+		if !ok {
+			return nil, nil
+		}
+		if err := r.loadUser(); err != nil {
+			return nil, err
+		}
+		return field(r.user, r.profile), nil
+	}
+
+	key := m.Source + ":" + m.Value
+	if v, ok := r.cache[key]; ok {
+		return v, nil
+	}
+	var (
+		v   interface{}
+		err error
+	)
+	switch m.Source {
+	case model.ClaimSourceTenantRoles:
+		v, err = r.tenantRoles()
+	case model.ClaimSourceAppRoles:
+		v, err = r.appRoles()
+	case model.ClaimSourceAppPermissions:
+		v, err = r.appPermissions()
+	case model.ClaimSourceTeams:
+		v, err = r.teams(m.Value == "id")
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.cache[key] = v
+	return v, nil
+}
+
+func (r *resolver) loadUser() error {
+	if r.user != nil {
+		return nil
+	}
+	var user model.User
+	if err := r.db.First(&user, r.ctx.UserID).Error; err != nil {
+		return err
+	}
+	var profile model.UserProfile
+	if err := r.db.Preload("Gender").Preload("Language").Where("user_id = ?", user.ID).First(&profile).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	r.user, r.profile = &user, &profile
+	return nil
+}
+
+func (r *resolver) tenantRoles() ([]string, error) {
+	var codes []string
+	err := r.db.Table("tenant_user_roles").
+		Select("DISTINCT tenant_roles.code").
+		Joins("JOIN tenant_roles ON tenant_roles.id = tenant_user_roles.role_id").
+		Where("tenant_user_roles.user_id = ? AND tenant_user_roles.tenant_id = ?", r.ctx.UserID, r.ctx.TenantID).
+		Where("tenant_user_roles.expires_at IS NULL OR tenant_user_roles.expires_at > ?", time.Now()).
+		Pluck("tenant_roles.code", &codes).Error
+	sort.Strings(codes)
+	return codes, err
+}
+
+func (r *resolver) appRoles() ([]string, error) {
+	var codes []string
+	err := r.db.Table("app_user_roles").
+		Select("DISTINCT app_roles.code").
+		Joins("JOIN app_roles ON app_roles.id = app_user_roles.role_id").
+		Where("app_user_roles.user_id = ? AND app_user_roles.app_id = ?", r.ctx.UserID, r.ctx.AppID).
+		Where("app_user_roles.expires_at IS NULL OR app_user_roles.expires_at > ?", time.Now()).
+		Pluck("app_roles.code", &codes).Error
+	sort.Strings(codes)
+	return codes, err
+}
+
+func (r *resolver) appPermissions() ([]string, error) {
+	var direct []string
+	err := r.db.Table("app_user_permissions").
+		Select("app_permissions.code").
+		Joins("JOIN app_permissions ON app_permissions.id = app_user_permissions.permission_id").
+		Where("app_user_permissions.user_id = ? AND app_user_permissions.app_id = ?", r.ctx.UserID, r.ctx.AppID).
+		Where("app_user_permissions.expires_at IS NULL OR app_user_permissions.expires_at > ?", time.Now()).
+		Pluck("app_permissions.code", &direct).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var inherited []string
+	err = r.db.Table("app_user_roles").
+		Select("DISTINCT app_permissions.code").
+		Joins("JOIN app_roles ON app_roles.id = app_user_roles.role_id").
+		Joins("JOIN app_role_permissions ON app_role_permissions.app_role_id = app_roles.id").
+		Joins("JOIN app_permissions ON app_permissions.id = app_role_permissions.app_permission_id").
+		Where("app_user_roles.user_id = ? AND app_user_roles.app_id = ?", r.ctx.UserID, r.ctx.AppID).
+		Where("app_user_roles.expires_at IS NULL OR app_user_roles.expires_at > ?", time.Now()).
+		Pluck("app_permissions.code", &inherited).Error
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{}, len(direct)+len(inherited))
+	for _, code := range append(direct, inherited...) {
+		set[code] = struct{}{}
+	}
+	codes := make([]string, 0, len(set))
+	for code := range set {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+func (r *resolver) teams(byID bool) (interface{}, error) {
+	var teams []model.Team
+	err := r.db.Table("system_auth_team_members").
+		Select("system_auth_teams.*").
+		Joins("JOIN system_auth_teams ON system_auth_teams.id = system_auth_team_members.team_id").
+		Where("system_auth_team_members.user_id = ? AND system_auth_team_members.status = ?", r.ctx.UserID, "active").
+		Where("system_auth_team_members.deleted_at IS NULL AND system_auth_teams.deleted_at IS NULL").
+		Where("system_auth_teams.tenant_id = ? AND system_auth_teams.is_active = ?", r.ctx.TenantID, true).
+		Order("system_auth_teams.id ASC").
+		Find(&teams).Error
+	if err != nil {
+		return nil, err
+	}
+	if byID {
+		ids := make([]uint, 0, len(teams))
+		for _, t := range teams {
+			ids = append(ids, t.ID)
+		}
+		return ids, nil
+	}
+	names := make([]string, 0, len(teams))
+	for _, t := range teams {
+		names = append(names, t.Name)
+	}
+	return names, nil
+}
+
+func isEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []string:
+		return len(val) == 0
+	case []uint:
+		return len(val) == 0
+	}
+	return false
+}
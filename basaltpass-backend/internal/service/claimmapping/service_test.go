@@ -0,0 +1,140 @@
+package claimmapping
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupClaimMappingTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.User{}, &model.UserProfile{}, &model.Gender{}, &model.Language{},
+		&model.AppClaimMapping{}, &model.AppRole{}, &model.AppUserRole{}, &model.AppPermission{}, &model.AppUserPermission{},
+		&model.TenantRbacRole{}, &model.TenantUserRbacRole{}, &model.Team{}, &model.TeamMember{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func TestResolveClaimMappings(t *testing.T) {
+	db := setupClaimMappingTestDB(t)
+	const tenantID, appID = 1, 7
+
+	user := model.User{TenantID: tenantID, Email: "alice@example.com", Nickname: "Alice", PasswordHash: "x"}
+	db.Create(&user)
+	db.Create(&model.UserProfile{UserID: user.ID, Company: "Basalt"})
+
+	expired := time.Now().Add(-time.Hour)
+	editor := model.AppRole{AppID: appID, TenantID: tenantID, Code: "editor", Name: "Editor"}
+	viewer := model.AppRole{AppID: appID, TenantID: tenantID, Code: "viewer", Name: "Viewer"}
+	db.Create(&editor)
+	db.Create(&viewer)
+	publish := model.AppPermission{AppID: appID, TenantID: tenantID, Code: "doc.publish", Name: "Publish", Category: "doc"}
+	read := model.AppPermission{AppID: appID, TenantID: tenantID, Code: "doc.read", Name: "Read", Category: "doc"}
+	db.Create(&publish)
+	db.Create(&read)
+	db.Model(&editor).Association("Permissions").Append(&publish)
+	db.Create(&model.AppUserRole{UserID: user.ID, AppID: appID, RoleID: editor.ID, AssignedBy: 1})
+	db.Create(&model.AppUserRole{UserID: user.ID, AppID: appID, RoleID: viewer.ID, AssignedBy: 1, ExpiresAt: &expired})
+	db.Create(&model.AppUserPermission{UserID: user.ID, AppID: appID, PermissionID: read.ID, GrantedBy: 1})
+
+	admin := model.TenantRbacRole{TenantID: tenantID, Code: "tenant_admin", Name: "Admin"}
+	db.Create(&admin)
+	db.Create(&model.TenantUserRbacRole{UserID: user.ID, TenantID: tenantID, RoleID: admin.ID, AssignedBy: 1})
+
+	team := model.Team{TenantID: tenantID, Name: "Platform", IsActive: true}
+	db.Create(&team)
+	db.Create(&model.TeamMember{TeamID: team.ID, UserID: user.ID, Role: "member", Status: "active"})
+
+	mappings := []model.AppClaimMapping{
+		{ClaimName: "roles", Source: model.ClaimSourceAppRoles, Scope: "roles"},
+		{ClaimName: "permissions", Source: model.ClaimSourceAppPermissions, Scope: "roles", Targets: "access_token"},
+		{ClaimName: "tenant_roles", Source: model.ClaimSourceTenantRoles},
+		{ClaimName: "groups", Source: model.ClaimSourceTeams, Targets: "userinfo"},
+		{ClaimName: "company", Source: model.ClaimSourceProfile, Value: "company", Targets: "userinfo"},
+		{ClaimName: "job_title", Source: model.ClaimSourceProfile, Value: "job_title"},
+		{ClaimName: "plan", Source: model.ClaimSourceStatic, Value: `{"tier":"pro"}`},
+	}
+	for i := range mappings {
+		mappings[i].AppID, mappings[i].TenantID = appID, tenantID
+		if err := ValidateMapping(&mappings[i]); err != nil {
+			t.Fatalf("mapping %s rejected: %v", mappings[i].ClaimName, err)
+		}
+		db.Create(&mappings[i])
+	}
+	svc := NewService(db)
+
+	// 未授权 roles scope：角色与权限不输出；空的资料字段不输出
+	got, err := svc.Resolve(&Context{TenantID: tenantID, AppID: appID, UserID: user.ID, Scopes: "openid"}, model.ClaimTargetIDToken)
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"tenant_roles": []string{"tenant_admin"},
+		"plan":         map[string]interface{}{"tier": "pro"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected id_token claims: %#v", got)
+	}
+
+	// 授权 roles scope 后按位置输出，过期角色不计入
+	got, _ = svc.Resolve(&Context{TenantID: tenantID, AppID: appID, UserID: user.ID, Scopes: "openid roles"}, model.ClaimTargetAccessToken)
+	if !reflect.DeepEqual(got["roles"], []string{"editor"}) || !reflect.DeepEqual(got["permissions"], []string{"doc.publish", "doc.read"}) {
+		t.Fatalf("unexpected access token claims: %#v", got)
+	}
+	if _, ok := got["groups"]; ok {
+		t.Fatalf("expected userinfo-only claim to be omitted from access token")
+	}
+
+	// claims 参数可把声明请求到 id_token，但不能绕过 scope
+	req, err := ParseRequest(`{"id_token":{"groups":null,"company":{"essential":true},"permissions":null}}`)
+	if err != nil {
+		t.Fatalf("parse claims request failed: %v", err)
+	}
+	got, _ = svc.Resolve(&Context{TenantID: tenantID, AppID: appID, UserID: user.ID, Scopes: "openid", Request: req}, model.ClaimTargetIDToken)
+	if !reflect.DeepEqual(got["groups"], []string{"Platform"}) || got["company"] != "Basalt" {
+		t.Fatalf("expected requested claims in id_token, got %#v", got)
+	}
+	if _, ok := got["permissions"]; ok {
+		t.Fatalf("expected claims request not to bypass the mapping scope")
+	}
+}
+
+func TestValidateMappingAndClaimsRequest(t *testing.T) {
+	invalid := []model.AppClaimMapping{
+		{ClaimName: "sub", Source: model.ClaimSourceStatic, Value: `"x"`},
+		{ClaimName: "bad name", Source: model.ClaimSourceAppRoles},
+		{ClaimName: "x", Source: "ldap"},
+		{ClaimName: "x", Source: model.ClaimSourceProfile, Value: "password_hash"},
+		{ClaimName: "x", Source: model.ClaimSourceStatic, Value: "not json"},
+		{ClaimName: "x", Source: model.ClaimSourceAppRoles, Targets: "id_token,cookie"},
+		{ClaimName: "x", Source: model.ClaimSourceAppRoles, Scope: "a b"},
+	}
+	for _, m := range invalid {
+		if err := ValidateMapping(&m); err == nil {
+			t.Fatalf("expected mapping %+v to be rejected", m)
+		}
+	}
+
+	if _, err := ParseRequest(`["id_token"]`); err == nil {
+		t.Fatalf("expected non-object claims request to be rejected")
+	}
+	req, err := ParseRequest(`{"id_token":{"acr":{"essential":true,"values":["urn:basaltpass:acr:mfa","urn:basaltpass:acr:pwd"]}}}`)
+	if err != nil || req.ACRValues() != "urn:basaltpass:acr:mfa urn:basaltpass:acr:pwd" {
+		t.Fatalf("expected acr values from claims request, got %v %v", req, err)
+	}
+	if empty, err := ParseRequest(""); err != nil || empty.ACRValues() != "" {
+		t.Fatalf("expected empty claims request to be accepted")
+	}
+}
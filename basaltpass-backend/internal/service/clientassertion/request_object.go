@@ -0,0 +1,60 @@
+package clientassertion
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxRequestObjectLifetime 请求对象 exp 距当前时间的上限（RFC 9101 §10.8 建议短时有效）
+const maxRequestObjectLifetime = time.Hour
+
+// ErrInvalidRequestObject 请求对象无法通过校验；具体原因包装在错误信息中，仅用于日志
+var ErrInvalidRequestObject = errors.New("invalid_request_object")
+
+// VerifyRequestObject 使用客户端登记的公钥校验签名请求对象（JAR，RFC 9101）并返回其中的授权参数。
+// 请求对象的 iss 必须为 client_id，aud 必须命中 audiences（授权服务器 issuer），且必须携带 exp。
+func (s *Service) VerifyRequestObject(client *model.OAuthClient, requestObject string, audiences []string) (jwt.MapClaims, error) {
+	// 1. 验签；只接受非对称算法，拒绝 alg=none
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(requestObject, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.verificationKeys(client, token)
+	},
+		jwt.WithValidMethods(SigningAlgorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequestObject, err)
+	}
+
+	// 2. iss 与 client_id 声明必须指向该客户端，aud 指向本授权服务器
+	if iss, _ := claims.GetIssuer(); iss != client.ClientID {
+		return nil, fmt.Errorf("%w: iss must equal client_id", ErrInvalidRequestObject)
+	}
+	if cid, ok := claims["client_id"]; ok && cid != client.ClientID {
+		return nil, fmt.Errorf("%w: client_id mismatch", ErrInvalidRequestObject)
+	}
+	aud, _ := claims.GetAudience()
+	if !audienceMatches(aud, audiences) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidRequestObject)
+	}
+	exp, _ := claims.GetExpirationTime()
+	if exp.Time.After(time.Now().Add(maxRequestObjectLifetime)) {
+		return nil, fmt.Errorf("%w: request object lifetime is too long", ErrInvalidRequestObject)
+	}
+
+	// 3. 请求对象不能再嵌套 request / request_uri
+	if _, ok := claims["request"]; ok {
+		return nil, fmt.Errorf("%w: nested request", ErrInvalidRequestObject)
+	}
+	if _, ok := claims["request_uri"]; ok {
+		return nil, fmt.Errorf("%w: nested request_uri", ErrInvalidRequestObject)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,242 @@
+package clientassertion
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// AssertionType client_assertion_type 的取值（RFC 7523 §2.2）
+const AssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+const (
+	// maxAssertionLifetime 断言 exp 距当前时间的上限，同时限定 jti 记录的保留时长
+	maxAssertionLifetime = 10 * time.Minute
+	clockSkew            = 30 * time.Second
+
+	jwksCacheTTL        = 5 * time.Minute
+	jwksRefreshInterval = 30 * time.Second // 遇到未知 kid 时重新拉取 jwks_uri 的最小间隔
+	maxJWKSBytes        = 64 << 10
+)
+
+// ErrInvalidClient 断言无法认证客户端；具体原因包装在错误信息中，仅用于日志
+var ErrInvalidClient = errors.New("invalid_client")
+
+// SigningAlgorithms 允许的断言签名算法，与 Discovery 中 token_endpoint_auth_signing_alg_values_supported 一致
+var SigningAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// httpClient 拉取客户端登记的 jwks_uri，只允许连接公网地址
+var httpClient = utils.NewPublicHTTPClient(5 * time.Second)
+
+// Fetcher 拉取客户端 jwks_uri 的内容
+type Fetcher func(uri string) ([]byte, error)
+
+type cachedJWKS struct {
+	keys      []PublicKey
+	fetchedAt time.Time
+}
+
+var (
+	jwksCacheMu sync.Mutex
+	jwksCache   = map[string]cachedJWKS{}
+)
+
+// Service 校验 private_key_jwt 客户端提交的 JWT 断言（RFC 7523 §2.2 / §3）
+type Service struct {
+	db    *gorm.DB
+	fetch Fetcher
+}
+
+// NewService 创建客户端断言校验服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, fetch: fetchJWKS}
+}
+
+// WithFetcher 替换 jwks_uri 的拉取方式（测试使用）
+func (s *Service) WithFetcher(fetch Fetcher) *Service {
+	s.fetch = fetch
+	return s
+}
+
+// Audiences 返回断言可接受的 aud：issuer、令牌端点及被调用端点地址。
+// base 为对外基础地址（utils.RequestBaseURL），与 Discovery 及 id_token 中的 iss 同源。
+func Audiences(base, path string) []string {
+	return []string{base + "/api/v1", base + "/api/v1/oauth/token", base + path}
+}
+
+// Verify 校验客户端断言并返回已认证的客户端。
+// audiences 为可接受的 aud 值（授权服务器 issuer 及被调用端点地址），断言命中其一即可。
+func (s *Service) Verify(assertion string, audiences []string) (*model.OAuthClient, error) {
+	// 1. 读取 iss 定位客户端（此时尚未验签）
+	clientID := Issuer(assertion)
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: missing iss", ErrInvalidClient)
+	}
+
+	var client model.OAuthClient
+	if err := s.db.Preload("App").Where("client_id = ? AND is_active = ?", clientID, true).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown client", ErrInvalidClient)
+		}
+		return nil, err
+	}
+
+	// 2. 只有登记为 private_key_jwt 的客户端可以使用断言
+	if client.TokenEndpointAuthMethod != model.TokenEndpointAuthPrivateKeyJWT {
+		return nil, fmt.Errorf("%w: client is not registered for private_key_jwt", ErrInvalidClient)
+	}
+
+	// 3. 使用客户端登记的公钥验签并校验 exp
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(assertion, &claims, func(token *jwt.Token) (interface{}, error) {
+		return s.verificationKeys(&client, token)
+	},
+		jwt.WithValidMethods(SigningAlgorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClient, err)
+	}
+
+	// 4. iss 与 sub 均为 client_id，aud 指向本授权服务器，必须携带 jti（RFC 7523 §3）
+	if claims.Issuer != client.ClientID || claims.Subject != client.ClientID {
+		return nil, fmt.Errorf("%w: iss and sub must equal client_id", ErrInvalidClient)
+	}
+	if !audienceMatches(claims.Audience, audiences) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidClient)
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidClient)
+	}
+	expiresAt := claims.ExpiresAt.Time
+	if expiresAt.After(time.Now().Add(maxAssertionLifetime)) {
+		return nil, fmt.Errorf("%w: assertion lifetime is too long", ErrInvalidClient)
+	}
+
+	// 5. 记录 jti，过期前重复使用视为重放
+	if err := s.recordJTI(client.ClientID, claims.ID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// Issuer 读取断言中未经验证的 iss，即声明的 client_id；无法解析时返回空串
+func Issuer(assertion string) string {
+	token, _, err := jwt.NewParser().ParseUnverified(assertion, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	iss, _ := token.Claims.(jwt.MapClaims)["iss"].(string)
+	return iss
+}
+
+// ClientKeys 返回客户端登记的验签公钥；refresh 为 true 时绕过 jwks_uri 缓存
+func (s *Service) ClientKeys(client *model.OAuthClient, refresh bool) ([]PublicKey, error) {
+	if strings.TrimSpace(client.JWKS) != "" {
+		return ParseJWKS([]byte(client.JWKS))
+	}
+	uri := strings.TrimSpace(client.JWKSURI)
+	if uri == "" {
+		return nil, errors.New("client has no registered keys")
+	}
+
+	jwksCacheMu.Lock()
+	cached, ok := jwksCache[uri]
+	jwksCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < jwksCacheTTL && (!refresh || time.Since(cached.fetchedAt) < jwksRefreshInterval) {
+		return cached.keys, nil
+	}
+
+	data, err := s.fetch(uri)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	jwksCacheMu.Lock()
+	jwksCache[uri] = cachedJWKS{keys: keys, fetchedAt: time.Now()}
+	jwksCacheMu.Unlock()
+	return keys, nil
+}
+
+// verificationKeys 按断言头中的 kid 与 alg 选出候选公钥；未知 kid 时刷新一次 jwks_uri
+func (s *Service) verificationKeys(client *model.OAuthClient, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	alg := token.Method.Alg()
+
+	for _, refresh := range []bool{false, true} {
+		keys, err := s.ClientKeys(client, refresh)
+		if err != nil {
+			return nil, err
+		}
+		set := jwt.VerificationKeySet{}
+		for _, k := range keys {
+			if (kid == "" || k.KID == kid) && (k.Alg == "" || k.Alg == alg) {
+				set.Keys = append(set.Keys, k.Key)
+			}
+		}
+		if len(set.Keys) > 0 {
+			return set, nil
+		}
+		if kid == "" || client.JWKSURI == "" || client.JWKS != "" {
+			break
+		}
+	}
+	return nil, errors.New("no matching key")
+}
+
+func (s *Service) recordJTI(clientID, jti string, expiresAt time.Time) error {
+	s.db.Where("expires_at < ?", time.Now()).Delete(&model.OAuthClientAssertionJTI{})
+
+	var count int64
+	if err := s.db.Model(&model.OAuthClientAssertionJTI{}).Where("client_id = ? AND jti = ?", clientID, jti).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: assertion jti has already been used", ErrInvalidClient)
+	}
+	// 并发提交相同 jti 时由唯一索引兜底
+	if err := s.db.Create(&model.OAuthClientAssertionJTI{ClientID: clientID, JTI: jti, ExpiresAt: expiresAt}).Error; err != nil {
+		return fmt.Errorf("%w: assertion jti has already been used", ErrInvalidClient)
+	}
+	return nil
+}
+
+func audienceMatches(got jwt.ClaimStrings, accepted []string) bool {
+	for _, aud := range got {
+		aud = strings.TrimRight(aud, "/")
+		for _, want := range accepted {
+			if want != "" && aud == strings.TrimRight(want, "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fetchJWKS(uri string) ([]byte, error) {
+	resp, err := httpClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks_uri returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+}
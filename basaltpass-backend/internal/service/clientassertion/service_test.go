@@ -0,0 +1,221 @@
+package clientassertion
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const testAudience = "https://id.example.com/api/v1/oauth/token"
+
+func setupClientAssertionTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(&model.App{}, &model.OAuthClient{}, &model.OAuthClientAssertionJTI{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func generateTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	return key
+}
+
+func testJWKS(t *testing.T, kid string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	doc, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"crv": "P-256",
+			"kid": kid,
+			"use": "sig",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("marshal jwks failed: %v", err)
+	}
+	return string(doc)
+}
+
+func signTestAssertion(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign assertion failed: %v", err)
+	}
+	return signed
+}
+
+func assertionClaims(clientID, jti string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": testAudience,
+		"jti": jti,
+		"exp": time.Now().Add(2 * time.Minute).Unix(),
+		"iat": time.Now().Unix(),
+	}
+}
+
+func createTestClient(t *testing.T, db *gorm.DB, client *model.OAuthClient) {
+	t.Helper()
+	client.TokenEndpointAuthMethod = model.TokenEndpointAuthPrivateKeyJWT
+	client.RedirectURIs = "https://rp.example.com/callback"
+	client.IsActive = true
+	client.CreatedBy = 1
+	if err := db.Create(client).Error; err != nil {
+		t.Fatalf("create client failed: %v", err)
+	}
+}
+
+func TestVerifyAcceptsSignedAssertionOnce(t *testing.T) {
+	db := setupClientAssertionTestDB(t)
+	key := generateTestKey(t)
+	createTestClient(t, db, &model.OAuthClient{ClientID: "pkjwt-inline", JWKS: testJWKS(t, "k1", key)})
+	s := NewService(db)
+
+	assertion := signTestAssertion(t, key, "k1", assertionClaims("pkjwt-inline", "jti-1"))
+	client, err := s.Verify(assertion, []string{"https://id.example.com/api/v1", testAudience})
+	if err != nil {
+		t.Fatalf("expected assertion to verify: %v", err)
+	}
+	if client.ClientID != "pkjwt-inline" {
+		t.Fatalf("unexpected client %q", client.ClientID)
+	}
+
+	if _, err := s.Verify(assertion, []string{testAudience}); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("expected replayed jti to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyRejectsInvalidAssertions(t *testing.T) {
+	db := setupClientAssertionTestDB(t)
+	key := generateTestKey(t)
+	createTestClient(t, db, &model.OAuthClient{ClientID: "pkjwt-invalid", JWKS: testJWKS(t, "k1", key)})
+	s := NewService(db)
+
+	cases := map[string]string{}
+
+	claims := assertionClaims("pkjwt-invalid", "jti-aud")
+	claims["aud"] = "https://other.example.com/token"
+	cases["wrong audience"] = signTestAssertion(t, key, "k1", claims)
+
+	claims = assertionClaims("pkjwt-invalid", "jti-exp")
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	cases["expired"] = signTestAssertion(t, key, "k1", claims)
+
+	claims = assertionClaims("pkjwt-invalid", "jti-long")
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	cases["lifetime too long"] = signTestAssertion(t, key, "k1", claims)
+
+	claims = assertionClaims("pkjwt-invalid", "")
+	delete(claims, "jti")
+	cases["missing jti"] = signTestAssertion(t, key, "k1", claims)
+
+	claims = assertionClaims("pkjwt-invalid", "jti-sub")
+	claims["sub"] = "someone-else"
+	cases["sub mismatch"] = signTestAssertion(t, key, "k1", claims)
+
+	cases["foreign key"] = signTestAssertion(t, generateTestKey(t), "k1", assertionClaims("pkjwt-invalid", "jti-key"))
+
+	for name, assertion := range cases {
+		if _, err := s.Verify(assertion, []string{testAudience}); !errors.Is(err, ErrInvalidClient) {
+			t.Fatalf("%s: expected invalid_client, got %v", name, err)
+		}
+	}
+}
+
+func TestVerifyRefreshesJWKSURIForUnknownKid(t *testing.T) {
+	db := setupClientAssertionTestDB(t)
+	oldKey, newKey := generateTestKey(t), generateTestKey(t)
+	createTestClient(t, db, &model.OAuthClient{ClientID: "pkjwt-uri", JWKSURI: "https://rp.example.com/jwks-" + t.Name()})
+
+	published := testJWKS(t, "old", oldKey)
+	fetches := 0
+	s := NewService(db).WithFetcher(func(uri string) ([]byte, error) {
+		fetches++
+		return []byte(published), nil
+	})
+
+	if _, err := s.Verify(signTestAssertion(t, oldKey, "old", assertionClaims("pkjwt-uri", "jti-old")), []string{testAudience}); err != nil {
+		t.Fatalf("expected assertion signed by published key to verify: %v", err)
+	}
+
+	// 客户端轮换密钥后，未知 kid 触发一次重新拉取（受最小刷新间隔限制）
+	published = testJWKS(t, "new", newKey)
+	jwksCacheMu.Lock()
+	cached := jwksCache["https://rp.example.com/jwks-"+t.Name()]
+	cached.fetchedAt = time.Now().Add(-jwksRefreshInterval)
+	jwksCache["https://rp.example.com/jwks-"+t.Name()] = cached
+	jwksCacheMu.Unlock()
+
+	if _, err := s.Verify(signTestAssertion(t, newKey, "new", assertionClaims("pkjwt-uri", "jti-new")), []string{testAudience}); err != nil {
+		t.Fatalf("expected rotated key to verify after refresh: %v", err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected jwks_uri to be fetched twice, got %d", fetches)
+	}
+}
+
+func TestVerifyRequiresPrivateKeyJWTClient(t *testing.T) {
+	db := setupClientAssertionTestDB(t)
+	key := generateTestKey(t)
+	client := &model.OAuthClient{ClientID: "pkjwt-secret", JWKS: testJWKS(t, "k1", key)}
+	createTestClient(t, db, client)
+	db.Model(client).Update("token_endpoint_auth_method", model.TokenEndpointAuthClientSecretBasic)
+
+	if _, err := NewService(db).Verify(signTestAssertion(t, key, "k1", assertionClaims("pkjwt-secret", "jti-1")), []string{testAudience}); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("expected secret client to be rejected, got %v", err)
+	}
+}
+
+func TestParseJWKSSkipsUnsupportedKeys(t *testing.T) {
+	key := generateTestKey(t)
+	var set map[string][]map[string]string
+	if err := json.Unmarshal([]byte(testJWKS(t, "ec-key", key)), &set); err != nil {
+		t.Fatalf("unmarshal jwks failed: %v", err)
+	}
+	set["keys"] = append([]map[string]string{
+		{"kty": "OKP", "crv": "Ed25519", "kid": "ed-key", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		{"kty": "oct", "kid": "hmac-key", "k": "c2VjcmV0"},
+	}, set["keys"]...)
+	doc, _ := json.Marshal(set)
+
+	keys, err := ParseJWKS(doc)
+	if err != nil {
+		t.Fatalf("expected unsupported keys to be skipped, got %v", err)
+	}
+	if len(keys) != 1 || keys[0].KID != "ec-key" {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+
+	// 受支持类型的密钥损坏时仍拒绝整个集合
+	set["keys"][2]["x"] = "!"
+	doc, _ = json.Marshal(set)
+	if _, err := ParseJWKS(doc); err == nil {
+		t.Fatalf("expected malformed EC key to be rejected")
+	}
+}
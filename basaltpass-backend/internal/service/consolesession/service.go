@@ -0,0 +1,261 @@
+package consolesession
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshGracePeriod 刷新令牌轮换后的宽限期：同一浏览器多个标签页并发刷新时，
+// 旧令牌在宽限期内被再次使用只拒绝本次请求，不视为令牌被盗用。
+const RefreshGracePeriod = 30 * time.Second
+
+// lastSeenInterval 访问令牌校验时更新 last_seen_at 的最小间隔，避免每个请求都写库
+const lastSeenInterval = time.Minute
+
+var (
+	// ErrSessionInactive 会话不存在、已撤销或已过期
+	ErrSessionInactive = errors.New("session revoked or expired")
+	// ErrRefreshRaced 刷新令牌刚被轮换（宽限期内的并发刷新）
+	ErrRefreshRaced = errors.New("refresh token already rotated")
+	// ErrRefreshReused 已轮换的刷新令牌被重放，会话已被撤销
+	ErrRefreshReused = errors.New("refresh token reused")
+)
+
+// Service 控制台登录会话登记：创建、轮换、校验与撤销
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建会话服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// NewID 生成会话ID或令牌 jti
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// CreateParams 创建会话所需的信息
+type CreateParams struct {
+	UserID     uint
+	TenantID   uint
+	Scope      string
+	AuthTime   time.Time
+	RefreshJTI string
+	ExpiresAt  time.Time
+}
+
+// Create 登记一次新的登录会话
+func (s *Service) Create(p CreateParams) (*model.ConsoleSession, error) {
+	now := time.Now()
+	authTime := p.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
+	session := &model.ConsoleSession{
+		SessionID:  NewID(),
+		UserID:     p.UserID,
+		TenantID:   p.TenantID,
+		Scope:      p.Scope,
+		RefreshJTI: p.RefreshJTI,
+		AuthTime:   authTime,
+		LastSeenAt: now,
+		ExpiresAt:  p.ExpiresAt,
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Rotate 为会话签发新的刷新令牌 jti。
+// presentedJTI 为客户端出示的刷新令牌 jti；为空表示由已校验的访问令牌发起（如切换租户身份），不比对 jti。
+// 出示的 jti 不是当前值时：宽限期内的上一个 jti 返回 ErrRefreshRaced，其余情况视为重放，撤销整个会话。
+func (s *Service) Rotate(sessionID, presentedJTI, newJTI string, tenantID uint, expiresAt time.Time) error {
+	var reused bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session model.ConsoleSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("session_id = ?", sessionID).
+			First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSessionInactive
+			}
+			return err
+		}
+
+		now := time.Now()
+		if !session.IsActive(now) {
+			return ErrSessionInactive
+		}
+		if presentedJTI != "" && presentedJTI != session.RefreshJTI {
+			if presentedJTI == session.PreviousRefreshJTI && session.RotatedAt != nil && now.Sub(*session.RotatedAt) < RefreshGracePeriod {
+				return ErrRefreshRaced
+			}
+			reused = true
+			return tx.Model(&session).Updates(map[string]interface{}{
+				"revoked_at":     now,
+				"revoked_reason": model.ConsoleSessionRevokedRefreshReuse,
+			}).Error
+		}
+
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_jti":          newJTI,
+			"previous_refresh_jti": session.RefreshJTI,
+			"rotated_at":           now,
+			"tenant_id":            tenantID,
+			"last_seen_at":         now,
+			"expires_at":           expiresAt,
+		}).Error
+	})
+	if err == nil && reused {
+		return ErrRefreshReused
+	}
+	return err
+}
+
+// RecordClient 记录会话所在的客户端信息（IP、User-Agent 及据此推断的设备描述）
+func (s *Service) RecordClient(sessionID, ip, userAgent string) error {
+	if sessionID == "" {
+		return nil
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return s.db.Model(&model.ConsoleSession{}).
+		Where("session_id = ?", sessionID).
+		Updates(map[string]interface{}{
+			"ip_address": ip,
+			"user_agent": userAgent,
+			"device":     DescribeDevice(userAgent),
+		}).Error
+}
+
+// Validate 校验访问令牌所属会话仍然有效，并按间隔刷新 last_seen_at
+func (s *Service) Validate(sessionID string) error {
+	var session model.ConsoleSession
+	if err := s.db.Select("id", "revoked_at", "expires_at", "last_seen_at").
+		Where("session_id = ?", sessionID).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionInactive
+		}
+		return err
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		return ErrSessionInactive
+	}
+	if now.Sub(session.LastSeenAt) >= lastSeenInterval {
+		s.db.Model(&session).UpdateColumn("last_seen_at", now)
+	}
+	return nil
+}
+
+// ListActive 列出用户未撤销且未过期的会话，最近活跃的在前
+func (s *Service) ListActive(userID uint) ([]model.ConsoleSession, error) {
+	var sessions []model.ConsoleSession
+	err := s.activeQuery(userID).Order("last_seen_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListActiveInTenant 列出用户在指定租户身份下的有效会话（租户管理员只能看到本租户的会话）
+func (s *Service) ListActiveInTenant(userID, tenantID uint) ([]model.ConsoleSession, error) {
+	var sessions []model.ConsoleSession
+	err := s.activeQuery(userID).Where("tenant_id = ?", tenantID).Order("last_seen_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke 撤销用户的指定会话；会话不存在或已失效时返回 ErrSessionInactive
+func (s *Service) Revoke(userID uint, sessionID, reason string) error {
+	result := s.activeQuery(userID).
+		Where("session_id = ?", sessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionInactive
+	}
+	return nil
+}
+
+// RevokeAll 撤销用户的全部有效会话，exceptSessionID 非空时保留该会话；返回撤销的数量
+func (s *Service) RevokeAll(userID uint, exceptSessionID, reason string) (int64, error) {
+	query := s.activeQuery(userID)
+	if exceptSessionID != "" {
+		query = query.Where("session_id <> ?", exceptSessionID)
+	}
+	result := query.Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeAllInTenant 撤销用户在指定租户身份下的全部有效会话；返回撤销的数量
+func (s *Service) RevokeAllInTenant(userID, tenantID uint, reason string) (int64, error) {
+	result := s.activeQuery(userID).
+		Where("tenant_id = ?", tenantID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+func (s *Service) activeQuery(userID uint) *gorm.DB {
+	return s.db.Model(&model.ConsoleSession{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now())
+}
+
+// DescribeDevice 从 User-Agent 推断简短的设备描述，例如 "Chrome on macOS"
+func DescribeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return ""
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os x") || strings.Contains(ua, "macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
@@ -0,0 +1,98 @@
+package consolesession
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupConsoleSessionTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(&model.ConsoleSession{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func TestRevokeSessions(t *testing.T) {
+	db := setupConsoleSessionTestDB(t)
+	svc := NewService(db)
+	expires := time.Now().Add(time.Hour)
+
+	create := func(userID, tenantID uint) string {
+		session, err := svc.Create(CreateParams{UserID: userID, TenantID: tenantID, Scope: "user", RefreshJTI: NewID(), ExpiresAt: expires})
+		if err != nil {
+			t.Fatalf("create session failed: %v", err)
+		}
+		return session.SessionID
+	}
+	current, other, tenantSession, otherUser := create(1, 0), create(1, 0), create(1, 9), create(2, 0)
+
+	if err := svc.RecordClient(current, "203.0.113.7", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15"); err != nil {
+		t.Fatalf("record client failed: %v", err)
+	}
+	sessions, err := svc.ListActive(1)
+	if err != nil || len(sessions) != 3 {
+		t.Fatalf("expected 3 active sessions, got %d %v", len(sessions), err)
+	}
+	for _, s := range sessions {
+		if s.SessionID == current && (s.Device != "Safari on macOS" || s.IPAddress != "203.0.113.7") {
+			t.Fatalf("unexpected client info: %+v", s)
+		}
+	}
+
+	// 只能撤销自己的会话
+	if err := svc.Revoke(1, otherUser, model.ConsoleSessionRevokedUser); !errors.Is(err, ErrSessionInactive) {
+		t.Fatalf("expected other user's session to be untouched, got %v", err)
+	}
+	if err := svc.Revoke(1, other, model.ConsoleSessionRevokedUser); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if err := svc.Validate(other); !errors.Is(err, ErrSessionInactive) {
+		t.Fatalf("expected revoked session to fail validation, got %v", err)
+	}
+
+	// 租户范围的撤销不影响其它身份下的会话
+	if n, err := svc.RevokeAllInTenant(1, 9, model.ConsoleSessionRevokedAdmin); err != nil || n != 1 {
+		t.Fatalf("expected 1 tenant session revoked, got %d %v", n, err)
+	}
+
+	// 退出其它设备时保留当前会话
+	create(1, 0)
+	if n, err := svc.RevokeAll(1, current, model.ConsoleSessionRevokedUser); err != nil || n != 1 {
+		t.Fatalf("expected 1 session revoked, got %d %v", n, err)
+	}
+	if err := svc.Validate(current); err != nil {
+		t.Fatalf("expected current session to stay active, got %v", err)
+	}
+	if err := svc.Validate(tenantSession); !errors.Is(err, ErrSessionInactive) {
+		t.Fatalf("expected tenant session to be revoked")
+	}
+	if err := svc.Validate(otherUser); err != nil {
+		t.Fatalf("expected other user's session to stay active, got %v", err)
+	}
+}
+
+func TestDescribeDevice(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/126.0 Safari/537.36 Edg/126.0":            "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 Chrome/126.0 Mobile Safari/537.36":                         "Chrome on Android",
+		"curl/8.5.0": "curl",
+		"":           "",
+	}
+	for ua, want := range cases {
+		if got := DescribeDevice(ua); got != want {
+			t.Fatalf("DescribeDevice(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package federation
+
+import (
+	"strings"
+
+	"basaltpass-backend/internal/model"
+)
+
+// Preset 常见身份提供方的预置配置，创建提供方时填充未指定的字段
+type Preset struct {
+	Type                  string
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserinfoEndpoint      string
+	Scopes                string
+	SubjectField          string
+	EmailField            string
+	NameField             string
+}
+
+// 预置名称
+const (
+	PresetGoogle    = "google"
+	PresetGitHub    = "github"
+	PresetMicrosoft = "microsoft"
+)
+
+var presets = map[string]Preset{
+	PresetGoogle: {
+		Type:   model.IdentityProviderTypeOIDC,
+		Issuer: "https://accounts.google.com",
+		Scopes: "openid email profile",
+	},
+	// Microsoft 默认使用多租户端点 common，实际签发者为 https://login.microsoftonline.com/{tid}/v2.0
+	PresetMicrosoft: {
+		Type:   model.IdentityProviderTypeOIDC,
+		Issuer: "https://login.microsoftonline.com/common/v2.0",
+		Scopes: "openid email profile",
+	},
+	PresetGitHub: {
+		Type:                  model.IdentityProviderTypeOAuth2,
+		AuthorizationEndpoint: "https://github.com/login/oauth/authorize",
+		TokenEndpoint:         "https://github.com/login/oauth/access_token",
+		UserinfoEndpoint:      "https://api.github.com/user",
+		Scopes:                "read:user user:email",
+		SubjectField:          "id",
+		EmailField:            "email",
+		NameField:             "name",
+	},
+}
+
+// PresetNames 返回可用的预置名称
+func PresetNames() []string {
+	return []string{PresetGoogle, PresetGitHub, PresetMicrosoft}
+}
+
+// applyPreset 用预置值补全提供方未填写的字段；未知预置返回 false
+func applyPreset(p *model.IdentityProvider) bool {
+	name := strings.ToLower(strings.TrimSpace(p.Preset))
+	if name == "" {
+		return true
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return false
+	}
+	p.Preset = name
+	p.Type = preset.Type
+	fill := func(dst *string, v string) {
+		if strings.TrimSpace(*dst) == "" {
+			*dst = v
+		}
+	}
+	fill(&p.Issuer, preset.Issuer)
+	fill(&p.AuthorizationEndpoint, preset.AuthorizationEndpoint)
+	fill(&p.TokenEndpoint, preset.TokenEndpoint)
+	fill(&p.UserinfoEndpoint, preset.UserinfoEndpoint)
+	fill(&p.Scopes, preset.Scopes)
+	fill(&p.SubjectField, preset.SubjectField)
+	fill(&p.EmailField, preset.EmailField)
+	fill(&p.NameField, preset.NameField)
+	return true
+}
@@ -0,0 +1,294 @@
+package federation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// ProviderInput 创建提供方的参数；指定 Preset 时未填写的端点、scope 与字段映射取预置值
+type ProviderInput struct {
+	Slug                  string `json:"slug"`
+	Name                  string `json:"name"`
+	Type                  string `json:"type"`
+	Preset                string `json:"preset"`
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	ClientID              string `json:"client_id"`
+	ClientSecret          string `json:"client_secret"`
+	Scopes                string `json:"scopes"`
+	SubjectField          string `json:"subject_field"`
+	EmailField            string `json:"email_field"`
+	NameField             string `json:"name_field"`
+	AllowProvisioning     *bool  `json:"allow_provisioning"`
+	Enabled               *bool  `json:"enabled"`
+}
+
+// ProviderUpdate 更新提供方的参数，仅修改非 nil 字段；ClientSecret 为空串表示清除密钥
+type ProviderUpdate struct {
+	Name                  *string `json:"name"`
+	Issuer                *string `json:"issuer"`
+	AuthorizationEndpoint *string `json:"authorization_endpoint"`
+	TokenEndpoint         *string `json:"token_endpoint"`
+	UserinfoEndpoint      *string `json:"userinfo_endpoint"`
+	JWKSURI               *string `json:"jwks_uri"`
+	ClientID              *string `json:"client_id"`
+	ClientSecret          *string `json:"client_secret"`
+	Scopes                *string `json:"scopes"`
+	SubjectField          *string `json:"subject_field"`
+	EmailField            *string `json:"email_field"`
+	NameField             *string `json:"name_field"`
+	AllowProvisioning     *bool   `json:"allow_provisioning"`
+	Enabled               *bool   `json:"enabled"`
+}
+
+// ListProviders 列出租户配置的全部提供方
+func (s *Service) ListProviders(tenantID uint) ([]model.IdentityProvider, error) {
+	var providers []model.IdentityProvider
+	err := s.db.Where("tenant_id = ?", tenantID).Order("id ASC").Find(&providers).Error
+	return providers, err
+}
+
+// ListEnabledProviders 列出租户已启用的提供方（登录页与绑定页展示）
+func (s *Service) ListEnabledProviders(tenantID uint) ([]model.IdentityProvider, error) {
+	var providers []model.IdentityProvider
+	err := s.db.Where("tenant_id = ? AND enabled = ?", tenantID, true).Order("id ASC").Find(&providers).Error
+	return providers, err
+}
+
+// GetProvider 获取租户下的提供方
+func (s *Service) GetProvider(tenantID, id uint) (*model.IdentityProvider, error) {
+	var provider model.IdentityProvider
+	if err := s.db.Where("id = ? AND tenant_id = ?", id, tenantID).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProviderNotFound
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// CreateProvider 为租户创建提供方
+func (s *Service) CreateProvider(tenantID uint, in ProviderInput) (*model.IdentityProvider, error) {
+	provider := &model.IdentityProvider{
+		TenantID:              tenantID,
+		Slug:                  strings.ToLower(strings.TrimSpace(in.Slug)),
+		Name:                  strings.TrimSpace(in.Name),
+		Type:                  strings.ToLower(strings.TrimSpace(in.Type)),
+		Preset:                in.Preset,
+		Issuer:                strings.TrimSpace(in.Issuer),
+		AuthorizationEndpoint: strings.TrimSpace(in.AuthorizationEndpoint),
+		TokenEndpoint:         strings.TrimSpace(in.TokenEndpoint),
+		UserinfoEndpoint:      strings.TrimSpace(in.UserinfoEndpoint),
+		JWKSURI:               strings.TrimSpace(in.JWKSURI),
+		ClientID:              strings.TrimSpace(in.ClientID),
+		Scopes:                strings.TrimSpace(in.Scopes),
+		SubjectField:          strings.TrimSpace(in.SubjectField),
+		EmailField:            strings.TrimSpace(in.EmailField),
+		NameField:             strings.TrimSpace(in.NameField),
+		AllowProvisioning:     true,
+		Enabled:               true,
+	}
+	if in.AllowProvisioning != nil {
+		provider.AllowProvisioning = *in.AllowProvisioning
+	}
+	if in.Enabled != nil {
+		provider.Enabled = *in.Enabled
+	}
+	if !applyPreset(provider) {
+		return nil, fmt.Errorf("%w: unknown preset %q", ErrInvalidProvider, in.Preset)
+	}
+	if provider.Slug == "" {
+		provider.Slug = provider.Preset
+	}
+	if err := validateProvider(provider); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.IdentityProvider{}).Where("tenant_id = ? AND slug = ?", tenantID, provider.Slug).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: slug %q is already in use", ErrInvalidProvider, provider.Slug)
+	}
+
+	secret, err := utils.EncryptIdentityProviderSecret(in.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	provider.ClientSecretEnc = secret
+
+	// 带默认值的布尔字段为 false 时 Create 会写入列默认值，需在创建后单独更新
+	allowProvisioning, enabled := provider.AllowProvisioning, provider.Enabled
+	if err := s.db.Create(provider).Error; err != nil {
+		return nil, err
+	}
+	if !allowProvisioning || !enabled {
+		if err := s.db.Model(provider).Updates(map[string]interface{}{
+			"allow_provisioning": allowProvisioning,
+			"enabled":            enabled,
+		}).Error; err != nil {
+			return nil, err
+		}
+		provider.AllowProvisioning, provider.Enabled = allowProvisioning, enabled
+	}
+	return provider, nil
+}
+
+// UpdateProvider 更新租户下的提供方
+func (s *Service) UpdateProvider(tenantID, id uint, in ProviderUpdate) (*model.IdentityProvider, error) {
+	provider, err := s.GetProvider(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	set := func(dst *string, v *string) {
+		if v != nil {
+			*dst = strings.TrimSpace(*v)
+		}
+	}
+	set(&provider.Name, in.Name)
+	set(&provider.Issuer, in.Issuer)
+	set(&provider.AuthorizationEndpoint, in.AuthorizationEndpoint)
+	set(&provider.TokenEndpoint, in.TokenEndpoint)
+	set(&provider.UserinfoEndpoint, in.UserinfoEndpoint)
+	set(&provider.JWKSURI, in.JWKSURI)
+	set(&provider.ClientID, in.ClientID)
+	set(&provider.Scopes, in.Scopes)
+	set(&provider.SubjectField, in.SubjectField)
+	set(&provider.EmailField, in.EmailField)
+	set(&provider.NameField, in.NameField)
+	if in.AllowProvisioning != nil {
+		provider.AllowProvisioning = *in.AllowProvisioning
+	}
+	if in.Enabled != nil {
+		provider.Enabled = *in.Enabled
+	}
+	if err := validateProvider(provider); err != nil {
+		return nil, err
+	}
+	if in.ClientSecret != nil {
+		secret, err := utils.EncryptIdentityProviderSecret(*in.ClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		provider.ClientSecretEnc = secret
+	}
+
+	if err := s.db.Save(provider).Error; err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// DeleteProvider 删除租户下的提供方及其全部绑定关系
+func (s *Service) DeleteProvider(tenantID, id uint) error {
+	provider, err := s.GetProvider(tenantID, id)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("provider_id = ?", provider.ID).Delete(&model.UserIdentityLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("provider_id = ?", provider.ID).Delete(&model.FederatedAuthState{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(provider).Error
+	})
+}
+
+// ListLinks 列出用户绑定的外部身份
+func (s *Service) ListLinks(userID uint) ([]model.UserIdentityLink, error) {
+	var links []model.UserIdentityLink
+	err := s.db.Preload("Provider").Where("user_id = ?", userID).Order("id ASC").Find(&links).Error
+	return links, err
+}
+
+// Unlink 解除用户的外部身份绑定；账号没有密码、Passkey 或其它绑定时拒绝，避免无法再登录
+func (s *Service) Unlink(userID, linkID uint) (*model.UserIdentityLink, error) {
+	var link model.UserIdentityLink
+	if err := s.db.Where("id = ? AND user_id = ?", linkID, userID).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLinkNotFound
+		}
+		return nil, err
+	}
+
+	var user model.User
+	if err := s.db.Select("id", "password_hash").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" {
+		var others, passkeys int64
+		if err := s.db.Model(&model.UserIdentityLink{}).Where("user_id = ? AND id <> ?", userID, link.ID).Count(&others).Error; err != nil {
+			return nil, err
+		}
+		if err := s.db.Model(&model.Passkey{}).Where("user_id = ?", userID).Count(&passkeys).Error; err != nil {
+			return nil, err
+		}
+		if others == 0 && passkeys == 0 {
+			return nil, ErrLastLoginMethod
+		}
+	}
+
+	if err := s.db.Delete(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func validateProvider(p *model.IdentityProvider) error {
+	if !slugPattern.MatchString(p.Slug) {
+		return fmt.Errorf("%w: slug must be lowercase letters, digits or dashes", ErrInvalidProvider)
+	}
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProvider)
+	}
+	if p.ClientID == "" {
+		return fmt.Errorf("%w: client_id is required", ErrInvalidProvider)
+	}
+
+	switch p.Type {
+	case model.IdentityProviderTypeOIDC:
+		if p.Issuer == "" {
+			return fmt.Errorf("%w: issuer is required for oidc providers", ErrInvalidProvider)
+		}
+		if p.Scopes == "" {
+			p.Scopes = "openid email profile"
+		}
+	case model.IdentityProviderTypeOAuth2:
+		if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.UserinfoEndpoint == "" {
+			return fmt.Errorf("%w: oauth2 providers require authorization, token and userinfo endpoints", ErrInvalidProvider)
+		}
+		if p.SubjectField == "" {
+			return fmt.Errorf("%w: subject_field is required for oauth2 providers", ErrInvalidProvider)
+		}
+	default:
+		return fmt.Errorf("%w: type must be oidc or oauth2", ErrInvalidProvider)
+	}
+
+	for _, raw := range []string{p.Issuer, p.AuthorizationEndpoint, p.TokenEndpoint, p.UserinfoEndpoint, p.JWKSURI} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: %q is not an absolute http(s) url", ErrInvalidProvider, raw)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,452 @@
+package federation
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	settingssvc "basaltpass-backend/internal/service/settings"
+	tenantservice "basaltpass-backend/internal/service/tenant"
+	"basaltpass-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// CallbackPath 上游身份提供方回调地址（所有提供方共用，通过 state 区分）
+const CallbackPath = "/api/v1/auth/federation/callback"
+
+// stateTTL 跳转上游授权到回调之间允许的最长时间
+const stateTTL = 10 * time.Minute
+
+// BindingCookieName 保存浏览器绑定值（Authorization.Binding）的 Cookie，仅发送到回调地址
+const BindingCookieName = "federation_binding"
+
+// BindingCookieMaxAge 浏览器绑定 Cookie 的有效期（秒），与 state 一致
+const BindingCookieMaxAge = int(stateTTL / time.Second)
+
+var (
+	ErrProviderNotFound        = errors.New("identity provider not found")
+	ErrInvalidProvider         = errors.New("invalid identity provider configuration")
+	ErrInvalidState            = errors.New("invalid or expired federation state")
+	ErrUpstream                = errors.New("upstream identity provider error")
+	ErrTenantLoginDisabled     = errors.New("tenant login is disabled")
+	ErrRegistrationDisabled    = errors.New("registration is disabled for this tenant")
+	ErrEmailRequired           = errors.New("identity provider did not return an email address")
+	ErrEmailNotVerified        = errors.New("identity provider did not confirm the email address is verified")
+	ErrLinkRequired            = errors.New("an account with this email already exists; sign in and link the identity provider first")
+	ErrIdentityLinkedElsewhere = errors.New("external account is already linked to another user")
+	ErrProviderAlreadyLinked   = errors.New("identity provider is already linked to this account")
+	ErrUserBanned              = errors.New("account is banned")
+	ErrLinkNotFound            = errors.New("identity link not found")
+	ErrLastLoginMethod         = errors.New("cannot unlink the only sign-in method of this account")
+)
+
+// Service 联合登录：跳转上游授权、处理回调、按外部身份登录、绑定或即时创建账号
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建联合登录服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// AuthorizeParams 发起上游授权所需的参数
+type AuthorizeParams struct {
+	Intent      string // model.FederatedIntentLogin / model.FederatedIntentLink
+	UserID      uint   // 绑定意图下为当前登录用户
+	RedirectURI string // 本服务的回调地址（CallbackPath 的完整 URL）
+	ReturnTo    string // 完成后返回的前端路径
+}
+
+// Authorization 跳转上游授权的地址与浏览器绑定值。
+// Binding 需写入发起授权的浏览器（HttpOnly Cookie），回调时原样回传给 Complete，
+// 防止回调被重放到其它浏览器（登录 CSRF / 绑定 CSRF）。
+type Authorization struct {
+	URL     string
+	Binding string
+}
+
+// Result 回调处理结果。出错时也会尽量填充 Provider / Intent / ReturnTo，便于调用方决定错误页的跳转位置
+type Result struct {
+	Intent      string
+	Provider    *model.IdentityProvider
+	ReturnTo    string
+	User        *model.User
+	Identity    ExternalIdentity
+	Provisioned bool
+}
+
+// EnabledProvider 返回租户下已启用的提供方
+func (s *Service) EnabledProvider(providerID uint) (*model.IdentityProvider, error) {
+	var provider model.IdentityProvider
+	if err := s.db.Where("id = ? AND enabled = ?", providerID, true).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProviderNotFound
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// AuthorizationURL 生成跳转上游授权端点的地址，并保存一次性的 state / nonce / PKCE code_verifier
+func (s *Service) AuthorizationURL(provider *model.IdentityProvider, p AuthorizeParams) (*Authorization, error) {
+	// 1. 解析上游端点
+	ep, err := resolveEndpoints(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 生成并保存一次性状态
+	state := &model.FederatedAuthState{
+		State:        randomToken(32),
+		ProviderID:   provider.ID,
+		Intent:       p.Intent,
+		UserID:       p.UserID,
+		CodeVerifier: randomToken(48),
+		RedirectURI:  p.RedirectURI,
+		ReturnTo:     p.ReturnTo,
+		ExpiresAt:    time.Now().Add(stateTTL),
+	}
+	if provider.Type == model.IdentityProviderTypeOIDC {
+		state.Nonce = randomToken(32)
+	}
+	s.db.Where("expires_at < ?", time.Now()).Delete(&model.FederatedAuthState{})
+	if err := s.db.Create(state).Error; err != nil {
+		return nil, err
+	}
+
+	// 3. 组装授权请求
+	challenge := sha256.Sum256([]byte(state.CodeVerifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", provider.ClientID)
+	q.Set("redirect_uri", p.RedirectURI)
+	q.Set("scope", provider.Scopes)
+	q.Set("state", state.State)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	if state.Nonce != "" {
+		q.Set("nonce", state.Nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(ep.Authorization, "?") {
+		sep = "&"
+	}
+	return &Authorization{
+		URL:     ep.Authorization + sep + q.Encode(),
+		Binding: stateBinding(state),
+	}, nil
+}
+
+// Complete 处理上游回调：校验 state 及其浏览器绑定，换取并校验上游身份，然后按意图登录或绑定
+func (s *Service) Complete(stateValue, code, binding string) (*Result, error) {
+	// 1. 取回并作废一次性状态；必须由发起授权的浏览器回调
+	state, err := s.consumeState(stateValue)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(binding), []byte(stateBinding(state))) != 1 {
+		return nil, fmt.Errorf("%w: browser binding mismatch", ErrInvalidState)
+	}
+	result := &Result{Intent: state.Intent, ReturnTo: state.ReturnTo}
+
+	// 2. 加载提供方
+	provider, err := s.EnabledProvider(state.ProviderID)
+	if err != nil {
+		return result, err
+	}
+	result.Provider = provider
+	if code == "" {
+		return result, fmt.Errorf("%w: authorization was not granted", ErrUpstream)
+	}
+
+	// 3. 换取并校验上游身份
+	identity, err := s.fetchIdentity(provider, state, code)
+	if err != nil {
+		return result, err
+	}
+	result.Identity = identity
+
+	// 4. 按意图处理
+	if state.Intent == model.FederatedIntentLink {
+		user, err := s.link(state.UserID, provider, identity)
+		result.User = user
+		return result, err
+	}
+	user, provisioned, err := s.resolveLogin(provider, identity)
+	result.User = user
+	result.Provisioned = provisioned
+	return result, err
+}
+
+func (s *Service) consumeState(value string) (*model.FederatedAuthState, error) {
+	if value == "" {
+		return nil, ErrInvalidState
+	}
+	var state model.FederatedAuthState
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ?", value).First(&state).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&model.FederatedAuthState{}, state.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+	return &state, nil
+}
+
+func (s *Service) fetchIdentity(provider *model.IdentityProvider, state *model.FederatedAuthState, code string) (ExternalIdentity, error) {
+	ep, err := resolveEndpoints(provider)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	secret, err := utils.DecryptIdentityProviderSecret(provider.ClientSecretEnc)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	tokens, err := exchangeCode(ep, provider.ClientID, secret, code, state.RedirectURI, state.CodeVerifier)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+
+	var identity ExternalIdentity
+	if provider.Type == model.IdentityProviderTypeOIDC {
+		if tokens.IDToken == "" {
+			return ExternalIdentity{}, fmt.Errorf("%w: token response has no id_token", ErrUpstream)
+		}
+		claims, err := verifyIDToken(ep, provider.ClientID, tokens.IDToken, state.Nonce)
+		if err != nil {
+			return ExternalIdentity{}, err
+		}
+		identity = identityFromClaims(claims, "sub", "email", "name")
+		// 部分提供方不在 id_token 中返回邮箱，从 userinfo 补全（sub 必须一致，OIDC Core §5.3.2）
+		if identity.Email == "" && ep.Userinfo != "" {
+			if info, err := fetchUserinfo(ep.Userinfo, tokens.AccessToken); err == nil && claimString(info["sub"]) == identity.Subject {
+				extra := identityFromClaims(info, "sub", "email", "name")
+				identity.Email, identity.EmailVerified = extra.Email, extra.EmailVerified
+				if identity.Name == "" {
+					identity.Name = extra.Name
+				}
+			}
+		}
+	} else {
+		info, err := fetchUserinfo(ep.Userinfo, tokens.AccessToken)
+		if err != nil {
+			return ExternalIdentity{}, err
+		}
+		identity = identityFromClaims(info, provider.SubjectField, provider.EmailField, provider.NameField)
+		if provider.Preset == PresetGitHub {
+			email, err := fetchGitHubVerifiedEmail(ep.Userinfo, tokens.AccessToken)
+			if err != nil {
+				return ExternalIdentity{}, err
+			}
+			identity.Email, identity.EmailVerified = strings.ToLower(email), email != ""
+		}
+	}
+
+	if identity.Subject == "" {
+		return ExternalIdentity{}, fmt.Errorf("%w: missing subject", ErrUpstream)
+	}
+	return identity, nil
+}
+
+// resolveLogin 按外部身份找到或创建本地用户：
+// 已绑定的外部身份直接登录；同邮箱的本地账号需先登录后手动绑定；否则在允许注册时即时创建租户账号
+func (s *Service) resolveLogin(provider *model.IdentityProvider, identity ExternalIdentity) (*model.User, bool, error) {
+	// 1. 租户登录开关
+	if provider.TenantID > 0 {
+		allowed, err := tenantservice.IsTenantLoginAllowed(provider.TenantID)
+		if err != nil {
+			return nil, false, err
+		}
+		if !allowed {
+			return nil, false, ErrTenantLoginDisabled
+		}
+	}
+
+	// 2. 已绑定：直接登录
+	var link model.UserIdentityLink
+	err := s.db.Where("provider_id = ? AND subject = ?", provider.ID, identity.Subject).First(&link).Error
+	if err == nil {
+		var user model.User
+		if err := s.db.First(&user, link.UserID).Error; err != nil {
+			return nil, false, err
+		}
+		if user.Banned {
+			return nil, false, ErrUserBanned
+		}
+		now := time.Now()
+		s.db.Model(&link).Updates(map[string]interface{}{
+			"email":         identity.Email,
+			"display_name":  utils.Truncate(identity.Name, 128),
+			"last_login_at": now,
+		})
+		return &user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	// 3. 同邮箱的本地账号：不自动绑定，避免上游邮箱被冒用后接管账号。
+	// 未经上游验证的邮箱既不用于匹配已有账号，也不用于创建账号
+	if identity.Email == "" {
+		return nil, false, ErrEmailRequired
+	}
+	if !identity.EmailVerified {
+		return nil, false, ErrEmailNotVerified
+	}
+	var existing int64
+	if err := s.db.Model(&model.User{}).
+		Where("email = ? AND tenant_id IN ?", identity.Email, []uint{0, provider.TenantID}).
+		Count(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if existing > 0 {
+		return nil, false, ErrLinkRequired
+	}
+
+	// 4. 即时创建账号
+	if !provider.AllowProvisioning || !settingssvc.GetBool("auth.enable_register", true) {
+		return nil, false, ErrRegistrationDisabled
+	}
+	allowed, err := tenantservice.IsTenantRegistrationAllowed(provider.TenantID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !allowed {
+		return nil, false, ErrRegistrationDisabled
+	}
+
+	user, err := s.provision(provider, identity)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+func (s *Service) provision(provider *model.IdentityProvider, identity ExternalIdentity) (*model.User, error) {
+	nickname := identity.Name
+	if nickname == "" {
+		nickname = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	now := time.Now()
+	user := &model.User{
+		TenantID:      provider.TenantID,
+		Email:         identity.Email,
+		Nickname:      utils.Truncate(nickname, 64),
+		EmailVerified: identity.EmailVerified,
+	}
+	if len(identity.Picture) <= 255 {
+		user.AvatarURL = identity.Picture
+	}
+	if identity.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.UserIdentityLink{
+			UserID:      user.ID,
+			TenantID:    provider.TenantID,
+			ProviderID:  provider.ID,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			DisplayName: utils.Truncate(identity.Name, 128),
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// link 将外部身份绑定到已登录的用户
+func (s *Service) link(userID uint, provider *model.IdentityProvider, identity ExternalIdentity) (*model.User, error) {
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var existing model.UserIdentityLink
+	err := s.db.Where("provider_id = ? AND subject = ?", provider.ID, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID != userID {
+			return &user, ErrIdentityLinkedElsewhere
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &user, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.UserIdentityLink{}).Where("user_id = ? AND provider_id = ?", userID, provider.ID).Count(&count).Error; err != nil {
+		return &user, err
+	}
+	if count > 0 {
+		return &user, ErrProviderAlreadyLinked
+	}
+
+	return &user, s.db.Create(&model.UserIdentityLink{
+		UserID:      userID,
+		TenantID:    provider.TenantID,
+		ProviderID:  provider.ID,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		DisplayName: utils.Truncate(identity.Name, 128),
+	}).Error
+}
+
+// CallbackURL 返回本服务对外的回调地址，需登记到上游提供方的 redirect URI 白名单
+func CallbackURL(baseURL string) string {
+	return strings.TrimRight(baseURL, "/") + CallbackPath
+}
+
+// SafeReturnTo 只接受站内相对路径作为完成后的返回地址，防止开放重定向
+func SafeReturnTo(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.Contains(raw, "\\") {
+		return ""
+	}
+	return raw
+}
+
+// stateBinding 计算 state 与 nonce 的摘要，作为发起授权的浏览器的绑定值
+func stateBinding(state *model.FederatedAuthState) string {
+	sum := sha256.Sum256([]byte(state.State + "." + state.Nonce))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,399 @@
+package federation
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// mockIdP 本地模拟的上游身份提供方：Discovery、JWKS、令牌端点（校验 PKCE）与 userinfo
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string                 // 授权请求中的 code_challenge
+	claims    map[string]interface{} // 下一次签发的 id_token / userinfo 内容
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	idp := &mockIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"userinfo_endpoint":      idp.server.URL + "/userinfo",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "mock", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{"iss": idp.server.URL, "aud": r.PostForm.Get("client_id"), "exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock"
+		idToken, _ := token.SignedString(key)
+		writeJSON(w, map[string]string{"access_token": "upstream-access", "token_type": "Bearer", "id_token": idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		writeJSON(w, idp.claims)
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	// 生产客户端拒绝连接回环地址；测试中的上游服务监听在本机，改用测试服务器自带的客户端
+	if _, err := httpClient.Get(idp.server.URL + "/.well-known/openid-configuration"); !errors.Is(err, utils.ErrNonPublicAddress) {
+		t.Fatalf("expected upstream requests to loopback to be refused, got %v", err)
+	}
+	previous := httpClient
+	httpClient = idp.server.Client()
+	t.Cleanup(func() { httpClient = previous })
+	return idp
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// authorize 模拟浏览器跳转上游：记录 code_challenge，并以 nonce 生成下一次的身份声明
+func (m *mockIdP) authorize(t *testing.T, authURL string, claims map[string]interface{}) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization url failed: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("expected PKCE S256, got %q", q.Get("code_challenge_method"))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challenge = q.Get("code_challenge")
+	m.claims = map[string]interface{}{}
+	for k, v := range claims {
+		m.claims[k] = v
+	}
+	if _, ok := m.claims["nonce"]; !ok && q.Get("nonce") != "" {
+		m.claims["nonce"] = q.Get("nonce")
+	}
+	return q.Get("state")
+}
+
+func setupFederationTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret-for-unit-tests")
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Tenant{}, &model.TenantAuthSetting{}, &model.Passkey{},
+		&model.IdentityProvider{}, &model.UserIdentityLink{}, &model.FederatedAuthState{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	common.SetDBForTest(db)
+	return db
+}
+
+func createTestTenant(t *testing.T, db *gorm.DB) *model.Tenant {
+	t.Helper()
+	tenant := &model.Tenant{Name: "Acme", Code: "acme"}
+	if err := db.Create(tenant).Error; err != nil {
+		t.Fatalf("create tenant failed: %v", err)
+	}
+	return tenant
+}
+
+func TestOIDCLoginProvisionsTenantAccount(t *testing.T) {
+	db := setupFederationTestDB(t)
+	tenant := createTestTenant(t, db)
+	idp := newMockIdP(t)
+	svc := NewService(db)
+
+	provider, err := svc.CreateProvider(tenant.ID, ProviderInput{
+		Slug: "corp", Name: "Corporate SSO", Type: "oidc", Issuer: idp.server.URL,
+		ClientID: "basaltpass", ClientSecret: "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	if provider.ClientSecretEnc == "" || provider.ClientSecretEnc == "s3cret" {
+		t.Fatalf("client secret must be stored encrypted")
+	}
+
+	login := func(code string) (*Result, error) {
+		auth, err := svc.AuthorizationURL(provider, AuthorizeParams{
+			Intent: model.FederatedIntentLogin, RedirectURI: CallbackURL("https://id.example.com"), ReturnTo: "/apps",
+		})
+		if err != nil {
+			t.Fatalf("authorization url failed: %v", err)
+		}
+		state := idp.authorize(t, auth.URL, map[string]interface{}{
+			"sub": "upstream-42", "email": "Alice@Example.com", "email_verified": true, "name": "Alice",
+		})
+		return svc.Complete(state, code, auth.Binding)
+	}
+
+	// 首次登录即时创建租户账号并建立绑定
+	result, err := login("good-code")
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if !result.Provisioned || result.User.TenantID != tenant.ID || result.User.Email != "alice@example.com" || !result.User.EmailVerified {
+		t.Fatalf("unexpected provisioned user: %+v", result.User)
+	}
+	if result.ReturnTo != "/apps" {
+		t.Fatalf("expected return path to round-trip, got %q", result.ReturnTo)
+	}
+
+	// 再次登录命中已有绑定
+	again, err := login("good-code")
+	if err != nil || again.Provisioned || again.User.ID != result.User.ID {
+		t.Fatalf("expected existing link to be used, got %+v %v", again, err)
+	}
+
+	// 上游换取令牌失败
+	if _, err := login("bad-code"); !errors.Is(err, ErrUpstream) {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+}
+
+func TestStateIsSingleUseAndNonceChecked(t *testing.T) {
+	db := setupFederationTestDB(t)
+	tenant := createTestTenant(t, db)
+	idp := newMockIdP(t)
+	svc := NewService(db)
+
+	provider, err := svc.CreateProvider(tenant.ID, ProviderInput{Slug: "corp", Name: "Corp", Type: "oidc", Issuer: idp.server.URL, ClientID: "basaltpass"})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	// 回调必须来自发起授权的浏览器
+	other, err := svc.AuthorizationURL(provider, AuthorizeParams{Intent: model.FederatedIntentLogin, RedirectURI: CallbackURL("https://id.example.com")})
+	if err != nil {
+		t.Fatalf("authorization url failed: %v", err)
+	}
+	replayed := idp.authorize(t, other.URL, map[string]interface{}{"sub": "u1", "email": "u1@example.com", "email_verified": true})
+	for _, binding := range []string{"", "forged-binding"} {
+		if _, err := svc.Complete(replayed, "good-code", binding); !errors.Is(err, ErrInvalidState) {
+			t.Fatalf("expected callback without browser binding to be rejected, got %v", err)
+		}
+	}
+
+	auth, err := svc.AuthorizationURL(provider, AuthorizeParams{Intent: model.FederatedIntentLogin, RedirectURI: CallbackURL("https://id.example.com")})
+	if err != nil {
+		t.Fatalf("authorization url failed: %v", err)
+	}
+	state := idp.authorize(t, auth.URL, map[string]interface{}{"sub": "u1", "email": "u1@example.com", "nonce": "forged"})
+
+	if _, err := svc.Complete(state, "good-code", auth.Binding); !errors.Is(err, ErrUpstream) {
+		t.Fatalf("expected nonce mismatch to be rejected, got %v", err)
+	}
+	if _, err := svc.Complete(state, "good-code", auth.Binding); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected consumed state to be rejected, got %v", err)
+	}
+}
+
+func TestExistingAccountRequiresExplicitLink(t *testing.T) {
+	db := setupFederationTestDB(t)
+	tenant := createTestTenant(t, db)
+	idp := newMockIdP(t)
+	svc := NewService(db)
+
+	user := model.User{TenantID: tenant.ID, Email: "bob@example.com", PasswordHash: "x", Nickname: "bob"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	provider, err := svc.CreateProvider(tenant.ID, ProviderInput{Slug: "corp", Name: "Corp", Type: "oidc", Issuer: idp.server.URL, ClientID: "basaltpass"})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	identity := map[string]interface{}{"sub": "bob-upstream", "email": "bob@example.com", "email_verified": true}
+	complete := func(params AuthorizeParams) (*Result, error) {
+		params.RedirectURI = CallbackURL("https://id.example.com")
+		auth, err := svc.AuthorizationURL(provider, params)
+		if err != nil {
+			t.Fatalf("authorization url failed: %v", err)
+		}
+		return svc.Complete(idp.authorize(t, auth.URL, identity), "good-code", auth.Binding)
+	}
+
+	// 未经上游验证的邮箱不用于匹配已有账号
+	identity["email_verified"] = false
+	if _, err := complete(AuthorizeParams{Intent: model.FederatedIntentLogin}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected unverified email to be rejected, got %v", err)
+	}
+	identity["email_verified"] = true
+
+	// 同邮箱本地账号不会被自动接管
+	if _, err := complete(AuthorizeParams{Intent: model.FederatedIntentLogin}); !errors.Is(err, ErrLinkRequired) {
+		t.Fatalf("expected link required, got %v", err)
+	}
+
+	// 登录后手动绑定，再次联合登录进入同一账号
+	if _, err := complete(AuthorizeParams{Intent: model.FederatedIntentLink, UserID: user.ID}); err != nil {
+		t.Fatalf("link failed: %v", err)
+	}
+	result, err := complete(AuthorizeParams{Intent: model.FederatedIntentLogin})
+	if err != nil || result.User.ID != user.ID {
+		t.Fatalf("expected login into linked account, got %+v %v", result, err)
+	}
+
+	// 已有密码时可以解绑
+	links, err := svc.ListLinks(user.ID)
+	if err != nil || len(links) != 1 || links[0].Provider.Name != "Corp" {
+		t.Fatalf("expected one link with provider, got %+v %v", links, err)
+	}
+	if _, err := svc.Unlink(user.ID, links[0].ID); err != nil {
+		t.Fatalf("unlink failed: %v", err)
+	}
+}
+
+func TestProvisioningRespectsTenantRegistration(t *testing.T) {
+	db := setupFederationTestDB(t)
+	tenant := createTestTenant(t, db)
+	idp := newMockIdP(t)
+	svc := NewService(db)
+
+	if err := db.Create(&model.TenantAuthSetting{TenantID: tenant.ID, AllowRegistration: false, AllowLogin: true}).Error; err != nil {
+		t.Fatalf("create auth setting failed: %v", err)
+	}
+	db.Model(&model.TenantAuthSetting{}).Where("tenant_id = ?", tenant.ID).Update("allow_registration", false)
+
+	provider, err := svc.CreateProvider(tenant.ID, ProviderInput{Slug: "corp", Name: "Corp", Type: "oidc", Issuer: idp.server.URL, ClientID: "basaltpass"})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	auth, err := svc.AuthorizationURL(provider, AuthorizeParams{Intent: model.FederatedIntentLogin, RedirectURI: CallbackURL("https://id.example.com")})
+	if err != nil {
+		t.Fatalf("authorization url failed: %v", err)
+	}
+	state := idp.authorize(t, auth.URL, map[string]interface{}{"sub": "new-user", "email": "new@example.com", "email_verified": true})
+
+	if _, err := svc.Complete(state, "good-code", auth.Binding); !errors.Is(err, ErrRegistrationDisabled) {
+		t.Fatalf("expected registration disabled, got %v", err)
+	}
+}
+
+func TestOAuth2ProviderReadsUserinfo(t *testing.T) {
+	db := setupFederationTestDB(t)
+	tenant := createTestTenant(t, db)
+	idp := newMockIdP(t)
+	svc := NewService(db)
+
+	provider, err := svc.CreateProvider(tenant.ID, ProviderInput{
+		Slug: "forge", Name: "Forge", Type: "oauth2",
+		AuthorizationEndpoint: idp.server.URL + "/authorize",
+		TokenEndpoint:         idp.server.URL + "/token",
+		UserinfoEndpoint:      idp.server.URL + "/userinfo",
+		ClientID:              "basaltpass",
+		SubjectField:          "id", EmailField: "mail", NameField: "login",
+	})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	login := func(claims map[string]interface{}) (*Result, error) {
+		auth, err := svc.AuthorizationURL(provider, AuthorizeParams{Intent: model.FederatedIntentLogin, RedirectURI: CallbackURL("https://id.example.com")})
+		if err != nil {
+			t.Fatalf("authorization url failed: %v", err)
+		}
+		return svc.Complete(idp.authorize(t, auth.URL, claims), "good-code", auth.Binding)
+	}
+
+	// 未声明邮箱已验证时不创建账号
+	if _, err := login(map[string]interface{}{"id": 9007199254740, "mail": "dev@example.com", "login": "octo"}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected unverified email to be rejected, got %v", err)
+	}
+
+	result, err := login(map[string]interface{}{"id": 9007199254740, "mail": "dev@example.com", "login": "octo", "email_verified": "true"})
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if result.Identity.Subject != "9007199254740" || result.User.Nickname != "octo" || !result.User.EmailVerified {
+		t.Fatalf("unexpected identity %+v user %+v", result.Identity, result.User)
+	}
+}
+
+func TestCreateProviderAppliesPreset(t *testing.T) {
+	db := setupFederationTestDB(t)
+	svc := NewService(db)
+
+	provider, err := svc.CreateProvider(1, ProviderInput{Preset: "GitHub", Name: "GitHub", ClientID: "gh", AllowProvisioning: new(bool)})
+	if err != nil {
+		t.Fatalf("create provider failed: %v", err)
+	}
+	if provider.Slug != PresetGitHub || provider.Type != model.IdentityProviderTypeOAuth2 || provider.SubjectField != "id" {
+		t.Fatalf("expected github preset to be applied, got %+v", provider)
+	}
+	stored, _ := svc.GetProvider(1, provider.ID)
+	if stored.AllowProvisioning {
+		t.Fatalf("expected allow_provisioning=false to be persisted")
+	}
+	if _, err := svc.CreateProvider(1, ProviderInput{Preset: "github", Name: "Again", ClientID: "gh"}); !errors.Is(err, ErrInvalidProvider) {
+		t.Fatalf("expected duplicate slug to be rejected, got %v", err)
+	}
+	if _, err := svc.CreateProvider(1, ProviderInput{Slug: "x", Name: "X", Type: "oidc", Issuer: "ftp://idp", ClientID: "c"}); !errors.Is(err, ErrInvalidProvider) {
+		t.Fatalf("expected invalid issuer to be rejected, got %v", err)
+	}
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	cases := map[string]string{
+		"/apps?x=1":            "/apps?x=1",
+		"//evil.example.com":   "",
+		"https://evil.example": "",
+		"/\\evil.example.com":  "",
+		"":                     "",
+	}
+	for in, want := range cases {
+		if got := SafeReturnTo(in); got != want {
+			t.Fatalf("SafeReturnTo(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package federation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/clientassertion"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	discoveryCacheTTL      = time.Hour
+	jwksCacheTTL           = 10 * time.Minute
+	jwksRefreshInterval    = 30 * time.Second // 遇到未知 kid 时重新拉取 jwks_uri 的最小间隔
+	maxUpstreamBodyBytes   = 1 << 20
+	idTokenClockSkew       = time.Minute
+	microsoftTenantPattern = "{tenantid}"
+)
+
+// httpClient 访问租户配置的上游发现、令牌与用户信息端点，只允许连接公网地址
+var httpClient = utils.NewPublicHTTPClient(10 * time.Second)
+
+// ExternalIdentity 上游身份提供方认证后返回的用户信息
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// endpoints 提供方的有效端点（显式配置优先，其余来自 Discovery）
+type endpoints struct {
+	Issuer        string
+	Authorization string
+	Token         string
+	Userinfo      string
+	JWKS          string
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type cachedDiscovery struct {
+	doc       discoveryDocument
+	fetchedAt time.Time
+}
+
+type cachedKeys struct {
+	keys      []clientassertion.PublicKey
+	fetchedAt time.Time
+}
+
+var (
+	upstreamCacheMu sync.Mutex
+	discoveryCache  = map[string]cachedDiscovery{}
+	jwksCache       = map[string]cachedKeys{}
+)
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// resolveEndpoints 合并显式配置与 Discovery 文档，得到完成登录所需的端点
+func resolveEndpoints(p *model.IdentityProvider) (endpoints, error) {
+	ep := endpoints{
+		Issuer:        strings.TrimRight(strings.TrimSpace(p.Issuer), "/"),
+		Authorization: strings.TrimSpace(p.AuthorizationEndpoint),
+		Token:         strings.TrimSpace(p.TokenEndpoint),
+		Userinfo:      strings.TrimSpace(p.UserinfoEndpoint),
+		JWKS:          strings.TrimSpace(p.JWKSURI),
+	}
+
+	if p.Type == model.IdentityProviderTypeOIDC && (ep.Authorization == "" || ep.Token == "" || ep.JWKS == "") {
+		doc, err := discover(ep.Issuer)
+		if err != nil {
+			return ep, err
+		}
+		// id_token 的 iss 以 Discovery 文档为准（Microsoft 多租户端点为 {tenantid} 模板）
+		if doc.Issuer != "" {
+			ep.Issuer = doc.Issuer
+		}
+		fill := func(dst *string, v string) {
+			if *dst == "" {
+				*dst = v
+			}
+		}
+		fill(&ep.Authorization, doc.AuthorizationEndpoint)
+		fill(&ep.Token, doc.TokenEndpoint)
+		fill(&ep.Userinfo, doc.UserinfoEndpoint)
+		fill(&ep.JWKS, doc.JWKSURI)
+	}
+
+	if ep.Authorization == "" || ep.Token == "" {
+		return ep, fmt.Errorf("%w: authorization and token endpoints are required", ErrUpstream)
+	}
+	if p.Type == model.IdentityProviderTypeOIDC && ep.JWKS == "" {
+		return ep, fmt.Errorf("%w: jwks_uri is required", ErrUpstream)
+	}
+	if p.Type == model.IdentityProviderTypeOAuth2 && ep.Userinfo == "" {
+		return ep, fmt.Errorf("%w: userinfo endpoint is required", ErrUpstream)
+	}
+	return ep, nil
+}
+
+// discover 拉取 issuer 的 OIDC Discovery 文档（OpenID Connect Discovery 1.0 §4）
+func discover(issuer string) (discoveryDocument, error) {
+	if issuer == "" {
+		return discoveryDocument{}, fmt.Errorf("%w: issuer is required for discovery", ErrUpstream)
+	}
+
+	upstreamCacheMu.Lock()
+	cached, ok := discoveryCache[issuer]
+	upstreamCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < discoveryCacheTTL {
+		return cached.doc, nil
+	}
+
+	var doc discoveryDocument
+	if err := getJSON(issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return discoveryDocument{}, err
+	}
+
+	upstreamCacheMu.Lock()
+	discoveryCache[issuer] = cachedDiscovery{doc: doc, fetchedAt: time.Now()}
+	upstreamCacheMu.Unlock()
+	return doc, nil
+}
+
+// exchangeCode 以授权码换取上游令牌（client_secret_post，附带 PKCE code_verifier）
+func exchangeCode(ep endpoints, clientID, clientSecret, code, redirectURI, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", clientID)
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ep.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub 默认返回表单编码，需显式要求 JSON
+	req.Header.Set("Accept", "application/json")
+
+	var tokens tokenResponse
+	status, err := doJSON(req, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("%w: token endpoint returned %s: %s", ErrUpstream, tokens.Error, tokens.ErrorDescription)
+	}
+	if status != http.StatusOK || tokens.AccessToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint returned status %d", ErrUpstream, status)
+	}
+	return &tokens, nil
+}
+
+// verifyIDToken 校验上游 id_token 的签名、iss、aud、exp 与 nonce（OIDC Core §3.1.3.7）
+func verifyIDToken(ep endpoints, clientID, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return lookupUpstreamKey(ep.JWKS, token)
+	},
+		jwt.WithValidMethods(clientassertion.SigningAlgorithms),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(idTokenClockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid id_token: %v", ErrUpstream, err)
+	}
+
+	expectedIssuer := ep.Issuer
+	if strings.Contains(expectedIssuer, microsoftTenantPattern) {
+		tid, _ := claims["tid"].(string)
+		expectedIssuer = strings.ReplaceAll(expectedIssuer, microsoftTenantPattern, tid)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != strings.TrimRight(expectedIssuer, "/") {
+		return nil, fmt.Errorf("%w: id_token issuer mismatch", ErrUpstream)
+	}
+	if got, _ := claims["nonce"].(string); nonce != "" && got != nonce {
+		return nil, fmt.Errorf("%w: id_token nonce mismatch", ErrUpstream)
+	}
+	return claims, nil
+}
+
+// lookupUpstreamKey 按 kid 从上游 JWKS 中查找验签公钥；未命中时在限频内重新拉取一次
+func lookupUpstreamKey(jwksURI string, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, refresh := range []bool{false, true} {
+		keys, err := upstreamKeys(jwksURI, refresh)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if (kid == "" || k.KID == kid) && (k.Alg == "" || k.Alg == token.Method.Alg()) {
+				return k.Key, nil
+			}
+		}
+	}
+	return nil, errors.New("no matching key in jwks")
+}
+
+func upstreamKeys(jwksURI string, refresh bool) ([]clientassertion.PublicKey, error) {
+	upstreamCacheMu.Lock()
+	cached, ok := jwksCache[jwksURI]
+	upstreamCacheMu.Unlock()
+	if ok {
+		age := time.Since(cached.fetchedAt)
+		if age < jwksCacheTTL && (!refresh || age < jwksRefreshInterval) {
+			return cached.keys, nil
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, status, err := doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("jwks_uri returned status %d", status)
+	}
+	keys, err := clientassertion.ParseJWKS(body)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamCacheMu.Lock()
+	jwksCache[jwksURI] = cachedKeys{keys: keys, fetchedAt: time.Now()}
+	upstreamCacheMu.Unlock()
+	return keys, nil
+}
+
+// fetchUserinfo 以上游访问令牌读取用户资料
+func fetchUserinfo(endpoint, accessToken string) (map[string]interface{}, error) {
+	info := map[string]interface{}{}
+	if err := getJSON(endpoint, accessToken, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// fetchGitHubVerifiedEmail 读取 GitHub 账号的主邮箱（/user 只返回公开邮箱且不含验证状态）
+func fetchGitHubVerifiedEmail(userinfoEndpoint, accessToken string) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(strings.TrimRight(userinfoEndpoint, "/")+"/emails", accessToken, &emails); err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+	return "", nil
+}
+
+// identityFromClaims 按字段映射从 id_token 声明或 userinfo 响应中提取用户信息
+func identityFromClaims(claims map[string]interface{}, subjectField, emailField, nameField string) ExternalIdentity {
+	field := func(name, def string) string {
+		if strings.TrimSpace(name) == "" {
+			name = def
+		}
+		return claimString(claims[name])
+	}
+	identity := ExternalIdentity{
+		Subject: field(subjectField, "sub"),
+		Email:   strings.ToLower(field(emailField, "email")),
+		Name:    field(nameField, "name"),
+		Picture: claimString(claims["picture"]),
+	}
+	if identity.Picture == "" {
+		identity.Picture = claimString(claims["avatar_url"])
+	}
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = strings.EqualFold(v, "true")
+	}
+	return identity
+}
+
+func claimString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case json.Number:
+		return t.String()
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func getJSON(endpoint, bearer string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	status, err := doJSON(req, out)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%w: %s returned status %d", ErrUpstream, endpoint, status)
+	}
+	return nil
+}
+
+// doJSON 发送请求并解码 JSON 响应体；数字保留为 json.Number，避免大整数 ID 丢失精度
+func doJSON(req *http.Request, out interface{}) (int, error) {
+	body, status, err := doRequest(req)
+	if err != nil {
+		return status, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return status, fmt.Errorf("%w: invalid response from %s: %v", ErrUpstream, req.URL.Host, err)
+	}
+	return status, nil
+}
+
+func doRequest(req *http.Request) ([]byte, int, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBodyBytes))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	return body, resp.StatusCode, nil
+}
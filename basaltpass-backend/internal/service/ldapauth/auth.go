@@ -0,0 +1,541 @@
+package ldapauth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// entry 目录用户条目中登录与同步需要的属性
+type entry struct {
+	dn      string
+	subject string
+	email   string
+	name    string
+	phone   string
+	groups  []string
+}
+
+// LoginGuard 目录登录的账号锁定钩子：绑定前检查锁定，目录拒绝密码时记录失败。
+// 两个方法返回的错误原样返回给 Authenticate 的调用方。
+type LoginGuard interface {
+	Check(userID uint) error
+	RecordFailure(userID uint) error
+}
+
+// Authenticate 通过租户目录校验用户名与密码，返回对应的租户账号（首次登录时按配置即时创建）。
+// 目录中不存在该用户时返回 ErrUserNotFound，调用方可回落到本地密码登录。
+// guard 不为 nil 时，目录条目对应的本地账号参与锁定策略。
+func (s *Service) Authenticate(tenantID uint, username, password string, guard LoginGuard) (*model.User, error) {
+	// 1. 读取目录配置
+	dir, err := s.enabledDirectory(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, ErrUserNotFound
+	}
+	// 空密码的简单绑定会被目录当作未认证绑定而成功，必须拒绝
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 2. 以服务账号查找用户条目
+	conn, err := s.connect(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	e, err := s.findEntry(conn, dir, dir.BaseDN, ldap.ScopeWholeSubtree, userFilter(dir, username))
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 条目对应已有账号时先检查锁定，锁定期内不再向目录校验密码
+	var accountID uint
+	if guard != nil {
+		if accountID, err = s.accountFor(dir, e); err != nil {
+			return nil, err
+		}
+		if accountID != 0 {
+			if err := guard.Check(accountID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 4. 以用户 DN 与提交的密码绑定校验，目录拒绝密码时计入失败次数
+	if err := conn.Bind(e.dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			if accountID != 0 {
+				if err := guard.RecordFailure(accountID); err != nil {
+					return nil, err
+				}
+			}
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%w: user bind: %v", ErrDirectoryUnavailable, err)
+	}
+
+	// 5. 按组查找时需恢复服务账号身份
+	if dir.GroupFilter != "" {
+		if err := s.bindService(conn, dir); err != nil {
+			return nil, err
+		}
+		if e.groups, err = s.searchGroups(conn, dir, e.dn); err != nil {
+			return nil, err
+		}
+	}
+
+	// 6. 关联或即时创建租户账号，并同步资料与组角色
+	return s.upsertUser(dir, e, true)
+}
+
+// accountFor 返回目录条目对应的本地账号：已绑定的账号，或首次登录时可能被自动绑定的无本地密码的同邮箱账号；没有时返回 0
+func (s *Service) accountFor(dir *model.LDAPDirectory, e *entry) (uint, error) {
+	var link model.LDAPUserLink
+	err := s.db.Select("user_id").Where("directory_id = ? AND subject = ?", dir.ID, e.subject).First(&link).Error
+	if err == nil {
+		return link.UserID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	if e.email == "" {
+		return 0, nil
+	}
+	var user model.User
+	err = s.db.Select("id").Where("tenant_id = ? AND email = ? AND password_hash = ?", dir.TenantID, e.email, "").First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return user.ID, err
+}
+
+// findEntry 查找唯一的目录条目；多个条目匹配时拒绝登录，避免认证到错误的账号
+func (s *Service) findEntry(conn ldap.Client, dir *model.LDAPDirectory, baseDN string, scope int, filter string) (*entry, error) {
+	req := ldap.NewSearchRequest(baseDN, scope, ldap.NeverDerefAliases, 2, int(requestTimeout.Seconds()), false,
+		filter, entryAttributes(dir), nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrUserNotFound
+		}
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, fmt.Errorf("%w: filter matched more than one entry", ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("%w: search: %v", ErrDirectoryUnavailable, err)
+	}
+	switch len(res.Entries) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+		return entryFromLDAP(dir, res.Entries[0]), nil
+	default:
+		return nil, fmt.Errorf("%w: filter matched more than one entry", ErrInvalidCredentials)
+	}
+}
+
+// searchGroups 按 GroupFilter 查找用户所属的组，返回组 DN
+func (s *Service) searchGroups(conn ldap.Client, dir *model.LDAPDirectory, dn string) ([]string, error) {
+	req := ldap.NewSearchRequest(dir.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(requestTimeout.Seconds()), false,
+		groupFilter(dir, dn), []string{"1.1"}, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: group search: %v", ErrDirectoryUnavailable, err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, g := range res.Entries {
+		groups = append(groups, g.DN)
+	}
+	return groups, nil
+}
+
+func (s *Service) bindService(conn ldap.Client, dir *model.LDAPDirectory) error {
+	if dir.BindDN == "" {
+		if err := conn.UnauthenticatedBind(""); err != nil {
+			return fmt.Errorf("%w: anonymous bind: %v", ErrDirectoryUnavailable, err)
+		}
+		return nil
+	}
+	password, err := utils.DecryptIdentityProviderSecret(dir.BindPasswordEnc)
+	if err != nil {
+		return err
+	}
+	if err := conn.Bind(dir.BindDN, password); err != nil {
+		return fmt.Errorf("%w: service account bind: %v", ErrDirectoryUnavailable, err)
+	}
+	return nil
+}
+
+func entryAttributes(dir *model.LDAPDirectory) []string {
+	var attrs []string
+	for _, a := range []string{dir.UIDAttribute, dir.EmailAttribute, dir.NameAttribute, dir.PhoneAttribute, "cn"} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	if dir.GroupFilter == "" && dir.GroupAttribute != "" {
+		attrs = append(attrs, dir.GroupAttribute)
+	}
+	return attrs
+}
+
+func entryFromLDAP(dir *model.LDAPDirectory, le *ldap.Entry) *entry {
+	e := &entry{dn: le.DN, subject: le.DN}
+	// objectGUID 等二进制标识按十六进制保存
+	if dir.UIDAttribute != "" {
+		if raw := le.GetEqualFoldRawAttributeValue(dir.UIDAttribute); len(raw) > 0 {
+			if utf8.Valid(raw) {
+				e.subject = string(raw)
+			} else {
+				e.subject = hex.EncodeToString(raw)
+			}
+		}
+	}
+	if email, err := utils.NewEmailValidator().Normalize(le.GetEqualFoldAttributeValue(dir.EmailAttribute)); err == nil {
+		e.email = email
+	}
+	if dir.NameAttribute != "" {
+		e.name = strings.TrimSpace(le.GetEqualFoldAttributeValue(dir.NameAttribute))
+	}
+	if e.name == "" {
+		e.name = strings.TrimSpace(le.GetEqualFoldAttributeValue("cn"))
+	}
+	if dir.PhoneAttribute != "" {
+		if raw := le.GetEqualFoldAttributeValue(dir.PhoneAttribute); raw != "" {
+			if phone, err := utils.NewPhoneValidator("+86").NormalizeToE164(raw); err == nil {
+				e.phone = phone
+			}
+		}
+	}
+	if dir.GroupFilter == "" && dir.GroupAttribute != "" {
+		e.groups = le.GetEqualFoldAttributeValues(dir.GroupAttribute)
+	}
+	return e
+}
+
+// upsertUser 按目录条目关联或创建租户账号，同步资料与组角色。
+// 首次登录时仅自动绑定租户内同邮箱、没有本地密码且不是租户所有者或管理员的账号；
+// 其余同邮箱账号返回 ErrLinkRequired，须由租户管理员通过 LinkUser 显式绑定，避免目录条目凭 mail 属性接管账号。
+func (s *Service) upsertUser(dir *model.LDAPDirectory, e *entry, login bool) (*model.User, error) {
+	var user model.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 1. 按稳定标识查找已绑定的账号
+		var link model.LDAPUserLink
+		err := tx.Where("directory_id = ? AND subject = ?", dir.ID, e.subject).First(&link).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if err := tx.First(&user, link.UserID).Error; err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+				// 账号已被删除：解除旧绑定，按新用户处理
+				if err := tx.Delete(&link).Error; err != nil {
+					return err
+				}
+				link = model.LDAPUserLink{}
+			}
+		}
+
+		// 2. 首次登录：绑定同邮箱账号或即时创建
+		if link.ID == 0 {
+			if !login {
+				return ErrUserNotFound
+			}
+			if e.email == "" {
+				return ErrEmailRequired
+			}
+			err := tx.Where("tenant_id = ? AND email = ?", dir.TenantID, e.email).First(&user).Error
+			switch {
+			case err == nil:
+				var linked int64
+				if err := tx.Model(&model.LDAPUserLink{}).Where("user_id = ?", user.ID).Count(&linked).Error; err != nil {
+					return err
+				}
+				if linked > 0 {
+					return ErrEmailConflict
+				}
+				privileged, err := isPrivileged(tx, dir.TenantID, user.ID)
+				if err != nil {
+					return err
+				}
+				if user.PasswordHash != "" || privileged {
+					return ErrLinkRequired
+				}
+				if err := recordLink(tx, dir, e, user.ID, 0, "login"); err != nil {
+					return err
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if !dir.AllowProvisioning {
+					return ErrProvisioningDisabled
+				}
+				now := time.Now()
+				nickname := e.name
+				if nickname == "" {
+					nickname = strings.SplitN(e.email, "@", 2)[0]
+				}
+				user = model.User{
+					TenantID:        dir.TenantID,
+					Email:           e.email,
+					Nickname:        utils.Truncate(nickname, 64),
+					EmailVerified:   true, // 邮箱由租户目录提供，视为已验证
+					EmailVerifiedAt: &now,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+			link = model.LDAPUserLink{UserID: user.ID, TenantID: dir.TenantID, DirectoryID: dir.ID, Subject: e.subject}
+		}
+		if user.Banned {
+			return ErrUserBanned
+		}
+
+		// 3. 同步资料：邮箱与手机号与租户内其他账号冲突时保留原值
+		if err := s.syncProfile(tx, &user, e); err != nil {
+			return err
+		}
+
+		// 4. 更新绑定记录
+		now := time.Now()
+		link.DN = utils.Truncate(e.dn, 512)
+		if login {
+			link.LastLoginAt = &now
+		} else {
+			link.LastSyncAt = &now
+		}
+		if err := tx.Save(&link).Error; err != nil {
+			return err
+		}
+
+		// 5. 按组映射同步租户角色
+		return s.syncRoles(tx, dir, user.ID, e.groups)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LinkUser 由租户管理员将租户账号显式绑定到目录条目（按 username 查找），清除本地密码并同步资料与组角色。
+// 绑定后该账号只能使用目录密码登录。operatorID 记录在审计日志中。
+func (s *Service) LinkUser(tenantID, userID uint, username string, operatorID uint) (*model.User, error) {
+	dir, err := s.enabledDirectory(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var user model.User
+	if err := s.db.Where("id = ? AND tenant_id = ?", userID, tenantID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, ErrUserNotFound
+	}
+
+	// 1. 以服务账号查找目录条目
+	conn, err := s.connect(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	e, err := s.findEntry(conn, dir, dir.BaseDN, ldap.ScopeWholeSubtree, userFilter(dir, username))
+	if err != nil {
+		return nil, err
+	}
+	if dir.GroupFilter != "" {
+		if e.groups, err = s.searchGroups(conn, dir, e.dn); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. 创建绑定并清除本地密码
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var linked int64
+		if err := tx.Model(&model.LDAPUserLink{}).
+			Where("user_id = ? OR (directory_id = ? AND subject = ?)", user.ID, dir.ID, e.subject).
+			Count(&linked).Error; err != nil {
+			return err
+		}
+		if linked > 0 {
+			return ErrAlreadyLinked
+		}
+		if err := tx.Create(&model.LDAPUserLink{UserID: user.ID, TenantID: dir.TenantID, DirectoryID: dir.ID, Subject: e.subject, DN: utils.Truncate(e.dn, 512)}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("password_hash", "").Error; err != nil {
+			return err
+		}
+		return recordLink(tx, dir, e, user.ID, operatorID, "admin")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 按目录同步资料与组角色
+	return s.upsertUser(dir, e, false)
+}
+
+// isPrivileged 账号是否为租户所有者或管理员
+func isPrivileged(tx *gorm.DB, tenantID, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&model.TenantUser{}).
+		Where("tenant_id = ? AND user_id = ? AND role IN ?", tenantID, userID,
+			[]model.TenantRole{model.TenantRoleOwner, model.TenantRoleAdmin}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// recordLink 记录账号与目录条目绑定的审计日志；operatorID 为 0 表示用户首次目录登录时自动绑定
+func recordLink(tx *gorm.DB, dir *model.LDAPDirectory, e *entry, userID, operatorID uint, method string) error {
+	actor := operatorID
+	if actor == 0 {
+		actor = userID
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"target_user_id": userID,
+		"tenant_id":      dir.TenantID,
+		"directory_id":   dir.ID,
+		"subject":        e.subject,
+		"dn":             e.dn,
+		"method":         method,
+	})
+	return tx.Create(&model.AuditLog{
+		UserID: actor,
+		Action: "ldap_account_linked",
+		Data:   string(data),
+	}).Error
+}
+
+func (s *Service) syncProfile(tx *gorm.DB, user *model.User, e *entry) error {
+	updates := map[string]interface{}{}
+	if e.name != "" && utils.Truncate(e.name, 64) != user.Nickname {
+		updates["nickname"] = utils.Truncate(e.name, 64)
+	}
+	available := func(column, value string) (bool, error) {
+		var count int64
+		err := tx.Model(&model.User{}).Where(column+" = ? AND tenant_id = ? AND id <> ?", value, user.TenantID, user.ID).Count(&count).Error
+		return count == 0, err
+	}
+	if e.email != "" && e.email != user.Email {
+		ok, err := available("email", e.email)
+		if err != nil {
+			return err
+		}
+		if ok {
+			updates["email"] = e.email
+		}
+	}
+	if e.phone != "" && e.phone != user.Phone {
+		ok, err := available("phone", e.phone)
+		if err != nil {
+			return err
+		}
+		if ok {
+			updates["phone"] = e.phone
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(user).Updates(updates).Error
+}
+
+// syncRoles 授予用户所在组映射的角色，并撤销映射中出现但用户已不在对应组的角色；未出现在映射中的角色不受影响
+func (s *Service) syncRoles(tx *gorm.DB, dir *model.LDAPDirectory, userID uint, groups []string) error {
+	mappings := GroupRoleMappings(dir)
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	memberOf := func(group string) bool {
+		want, err := ldap.ParseDN(group)
+		for _, g := range groups {
+			if err == nil {
+				if dn, err := ldap.ParseDN(g); err == nil && dn.EqualFold(want) {
+					return true
+				}
+			}
+			if strings.EqualFold(strings.TrimSpace(g), group) {
+				return true
+			}
+		}
+		return false
+	}
+	managed := make(map[string]bool)
+	wanted := make(map[string]bool)
+	for _, m := range mappings {
+		managed[m.Role] = true
+		if memberOf(m.Group) {
+			wanted[m.Role] = true
+		}
+	}
+	codes := make([]string, 0, len(managed))
+	for code := range managed {
+		codes = append(codes, code)
+	}
+	var roles []model.TenantRbacRole
+	if err := tx.Where("tenant_id = ? AND code IN ?", dir.TenantID, codes).Find(&roles).Error; err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+	roleIDs := make([]uint, 0, len(roles))
+	for _, r := range roles {
+		roleIDs = append(roleIDs, r.ID)
+	}
+
+	var current []uint
+	if err := tx.Model(&model.TenantUserRbacRole{}).
+		Where("user_id = ? AND tenant_id = ? AND role_id IN ?", userID, dir.TenantID, roleIDs).
+		Pluck("role_id", &current).Error; err != nil {
+		return err
+	}
+	has := make(map[uint]bool, len(current))
+	for _, id := range current {
+		has[id] = true
+	}
+
+	var revoke []uint
+	for _, r := range roles {
+		switch {
+		case wanted[r.Code] && !has[r.ID]:
+			if err := tx.Create(&model.TenantUserRbacRole{
+				UserID:     userID,
+				TenantID:   dir.TenantID,
+				RoleID:     r.ID,
+				AssignedAt: time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+		case !wanted[r.Code] && has[r.ID]:
+			revoke = append(revoke, r.ID)
+		}
+	}
+	if len(revoke) == 0 {
+		return nil
+	}
+	return tx.Where("user_id = ? AND tenant_id = ? AND role_id IN ?", userID, dir.TenantID, revoke).
+		Delete(&model.TenantUserRbacRole{}).Error
+}
@@ -0,0 +1,238 @@
+package ldapauth
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	testServiceDN       = "cn=admin,dc=example,dc=com"
+	testServicePassword = "admin-secret"
+)
+
+// testEntry 测试目录中的条目
+type testEntry struct {
+	password string
+	attrs    map[string][]string
+}
+
+// testDirectory 进程内最小 LDAP 服务器，仅实现简单绑定与搜索（and/or/not/equality/present 过滤器），用于测试登录与同步流程
+type testDirectory struct {
+	mu       sync.Mutex
+	entries  map[string]*testEntry
+	listener net.Listener
+}
+
+func newTestDirectory(t *testing.T) *testDirectory {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	d := &testDirectory{
+		entries: map[string]*testEntry{
+			"dc=example,dc=com": {attrs: map[string][]string{"objectClass": {"domain"}}},
+		},
+		listener: ln,
+	}
+	d.put(testServiceDN, testServicePassword, map[string][]string{"objectClass": {"person"}, "cn": {"admin"}})
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *testDirectory) URL() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *testDirectory) put(dn, password string, attrs map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[strings.ToLower(dn)] = &testEntry{password: password, attrs: attrs}
+}
+
+func (d *testDirectory) remove(dn string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, strings.ToLower(dn))
+}
+
+func (d *testDirectory) setAttr(dn, name string, values ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[strings.ToLower(dn)].attrs[name] = values
+}
+
+func (d *testDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	bound := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID := packet.Children[0].Value
+		op := packet.Children[1]
+		var replies []*ber.Packet
+		switch op.Tag {
+		case ber.Tag(0): // BindRequest
+			name, _ := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := d.bind(name, password)
+			if code == 0 {
+				bound = strings.ToLower(name)
+			}
+			replies = append(replies, ldapResult(1, code))
+		case ber.Tag(2): // UnbindRequest
+			return
+		case ber.Tag(3): // SearchRequest
+			replies = d.search(op, bound)
+		default:
+			return
+		}
+		for _, reply := range replies {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID"))
+			envelope.AppendChild(reply)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (d *testDirectory) bind(name, password string) int64 {
+	if name == "" && password == "" {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[strings.ToLower(name)]
+	if !ok || e.password == "" || e.password != password {
+		return 49 // invalidCredentials
+	}
+	return 0
+}
+
+func (d *testDirectory) search(op *ber.Packet, bound string) []*ber.Packet {
+	if bound != testServiceDN {
+		return []*ber.Packet{ldapResult(5, 50)} // insufficientAccessRights
+	}
+	base := strings.ToLower(op.Children[0].Value.(string))
+	scope := packetInt(op.Children[1])
+	sizeLimit := packetInt(op.Children[3])
+	filter := op.Children[6]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.entries[base]; !ok {
+		return []*ber.Packet{ldapResult(5, 32)} // noSuchObject
+	}
+	var replies []*ber.Packet
+	for dn, e := range d.entries {
+		inScope := dn == base
+		if scope == 2 {
+			inScope = inScope || strings.HasSuffix(dn, ","+base)
+		}
+		if !inScope || !matchFilter(filter, e.attrs) {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(replies)) >= sizeLimit {
+			return append(replies, ldapResult(5, 4)) // sizeLimitExceeded
+		}
+		replies = append(replies, searchEntry(dn, e.attrs))
+	}
+	return append(replies, ldapResult(5, 0))
+}
+
+func matchFilter(f *ber.Packet, attrs map[string][]string) bool {
+	switch f.Tag {
+	case ber.Tag(0): // and
+		for _, child := range f.Children {
+			if !matchFilter(child, attrs) {
+				return false
+			}
+		}
+		return true
+	case ber.Tag(1): // or
+		for _, child := range f.Children {
+			if matchFilter(child, attrs) {
+				return true
+			}
+		}
+		return false
+	case ber.Tag(2): // not
+		return !matchFilter(f.Children[0], attrs)
+	case ber.Tag(3): // equalityMatch
+		name := f.Children[0].Data.String()
+		value := f.Children[1].Data.String()
+		for _, v := range attrValues(attrs, name) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ber.Tag(7): // present
+		return strings.EqualFold(f.Data.String(), "objectClass") || len(attrValues(attrs, f.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+func attrValues(attrs map[string][]string, name string) []string {
+	for k, v := range attrs {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func packetInt(p *ber.Packet) int64 {
+	if v, ok := p.Value.(int64); ok {
+		return v
+	}
+	var n int64
+	for _, b := range p.Data.Bytes() {
+		n = n<<8 | int64(b)
+	}
+	return n
+}
+
+func ldapResult(tag ber.Tag, code int64) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "LDAP Result")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return p
+}
+
+func searchEntry(dn string, attrs map[string][]string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(4), nil, "Search Result Entry")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range attrs {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	p.AppendChild(list)
+	return p
+}
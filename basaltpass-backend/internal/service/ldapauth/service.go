@@ -0,0 +1,345 @@
+package ldapauth
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+const (
+	dialTimeout    = 5 * time.Second
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	ErrDirectoryNotFound    = errors.New("ldap directory not configured")
+	ErrInvalidDirectory     = errors.New("invalid ldap directory configuration")
+	ErrDirectoryUnavailable = errors.New("ldap directory unavailable")
+	ErrUserNotFound         = errors.New("user not found in directory")
+	ErrInvalidCredentials   = errors.New("invalid directory credentials")
+	ErrProvisioningDisabled = errors.New("directory user has no account and provisioning is disabled")
+	ErrEmailRequired        = errors.New("directory entry has no email address")
+	ErrEmailConflict        = errors.New("email is already used by an account linked to another directory entry")
+	ErrUserBanned           = errors.New("account is banned")
+	ErrLinkRequired         = errors.New("existing account must be linked to the directory by a tenant administrator")
+	ErrAccountNotFound      = errors.New("account not found in tenant")
+	ErrAlreadyLinked        = errors.New("account or directory entry is already linked")
+)
+
+// GroupRoleMapping 目录组到租户角色的映射：属于 Group 的用户被授予角色 Role（租户角色代码）
+type GroupRoleMapping struct {
+	Group string `json:"group"`
+	Role  string `json:"role"`
+}
+
+// DirectoryInput 保存目录配置的参数，仅修改非 nil 字段；BindPassword 为空串表示清除密码
+type DirectoryInput struct {
+	URL                 *string             `json:"url"`
+	StartTLS            *bool               `json:"start_tls"`
+	InsecureSkipVerify  *bool               `json:"insecure_skip_verify"`
+	BindDN              *string             `json:"bind_dn"`
+	BindPassword        *string             `json:"bind_password"`
+	BaseDN              *string             `json:"base_dn"`
+	UserFilter          *string             `json:"user_filter"`
+	UIDAttribute        *string             `json:"uid_attribute"`
+	EmailAttribute      *string             `json:"email_attribute"`
+	NameAttribute       *string             `json:"name_attribute"`
+	PhoneAttribute      *string             `json:"phone_attribute"`
+	GroupAttribute      *string             `json:"group_attribute"`
+	GroupBaseDN         *string             `json:"group_base_dn"`
+	GroupFilter         *string             `json:"group_filter"`
+	GroupRoleMappings   *[]GroupRoleMapping `json:"group_role_mappings"`
+	AllowProvisioning   *bool               `json:"allow_provisioning"`
+	SyncIntervalMinutes *int                `json:"sync_interval_minutes"`
+	Enabled             *bool               `json:"enabled"`
+}
+
+// Service LDAP / Active Directory 认证后端：目录配置、登录校验、即时创建账号与组角色同步
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建 LDAP 认证服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// GetDirectory 获取租户的目录配置
+func (s *Service) GetDirectory(tenantID uint) (*model.LDAPDirectory, error) {
+	var dir model.LDAPDirectory
+	if err := s.db.Where("tenant_id = ?", tenantID).First(&dir).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDirectoryNotFound
+		}
+		return nil, err
+	}
+	return &dir, nil
+}
+
+// enabledDirectory 获取租户已启用的目录配置
+func (s *Service) enabledDirectory(tenantID uint) (*model.LDAPDirectory, error) {
+	dir, err := s.GetDirectory(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.Enabled {
+		return nil, ErrDirectoryNotFound
+	}
+	return dir, nil
+}
+
+// SaveDirectory 创建或更新租户的目录配置。新建时未填写的属性映射取 OpenLDAP 常用值
+func (s *Service) SaveDirectory(tenantID uint, in DirectoryInput) (*model.LDAPDirectory, error) {
+	dir, err := s.GetDirectory(tenantID)
+	creating := errors.Is(err, ErrDirectoryNotFound)
+	if err != nil && !creating {
+		return nil, err
+	}
+	if creating {
+		dir = &model.LDAPDirectory{
+			TenantID:          tenantID,
+			UIDAttribute:      "entryUUID",
+			EmailAttribute:    "mail",
+			NameAttribute:     "displayName",
+			PhoneAttribute:    "telephoneNumber",
+			GroupAttribute:    "memberOf",
+			AllowProvisioning: true,
+			Enabled:           true,
+		}
+	}
+
+	set := func(dst *string, v *string) {
+		if v != nil {
+			*dst = strings.TrimSpace(*v)
+		}
+	}
+	set(&dir.URL, in.URL)
+	set(&dir.BindDN, in.BindDN)
+	set(&dir.BaseDN, in.BaseDN)
+	set(&dir.UserFilter, in.UserFilter)
+	set(&dir.UIDAttribute, in.UIDAttribute)
+	set(&dir.EmailAttribute, in.EmailAttribute)
+	set(&dir.NameAttribute, in.NameAttribute)
+	set(&dir.PhoneAttribute, in.PhoneAttribute)
+	set(&dir.GroupAttribute, in.GroupAttribute)
+	set(&dir.GroupBaseDN, in.GroupBaseDN)
+	set(&dir.GroupFilter, in.GroupFilter)
+	if in.StartTLS != nil {
+		dir.StartTLS = *in.StartTLS
+	}
+	if in.InsecureSkipVerify != nil {
+		dir.InsecureSkipVerify = *in.InsecureSkipVerify
+	}
+	if in.AllowProvisioning != nil {
+		dir.AllowProvisioning = *in.AllowProvisioning
+	}
+	if in.SyncIntervalMinutes != nil {
+		dir.SyncIntervalMinutes = *in.SyncIntervalMinutes
+	}
+	if in.Enabled != nil {
+		dir.Enabled = *in.Enabled
+	}
+	if dir.GroupFilter != "" && dir.GroupBaseDN == "" {
+		dir.GroupBaseDN = dir.BaseDN
+	}
+	if in.GroupRoleMappings != nil {
+		if err := s.validateMappings(tenantID, *in.GroupRoleMappings); err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(*in.GroupRoleMappings)
+		if err != nil {
+			return nil, err
+		}
+		dir.GroupRoleMappings = string(raw)
+	}
+	if err := validateDirectory(dir); err != nil {
+		return nil, err
+	}
+	if in.BindPassword != nil {
+		secret, err := utils.EncryptIdentityProviderSecret(*in.BindPassword)
+		if err != nil {
+			return nil, err
+		}
+		dir.BindPasswordEnc = secret
+	}
+
+	if !creating {
+		return dir, s.db.Save(dir).Error
+	}
+	// 带默认值的布尔字段为 false 时 Create 会写入列默认值，需在创建后单独更新
+	allowProvisioning, enabled := dir.AllowProvisioning, dir.Enabled
+	if err := s.db.Create(dir).Error; err != nil {
+		return nil, err
+	}
+	if !allowProvisioning || !enabled {
+		if err := s.db.Model(dir).Updates(map[string]interface{}{
+			"allow_provisioning": allowProvisioning,
+			"enabled":            enabled,
+		}).Error; err != nil {
+			return nil, err
+		}
+		dir.AllowProvisioning, dir.Enabled = allowProvisioning, enabled
+	}
+	return dir, nil
+}
+
+// DeleteDirectory 删除租户的目录配置及全部绑定关系；已创建的账号保留，但需通过重置密码设置本地密码
+func (s *Service) DeleteDirectory(tenantID uint) error {
+	dir, err := s.GetDirectory(tenantID)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("directory_id = ?", dir.ID).Delete(&model.LDAPUserLink{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(dir).Error
+	})
+}
+
+// GroupRoleMappings 解析目录配置中的组角色映射
+func GroupRoleMappings(dir *model.LDAPDirectory) []GroupRoleMapping {
+	var mappings []GroupRoleMapping
+	if dir.GroupRoleMappings != "" {
+		_ = json.Unmarshal([]byte(dir.GroupRoleMappings), &mappings)
+	}
+	return mappings
+}
+
+// TestConnection 使用当前配置连接目录并以服务账号绑定，校验 BaseDN 可读
+func (s *Service) TestConnection(tenantID uint) error {
+	dir, err := s.GetDirectory(tenantID)
+	if err != nil {
+		return err
+	}
+	conn, err := s.connect(dir)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	req := ldap.NewSearchRequest(dir.BaseDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, int(requestTimeout.Seconds()), false,
+		"(objectClass=*)", []string{"1.1"}, nil)
+	if _, err := conn.Search(req); err != nil {
+		return fmt.Errorf("%w: search base dn: %v", ErrDirectoryUnavailable, err)
+	}
+	return nil
+}
+
+func validateDirectory(dir *model.LDAPDirectory) error {
+	u, err := url.Parse(dir.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return fmt.Errorf("%w: url must be ldap://host[:port] or ldaps://host[:port]", ErrInvalidDirectory)
+	}
+	if dir.StartTLS && u.Scheme == "ldaps" {
+		return fmt.Errorf("%w: start_tls cannot be used with ldaps://", ErrInvalidDirectory)
+	}
+	if _, err := ldap.ParseDN(dir.BaseDN); err != nil || dir.BaseDN == "" {
+		return fmt.Errorf("%w: base_dn is not a valid DN", ErrInvalidDirectory)
+	}
+	if !strings.Contains(dir.UserFilter, "{username}") {
+		return fmt.Errorf("%w: user_filter must contain {username}", ErrInvalidDirectory)
+	}
+	if _, err := ldap.CompileFilter(userFilter(dir, "test")); err != nil {
+		return fmt.Errorf("%w: user_filter: %v", ErrInvalidDirectory, err)
+	}
+	if dir.EmailAttribute == "" {
+		return fmt.Errorf("%w: email_attribute is required", ErrInvalidDirectory)
+	}
+	if dir.GroupFilter != "" {
+		if !strings.Contains(dir.GroupFilter, "{dn}") {
+			return fmt.Errorf("%w: group_filter must contain {dn}", ErrInvalidDirectory)
+		}
+		if _, err := ldap.CompileFilter(groupFilter(dir, "cn=test")); err != nil {
+			return fmt.Errorf("%w: group_filter: %v", ErrInvalidDirectory, err)
+		}
+	}
+	if dir.SyncIntervalMinutes != 0 && (dir.SyncIntervalMinutes < 5 || dir.SyncIntervalMinutes > 7*24*60) {
+		return fmt.Errorf("%w: sync_interval_minutes must be 0 or between 5 and 10080", ErrInvalidDirectory)
+	}
+	return nil
+}
+
+// validateMappings 组必须是合法 DN，角色必须是租户下已存在的角色代码
+func (s *Service) validateMappings(tenantID uint, mappings []GroupRoleMapping) error {
+	codes := make([]string, 0, len(mappings))
+	for i := range mappings {
+		mappings[i].Group = strings.TrimSpace(mappings[i].Group)
+		mappings[i].Role = strings.TrimSpace(mappings[i].Role)
+		if _, err := ldap.ParseDN(mappings[i].Group); err != nil || mappings[i].Group == "" {
+			return fmt.Errorf("%w: group %q is not a valid DN", ErrInvalidDirectory, mappings[i].Group)
+		}
+		codes = append(codes, mappings[i].Role)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	var found []string
+	if err := s.db.Model(&model.TenantRbacRole{}).Where("tenant_id = ? AND code IN ?", tenantID, codes).Pluck("code", &found).Error; err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(found))
+	for _, code := range found {
+		known[code] = true
+	}
+	for _, code := range codes {
+		if !known[code] {
+			return fmt.Errorf("%w: role %q does not exist", ErrInvalidDirectory, code)
+		}
+	}
+	return nil
+}
+
+func userFilter(dir *model.LDAPDirectory, username string) string {
+	return strings.ReplaceAll(dir.UserFilter, "{username}", ldap.EscapeFilter(username))
+}
+
+func groupFilter(dir *model.LDAPDirectory, dn string) string {
+	return strings.ReplaceAll(dir.GroupFilter, "{dn}", ldap.EscapeFilter(dn))
+}
+
+// connect 连接目录并以服务账号绑定（未配置时匿名）
+func (s *Service) connect(dir *model.LDAPDirectory) (ldap.Client, error) {
+	u, err := url.Parse(dir.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDirectory, err)
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: dir.InsecureSkipVerify, // #nosec G402 -- 由租户管理员显式开启，用于自签名证书的内网目录
+		MinVersion:         tls.VersionTLS12,
+	}
+	conn, err := ldap.DialURL(dir.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	conn.SetTimeout(requestTimeout)
+	if dir.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: start tls: %v", ErrDirectoryUnavailable, err)
+		}
+	}
+
+	// 新连接默认即为匿名身份，仅在配置了服务账号时绑定
+	if dir.BindDN != "" {
+		if err := s.bindService(conn, dir); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
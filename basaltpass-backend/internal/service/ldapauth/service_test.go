@@ -0,0 +1,368 @@
+package ldapauth
+
+import (
+	"errors"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	aliceDN       = "uid=alice,ou=people,dc=example,dc=com"
+	adminsDN      = "cn=admins,ou=groups,dc=example,dc=com"
+	alicePassword = "alice-secret"
+)
+
+func setupLDAPTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Tenant{},
+		&model.TenantUser{},
+		&model.User{},
+		&model.AuditLog{},
+		&model.TenantRbacRole{},
+		&model.TenantUserRbacRole{},
+		&model.LDAPDirectory{},
+		&model.LDAPUserLink{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// newTestService 创建租户、admin 角色与一个包含 alice 的测试目录
+func newTestService(t *testing.T, in DirectoryInput) (*Service, *gorm.DB, *testDirectory, uint) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret-for-unit-tests")
+	db := setupLDAPTestDB(t)
+	tenant := model.Tenant{Name: "Acme", Code: "acme"}
+	if err := db.Create(&tenant).Error; err != nil {
+		t.Fatalf("create tenant failed: %v", err)
+	}
+	if err := db.Create(&model.TenantRbacRole{TenantID: tenant.ID, Code: "admin", Name: "Admin"}).Error; err != nil {
+		t.Fatalf("create role failed: %v", err)
+	}
+
+	ldapServer := newTestDirectory(t)
+	ldapServer.put(aliceDN, alicePassword, map[string][]string{
+		"objectClass": {"person"},
+		"uid":         {"alice"},
+		"cn":          {"Alice"},
+		"entryUUID":   {"0f1e2d3c-aaaa-bbbb-cccc-000000000001"},
+		"mail":        {"Alice@Example.com"},
+		"memberOf":    {adminsDN},
+	})
+	ldapServer.put(adminsDN, "", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"member":      {aliceDN},
+	})
+
+	in.URL = ptr(ldapServer.URL())
+	in.BindDN = ptr(testServiceDN)
+	in.BindPassword = ptr(testServicePassword)
+	in.BaseDN = ptr("dc=example,dc=com")
+	in.UserFilter = ptr("(&(objectClass=person)(uid={username}))")
+	in.GroupRoleMappings = &[]GroupRoleMapping{{Group: "CN=Admins,OU=Groups,DC=example,DC=com", Role: "admin"}}
+
+	svc := NewService(db)
+	if _, err := svc.SaveDirectory(tenant.ID, in); err != nil {
+		t.Fatalf("save directory failed: %v", err)
+	}
+	return svc, db, ldapServer, tenant.ID
+}
+
+func hasRole(t *testing.T, db *gorm.DB, userID uint, code string) bool {
+	t.Helper()
+	var role model.TenantRbacRole
+	if err := db.Where("code = ?", code).First(&role).Error; err != nil {
+		t.Fatalf("load role failed: %v", err)
+	}
+	var count int64
+	if err := db.Model(&model.TenantUserRbacRole{}).Where("user_id = ? AND role_id = ?", userID, role.ID).Count(&count).Error; err != nil {
+		t.Fatalf("count roles failed: %v", err)
+	}
+	return count > 0
+}
+
+func TestAuthenticateProvisionsUserAndMapsGroups(t *testing.T) {
+	svc, db, _, tenantID := newTestService(t, DirectoryInput{})
+
+	user, err := svc.Authenticate(tenantID, "alice", alicePassword, nil)
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	if user.TenantID != tenantID || user.Email != "alice@example.com" || !user.EmailVerified || user.Nickname != "Alice" {
+		t.Fatalf("unexpected provisioned user: %+v", user)
+	}
+	if user.PasswordHash != "" {
+		t.Fatalf("expected directory user to have no local password")
+	}
+	var link model.LDAPUserLink
+	if err := db.Where("user_id = ?", user.ID).First(&link).Error; err != nil {
+		t.Fatalf("expected directory link: %v", err)
+	}
+	if link.Subject != "0f1e2d3c-aaaa-bbbb-cccc-000000000001" || link.LastLoginAt == nil {
+		t.Fatalf("unexpected link: %+v", link)
+	}
+	if !hasRole(t, db, user.ID, "admin") {
+		t.Fatalf("expected memberOf mapping to grant admin role")
+	}
+
+	// 再次登录复用同一账号
+	again, err := svc.Authenticate(tenantID, "alice", alicePassword, nil)
+	if err != nil || again.ID != user.ID {
+		t.Fatalf("expected second login to reuse account, got %+v, %v", again, err)
+	}
+
+	if _, err := svc.Authenticate(tenantID, "alice", "wrong", nil); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := svc.Authenticate(tenantID, "alice", "", nil); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected empty password to be rejected, got %v", err)
+	}
+	if _, err := svc.Authenticate(tenantID, "bob", "whatever", nil); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	// 过滤器注入不能扩大匹配范围
+	if _, err := svc.Authenticate(tenantID, "*", alicePassword, nil); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected escaped wildcard to match nothing, got %v", err)
+	}
+}
+
+func countLinkAudits(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&model.AuditLog{}).Where("action = ?", "ldap_account_linked").Count(&count).Error; err != nil {
+		t.Fatalf("count audit logs failed: %v", err)
+	}
+	return count
+}
+
+func TestAuthenticateLinksPasswordlessAccount(t *testing.T) {
+	svc, db, _, tenantID := newTestService(t, DirectoryInput{})
+	local := model.User{TenantID: tenantID, Email: "alice@example.com", Nickname: "alice"}
+	if err := db.Create(&local).Error; err != nil {
+		t.Fatalf("create local user failed: %v", err)
+	}
+
+	user, err := svc.Authenticate(tenantID, "alice", alicePassword, nil)
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	if user.ID != local.ID {
+		t.Fatalf("expected existing account %d to be linked, got %d", local.ID, user.ID)
+	}
+	if countLinkAudits(t, db) != 1 {
+		t.Fatalf("expected linking to be audited")
+	}
+
+	db.Model(&model.User{}).Where("id = ?", local.ID).Update("banned", true)
+	if _, err := svc.Authenticate(tenantID, "alice", alicePassword, nil); !errors.Is(err, ErrUserBanned) {
+		t.Fatalf("expected ErrUserBanned, got %v", err)
+	}
+}
+
+func TestAuthenticateRequiresAdminLinkForProtectedAccounts(t *testing.T) {
+	svc, db, _, tenantID := newTestService(t, DirectoryInput{})
+
+	// 有本地密码的账号不自动绑定
+	local := model.User{TenantID: tenantID, Email: "alice@example.com", Nickname: "alice", PasswordHash: "local-hash"}
+	if err := db.Create(&local).Error; err != nil {
+		t.Fatalf("create local user failed: %v", err)
+	}
+	if _, err := svc.Authenticate(tenantID, "alice", alicePassword, nil); !errors.Is(err, ErrLinkRequired) {
+		t.Fatalf("expected ErrLinkRequired for account with a local password, got %v", err)
+	}
+
+	// 租户管理员即使没有本地密码也不自动绑定
+	db.Model(&model.User{}).Where("id = ?", local.ID).Update("password_hash", "")
+	if err := db.Create(&model.TenantUser{TenantID: tenantID, UserID: local.ID, Role: model.TenantRoleAdmin}).Error; err != nil {
+		t.Fatalf("create membership failed: %v", err)
+	}
+	if _, err := svc.Authenticate(tenantID, "alice", alicePassword, nil); !errors.Is(err, ErrLinkRequired) {
+		t.Fatalf("expected ErrLinkRequired for tenant admin, got %v", err)
+	}
+	if countLinkAudits(t, db) != 0 {
+		t.Fatalf("expected no link to be recorded")
+	}
+
+	// 管理员显式绑定后可以使用目录密码登录
+	db.Model(&model.User{}).Where("id = ?", local.ID).Update("password_hash", "local-hash")
+	if _, err := svc.LinkUser(tenantID, local.ID, "alice", 99); err != nil {
+		t.Fatalf("link user failed: %v", err)
+	}
+	var reloaded model.User
+	db.First(&reloaded, local.ID)
+	if reloaded.PasswordHash != "" {
+		t.Fatalf("expected local password to be cleared after linking")
+	}
+	var audit model.AuditLog
+	if err := db.Where("action = ?", "ldap_account_linked").First(&audit).Error; err != nil || audit.UserID != 99 {
+		t.Fatalf("expected link to be audited with the operator, got %+v, %v", audit, err)
+	}
+	user, err := svc.Authenticate(tenantID, "alice", alicePassword, nil)
+	if err != nil || user.ID != local.ID {
+		t.Fatalf("expected linked account to sign in, got %+v, %v", user, err)
+	}
+	if _, err := svc.LinkUser(tenantID, local.ID, "alice", 99); !errors.Is(err, ErrAlreadyLinked) {
+		t.Fatalf("expected ErrAlreadyLinked, got %v", err)
+	}
+	if _, err := svc.LinkUser(tenantID, local.ID+100, "alice", 99); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func TestAuthenticateWithoutProvisioning(t *testing.T) {
+	svc, db, _, tenantID := newTestService(t, DirectoryInput{AllowProvisioning: ptr(false)})
+
+	if _, err := svc.Authenticate(tenantID, "alice", alicePassword, nil); !errors.Is(err, ErrProvisioningDisabled) {
+		t.Fatalf("expected ErrProvisioningDisabled, got %v", err)
+	}
+	var count int64
+	db.Model(&model.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no account to be created, got %d", count)
+	}
+}
+
+// recordingGuard 记录失败次数，达到 limit 后拒绝登录
+type recordingGuard struct {
+	failures map[uint]int
+	limit    int
+}
+
+var errGuardLocked = errors.New("locked")
+
+func (g *recordingGuard) Check(userID uint) error {
+	if g.failures[userID] >= g.limit {
+		return errGuardLocked
+	}
+	return nil
+}
+
+func (g *recordingGuard) RecordFailure(userID uint) error {
+	g.failures[userID]++
+	return nil
+}
+
+func TestAuthenticateAppliesLoginGuard(t *testing.T) {
+	svc, _, _, tenantID := newTestService(t, DirectoryInput{})
+	guard := &recordingGuard{failures: map[uint]int{}, limit: 2}
+
+	// 尚无对应账号的条目不计失败
+	if _, err := svc.Authenticate(tenantID, "alice", "wrong", guard); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if len(guard.failures) != 0 {
+		t.Fatalf("expected no failure without a local account, got %v", guard.failures)
+	}
+
+	user, err := svc.Authenticate(tenantID, "alice", alicePassword, guard)
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Authenticate(tenantID, "alice", "wrong", guard); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+	if guard.failures[user.ID] != 2 {
+		t.Fatalf("expected directory rejections to be recorded, got %v", guard.failures)
+	}
+	// 锁定后即使密码正确也不再绑定
+	if _, err := svc.Authenticate(tenantID, "alice", alicePassword, guard); !errors.Is(err, errGuardLocked) {
+		t.Fatalf("expected guard to reject locked account, got %v", err)
+	}
+}
+
+func TestSyncDirectoryRevokesRoles(t *testing.T) {
+	svc, db, ldapServer, tenantID := newTestService(t, DirectoryInput{
+		GroupFilter: ptr("(&(objectClass=groupOfNames)(member={dn}))"),
+	})
+
+	user, err := svc.Authenticate(tenantID, "alice", alicePassword, nil)
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	if !hasRole(t, db, user.ID, "admin") {
+		t.Fatalf("expected group search to grant admin role")
+	}
+
+	// 目录中移出组并修改显示名后同步
+	ldapServer.setAttr(adminsDN, "member")
+	ldapServer.setAttr(aliceDN, "cn", "Alice Liddell")
+	result, err := svc.SyncDirectory(tenantID)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if result.Synced != 1 || result.Missing != 0 || result.Failed != 0 {
+		t.Fatalf("unexpected sync result: %+v", result)
+	}
+	if hasRole(t, db, user.ID, "admin") {
+		t.Fatalf("expected admin role to be revoked after leaving the group")
+	}
+	var reloaded model.User
+	db.First(&reloaded, user.ID)
+	if reloaded.Nickname != "Alice Liddell" {
+		t.Fatalf("expected nickname to be synced, got %q", reloaded.Nickname)
+	}
+
+	// 条目删除后计为缺失，账号保留
+	ldapServer.setAttr(adminsDN, "member", aliceDN)
+	if _, err := svc.Authenticate(tenantID, "alice", alicePassword, nil); err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	ldapServer.remove(aliceDN)
+	result, err = svc.SyncDirectory(tenantID)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if result.Missing != 1 {
+		t.Fatalf("expected missing entry, got %+v", result)
+	}
+	if hasRole(t, db, user.ID, "admin") {
+		t.Fatalf("expected mapped roles to be revoked for missing entry")
+	}
+	dir, _ := svc.GetDirectory(tenantID)
+	if dir.LastSyncAt == nil || dir.LastSyncError != "" {
+		t.Fatalf("expected sync status to be recorded: %+v", dir)
+	}
+}
+
+func TestSaveDirectoryValidation(t *testing.T) {
+	svc, _, _, tenantID := newTestService(t, DirectoryInput{})
+
+	cases := map[string]DirectoryInput{
+		"bad url":        {URL: ptr("http://ldap.example.com")},
+		"starttls ldaps": {URL: ptr("ldaps://ldap.example.com"), StartTLS: ptr(true)},
+		"no placeholder": {UserFilter: ptr("(uid=alice)")},
+		"bad filter":     {UserFilter: ptr("(uid={username}")},
+		"group filter":   {GroupFilter: ptr("(member=alice)")},
+		"sync interval":  {SyncIntervalMinutes: ptr(1)},
+		"unknown role":   {GroupRoleMappings: &[]GroupRoleMapping{{Group: adminsDN, Role: "owner"}}},
+	}
+	for name, in := range cases {
+		if _, err := svc.SaveDirectory(tenantID, in); !errors.Is(err, ErrInvalidDirectory) {
+			t.Fatalf("%s: expected ErrInvalidDirectory, got %v", name, err)
+		}
+	}
+
+	if err := svc.TestConnection(tenantID); err != nil {
+		t.Fatalf("test connection failed: %v", err)
+	}
+	if _, err := svc.SaveDirectory(tenantID, DirectoryInput{BindPassword: ptr("wrong")}); err != nil {
+		t.Fatalf("update bind password failed: %v", err)
+	}
+	if err := svc.TestConnection(tenantID); !errors.Is(err, ErrDirectoryUnavailable) {
+		t.Fatalf("expected ErrDirectoryUnavailable with wrong bind password, got %v", err)
+	}
+}
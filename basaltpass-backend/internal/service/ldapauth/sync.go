@@ -0,0 +1,130 @@
+package ldapauth
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// SyncResult 一次目录同步的统计
+type SyncResult struct {
+	Synced  int `json:"synced"`  // 已从目录刷新资料与组角色的账号
+	Missing int `json:"missing"` // 目录中已不存在的条目，其映射角色已撤销
+	Failed  int `json:"failed"`
+}
+
+// SyncDirectory 按目录刷新租户全部已绑定账号的资料与组角色。
+// 仅处理已登录过的账号，不会为目录中的其他条目创建账号；目录中已删除的条目保留账号，但撤销其映射角色。
+func (s *Service) SyncDirectory(tenantID uint) (*SyncResult, error) {
+	dir, err := s.enabledDirectory(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.syncDirectory(dir)
+
+	// 记录同步时间与结果，供管理界面展示
+	updates := map[string]interface{}{"last_sync_at": time.Now(), "last_sync_error": ""}
+	if err != nil {
+		updates["last_sync_error"] = utils.Truncate(err.Error(), 512)
+	}
+	if uerr := s.db.Model(dir).Updates(updates).Error; uerr != nil {
+		log.Printf("[ldap][error] record sync result for tenant %d failed: %v", tenantID, uerr)
+	}
+	return result, err
+}
+
+func (s *Service) syncDirectory(dir *model.LDAPDirectory) (*SyncResult, error) {
+	var links []model.LDAPUserLink
+	if err := s.db.Where("directory_id = ?", dir.ID).Order("id ASC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	result := &SyncResult{}
+	if len(links) == 0 {
+		return result, nil
+	}
+
+	conn, err := s.connect(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for i := range links {
+		link := &links[i]
+		e, err := s.findEntry(conn, dir, link.DN, ldap.ScopeBaseObject, "(objectClass=*)")
+		if err == nil && dir.GroupFilter != "" {
+			e.groups, err = s.searchGroups(conn, dir, e.dn)
+		}
+		switch {
+		case err == nil:
+			// 条目仍在但稳定标识变化（如目录重建）时视为不同用户，不做同步
+			if e.subject != link.Subject {
+				result.Missing++
+				err = s.revokeMappedRoles(dir, link.UserID)
+				break
+			}
+			_, err = s.upsertUser(dir, e, false)
+			if err == nil || errors.Is(err, ErrUserBanned) {
+				result.Synced++
+				err = nil
+			}
+		case errors.Is(err, ErrUserNotFound):
+			result.Missing++
+			err = s.revokeMappedRoles(dir, link.UserID)
+		case errors.Is(err, ErrDirectoryUnavailable):
+			// 连接中断时终止本次同步
+			return result, err
+		}
+		if err != nil {
+			result.Failed++
+			log.Printf("[ldap][warn] sync user %d of tenant %d failed: %v", link.UserID, dir.TenantID, err)
+		}
+	}
+	return result, nil
+}
+
+func (s *Service) revokeMappedRoles(dir *model.LDAPDirectory, userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.syncRoles(tx, dir, userID, nil)
+	})
+}
+
+// SyncDue 同步所有已到同步周期的目录
+func (s *Service) SyncDue() {
+	var dirs []model.LDAPDirectory
+	if err := s.db.Where("enabled = ? AND sync_interval_minutes > 0", true).Find(&dirs).Error; err != nil {
+		log.Printf("[ldap][error] load directories failed: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, dir := range dirs {
+		if dir.LastSyncAt != nil && now.Sub(*dir.LastSyncAt) < time.Duration(dir.SyncIntervalMinutes)*time.Minute {
+			continue
+		}
+		if result, err := s.SyncDirectory(dir.TenantID); err != nil {
+			log.Printf("[ldap][error] scheduled sync for tenant %d failed: %v", dir.TenantID, err)
+		} else if result.Missing > 0 || result.Failed > 0 {
+			log.Printf("[ldap][info] scheduled sync for tenant %d: %+v", dir.TenantID, *result)
+		}
+	}
+}
+
+// StartSyncScheduler 启动后台定时同步检查
+func StartSyncScheduler(s *Service, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.SyncDue()
+		}
+	}()
+}
@@ -0,0 +1,421 @@
+package lockout
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	emailservice "basaltpass-backend/internal/service/email"
+	settingssvc "basaltpass-backend/internal/service/settings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// unlockTokenTTL 邮件解锁链接有效期
+const unlockTokenTTL = 24 * time.Hour
+
+// LoginHistory 中锁定/解锁事件的状态值
+const (
+	HistoryStatusLocked   = "locked"
+	HistoryStatusUnlocked = "unlocked"
+)
+
+var (
+	// ErrAccountLocked 账号因登录失败次数过多被临时锁定
+	ErrAccountLocked = errors.New("account temporarily locked due to too many failed login attempts")
+	// ErrInvalidUnlockToken 解锁链接无效或已过期
+	ErrInvalidUnlockToken = errors.New("invalid or expired unlock link")
+)
+
+// LockedError 携带锁定截止时间的 ErrAccountLocked
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string { return ErrAccountLocked.Error() }
+
+func (e *LockedError) Unwrap() error { return ErrAccountLocked }
+
+// Policy 登录失败锁定策略
+type Policy struct {
+	Enabled     bool
+	MaxAttempts int
+	// Window 统计失败次数的窗口，同时是首次锁定的时长；之后每次锁定时长翻倍，最长 MaxLock
+	Window  time.Duration
+	MaxLock time.Duration
+}
+
+// LockDuration 第 lockCount 次（从 0 开始）锁定的时长
+func (p Policy) LockDuration(lockCount int) time.Duration {
+	d := p.Window
+	for i := 0; i < lockCount && d < p.MaxLock; i++ {
+		d *= 2
+	}
+	if d > p.MaxLock {
+		d = p.MaxLock
+	}
+	return d
+}
+
+// Service 账号登录失败锁定
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// PolicyFor 读取账号所属租户的锁定策略：系统设置 security.account_lockout.*，租户可在登录设置中覆盖。
+// 全局账号（tenant_id=0）只适用平台策略
+func (s *Service) PolicyFor(tenantID uint) Policy {
+	p := Policy{
+		Enabled:     settingssvc.GetBool("security.account_lockout.enabled", true),
+		MaxAttempts: settingssvc.GetInt("security.account_lockout.max_attempts", 5),
+		Window:      time.Duration(settingssvc.GetInt("security.account_lockout.window_minutes", 15)) * time.Minute,
+		MaxLock:     time.Duration(settingssvc.GetInt("security.account_lockout.max_lock_minutes", 1440)) * time.Minute,
+	}
+
+	if tenantID > 0 {
+		var setting model.TenantAuthSetting
+		if err := s.db.Select("lockout_mode", "lockout_max_attempts", "lockout_window_minutes").
+			Where("tenant_id = ?", tenantID).First(&setting).Error; err == nil {
+			switch setting.LockoutMode {
+			case model.LockoutModeEnabled:
+				p.Enabled = true
+			case model.LockoutModeDisabled:
+				p.Enabled = false
+			}
+			if setting.LockoutMaxAttempts > 0 {
+				p.MaxAttempts = setting.LockoutMaxAttempts
+			}
+			if setting.LockoutWindowMinutes > 0 {
+				p.Window = time.Duration(setting.LockoutWindowMinutes) * time.Minute
+			}
+		}
+	}
+
+	if p.MaxAttempts <= 0 || p.Window <= 0 {
+		p.Enabled = false
+	}
+	if p.MaxLock < p.Window {
+		p.MaxLock = p.Window
+	}
+	return p
+}
+
+// Check 账号处于锁定期时返回 *LockedError。已有的锁定不受策略开关影响，需等待到期或解锁
+func (s *Service) Check(userID uint) error {
+	var state model.AccountLockout
+	if err := s.db.Select("locked_until").Where("user_id = ?", userID).First(&state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if state.IsLocked() {
+		return &LockedError{Until: *state.LockedUntil}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次密码错误；达到策略的失败次数时锁定账号并返回 *LockedError。
+// 锁定状态按账号保存，策略按账号所属租户而非登录入口确定，避免某个租户的覆盖设置
+// 放开或收紧同一全局账号在其它租户的登录
+func (s *Service) RecordFailure(userID uint, ip, userAgent string) error {
+	var user model.User
+	if err := s.db.Select("id", "tenant_id").First(&user, userID).Error; err != nil {
+		return err
+	}
+	tenantID := user.TenantID
+	policy := s.PolicyFor(tenantID)
+	if !policy.Enabled {
+		return nil
+	}
+
+	// 1. 确保存在状态记录
+	state := model.AccountLockout{UserID: userID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error; err != nil {
+		return err
+	}
+
+	// 2. 窗口过期则从 1 重新计数，否则原子递增
+	now := time.Now()
+	res := s.db.Model(&model.AccountLockout{}).
+		Where("user_id = ? AND (window_started_at IS NULL OR window_started_at < ?)", userID, now.Add(-policy.Window)).
+		Updates(map[string]interface{}{
+			"failed_count":      1,
+			"window_started_at": now,
+			"last_failed_at":    now,
+			"last_failed_ip":    ip,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := s.db.Model(&model.AccountLockout{}).Where("user_id = ?", userID).
+			Updates(map[string]interface{}{
+				"failed_count":   gorm.Expr("failed_count + 1"),
+				"last_failed_at": now,
+				"last_failed_ip": ip,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	// 3. 达到上限时锁定；以条件更新保证并发请求只锁定一次
+	if err := s.db.Where("user_id = ?", userID).First(&state).Error; err != nil {
+		return err
+	}
+	if state.FailedCount < policy.MaxAttempts {
+		return nil
+	}
+	until := now.Add(policy.LockDuration(state.LockCount))
+	res = s.db.Model(&model.AccountLockout{}).
+		Where("user_id = ? AND failed_count >= ?", userID, policy.MaxAttempts).
+		Updates(map[string]interface{}{
+			"failed_count":      0,
+			"window_started_at": nil,
+			"lock_count":        gorm.Expr("lock_count + 1"),
+			"locked_until":      until,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return s.Check(userID)
+	}
+
+	s.recordEvent(userID, userID, HistoryStatusLocked, "account_locked", ip, userAgent, map[string]interface{}{
+		"tenant_id":    tenantID,
+		"lock_count":   state.LockCount + 1,
+		"locked_until": until,
+	})
+	go func() {
+		if err := s.SendUnlockEmail(userID); err != nil {
+			log.Printf("[lockout][warn] send unlock email to user %d failed: %v", userID, err)
+		}
+	}()
+	return &LockedError{Until: until}
+}
+
+// RecordSuccess 登录成功后清零失败次数与退避等级
+func (s *Service) RecordSuccess(userID uint) error {
+	return s.db.Model(&model.AccountLockout{}).
+		Where("user_id = ? AND (failed_count > 0 OR lock_count > 0)", userID).
+		Updates(map[string]interface{}{
+			"failed_count":      0,
+			"window_started_at": nil,
+			"lock_count":        0,
+		}).Error
+}
+
+// Unlock 管理员解锁账号
+func (s *Service) Unlock(userID, operatorID uint, ip, userAgent string) error {
+	res := s.db.Model(&model.AccountLockout{}).Where("user_id = ?", userID).Updates(unlockUpdates())
+	if res.Error != nil {
+		return res.Error
+	}
+	s.recordEvent(userID, operatorID, HistoryStatusUnlocked, "admin_unlock_account", ip, userAgent, map[string]interface{}{
+		"target_user_id": userID,
+	})
+	return nil
+}
+
+// UnlockWithToken 通过邮件中的解锁链接解锁账号
+func (s *Service) UnlockWithToken(token, ip, userAgent string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidUnlockToken
+	}
+	var state model.AccountLockout
+	if err := s.db.Where("unlock_token_hash = ?", hashToken(token)).First(&state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidUnlockToken
+		}
+		return err
+	}
+	if state.UnlockTokenExpiresAt == nil || time.Now().After(*state.UnlockTokenExpiresAt) {
+		return ErrInvalidUnlockToken
+	}
+
+	// 链接只能使用一次
+	res := s.db.Model(&model.AccountLockout{}).
+		Where("id = ? AND unlock_token_hash = ?", state.ID, state.UnlockTokenHash).
+		Updates(unlockUpdates())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidUnlockToken
+	}
+	s.recordEvent(state.UserID, state.UserID, HistoryStatusUnlocked, "unlock_account_by_email", ip, userAgent, nil)
+	return nil
+}
+
+// States 批量读取账号的锁定状态，供管理端列表展示
+func (s *Service) States(userIDs []uint) (map[uint]model.AccountLockout, error) {
+	states := make(map[uint]model.AccountLockout, len(userIDs))
+	if len(userIDs) == 0 {
+		return states, nil
+	}
+	var rows []model.AccountLockout
+	if err := s.db.Where("user_id IN ?", userIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		states[row.UserID] = row
+	}
+	return states, nil
+}
+
+// SendUnlockEmail 为锁定中的账号生成解锁链接并发送到账号邮箱
+func (s *Service) SendUnlockEmail(userID uint) error {
+	var user model.User
+	if err := s.db.Select("id", "email").First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return nil
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(tokenBytes)
+	expiresAt := time.Now().Add(unlockTokenTTL)
+	res := s.db.Model(&model.AccountLockout{}).
+		Where("user_id = ? AND locked_until > ?", userID, time.Now()).
+		Updates(map[string]interface{}{
+			"unlock_token_hash":       hashToken(token),
+			"unlock_token_expires_at": expiresAt,
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	link := strings.TrimRight(config.Get().UI.BaseURL, "/") + "/auth/unlock?" + url.Values{"token": {token}}.Encode()
+	return s.sendUnlockEmail(user, link)
+}
+
+// recordEvent 在登录历史与审计日志中记录锁定/解锁
+func (s *Service) recordEvent(userID, actorID uint, historyStatus, action, ip, userAgent string, data map[string]interface{}) {
+	if err := s.db.Create(&model.LoginHistory{
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Status:    historyStatus,
+	}).Error; err != nil {
+		log.Printf("[lockout][warn] record login history for user %d failed: %v", userID, err)
+	}
+
+	payload := map[string]interface{}{"target_user_id": userID}
+	for k, v := range data {
+		payload[k] = v
+	}
+	payloadBytes, _ := json.Marshal(payload)
+	if err := s.db.Create(&model.AuditLog{
+		UserID:    actorID,
+		Action:    action,
+		IP:        ip,
+		UserAgent: userAgent,
+		Data:      string(payloadBytes),
+	}).Error; err != nil {
+		log.Printf("[lockout][warn] record audit log for user %d failed: %v", userID, err)
+	}
+}
+
+func (s *Service) sendUnlockEmail(user model.User, link string) error {
+	textBody := fmt.Sprintf(`
+亲爱的用户，
+
+由于连续多次登录失败，您的 BasaltPass 账户已被临时锁定。
+
+如果是您本人操作，可以点击以下链接立即解锁（24 小时内有效，仅可使用一次）：
+%s
+
+如果不是您本人操作，说明有人正在尝试登录您的账户，建议您解锁后立即修改密码并启用两步验证。
+
+祝好，
+BasaltPass 团队
+`, link)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>账户已被临时锁定</title>
+</head>
+<body style="margin:0;padding:0;background-color:#f5f5f7;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;color:#1d1d1f;">
+    <div style="max-width:640px;margin:0 auto;padding:40px 20px;">
+        <div style="background-color:#ffffff;border-radius:28px;overflow:hidden;box-shadow:0 10px 34px rgba(0,0,0,0.035);">
+            <div style="padding:32px 36px 22px;">
+                <div style="font-size:12px;line-height:18px;font-weight:600;letter-spacing:0.12em;text-transform:uppercase;color:#86868b;">BasaltPass</div>
+                <h1 style="margin:14px 0 0;font-size:28px;line-height:36px;font-weight:600;color:#1d1d1f;">账户已被临时锁定</h1>
+            </div>
+            <div style="padding:8px 36px 36px;">
+                <p style="margin:0 0 24px;font-size:15px;line-height:26px;color:#424245;">
+                    由于连续多次登录失败，您的账户已被临时锁定。如果是您本人操作，可以点击下方按钮立即解锁。
+                </p>
+                <p style="margin:0 0 24px;">
+                    <a href="%s" style="display:inline-block;padding:14px 28px;border-radius:980px;background-color:#0071e3;color:#ffffff;font-size:16px;font-weight:600;text-decoration:none;">解锁账户</a>
+                </p>
+                <div style="padding:18px 20px;border-radius:18px;background-color:#fff4e5;color:#8a4b00;font-size:14px;line-height:24px;">
+                    链接 24 小时内有效，仅可使用一次。如果不是您本人操作，建议解锁后立即修改密码并启用两步验证。
+                </div>
+            </div>
+            <div style="padding:20px 36px;background-color:#fbfbfd;">
+                <p style="margin:0;font-size:12px;line-height:20px;color:#8d8d92;">
+                    此邮件由 BasaltPass 系统自动发送，请勿直接回复
+                </p>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`, link)
+
+	msg := &emailservice.Message{
+		To:       []string{user.Email},
+		Subject:  "🔐 BasaltPass 账户锁定通知",
+		TextBody: textBody,
+		HTMLBody: htmlBody,
+	}
+	emailSvc, err := emailservice.NewServiceFromConfig(config.Get())
+	if err != nil {
+		return err
+	}
+	userID := user.ID
+	_, err = emailSvc.SendWithLogging(context.Background(), msg, &userID, "account_lockout")
+	return err
+}
+
+func unlockUpdates() map[string]interface{} {
+	return map[string]interface{}{
+		"failed_count":            0,
+		"window_started_at":       nil,
+		"lock_count":              0,
+		"locked_until":            nil,
+		"unlock_token_hash":       "",
+		"unlock_token_expires_at": nil,
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
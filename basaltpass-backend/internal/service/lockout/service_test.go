@@ -0,0 +1,204 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupLockoutTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.User{},
+		&model.TenantAuthSetting{},
+		&model.AccountLockout{},
+		&model.LoginHistory{},
+		&model.AuditLog{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	common.SetDBForTest(db)
+	return db
+}
+
+// newLockoutTestTenant 创建租户登录设置（3 次失败锁定，窗口 10 分钟）及该租户的账号（ID 为 1）
+func newLockoutTestTenant(t *testing.T, db *gorm.DB, tenantID uint, mode string) {
+	t.Helper()
+	if err := db.Create(&model.User{TenantID: tenantID, Email: "alice@example.com"}).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	setting := model.TenantAuthSetting{
+		TenantID:             tenantID,
+		AllowLogin:           true,
+		LockoutMode:          mode,
+		LockoutMaxAttempts:   3,
+		LockoutWindowMinutes: 10,
+	}
+	if err := db.Create(&setting).Error; err != nil {
+		t.Fatalf("create auth setting failed: %v", err)
+	}
+}
+
+func failTimes(t *testing.T, svc *Service, userID uint, n int) error {
+	t.Helper()
+	var err error
+	for i := 0; i < n; i++ {
+		err = svc.RecordFailure(userID, "10.0.0.1", "test-agent")
+	}
+	return err
+}
+
+func TestLockAfterMaxAttemptsWithBackoff(t *testing.T) {
+	db := setupLockoutTestDB(t)
+	svc := NewService(db)
+	newLockoutTestTenant(t, db, 7, model.LockoutModeEnabled)
+
+	if err := failTimes(t, svc, 1, 2); err != nil {
+		t.Fatalf("expected no lock below max attempts, got %v", err)
+	}
+	err := failTimes(t, svc, 1, 1)
+	var lockedErr *LockedError
+	if !errors.As(err, &lockedErr) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected LockedError, got %v", err)
+	}
+	first := time.Until(lockedErr.Until)
+	if first < 9*time.Minute || first > 10*time.Minute {
+		t.Fatalf("expected first lock to last the window, got %v", first)
+	}
+	if err := svc.Check(1); !errors.As(err, &lockedErr) {
+		t.Fatalf("expected Check to report the lock, got %v", err)
+	}
+
+	// 锁定到期后再次锁定，时长翻倍
+	db.Model(&model.AccountLockout{}).Where("user_id = ?", 1).Update("locked_until", time.Now().Add(-time.Second))
+	if err := svc.Check(1); err != nil {
+		t.Fatalf("expected expired lock to be lifted, got %v", err)
+	}
+	if err := failTimes(t, svc, 1, 3); !errors.As(err, &lockedErr) {
+		t.Fatalf("expected second lock, got %v", err)
+	}
+	second := time.Until(lockedErr.Until)
+	if second < 19*time.Minute || second > 20*time.Minute {
+		t.Fatalf("expected second lock to double, got %v", second)
+	}
+
+	var history int64
+	db.Model(&model.LoginHistory{}).Where("user_id = ? AND status = ?", 1, HistoryStatusLocked).Count(&history)
+	if history != 2 {
+		t.Fatalf("expected 2 locked login history records, got %d", history)
+	}
+	var audits int64
+	db.Model(&model.AuditLog{}).Where("action = ?", "account_locked").Count(&audits)
+	if audits != 2 {
+		t.Fatalf("expected 2 account_locked audit logs, got %d", audits)
+	}
+}
+
+func TestFailureWindowAndSuccessReset(t *testing.T) {
+	db := setupLockoutTestDB(t)
+	svc := NewService(db)
+	newLockoutTestTenant(t, db, 7, model.LockoutModeInherit)
+
+	failTimes(t, svc, 1, 2)
+	// 窗口过期后重新计数
+	db.Model(&model.AccountLockout{}).Where("user_id = ?", 1).Update("window_started_at", time.Now().Add(-11*time.Minute))
+	if err := failTimes(t, svc, 1, 2); err != nil {
+		t.Fatalf("expected window to restart counting, got %v", err)
+	}
+
+	if err := svc.RecordSuccess(1); err != nil {
+		t.Fatalf("record success failed: %v", err)
+	}
+	var state model.AccountLockout
+	db.Where("user_id = ?", 1).First(&state)
+	if state.FailedCount != 0 || state.LockCount != 0 {
+		t.Fatalf("expected counters to be reset: %+v", state)
+	}
+}
+
+func TestTenantOverrideDisablesLockout(t *testing.T) {
+	db := setupLockoutTestDB(t)
+	svc := NewService(db)
+	newLockoutTestTenant(t, db, 7, model.LockoutModeDisabled)
+
+	if policy := svc.PolicyFor(7); policy.Enabled {
+		t.Fatalf("expected tenant override to disable lockout")
+	}
+	if err := failTimes(t, svc, 1, 10); err != nil {
+		t.Fatalf("expected no lock when disabled, got %v", err)
+	}
+	var count int64
+	db.Model(&model.AccountLockout{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no lockout state, got %d", count)
+	}
+
+	// 全局账号只适用平台策略（默认 5 次），不受登录入口租户的覆盖影响
+	global := model.User{Email: "global@example.com"}
+	if err := db.Create(&global).Error; err != nil {
+		t.Fatalf("create global user failed: %v", err)
+	}
+	if err := failTimes(t, svc, global.ID, 4); err != nil {
+		t.Fatalf("expected no lock below the platform limit, got %v", err)
+	}
+	if err := failTimes(t, svc, global.ID, 1); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected global account to lock under the platform policy, got %v", err)
+	}
+}
+
+func TestUnlockWithTokenAndAdminUnlock(t *testing.T) {
+	db := setupLockoutTestDB(t)
+	svc := NewService(db)
+	newLockoutTestTenant(t, db, 7, model.LockoutModeEnabled)
+
+	if err := failTimes(t, svc, 1, 3); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected lock, got %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	db.Model(&model.AccountLockout{}).Where("user_id = ?", 1).Updates(map[string]interface{}{
+		"unlock_token_hash":       hashToken("known-token"),
+		"unlock_token_expires_at": expiresAt,
+	})
+
+	if err := svc.UnlockWithToken("wrong-token", "", ""); !errors.Is(err, ErrInvalidUnlockToken) {
+		t.Fatalf("expected ErrInvalidUnlockToken, got %v", err)
+	}
+	if err := svc.UnlockWithToken("known-token", "10.0.0.2", "browser"); err != nil {
+		t.Fatalf("unlock with token failed: %v", err)
+	}
+	if err := svc.Check(1); err != nil {
+		t.Fatalf("expected account to be unlocked, got %v", err)
+	}
+	// 解锁链接只能使用一次
+	if err := svc.UnlockWithToken("known-token", "", ""); !errors.Is(err, ErrInvalidUnlockToken) {
+		t.Fatalf("expected unlock token to be single use, got %v", err)
+	}
+
+	failTimes(t, svc, 1, 3)
+	if err := svc.Unlock(1, 99, "10.0.0.3", "admin-console"); err != nil {
+		t.Fatalf("admin unlock failed: %v", err)
+	}
+	if err := svc.Check(1); err != nil {
+		t.Fatalf("expected account to be unlocked by admin, got %v", err)
+	}
+
+	var history int64
+	db.Model(&model.LoginHistory{}).Where("user_id = ? AND status = ?", 1, HistoryStatusUnlocked).Count(&history)
+	if history != 2 {
+		t.Fatalf("expected 2 unlocked login history records, got %d", history)
+	}
+	var audit model.AuditLog
+	if err := db.Where("action = ?", "admin_unlock_account").First(&audit).Error; err != nil || audit.UserID != 99 {
+		t.Fatalf("expected admin unlock audit log, got %+v, %v", audit, err)
+	}
+}
@@ -0,0 +1,353 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/config"
+	"basaltpass-backend/internal/model"
+	emailservice "basaltpass-backend/internal/service/email"
+	notif "basaltpass-backend/internal/service/notification"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// RecoveryCodeCount 每次生成的恢复码数量
+	RecoveryCodeCount = 10
+	// recoveryCodeLength 恢复码长度（不含分隔符），展示为 xxxxx-xxxxx
+	recoveryCodeLength = 10
+	// recoveryCodeCharset 去掉易混淆字符（0/o、1/l/i）的字母表
+	recoveryCodeCharset = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrUserNotFound         = errors.New("用户不存在")
+	ErrReverificationFailed = errors.New("身份验证失败，请输入正确的密码或验证码")
+	ErrReasonRequired       = errors.New("请填写重置原因")
+)
+
+// Service 两步验证恢复码与管理员重置
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService 创建服务；通知邮件服务只在重置时按需创建
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// GenerateRecoveryCodes 为用户在指定租户下生成一组新的恢复码并替换旧的，返回明文（仅此一次可见）
+func (s *Service) GenerateRecoveryCodes(userID, tenantID uint) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]model.UserRecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, model.UserRecoveryCode{
+			UserID:   userID,
+			TenantID: tenantID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND tenant_id = ?", userID, tenantID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ConsumeRecoveryCode 校验并消费一个恢复码；每个恢复码只能使用一次
+func (s *Service) ConsumeRecoveryCode(userID, tenantID uint, code string) (bool, error) {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return false, nil
+	}
+	now := time.Now()
+	res := s.db.Model(&model.UserRecoveryCode{}).
+		Where("user_id = ? AND tenant_id = ? AND code_hash = ? AND used_at IS NULL", userID, tenantID, hashRecoveryCode(normalized)).
+		Update("used_at", &now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// RemainingRecoveryCodes 返回未使用的恢复码数量
+func (s *Service) RemainingRecoveryCodes(userID, tenantID uint) (int64, error) {
+	var count int64
+	err := s.db.Model(&model.UserRecoveryCode{}).
+		Where("user_id = ? AND tenant_id = ? AND used_at IS NULL", userID, tenantID).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteRecoveryCodes 删除用户在指定租户下的全部恢复码（关闭两步验证时调用）
+func (s *Service) DeleteRecoveryCodes(userID, tenantID uint) error {
+	return s.db.Where("user_id = ? AND tenant_id = ?", userID, tenantID).Delete(&model.UserRecoveryCode{}).Error
+}
+
+// VerifyOperator 管理员执行敏感操作前重新验证身份：有本地密码的账号验证密码，
+// 否则（目录/联合登录账号）验证其在 tenantID 下的 TOTP 验证码
+func (s *Service) VerifyOperator(operatorID, tenantID uint, password, code string) error {
+	var operator model.User
+	if err := s.db.Select("id", "password_hash").First(&operator, operatorID).Error; err != nil {
+		return ErrReverificationFailed
+	}
+	if operator.PasswordHash != "" {
+		if password == "" || bcrypt.CompareHashAndPassword([]byte(operator.PasswordHash), []byte(password)) != nil {
+			return ErrReverificationFailed
+		}
+		return nil
+	}
+
+	var totpCfg model.UserTenantTOTP
+	if err := s.db.Where("user_id = ? AND tenant_id = ? AND enabled = ?", operatorID, tenantID, true).
+		First(&totpCfg).Error; err != nil {
+		return ErrReverificationFailed
+	}
+	rawSecret, err := utils.DecryptTOTPSecret(totpCfg.Secret)
+	if err != nil || rawSecret == "" || code == "" || !totp.Validate(code, rawSecret) {
+		return ErrReverificationFailed
+	}
+	return nil
+}
+
+// ResetParams 管理员重置用户两步验证的参数
+type ResetParams struct {
+	UserID     uint
+	OperatorID uint
+	// TenantID 为空表示重置用户在所有租户下的两步验证（平台管理员）；否则仅重置该租户
+	TenantID *uint
+	// RemovePasskeys 同时移除通行密钥（用户设备丢失时）
+	RemovePasskeys bool
+	Reason         string
+	IP             string
+	UserAgent      string
+}
+
+// ResetResult 重置结果
+type ResetResult struct {
+	TOTPDisabled         int64 `json:"totp_disabled"`
+	RecoveryCodesDeleted int64 `json:"recovery_codes_deleted"`
+	PasskeysRemoved      int64 `json:"passkeys_removed"`
+}
+
+// ResetMFA 关闭用户的 TOTP、删除恢复码（可选移除通行密钥），记录审计日志并通知用户
+func (s *Service) ResetMFA(params ResetParams) (*ResetResult, error) {
+	reason := strings.TrimSpace(params.Reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var user model.User
+	if err := s.db.Select("id", "email", "nickname").First(&user, params.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	scope := func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Where("user_id = ?", params.UserID)
+		if params.TenantID != nil {
+			tx = tx.Where("tenant_id = ?", *params.TenantID)
+		}
+		return tx
+	}
+
+	result := &ResetResult{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 1. 关闭 TOTP 并清空密钥
+		res := tx.Model(&model.UserTenantTOTP{}).Scopes(scope).Where("enabled = ? OR secret <> ''", true).
+			Updates(map[string]interface{}{
+				"enabled":    false,
+				"secret":     "",
+				"enabled_at": nil,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.TOTPDisabled = res.RowsAffected
+
+		// 2. 删除恢复码
+		res = tx.Scopes(scope).Delete(&model.UserRecoveryCode{})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.RecoveryCodesDeleted = res.RowsAffected
+
+		// 3. 可选：移除通行密钥
+		if params.RemovePasskeys {
+			res = tx.Scopes(scope).Delete(&model.Passkey{})
+			if res.Error != nil {
+				return res.Error
+			}
+			result.PasskeysRemoved = res.RowsAffected
+		}
+
+		// 4. 审计日志
+		payload := map[string]interface{}{
+			"target_user_id":         params.UserID,
+			"reason":                 reason,
+			"totp_disabled":          result.TOTPDisabled,
+			"recovery_codes_deleted": result.RecoveryCodesDeleted,
+			"passkeys_removed":       result.PasskeysRemoved,
+		}
+		if params.TenantID != nil {
+			payload["tenant_id"] = *params.TenantID
+		}
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&model.AuditLog{
+			UserID:    params.OperatorID,
+			Action:    "admin_reset_mfa",
+			IP:        params.IP,
+			UserAgent: params.UserAgent,
+			Data:      string(payloadBytes),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. 通知用户（站内通知 + 邮件）
+	go notif.Send(
+		"安全中心",
+		"两步验证已被管理员重置",
+		"管理员已重置您账户的两步验证，请尽快登录并重新启用两步验证。如果您未申请此操作，请立即修改密码并联系管理员。",
+		"warning",
+		nil,
+		"系统",
+		[]uint{user.ID},
+	)
+	if user.Email != "" {
+		go func() {
+			if err := s.sendResetNotificationEmail(user); err != nil {
+				log.Printf("[mfa][warn] send reset notification to user %d failed: %v", user.ID, err)
+			}
+		}()
+	}
+	return result, nil
+}
+
+// sendResetNotificationEmail 发送两步验证被重置的通知邮件
+func (s *Service) sendResetNotificationEmail(user model.User) error {
+	emailSvc, err := emailservice.NewServiceFromConfig(config.Get())
+	if err != nil {
+		return fmt.Errorf("email service unavailable: %w", err)
+	}
+
+	textBody := fmt.Sprintf(`
+亲爱的用户，
+
+管理员已于 %s 重置了您 BasaltPass 账户的两步验证，原有的身份验证器、恢复码均已失效。
+
+请尽快登录并在安全设置中重新启用两步验证。
+
+如果您没有申请重置，请立即：
+1. 修改账户密码
+2. 检查账户其他安全设置
+3. 联系管理员
+
+祝好，
+BasaltPass 团队
+`, time.Now().Format("2006-01-02 15:04"))
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>两步验证已重置</title>
+</head>
+<body style="margin:0;padding:0;background-color:#f5f5f7;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;color:#1d1d1f;">
+    <div style="max-width:640px;margin:0 auto;padding:40px 20px;">
+        <div style="background-color:#ffffff;border-radius:28px;overflow:hidden;box-shadow:0 10px 34px rgba(0,0,0,0.035);">
+            <div style="padding:32px 36px 22px;">
+                <div style="font-size:12px;line-height:18px;font-weight:600;letter-spacing:0.12em;text-transform:uppercase;color:#86868b;">BasaltPass</div>
+                <h1 style="margin:14px 0 0;font-size:28px;line-height:36px;font-weight:600;color:#1d1d1f;">两步验证已被重置</h1>
+            </div>
+            <div style="padding:8px 36px 36px;">
+                <p style="margin:0 0 16px;font-size:15px;line-height:26px;color:#424245;">
+                    管理员已于 <strong style="color:#1d1d1f;">%s</strong> 重置了您账户的两步验证，原有的身份验证器与恢复码均已失效。
+                </p>
+                <p style="margin:0 0 24px;font-size:15px;line-height:26px;color:#424245;">
+                    请尽快登录并在安全设置中重新启用两步验证。
+                </p>
+                <div style="padding:18px 20px;border-radius:18px;background-color:#fff4e5;color:#8a4b00;font-size:14px;line-height:24px;">
+                    如果您没有申请重置，请立即修改密码并联系管理员。
+                </div>
+            </div>
+            <div style="padding:20px 36px;background-color:#fbfbfd;">
+                <p style="margin:0;font-size:12px;line-height:20px;color:#8d8d92;">
+                    此邮件由 BasaltPass 系统自动发送，请勿直接回复
+                </p>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`, time.Now().Format("2006-01-02 15:04"))
+
+	msg := &emailservice.Message{
+		To:       []string{user.Email},
+		Subject:  "🔐 BasaltPass 两步验证重置通知",
+		TextBody: textBody,
+		HTMLBody: htmlBody,
+	}
+	userID := user.ID
+	_, err = emailSvc.SendWithLogging(context.Background(), msg, &userID, "mfa_reset_notification")
+	return err
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	max := big.NewInt(int64(len(recoveryCodeCharset)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = recoveryCodeCharset[n.Int64()]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空白与分隔符
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package mfa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func setupMFATestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.User{},
+		&model.UserTenantTOTP{},
+		&model.UserRecoveryCode{},
+		&model.Passkey{},
+		&model.AuditLog{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	// 站内通知在后台读取全局连接
+	common.SetDBForTest(db)
+	return db
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	db := setupMFATestDB(t)
+	svc := &Service{db: db}
+
+	codes, err := svc.GenerateRecoveryCodes(1, 7)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	var stored model.UserRecoveryCode
+	db.First(&stored)
+	if strings.Contains(stored.CodeHash, codes[0]) || len(stored.CodeHash) != 64 {
+		t.Fatalf("expected codes to be stored hashed")
+	}
+
+	// 忽略大小写与分隔符
+	ok, err := svc.ConsumeRecoveryCode(1, 7, strings.ToUpper(strings.ReplaceAll(codes[0], "-", " ")))
+	if err != nil || !ok {
+		t.Fatalf("expected recovery code to be accepted, got %v, %v", ok, err)
+	}
+	if ok, _ := svc.ConsumeRecoveryCode(1, 7, codes[0]); ok {
+		t.Fatalf("expected recovery code to be single use")
+	}
+	if ok, _ := svc.ConsumeRecoveryCode(1, 0, codes[1]); ok {
+		t.Fatalf("expected recovery code to be bound to its tenant")
+	}
+	if remaining, _ := svc.RemainingRecoveryCodes(1, 7); remaining != RecoveryCodeCount-1 {
+		t.Fatalf("expected %d remaining codes, got %d", RecoveryCodeCount-1, remaining)
+	}
+
+	// 重新生成后旧恢复码失效
+	if _, err := svc.GenerateRecoveryCodes(1, 7); err != nil {
+		t.Fatalf("regenerate failed: %v", err)
+	}
+	if ok, _ := svc.ConsumeRecoveryCode(1, 7, codes[1]); ok {
+		t.Fatalf("expected old recovery codes to be invalidated")
+	}
+	if remaining, _ := svc.RemainingRecoveryCodes(1, 7); remaining != RecoveryCodeCount {
+		t.Fatalf("expected a full new set, got %d", remaining)
+	}
+}
+
+func TestVerifyOperatorRequiresPassword(t *testing.T) {
+	db := setupMFATestDB(t)
+	svc := &Service{db: db}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("admin-secret"), bcrypt.MinCost)
+	operator := model.User{Email: "admin@example.com", PasswordHash: string(hash)}
+	db.Create(&operator)
+	directoryOperator := model.User{Email: "dir@example.com"}
+	db.Create(&directoryOperator)
+
+	if err := svc.VerifyOperator(operator.ID, 0, "admin-secret", ""); err != nil {
+		t.Fatalf("expected password re-verification to pass, got %v", err)
+	}
+	if err := svc.VerifyOperator(operator.ID, 0, "wrong", ""); !errors.Is(err, ErrReverificationFailed) {
+		t.Fatalf("expected ErrReverificationFailed, got %v", err)
+	}
+	// 无本地密码且未启用 TOTP 的操作者无法通过验证
+	if err := svc.VerifyOperator(directoryOperator.ID, 0, "", "123456"); !errors.Is(err, ErrReverificationFailed) {
+		t.Fatalf("expected ErrReverificationFailed without TOTP, got %v", err)
+	}
+}
+
+func TestResetMFAScopedToTenant(t *testing.T) {
+	db := setupMFATestDB(t)
+	svc := &Service{db: db}
+
+	user := model.User{Email: "alice@example.com"}
+	db.Create(&user)
+	for _, tenantID := range []uint{0, 7} {
+		db.Create(&model.UserTenantTOTP{UserID: user.ID, TenantID: tenantID, Secret: "enc:v1:secret", Enabled: true})
+		if _, err := svc.GenerateRecoveryCodes(user.ID, tenantID); err != nil {
+			t.Fatalf("generate failed: %v", err)
+		}
+	}
+
+	tenantID := uint(7)
+	if _, err := svc.ResetMFA(ResetParams{UserID: user.ID, OperatorID: 99, TenantID: &tenantID}); !errors.Is(err, ErrReasonRequired) {
+		t.Fatalf("expected ErrReasonRequired, got %v", err)
+	}
+	result, err := svc.ResetMFA(ResetParams{UserID: user.ID, OperatorID: 99, TenantID: &tenantID, Reason: "lost phone"})
+	if err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if result.TOTPDisabled != 1 || result.RecoveryCodesDeleted != RecoveryCodeCount {
+		t.Fatalf("unexpected reset result: %+v", result)
+	}
+
+	var reset, untouched model.UserTenantTOTP
+	db.Where("user_id = ? AND tenant_id = ?", user.ID, 7).First(&reset)
+	db.Where("user_id = ? AND tenant_id = ?", user.ID, 0).First(&untouched)
+	if reset.Enabled || reset.Secret != "" {
+		t.Fatalf("expected tenant TOTP to be disabled: %+v", reset)
+	}
+	if !untouched.Enabled {
+		t.Fatalf("expected other tenant TOTP to be kept")
+	}
+	if remaining, _ := svc.RemainingRecoveryCodes(user.ID, 0); remaining != RecoveryCodeCount {
+		t.Fatalf("expected other tenant recovery codes to be kept, got %d", remaining)
+	}
+
+	var audit model.AuditLog
+	if err := db.Where("action = ?", "admin_reset_mfa").First(&audit).Error; err != nil {
+		t.Fatalf("expected audit log: %v", err)
+	}
+	if audit.UserID != 99 || !strings.Contains(audit.Data, "lost phone") {
+		t.Fatalf("unexpected audit log: %+v", audit)
+	}
+
+	if _, err := svc.ResetMFA(ResetParams{UserID: 12345, OperatorID: 99, Reason: "x"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
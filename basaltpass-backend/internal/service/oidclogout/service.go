@@ -0,0 +1,172 @@
+package oidclogout
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/signingkey"
+	"basaltpass-backend/internal/service/subject"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// LogoutTokenType 登出令牌的 typ 头（OIDC Back-Channel Logout §2.4）
+const LogoutTokenType = "logout+jwt"
+
+// BackchannelLogoutEvent 登出令牌 events 声明中的事件类型
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutTokenTTL 登出令牌有效期，仅用于一次性投递
+const logoutTokenTTL = 2 * time.Minute
+
+// httpClient 向客户端登记的 backchannel_logout_uri 投递注销令牌，只允许连接公网地址
+var httpClient = utils.NewPublicHTTPClient(5 * time.Second)
+
+// Sender 将登出令牌投递到客户端的 backchannel_logout_uri
+type Sender func(uri, logoutToken string) error
+
+// Service 向用户授权过的应用分发登出通知（OIDC Back-Channel / Front-Channel Logout）
+type Service struct {
+	db     *gorm.DB
+	keys   *signingkey.Service
+	sender Sender
+}
+
+// NewService 创建登出通知服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:     db,
+		keys:   signingkey.NewService(db),
+		sender: postLogoutToken,
+	}
+}
+
+// WithSender 替换登出令牌的投递方式（测试使用）
+func (s *Service) WithSender(sender Sender) *Service {
+	s.sender = sender
+	return s
+}
+
+// Clients 返回用户授权过（AppUser）且配置了登出地址的活跃客户端
+func (s *Service) Clients(userID uint) ([]model.OAuthClient, error) {
+	var clients []model.OAuthClient
+	err := s.db.
+		Where("is_active = ?", true).
+		Where("app_id IN (?)", s.db.Model(&model.AppUser{}).Select("app_id").Where("user_id = ?", userID)).
+		Where("(backchannel_logout_uri <> '' OR frontchannel_logout_uri <> '')").
+		Order("id ASC").
+		Find(&clients).Error
+	return clients, err
+}
+
+// IssueLogoutToken 签发发给指定客户端的登出令牌，sub 与该客户端收到的 ID Token 一致
+func (s *Service) IssueLogoutToken(issuer string, client *model.OAuthClient, userID uint) (string, error) {
+	key, err := s.keys.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+	sub, err := subject.NewService(s.db).Subject(client, userID)
+	if err != nil {
+		return "", err
+	}
+
+	jtiBytes := make([]byte, 16)
+	if _, err := rand.Read(jtiBytes); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": issuer,
+		"aud": client.ClientID,
+		"sub": sub,
+		"iat": now.Unix(),
+		"exp": now.Add(logoutTokenTTL).Unix(),
+		"jti": hex.EncodeToString(jtiBytes),
+		"events": map[string]interface{}{
+			BackchannelLogoutEvent: map[string]interface{}{},
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = LogoutTokenType
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.PrivateKey)
+}
+
+// NotifyBackchannel 向配置了 backchannel_logout_uri 的客户端投递登出令牌，返回投递成功的数量。
+// 单个客户端失败只记录日志，不影响其余客户端。
+func (s *Service) NotifyBackchannel(issuer string, userID uint) int {
+	clients, err := s.Clients(userID)
+	if err != nil {
+		log.Printf("[OIDCLogout] load clients for user %d failed: %v", userID, err)
+		return 0
+	}
+
+	delivered := 0
+	for _, client := range clients {
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+		logoutToken, err := s.IssueLogoutToken(issuer, &client, userID)
+		if err != nil {
+			log.Printf("[OIDCLogout] issue logout token for client %s failed: %v", client.ClientID, err)
+			continue
+		}
+		if err := s.sender(client.BackchannelLogoutURI, logoutToken); err != nil {
+			log.Printf("[OIDCLogout] back-channel logout to client %s failed: %v", client.ClientID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// FrontchannelURLs 返回需要在浏览器中以 iframe 加载的登出地址，附带 iss 参数
+func (s *Service) FrontchannelURLs(issuer string, userID uint) ([]string, error) {
+	clients, err := s.Clients(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(clients))
+	for _, client := range clients {
+		if client.FrontchannelLogoutURI == "" {
+			continue
+		}
+		u, err := url.Parse(client.FrontchannelLogoutURI)
+		if err != nil {
+			continue
+		}
+		q := u.Query()
+		q.Set("iss", issuer)
+		u.RawQuery = q.Encode()
+		urls = append(urls, u.String())
+	}
+	return urls, nil
+}
+
+// postLogoutToken 以表单方式 POST logout_token，2xx 视为成功
+func postLogoutToken(uri, logoutToken string) error {
+	form := url.Values{}
+	form.Set("logout_token", logoutToken)
+
+	resp, err := httpClient.Post(uri, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
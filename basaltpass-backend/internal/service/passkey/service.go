@@ -2,6 +2,7 @@ package passkey
 
 import (
 	"basaltpass-backend/internal/service/auth"
+	"basaltpass-backend/internal/service/consolesession"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
@@ -185,9 +186,9 @@ func (s *Service) UpdatePasskeyUsage(credentialID []byte, tenantID uint, signCou
 		}).Error
 }
 
-// GenerateTokensForUser 为用户生成JWT tokens并记录审计日志。
-func (s *Service) GenerateTokensForUser(userID uint, tenantID uint, scope string, ctx *RequestContext) (*auth.TokenPair, error) {
-	tokens, err := auth.GenerateTokenPairWithTenantAndScope(userID, tenantID, scope)
+// GenerateTokensForUser 为用户生成JWT tokens并记录审计日志；authCtx 记录本次登录的认证方式。
+func (s *Service) GenerateTokensForUser(userID uint, tenantID uint, scope string, authCtx auth.AuthContext, ctx *RequestContext) (*auth.TokenPair, error) {
+	tokens, err := auth.GenerateTokenPairWithAuthContext(userID, tenantID, scope, authCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -212,6 +213,9 @@ func (s *Service) GenerateTokensForUser(userID uint, tenantID uint, scope string
 	if ctx != nil {
 		auditLog.IP = ctx.IP
 		auditLog.UserAgent = ctx.UserAgent
+		if err := consolesession.NewService(common.DB()).RecordClient(tokens.SessionID, ctx.IP, ctx.UserAgent); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := common.DB().Create(&auditLog).Error; err != nil {
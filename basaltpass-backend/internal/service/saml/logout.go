@@ -0,0 +1,189 @@
+package saml
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+)
+
+// LogoutRequest 已校验的 SP 发起登出请求；找不到对应会话时 UserID 为 0
+type LogoutRequest struct {
+	ID              string
+	ServiceProvider *model.SAMLServiceProvider
+	UserID          uint
+	RelayState      string
+
+	nameID         string
+	sessionIndexes []string
+}
+
+// ParseLogoutRequest 解析并校验 SP 发起的 LogoutRequest，按 NameID 与 SessionIndex 找到登出的用户。
+// 登出会结束用户会话，因此无论 SP 是否要求请求签名，都必须携带有效签名与 SessionIndex。
+func (s *Service) ParseLogoutRequest(idp *IdentityProvider, in *Inbound) (*LogoutRequest, error) {
+	// 1. 解码并解析消息
+	data, err := in.decode()
+	if err != nil {
+		return nil, err
+	}
+	var msg logoutRequestXML
+	if err := unmarshalMessage(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.ID == "" || msg.Version != "2.0" || msg.NameID == "" {
+		return nil, fmt.Errorf("%w: missing ID, version or NameID", ErrInvalidRequest)
+	}
+	if len(msg.SessionIndex) == 0 {
+		return nil, fmt.Errorf("%w: missing SessionIndex", ErrInvalidRequest)
+	}
+
+	// 2. 按 Issuer 查找 SP 并校验签名
+	sp, err := s.serviceProviderByEntityID(idp.TenantID, msg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyLogoutSignature(sp, in); err != nil {
+		return nil, err
+	}
+
+	// 3. 定位该 SP 下 NameID 与 SessionIndex 均匹配的有效会话
+	req := &LogoutRequest{
+		ID:              msg.ID,
+		ServiceProvider: sp,
+		RelayState:      in.RelayState,
+		nameID:          msg.NameID,
+		sessionIndexes:  msg.SessionIndex,
+	}
+	var session model.SAMLSession
+	if err := s.db.Where("service_provider_id = ? AND name_id = ? AND session_index IN ? AND ended_at IS NULL",
+		sp.ID, msg.NameID, msg.SessionIndex).
+		Order("id DESC").First(&session).Error; err == nil {
+		req.UserID = session.UserID
+	}
+	return req, nil
+}
+
+// EndSessions 结束 LogoutRequest 所指的会话，仅限发起登出的 SP
+func (s *Service) EndSessions(req *LogoutRequest) error {
+	if req.UserID == 0 {
+		return nil
+	}
+	return s.db.Model(&model.SAMLSession{}).
+		Where("service_provider_id = ? AND name_id = ? AND session_index IN ? AND ended_at IS NULL",
+			req.ServiceProvider.ID, req.nameID, req.sessionIndexes).
+		Update("ended_at", time.Now()).Error
+}
+
+// verifyLogoutSignature 校验 LogoutRequest 的 Redirect 绑定签名；SP 未登记证书或经 POST 绑定到达时拒绝
+func verifyLogoutSignature(sp *model.SAMLServiceProvider, in *Inbound) error {
+	if strings.TrimSpace(sp.Certificate) == "" {
+		return fmt.Errorf("%w: no certificate registered", ErrRequestSignature)
+	}
+	if in.Binding != BindingRedirect {
+		return fmt.Errorf("%w: logout requests must use the signed HTTP-Redirect binding", ErrRequestSignature)
+	}
+	cert, err := parseCertificate(sp.Certificate)
+	if err != nil {
+		return fmt.Errorf("%w: invalid certificate", ErrRequestSignature)
+	}
+	return verifyRedirectSignature(in.RawQuery, "SAMLRequest", cert)
+}
+
+// FrontchannelLogoutURLs 结束用户的全部有效 SAML 会话，返回需由浏览器加载的 LogoutRequest 地址
+// （HTTP-Redirect 绑定、已签名），发起登出的 SP（exceptSPID）与未配置 SLO 的 SP 不在其中
+func (s *Service) FrontchannelLogoutURLs(baseURL string, userID, exceptSPID uint) ([]string, error) {
+	if userID == 0 {
+		return nil, nil
+	}
+
+	// 1. 结束会话
+	now := time.Now()
+	var sessions []model.SAMLSession
+	if err := s.db.Preload("ServiceProvider").
+		Where("user_id = ? AND ended_at IS NULL AND expires_at > ?", userID, now).
+		Order("id ASC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	if err := s.db.Model(&model.SAMLSession{}).
+		Where("user_id = ? AND ended_at IS NULL", userID).
+		Update("ended_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	// 2. 为其余配置了 SLO 的 SP 生成登出请求
+	var sign *signer
+	idps := make(map[uint]*IdentityProvider)
+	var urls []string
+	for i := range sessions {
+		session := &sessions[i]
+		sp := &session.ServiceProvider
+		if sp.ID == exceptSPID || sp.SLOURL == "" || !sp.Enabled {
+			continue
+		}
+		if sign == nil {
+			var err error
+			if sign, err = s.signer(); err != nil {
+				return nil, err
+			}
+		}
+		idp, ok := idps[sp.TenantID]
+		if !ok {
+			var err error
+			if idp, err = s.IdentityProviderForTenant(baseURL, sp.TenantID); err != nil {
+				log.Printf("[SAML] load tenant %d for logout failed: %v", sp.TenantID, err)
+				continue
+			}
+			idps[sp.TenantID] = idp
+		}
+
+		message := newElement("samlp:LogoutRequest").xmlns("samlp", nsProtocol).
+			attr("Destination", sp.SLOURL).
+			attr("ID", newID()).
+			attr("IssueInstant", formatTime(now)).
+			attr("NotOnOrAfter", formatTime(now.Add(assertionLifetime))).
+			attr("Version", "2.0").
+			add(
+				newElement("saml:Issuer").xmlns("saml", nsAssertion).setText(idp.EntityID),
+				newElement("saml:NameID").xmlns("saml", nsAssertion).
+					attr("Format", session.NameIDFormat).
+					attr("SPNameQualifier", sp.EntityID).
+					setText(session.NameID),
+				newElement("samlp:SessionIndex").setText(session.SessionIndex),
+			)
+		u, err := sign.redirectURL(sp.SLOURL, "SAMLRequest", []byte(message.String()), "")
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// LogoutResponseURL 生成回复发起方的 LogoutResponse 地址（HTTP-Redirect 绑定、已签名）；SP 未配置 SLO 时返回空串
+func (s *Service) LogoutResponseURL(idp *IdentityProvider, req *LogoutRequest) (string, error) {
+	sp := req.ServiceProvider
+	if sp.SLOURL == "" {
+		return "", nil
+	}
+	sign, err := s.signer()
+	if err != nil {
+		return "", err
+	}
+	message := newElement("samlp:LogoutResponse").xmlns("samlp", nsProtocol).
+		attr("Destination", sp.SLOURL).
+		attr("ID", newID()).
+		attr("InResponseTo", req.ID).
+		attr("IssueInstant", formatTime(time.Now())).
+		attr("Version", "2.0").
+		add(
+			newElement("saml:Issuer").xmlns("saml", nsAssertion).setText(idp.EntityID),
+			newElement("samlp:Status").add(newElement("samlp:StatusCode").attr("Value", statusSuccess)),
+		)
+	return sign.redirectURL(sp.SLOURL, "SAMLResponse", []byte(message.String()), req.RelayState)
+}
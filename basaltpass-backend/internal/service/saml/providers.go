@@ -0,0 +1,174 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"basaltpass-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ServiceProviderInput 登记或更新应用 SP 的参数；布尔字段为 nil 时取默认值（新建）或保持不变（更新）
+type ServiceProviderInput struct {
+	EntityID               string `json:"entity_id"`
+	ACSURL                 string `json:"acs_url"`
+	SLOURL                 string `json:"slo_url"`
+	NameIDFormat           string `json:"name_id_format"`
+	Certificate            string `json:"certificate"`
+	WantRequestsSigned     *bool  `json:"want_requests_signed"`
+	SignResponse           *bool  `json:"sign_response"`
+	AllowIdPInitiated      *bool  `json:"allow_idp_initiated"`
+	DefaultRelayState      string `json:"default_relay_state"`
+	SessionLifetimeMinutes int    `json:"session_lifetime_minutes"`
+	Enabled                *bool  `json:"enabled"`
+}
+
+const (
+	defaultSessionLifetimeMins = 480
+	maxSessionLifetimeMins     = 7 * 24 * 60
+)
+
+// GetServiceProvider 返回应用登记的 SP
+func (s *Service) GetServiceProvider(tenantID, appID uint) (*model.SAMLServiceProvider, error) {
+	var sp model.SAMLServiceProvider
+	err := s.db.Where("tenant_id = ? AND app_id = ?", tenantID, appID).First(&sp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrServiceProviderNotFound
+	}
+	return &sp, err
+}
+
+// SaveServiceProvider 为应用登记 SP，已登记时整体更新
+func (s *Service) SaveServiceProvider(tenantID, appID uint, in ServiceProviderInput) (*model.SAMLServiceProvider, error) {
+	// 1. 应用必须属于该租户
+	var app model.App
+	if err := s.db.Select("id", "tenant_id").Where("id = ? AND tenant_id = ?", appID, tenantID).First(&app).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServiceProviderNotFound
+		}
+		return nil, err
+	}
+
+	// 2. 读取已有登记
+	sp, err := s.GetServiceProvider(tenantID, appID)
+	if errors.Is(err, ErrServiceProviderNotFound) {
+		sp = &model.SAMLServiceProvider{
+			AppID:    appID,
+			TenantID: tenantID,
+			Enabled:  true,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// 3. 合并并校验参数
+	sp.EntityID = strings.TrimSpace(in.EntityID)
+	sp.ACSURL = strings.TrimSpace(in.ACSURL)
+	sp.SLOURL = strings.TrimSpace(in.SLOURL)
+	sp.NameIDFormat = strings.TrimSpace(in.NameIDFormat)
+	sp.Certificate = strings.TrimSpace(in.Certificate)
+	sp.DefaultRelayState = strings.TrimSpace(in.DefaultRelayState)
+	sp.SessionLifetimeMins = in.SessionLifetimeMinutes
+	if in.WantRequestsSigned != nil {
+		sp.WantRequestsSigned = *in.WantRequestsSigned
+	}
+	if in.SignResponse != nil {
+		sp.SignResponse = *in.SignResponse
+	}
+	if in.AllowIdPInitiated != nil {
+		sp.AllowIdPInitiated = *in.AllowIdPInitiated
+	}
+	if in.Enabled != nil {
+		sp.Enabled = *in.Enabled
+	}
+	if err := validateServiceProvider(sp); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.SAMLServiceProvider{}).
+		Where("tenant_id = ? AND entity_id = ? AND app_id <> ?", tenantID, sp.EntityID, appID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: entity_id 已被其他应用使用", ErrInvalidServiceProvider)
+	}
+
+	// 4. 保存；布尔字段带数据库默认值，新建后再显式写入以免 false 被默认值覆盖
+	flags := map[string]interface{}{
+		"want_requests_signed": sp.WantRequestsSigned,
+		"sign_response":        sp.SignResponse,
+		"allow_idp_initiated":  sp.AllowIdPInitiated,
+		"enabled":              sp.Enabled,
+	}
+	return sp, s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(sp).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(sp).Updates(flags).Error; err != nil {
+			return err
+		}
+		sp.WantRequestsSigned = flags["want_requests_signed"].(bool)
+		sp.SignResponse = flags["sign_response"].(bool)
+		sp.AllowIdPInitiated = flags["allow_idp_initiated"].(bool)
+		sp.Enabled = flags["enabled"].(bool)
+		return nil
+	})
+}
+
+// DeleteServiceProvider 删除应用登记的 SP 及其 SAML 会话
+func (s *Service) DeleteServiceProvider(tenantID, appID uint) error {
+	sp, err := s.GetServiceProvider(tenantID, appID)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_provider_id = ?", sp.ID).Delete(&model.SAMLSession{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(sp).Error
+	})
+}
+
+func validateServiceProvider(sp *model.SAMLServiceProvider) error {
+	if sp.EntityID == "" || len(sp.EntityID) > 255 {
+		return fmt.Errorf("%w: entity_id 不能为空且不超过 255 个字符", ErrInvalidServiceProvider)
+	}
+	if !isHTTPURL(sp.ACSURL) {
+		return fmt.Errorf("%w: acs_url 必须是 http(s) 地址", ErrInvalidServiceProvider)
+	}
+	if sp.SLOURL != "" && !isHTTPURL(sp.SLOURL) {
+		return fmt.Errorf("%w: slo_url 必须是 http(s) 地址", ErrInvalidServiceProvider)
+	}
+	switch sp.NameIDFormat {
+	case "":
+		sp.NameIDFormat = model.SAMLNameIDFormatEmail
+	case model.SAMLNameIDFormatEmail, model.SAMLNameIDFormatPersistent, model.SAMLNameIDFormatUnspecified:
+	default:
+		return fmt.Errorf("%w: 不支持的 name_id_format", ErrInvalidServiceProvider)
+	}
+	if sp.Certificate != "" {
+		if _, err := parseCertificate(sp.Certificate); err != nil {
+			return fmt.Errorf("%w: certificate 不是合法的 X.509 证书", ErrInvalidServiceProvider)
+		}
+	}
+	if sp.WantRequestsSigned && sp.Certificate == "" {
+		return fmt.Errorf("%w: 要求请求签名时必须提供 SP 证书", ErrInvalidServiceProvider)
+	}
+	switch {
+	case sp.SessionLifetimeMins == 0:
+		sp.SessionLifetimeMins = defaultSessionLifetimeMins
+	case sp.SessionLifetimeMins < 5 || sp.SessionLifetimeMins > maxSessionLifetimeMins:
+		return fmt.Errorf("%w: session_lifetime_minutes 需在 5 到 %d 之间", ErrInvalidServiceProvider, maxSessionLifetimeMins)
+	}
+	return nil
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
@@ -0,0 +1,200 @@
+package saml
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/claimmapping"
+	"basaltpass-backend/internal/service/signingkey"
+
+	"gorm.io/gorm"
+)
+
+// PathPrefix 租户 SAML 端点的路径前缀，后接租户 code
+const PathPrefix = "/api/v1/saml/"
+
+var (
+	ErrTenantNotFound          = errors.New("tenant not found")
+	ErrServiceProviderNotFound = errors.New("saml service provider not found")
+	ErrInvalidServiceProvider  = errors.New("invalid saml service provider")
+	ErrInvalidRequest          = errors.New("invalid saml request")
+	ErrRequestSignature        = errors.New("saml request signature invalid")
+	ErrUnknownACS              = errors.New("assertion consumer service url not registered")
+	ErrIdPInitiatedDisabled    = errors.New("idp-initiated sso disabled for this service provider")
+	ErrPendingRequest          = errors.New("invalid or expired pending saml request")
+	ErrNameIDUnavailable       = errors.New("user has no value for the requested name id format")
+)
+
+// Service SAML 2.0 IdP：SP 登记、元数据、断言签发与单点登出
+type Service struct {
+	db     *gorm.DB
+	keys   *signingkey.Service
+	claims *claimmapping.Service
+}
+
+// NewService 创建 SAML 服务；断言使用 OAuth 签名密钥集合（RS256）签名
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:     db,
+		keys:   signingkey.NewService(db),
+		claims: claimmapping.NewService(db),
+	}
+}
+
+// IdentityProvider 租户作为 SAML IdP 的端点
+type IdentityProvider struct {
+	TenantID   uint
+	TenantCode string
+	EntityID   string
+	SSOURL     string
+	SLOURL     string
+}
+
+// IdentityProvider 返回租户 code 对应的 IdP 端点；baseURL 为 API 的对外地址
+func (s *Service) IdentityProvider(baseURL, tenantCode string) (*IdentityProvider, error) {
+	var tenant model.Tenant
+	if err := s.db.Select("id", "code", "status").Where("code = ?", strings.TrimSpace(tenantCode)).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	if tenant.Status != "" && tenant.Status != model.TenantStatusActive {
+		return nil, ErrTenantNotFound
+	}
+	return newIdentityProvider(baseURL, &tenant), nil
+}
+
+// IdentityProviderForTenant 返回租户ID对应的 IdP 端点
+func (s *Service) IdentityProviderForTenant(baseURL string, tenantID uint) (*IdentityProvider, error) {
+	var tenant model.Tenant
+	if err := s.db.Select("id", "code").First(&tenant, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return newIdentityProvider(baseURL, &tenant), nil
+}
+
+func newIdentityProvider(baseURL string, tenant *model.Tenant) *IdentityProvider {
+	base := strings.TrimRight(baseURL, "/") + PathPrefix + tenant.Code
+	return &IdentityProvider{
+		TenantID:   tenant.ID,
+		TenantCode: tenant.Code,
+		EntityID:   base + "/metadata",
+		SSOURL:     base + "/sso",
+		SLOURL:     base + "/slo",
+	}
+}
+
+// Metadata 生成 IdP 元数据（SAML Metadata §2.4.3），发布 OAuth 密钥集合中全部已发布 RS256 密钥的证书，
+// SP 可在密钥轮换前预先信任 next 密钥
+func (s *Service) Metadata(idp *IdentityProvider) (string, error) {
+	keys, err := s.keys.PublishedKeys()
+	if err != nil {
+		return "", err
+	}
+
+	descriptor := newElement("md:IDPSSODescriptor").
+		attr("WantAuthnRequestsSigned", "false").
+		attr("protocolSupportEnumeration", nsProtocol)
+	for _, key := range keys {
+		if key.Algorithm != signingkey.AlgorithmRS256 {
+			continue
+		}
+		cert, err := s.keys.Certificate(key.KID)
+		if err != nil {
+			return "", err
+		}
+		descriptor.add(newElement("md:KeyDescriptor").attr("use", "signing").add(
+			newElement("ds:KeyInfo").xmlns("ds", nsDSig).add(
+				newElement("ds:X509Data").add(
+					newElement("ds:X509Certificate").setText(base64.StdEncoding.EncodeToString(cert)),
+				),
+			),
+		))
+	}
+	descriptor.add(
+		newElement("md:SingleLogoutService").attr("Binding", bindingRedir).attr("Location", idp.SLOURL),
+		newElement("md:SingleLogoutService").attr("Binding", bindingPOST).attr("Location", idp.SLOURL),
+		newElement("md:NameIDFormat").setText(model.SAMLNameIDFormatEmail),
+		newElement("md:NameIDFormat").setText(model.SAMLNameIDFormatPersistent),
+		newElement("md:NameIDFormat").setText(model.SAMLNameIDFormatUnspecified),
+		newElement("md:SingleSignOnService").attr("Binding", bindingRedir).attr("Location", idp.SSOURL),
+		newElement("md:SingleSignOnService").attr("Binding", bindingPOST).attr("Location", idp.SSOURL),
+	)
+
+	doc := newElement("md:EntityDescriptor").
+		xmlns("md", nsMetadata).
+		attr("entityID", idp.EntityID).
+		add(descriptor)
+	return xmlHeader + doc.String(), nil
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// signer 返回当前签名密钥及其证书
+func (s *Service) signer() (*signer, error) {
+	key, err := s.keys.ActiveKey()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := s.keys.Certificate(key.KID)
+	if err != nil {
+		return nil, err
+	}
+	return &signer{key: key, cert: cert}, nil
+}
+
+// serviceProviderByEntityID 查找租户下启用的 SP
+func (s *Service) serviceProviderByEntityID(tenantID uint, entityID string) (*model.SAMLServiceProvider, error) {
+	var sp model.SAMLServiceProvider
+	err := s.db.Preload("App").
+		Where("tenant_id = ? AND entity_id = ? AND enabled = ?", tenantID, strings.TrimSpace(entityID), true).
+		First(&sp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrServiceProviderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sp.App.Status != "" && sp.App.Status != model.AppStatusActive {
+		return nil, ErrServiceProviderNotFound
+	}
+	return &sp, nil
+}
+
+// verifyInbound 校验请求签名：SP 要求签名时 Redirect 绑定的消息必须带有效签名，经 POST 绑定到达则拒绝
+// （不校验内嵌 XML 签名）；未要求签名但消息携带了签名且 SP 登记了证书时同样校验
+func verifyInbound(sp *model.SAMLServiceProvider, in *Inbound, param string) error {
+	if strings.TrimSpace(sp.Certificate) == "" {
+		if sp.WantRequestsSigned {
+			return fmt.Errorf("%w: no certificate registered", ErrRequestSignature)
+		}
+		return nil
+	}
+	if in.Binding != BindingRedirect {
+		if sp.WantRequestsSigned {
+			return fmt.Errorf("%w: signed requests must use the HTTP-Redirect binding", ErrRequestSignature)
+		}
+		return nil
+	}
+	if !sp.WantRequestsSigned && !strings.Contains(in.RawQuery, "Signature=") {
+		return nil
+	}
+	cert, err := parseCertificate(sp.Certificate)
+	if err != nil {
+		return fmt.Errorf("%w: invalid certificate", ErrRequestSignature)
+	}
+	return verifyRedirectSignature(in.RawQuery, param, cert)
+}
+
+func newID() string {
+	return "_" + randomHex(20)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -0,0 +1,508 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const testBaseURL = "https://id.example.com"
+
+func setupSAMLTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Tenant{}, &model.App{}, &model.User{}, &model.UserProfile{}, &model.Gender{}, &model.Language{},
+		&model.SigningKey{}, &model.SAMLServiceProvider{}, &model.SAMLSession{},
+		&model.AppClaimMapping{}, &model.AppRole{}, &model.AppUserRole{},
+		&model.TenantRbacRole{}, &model.TenantUserRbacRole{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+type samlFixture struct {
+	db   *gorm.DB
+	svc  *Service
+	idp  *IdentityProvider
+	app  model.App
+	user model.User
+}
+
+func newSAMLFixture(t *testing.T) *samlFixture {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret-for-unit-tests")
+	db := setupSAMLTestDB(t)
+	tenant := model.Tenant{Name: "Acme", Code: "acme"}
+	db.Create(&tenant)
+	app := model.App{TenantID: tenant.ID, Name: "Wiki"}
+	db.Create(&app)
+	user := model.User{TenantID: tenant.ID, Email: "alice@example.com", Nickname: "Alice", PasswordHash: "x", UserUUID: "6f1c2b9e-0000-4000-8000-000000000001"}
+	db.Create(&user)
+
+	svc := NewService(db)
+	idp, err := svc.IdentityProvider(testBaseURL, "acme")
+	if err != nil {
+		t.Fatalf("identity provider failed: %v", err)
+	}
+	return &samlFixture{db: db, svc: svc, idp: idp, app: app, user: user}
+}
+
+func (f *samlFixture) registerSP(t *testing.T, appID uint, in ServiceProviderInput) *model.SAMLServiceProvider {
+	t.Helper()
+	sp, err := f.svc.SaveServiceProvider(f.idp.TenantID, appID, in)
+	if err != nil {
+		t.Fatalf("save service provider failed: %v", err)
+	}
+	return sp
+}
+
+func redirectInbound(t *testing.T, param, message, relayState string) *Inbound {
+	t.Helper()
+	encoded, err := deflateBase64([]byte(message))
+	if err != nil {
+		t.Fatalf("deflate failed: %v", err)
+	}
+	raw := param + "=" + url.QueryEscape(encoded)
+	if relayState != "" {
+		raw += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	return &Inbound{Binding: BindingRedirect, Message: encoded, RelayState: relayState, RawQuery: raw}
+}
+
+// newSPCertificate 生成 SP 的签名密钥与自签证书（PEM）
+func newSPCertificate(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	spKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "sp"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	certDER, _ := x509.CreateCertificate(rand.Reader, template, template, &spKey.PublicKey, spKey)
+	return spKey, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+}
+
+// signInbound 按 HTTP-Redirect 绑定为消息签名
+func signInbound(in *Inbound, key *rsa.PrivateKey) *Inbound {
+	signed := in.RawQuery + "&SigAlg=" + url.QueryEscape(algRSASHA256)
+	sum := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	out := *in
+	out.RawQuery = signed + "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig))
+	return &out
+}
+
+func authnRequest(id, issuer, acs string) string {
+	return `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"` +
+		` ID="` + id + `" Version="2.0" IssueInstant="2026-01-01T00:00:00Z" AssertionConsumerServiceURL="` + acs + `"` +
+		` ProtocolBinding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"><saml:Issuer>` + issuer + `</saml:Issuer>` +
+		`<samlp:NameIDPolicy Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress" AllowCreate="true"/></samlp:AuthnRequest>`
+}
+
+// 以下结构仅用于在测试中读取签发的 Response
+type testResponse struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol Response"`
+	InResponseTo string   `xml:"InResponseTo,attr"`
+	Destination  string   `xml:"Destination,attr"`
+	Status       struct {
+		StatusCode struct {
+			Value string `xml:"Value,attr"`
+			Inner *struct {
+				Value string `xml:"Value,attr"`
+			} `xml:"StatusCode"`
+		} `xml:"StatusCode"`
+	} `xml:"Status"`
+	Assertion *struct {
+		NameID         string `xml:"Subject>NameID"`
+		Audience       string `xml:"Conditions>AudienceRestriction>Audience"`
+		AuthnStatement struct {
+			SessionIndex string `xml:"SessionIndex,attr"`
+		} `xml:"AuthnStatement"`
+		Attributes []struct {
+			Name   string   `xml:"Name,attr"`
+			Values []string `xml:"AttributeValue"`
+		} `xml:"AttributeStatement>Attribute"`
+	} `xml:"Assertion"`
+}
+
+func decodeResponse(t *testing.T, resp *Response) (string, *testResponse) {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(resp.SAMLResponse)
+	if err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	var parsed testResponse
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("response is not well-formed XML: %v", err)
+	}
+	return string(data), &parsed
+}
+
+// verifyEnveloped 按 enveloped-signature + exc-c14n 的规则重新计算摘要并验证签名。
+// 签发的文档本身即规范化形式，因此去掉 Signature 元素即可得到被签名的内容。
+func verifyEnveloped(t *testing.T, doc, openTag, closeTag string) {
+	t.Helper()
+	start := strings.Index(doc, openTag)
+	end := strings.Index(doc, closeTag)
+	if start < 0 || end < 0 {
+		t.Fatalf("element %s not found", openTag)
+	}
+	signedElement := doc[start : end+len(closeTag)]
+
+	sigStart := strings.Index(signedElement, "<ds:Signature ")
+	sigEnd := strings.Index(signedElement, "</ds:Signature>") + len("</ds:Signature>")
+	signature := signedElement[sigStart:sigEnd]
+	digest := sha256.Sum256([]byte(signedElement[:sigStart] + signedElement[sigEnd:]))
+
+	digestValue := between(signature, "<ds:DigestValue>", "</ds:DigestValue>")
+	if digestValue != base64.StdEncoding.EncodeToString(digest[:]) {
+		t.Fatalf("digest mismatch for %s", openTag)
+	}
+
+	signedInfo := "<ds:SignedInfo>" + between(signature, "<ds:SignedInfo>", "</ds:SignedInfo>") + "</ds:SignedInfo>"
+	signedInfo = strings.Replace(signedInfo, "<ds:SignedInfo>", `<ds:SignedInfo xmlns:ds="`+nsDSig+`">`, 1)
+	sigValue, _ := base64.StdEncoding.DecodeString(between(signature, "<ds:SignatureValue>", "</ds:SignatureValue>"))
+	certDER, _ := base64.StdEncoding.DecodeString(between(signature, "<ds:X509Certificate>", "</ds:X509Certificate>"))
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("parse KeyInfo certificate failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte(signedInfo))
+	if err := rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, sum[:], sigValue); err != nil {
+		t.Fatalf("signature of %s does not verify: %v", openTag, err)
+	}
+}
+
+func between(s, open, close string) string {
+	i := strings.Index(s, open)
+	if i < 0 {
+		return ""
+	}
+	s = s[i+len(open):]
+	j := strings.Index(s, close)
+	if j < 0 {
+		return ""
+	}
+	return s[:j]
+}
+
+func TestSPInitiatedSSOIssuesSignedAssertion(t *testing.T) {
+	f := newSAMLFixture(t)
+	signResponse := true
+	sp := f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID:     "https://wiki.example.com/saml",
+		ACSURL:       "https://wiki.example.com/saml/acs",
+		SignResponse: &signResponse,
+	})
+	if sp.NameIDFormat != model.SAMLNameIDFormatEmail || !sp.Enabled || sp.SessionLifetimeMins != defaultSessionLifetimeMins {
+		t.Fatalf("unexpected defaults: %+v", sp)
+	}
+
+	editor := model.AppRole{AppID: f.app.ID, TenantID: f.idp.TenantID, Code: "editor", Name: "Editor"}
+	f.db.Create(&editor)
+	f.db.Create(&model.AppUserRole{UserID: f.user.ID, AppID: f.app.ID, RoleID: editor.ID, AssignedBy: 1})
+	f.db.Create(&model.UserProfile{UserID: f.user.ID, Company: "Acme & Co"})
+	f.db.Create(&model.AppClaimMapping{AppID: f.app.ID, TenantID: f.idp.TenantID, ClaimName: "company", Source: model.ClaimSourceProfile, Value: "company", Scope: "profile", Targets: "saml"})
+	f.db.Create(&model.AppClaimMapping{AppID: f.app.ID, TenantID: f.idp.TenantID, ClaimName: "tier", Source: model.ClaimSourceStatic, Value: `"gold"`, Targets: "id_token"})
+
+	in := redirectInbound(t, "SAMLRequest", authnRequest("_req1", sp.EntityID, sp.ACSURL), "/wiki/home")
+	req, err := f.svc.ParseAuthnRequest(f.idp, in)
+	if err != nil {
+		t.Fatalf("parse authn request failed: %v", err)
+	}
+
+	// 登录跳转往返后还原请求
+	pending, err := f.svc.EncodePending(f.idp, req)
+	if err != nil {
+		t.Fatalf("encode pending failed: %v", err)
+	}
+	req, err = f.svc.DecodePending(f.idp, pending)
+	if err != nil || req.ID != "_req1" || req.RelayState != "/wiki/home" {
+		t.Fatalf("decode pending failed: %+v %v", req, err)
+	}
+	other := &IdentityProvider{TenantID: f.idp.TenantID + 1}
+	if _, err := f.svc.DecodePending(other, pending); !errors.Is(err, ErrPendingRequest) {
+		t.Fatalf("pending request must be bound to its tenant, got %v", err)
+	}
+
+	resp, err := f.svc.IssueResponse(f.idp, req, f.user.ID, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("issue response failed: %v", err)
+	}
+	if resp.ACSURL != sp.ACSURL || resp.RelayState != "/wiki/home" {
+		t.Fatalf("unexpected response target: %+v", resp)
+	}
+
+	doc, parsed := decodeResponse(t, resp)
+	if parsed.InResponseTo != "_req1" || parsed.Status.StatusCode.Value != statusSuccess || parsed.Assertion == nil {
+		t.Fatalf("unexpected response: %+v", parsed)
+	}
+	if parsed.Assertion.NameID != "alice@example.com" || parsed.Assertion.Audience != sp.EntityID {
+		t.Fatalf("unexpected subject or audience: %+v", parsed.Assertion)
+	}
+	attributes := make(map[string][]string)
+	for _, a := range parsed.Assertion.Attributes {
+		attributes[a.Name] = a.Values
+	}
+	if got := attributes["roles"]; len(got) != 1 || got[0] != "editor" {
+		t.Fatalf("expected app roles attribute, got %v", attributes)
+	}
+	// 面向 saml 的映射不受 scope 限制；只面向 id_token 的映射不输出
+	if got := attributes["company"]; len(got) != 1 || got[0] != "Acme & Co" {
+		t.Fatalf("expected mapped company attribute, got %v", attributes)
+	}
+	if _, ok := attributes["tier"]; ok {
+		t.Fatalf("id_token-only mapping must not appear in the assertion")
+	}
+
+	verifyEnveloped(t, doc, "<saml:Assertion ", "</saml:Assertion>")
+	verifyEnveloped(t, doc, "<samlp:Response ", "</samlp:Response>")
+
+	var session model.SAMLSession
+	if err := f.db.Where("session_index = ?", parsed.Assertion.AuthnStatement.SessionIndex).First(&session).Error; err != nil || session.UserID != f.user.ID {
+		t.Fatalf("expected saml session to be recorded, got %+v %v", session, err)
+	}
+}
+
+func TestAuthnRequestValidation(t *testing.T) {
+	f := newSAMLFixture(t)
+	sp := f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID: "https://wiki.example.com/saml",
+		ACSURL:   "https://wiki.example.com/saml/acs",
+	})
+
+	if _, err := f.svc.ParseAuthnRequest(f.idp, redirectInbound(t, "SAMLRequest", authnRequest("_r", "https://unknown.example.com", sp.ACSURL), "")); !errors.Is(err, ErrServiceProviderNotFound) {
+		t.Fatalf("expected unknown issuer to be rejected, got %v", err)
+	}
+	if _, err := f.svc.ParseAuthnRequest(f.idp, redirectInbound(t, "SAMLRequest", authnRequest("_r", sp.EntityID, "https://evil.example.com/acs"), "")); !errors.Is(err, ErrUnknownACS) {
+		t.Fatalf("expected unregistered ACS to be rejected, got %v", err)
+	}
+
+	// 要求请求签名：登记 SP 证书后只接受签名有效的 Redirect 绑定请求
+	spKey, certPEM := newSPCertificate(t)
+	wantSigned := true
+	f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID:           sp.EntityID,
+		ACSURL:             sp.ACSURL,
+		Certificate:        certPEM,
+		WantRequestsSigned: &wantSigned,
+	})
+
+	message := authnRequest("_signed", sp.EntityID, sp.ACSURL)
+	unsigned := redirectInbound(t, "SAMLRequest", message, "state")
+	if _, err := f.svc.ParseAuthnRequest(f.idp, unsigned); !errors.Is(err, ErrRequestSignature) {
+		t.Fatalf("expected unsigned request to be rejected, got %v", err)
+	}
+
+	signedIn := *signInbound(unsigned, spKey)
+	if _, err := f.svc.ParseAuthnRequest(f.idp, &signedIn); err != nil {
+		t.Fatalf("expected signed request to be accepted, got %v", err)
+	}
+
+	tampered := signedIn
+	tampered.RawQuery = strings.Replace(signedIn.RawQuery, "RelayState=state", "RelayState=other", 1)
+	if _, err := f.svc.ParseAuthnRequest(f.idp, &tampered); !errors.Is(err, ErrRequestSignature) {
+		t.Fatalf("expected tampered request to be rejected, got %v", err)
+	}
+
+	post := &Inbound{Binding: BindingPOST, Message: base64.StdEncoding.EncodeToString([]byte(message))}
+	if _, err := f.svc.ParseAuthnRequest(f.idp, post); !errors.Is(err, ErrRequestSignature) {
+		t.Fatalf("expected POST binding to be rejected when signatures are required, got %v", err)
+	}
+}
+
+func TestIdPInitiatedAndFailureResponses(t *testing.T) {
+	f := newSAMLFixture(t)
+	sp := f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID:          "https://wiki.example.com/saml",
+		ACSURL:            "https://wiki.example.com/saml/acs",
+		NameIDFormat:      model.SAMLNameIDFormatPersistent,
+		DefaultRelayState: "/dashboard",
+	})
+
+	if _, err := f.svc.IdPInitiatedRequest(f.idp, f.app.ID, ""); !errors.Is(err, ErrIdPInitiatedDisabled) {
+		t.Fatalf("expected idp-initiated sso to require opt-in, got %v", err)
+	}
+	allow := true
+	f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID:          sp.EntityID,
+		ACSURL:            sp.ACSURL,
+		NameIDFormat:      model.SAMLNameIDFormatPersistent,
+		DefaultRelayState: "/dashboard",
+		AllowIdPInitiated: &allow,
+	})
+	req, err := f.svc.IdPInitiatedRequest(f.idp, f.app.ID, "")
+	if err != nil || req.ID != "" || req.RelayState != "/dashboard" {
+		t.Fatalf("unexpected idp-initiated request: %+v %v", req, err)
+	}
+
+	resp, err := f.svc.IssueResponse(f.idp, req, f.user.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("issue response failed: %v", err)
+	}
+	_, parsed := decodeResponse(t, resp)
+	if parsed.InResponseTo != "" || parsed.Assertion == nil || parsed.Assertion.NameID != f.user.UserUUID {
+		t.Fatalf("expected unsolicited response with persistent name id, got %+v", parsed)
+	}
+
+	denied, err := f.svc.ErrorResponse(f.idp, req, StatusRequestDenied)
+	if err != nil {
+		t.Fatalf("error response failed: %v", err)
+	}
+	_, parsed = decodeResponse(t, denied)
+	if parsed.Assertion != nil || parsed.Status.StatusCode.Value != statusResponder ||
+		parsed.Status.StatusCode.Inner == nil || parsed.Status.StatusCode.Inner.Value != StatusRequestDenied {
+		t.Fatalf("unexpected failure response: %+v", parsed)
+	}
+}
+
+func TestSingleLogoutRequiresSignedRequestAndEndsIssuerSessions(t *testing.T) {
+	f := newSAMLFixture(t)
+	chat := model.App{TenantID: f.idp.TenantID, Name: "Chat"}
+	f.db.Create(&chat)
+	wikiKey, wikiCert := newSPCertificate(t)
+	wiki := f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID:    "https://wiki.example.com/saml",
+		ACSURL:      "https://wiki.example.com/saml/acs",
+		SLOURL:      "https://wiki.example.com/saml/slo",
+		Certificate: wikiCert,
+	})
+	chatSP := f.registerSP(t, chat.ID, ServiceProviderInput{
+		EntityID: "https://chat.example.com/saml",
+		ACSURL:   "https://chat.example.com/saml/acs",
+		SLOURL:   "https://chat.example.com/saml/slo?tenant=acme",
+	})
+
+	var wikiIndex string
+	for _, sp := range []*model.SAMLServiceProvider{wiki, chatSP} {
+		resp, err := f.svc.IssueResponse(f.idp, &AuthnRequest{ServiceProvider: sp}, f.user.ID, time.Now())
+		if err != nil {
+			t.Fatalf("issue response failed: %v", err)
+		}
+		if sp == wiki {
+			_, parsed := decodeResponse(t, resp)
+			wikiIndex = parsed.Assertion.AuthnStatement.SessionIndex
+		}
+	}
+
+	logoutRequest := func(sessionIndex string) string {
+		msg := `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_lo1" Version="2.0">` +
+			`<saml:Issuer>` + wiki.EntityID + `</saml:Issuer><saml:NameID>alice@example.com</saml:NameID>`
+		if sessionIndex != "" {
+			msg += `<samlp:SessionIndex>` + sessionIndex + `</samlp:SessionIndex>`
+		}
+		return msg + `</samlp:LogoutRequest>`
+	}
+
+	// 未签名、缺少 SessionIndex 的请求均被拒绝
+	unsigned := redirectInbound(t, "SAMLRequest", logoutRequest(wikiIndex), "bye")
+	if _, err := f.svc.ParseLogoutRequest(f.idp, unsigned); !errors.Is(err, ErrRequestSignature) {
+		t.Fatalf("expected unsigned logout request to be rejected, got %v", err)
+	}
+	noIndex := signInbound(redirectInbound(t, "SAMLRequest", logoutRequest(""), "bye"), wikiKey)
+	if _, err := f.svc.ParseLogoutRequest(f.idp, noIndex); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected logout request without SessionIndex to be rejected, got %v", err)
+	}
+	// SessionIndex 不匹配时不定位到用户
+	wrongIndex, err := f.svc.ParseLogoutRequest(f.idp, signInbound(redirectInbound(t, "SAMLRequest", logoutRequest("_unknown"), "bye"), wikiKey))
+	if err != nil || wrongIndex.UserID != 0 {
+		t.Fatalf("expected unmatched SessionIndex to resolve no user, got %+v %v", wrongIndex, err)
+	}
+
+	req, err := f.svc.ParseLogoutRequest(f.idp, signInbound(unsigned, wikiKey))
+	if err != nil || req.UserID != f.user.ID {
+		t.Fatalf("parse logout request failed: %+v %v", req, err)
+	}
+	if err := f.svc.EndSessions(req); err != nil {
+		t.Fatalf("end sessions failed: %v", err)
+	}
+
+	// 只结束发起登出的 SP 的会话
+	var wikiActive, chatActive int64
+	f.db.Model(&model.SAMLSession{}).Where("service_provider_id = ? AND ended_at IS NULL", wiki.ID).Count(&wikiActive)
+	f.db.Model(&model.SAMLSession{}).Where("service_provider_id = ? AND ended_at IS NULL", chatSP.ID).Count(&chatActive)
+	if wikiActive != 0 || chatActive != 1 {
+		t.Fatalf("expected only the issuer session to end, wiki=%d chat=%d", wikiActive, chatActive)
+	}
+
+	// 用户自身登出时向其余 SP 发送已签名的登出请求
+	urls, err := f.svc.FrontchannelLogoutURLs(testBaseURL, f.user.ID, 0)
+	if err != nil || len(urls) != 1 {
+		t.Fatalf("expected one logout url for the other service provider, got %v %v", urls, err)
+	}
+	if !strings.HasPrefix(urls[0], "https://chat.example.com/saml/slo?tenant=acme&SAMLRequest=") {
+		t.Fatalf("unexpected logout url: %s", urls[0])
+	}
+	sign, err := f.svc.signer()
+	if err != nil {
+		t.Fatalf("load signer failed: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(sign.cert)
+	rawQuery := urls[0][strings.Index(urls[0], "SAMLRequest="):]
+	if err := verifyRedirectSignature(rawQuery, "SAMLRequest", cert); err != nil {
+		t.Fatalf("logout request signature does not verify: %v", err)
+	}
+	if again, _ := f.svc.FrontchannelLogoutURLs(testBaseURL, f.user.ID, 0); len(again) != 0 {
+		t.Fatalf("ended sessions must not be logged out twice")
+	}
+
+	responseURL, err := f.svc.LogoutResponseURL(f.idp, req)
+	if err != nil || !strings.HasPrefix(responseURL, wiki.SLOURL+"?SAMLResponse=") || !strings.Contains(responseURL, "RelayState=bye") {
+		t.Fatalf("unexpected logout response url: %s %v", responseURL, err)
+	}
+}
+
+func TestSaveServiceProviderValidation(t *testing.T) {
+	f := newSAMLFixture(t)
+	other := model.App{TenantID: f.idp.TenantID, Name: "Other"}
+	f.db.Create(&other)
+
+	disabled := false
+	sp := f.registerSP(t, f.app.ID, ServiceProviderInput{
+		EntityID: "https://wiki.example.com/saml",
+		ACSURL:   "https://wiki.example.com/saml/acs",
+		Enabled:  &disabled,
+	})
+	var stored model.SAMLServiceProvider
+	f.db.First(&stored, sp.ID)
+	if stored.Enabled {
+		t.Fatalf("expected enabled=false to be persisted")
+	}
+
+	cases := []ServiceProviderInput{
+		{EntityID: "https://wiki.example.com/saml", ACSURL: "https://other.example.com/acs"}, // entity_id 已被占用
+		{EntityID: "https://other.example.com", ACSURL: "javascript:alert(1)"},
+		{EntityID: "https://other.example.com", ACSURL: "https://other.example.com/acs", NameIDFormat: "urn:custom"},
+		{EntityID: "https://other.example.com", ACSURL: "https://other.example.com/acs", Certificate: "not a certificate"},
+	}
+	for i, in := range cases {
+		if _, err := f.svc.SaveServiceProvider(f.idp.TenantID, other.ID, in); !errors.Is(err, ErrInvalidServiceProvider) {
+			t.Fatalf("case %d: expected invalid service provider, got %v", i, err)
+		}
+	}
+
+	if _, err := f.svc.SaveServiceProvider(f.idp.TenantID+1, f.app.ID, ServiceProviderInput{EntityID: "x", ACSURL: "https://x.example.com"}); !errors.Is(err, ErrServiceProviderNotFound) {
+		t.Fatalf("expected apps of other tenants to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/claimmapping"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 失败响应的二级状态码（SAML Core §3.2.2.2）
+const (
+	StatusRequestDenied       = "urn:oasis:names:tc:SAML:2.0:status:RequestDenied"
+	StatusNoPassive           = "urn:oasis:names:tc:SAML:2.0:status:NoPassive"
+	StatusAuthnFailed         = "urn:oasis:names:tc:SAML:2.0:status:AuthnFailed"
+	StatusInvalidNameIDPolicy = "urn:oasis:names:tc:SAML:2.0:status:InvalidNameIDPolicy"
+
+	statusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	statusResponder = "urn:oasis:names:tc:SAML:2.0:status:Responder"
+)
+
+const (
+	pendingTTL        = 10 * time.Minute
+	pendingTokenType  = "saml_authn_request"
+	assertionLifetime = 5 * time.Minute
+	clockSkew         = time.Minute
+	authnContextPPT   = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+	confirmBearer     = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// defaultAttributes 断言默认携带的用户属性；应用面向 saml 的声明映射可追加或覆盖同名属性
+var defaultAttributes = []model.AppClaimMapping{
+	{ClaimName: "email", Source: model.ClaimSourceProfile, Value: "email"},
+	{ClaimName: "name", Source: model.ClaimSourceProfile, Value: "nickname"},
+	{ClaimName: "user_uuid", Source: model.ClaimSourceProfile, Value: "user_uuid"},
+	{ClaimName: "roles", Source: model.ClaimSourceAppRoles},
+	{ClaimName: "tenant_roles", Source: model.ClaimSourceTenantRoles},
+}
+
+// AuthnRequest 已校验的登录请求；IdP 发起的登录没有请求 ID
+type AuthnRequest struct {
+	ID              string
+	ServiceProvider *model.SAMLServiceProvider
+	RelayState      string
+	ForceAuthn      bool
+	IsPassive       bool
+}
+
+// Response 以 HTTP-POST 绑定发往 SP 断言消费地址的消息
+type Response struct {
+	ACSURL       string
+	SAMLResponse string // base64 编码的 Response
+	RelayState   string
+}
+
+// ParseAuthnRequest 解析并校验 SP 发起的 AuthnRequest
+func (s *Service) ParseAuthnRequest(idp *IdentityProvider, in *Inbound) (*AuthnRequest, error) {
+	// 1. 解码并解析消息
+	data, err := in.decode()
+	if err != nil {
+		return nil, err
+	}
+	var msg authnRequestXML
+	if err := unmarshalMessage(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.ID == "" || msg.Version != "2.0" {
+		return nil, fmt.Errorf("%w: missing ID or unsupported version", ErrInvalidRequest)
+	}
+
+	// 2. 按 Issuer 查找 SP 并校验签名
+	sp, err := s.serviceProviderByEntityID(idp.TenantID, msg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyInbound(sp, in, "SAMLRequest"); err != nil {
+		return nil, err
+	}
+
+	// 3. 断言只投递到登记的消费地址，且只支持 HTTP-POST 绑定
+	if msg.AssertionConsumerServiceURL != "" && msg.AssertionConsumerServiceURL != sp.ACSURL {
+		return nil, ErrUnknownACS
+	}
+	if msg.ProtocolBinding != "" && msg.ProtocolBinding != bindingPOST {
+		return nil, fmt.Errorf("%w: only the HTTP-POST binding is supported for responses", ErrInvalidRequest)
+	}
+
+	req := &AuthnRequest{
+		ID:              msg.ID,
+		ServiceProvider: sp,
+		RelayState:      in.RelayState,
+		ForceAuthn:      msg.ForceAuthn,
+		IsPassive:       msg.IsPassive,
+	}
+	if msg.NameIDPolicy != nil {
+		format := msg.NameIDPolicy.Format
+		if format != "" && format != model.SAMLNameIDFormatUnspecified && format != sp.NameIDFormat {
+			return req, fmt.Errorf("%w: requested name id format %s", ErrNameIDUnavailable, format)
+		}
+	}
+	return req, nil
+}
+
+// IdPInitiatedRequest 为应用的 SP 构造 IdP 发起的登录请求
+func (s *Service) IdPInitiatedRequest(idp *IdentityProvider, appID uint, relayState string) (*AuthnRequest, error) {
+	var sp model.SAMLServiceProvider
+	err := s.db.Preload("App").
+		Where("tenant_id = ? AND app_id = ? AND enabled = ?", idp.TenantID, appID, true).
+		First(&sp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && sp.App.Status != "" && sp.App.Status != model.AppStatusActive {
+		return nil, ErrServiceProviderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sp.AllowIdPInitiated {
+		return nil, ErrIdPInitiatedDisabled
+	}
+	if relayState == "" {
+		relayState = sp.DefaultRelayState
+	}
+	return &AuthnRequest{ServiceProvider: &sp, RelayState: relayState}, nil
+}
+
+// pendingKey 派生待登录请求令牌的签名密钥，与控制台令牌的密钥相互独立
+func pendingKey() []byte {
+	sum := sha256.Sum256(append(common.MustJWTSecret(), []byte(":saml_pending_v1")...))
+	return sum[:]
+}
+
+// EncodePending 将已校验的请求编码为短期令牌，用户登录后凭此继续签发断言，
+// 避免再次校验 Redirect 绑定签名（登录跳转会改变查询串的编码）
+func (s *Service) EncodePending(idp *IdentityProvider, req *AuthnRequest) (string, error) {
+	claims := jwt.MapClaims{
+		"typ": pendingTokenType,
+		"tid": idp.TenantID,
+		"spi": req.ServiceProvider.ID,
+		"rid": req.ID,
+		"rs":  req.RelayState,
+		"fa":  req.ForceAuthn,
+		"ip":  req.IsPassive,
+		"exp": time.Now().Add(pendingTTL).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(pendingKey())
+}
+
+// DecodePending 还原 EncodePending 生成的请求，并重新确认 SP 仍然启用
+func (s *Service) DecodePending(idp *IdentityProvider, token string) (*AuthnRequest, error) {
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return pendingKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		return nil, ErrPendingRequest
+	}
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	if typ, _ := claims["typ"].(string); typ != pendingTokenType {
+		return nil, ErrPendingRequest
+	}
+	if tid, _ := claims["tid"].(float64); uint(tid) != idp.TenantID {
+		return nil, ErrPendingRequest
+	}
+	spID, _ := claims["spi"].(float64)
+
+	var sp model.SAMLServiceProvider
+	if err := s.db.Preload("App").Where("id = ? AND tenant_id = ? AND enabled = ?", uint(spID), idp.TenantID, true).First(&sp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServiceProviderNotFound
+		}
+		return nil, err
+	}
+	req := &AuthnRequest{ServiceProvider: &sp}
+	req.ID, _ = claims["rid"].(string)
+	req.RelayState, _ = claims["rs"].(string)
+	req.ForceAuthn, _ = claims["fa"].(bool)
+	req.IsPassive, _ = claims["ip"].(bool)
+	return req, nil
+}
+
+// IssueResponse 为已登录且通过租户授权判定的用户签发断言，并登记 SAML 会话（SessionIndex）用于单点登出
+func (s *Service) IssueResponse(idp *IdentityProvider, req *AuthnRequest, userID uint, authTime time.Time) (*Response, error) {
+	sp := req.ServiceProvider
+
+	// 1. 确定 NameID
+	var user model.User
+	if err := s.db.Select("id", "user_uuid", "email").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	nameID := user.UserUUID
+	if sp.NameIDFormat == model.SAMLNameIDFormatEmail {
+		nameID = strings.TrimSpace(user.Email)
+	}
+	if nameID == "" {
+		return nil, ErrNameIDUnavailable
+	}
+
+	// 2. 解析属性：默认属性 + 应用面向 saml 的声明映射
+	ctx := &claimmapping.Context{TenantID: idp.TenantID, AppID: sp.AppID, UserID: userID}
+	attributes, err := s.claims.Evaluate(ctx, defaultAttributes)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := s.claims.Resolve(ctx, model.ClaimTargetSAML)
+	if err != nil {
+		return nil, err
+	}
+	if attributes == nil {
+		attributes = make(map[string]interface{})
+	}
+	for name, value := range custom {
+		attributes[name] = value
+	}
+
+	// 3. 登记 SAML 会话
+	now := time.Now()
+	if authTime.IsZero() || authTime.After(now) {
+		authTime = now
+	}
+	session := &model.SAMLSession{
+		SessionIndex:      newID(),
+		ServiceProviderID: sp.ID,
+		UserID:            userID,
+		NameID:            nameID,
+		NameIDFormat:      sp.NameIDFormat,
+		ExpiresAt:         now.Add(time.Duration(sp.SessionLifetimeMins) * time.Minute),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+
+	// 4. 生成并签名断言
+	sign, err := s.signer()
+	if err != nil {
+		return nil, err
+	}
+	assertionID := newID()
+	assertion := newElement("saml:Assertion").xmlns("saml", nsAssertion).
+		attr("ID", assertionID).
+		attr("IssueInstant", formatTime(now)).
+		attr("Version", "2.0").
+		add(
+			newElement("saml:Issuer").setText(idp.EntityID),
+			newElement("saml:Subject").add(
+				newElement("saml:NameID").attr("Format", sp.NameIDFormat).attr("SPNameQualifier", sp.EntityID).setText(nameID),
+				newElement("saml:SubjectConfirmation").attr("Method", confirmBearer).add(
+					newElement("saml:SubjectConfirmationData").
+						attr("InResponseTo", req.ID).
+						attr("NotOnOrAfter", formatTime(now.Add(assertionLifetime))).
+						attr("Recipient", sp.ACSURL),
+				),
+			),
+			newElement("saml:Conditions").
+				attr("NotBefore", formatTime(now.Add(-clockSkew))).
+				attr("NotOnOrAfter", formatTime(now.Add(assertionLifetime))).
+				add(newElement("saml:AudienceRestriction").add(newElement("saml:Audience").setText(sp.EntityID))),
+			newElement("saml:AuthnStatement").
+				attr("AuthnInstant", formatTime(authTime)).
+				attr("SessionIndex", session.SessionIndex).
+				attr("SessionNotOnOrAfter", formatTime(session.ExpiresAt)).
+				add(newElement("saml:AuthnContext").add(newElement("saml:AuthnContextClassRef").setText(authnContextPPT))),
+			attributeStatement(attributes),
+		)
+	if err := sign.signEnveloped(assertion, assertionID); err != nil {
+		return nil, err
+	}
+
+	return s.buildResponse(idp, req, sign, statusSuccess, "", assertion)
+}
+
+// ErrorResponse 生成不含断言的失败响应（如用户不属于应用所在租户、被动登录时未登录）
+func (s *Service) ErrorResponse(idp *IdentityProvider, req *AuthnRequest, status string) (*Response, error) {
+	sign, err := s.signer()
+	if err != nil {
+		return nil, err
+	}
+	return s.buildResponse(idp, req, sign, statusResponder, status, nil)
+}
+
+func (s *Service) buildResponse(idp *IdentityProvider, req *AuthnRequest, sign *signer, status, subStatus string, assertion *element) (*Response, error) {
+	sp := req.ServiceProvider
+	statusCode := newElement("samlp:StatusCode").attr("Value", status)
+	if subStatus != "" {
+		statusCode.add(newElement("samlp:StatusCode").attr("Value", subStatus))
+	}
+
+	responseID := newID()
+	response := newElement("samlp:Response").xmlns("samlp", nsProtocol).
+		attr("Destination", sp.ACSURL).
+		attr("ID", responseID).
+		attr("InResponseTo", req.ID).
+		attr("IssueInstant", formatTime(time.Now())).
+		attr("Version", "2.0").
+		add(
+			newElement("saml:Issuer").xmlns("saml", nsAssertion).setText(idp.EntityID),
+			newElement("samlp:Status").add(statusCode),
+			assertion,
+		)
+	if sp.SignResponse {
+		if err := sign.signEnveloped(response, responseID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Response{
+		ACSURL:       sp.ACSURL,
+		SAMLResponse: base64.StdEncoding.EncodeToString([]byte(xmlHeader + response.String())),
+		RelayState:   req.RelayState,
+	}, nil
+}
+
+// attributeStatement 将属性输出为 AttributeStatement；列表输出为多个 AttributeValue，
+// 对象以 JSON 字符串输出。没有属性时返回 nil（AttributeStatement 不能为空）
+func attributeStatement(attributes map[string]interface{}) *element {
+	if len(attributes) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statement := newElement("saml:AttributeStatement")
+	for _, name := range names {
+		attribute := newElement("saml:Attribute").attr("Name", name).attr("NameFormat", attrFormatBas)
+		for _, value := range attributeValues(attributes[name]) {
+			attribute.add(newElement("saml:AttributeValue").setText(value))
+		}
+		statement.add(attribute)
+	}
+	return statement
+}
+
+func attributeValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []string:
+		return v
+	case []uint:
+		out := make([]string, 0, len(v))
+		for _, n := range v {
+			out = append(out, strconv.FormatUint(uint64(n), 10))
+		}
+		return out
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, attributeValues(item)...)
+		}
+		return out
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return []string{strings.Trim(string(data), `"`)}
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
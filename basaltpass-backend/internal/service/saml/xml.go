@@ -0,0 +1,146 @@
+package saml
+
+import (
+	"sort"
+	"strings"
+)
+
+// SAML 与 XML 签名使用的命名空间和算法标识
+const (
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+
+	algExcC14N    = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnveloped  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA256  = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algSHA256     = "http://www.w3.org/2001/04/xmlenc#sha256"
+	bindingPOST   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	bindingRedir  = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	attrFormatBas = "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"
+)
+
+// element 是待输出的 XML 元素。
+// 渲染结果即其 Exclusive XML Canonicalization（不含注释）形式：属性按名称排序、元素总是写出结束标签、
+// 命名空间只声明在首个使用该前缀的元素上，因此可以直接对输出字节计算摘要，无需通用的规范化实现。
+// 约定：属性均不带前缀；每个元素的前缀必须由自身或祖先声明，兄弟元素之间不共享声明。
+type element struct {
+	name     string
+	ns       [][2]string // 命名空间声明（前缀, URI）
+	attrs    [][2]string
+	children []*element
+	text     string
+}
+
+func newElement(name string) *element {
+	return &element{name: name}
+}
+
+// xmlns 在元素上声明命名空间前缀
+func (e *element) xmlns(prefix, uri string) *element {
+	e.ns = append(e.ns, [2]string{prefix, uri})
+	return e
+}
+
+// attr 设置属性；值为空时不输出
+func (e *element) attr(name, value string) *element {
+	if value == "" {
+		return e
+	}
+	e.attrs = append(e.attrs, [2]string{name, value})
+	return e
+}
+
+func (e *element) add(children ...*element) *element {
+	for _, child := range children {
+		if child != nil {
+			e.children = append(e.children, child)
+		}
+	}
+	return e
+}
+
+func (e *element) setText(text string) *element {
+	e.text = text
+	return e
+}
+
+// insertAfterFirst 将子元素插入到第一个子元素（SAML 中为 Issuer）之后，签名元素按规范位于此处
+func (e *element) insertAfterFirst(child *element) {
+	if len(e.children) == 0 {
+		e.children = []*element{child}
+		return
+	}
+	rest := append([]*element{child}, e.children[1:]...)
+	e.children = append(e.children[:1], rest...)
+}
+
+// String 返回元素的规范化 XML
+func (e *element) String() string {
+	var b strings.Builder
+	e.render(&b)
+	return b.String()
+}
+
+func (e *element) render(b *strings.Builder) {
+	b.WriteByte('<')
+	b.WriteString(e.name)
+
+	ns := append([][2]string(nil), e.ns...)
+	sort.Slice(ns, func(i, j int) bool { return ns[i][0] < ns[j][0] })
+	for _, n := range ns {
+		b.WriteString(" xmlns")
+		if n[0] != "" {
+			b.WriteByte(':')
+			b.WriteString(n[0])
+		}
+		b.WriteString(`="`)
+		b.WriteString(escapeAttr(n[1]))
+		b.WriteByte('"')
+	}
+
+	attrs := append([][2]string(nil), e.attrs...)
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i][0] < attrs[j][0] })
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(a[0])
+		b.WriteString(`="`)
+		b.WriteString(escapeAttr(a[1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+
+	b.WriteString(escapeText(e.text))
+	for _, child := range e.children {
+		child.render(b)
+	}
+
+	b.WriteString("</")
+	b.WriteString(e.name)
+	b.WriteByte('>')
+}
+
+// escapeText 按 C14N 规则转义文本节点
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+// escapeAttr 按 C14N 规则转义属性值
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(stripInvalidXMLChars(s))
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(stripInvalidXMLChars(s))
+}
+
+// stripInvalidXMLChars 去除 XML 1.0 不允许出现的控制字符，避免用户资料中的异常字符导致文档不合法
+func stripInvalidXMLChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r != 0xFFFE && r != 0xFFFF {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,299 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BulkOperation Bulk 请求中的单个操作（RFC 7644 §3.7）
+type BulkOperation struct {
+	Method string          `json:"method"`
+	BulkID string          `json:"bulkId,omitempty"`
+	Path   string          `json:"path"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// BulkRequest Bulk 请求体
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+// BulkOperationResult 单个操作的结果
+type BulkOperationResult struct {
+	Method   string      `json:"method"`
+	BulkID   string      `json:"bulkId,omitempty"`
+	Location string      `json:"location,omitempty"`
+	Version  string      `json:"version,omitempty"`
+	Status   string      `json:"status"`
+	Response interface{} `json:"response,omitempty"`
+}
+
+// BulkResponse Bulk 响应体
+type BulkResponse struct {
+	Schemas    []string              `json:"schemas"`
+	Operations []BulkOperationResult `json:"Operations"`
+}
+
+const bulkIDPrefix = "bulkId:"
+
+// Bulk 依次执行批量操作。引用尚未创建资源（"bulkId:xxx"）的操作会推迟到被引用的 POST 完成后执行；
+// 无法解析的引用（含循环引用）返回 409。failOnErrors 大于 0 时错误数达到该值即停止
+func (s *Service) Bulk(req *BulkRequest) (*BulkResponse, error) {
+	if !containsSchema(req.Schemas, SchemaBulkRequest) {
+		return nil, newError(http.StatusBadRequest, "invalidSyntax", "schemas must contain %s", SchemaBulkRequest)
+	}
+	if len(req.Operations) > maxBulkOperations {
+		return nil, newError(http.StatusRequestEntityTooLarge, "tooMany", "at most %d operations are allowed", maxBulkOperations)
+	}
+
+	resolved := make(map[string]string) // bulkId -> 资源 id
+	declared := make(map[string]bool)
+	for _, op := range req.Operations {
+		if op.BulkID != "" {
+			declared[op.BulkID] = true
+		}
+	}
+
+	results := make([]*BulkOperationResult, len(req.Operations))
+	pending := make([]int, len(req.Operations))
+	for i := range pending {
+		pending[i] = i
+	}
+	errorCount := 0
+	for len(pending) > 0 {
+		var deferred []int
+		for _, i := range pending {
+			op := req.Operations[i]
+			if refs := unresolvedRefs(op, resolved); len(refs) > 0 {
+				waiting := false
+				for _, ref := range refs {
+					if declared[ref] {
+						waiting = true
+					}
+				}
+				if waiting {
+					deferred = append(deferred, i)
+					continue
+				}
+				results[i] = bulkError(op, newError(http.StatusConflict, "invalidValue", "unknown bulkId reference %s", refs[0]))
+			} else {
+				results[i] = s.bulkExecute(op, resolved)
+			}
+			if results[i].Response != nil {
+				errorCount++
+				if req.FailOnErrors > 0 && errorCount >= req.FailOnErrors {
+					return bulkResponse(results), nil
+				}
+			}
+		}
+		if len(deferred) == len(pending) {
+			// 没有进展：剩余操作的引用无法满足（循环引用或引用的 POST 失败）
+			for _, i := range deferred {
+				op := req.Operations[i]
+				results[i] = bulkError(op, newError(http.StatusConflict, "invalidValue", "bulkId references could not be resolved"))
+			}
+			break
+		}
+		pending = deferred
+	}
+	return bulkResponse(results), nil
+}
+
+func bulkResponse(results []*BulkOperationResult) *BulkResponse {
+	resp := &BulkResponse{Schemas: []string{SchemaBulkResponse}, Operations: make([]BulkOperationResult, 0, len(results))}
+	for _, r := range results {
+		if r != nil {
+			resp.Operations = append(resp.Operations, *r)
+		}
+	}
+	return resp
+}
+
+// bulkExecute 执行单个操作；失败时结果中带 SCIM 错误体
+func (s *Service) bulkExecute(op BulkOperation, resolved map[string]string) *BulkOperationResult {
+	method := strings.ToUpper(strings.TrimSpace(op.Method))
+	op.Method = method
+	path := replaceRefs(op.Path, resolved).(string)
+	resourceType, id := splitBulkPath(path)
+	if resourceType == "" || (method == http.MethodPost) != (id == "") {
+		return bulkError(op, newError(http.StatusBadRequest, "invalidPath", "invalid path %q", op.Path))
+	}
+	if method == http.MethodPost && op.BulkID == "" {
+		return bulkError(op, newError(http.StatusBadRequest, "invalidValue", "bulkId is required for POST"))
+	}
+
+	var data interface{}
+	if method != http.MethodDelete {
+		if len(op.Data) == 0 {
+			return bulkError(op, newError(http.StatusBadRequest, "invalidSyntax", "data is required"))
+		}
+		if err := json.Unmarshal(op.Data, &data); err != nil {
+			return bulkError(op, newError(http.StatusBadRequest, "invalidSyntax", "invalid data"))
+		}
+		data = replaceRefs(data, resolved)
+	}
+	resource, _ := data.(map[string]interface{})
+	if method != http.MethodDelete && resource == nil {
+		return bulkError(op, newError(http.StatusBadRequest, "invalidSyntax", "data must be an object"))
+	}
+
+	var result Resource
+	var err error
+	status := http.StatusOK
+	switch method {
+	case http.MethodPost:
+		status = http.StatusCreated
+		if resourceType == "Users" {
+			result, err = s.CreateUser(resource)
+		} else {
+			result, err = s.CreateGroup(resource)
+		}
+	case http.MethodPut:
+		if resourceType == "Users" {
+			result, err = s.ReplaceUser(id, resource)
+		} else {
+			result, err = s.ReplaceGroup(id, resource)
+		}
+	case http.MethodPatch:
+		var patch PatchRequest
+		raw, _ := json.Marshal(resource)
+		if err = json.Unmarshal(raw, &patch); err != nil {
+			err = newError(http.StatusBadRequest, "invalidSyntax", "invalid patch request")
+			break
+		}
+		if resourceType == "Users" {
+			result, err = s.PatchUser(id, &patch)
+		} else {
+			result, err = s.PatchGroup(id, &patch)
+		}
+	case http.MethodDelete:
+		status = http.StatusNoContent
+		if resourceType == "Users" {
+			err = s.DeleteUser(id)
+		} else {
+			err = s.DeleteGroup(id)
+		}
+	default:
+		err = newError(http.StatusMethodNotAllowed, "", "unsupported method %q", op.Method)
+	}
+	if err != nil {
+		return bulkError(op, err)
+	}
+
+	out := &BulkOperationResult{Method: method, BulkID: op.BulkID, Status: strconv.Itoa(status)}
+	if result != nil {
+		if m, ok := result["meta"].(map[string]interface{}); ok {
+			out.Location, _ = m["location"].(string)
+			out.Version, _ = m["version"].(string)
+		}
+		if method == http.MethodPost {
+			resolved[op.BulkID], _ = result["id"].(string)
+		}
+	} else if method == http.MethodDelete {
+		out.Location = s.baseURL + "/" + resourceType + "/" + id
+	}
+	return out
+}
+
+func bulkError(op BulkOperation, err error) *BulkOperationResult {
+	var scimErr *Error
+	if !errors.As(err, &scimErr) {
+		scimErr = newError(http.StatusInternalServerError, "", "internal error")
+	}
+	return &BulkOperationResult{
+		Method:   strings.ToUpper(op.Method),
+		BulkID:   op.BulkID,
+		Status:   strconv.Itoa(scimErr.Status),
+		Response: scimErr.Body(),
+	}
+}
+
+// splitBulkPath "/Users" -> ("Users", "")，"/Groups/team-1" -> ("Groups", "team-1")
+func splitBulkPath(path string) (string, string) {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(path), "/"), "/")
+	if len(parts) == 0 || len(parts) > 2 {
+		return "", ""
+	}
+	var resourceType string
+	switch strings.ToLower(parts[0]) {
+	case "users":
+		resourceType = "Users"
+	case "groups":
+		resourceType = "Groups"
+	default:
+		return "", ""
+	}
+	if len(parts) == 2 {
+		return resourceType, parts[1]
+	}
+	return resourceType, ""
+}
+
+// unresolvedRefs 操作中尚未解析的 bulkId 引用
+func unresolvedRefs(op BulkOperation, resolved map[string]string) []string {
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			if strings.HasPrefix(t, bulkIDPrefix) {
+				if _, ok := resolved[t[len(bulkIDPrefix):]]; !ok {
+					refs = append(refs, t[len(bulkIDPrefix):])
+				}
+			}
+		case []interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		case map[string]interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		}
+	}
+	for _, segment := range strings.Split(op.Path, "/") {
+		walk(segment)
+	}
+	if len(op.Data) > 0 {
+		var data interface{}
+		if json.Unmarshal(op.Data, &data) == nil {
+			walk(data)
+		}
+	}
+	return refs
+}
+
+// replaceRefs 将 "bulkId:xxx" 替换为已创建资源的 id
+func replaceRefs(v interface{}, resolved map[string]string) interface{} {
+	switch t := v.(type) {
+	case string:
+		if strings.Contains(t, bulkIDPrefix) {
+			parts := strings.Split(t, "/")
+			for i, part := range parts {
+				if strings.HasPrefix(part, bulkIDPrefix) {
+					if id, ok := resolved[part[len(bulkIDPrefix):]]; ok {
+						parts[i] = id
+					}
+				}
+			}
+			return strings.Join(parts, "/")
+		}
+		return t
+	case []interface{}:
+		for i, item := range t {
+			t[i] = replaceRefs(item, resolved)
+		}
+		return t
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = replaceRefs(item, resolved)
+		}
+		return t
+	}
+	return v
+}
@@ -0,0 +1,183 @@
+package scim
+
+import "net/http"
+
+// 发现端点（RFC 7644 §4）：ServiceProviderConfig、ResourceTypes、Schemas
+
+// ServiceProviderConfig 服务能力声明
+func (s *Service) ServiceProviderConfig() Resource {
+	return Resource{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]interface{}{"supported": true},
+		"bulk": map[string]interface{}{
+			"supported":      true,
+			"maxOperations":  maxBulkOperations,
+			"maxPayloadSize": MaxBulkPayload,
+		},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxListCount},
+		"changePassword": map[string]interface{}{"supported": false},
+		"sort":           map[string]interface{}{"supported": false},
+		"etag":           map[string]interface{}{"supported": false},
+		"authenticationSchemes": []interface{}{
+			map[string]interface{}{
+				"type":        "oauthbearertoken",
+				"name":        "Tenant API Key",
+				"description": "Authentication with a tenant-scoped API key sent as a bearer token",
+				"primary":     true,
+			},
+		},
+		"meta": map[string]interface{}{
+			"resourceType": "ServiceProviderConfig",
+			"location":     s.baseURL + "/ServiceProviderConfig",
+		},
+	}
+}
+
+// ResourceTypes 支持的资源类型
+func (s *Service) ResourceTypes() []Resource {
+	return []Resource{
+		{
+			"schemas":     []string{SchemaResourceType},
+			"id":          "User",
+			"name":        "User",
+			"endpoint":    "/Users",
+			"description": "Tenant user account",
+			"schema":      SchemaUser,
+			"meta":        map[string]interface{}{"resourceType": "ResourceType", "location": s.baseURL + "/ResourceTypes/User"},
+		},
+		{
+			"schemas":     []string{SchemaResourceType},
+			"id":          "Group",
+			"name":        "Group",
+			"endpoint":    "/Groups",
+			"description": "Tenant team or role",
+			"schema":      SchemaGroup,
+			"schemaExtensions": []interface{}{
+				map[string]interface{}{"schema": SchemaGroupExtension, "required": false},
+			},
+			"meta": map[string]interface{}{"resourceType": "ResourceType", "location": s.baseURL + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+// ResourceType 按 id 查询资源类型
+func (s *Service) ResourceType(id string) (Resource, error) {
+	for _, rt := range s.ResourceTypes() {
+		if rt["id"] == id {
+			return rt, nil
+		}
+	}
+	return nil, errNotFound("ResourceType", id)
+}
+
+// Schemas 支持的架构定义
+func (s *Service) Schemas() []Resource {
+	schemas := []Resource{
+		{
+			"id":          SchemaUser,
+			"name":        "User",
+			"description": "User Account",
+			"attributes": []interface{}{
+				attribute("userName", "string", true, "readWrite", "server"),
+				complexAttribute("name", false, []interface{}{
+					attribute("givenName", "string", false, "readWrite", "none"),
+					attribute("familyName", "string", false, "readWrite", "none"),
+					attribute("formatted", "string", false, "readOnly", "none"),
+				}),
+				attribute("displayName", "string", false, "readWrite", "none"),
+				attribute("nickName", "string", false, "readWrite", "none"),
+				attribute("title", "string", false, "readWrite", "none"),
+				attribute("timezone", "string", false, "readWrite", "none"),
+				attribute("active", "boolean", false, "readWrite", "none"),
+				writeOnly(attribute("password", "string", false, "writeOnly", "none")),
+				complexAttribute("emails", true, []interface{}{
+					attribute("value", "string", false, "readWrite", "none"),
+					attribute("type", "string", false, "readWrite", "none"),
+					attribute("primary", "boolean", false, "readWrite", "none"),
+				}),
+				complexAttribute("phoneNumbers", true, []interface{}{
+					attribute("value", "string", false, "readWrite", "none"),
+					attribute("type", "string", false, "readWrite", "none"),
+					attribute("primary", "boolean", false, "readWrite", "none"),
+				}),
+				readOnly(complexAttribute("groups", true, []interface{}{
+					attribute("value", "string", false, "readOnly", "none"),
+					attribute("$ref", "reference", false, "readOnly", "none"),
+					attribute("display", "string", false, "readOnly", "none"),
+				})),
+			},
+		},
+		{
+			"id":          SchemaGroup,
+			"name":        "Group",
+			"description": "Group",
+			"attributes": []interface{}{
+				attribute("displayName", "string", true, "readWrite", "none"),
+				complexAttribute("members", true, []interface{}{
+					attribute("value", "string", false, "immutable", "none"),
+					attribute("$ref", "reference", false, "immutable", "none"),
+					attribute("display", "string", false, "readOnly", "none"),
+				}),
+			},
+		},
+		{
+			"id":          SchemaGroupExtension,
+			"name":        "BasaltPassGroup",
+			"description": "BasaltPass group extension",
+			"attributes": []interface{}{
+				attribute("type", "string", false, "immutable", "none"),
+				attribute("code", "string", false, "readWrite", "server"),
+				attribute("description", "string", false, "readWrite", "none"),
+			},
+		},
+	}
+	for _, schema := range schemas {
+		schema["schemas"] = []string{SchemaSchema}
+		schema["meta"] = map[string]interface{}{
+			"resourceType": "Schema",
+			"location":     s.baseURL + "/Schemas/" + schema["id"].(string),
+		}
+	}
+	return schemas
+}
+
+// Schema 按 URN 查询架构定义
+func (s *Service) Schema(id string) (Resource, error) {
+	for _, schema := range s.Schemas() {
+		if schema["id"] == id {
+			return schema, nil
+		}
+	}
+	return nil, newError(http.StatusNotFound, "", "Schema %s not found", id)
+}
+
+func attribute(name, typ string, required bool, mutability, uniqueness string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"type":        typ,
+		"multiValued": false,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  uniqueness,
+	}
+}
+
+func complexAttribute(name string, multiValued bool, subAttributes []interface{}) map[string]interface{} {
+	attr := attribute(name, "complex", false, "readWrite", "none")
+	attr["multiValued"] = multiValued
+	attr["subAttributes"] = subAttributes
+	return attr
+}
+
+func readOnly(attr map[string]interface{}) map[string]interface{} {
+	attr["mutability"] = "readOnly"
+	return attr
+}
+
+func writeOnly(attr map[string]interface{}) map[string]interface{} {
+	attr["returned"] = "never"
+	return attr
+}
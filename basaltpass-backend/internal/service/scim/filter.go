@@ -0,0 +1,590 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// 扩展架构：属性路径可以 "<urn>:attr" 的形式引用扩展对象中的属性
+var extensionSchemas = []string{SchemaGroupExtension}
+
+func errInvalidFilter(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "invalidFilter", format, args...)
+}
+
+// attrPath 属性路径：[扩展URN:]attr[.sub]
+type attrPath struct {
+	ext  string
+	attr string
+	sub  string
+}
+
+func parseAttrPath(raw string) (attrPath, bool) {
+	raw = strings.TrimSpace(raw)
+	var p attrPath
+	lower := strings.ToLower(raw)
+	for _, schema := range extensionSchemas {
+		s := strings.ToLower(schema)
+		if lower == s {
+			return attrPath{ext: schema}, true
+		}
+		if strings.HasPrefix(lower, s+":") {
+			p.ext = schema
+			raw = raw[len(schema)+1:]
+			break
+		}
+	}
+	if p.ext == "" {
+		raw = stripSchema(raw)
+	}
+	if raw == "" || strings.HasPrefix(strings.ToLower(raw), "urn:") {
+		return attrPath{}, false
+	}
+	p.attr = raw
+	if i := strings.IndexByte(raw, '.'); i >= 0 {
+		p.attr, p.sub = raw[:i], raw[i+1:]
+		if p.attr == "" || p.sub == "" || strings.ContainsAny(p.sub, ".") {
+			return attrPath{}, false
+		}
+	}
+	return p, true
+}
+
+// container 返回路径所在的对象（核心属性为资源本身，扩展属性为扩展对象）
+func (p attrPath) container(r map[string]interface{}, create bool) map[string]interface{} {
+	if p.ext == "" {
+		return r
+	}
+	ext := mapAttr(r, p.ext)
+	if ext == nil && create {
+		ext = make(map[string]interface{})
+		setAttr(r, p.ext, ext)
+	}
+	return ext
+}
+
+// values 取路径上的全部取值；多值属性的子属性按元素展开，省略子属性时取元素的 value
+func (p attrPath) values(r map[string]interface{}) []interface{} {
+	c := p.container(r, false)
+	if c == nil {
+		return nil
+	}
+	v, ok := getAttr(c, p.attr)
+	if !ok || v == nil {
+		return nil
+	}
+	var out []interface{}
+	collect := func(item interface{}) {
+		if obj, ok := item.(map[string]interface{}); ok {
+			sub := p.sub
+			if sub == "" {
+				sub = "value"
+			}
+			if sv, ok := getAttr(obj, sub); ok && sv != nil {
+				out = append(out, sv)
+			}
+			return
+		}
+		if p.sub == "" {
+			out = append(out, item)
+		}
+	}
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			collect(item)
+		}
+		return out
+	}
+	collect(v)
+	return out
+}
+
+// Filter 已解析的过滤表达式
+type Filter interface {
+	Match(r map[string]interface{}) bool
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right Filter
+}
+
+func (f *logicalFilter) Match(r map[string]interface{}) bool {
+	if f.and {
+		return f.left.Match(r) && f.right.Match(r)
+	}
+	return f.left.Match(r) || f.right.Match(r)
+}
+
+type notFilter struct{ inner Filter }
+
+func (f *notFilter) Match(r map[string]interface{}) bool { return !f.inner.Match(r) }
+
+// valuePathFilter emails[type eq "work"]：多值属性中任一元素满足子表达式
+type valuePathFilter struct {
+	path  attrPath
+	inner Filter
+}
+
+func (f *valuePathFilter) Match(r map[string]interface{}) bool {
+	for _, item := range elements(f.path, r) {
+		if f.inner.Match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// elements 取多值属性的各个元素（单个复杂属性视为一个元素）
+func elements(p attrPath, r map[string]interface{}) []map[string]interface{} {
+	c := p.container(r, false)
+	if c == nil {
+		return nil
+	}
+	v, _ := getAttr(c, p.attr)
+	var out []map[string]interface{}
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			if obj, ok := item.(map[string]interface{}); ok {
+				out = append(out, obj)
+			}
+		}
+	case map[string]interface{}:
+		out = append(out, t)
+	}
+	return out
+}
+
+type compareFilter struct {
+	path  attrPath
+	op    string
+	value interface{}
+}
+
+func (f *compareFilter) Match(r map[string]interface{}) bool {
+	values := f.path.values(r)
+	if f.op == "pr" {
+		for _, v := range values {
+			if s, ok := v.(string); !ok || s != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if f.op == "ne" {
+		for _, v := range values {
+			if compare(v, "eq", f.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if compare(v, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare 字符串比较不区分大小写（RFC 7643 中除 id 等少数属性外均为 caseExact=false）；
+// 时间为统一格式的 UTC RFC 3339 字符串，可按字典序比较
+func compare(actual interface{}, op string, expected interface{}) bool {
+	switch e := expected.(type) {
+	case nil:
+		return false
+	case bool:
+		a, ok := actual.(bool)
+		if !ok {
+			if s, isString := actual.(string); isString {
+				a, ok = strings.EqualFold(s, "true"), strings.EqualFold(s, "true") || strings.EqualFold(s, "false")
+			}
+		}
+		return ok && op == "eq" && a == e
+	case float64:
+		a, ok := toFloat(actual)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+		return false
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		a, b := strings.ToLower(a), strings.ToLower(e)
+		switch op {
+		case "eq":
+			return a == b
+		case "co":
+			return strings.Contains(a, b)
+		case "sw":
+			return strings.HasPrefix(a, b)
+		case "ew":
+			return strings.HasSuffix(a, b)
+		case "gt":
+			return a > b
+		case "ge":
+			return a >= b
+		case "lt":
+			return a < b
+		case "le":
+			return a <= b
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// ParseFilter 解析 RFC 7644 §3.4.2.2 过滤表达式
+func ParseFilter(raw string) (Filter, error) {
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, errInvalidFilter("unexpected %q", p.peek().text)
+	}
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool // 字符串字面量
+}
+
+func tokenize(raw string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(raw); {
+		ch := raw[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '(' || ch == ')' || ch == '[' || ch == ']':
+			tokens = append(tokens, token{text: string(ch)})
+			i++
+		case ch == '"':
+			j := i + 1
+			for ; j < len(raw); j++ {
+				if raw[j] == '\\' {
+					j++
+					continue
+				}
+				if raw[j] == '"' {
+					break
+				}
+			}
+			if j >= len(raw) {
+				return nil, errInvalidFilter("unterminated string")
+			}
+			var s string
+			if err := json.Unmarshal([]byte(raw[i:j+1]), &s); err != nil {
+				return nil, errInvalidFilter("invalid string literal")
+			}
+			tokens = append(tokens, token{text: s, quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(raw) && !strings.ContainsRune(" \t\r\n()[]\"", rune(raw[j])) {
+				j++
+			}
+			tokens = append(tokens, token{text: raw[i:j]})
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errInvalidFilter("empty filter")
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *filterParser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// keyword 下一个 token 是否为（不区分大小写的）关键字
+func (p *filterParser) keyword(word string) bool {
+	t := p.peek()
+	return !t.quoted && strings.EqualFold(t.text, word)
+}
+
+func (p *filterParser) expect(text string) error {
+	if t := p.next(); t.quoted || t.text != text {
+		return errInvalidFilter("expected %q", text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (Filter, error) {
+	if p.keyword("not") {
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &notFilter{inner: inner}, nil
+	}
+	if t := p.peek(); !t.quoted && t.text == "(" {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return p.parseAttrExpr()
+}
+
+func (p *filterParser) parseAttrExpr() (Filter, error) {
+	t := p.next()
+	if t.quoted || t.text == "" {
+		return nil, errInvalidFilter("expected attribute path")
+	}
+	path, ok := parseAttrPath(t.text)
+	if !ok {
+		return nil, errInvalidFilter("invalid attribute path %q", t.text)
+	}
+
+	// valuePath: attr[子表达式]
+	if next := p.peek(); !next.quoted && next.text == "[" {
+		if path.sub != "" {
+			return nil, errInvalidFilter("invalid attribute path %q", t.text)
+		}
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &valuePathFilter{path: path, inner: inner}, nil
+	}
+
+	opToken := p.next()
+	op := strings.ToLower(opToken.text)
+	if opToken.quoted {
+		return nil, errInvalidFilter("expected operator")
+	}
+	switch op {
+	case "pr":
+		return &compareFilter{path: path, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "lt", "ge", "le":
+	default:
+		return nil, errInvalidFilter("unsupported operator %q", opToken.text)
+	}
+
+	value, err := literal(p.next())
+	if err != nil {
+		return nil, err
+	}
+	return &compareFilter{path: path, op: op, value: value}, nil
+}
+
+func literal(t token) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	var n float64
+	if err := json.Unmarshal([]byte(t.text), &n); err != nil {
+		return nil, errInvalidFilter("invalid comparison value %q", t.text)
+	}
+	return n, nil
+}
+
+// equalityValue 若过滤式为 attr eq "value"（或以 and 连接的其中一项），返回该值，用于预先缩小查询范围
+func equalityValue(f Filter, attr string) (string, bool) {
+	switch t := f.(type) {
+	case *compareFilter:
+		if t.op == "eq" && t.path.ext == "" && t.path.sub == "" && strings.EqualFold(t.path.attr, attr) {
+			s, ok := t.value.(string)
+			return s, ok
+		}
+	case *logicalFilter:
+		if t.and {
+			if v, ok := equalityValue(t.left, attr); ok {
+				return v, true
+			}
+			return equalityValue(t.right, attr)
+		}
+	}
+	return "", false
+}
+
+// sqlColumn 可下推到数据库求值的属性
+type sqlColumn struct {
+	expr    string // 列表达式；字符串列须已处理 NULL
+	boolean bool
+	negate  bool // 布尔列与属性取值相反（如 banned 与 active）
+}
+
+// sqlCondition 将过滤式翻译为 SQL 条件，语义与 Match 一致（字符串不区分大小写）。
+// 含无法翻译的属性、运算符或取值时返回 false，由调用方在内存中求值
+func sqlCondition(f Filter, columns map[string]sqlColumn) (string, []interface{}, bool) {
+	switch t := f.(type) {
+	case *logicalFilter:
+		left, leftArgs, ok := sqlCondition(t.left, columns)
+		if !ok {
+			return "", nil, false
+		}
+		right, rightArgs, ok := sqlCondition(t.right, columns)
+		if !ok {
+			return "", nil, false
+		}
+		op := " OR "
+		if t.and {
+			op = " AND "
+		}
+		return "(" + left + op + right + ")", append(leftArgs, rightArgs...), true
+	case *notFilter:
+		inner, args, ok := sqlCondition(t.inner, columns)
+		if !ok {
+			return "", nil, false
+		}
+		return "NOT " + inner, args, true
+	case *compareFilter:
+		if t.path.ext != "" {
+			return "", nil, false
+		}
+		key := strings.ToLower(t.path.attr)
+		if t.path.sub != "" {
+			key += "." + strings.ToLower(t.path.sub)
+		}
+		col, ok := columns[key]
+		if !ok {
+			return "", nil, false
+		}
+		if col.boolean {
+			v, ok := t.value.(bool)
+			if !ok || (t.op != "eq" && t.op != "ne") {
+				return "", nil, false
+			}
+			if t.op == "ne" {
+				v = !v
+			}
+			return "(" + col.expr + " = ?)", []interface{}{v != col.negate}, true
+		}
+		v, ok := t.value.(string)
+		// 空字符串与缺省属性在内存求值中语义不同，不下推
+		if !ok || v == "" {
+			return "", nil, false
+		}
+		v = strings.ToLower(v)
+		like := "(LOWER(" + col.expr + ") LIKE ? ESCAPE '!')"
+		switch t.op {
+		case "eq":
+			return "(LOWER(" + col.expr + ") = ?)", []interface{}{v}, true
+		case "ne":
+			return "(LOWER(" + col.expr + ") <> ?)", []interface{}{v}, true
+		case "co":
+			return like, []interface{}{"%" + escapeLike(v) + "%"}, true
+		case "sw":
+			return like, []interface{}{escapeLike(v) + "%"}, true
+		case "ew":
+			return like, []interface{}{"%" + escapeLike(v)}, true
+		}
+	}
+	return "", nil, false
+}
+
+// escapeLike 转义 LIKE 模式中的通配符（转义符为 !）
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
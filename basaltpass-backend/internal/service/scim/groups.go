@@ -0,0 +1,584 @@
+package scim
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// SCIM 组映射为租户下的团队或租户角色，id 为 "team-<id>" / "role-<id>"；
+// 创建时通过扩展属性 type 选择类型，缺省为团队
+func groupID(kind string, id uint) string {
+	return kind + "-" + strconv.FormatUint(uint64(id), 10)
+}
+
+func parseGroupID(raw string) (string, uint, bool) {
+	kind, idPart, ok := strings.Cut(strings.ToLower(strings.TrimSpace(raw)), "-")
+	if !ok || (kind != model.SCIMGroupKindTeam && kind != model.SCIMGroupKindRole) {
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil || id == 0 {
+		return "", 0, false
+	}
+	return kind, uint(id), true
+}
+
+// group 团队或角色的统一视图
+type group struct {
+	kind        string
+	id          uint
+	name        string
+	code        string
+	description string
+	isSystem    bool
+	createdAt   time.Time
+	updatedAt   time.Time
+}
+
+type groupInput struct {
+	externalID  string
+	displayName string
+	kind        string
+	code        string
+	description string
+	memberIDs   []uint
+}
+
+// parseGroup 校验并读取 Group 资源，成员按用户 UUID 解析为本租户的用户
+func (s *Service) parseGroup(r Resource) (*groupInput, error) {
+	in := &groupInput{
+		externalID:  stringAttr(r, "externalId"),
+		displayName: stringAttr(r, "displayName"),
+		kind:        model.SCIMGroupKindTeam,
+	}
+	if in.displayName == "" {
+		return nil, errInvalidValue("displayName is required")
+	}
+	if len(in.displayName) > 100 || len(in.externalID) > 255 {
+		return nil, errInvalidValue("displayName must not exceed 100 characters")
+	}
+	if ext := mapAttr(r, SchemaGroupExtension); ext != nil {
+		if kind := strings.ToLower(stringAttr(ext, "type")); kind != "" {
+			if kind != model.SCIMGroupKindTeam && kind != model.SCIMGroupKindRole {
+				return nil, errInvalidValue("type must be team or role")
+			}
+			in.kind = kind
+		}
+		in.code = stringAttr(ext, "code")
+		in.description = utils.Truncate(stringAttr(ext, "description"), 500)
+	}
+
+	var uuids []string
+	seen := make(map[string]bool)
+	for _, item := range listAttr(r, "members") {
+		value := strings.ToLower(elementValue(item))
+		if value == "" {
+			return nil, errInvalidValue("members must have a value")
+		}
+		if !seen[value] {
+			seen[value] = true
+			uuids = append(uuids, value)
+		}
+	}
+	if len(uuids) > 0 {
+		var users []model.User
+		if err := s.db.Select("id", "user_uuid").
+			Where("tenant_id = ? AND LOWER(user_uuid) IN ?", s.tenantID, uuids).
+			Find(&users).Error; err != nil {
+			return nil, err
+		}
+		if len(users) != len(uuids) {
+			found := make(map[string]bool, len(users))
+			for _, u := range users {
+				found[strings.ToLower(u.UserUUID)] = true
+			}
+			for _, id := range uuids {
+				if !found[id] {
+					return nil, errInvalidValue("member %s is not a user of this tenant", id)
+				}
+			}
+		}
+		for _, u := range users {
+			in.memberIDs = append(in.memberIDs, u.ID)
+		}
+	}
+	return in, nil
+}
+
+// roleCode 角色代码：扩展属性 code，缺省由 displayName 生成
+func roleCode(in *groupInput) (string, error) {
+	code := in.code
+	if code == "" {
+		var b strings.Builder
+		for _, r := range strings.ToLower(in.displayName) {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+				b.WriteRune(r)
+			case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+				b.WriteByte('_')
+			}
+		}
+		code = strings.Trim(b.String(), "_")
+	}
+	if len(code) < 2 || len(code) > 64 {
+		return "", errInvalidValue("role code must be 2-64 characters; set %s:code", SchemaGroupExtension)
+	}
+	return code, nil
+}
+
+// ListGroups 列出租户的团队与角色
+func (s *Service) ListGroups(params ListParams) (*ListResponse, error) {
+	filter, err := parseListFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	teamQuery := s.db.Where("tenant_id = ?", s.tenantID)
+	roleQuery := s.db.Where("tenant_id = ?", s.tenantID)
+	if filter != nil {
+		if v, ok := equalityValue(filter, "displayName"); ok {
+			teamQuery = teamQuery.Where("LOWER(name) = ?", strings.ToLower(v))
+			roleQuery = roleQuery.Where("LOWER(name) = ?", strings.ToLower(v))
+		}
+	}
+
+	var teams []model.Team
+	if err := teamQuery.Order("id ASC").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	var roles []model.TenantRbacRole
+	if err := roleQuery.Order("id ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	groups := make([]group, 0, len(teams)+len(roles))
+	for i := range teams {
+		groups = append(groups, teamGroup(&teams[i]))
+	}
+	for i := range roles {
+		groups = append(groups, roleGroup(&roles[i]))
+	}
+
+	// 未请求成员时不必加载（Azure AD 等客户端常以 excludedAttributes=members 查询）
+	withMembers := !excludesAttribute(params, "members")
+	resources, err := s.groupResources(groups, withMembers)
+	if err != nil {
+		return nil, err
+	}
+	return list(resources, filter, params), nil
+}
+
+func excludesAttribute(params ListParams, name string) bool {
+	for _, a := range params.ExcludedAttributes {
+		if strings.EqualFold(topLevelAttr(a), name) {
+			return true
+		}
+	}
+	if len(params.Attributes) == 0 {
+		return false
+	}
+	for _, a := range params.Attributes {
+		if strings.EqualFold(topLevelAttr(a), name) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetGroup 获取组
+func (s *Service) GetGroup(id string) (Resource, error) {
+	g, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.groupResources([]group{*g}, true)
+	if err != nil {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// CreateGroup 创建团队或租户角色
+func (s *Service) CreateGroup(r Resource) (Resource, error) {
+	in, err := s.parseGroup(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var id uint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		switch in.kind {
+		case model.SCIMGroupKindRole:
+			code, err := roleCode(in)
+			if err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&model.TenantRbacRole{}).Where("tenant_id = ? AND code = ?", s.tenantID, code).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errUniqueness("role %s already exists", code)
+			}
+			role := model.TenantRbacRole{TenantID: s.tenantID, Code: code, Name: in.displayName, Description: in.description}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+			id = role.ID
+		default:
+			var count int64
+			if err := tx.Model(&model.Team{}).Where("tenant_id = ? AND LOWER(name) = ?", s.tenantID, strings.ToLower(in.displayName)).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errUniqueness("group %s already exists", in.displayName)
+			}
+			team := model.Team{TenantID: s.tenantID, Name: in.displayName, Description: in.description, IsActive: true}
+			if err := tx.Create(&team).Error; err != nil {
+				return err
+			}
+			id = team.ID
+		}
+		if err := s.syncMembers(tx, in.kind, id, in.memberIDs); err != nil {
+			return err
+		}
+		return s.saveGroupLink(tx, in.kind, id, in.externalID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(groupID(in.kind, id))
+}
+
+// ReplaceGroup 整体替换组（PUT），成员按请求内容同步
+func (s *Service) ReplaceGroup(id string, r Resource) (Resource, error) {
+	g, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	in, err := s.parseGroup(r)
+	if err != nil {
+		return nil, err
+	}
+	if ext := mapAttr(r, SchemaGroupExtension); ext != nil && stringAttr(ext, "type") != "" && in.kind != g.kind {
+		return nil, errMutability("group type cannot be changed")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		switch g.kind {
+		case model.SCIMGroupKindRole:
+			updates := map[string]interface{}{"name": in.displayName, "description": in.description}
+			if in.code != "" && in.code != g.code {
+				code, err := roleCode(in)
+				if err != nil {
+					return err
+				}
+				updates["code"] = code
+			}
+			if g.isSystem && (in.displayName != g.name || updates["code"] != nil) {
+				return errMutability("system roles cannot be renamed")
+			}
+			if err := tx.Model(&model.TenantRbacRole{}).Where("id = ?", g.id).Updates(updates).Error; err != nil {
+				if isUniqueViolation(err) {
+					return errUniqueness("role %s already exists", in.code)
+				}
+				return err
+			}
+		default:
+			if err := tx.Model(&model.Team{}).Where("id = ?", g.id).
+				Updates(map[string]interface{}{"name": in.displayName, "description": in.description}).Error; err != nil {
+				return err
+			}
+		}
+		if err := s.syncMembers(tx, g.kind, g.id, in.memberIDs); err != nil {
+			return err
+		}
+		return s.saveGroupLink(tx, g.kind, g.id, in.externalID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(id)
+}
+
+// PatchGroup 按 PATCH 操作修改组
+func (s *Service) PatchGroup(id string, req *PatchRequest) (Resource, error) {
+	current, err := s.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyPatch(current, req); err != nil {
+		return nil, err
+	}
+	return s.ReplaceGroup(id, current)
+}
+
+// DeleteGroup 删除团队或租户角色（系统角色不可删除）
+func (s *Service) DeleteGroup(id string) error {
+	g, err := s.findGroup(id)
+	if err != nil {
+		return err
+	}
+	if g.isSystem {
+		return errMutability("system roles cannot be deleted")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		switch g.kind {
+		case model.SCIMGroupKindRole:
+			if err := tx.Where("role_id = ? AND tenant_id = ?", g.id, s.tenantID).Delete(&model.TenantUserRbacRole{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("role_id = ?", g.id).Delete(&model.TenantRbacRolePermission{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.TenantRbacRole{}, g.id).Error; err != nil {
+				return err
+			}
+		default:
+			if err := tx.Where("team_id = ?", g.id).Delete(&model.TeamMember{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.Team{}, g.id).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("tenant_id = ? AND kind = ? AND resource_id = ?", s.tenantID, g.kind, g.id).Delete(&model.SCIMGroup{}).Error
+	})
+}
+
+func (s *Service) findGroup(id string) (*group, error) {
+	kind, resourceID, ok := parseGroupID(id)
+	if !ok {
+		return nil, errNotFound("Group", id)
+	}
+	var err error
+	var g group
+	if kind == model.SCIMGroupKindRole {
+		var role model.TenantRbacRole
+		if err = s.db.Where("id = ? AND tenant_id = ?", resourceID, s.tenantID).First(&role).Error; err == nil {
+			g = roleGroup(&role)
+		}
+	} else {
+		var team model.Team
+		if err = s.db.Where("id = ? AND tenant_id = ?", resourceID, s.tenantID).First(&team).Error; err == nil {
+			g = teamGroup(&team)
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotFound("Group", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func teamGroup(t *model.Team) group {
+	return group{kind: model.SCIMGroupKindTeam, id: t.ID, name: t.Name, description: t.Description, createdAt: t.CreatedAt, updatedAt: t.UpdatedAt}
+}
+
+func roleGroup(r *model.TenantRbacRole) group {
+	return group{kind: model.SCIMGroupKindRole, id: r.ID, name: r.Name, code: r.Code, description: r.Description, isSystem: r.IsSystem, createdAt: r.CreatedAt, updatedAt: r.UpdatedAt}
+}
+
+// syncMembers 将组成员同步为 userIDs；团队所有者由 BasaltPass 管理，不会被移除
+func (s *Service) syncMembers(tx *gorm.DB, kind string, id uint, userIDs []uint) error {
+	want := make(map[uint]bool, len(userIDs))
+	for _, uid := range userIDs {
+		want[uid] = true
+	}
+	now := time.Now()
+
+	if kind == model.SCIMGroupKindRole {
+		var current []uint
+		if err := tx.Model(&model.TenantUserRbacRole{}).Where("role_id = ? AND tenant_id = ?", id, s.tenantID).Pluck("user_id", &current).Error; err != nil {
+			return err
+		}
+		have := make(map[uint]bool, len(current))
+		var remove []uint
+		for _, uid := range current {
+			have[uid] = true
+			if !want[uid] {
+				remove = append(remove, uid)
+			}
+		}
+		if len(remove) > 0 {
+			if err := tx.Where("role_id = ? AND tenant_id = ? AND user_id IN ?", id, s.tenantID, remove).Delete(&model.TenantUserRbacRole{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, uid := range userIDs {
+			if have[uid] {
+				continue
+			}
+			if err := tx.Create(&model.TenantUserRbacRole{UserID: uid, TenantID: s.tenantID, RoleID: id, AssignedAt: now, AssignedBy: s.actorID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var current []model.TeamMember
+	if err := tx.Where("team_id = ?", id).Find(&current).Error; err != nil {
+		return err
+	}
+	have := make(map[uint]bool, len(current))
+	var remove []uint
+	for _, m := range current {
+		have[m.UserID] = true
+		if !want[m.UserID] && m.Role != model.TeamRoleOwner {
+			remove = append(remove, m.UserID)
+		}
+	}
+	if len(remove) > 0 {
+		if err := tx.Where("team_id = ? AND user_id IN ?", id, remove).Delete(&model.TeamMember{}).Error; err != nil {
+			return err
+		}
+	}
+	for _, uid := range userIDs {
+		if have[uid] {
+			continue
+		}
+		member := model.TeamMember{TeamID: id, UserID: uid, Role: model.TeamRoleMember, Status: "active", JoinedAt: now.Unix()}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) saveGroupLink(tx *gorm.DB, kind string, id uint, externalID string) error {
+	var link model.SCIMGroup
+	err := tx.Where("tenant_id = ? AND kind = ? AND resource_id = ?", s.tenantID, kind, id).First(&link).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if link.ID == 0 && externalID == "" {
+		return nil
+	}
+	link.TenantID = s.tenantID
+	link.Kind = kind
+	link.ResourceID = id
+	link.ExternalID = externalID
+	return tx.Save(&link).Error
+}
+
+// groupResources 批量构造 Group 资源
+func (s *Service) groupResources(groups []group, withMembers bool) ([]Resource, error) {
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	var links []model.SCIMGroup
+	if err := s.db.Where("tenant_id = ?", s.tenantID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	externalIDs := make(map[string]string, len(links))
+	for _, l := range links {
+		externalIDs[groupID(l.Kind, l.ResourceID)] = l.ExternalID
+	}
+
+	members := make(map[string][]interface{})
+	if withMembers {
+		var err error
+		if members, err = s.groupMembers(groups); err != nil {
+			return nil, err
+		}
+	}
+
+	resources := make([]Resource, len(groups))
+	for i, g := range groups {
+		id := groupID(g.kind, g.id)
+		ext := map[string]interface{}{"type": g.kind}
+		if g.code != "" {
+			ext["code"] = g.code
+		}
+		if g.description != "" {
+			ext["description"] = g.description
+		}
+		r := Resource{
+			"schemas":            []interface{}{SchemaGroup, SchemaGroupExtension},
+			"id":                 id,
+			"displayName":        g.name,
+			SchemaGroupExtension: ext,
+			"meta":               meta("Group", s.baseURL+"/Groups/"+id, g.createdAt, g.updatedAt),
+		}
+		if withMembers {
+			r["members"] = members[id]
+			if r["members"] == nil {
+				r["members"] = []interface{}{}
+			}
+		}
+		if externalID := externalIDs[id]; externalID != "" {
+			r["externalId"] = externalID
+		}
+		resources[i] = r
+	}
+	return resources, nil
+}
+
+// groupMembers 加载组成员（仅本租户的用户）
+func (s *Service) groupMembers(groups []group) (map[string][]interface{}, error) {
+	var teamIDs, roleIDs []uint
+	for _, g := range groups {
+		if g.kind == model.SCIMGroupKindRole {
+			roleIDs = append(roleIDs, g.id)
+		} else {
+			teamIDs = append(teamIDs, g.id)
+		}
+	}
+
+	type row struct {
+		GroupID  uint
+		UserUUID string
+		Nickname string
+		Email    string
+	}
+	out := make(map[string][]interface{})
+	add := func(kind string, rows []row) {
+		for _, r := range rows {
+			display := r.Nickname
+			if display == "" {
+				display = r.Email
+			}
+			id := groupID(kind, r.GroupID)
+			out[id] = append(out[id], map[string]interface{}{
+				"value":   r.UserUUID,
+				"display": display,
+				"type":    "User",
+				"$ref":    s.baseURL + "/Users/" + r.UserUUID,
+			})
+		}
+	}
+
+	if len(teamIDs) > 0 {
+		var rows []row
+		if err := s.db.Table("system_auth_team_members m").
+			Select("m.team_id AS group_id, u.user_uuid, u.nickname, u.email").
+			Joins("JOIN system_auth_users u ON u.id = m.user_id").
+			Where("m.team_id IN ? AND m.deleted_at IS NULL AND u.tenant_id = ? AND u.deleted_at IS NULL", teamIDs, s.tenantID).
+			Order("m.id ASC").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		add(model.SCIMGroupKindTeam, rows)
+	}
+	if len(roleIDs) > 0 {
+		var rows []row
+		if err := s.db.Table("tenant_user_roles ur").
+			Select("ur.role_id AS group_id, u.user_uuid, u.nickname, u.email").
+			Joins("JOIN system_auth_users u ON u.id = ur.user_id").
+			Where("ur.role_id IN ? AND ur.tenant_id = ? AND u.tenant_id = ? AND u.deleted_at IS NULL", roleIDs, s.tenantID, s.tenantID).
+			Order("ur.id ASC").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		add(model.SCIMGroupKindRole, rows)
+	}
+	return out, nil
+}
@@ -0,0 +1,341 @@
+package scim
+
+import (
+	"net/http"
+	"strings"
+)
+
+// PatchOperation PATCH 请求中的单个操作（RFC 7644 §3.5.2）
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// PatchRequest PATCH 请求体
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+func errInvalidPath(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "invalidPath", format, args...)
+}
+
+func errNoTarget(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "noTarget", format, args...)
+}
+
+// patchPath PATCH 的目标路径：attrPath 或 attr[filter][.sub]
+type patchPath struct {
+	attrPath
+	filter Filter
+}
+
+func parsePatchPath(raw string) (*patchPath, error) {
+	raw = strings.TrimSpace(raw)
+	open := strings.IndexByte(raw, '[')
+	if open < 0 {
+		p, ok := parseAttrPath(raw)
+		if !ok {
+			return nil, errInvalidPath("invalid path %q", raw)
+		}
+		return &patchPath{attrPath: p}, nil
+	}
+
+	closeIdx := strings.LastIndexByte(raw, ']')
+	if closeIdx < open {
+		return nil, errInvalidPath("invalid path %q", raw)
+	}
+	p, ok := parseAttrPath(raw[:open])
+	if !ok || p.attr == "" || p.sub != "" {
+		return nil, errInvalidPath("invalid path %q", raw)
+	}
+	filter, err := ParseFilter(raw[open+1 : closeIdx])
+	if err != nil {
+		return nil, errInvalidPath("invalid filter in path %q", raw)
+	}
+	rest := raw[closeIdx+1:]
+	if rest != "" {
+		if !strings.HasPrefix(rest, ".") || len(rest) == 1 || strings.ContainsAny(rest[1:], ".[]") {
+			return nil, errInvalidPath("invalid path %q", raw)
+		}
+		p.sub = rest[1:]
+	}
+	return &patchPath{attrPath: p, filter: filter}, nil
+}
+
+// ApplyPatch 将 PATCH 操作依次应用到资源的 JSON 表示上；调用方随后按整体替换保存结果
+func ApplyPatch(r Resource, req *PatchRequest) error {
+	if !containsSchema(req.Schemas, SchemaPatchOp) {
+		return newError(http.StatusBadRequest, "invalidSyntax", "schemas must contain %s", SchemaPatchOp)
+	}
+	if len(req.Operations) == 0 {
+		return newError(http.StatusBadRequest, "invalidSyntax", "Operations is required")
+	}
+	for _, op := range req.Operations {
+		if err := applyOperation(r, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOperation(r Resource, op PatchOperation) error {
+	kind := strings.ToLower(strings.TrimSpace(op.Op))
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return newError(http.StatusBadRequest, "invalidSyntax", "unsupported op %q", op.Op)
+	}
+
+	// 无 path：value 为对象，逐个属性应用
+	if strings.TrimSpace(op.Path) == "" {
+		if kind == "remove" {
+			return errNoTarget("remove requires a path")
+		}
+		values, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return errInvalidValue("value must be an object when path is omitted")
+		}
+		for name, value := range values {
+			if err := applyOperation(r, PatchOperation{Op: kind, Path: name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := parsePatchPath(op.Path)
+	if err != nil {
+		return err
+	}
+	// 扩展架构整体：urn:...:Group 配合对象值
+	if path.attr == "" {
+		values, ok := op.Value.(map[string]interface{})
+		if !ok && kind != "remove" {
+			return errInvalidValue("value must be an object for %s", path.ext)
+		}
+		if kind == "remove" {
+			deleteAttr(r, path.ext)
+			return nil
+		}
+		for name, value := range values {
+			if err := applyOperation(r, PatchOperation{Op: kind, Path: path.ext + ":" + name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	container := path.container(r, kind != "remove")
+	if container == nil {
+		return nil
+	}
+	if path.filter != nil {
+		return applyFiltered(container, path, kind, op.Value)
+	}
+
+	current, exists := getAttr(container, path.attr)
+	if path.sub != "" {
+		return applySubAttribute(container, path, kind, current, exists, op.Value)
+	}
+
+	switch kind {
+	case "remove":
+		// 多值属性按 value 移除指定元素（如 Azure AD 移除组成员）
+		if items, ok := current.([]interface{}); ok && op.Value != nil {
+			setAttr(container, path.attr, removeByValue(items, op.Value))
+			return nil
+		}
+		deleteAttr(container, path.attr)
+	case "add":
+		if items, ok := current.([]interface{}); ok {
+			setAttr(container, path.attr, appendUnique(items, op.Value))
+			return nil
+		}
+		if obj, ok := current.(map[string]interface{}); ok {
+			if patch, ok := op.Value.(map[string]interface{}); ok {
+				for k, v := range patch {
+					setAttr(obj, k, v)
+				}
+				return nil
+			}
+		}
+		setAttr(container, path.attr, op.Value)
+	case "replace":
+		if obj, ok := current.(map[string]interface{}); ok && exists {
+			if patch, ok := op.Value.(map[string]interface{}); ok {
+				for k, v := range patch {
+					setAttr(obj, k, v)
+				}
+				return nil
+			}
+		}
+		setAttr(container, path.attr, op.Value)
+	}
+	return nil
+}
+
+// applySubAttribute attr.sub：作用于单个复杂属性，或多值属性的每个元素
+func applySubAttribute(container map[string]interface{}, path *patchPath, kind string, current interface{}, exists bool, value interface{}) error {
+	switch t := current.(type) {
+	case map[string]interface{}:
+		if kind == "remove" {
+			deleteAttr(t, path.sub)
+		} else {
+			setAttr(t, path.sub, value)
+		}
+	case []interface{}:
+		for _, item := range t {
+			if obj, ok := item.(map[string]interface{}); ok {
+				if kind == "remove" {
+					deleteAttr(obj, path.sub)
+				} else {
+					setAttr(obj, path.sub, value)
+				}
+			}
+		}
+	default:
+		if kind != "remove" {
+			setAttr(container, path.attr, map[string]interface{}{path.sub: value})
+		} else if exists {
+			deleteAttr(container, path.attr)
+		}
+	}
+	return nil
+}
+
+// applyFiltered attr[filter] / attr[filter].sub
+func applyFiltered(container map[string]interface{}, path *patchPath, kind string, value interface{}) error {
+	current, _ := getAttr(container, path.attr)
+	items, _ := current.([]interface{})
+
+	matched := false
+	kept := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok || !path.filter.Match(obj) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+		switch {
+		case kind == "remove" && path.sub == "":
+			continue
+		case kind == "remove":
+			deleteAttr(obj, path.sub)
+		case path.sub != "":
+			setAttr(obj, path.sub, value)
+		default:
+			if replacement, ok := value.(map[string]interface{}); ok {
+				for k, v := range replacement {
+					setAttr(obj, k, v)
+				}
+			}
+		}
+		kept = append(kept, obj)
+	}
+
+	if !matched {
+		if kind == "remove" {
+			return nil
+		}
+		// 目标元素不存在时按过滤条件新建（如 emails[type eq "work"].value），仅支持 attr eq "x" 形式
+		element, ok := elementFromFilter(path.filter)
+		if !ok {
+			return errNoTarget("no values matched %s", path.attr)
+		}
+		if path.sub != "" {
+			element[path.sub] = value
+		} else if replacement, ok := value.(map[string]interface{}); ok {
+			for k, v := range replacement {
+				element[k] = v
+			}
+		}
+		kept = append(kept, element)
+	}
+	setAttr(container, path.attr, kept)
+	return nil
+}
+
+func elementFromFilter(f Filter) (map[string]interface{}, bool) {
+	element := make(map[string]interface{})
+	var collect func(Filter) bool
+	collect = func(f Filter) bool {
+		switch t := f.(type) {
+		case *compareFilter:
+			if t.op != "eq" || t.path.sub != "" || t.path.ext != "" {
+				return false
+			}
+			element[t.path.attr] = t.value
+			return true
+		case *logicalFilter:
+			return t.and && collect(t.left) && collect(t.right)
+		}
+		return false
+	}
+	return element, collect(f)
+}
+
+// appendUnique 向多值属性追加元素，value 相同的元素不重复添加
+func appendUnique(items []interface{}, value interface{}) []interface{} {
+	additions, ok := value.([]interface{})
+	if !ok {
+		additions = []interface{}{value}
+	}
+	out := append([]interface{}{}, items...)
+	for _, add := range additions {
+		key := elementValue(add)
+		duplicate := false
+		if key != "" {
+			for _, existing := range out {
+				if strings.EqualFold(elementValue(existing), key) {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if !duplicate {
+			out = append(out, add)
+		}
+	}
+	return out
+}
+
+func removeByValue(items []interface{}, value interface{}) []interface{} {
+	removals, ok := value.([]interface{})
+	if !ok {
+		removals = []interface{}{value}
+	}
+	drop := make(map[string]bool)
+	for _, r := range removals {
+		if key := elementValue(r); key != "" {
+			drop[strings.ToLower(key)] = true
+		}
+	}
+	out := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if !drop[strings.ToLower(elementValue(item))] {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func elementValue(item interface{}) string {
+	switch t := item.(type) {
+	case map[string]interface{}:
+		return stringAttr(t, "value")
+	case string:
+		return t
+	}
+	return ""
+}
+
+func containsSchema(schemas []string, schema string) bool {
+	for _, s := range schemas {
+		if strings.EqualFold(strings.TrimSpace(s), schema) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,369 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SCIM 2.0 架构 URN（RFC 7643 / RFC 7644）
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaGroupExtension        = "urn:basaltpass:params:scim:schemas:extension:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaBulkRequest           = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	SchemaBulkResponse          = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ContentType SCIM 请求与响应的媒体类型
+const ContentType = "application/scim+json"
+
+const (
+	defaultListCount  = 100
+	maxListCount      = 200
+	maxBulkOperations = 100
+	// MaxBulkPayload Bulk 请求体的上限（字节）
+	MaxBulkPayload = 1 << 20
+)
+
+// Error SCIM 错误响应（RFC 7644 §3.12）
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	if e.ScimType != "" {
+		return e.ScimType + ": " + e.Detail
+	}
+	return e.Detail
+}
+
+// Body 错误响应体
+func (e *Error) Body() map[string]interface{} {
+	body := map[string]interface{}{
+		"schemas": []string{SchemaError},
+		"status":  strconv.Itoa(e.Status),
+		"detail":  e.Detail,
+	}
+	if e.ScimType != "" {
+		body["scimType"] = e.ScimType
+	}
+	return body
+}
+
+func newError(status int, scimType, format string, args ...interface{}) *Error {
+	return &Error{Status: status, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+func errInvalidValue(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "invalidValue", format, args...)
+}
+
+func errNotFound(resourceType, id string) *Error {
+	return newError(http.StatusNotFound, "", "%s %s not found", resourceType, id)
+}
+
+func errUniqueness(format string, args ...interface{}) *Error {
+	return newError(http.StatusConflict, "uniqueness", format, args...)
+}
+
+func errMutability(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, "mutability", format, args...)
+}
+
+// Service 租户的 SCIM 2.0 预配服务。每个请求创建一个实例，租户由 API Key 决定
+type Service struct {
+	db       *gorm.DB
+	tenantID uint
+	baseURL  string // SCIM 端点的对外地址，如 https://id.example.com/api/v1/scim/v2
+	actorID  uint   // 写入角色分配的 AssignedBy（API Key 的创建者）
+
+	// RevokeTokens 撤销用户的全部 OAuth 令牌（由处理器注入 OAuth 服务的实现）
+	RevokeTokens func(userID uint) error
+	// OnDeprovision 用户被停用或删除、会话与令牌已撤销后调用，用于通知其授权过的应用登出
+	OnDeprovision func(userID uint)
+}
+
+// NewService 创建租户的 SCIM 服务
+func NewService(db *gorm.DB, tenantID uint, baseURL string, actorID uint) *Service {
+	return &Service{
+		db:       db,
+		tenantID: tenantID,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		actorID:  actorID,
+	}
+}
+
+// Resource SCIM 资源的 JSON 表示
+type Resource = map[string]interface{}
+
+// ListParams 列表查询参数（RFC 7644 §3.4.2）
+type ListParams struct {
+	Filter             string
+	StartIndex         int
+	Count              int
+	Attributes         []string
+	ExcludedAttributes []string
+}
+
+// ListResponse 列表响应
+type ListResponse struct {
+	Schemas      []string   `json:"schemas"`
+	TotalResults int        `json:"totalResults"`
+	StartIndex   int        `json:"startIndex"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	Resources    []Resource `json:"Resources"`
+}
+
+// NewListParams 解析列表查询参数；count 缺省为 100、上限 200
+func NewListParams(filter, startIndex, count, attributes, excludedAttributes string) ListParams {
+	params := ListParams{Filter: strings.TrimSpace(filter), StartIndex: 1, Count: defaultListCount}
+	if v, err := strconv.Atoi(strings.TrimSpace(startIndex)); err == nil && v > 1 {
+		params.StartIndex = v
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(count)); err == nil {
+		params.Count = v
+	}
+	params.Attributes = splitAttributeList(attributes)
+	params.ExcludedAttributes = splitAttributeList(excludedAttributes)
+	return params
+}
+
+func splitAttributeList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseListFilter 解析列表的过滤表达式；未指定时返回 nil
+func parseListFilter(params ListParams) (Filter, error) {
+	if params.Filter == "" {
+		return nil, nil
+	}
+	return ParseFilter(params.Filter)
+}
+
+// list 过滤、分页并按 attributes / excludedAttributes 裁剪资源
+func list(resources []Resource, filter Filter, params ListParams) *ListResponse {
+	if filter != nil {
+		matched := resources[:0]
+		for _, r := range resources {
+			if filter.Match(r) {
+				matched = append(matched, r)
+			}
+		}
+		resources = matched
+	}
+
+	start, count := pageBounds(params)
+	var page []Resource
+	if start <= len(resources) {
+		end := start - 1 + count
+		if end > len(resources) {
+			end = len(resources)
+		}
+		page = resources[start-1 : end]
+	}
+	return listResponse(page, len(resources), start, params)
+}
+
+// pageBounds 规范化 startIndex（从 1 开始）与 count（不超过 maxListCount）
+func pageBounds(params ListParams) (start, count int) {
+	start = params.StartIndex
+	if start < 1 {
+		start = 1
+	}
+	count = params.Count
+	if count < 0 {
+		count = 0
+	}
+	if count > maxListCount {
+		count = maxListCount
+	}
+	return start, count
+}
+
+// listResponse 以已分页的资源构造列表响应
+func listResponse(page []Resource, total, start int, params ListParams) *ListResponse {
+	resources := make([]Resource, 0, len(page))
+	for _, r := range page {
+		resources = append(resources, Project(r, params.Attributes, params.ExcludedAttributes))
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   start,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Project 按 attributes / excludedAttributes 裁剪资源的顶层属性；id 与 schemas 始终返回
+func Project(r Resource, attributes, excluded []string) Resource {
+	if len(attributes) == 0 && len(excluded) == 0 {
+		return r
+	}
+	out := make(Resource, len(r))
+	if len(attributes) > 0 {
+		keep := make(map[string]bool)
+		for _, a := range attributes {
+			keep[strings.ToLower(topLevelAttr(a))] = true
+		}
+		for k, v := range r {
+			if k == "id" || k == "schemas" || keep[strings.ToLower(k)] {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	drop := make(map[string]bool)
+	for _, a := range excluded {
+		drop[strings.ToLower(topLevelAttr(a))] = true
+	}
+	for k, v := range r {
+		if k == "id" || k == "schemas" || !drop[strings.ToLower(k)] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// topLevelAttr 去掉架构前缀与子属性，如 "urn:...:User:name.givenName" -> "name"
+func topLevelAttr(path string) string {
+	path = stripSchema(strings.TrimSpace(path))
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// stripSchema 去掉核心架构前缀；扩展架构的属性保留为 "<urn>:attr" 形式以便按扩展对象查找
+func stripSchema(path string) string {
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if len(path) > len(schema) && strings.EqualFold(path[:len(schema)+1], schema+":") {
+			return path[len(schema)+1:]
+		}
+	}
+	return path
+}
+
+// getAttr 按不区分大小写的属性名读取（RFC 7643 §2.1）
+func getAttr(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// setAttr 写入属性，已存在时沿用原有的大小写
+func setAttr(m map[string]interface{}, name string, value interface{}) {
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			m[k] = value
+			return
+		}
+	}
+	m[name] = value
+}
+
+func deleteAttr(m map[string]interface{}, name string) {
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			delete(m, k)
+		}
+	}
+}
+
+func stringAttr(m map[string]interface{}, name string) string {
+	v, _ := getAttr(m, name)
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+// boolAttr 读取布尔属性；部分客户端（如 Azure AD）以字符串 "True" / "False" 发送
+func boolAttr(m map[string]interface{}, name string, fallback bool) (bool, error) {
+	v, ok := getAttr(m, name)
+	if !ok || v == nil {
+		return fallback, nil
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return false, errInvalidValue("%s must be a boolean", name)
+		}
+		return parsed, nil
+	}
+	return false, errInvalidValue("%s must be a boolean", name)
+}
+
+func mapAttr(m map[string]interface{}, name string) map[string]interface{} {
+	v, _ := getAttr(m, name)
+	sub, _ := v.(map[string]interface{})
+	return sub
+}
+
+func listAttr(m map[string]interface{}, name string) []interface{} {
+	v, _ := getAttr(m, name)
+	items, _ := v.([]interface{})
+	return items
+}
+
+// primaryValue 从多值属性中选出 primary 项（没有时取第一项）的 value
+func primaryValue(items []interface{}) string {
+	var first string
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value := stringAttr(entry, "value")
+		if value == "" {
+			continue
+		}
+		if primary, _ := boolAttr(entry, "primary", false); primary {
+			return value
+		}
+		if first == "" {
+			first = value
+		}
+	}
+	return first
+}
+
+func meta(resourceType, location string, created, modified time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"resourceType": resourceType,
+		"created":      formatTime(created),
+		"lastModified": formatTime(modified),
+		"location":     location,
+		"version":      fmt.Sprintf(`W/"%d"`, modified.UnixNano()),
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
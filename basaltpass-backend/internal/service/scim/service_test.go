@@ -0,0 +1,412 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSCIMTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Tenant{},
+		&model.User{},
+		&model.UserProfile{},
+		&model.TenantUser{},
+		&model.Team{},
+		&model.TeamMember{},
+		&model.TenantRbacRole{},
+		&model.TenantUserRbacRole{},
+		&model.TenantRbacRolePermission{},
+		&model.ConsoleSession{},
+		&model.SCIMUser{},
+		&model.SCIMGroup{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := setupSCIMTestDB(t)
+	tenant := model.Tenant{Name: "Acme", Code: "acme"}
+	if err := db.Create(&tenant).Error; err != nil {
+		t.Fatalf("create tenant failed: %v", err)
+	}
+	return NewService(db, tenant.ID, "https://id.example.com/api/v1/scim/v2", 1), db
+}
+
+// decode 模拟 JSON 请求体，保证数值、数组等类型与线上一致
+func decode(t *testing.T, raw string) Resource {
+	t.Helper()
+	var r Resource
+	if err := json.Unmarshal([]byte(raw), &r); err != nil {
+		t.Fatalf("decode %s failed: %v", raw, err)
+	}
+	return r
+}
+
+func patchRequest(t *testing.T, raw string) *PatchRequest {
+	t.Helper()
+	var req PatchRequest
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		t.Fatalf("decode patch failed: %v", err)
+	}
+	return &req
+}
+
+func expectSCIMError(t *testing.T, err error, status int) {
+	t.Helper()
+	var scimErr *Error
+	if !errors.As(err, &scimErr) || scimErr.Status != status {
+		t.Fatalf("expected SCIM error with status %d, got %v", status, err)
+	}
+}
+
+func TestCreateAndFilterUsers(t *testing.T) {
+	svc, db := newTestService(t)
+
+	created, err := svc.CreateUser(decode(t, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice@example.com",
+		"externalId": "00u1",
+		"name": {"givenName": "Alice", "familyName": "Liddell"},
+		"emails": [{"value": "Alice@Example.com", "type": "work", "primary": true}],
+		"title": "Engineer"
+	}`))
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	id, _ := created["id"].(string)
+	if id == "" || created["displayName"] != "Alice Liddell" || created["active"] != true || created["title"] != "Engineer" {
+		t.Fatalf("unexpected user resource: %+v", created)
+	}
+	var user model.User
+	if err := db.Where("user_uuid = ?", id).First(&user).Error; err != nil {
+		t.Fatalf("load user failed: %v", err)
+	}
+	if user.TenantID != svc.tenantID || user.Email != "alice@example.com" || !user.EmailVerified {
+		t.Fatalf("unexpected stored user: %+v", user)
+	}
+
+	if _, err := svc.CreateUser(decode(t, `{"userName": "alice@example.com"}`)); err == nil {
+		t.Fatal("expected duplicate user to be rejected")
+	} else {
+		expectSCIMError(t, err, http.StatusConflict)
+	}
+	if _, err := svc.CreateUser(decode(t, `{"userName": "bob@example.com", "externalId": "00u2"}`)); err != nil {
+		t.Fatalf("create second user failed: %v", err)
+	}
+
+	resp, err := svc.ListUsers(NewListParams(`userName eq "ALICE@example.com"`, "", "", "", ""))
+	if err != nil || resp.TotalResults != 1 || resp.Resources[0]["id"] != id {
+		t.Fatalf("expected filter by userName to match alice, got %+v %v", resp, err)
+	}
+	resp, err = svc.ListUsers(NewListParams(`emails[type eq "work" and value co "example.com"] and name.givenName sw "Al"`, "", "", "", ""))
+	if err != nil || resp.TotalResults != 1 {
+		t.Fatalf("expected complex filter to match alice, got %+v %v", resp, err)
+	}
+	resp, err = svc.ListUsers(NewListParams("", "2", "1", "userName", ""))
+	if err != nil || resp.TotalResults != 2 || resp.ItemsPerPage != 1 || resp.StartIndex != 2 {
+		t.Fatalf("unexpected page: %+v %v", resp, err)
+	}
+	if _, ok := resp.Resources[0]["emails"]; ok {
+		t.Fatalf("expected attributes to limit returned attributes, got %+v", resp.Resources[0])
+	}
+	// 可翻译的过滤式在数据库中过滤与分页
+	resp, err = svc.ListUsers(NewListParams(`externalId eq "00u2" or (active eq true and not (userName co "BOB"))`, "2", "1", "", ""))
+	if err != nil || resp.TotalResults != 2 || resp.ItemsPerPage != 1 || resp.Resources[0]["userName"] != "bob@example.com" {
+		t.Fatalf("unexpected database page: %+v %v", resp, err)
+	}
+	resp, err = svc.ListUsers(NewListParams(`userName sw "al_"`, "", "", "", ""))
+	if err != nil || resp.TotalResults != 0 {
+		t.Fatalf("expected LIKE wildcards to be matched literally, got %+v %v", resp, err)
+	}
+	if _, err := svc.ListUsers(NewListParams(`userName eq`, "", "", "", "")); err == nil {
+		t.Fatal("expected invalid filter to be rejected")
+	} else {
+		expectSCIMError(t, err, http.StatusBadRequest)
+	}
+
+	if _, err := svc.GetUser("missing"); err == nil {
+		t.Fatal("expected unknown user to be rejected")
+	} else {
+		expectSCIMError(t, err, http.StatusNotFound)
+	}
+}
+
+func TestDeactivateUserRevokesAccess(t *testing.T) {
+	svc, db := newTestService(t)
+	var deprovisioned, revoked []uint
+	svc.OnDeprovision = func(userID uint) { deprovisioned = append(deprovisioned, userID) }
+	svc.RevokeTokens = func(userID uint) error {
+		revoked = append(revoked, userID)
+		return nil
+	}
+
+	created, err := svc.CreateUser(decode(t, `{"userName": "carol@example.com"}`))
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	id := created["id"].(string)
+	var user model.User
+	db.Where("user_uuid = ?", id).First(&user)
+
+	expires := time.Now().Add(time.Hour)
+	db.Create(&model.ConsoleSession{SessionID: "sid-1", UserID: user.ID, Scope: "user", RefreshJTI: "jti-1", ExpiresAt: expires, LastSeenAt: time.Now()})
+
+	// Azure AD 风格：active 以字符串形式下发
+	patched, err := svc.PatchUser(id, patchRequest(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "Replace", "path": "active", "value": "False"}]
+	}`))
+	if err != nil {
+		t.Fatalf("patch user failed: %v", err)
+	}
+	if patched["active"] != false {
+		t.Fatalf("expected user to be inactive, got %+v", patched)
+	}
+	db.First(&user, user.ID)
+	if !user.Banned {
+		t.Fatal("expected deactivated user to be banned")
+	}
+	var session model.ConsoleSession
+	db.Where("session_id = ?", "sid-1").First(&session)
+	if session.RevokedAt == nil {
+		t.Fatal("expected console session to be revoked")
+	}
+	if len(revoked) != 1 || revoked[0] != user.ID {
+		t.Fatalf("expected oauth tokens of user %d to be revoked, got %v", user.ID, revoked)
+	}
+	if len(deprovisioned) != 1 || deprovisioned[0] != user.ID {
+		t.Fatalf("expected deprovision callback for user %d, got %v", user.ID, deprovisioned)
+	}
+
+	// 重新启用不会再次触发回调；删除则软删除用户
+	if _, err := svc.PatchUser(id, patchRequest(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "value": {"active": true}}]
+	}`)); err != nil {
+		t.Fatalf("reactivate user failed: %v", err)
+	}
+	db.First(&user, user.ID)
+	if user.Banned || len(deprovisioned) != 1 {
+		t.Fatalf("expected user to be reactivated without callback, banned=%v calls=%d", user.Banned, len(deprovisioned))
+	}
+	if err := svc.DeleteUser(id); err != nil {
+		t.Fatalf("delete user failed: %v", err)
+	}
+	if _, err := svc.GetUser(id); err == nil {
+		t.Fatal("expected deleted user to be gone")
+	}
+	if len(deprovisioned) != 2 {
+		t.Fatalf("expected delete to deprovision the user, got %v", deprovisioned)
+	}
+}
+
+func TestTenantOwnerCannotBeDeprovisioned(t *testing.T) {
+	svc, db := newTestService(t)
+	created, err := svc.CreateUser(decode(t, `{"userName": "owner@example.com"}`))
+	if err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	var user model.User
+	db.Where("user_uuid = ?", created["id"]).First(&user)
+	db.Create(&model.TenantUser{UserID: user.ID, TenantID: svc.tenantID, Role: model.TenantRoleOwner})
+
+	err = svc.DeleteUser(created["id"].(string))
+	expectSCIMError(t, err, http.StatusForbidden)
+}
+
+func TestGroupsAndMembership(t *testing.T) {
+	svc, db := newTestService(t)
+	alice, _ := svc.CreateUser(decode(t, `{"userName": "alice@example.com"}`))
+	bob, _ := svc.CreateUser(decode(t, `{"userName": "bob@example.com"}`))
+	aliceID, bobID := alice["id"].(string), bob["id"].(string)
+
+	team, err := svc.CreateGroup(decode(t, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"displayName": "Engineering",
+		"externalId": "grp-1",
+		"members": [{"value": "`+aliceID+`"}]
+	}`))
+	if err != nil {
+		t.Fatalf("create team group failed: %v", err)
+	}
+	teamID := team["id"].(string)
+	if members := team["members"].([]interface{}); len(members) != 1 {
+		t.Fatalf("expected one member, got %+v", team)
+	}
+
+	// Azure AD 风格的成员增删
+	team, err = svc.PatchGroup(teamID, patchRequest(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "add", "path": "members", "value": [{"value": "`+bobID+`"}]},
+			{"op": "remove", "path": "members[value eq \"`+aliceID+`\"]"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("patch team members failed: %v", err)
+	}
+	members := team["members"].([]interface{})
+	if len(members) != 1 || members[0].(map[string]interface{})["value"] != bobID {
+		t.Fatalf("expected only bob to remain, got %+v", members)
+	}
+	user, _ := svc.GetUser(bobID)
+	if groups := user["groups"].([]interface{}); len(groups) != 1 || groups[0].(map[string]interface{})["value"] != teamID {
+		t.Fatalf("expected bob's groups to list the team, got %+v", user["groups"])
+	}
+
+	role, err := svc.CreateGroup(decode(t, `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group", "urn:basaltpass:params:scim:schemas:extension:2.0:Group"],
+		"displayName": "Billing Admins",
+		"urn:basaltpass:params:scim:schemas:extension:2.0:Group": {"type": "role"},
+		"members": [{"value": "`+aliceID+`"}, {"value": "`+bobID+`"}]
+	}`))
+	if err != nil {
+		t.Fatalf("create role group failed: %v", err)
+	}
+	var tenantRole model.TenantRbacRole
+	if err := db.Where("tenant_id = ? AND code = ?", svc.tenantID, "billing_admins").First(&tenantRole).Error; err != nil {
+		t.Fatalf("expected role to be created with a derived code: %v", err)
+	}
+	var assignments int64
+	db.Model(&model.TenantUserRbacRole{}).Where("role_id = ?", tenantRole.ID).Count(&assignments)
+	if assignments != 2 {
+		t.Fatalf("expected 2 role assignments, got %d", assignments)
+	}
+	if _, err := svc.ReplaceGroup(role["id"].(string), decode(t, `{
+		"displayName": "Billing Admins",
+		"urn:basaltpass:params:scim:schemas:extension:2.0:Group": {"type": "team"}
+	}`)); err == nil {
+		t.Fatal("expected changing the group type to be rejected")
+	} else {
+		expectSCIMError(t, err, http.StatusBadRequest)
+	}
+
+	resp, err := svc.ListGroups(NewListParams(`displayName eq "Engineering"`, "", "", "", "members"))
+	if err != nil || resp.TotalResults != 1 || resp.Resources[0]["id"] != teamID {
+		t.Fatalf("expected to find the team by displayName, got %+v %v", resp, err)
+	}
+	if _, ok := resp.Resources[0]["members"]; ok {
+		t.Fatal("expected members to be excluded")
+	}
+
+	if err := svc.DeleteGroup(role["id"].(string)); err != nil {
+		t.Fatalf("delete role group failed: %v", err)
+	}
+	db.Model(&model.TenantUserRbacRole{}).Where("role_id = ?", tenantRole.ID).Count(&assignments)
+	if assignments != 0 {
+		t.Fatalf("expected role assignments to be removed, got %d", assignments)
+	}
+	if _, err := svc.GetGroup(role["id"].(string)); err == nil {
+		t.Fatal("expected deleted group to be gone")
+	}
+}
+
+func TestBulkResolvesBulkIDs(t *testing.T) {
+	svc, _ := newTestService(t)
+	var req BulkRequest
+	raw := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Groups", "bulkId": "g1", "data": {"displayName": "Ops", "members": [{"value": "bulkId:u1"}]}},
+			{"method": "POST", "path": "/Users", "bulkId": "u1", "data": {"userName": "dave@example.com"}},
+			{"method": "PATCH", "path": "/Users/bulkId:u1", "data": {"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "title", "value": "SRE"}]}},
+			{"method": "DELETE", "path": "/Users/unknown"}
+		]
+	}`
+	if err := json.Unmarshal([]byte(raw), &req); err != nil {
+		t.Fatalf("decode bulk request failed: %v", err)
+	}
+	resp, err := svc.Bulk(&req)
+	if err != nil {
+		t.Fatalf("bulk failed: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, op := range resp.Operations {
+		statuses[op.Method+" "+op.BulkID] = op.Status
+	}
+	if statuses["POST u1"] != "201" || statuses["POST g1"] != "201" || statuses["PATCH "] != "200" || statuses["DELETE "] != "404" {
+		t.Fatalf("unexpected bulk statuses: %+v", resp.Operations)
+	}
+
+	users, _ := svc.ListUsers(NewListParams(`userName eq "dave@example.com"`, "", "", "", ""))
+	if users.TotalResults != 1 || users.Resources[0]["title"] != "SRE" {
+		t.Fatalf("expected dave to be created and patched, got %+v", users.Resources)
+	}
+	groups, _ := users.Resources[0]["groups"].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("expected dave to be a member of the group created before him, got %+v", users.Resources[0])
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	r := decode(t, `{
+		"userName": "alice@example.com",
+		"active": true,
+		"emails": [{"value": "alice@example.com", "type": "work"}],
+		"meta": {"lastModified": "2026-01-02T03:04:05Z"}
+	}`)
+	cases := map[string]bool{
+		`userName eq "Alice@Example.com"`:                             true,
+		`userName ne "alice@example.com"`:                             false,
+		`active eq true and emails.type eq "work"`:                    true,
+		`not (emails[type eq "home"])`:                                true,
+		`title pr or userName ew "@example.com"`:                      true,
+		`meta.lastModified gt "2026-01-01T00:00:00Z"`:                 true,
+		`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "al"`: true,
+	}
+	for raw, want := range cases {
+		f, err := ParseFilter(raw)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", raw, err)
+		}
+		if got := f.Match(r); got != want {
+			t.Fatalf("filter %q: expected %v, got %v", raw, want, got)
+		}
+	}
+	for _, raw := range []string{`userName`, `userName eq "x" and`, `userName xx "x"`, `(userName eq "x"`, `emails[type eq "work"`} {
+		if _, err := ParseFilter(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestSQLConditionFallsBackForUntranslatableFilters(t *testing.T) {
+	cases := []struct {
+		filter string
+		ok     bool
+	}{
+		{`userName eq "alice@example.com"`, true},
+		{`displayName co "Al" and active eq false`, true},
+		{`not (emails.value ew "@example.com")`, true},
+		{`name.givenName sw "Al"`, false},
+		{`emails[type eq "work"]`, false},
+		{`userName gt "a"`, false},
+		{`externalId eq ""`, false},
+		{`userName eq "a" or title eq "SRE"`, false},
+	}
+	for _, tc := range cases {
+		f, err := ParseFilter(tc.filter)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", tc.filter, err)
+		}
+		if _, _, ok := sqlCondition(f, userColumns); ok != tc.ok {
+			t.Fatalf("%q: expected translatable=%v", tc.filter, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package scim
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/consolesession"
+	"basaltpass-backend/internal/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// userInput 从 SCIM User 资源中读取的、BasaltPass 保存的属性
+type userInput struct {
+	externalID  string
+	userName    string
+	givenName   string
+	familyName  string
+	displayName string
+	email       string
+	phone       string
+	title       string
+	timezone    string
+	password    string
+	active      bool
+}
+
+// parseUser 校验并读取 User 资源；userName 为登录标识，不是邮箱时需在 emails 中提供邮箱
+func parseUser(r Resource) (*userInput, error) {
+	in := &userInput{
+		externalID:  stringAttr(r, "externalId"),
+		userName:    stringAttr(r, "userName"),
+		displayName: stringAttr(r, "displayName"),
+		title:       stringAttr(r, "title"),
+		timezone:    stringAttr(r, "timezone"),
+		password:    stringAttr(r, "password"),
+	}
+	if in.userName == "" {
+		return nil, errInvalidValue("userName is required")
+	}
+	if name := mapAttr(r, "name"); name != nil {
+		in.givenName = stringAttr(name, "givenName")
+		in.familyName = stringAttr(name, "familyName")
+		if in.displayName == "" {
+			in.displayName = stringAttr(name, "formatted")
+		}
+	}
+	active, err := boolAttr(r, "active", true)
+	if err != nil {
+		return nil, err
+	}
+	in.active = active
+
+	email := primaryValue(listAttr(r, "emails"))
+	if email == "" && strings.Contains(in.userName, "@") {
+		email = in.userName
+	}
+	if email == "" {
+		return nil, errInvalidValue("userName or emails must contain an email address")
+	}
+	normalized, err := utils.NewEmailValidator().Normalize(email)
+	if err != nil {
+		return nil, errInvalidValue("invalid email address %q", email)
+	}
+	in.email = normalized
+
+	if phone := primaryValue(listAttr(r, "phoneNumbers")); phone != "" {
+		normalizedPhone, err := utils.NewPhoneValidator("+86").NormalizeToE164(phone)
+		if err != nil {
+			return nil, errInvalidValue("invalid phone number %q", phone)
+		}
+		in.phone = normalizedPhone
+	}
+
+	if in.displayName == "" {
+		in.displayName = strings.TrimSpace(in.givenName + " " + in.familyName)
+	}
+	if in.displayName == "" {
+		in.displayName = strings.SplitN(in.email, "@", 2)[0]
+	}
+	if len(in.userName) > 255 || len(in.externalID) > 255 {
+		return nil, errInvalidValue("userName and externalId must not exceed 255 characters")
+	}
+	in.displayName = utils.Truncate(in.displayName, 64)
+	in.givenName = utils.Truncate(in.givenName, 64)
+	in.familyName = utils.Truncate(in.familyName, 64)
+	in.title = utils.Truncate(in.title, 128)
+	in.timezone = utils.Truncate(in.timezone, 64)
+	return in, nil
+}
+
+// userColumns 可在数据库中求值的 User 属性（u 为用户表，su 为 SCIM 属性表）
+var userColumns = map[string]sqlColumn{
+	"id":           {expr: "u.user_uuid"},
+	"username":     {expr: "COALESCE(NULLIF(su.user_name, ''), u.email, '')"},
+	"externalid":   {expr: "COALESCE(su.external_id, '')"},
+	"displayname":  {expr: "COALESCE(u.nickname, '')"},
+	"emails":       {expr: "COALESCE(u.email, '')"},
+	"emails.value": {expr: "COALESCE(u.email, '')"},
+	"active":       {expr: "u.banned", boolean: true, negate: true},
+}
+
+// ListUsers 列出租户用户
+func (s *Service) ListUsers(params ListParams) (*ListResponse, error) {
+	filter, err := parseListFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 过滤式可翻译为 SQL 时在数据库中过滤并分页，只为当前页构造资源
+	if filter == nil {
+		return s.listUsersPage("", nil, params)
+	}
+	if cond, args, ok := sqlCondition(filter, userColumns); ok {
+		return s.listUsersPage(cond, args, params)
+	}
+
+	// 常见的 userName / externalId / id 精确匹配先在数据库中缩小范围，其余条件在内存中求值
+	query := s.db.Where("tenant_id = ?", s.tenantID)
+	if v, ok := equalityValue(filter, "userName"); ok {
+		query = query.Where("LOWER(email) = ? OR id IN (?)", strings.ToLower(v),
+			s.db.Model(&model.SCIMUser{}).Select("user_id").Where("tenant_id = ? AND LOWER(user_name) = ?", s.tenantID, strings.ToLower(v)))
+	}
+	if v, ok := equalityValue(filter, "externalId"); ok {
+		query = query.Where("id IN (?)",
+			s.db.Model(&model.SCIMUser{}).Select("user_id").Where("tenant_id = ? AND external_id = ?", s.tenantID, v))
+	}
+	if v, ok := equalityValue(filter, "id"); ok {
+		query = query.Where("LOWER(user_uuid) = ?", strings.ToLower(v))
+	}
+	var users []model.User
+	if err := query.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	resources, err := s.userResources(users)
+	if err != nil {
+		return nil, err
+	}
+	return list(resources, filter, params), nil
+}
+
+// listUsersPage 在数据库中按条件过滤、计数并分页
+func (s *Service) listUsersPage(cond string, args []interface{}, params ListParams) (*ListResponse, error) {
+	start, count := pageBounds(params)
+	base := s.db.Table("system_auth_users u").
+		Joins("LEFT JOIN scim_users su ON su.user_id = u.id AND su.tenant_id = ?", s.tenantID).
+		Where("u.tenant_id = ? AND u.deleted_at IS NULL", s.tenantID)
+	if cond != "" {
+		base = base.Where(cond, args...)
+	}
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var users []model.User
+	if count > 0 && int64(start) <= total {
+		if err := base.Select("u.*").Order("u.id ASC").Offset(start - 1).Limit(count).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	resources, err := s.userResources(users)
+	if err != nil {
+		return nil, err
+	}
+	return listResponse(resources, int(total), start, params), nil
+}
+
+// GetUser 按 id（用户 UUID）获取用户
+func (s *Service) GetUser(id string) (Resource, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.userResources([]model.User{*user})
+	if err != nil {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// CreateUser 创建租户用户；同租户下已删除的同邮箱用户会被恢复并按请求内容覆盖
+func (s *Service) CreateUser(r Resource) (Resource, error) {
+	in, err := parseUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing model.User
+	err = s.db.Unscoped().Where("tenant_id = ? AND email = ?", s.tenantID, in.email).First(&existing).Error
+	if err == nil {
+		if !existing.DeletedAt.Valid {
+			return nil, errUniqueness("user %s already exists", in.email)
+		}
+		if err := s.db.Unscoped().Model(&existing).Update("deleted_at", nil).Error; err != nil {
+			return nil, err
+		}
+		existing.DeletedAt = gorm.DeletedAt{}
+		if err := s.saveUser(&existing, in); err != nil {
+			return nil, err
+		}
+		return s.GetUser(existing.UserUUID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := s.checkUserNameAvailable(in.userName, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &model.User{
+		TenantID:        s.tenantID,
+		Email:           in.email,
+		Phone:           in.phone,
+		Nickname:        in.displayName,
+		EmailVerified:   true, // 由租户的身份源预配，邮箱视为已验证
+		EmailVerifiedAt: &now,
+	}
+	if err := s.db.Create(user).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, errUniqueness("user %s already exists", in.email)
+		}
+		return nil, err
+	}
+	if err := s.saveUser(user, in); err != nil {
+		return nil, err
+	}
+	return s.GetUser(user.UserUUID)
+}
+
+// ReplaceUser 整体替换用户属性（PUT）
+func (s *Service) ReplaceUser(id string, r Resource) (Resource, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	in, err := parseUser(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveUser(user, in); err != nil {
+		return nil, err
+	}
+	return s.GetUser(user.UserUUID)
+}
+
+// PatchUser 按 PATCH 操作修改用户
+func (s *Service) PatchUser(id string, req *PatchRequest) (Resource, error) {
+	current, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyPatch(current, req); err != nil {
+		return nil, err
+	}
+	return s.ReplaceUser(id, current)
+}
+
+// DeleteUser 停用并删除（软删除）用户
+func (s *Service) DeleteUser(id string) error {
+	user, err := s.findUser(id)
+	if err != nil {
+		return err
+	}
+	if err := s.guardOwner(user.ID); err != nil {
+		return err
+	}
+	if err := s.deprovision(user.ID); err != nil {
+		return err
+	}
+	return s.db.Delete(user).Error
+}
+
+func (s *Service) findUser(id string) (*model.User, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	var user model.User
+	err := s.db.Where("tenant_id = ? AND LOWER(user_uuid) = ?", s.tenantID, id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || id == "" {
+		return nil, errNotFound("User", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// saveUser 写入用户、SCIM 属性与资料，并按 active 停用或恢复用户
+func (s *Service) saveUser(user *model.User, in *userInput) error {
+	if err := s.checkUserNameAvailable(in.userName, user.ID); err != nil {
+		return err
+	}
+	if !in.active && !user.Banned {
+		if err := s.guardOwner(user.ID); err != nil {
+			return err
+		}
+	}
+
+	updates := map[string]interface{}{
+		"nickname": in.displayName,
+		"banned":   !in.active,
+	}
+	if in.phone != "" {
+		updates["phone"] = in.phone
+	} else {
+		updates["phone"] = nil
+	}
+	if !strings.EqualFold(user.Email, in.email) {
+		updates["email"] = in.email
+		updates["email_verified"] = true
+		updates["email_verified_at"] = time.Now()
+	}
+	if in.password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(in.password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		updates["password_hash"] = string(hash)
+		updates["password_changed_at"] = time.Now()
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		var link model.SCIMUser
+		err := tx.Where("tenant_id = ? AND user_id = ?", s.tenantID, user.ID).First(&link).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		link.TenantID = s.tenantID
+		link.UserID = user.ID
+		link.ExternalID = in.externalID
+		link.UserName = in.userName
+		link.GivenName = in.givenName
+		link.FamilyName = in.familyName
+		if err := tx.Save(&link).Error; err != nil {
+			return err
+		}
+
+		var profile model.UserProfile
+		err = tx.Where("user_id = ?", user.ID).First(&profile).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if in.title == "" && in.timezone == "" {
+				return nil
+			}
+			profile = model.UserProfile{UserID: user.ID, Timezone: "UTC"}
+		} else if err != nil {
+			return err
+		}
+		profile.JobTitle = in.title
+		if in.timezone != "" {
+			profile.Timezone = in.timezone
+		}
+		return tx.Save(&profile).Error
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errUniqueness("email or phone number already in use")
+		}
+		return err
+	}
+
+	if !in.active && !user.Banned {
+		return s.deprovision(user.ID)
+	}
+	return nil
+}
+
+func (s *Service) checkUserNameAvailable(userName string, userID uint) error {
+	var count int64
+	if err := s.db.Model(&model.SCIMUser{}).
+		Where("tenant_id = ? AND LOWER(user_name) = ? AND user_id <> ?", s.tenantID, strings.ToLower(userName), userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errUniqueness("userName %s already exists", userName)
+	}
+	return nil
+}
+
+// guardOwner 租户所有者不能通过 SCIM 停用或删除
+func (s *Service) guardOwner(userID uint) error {
+	var count int64
+	if err := s.db.Model(&model.TenantUser{}).
+		Where("tenant_id = ? AND user_id = ? AND role = ?", s.tenantID, userID, model.TenantRoleOwner).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return newError(http.StatusForbidden, "", "the tenant owner cannot be deprovisioned")
+	}
+	return nil
+}
+
+// deprovision 撤销用户的控制台会话与 OAuth 令牌，并通知调用方
+func (s *Service) deprovision(userID uint) error {
+	if _, err := consolesession.NewService(s.db).RevokeAll(userID, "", model.ConsoleSessionRevokedAdmin); err != nil {
+		return err
+	}
+	if s.RevokeTokens != nil {
+		if err := s.RevokeTokens(userID); err != nil {
+			return err
+		}
+	}
+	if s.OnDeprovision != nil {
+		s.OnDeprovision(userID)
+	}
+	return nil
+}
+
+// userResources 批量构造 User 资源
+func (s *Service) userResources(users []model.User) ([]Resource, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+
+	var links []model.SCIMUser
+	if err := s.db.Where("tenant_id = ? AND user_id IN ?", s.tenantID, ids).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	linkByUser := make(map[uint]*model.SCIMUser, len(links))
+	for i := range links {
+		linkByUser[links[i].UserID] = &links[i]
+	}
+
+	var profiles []model.UserProfile
+	if err := s.db.Where("user_id IN ?", ids).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	profileByUser := make(map[uint]*model.UserProfile, len(profiles))
+	for i := range profiles {
+		profileByUser[profiles[i].UserID] = &profiles[i]
+	}
+
+	groups, err := s.userGroups(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, len(users))
+	for i := range users {
+		resources[i] = s.userResource(&users[i], linkByUser[users[i].ID], profileByUser[users[i].ID], groups[users[i].ID])
+	}
+	return resources, nil
+}
+
+func (s *Service) userResource(user *model.User, link *model.SCIMUser, profile *model.UserProfile, groups []interface{}) Resource {
+	location := s.baseURL + "/Users/" + user.UserUUID
+	r := Resource{
+		"schemas":     []interface{}{SchemaUser},
+		"id":          user.UserUUID,
+		"userName":    user.Email,
+		"displayName": user.Nickname,
+		"active":      !user.Banned,
+		"emails": []interface{}{
+			map[string]interface{}{"value": user.Email, "type": "work", "primary": true},
+		},
+		"groups": groups,
+		"meta":   meta("User", location, user.CreatedAt, user.UpdatedAt),
+	}
+	if groups == nil {
+		r["groups"] = []interface{}{}
+	}
+	if user.Phone != "" {
+		r["phoneNumbers"] = []interface{}{
+			map[string]interface{}{"value": user.Phone, "type": "mobile", "primary": true},
+		}
+	}
+	if link != nil {
+		if link.ExternalID != "" {
+			r["externalId"] = link.ExternalID
+		}
+		if link.UserName != "" {
+			r["userName"] = link.UserName
+		}
+		if link.GivenName != "" || link.FamilyName != "" {
+			r["name"] = map[string]interface{}{
+				"givenName":  link.GivenName,
+				"familyName": link.FamilyName,
+				"formatted":  strings.TrimSpace(link.GivenName + " " + link.FamilyName),
+			}
+		}
+		if link.UpdatedAt.After(user.UpdatedAt) {
+			r["meta"] = meta("User", location, user.CreatedAt, link.UpdatedAt)
+		}
+	}
+	if profile != nil {
+		if profile.JobTitle != "" {
+			r["title"] = profile.JobTitle
+		}
+		if profile.Timezone != "" {
+			r["timezone"] = profile.Timezone
+		}
+	}
+	return r
+}
+
+// userGroups 用户所属的团队与租户角色（只读的 groups 属性）
+func (s *Service) userGroups(userIDs []uint) (map[uint][]interface{}, error) {
+	type row struct {
+		UserID  uint
+		GroupID uint
+		Name    string
+	}
+	out := make(map[uint][]interface{})
+
+	var teams []row
+	if err := s.db.Table("system_auth_team_members m").
+		Select("m.user_id, t.id AS group_id, t.name").
+		Joins("JOIN system_auth_teams t ON t.id = m.team_id").
+		Where("t.tenant_id = ? AND m.user_id IN ? AND m.deleted_at IS NULL AND t.deleted_at IS NULL", s.tenantID, userIDs).
+		Order("t.id ASC").
+		Scan(&teams).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		out[t.UserID] = append(out[t.UserID], s.groupRef(groupID(model.SCIMGroupKindTeam, t.GroupID), t.Name))
+	}
+
+	var roles []row
+	if err := s.db.Table("tenant_user_roles ur").
+		Select("ur.user_id, r.id AS group_id, r.name").
+		Joins("JOIN tenant_roles r ON r.id = ur.role_id").
+		Where("r.tenant_id = ? AND ur.tenant_id = ? AND ur.user_id IN ?", s.tenantID, s.tenantID, userIDs).
+		Order("r.id ASC").
+		Scan(&roles).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		out[r.UserID] = append(out[r.UserID], s.groupRef(groupID(model.SCIMGroupKindRole, r.GroupID), r.Name))
+	}
+	return out, nil
+}
+
+func (s *Service) groupRef(id, display string) map[string]interface{} {
+	return map[string]interface{}{
+		"value":   id,
+		"display": display,
+		"type":    "direct",
+		"$ref":    s.baseURL + "/Groups/" + id,
+	}
+}
+
+// isUniqueViolation 识别各数据库的唯一约束冲突
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unique constraint") || strings.Contains(msg, "duplicate") || strings.Contains(msg, "duplicated key")
+}
@@ -86,15 +86,20 @@ func defaultItems() map[string]SettingItem {
 		"auth.passkey.session_ttl_seconds": {Value: 300, Category: "auth", Description: "Passkey challenge 会话 TTL（秒）"},
 		"auth.passkey.session_capacity":    {Value: 1024, Category: "auth", Description: "Passkey challenge 会话最大容量"},
 
+		// 免密登录：平台登录入口的开关，租户登录入口由租户登录设置单独控制
+		"auth.passwordless.email_enabled": {Value: false, Category: "auth", Description: "是否允许通过邮箱登录链接或验证码免密登录平台"},
+		"auth.passwordless.sms_enabled":   {Value: false, Category: "auth", Description: "是否允许通过短信验证码免密登录平台"},
+
 		// Security
-		"security.enforce_2fa":                    {Value: false, Category: "security", Description: "是否强制启用 2FA"},
-		"security.allowed_ips":                    {Value: []string{}, Category: "security", Description: "允许访问的 IP 列表（留空表示不限制）"},
-		"security.csp":                            {Value: "default-src 'self'", Category: "security", Description: "Content Security Policy"},
-		"security.rate_limit.enabled":             {Value: true, Category: "security", Description: "是否启用速率限制"},
-		"security.rate_limit.requests_per_minute": {Value: 120, Category: "security", Description: "每分钟请求上限"},
-		"security.account_lockout.enabled":        {Value: true, Category: "security", Description: "是否启用登录失败锁定"},
-		"security.account_lockout.max_attempts":   {Value: 5, Category: "security", Description: "锁定前允许的最大失败次数"},
-		"security.account_lockout.window_minutes": {Value: 15, Category: "security", Description: "统计失败次数的时间窗口（分钟）"},
+		"security.enforce_2fa":                      {Value: false, Category: "security", Description: "是否强制启用 2FA"},
+		"security.allowed_ips":                      {Value: []string{}, Category: "security", Description: "允许访问的 IP 列表（留空表示不限制）"},
+		"security.csp":                              {Value: "default-src 'self'", Category: "security", Description: "Content Security Policy"},
+		"security.rate_limit.enabled":               {Value: true, Category: "security", Description: "是否启用速率限制"},
+		"security.rate_limit.requests_per_minute":   {Value: 120, Category: "security", Description: "每分钟请求上限"},
+		"security.account_lockout.enabled":          {Value: true, Category: "security", Description: "是否启用登录失败锁定"},
+		"security.account_lockout.max_attempts":     {Value: 5, Category: "security", Description: "锁定前允许的最大失败次数"},
+		"security.account_lockout.window_minutes":   {Value: 15, Category: "security", Description: "统计失败次数的时间窗口（分钟），也是首次锁定的时长，之后每次锁定翻倍"},
+		"security.account_lockout.max_lock_minutes": {Value: 1440, Category: "security", Description: "指数退避后单次锁定的最长时长（分钟）"},
 
 		// CORS
 		"cors.allow_origins": {Value: []string{
@@ -120,9 +125,11 @@ func defaultItems() map[string]SettingItem {
 		"billing.invoice_prefix":   {Value: "INV", Category: "billing", Description: "发票编号前缀"},
 
 		// OAuth
-		"oauth.allowed_redirect_hosts": {Value: []string{"localhost"}, Category: "oauth", Description: "允许的 OAuth 回调主机名"},
-		"oauth.pkce_required":          {Value: true, Category: "oauth", Description: "是否要求 PKCE"},
-		"oauth.enable_refresh_tokens":  {Value: true, Category: "oauth", Description: "是否启用刷新令牌"},
+		"oauth.allowed_redirect_hosts":     {Value: []string{"localhost"}, Category: "oauth", Description: "允许的 OAuth 回调主机名"},
+		"oauth.pkce_required":              {Value: true, Category: "oauth", Description: "是否要求 PKCE"},
+		"oauth.enable_refresh_tokens":      {Value: true, Category: "oauth", Description: "是否启用刷新令牌"},
+		"oauth.signing_key_rotation_days":  {Value: 90, Category: "oauth", Description: "ID Token 签名密钥轮换周期（天）"},
+		"oauth.signing_key_retention_days": {Value: 7, Category: "oauth", Description: "退役签名密钥在 JWKS 中保留的天数"},
 		"oauth.allowed_scopes": {Value: []string{
 			"openid",
 			"profile",
@@ -193,8 +200,9 @@ func defaultItems() map[string]SettingItem {
 		"jwt.issuer":              {Value: "basaltpass", Category: "jwt", Description: "JWT Issuer"},
 		"jwt.exp_minutes":         {Value: 60, Category: "jwt", Description: "访问令牌过期分钟数"},
 		"jwt.refresh_exp_minutes": {Value: 60 * 24 * 7, Category: "jwt", Description: "刷新令牌过期分钟数"},
-		"jwt.algorithm":           {Value: "HS256", Category: "jwt", Description: "JWT 签名算法（HS256/RS256/ES256）"},
+		"jwt.algorithm":           {Value: "HS256", Category: "jwt", Description: "控制台令牌签名算法（HS256/RS256/EdDSA），非对称算法使用轮换密钥并通过 /auth/jwks 发布公钥；RS256 与 EdDSA 互换在新生成的密钥上生效"},
 		"jwt.audience":            {Value: "", Category: "jwt", Description: "JWT 受众（aud）"},
+		"jwt.hmac_grace_minutes":  {Value: 60 * 24 * 7, Category: "jwt", Description: "切换到非对称签名后仍接受 HS256 令牌的宽限期（分钟）"},
 
 		// Maintenance & Features
 		"maintenance.enabled":               {Value: false, Category: "maintenance", Description: "维护模式：启用后仅管理员可访问"},
@@ -0,0 +1,624 @@
+package signingkey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	settingssvc "basaltpass-backend/internal/service/settings"
+	"basaltpass-backend/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the signing key service.
+var (
+	ErrKeyNotFound    = errors.New("signing_key_not_found")
+	ErrKeyNotUsable   = errors.New("signing_key_not_usable")
+	ErrNoActiveKey    = errors.New("no_active_signing_key")
+	ErrAlreadyRevoked = errors.New("signing_key_already_revoked")
+)
+
+const (
+	// AlgorithmRS256 RSA PKCS#1 v1.5 + SHA-256（OAuth/OIDC 令牌固定使用）
+	AlgorithmRS256 = "RS256"
+	// AlgorithmEdDSA Ed25519（仅控制台令牌可选）
+	AlgorithmEdDSA = "EdDSA"
+
+	rsaKeyBits           = 2048
+	defaultRotationDays  = 90
+	defaultRetentionDays = 7
+	cacheTTL             = time.Minute
+)
+
+// Key 是已加载到内存中的签名密钥。私钥仅在 active 密钥上可用。
+// RS256 密钥使用 PublicKey / PrivateKey，EdDSA 密钥使用 EdPublicKey / EdPrivateKey。
+type Key struct {
+	KID          string
+	Algorithm    string
+	Status       model.SigningKeyStatus
+	PublicKey    *rsa.PublicKey
+	PrivateKey   *rsa.PrivateKey
+	EdPublicKey  ed25519.PublicKey
+	EdPrivateKey ed25519.PrivateKey
+}
+
+// SigningMethod 返回密钥对应的 JWT 签名算法
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// SignKey 返回用于签名的私钥；非 active 密钥返回 nil
+func (k *Key) SignKey() interface{} {
+	if k.Algorithm == AlgorithmEdDSA {
+		if k.EdPrivateKey == nil {
+			return nil
+		}
+		return k.EdPrivateKey
+	}
+	if k.PrivateKey == nil {
+		return nil
+	}
+	return k.PrivateKey
+}
+
+// VerifyKey 返回用于验证签名的公钥
+func (k *Key) VerifyKey() interface{} {
+	if k.Algorithm == AlgorithmEdDSA {
+		return k.EdPublicKey
+	}
+	return k.PublicKey
+}
+
+// Service 管理数据库中持久化的签名密钥集合。
+// 多副本部署下数据库是唯一事实来源，内存缓存最长 cacheTTL 后刷新。
+type Service struct {
+	db        *gorm.DB
+	purpose   string
+	algorithm func() string
+
+	mu             sync.Mutex
+	keys           []*Key
+	loadedAt       time.Time
+	firstCreatedAt time.Time
+	certs          map[string][]byte
+}
+
+// NewService creates the signing key service for OAuth/OIDC tokens (RS256).
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, purpose: model.SigningKeyPurposeOAuth, algorithm: func() string { return AlgorithmRS256 }}
+}
+
+// NewConsoleService 创建控制台令牌的签名密钥服务。
+// algorithm 在生成新密钥时读取，切换算法后于下一次轮换生效，存量密钥仍按自身算法验证。
+func NewConsoleService(db *gorm.DB, algorithm func() string) *Service {
+	return &Service{db: db, purpose: model.SigningKeyPurposeConsole, algorithm: algorithm}
+}
+
+// Purpose 返回密钥集合的用途
+func (s *Service) Purpose() string {
+	return s.purpose
+}
+
+// FirstCreatedAt 返回该用途下最早一把密钥的创建时间；尚未生成过密钥时返回零值。
+// 该时间一经产生便不再变化，查询到后缓存在内存中。
+func (s *Service) FirstCreatedAt() (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.firstCreatedAt.IsZero() {
+		return s.firstCreatedAt, nil
+	}
+
+	var first model.SigningKey
+	err := s.db.Select("created_at").Where("purpose = ?", s.purpose).Order("id ASC").First(&first).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.firstCreatedAt = first.CreatedAt
+	return s.firstCreatedAt, nil
+}
+
+// RotationInterval 活跃密钥的轮换周期
+func RotationInterval() time.Duration {
+	days := settingssvc.GetInt("oauth.signing_key_rotation_days", defaultRotationDays)
+	if days <= 0 {
+		days = defaultRotationDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RetentionPeriod 退役密钥继续发布在 JWKS 中的时间
+func RetentionPeriod() time.Duration {
+	days := settingssvc.GetInt("oauth.signing_key_retention_days", defaultRetentionDays)
+	if days <= 0 {
+		days = defaultRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ActiveKey 返回当前用于签名的密钥；首次调用时若数据库中没有密钥会自动生成。
+func (s *Service) ActiveKey() (*Key, error) {
+	keys, err := s.loadKeys(false)
+	if err != nil {
+		return nil, err
+	}
+	if key := pickActive(keys); key != nil {
+		return key, nil
+	}
+
+	if err := s.bootstrap(); err != nil {
+		return nil, err
+	}
+	keys, err = s.loadKeys(true)
+	if err != nil {
+		return nil, err
+	}
+	if key := pickActive(keys); key != nil {
+		return key, nil
+	}
+	return nil, ErrNoActiveKey
+}
+
+// PublishedKeys 返回需要发布到 JWKS 的全部验证密钥（next / active / 未过期的 retired）。
+func (s *Service) PublishedKeys() ([]*Key, error) {
+	if _, err := s.ActiveKey(); err != nil {
+		return nil, err
+	}
+	return s.loadKeys(false)
+}
+
+// LookupKey 按 kid 查找可用于验证签名的密钥；缓存未命中时强制刷新一次。
+func (s *Service) LookupKey(kid string) (*Key, error) {
+	for _, force := range []bool{false, true} {
+		keys, err := s.loadKeys(force)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if k.KID == kid {
+				return k, nil
+			}
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// List 返回所有密钥记录（包括已吊销的），用于管理端展示。
+func (s *Service) List() ([]model.SigningKey, error) {
+	var records []model.SigningKey
+	if err := s.db.Where("purpose = ?", s.purpose).Order("id DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Rotate 立即轮换：next 晋升为 active，原 active 退役，并生成新的 next。
+func (s *Service) Rotate() (*model.SigningKey, error) {
+	var promoted model.SigningKey
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		expiresAt := now.Add(RetentionPeriod())
+
+		if err := tx.Model(&model.SigningKey{}).
+			Where("purpose = ? AND status = ?", s.purpose, model.SigningKeyStatusActive).
+			Updates(map[string]interface{}{
+				"status":     model.SigningKeyStatusRetired,
+				"retired_at": now,
+				"expires_at": expiresAt,
+			}).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("purpose = ? AND status = ?", s.purpose, model.SigningKeyStatusNext).Order("id ASC").First(&promoted).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created, genErr := s.generateKeyRecord(model.SigningKeyStatusNext)
+			if genErr != nil {
+				return genErr
+			}
+			if err := tx.Create(created).Error; err != nil {
+				return err
+			}
+			promoted = *created
+		} else if err != nil {
+			return err
+		}
+
+		promoted.Status = model.SigningKeyStatusActive
+		promoted.ActivatedAt = &now
+		if err := tx.Model(&promoted).Updates(map[string]interface{}{
+			"status":       promoted.Status,
+			"activated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		next, err := s.generateKeyRecord(model.SigningKeyStatusNext)
+		if err != nil {
+			return err
+		}
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	log.Printf("[signingkey][info] Rotated %s signing key, active kid=%s", s.purpose, promoted.KID)
+	return &promoted, nil
+}
+
+// Revoke 立即吊销指定密钥并将其从 JWKS 中移除；若吊销的是 active 密钥则同时触发轮换。
+func (s *Service) Revoke(kid string) error {
+	var record model.SigningKey
+	if err := s.db.Where("kid = ? AND purpose = ?", kid, s.purpose).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrKeyNotFound
+		}
+		return err
+	}
+	if record.Status == model.SigningKeyStatusRevoked {
+		return ErrAlreadyRevoked
+	}
+
+	wasActive := record.Status == model.SigningKeyStatusActive
+	now := time.Now()
+	if err := s.db.Model(&record).Updates(map[string]interface{}{
+		"status":     model.SigningKeyStatusRevoked,
+		"revoked_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	s.invalidate()
+
+	log.Printf("[signingkey][warn] Revoked signing key kid=%s (was %s)", kid, record.Status)
+	if wasActive {
+		if _, err := s.Rotate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RotateIfDue 当活跃密钥超过轮换周期时执行轮换。
+func (s *Service) RotateIfDue() (bool, error) {
+	var active model.SigningKey
+	err := s.db.Where("purpose = ? AND status = ?", s.purpose, model.SigningKeyStatusActive).Order("activated_at DESC").First(&active).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	activatedAt := active.CreatedAt
+	if active.ActivatedAt != nil {
+		activatedAt = *active.ActivatedAt
+	}
+	if time.Since(activatedAt) < RotationInterval() {
+		return false, nil
+	}
+
+	if _, err := s.Rotate(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartRotationScheduler 启动后台定时轮换检查。
+func StartRotationScheduler(s *Service, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := s.RotateIfDue(); err != nil {
+				log.Printf("[signingkey][error] Scheduled rotation failed: %v", err)
+			}
+		}
+	}()
+}
+
+// JWK 返回密钥的 JWK 公钥表示
+func JWK(k *Key) map[string]interface{} {
+	if k.Algorithm == AlgorithmEdDSA {
+		return map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"use": "sig",
+			"alg": k.Algorithm,
+			"kid": k.KID,
+			"x":   base64.RawURLEncoding.EncodeToString(k.EdPublicKey),
+		}
+	}
+
+	// Base64URL编码（无填充）
+	n := base64.RawURLEncoding.EncodeToString(k.PublicKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.PublicKey.E)).Bytes())
+
+	return map[string]interface{}{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": k.Algorithm,
+		"kid": k.KID,
+		"n":   n,
+		"e":   e,
+	}
+}
+
+// Certificate 返回 RS256 密钥的自签名 X.509 证书（DER），供 SAML 元数据与 XML 签名的 KeyInfo 使用。
+// 证书字段全部由密钥记录确定（PKCS#1 v1.5 签名不含随机数），同一密钥每次生成的证书逐字节相同，
+// 因此 next 密钥的证书可以提前发布，SP 缓存的证书在轮换后依然有效。
+func (s *Service) Certificate(kid string) ([]byte, error) {
+	s.mu.Lock()
+	if der, ok := s.certs[kid]; ok {
+		s.mu.Unlock()
+		return der, nil
+	}
+	s.mu.Unlock()
+
+	var record model.SigningKey
+	if err := s.db.Where("kid = ? AND purpose = ?", kid, s.purpose).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+	if record.Algorithm != AlgorithmRS256 || !record.IsPublished(time.Now()) {
+		return nil, ErrKeyNotUsable
+	}
+	privDER, err := utils.DecryptSigningKey(record.PrivateKeyEnc)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := x509.ParsePKCS1PrivateKey(privDER)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(record.KID))
+	notBefore := record.CreatedAt.UTC().Truncate(24 * time.Hour)
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(sum[:16]),
+		Subject:               pkix.Name{CommonName: "BasaltPass Signing Key " + record.KID},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(20, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.certs == nil {
+		s.certs = make(map[string][]byte)
+	}
+	s.certs[kid] = der
+	s.mu.Unlock()
+	return der, nil
+}
+
+// bootstrap 在没有 active 密钥时生成 active 与 next 两把密钥。
+func (s *Service) bootstrap() error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var activeCount int64
+		if err := tx.Model(&model.SigningKey{}).Where("purpose = ? AND status = ?", s.purpose, model.SigningKeyStatusActive).Count(&activeCount).Error; err != nil {
+			return err
+		}
+		if activeCount > 0 {
+			return nil
+		}
+
+		now := time.Now()
+		active, err := s.generateKeyRecord(model.SigningKeyStatusActive)
+		if err != nil {
+			return err
+		}
+		active.ActivatedAt = &now
+		if err := tx.Create(active).Error; err != nil {
+			return err
+		}
+
+		var nextCount int64
+		if err := tx.Model(&model.SigningKey{}).Where("purpose = ? AND status = ?", s.purpose, model.SigningKeyStatusNext).Count(&nextCount).Error; err != nil {
+			return err
+		}
+		if nextCount == 0 {
+			next, err := s.generateKeyRecord(model.SigningKeyStatusNext)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(next).Error; err != nil {
+				return err
+			}
+		}
+
+		log.Printf("[signingkey][info] Generated initial %s signing key kid=%s", s.purpose, active.KID)
+		return nil
+	})
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.keys = nil
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// loadKeys 加载已发布的密钥；仅对 active 密钥解密私钥。
+func (s *Service) loadKeys(force bool) ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force && s.keys != nil && time.Since(s.loadedAt) < cacheTTL {
+		return s.keys, nil
+	}
+
+	var records []model.SigningKey
+	if err := s.db.Where("purpose = ? AND status IN ?", s.purpose, []model.SigningKeyStatus{
+		model.SigningKeyStatusNext,
+		model.SigningKeyStatusActive,
+		model.SigningKeyStatusRetired,
+	}).Order("id DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	keys := make([]*Key, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		if !record.IsPublished(now) {
+			continue
+		}
+		key, err := decodeKey(record)
+		if err != nil {
+			log.Printf("[signingkey][error] Failed to load signing key kid=%s: %v", record.KID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	s.keys = keys
+	s.loadedAt = now
+	return keys, nil
+}
+
+func pickActive(keys []*Key) *Key {
+	for _, k := range keys {
+		if k.Status == model.SigningKeyStatusActive && k.SignKey() != nil {
+			return k
+		}
+	}
+	return nil
+}
+
+func decodeKey(record *model.SigningKey) (*Key, error) {
+	block, _ := pem.Decode([]byte(record.PublicKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid public key pem")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{
+		KID:       record.KID,
+		Algorithm: record.Algorithm,
+		Status:    record.Status,
+	}
+	switch typed := pub.(type) {
+	case *rsa.PublicKey:
+		if record.Algorithm != AlgorithmRS256 {
+			return nil, ErrKeyNotUsable
+		}
+		key.PublicKey = typed
+	case ed25519.PublicKey:
+		if record.Algorithm != AlgorithmEdDSA {
+			return nil, ErrKeyNotUsable
+		}
+		key.EdPublicKey = typed
+	default:
+		return nil, ErrKeyNotUsable
+	}
+
+	if record.Status == model.SigningKeyStatusActive {
+		der, err := utils.DecryptSigningKey(record.PrivateKeyEnc)
+		if err != nil {
+			return nil, err
+		}
+		if record.Algorithm == AlgorithmEdDSA {
+			priv, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return nil, err
+			}
+			edPriv, ok := priv.(ed25519.PrivateKey)
+			if !ok {
+				return nil, ErrKeyNotUsable
+			}
+			key.EdPrivateKey = edPriv
+		} else {
+			priv, err := x509.ParsePKCS1PrivateKey(der)
+			if err != nil {
+				return nil, err
+			}
+			key.PrivateKey = priv
+		}
+	}
+
+	return key, nil
+}
+
+// generateKeyRecord 按密钥集合当前配置的算法生成新密钥；RSA 私钥以 PKCS#1、Ed25519 私钥以 PKCS#8 编码后加密
+func (s *Service) generateKeyRecord(status model.SigningKeyStatus) (*model.SigningKey, error) {
+	algorithm := AlgorithmRS256
+	if s.algorithm != nil && s.algorithm() == AlgorithmEdDSA {
+		algorithm = AlgorithmEdDSA
+	}
+
+	var public interface{}
+	var privDER []byte
+	if algorithm == AlgorithmEdDSA {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		if privDER, err = x509.MarshalPKCS8PrivateKey(priv); err != nil {
+			return nil, err
+		}
+		public = pub
+	} else {
+		priv, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		privDER = x509.MarshalPKCS1PrivateKey(priv)
+		public = &priv.PublicKey
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	encPriv, err := utils.EncryptSigningKey(privDER)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SigningKey{
+		KID:           thumbprintKID(pubDER),
+		Purpose:       s.purpose,
+		Algorithm:     algorithm,
+		Status:        status,
+		PublicKeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+		PrivateKeyEnc: encPriv,
+	}, nil
+}
+
+// thumbprintKID 由公钥 DER 的 SHA-256 摘要派生 kid，保证全局唯一且与密钥内容绑定。
+func thumbprintKID(pubDER []byte) string {
+	sum := sha256.Sum256(pubDER)
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
@@ -0,0 +1,219 @@
+package signingkey
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSigningKeyTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret-for-unit-tests")
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(&model.SigningKey{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func publishedKIDs(t *testing.T, s *Service) map[string]model.SigningKeyStatus {
+	t.Helper()
+	keys, err := s.PublishedKeys()
+	if err != nil {
+		t.Fatalf("published keys failed: %v", err)
+	}
+	kids := make(map[string]model.SigningKeyStatus, len(keys))
+	for _, k := range keys {
+		kids[k.KID] = k.Status
+	}
+	return kids
+}
+
+func TestActiveKeyBootstrapsActiveAndNext(t *testing.T) {
+	db := setupSigningKeyTestDB(t)
+	s := NewService(db)
+
+	active, err := s.ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	if active.PrivateKey == nil {
+		t.Fatalf("active key must carry a private key")
+	}
+
+	var record model.SigningKey
+	if err := db.Where("kid = ?", active.KID).First(&record).Error; err != nil {
+		t.Fatalf("load record failed: %v", err)
+	}
+	if !strings.HasPrefix(record.PrivateKeyEnc, "enc:sk1:") {
+		t.Fatalf("private key must be stored encrypted")
+	}
+
+	kids := publishedKIDs(t, s)
+	if len(kids) != 2 {
+		t.Fatalf("expected active and next keys in JWKS, got %v", kids)
+	}
+
+	// 新实例（模拟其他副本）读取同一密钥
+	other, err := NewService(db).ActiveKey()
+	if err != nil {
+		t.Fatalf("active key from second instance failed: %v", err)
+	}
+	if other.KID != active.KID {
+		t.Fatalf("expected replicas to share the active key")
+	}
+}
+
+func TestRotateKeepsRetiredKeyPublished(t *testing.T) {
+	db := setupSigningKeyTestDB(t)
+	s := NewService(db)
+
+	before, err := s.ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	var next model.SigningKey
+	if err := db.Where("status = ?", model.SigningKeyStatusNext).First(&next).Error; err != nil {
+		t.Fatalf("load next key failed: %v", err)
+	}
+
+	promoted, err := s.Rotate()
+	if err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if promoted.KID != next.KID {
+		t.Fatalf("expected next key %s to be promoted, got %s", next.KID, promoted.KID)
+	}
+
+	after, err := s.ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	if after.KID != next.KID {
+		t.Fatalf("expected active key to change after rotation")
+	}
+
+	kids := publishedKIDs(t, s)
+	if kids[before.KID] != model.SigningKeyStatusRetired {
+		t.Fatalf("retired key must stay in JWKS during retention, got %v", kids)
+	}
+	if _, err := s.LookupKey(before.KID); err != nil {
+		t.Fatalf("retired key must still verify: %v", err)
+	}
+
+	// 保留期结束后从 JWKS 移除
+	past := time.Now().Add(-time.Minute)
+	if err := db.Model(&model.SigningKey{}).Where("kid = ?", before.KID).Update("expires_at", past).Error; err != nil {
+		t.Fatalf("expire key failed: %v", err)
+	}
+	s.invalidate()
+	if _, err := s.LookupKey(before.KID); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected expired key to be unpublished, got %v", err)
+	}
+}
+
+func TestRevokeActiveKeyRotatesImmediately(t *testing.T) {
+	db := setupSigningKeyTestDB(t)
+	s := NewService(db)
+
+	compromised, err := s.ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	if err := s.Revoke(compromised.KID); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	if _, ok := publishedKIDs(t, s)[compromised.KID]; ok {
+		t.Fatalf("revoked key must be removed from JWKS")
+	}
+	replacement, err := s.ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	if replacement.KID == compromised.KID {
+		t.Fatalf("expected a new active key after revoking the active one")
+	}
+	if err := s.Revoke(compromised.KID); !errors.Is(err, ErrAlreadyRevoked) {
+		t.Fatalf("expected already revoked error, got %v", err)
+	}
+}
+
+func TestConsoleKeysAreSeparateFromOAuthKeys(t *testing.T) {
+	db := setupSigningKeyTestDB(t)
+	oauthKeys := NewService(db)
+	consoleKeys := NewConsoleService(db, func() string { return AlgorithmEdDSA })
+
+	oauthActive, err := oauthKeys.ActiveKey()
+	if err != nil {
+		t.Fatalf("oauth active key failed: %v", err)
+	}
+	consoleActive, err := consoleKeys.ActiveKey()
+	if err != nil {
+		t.Fatalf("console active key failed: %v", err)
+	}
+	if consoleActive.EdPrivateKey == nil || consoleActive.SigningMethod().Alg() != AlgorithmEdDSA {
+		t.Fatalf("expected an Ed25519 console key")
+	}
+	if jwk := JWK(consoleActive); jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" {
+		t.Fatalf("unexpected console JWK: %v", jwk)
+	}
+
+	// 两组密钥互不可见，轮换互不影响
+	if _, ok := publishedKIDs(t, consoleKeys)[oauthActive.KID]; ok {
+		t.Fatalf("oauth key must not be published in console JWKS")
+	}
+	if _, err := oauthKeys.LookupKey(consoleActive.KID); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected console key unknown to oauth set, got %v", err)
+	}
+	if _, err := consoleKeys.Rotate(); err != nil {
+		t.Fatalf("rotate console keys failed: %v", err)
+	}
+	if after, _ := oauthKeys.ActiveKey(); after.KID != oauthActive.KID {
+		t.Fatalf("rotating console keys must not rotate oauth keys")
+	}
+}
+
+func TestCertificateIsStableAcrossInstances(t *testing.T) {
+	db := setupSigningKeyTestDB(t)
+	s := NewService(db)
+
+	active, err := s.ActiveKey()
+	if err != nil {
+		t.Fatalf("active key failed: %v", err)
+	}
+	der, err := s.Certificate(active.KID)
+	if err != nil {
+		t.Fatalf("certificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate failed: %v", err)
+	}
+	if !active.PublicKey.Equal(cert.PublicKey) {
+		t.Fatalf("certificate must carry the signing key's public key")
+	}
+
+	// 其他副本生成的证书逐字节相同，next 密钥同样可以生成证书
+	again, err := NewService(db).Certificate(active.KID)
+	if err != nil || !bytes.Equal(der, again) {
+		t.Fatalf("expected identical certificate from another instance, err=%v", err)
+	}
+	for kid := range publishedKIDs(t, s) {
+		if _, err := s.Certificate(kid); err != nil {
+			t.Fatalf("certificate for published key %s failed: %v", kid, err)
+		}
+	}
+}
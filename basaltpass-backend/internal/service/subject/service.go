@@ -0,0 +1,193 @@
+package subject
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const maxSectorDocumentBytes = 64 << 10
+
+// ErrUnknownSubject sub 不对应该客户端可见的任何用户
+var ErrUnknownSubject = errors.New("unknown subject")
+
+// httpClient 拉取客户端登记的 sector_identifier_uri，只允许连接公网地址
+var httpClient = utils.NewPublicHTTPClient(5 * time.Second)
+
+// Fetcher 拉取 sector_identifier_uri 的内容
+type Fetcher func(uri string) ([]byte, error)
+
+// Service 计算客户端看到的用户主体标识（OIDC Core §8）。
+// public 客户端的 sub 为用户ID；pairwise 客户端的 sub 按扇区随机生成并持久化，以便反查用户。
+type Service struct {
+	db    *gorm.DB
+	fetch Fetcher
+}
+
+// NewService 创建主体标识服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, fetch: fetchSectorDocument}
+}
+
+// WithFetcher 替换 sector_identifier_uri 的拉取方式（测试使用）
+func (s *Service) WithFetcher(fetch Fetcher) *Service {
+	s.fetch = fetch
+	return s
+}
+
+// Sector 返回 pairwise 客户端的扇区：sector_identifier_uri 的主机名，未配置时取重定向地址的主机名
+func Sector(client *model.OAuthClient) (string, error) {
+	if client.SectorIdentifierURI != "" {
+		u, err := url.Parse(client.SectorIdentifierURI)
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("invalid sector_identifier_uri")
+		}
+		return strings.ToLower(u.Hostname()), nil
+	}
+	return redirectHost(client.GetRedirectURIList())
+}
+
+// redirectHost 返回重定向地址共同的主机名；主机名不唯一时无法确定扇区
+func redirectHost(redirectURIs []string) (string, error) {
+	host := ""
+	for _, raw := range redirectURIs {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("invalid redirect_uri: %s", raw)
+		}
+		h := strings.ToLower(u.Hostname())
+		if host != "" && h != host {
+			return "", fmt.Errorf("redirect_uris span multiple hosts, sector_identifier_uri is required")
+		}
+		host = h
+	}
+	if host == "" {
+		return "", fmt.Errorf("no redirect_uris to derive sector from")
+	}
+	return host, nil
+}
+
+// Subject 返回 userID 在该客户端下的 sub
+func (s *Service) Subject(client *model.OAuthClient, userID uint) (string, error) {
+	if client == nil || !client.UsesPairwiseSubjects() {
+		return strconv.FormatUint(uint64(userID), 10), nil
+	}
+	sector, err := Sector(client)
+	if err != nil {
+		return "", err
+	}
+
+	var existing model.OAuthPairwiseSubject
+	err = s.db.Where("sector = ? AND user_id = ?", sector, userID).First(&existing).Error
+	if err == nil {
+		return existing.Subject, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	record := model.OAuthPairwiseSubject{Sector: sector, UserID: userID, Subject: hex.EncodeToString(raw)}
+	if err := s.db.Create(&record).Error; err != nil {
+		// 并发请求已生成同一扇区的记录时以已保存的为准
+		if lookupErr := s.db.Where("sector = ? AND user_id = ?", sector, userID).First(&existing).Error; lookupErr == nil {
+			return existing.Subject, nil
+		}
+		return "", err
+	}
+	return record.Subject, nil
+}
+
+// ResolveUserID 将客户端看到的 sub 还原为用户ID
+func (s *Service) ResolveUserID(client *model.OAuthClient, sub string) (uint, error) {
+	sub = strings.TrimSpace(sub)
+	if client == nil || !client.UsesPairwiseSubjects() {
+		uid, err := strconv.ParseUint(sub, 10, 64)
+		if err != nil || uid == 0 {
+			return 0, ErrUnknownSubject
+		}
+		return uint(uid), nil
+	}
+	sector, err := Sector(client)
+	if err != nil {
+		return 0, err
+	}
+
+	var record model.OAuthPairwiseSubject
+	if err := s.db.Where("sector = ? AND subject = ?", sector, sub).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrUnknownSubject
+		}
+		return 0, err
+	}
+	return record.UserID, nil
+}
+
+// ValidateClient 校验客户端的主体标识配置（OIDC Registration §5）。
+// pairwise 客户端需能确定扇区：配置 sector_identifier_uri 时，其内容必须是包含全部 redirect_uris 的 JSON 数组；
+// 未配置时所有 redirect_uris 必须位于同一主机。
+func (s *Service) ValidateClient(subjectType, sectorIdentifierURI string, redirectURIs []string) error {
+	switch subjectType {
+	case "", model.SubjectTypePublic:
+		return nil
+	case model.SubjectTypePairwise:
+	default:
+		return fmt.Errorf("unsupported subject_type: %s", subjectType)
+	}
+
+	if sectorIdentifierURI == "" {
+		_, err := redirectHost(redirectURIs)
+		return err
+	}
+
+	u, err := url.Parse(sectorIdentifierURI)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("sector_identifier_uri must be an https URL")
+	}
+	body, err := s.fetch(sectorIdentifierURI)
+	if err != nil {
+		return fmt.Errorf("fetch sector_identifier_uri failed: %v", err)
+	}
+	var listed []string
+	if err := json.Unmarshal(body, &listed); err != nil {
+		return fmt.Errorf("sector_identifier_uri must return a JSON array of redirect URIs")
+	}
+	allowed := make(map[string]struct{}, len(listed))
+	for _, uri := range listed {
+		allowed[uri] = struct{}{}
+	}
+	for _, uri := range redirectURIs {
+		if _, ok := allowed[strings.TrimSpace(uri)]; !ok {
+			return fmt.Errorf("redirect_uri %s is not listed in sector_identifier_uri", uri)
+		}
+	}
+	return nil
+}
+
+func fetchSectorDocument(uri string) ([]byte, error) {
+	resp, err := httpClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sector_identifier_uri returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSectorDocumentBytes))
+}
@@ -0,0 +1,88 @@
+package subject
+
+import (
+	"errors"
+	"testing"
+
+	"basaltpass-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSubjectTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(&model.OAuthPairwiseSubject{}); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	return db
+}
+
+func TestPairwiseSubjects(t *testing.T) {
+	svc := NewService(setupSubjectTestDB(t))
+
+	public := &model.OAuthClient{ClientID: "public", SubjectType: model.SubjectTypePublic}
+	if sub, err := svc.Subject(public, 42); err != nil || sub != "42" {
+		t.Fatalf("expected public subject 42, got %q %v", sub, err)
+	}
+
+	rpA := &model.OAuthClient{ClientID: "rp-a", SubjectType: model.SubjectTypePairwise, RedirectURIs: "https://a.example.com/cb,https://a.example.com/cb2"}
+	rpA2 := &model.OAuthClient{ClientID: "rp-a2", SubjectType: model.SubjectTypePairwise, RedirectURIs: "https://a.example.com/other"}
+	rpB := &model.OAuthClient{ClientID: "rp-b", SubjectType: model.SubjectTypePairwise, RedirectURIs: "https://b.example.com/cb"}
+
+	subA, err := svc.Subject(rpA, 42)
+	if err != nil || subA == "" || subA == "42" {
+		t.Fatalf("expected opaque pairwise subject, got %q %v", subA, err)
+	}
+	// 同一扇区内的客户端看到相同的 sub，且多次计算保持稳定
+	if again, _ := svc.Subject(rpA2, 42); again != subA {
+		t.Fatalf("expected same subject within a sector, got %q and %q", subA, again)
+	}
+	if subB, _ := svc.Subject(rpB, 42); subB == subA {
+		t.Fatalf("expected different subjects across sectors")
+	}
+	if other, _ := svc.Subject(rpA, 43); other == subA {
+		t.Fatalf("expected different users to get different subjects")
+	}
+
+	if uid, err := svc.ResolveUserID(rpA2, subA); err != nil || uid != 42 {
+		t.Fatalf("expected subject to resolve to user 42, got %d %v", uid, err)
+	}
+	if _, err := svc.ResolveUserID(rpB, subA); !errors.Is(err, ErrUnknownSubject) {
+		t.Fatalf("expected subject from another sector to be unknown, got %v", err)
+	}
+	if _, err := svc.ResolveUserID(rpA, "42"); !errors.Is(err, ErrUnknownSubject) {
+		t.Fatalf("expected raw user id to be rejected for pairwise client, got %v", err)
+	}
+}
+
+func TestValidateClientSector(t *testing.T) {
+	svc := NewService(setupSubjectTestDB(t)).WithFetcher(func(uri string) ([]byte, error) {
+		return []byte(`["https://a.example.com/cb","https://b.example.com/cb"]`), nil
+	})
+
+	if err := svc.ValidateClient(model.SubjectTypePairwise, "", []string{"https://a.example.com/cb", "https://b.example.com/cb"}); err == nil {
+		t.Fatalf("expected redirect URIs on multiple hosts to require sector_identifier_uri")
+	}
+	if err := svc.ValidateClient(model.SubjectTypePairwise, "https://sector.example.com/uris.json", []string{"https://a.example.com/cb", "https://b.example.com/cb"}); err != nil {
+		t.Fatalf("expected sector_identifier_uri listing all redirect URIs to be accepted: %v", err)
+	}
+	if err := svc.ValidateClient(model.SubjectTypePairwise, "https://sector.example.com/uris.json", []string{"https://c.example.com/cb"}); err == nil {
+		t.Fatalf("expected unlisted redirect URI to be rejected")
+	}
+	if err := svc.ValidateClient(model.SubjectTypePairwise, "http://sector.example.com/uris.json", []string{"https://a.example.com/cb"}); err == nil {
+		t.Fatalf("expected non-https sector_identifier_uri to be rejected")
+	}
+	if err := svc.ValidateClient("secret", "", nil); err == nil {
+		t.Fatalf("expected unknown subject_type to be rejected")
+	}
+
+	client := &model.OAuthClient{SubjectType: model.SubjectTypePairwise, SectorIdentifierURI: "https://Sector.example.com/uris.json", RedirectURIs: "https://a.example.com/cb"}
+	if sector, err := Sector(client); err != nil || sector != "sector.example.com" {
+		t.Fatalf("expected sector from sector_identifier_uri, got %q %v", sector, err)
+	}
+}
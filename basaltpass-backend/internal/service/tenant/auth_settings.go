@@ -13,11 +13,25 @@ type TenantAuthSettings struct {
 	TenantID          uint `json:"tenant_id"`
 	AllowRegistration bool `json:"allow_registration"`
 	AllowLogin        bool `json:"allow_login"`
+	PKCERequired      bool `json:"pkce_required"`
+	PasswordlessEmail bool `json:"passwordless_email"`
+	PasswordlessSMS   bool `json:"passwordless_sms"`
+
+	LockoutMode          string `json:"lockout_mode"`
+	LockoutMaxAttempts   int    `json:"lockout_max_attempts"`
+	LockoutWindowMinutes int    `json:"lockout_window_minutes"`
 }
 
 type UpdateTenantAuthSettingsRequest struct {
 	AllowRegistration *bool `json:"allow_registration,omitempty"`
 	AllowLogin        *bool `json:"allow_login,omitempty"`
+	PKCERequired      *bool `json:"pkce_required,omitempty"`
+	PasswordlessEmail *bool `json:"passwordless_email,omitempty"`
+	PasswordlessSMS   *bool `json:"passwordless_sms,omitempty"`
+
+	LockoutMode          *string `json:"lockout_mode,omitempty"`
+	LockoutMaxAttempts   *int    `json:"lockout_max_attempts,omitempty"`
+	LockoutWindowMinutes *int    `json:"lockout_window_minutes,omitempty"`
 }
 
 func loadOrCreateTenantAuthSetting(db *gorm.DB, tenantID uint) (*model.TenantAuthSetting, error) {
@@ -83,9 +97,41 @@ func toTenantAuthSettings(setting *model.TenantAuthSetting) *TenantAuthSettings
 		TenantID:          setting.TenantID,
 		AllowRegistration: setting.AllowRegistration,
 		AllowLogin:        setting.AllowLogin,
+		PKCERequired:      setting.PKCERequired,
+		PasswordlessEmail: setting.PasswordlessEmail,
+		PasswordlessSMS:   setting.PasswordlessSMS,
+
+		LockoutMode:          setting.LockoutMode,
+		LockoutMaxAttempts:   setting.LockoutMaxAttempts,
+		LockoutWindowMinutes: setting.LockoutWindowMinutes,
 	}
 }
 
+// applyLockoutUpdates 校验并写入登录失败锁定的租户级覆盖
+func applyLockoutUpdates(req *UpdateTenantAuthSettingsRequest, updates map[string]interface{}) error {
+	if req.LockoutMode != nil {
+		switch *req.LockoutMode {
+		case model.LockoutModeInherit, model.LockoutModeEnabled, model.LockoutModeDisabled:
+			updates["lockout_mode"] = *req.LockoutMode
+		default:
+			return errors.New("invalid lockout_mode")
+		}
+	}
+	if req.LockoutMaxAttempts != nil {
+		if *req.LockoutMaxAttempts < 0 || *req.LockoutMaxAttempts > 100 {
+			return errors.New("lockout_max_attempts must be between 0 and 100")
+		}
+		updates["lockout_max_attempts"] = *req.LockoutMaxAttempts
+	}
+	if req.LockoutWindowMinutes != nil {
+		if *req.LockoutWindowMinutes < 0 || *req.LockoutWindowMinutes > 1440 {
+			return errors.New("lockout_window_minutes must be between 0 and 1440")
+		}
+		updates["lockout_window_minutes"] = *req.LockoutWindowMinutes
+	}
+	return nil
+}
+
 func IsTenantRegistrationAllowed(tenantID uint) (bool, error) {
 	setting, err := loadOrCreateTenantAuthSetting(common.DB(), tenantID)
 	if err != nil {
@@ -104,6 +150,34 @@ func IsTenantLoginAllowed(tenantID uint) (bool, error) {
 	return setting.AllowLogin, nil
 }
 
+// IsTenantPKCERequired 租户是否强制授权码流程使用 PKCE
+func IsTenantPKCERequired(tenantID uint) bool {
+	if tenantID == 0 {
+		return false
+	}
+	var setting model.TenantAuthSetting
+	if err := common.DB().Select("pkce_required").Where("tenant_id = ?", tenantID).First(&setting).Error; err != nil {
+		return false
+	}
+	return setting.PKCERequired
+}
+
+// IsTenantPasswordlessAllowed 租户是否允许通过指定渠道（email/sms）免密登录
+func IsTenantPasswordlessAllowed(tenantID uint, channel model.ChallengeChannel) (bool, error) {
+	setting, err := loadOrCreateTenantAuthSetting(common.DB(), tenantID)
+	if err != nil {
+		return false, err
+	}
+	switch channel {
+	case model.ChallengeChannelEmail:
+		return setting.PasswordlessEmail, nil
+	case model.ChallengeChannelSMS:
+		return setting.PasswordlessSMS, nil
+	default:
+		return false, nil
+	}
+}
+
 func (s *TenantService) GetTenantAuthSettings(tenantID uint) (*TenantAuthSettings, error) {
 	setting, err := loadOrCreateTenantAuthSetting(s.db, tenantID)
 	if err != nil {
@@ -116,7 +190,9 @@ func (s *TenantService) UpdateTenantAuthSettings(tenantID uint, req *UpdateTenan
 	if req == nil {
 		return nil, errors.New("request is required")
 	}
-	if req.AllowRegistration == nil && req.AllowLogin == nil {
+	if req.AllowRegistration == nil && req.AllowLogin == nil && req.PKCERequired == nil &&
+		req.PasswordlessEmail == nil && req.PasswordlessSMS == nil &&
+		req.LockoutMode == nil && req.LockoutMaxAttempts == nil && req.LockoutWindowMinutes == nil {
 		return nil, errors.New("no auth setting to update")
 	}
 
@@ -132,6 +208,18 @@ func (s *TenantService) UpdateTenantAuthSettings(tenantID uint, req *UpdateTenan
 	if req.AllowLogin != nil {
 		updates["allow_login"] = *req.AllowLogin
 	}
+	if req.PKCERequired != nil {
+		updates["pkce_required"] = *req.PKCERequired
+	}
+	if req.PasswordlessEmail != nil {
+		updates["passwordless_email"] = *req.PasswordlessEmail
+	}
+	if req.PasswordlessSMS != nil {
+		updates["passwordless_sms"] = *req.PasswordlessSMS
+	}
+	if err := applyLockoutUpdates(req, updates); err != nil {
+		return nil, err
+	}
 
 	if len(updates) > 0 {
 		if err := s.db.Model(setting).Updates(updates).Error; err != nil {
@@ -157,7 +245,9 @@ func (s *AdminTenantService) UpdateTenantAuthSettings(tenantID uint, req *Update
 	if req == nil {
 		return nil, errors.New("request is required")
 	}
-	if req.AllowRegistration == nil && req.AllowLogin == nil {
+	if req.AllowRegistration == nil && req.AllowLogin == nil && req.PKCERequired == nil &&
+		req.PasswordlessEmail == nil && req.PasswordlessSMS == nil &&
+		req.LockoutMode == nil && req.LockoutMaxAttempts == nil && req.LockoutWindowMinutes == nil {
 		return nil, errors.New("no auth setting to update")
 	}
 
@@ -173,6 +263,18 @@ func (s *AdminTenantService) UpdateTenantAuthSettings(tenantID uint, req *Update
 	if req.AllowLogin != nil {
 		updates["allow_login"] = *req.AllowLogin
 	}
+	if req.PKCERequired != nil {
+		updates["pkce_required"] = *req.PKCERequired
+	}
+	if req.PasswordlessEmail != nil {
+		updates["passwordless_email"] = *req.PasswordlessEmail
+	}
+	if req.PasswordlessSMS != nil {
+		updates["passwordless_sms"] = *req.PasswordlessSMS
+	}
+	if err := applyLockoutUpdates(req, updates); err != nil {
+		return nil, err
+	}
 
 	if len(updates) > 0 {
 		if err := s.db.Model(setting).Updates(updates).Error; err != nil {
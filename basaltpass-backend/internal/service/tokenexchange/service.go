@@ -3,7 +3,11 @@ package tokenexchange
 import (
 	"basaltpass-backend/internal/common"
 	"basaltpass-backend/internal/model"
+	"basaltpass-backend/internal/service/accesstoken"
+	"basaltpass-backend/internal/service/signingkey"
+	"basaltpass-backend/internal/utils"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +32,7 @@ type ExchangeRequest struct {
 	SubjectTokenType string // urn:ietf:params:oauth:token-type:access_token
 	Resource         string // target app_id or app_key
 	Scope            string // requested scopes (space-separated)
+	Issuer           string // iss for JWT access tokens, filled by the handler
 }
 
 // ExchangeResult is returned on a successful token exchange.
@@ -41,15 +46,18 @@ type ExchangeResult struct {
 
 // Service implements the OAuth 2.0 Token Exchange (RFC 8693) logic.
 type Service struct {
-	db    *gorm.DB
-	cache *trustCache
+	db     *gorm.DB
+	cache  *trustCache
+	tokens *accesstoken.Service
 }
 
 // NewService creates a new Token Exchange service.
 func NewService() *Service {
+	db := common.DB()
 	return &Service{
-		db:    common.DB(),
-		cache: newTrustCache(60 * time.Second),
+		db:     db,
+		cache:  newTrustCache(60 * time.Second),
+		tokens: accesstoken.NewService(signingkey.NewService(db)),
 	}
 }
 
@@ -65,7 +73,12 @@ func NewService() *Service {
 func (s *Service) Exchange(clientID string, clientAppID uint, clientTenantID uint, req ExchangeRequest, ip string) (*ExchangeResult, error) {
 	// Step 1: Validate subject_token
 	var subjectToken model.OAuthAccessToken
-	if err := s.db.Where("token = ?", req.SubjectToken).First(&subjectToken).Error; err != nil {
+	subjectKey := s.tokens.StorageKey(req.SubjectToken)
+	if subjectKey == "" {
+		s.logExchange(clientTenantID, 0, clientID, clientAppID, 0, 0, req.Scope, "", 0, "denied", "invalid subject token", ip)
+		return nil, ErrInvalidSubjectToken
+	}
+	if err := s.db.Where("token = ?", subjectKey).First(&subjectToken).Error; err != nil {
 		s.logExchange(clientTenantID, 0, clientID, clientAppID, 0, 0, req.Scope, "", 0, "denied", "invalid subject token", ip)
 		return nil, ErrInvalidSubjectToken
 	}
@@ -73,6 +86,10 @@ func (s *Service) Exchange(clientID string, clientAppID uint, clientTenantID uin
 		s.logExchange(clientTenantID, subjectToken.UserID, clientID, clientAppID, 0, 0, req.Scope, "", 0, "denied", "subject token expired", ip)
 		return nil, ErrTokenExpired
 	}
+	if subjectToken.IsClientToken() {
+		s.logExchange(clientTenantID, 0, clientID, clientAppID, 0, 0, req.Scope, "", 0, "denied", "subject token has no user", ip)
+		return nil, ErrInvalidSubjectToken
+	}
 
 	// Step 2: Verify subject_token belongs to the requesting client
 	if subjectToken.ClientID != clientID {
@@ -123,8 +140,8 @@ func (s *Service) Exchange(clientID string, clientAppID uint, clientTenantID uin
 		ttl = 600 // hard cap at 10 minutes
 	}
 
-	// Step 8: Resolve the target app's OAuth client_id for the token record
-	targetClientID := s.resolveTargetClientID(targetApp.ID)
+	// Step 8: Resolve the target app's OAuth client for the token record
+	targetClient := s.resolveTargetClient(targetApp.ID)
 
 	// Step 9: Generate the cross-app token
 	tokenStr, err := model.GenerateCrossAppToken()
@@ -133,8 +150,8 @@ func (s *Service) Exchange(clientID string, clientAppID uint, clientTenantID uin
 	}
 
 	accessToken := &model.OAuthAccessToken{
-		Token:         tokenStr,
-		ClientID:      targetClientID, // the token is "for" the target app
+		Token:         utils.HashOAuthToken(tokenStr), // only the digest is stored
+		ClientID:      targetClient.ClientID, // the token is "for" the target app
 		UserID:        userID,
 		TenantID:      tenantID,
 		AppID:         targetApp.ID,
@@ -144,6 +161,30 @@ func (s *Service) Exchange(clientID string, clientAppID uint, clientTenantID uin
 		ActorAppID:    clientAppID,
 		IsExchanged:   true,
 	}
+
+	// JWT-format target clients receive an RFC 9068 token; the random value
+	// above becomes its jti so introspection and revocation keep working.
+	issued := tokenStr
+	if targetClient.UsesJWTAccessTokens() {
+		issued, err = s.tokens.Sign(&accesstoken.Claims{
+			Issuer:        req.Issuer,
+			Subject:       fmt.Sprintf("%d", userID),
+			Audience:      targetClient.ClientID,
+			ClientID:      targetClient.ClientID,
+			Scope:         grantedScopeStr,
+			TenantID:      tenantID,
+			AppID:         targetApp.ID,
+			JTI:           tokenStr,
+			IssuedAt:      time.Now(),
+			ExpiresAt:     accessToken.ExpiresAt,
+			ActorClientID: clientID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken.TokenPrefix = utils.OAuthTokenDisplayPrefix(issued)
 	if err := s.db.Create(accessToken).Error; err != nil {
 		return nil, err
 	}
@@ -152,7 +193,7 @@ func (s *Service) Exchange(clientID string, clientAppID uint, clientTenantID uin
 	s.logExchange(tenantID, userID, clientID, clientAppID, targetApp.ID, trust.ID, req.Scope, grantedScopeStr, ttl, "granted", "", ip)
 
 	return &ExchangeResult{
-		AccessToken:     tokenStr,
+		AccessToken:     issued,
 		IssuedTokenType: "urn:ietf:params:oauth:token-type:access_token",
 		TokenType:       "Bearer",
 		ExpiresIn:       ttl,
@@ -249,16 +290,16 @@ func splitScopes(s string) []string {
 	return out
 }
 
-// resolveTargetClientID finds the primary OAuth client_id for the target app.
-func (s *Service) resolveTargetClientID(appID uint) string {
+// resolveTargetClient finds the primary OAuth client for the target app.
+func (s *Service) resolveTargetClient(appID uint) *model.OAuthClient {
 	var client model.OAuthClient
-	if err := s.db.Select("client_id").
+	if err := s.db.Select("client_id", "access_token_format").
 		Where("app_id = ? AND is_active = ?", appID, true).
 		Order("id ASC").
 		First(&client).Error; err != nil {
-		return ""
+		return &model.OAuthClient{}
 	}
-	return client.ClientID
+	return &client
 }
 
 // logExchange writes a TokenExchangeLog record.
@@ -0,0 +1,455 @@
+package verification
+
+import (
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	emailservice "basaltpass-backend/internal/service/email"
+	settingssvc "basaltpass-backend/internal/service/settings"
+	tenantservice "basaltpass-backend/internal/service/tenant"
+	"basaltpass-backend/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	mathrand "math/rand"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPasswordlessDisabled = errors.New("passwordless login is disabled")
+	ErrLoginDisabled        = errors.New("tenant login is disabled")
+	ErrInvalidLoginCode     = errors.New("invalid or expired sign-in code")
+	ErrLoginLocked          = errors.New("too many attempts, please try again later")
+	ErrDeliveryFailed       = errors.New("failed to deliver sign-in code")
+)
+
+// StartLoginRequest 发起免密登录请求
+type StartLoginRequest struct {
+	Identifier string                 `json:"identifier"`          // 邮箱或手机号
+	Channel    model.ChallengeChannel `json:"channel"`             // email/sms，为空时按标识符推断
+	TenantID   uint                   `json:"tenant_id"`           // 登录入口所属租户，0 表示平台
+	ReturnTo   string                 `json:"return_to,omitempty"` // 登录后返回的站内地址，OAuth 托管登录时为授权地址
+
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+	LinkURL   string `json:"-"` // 登录链接落地页地址，由处理器按前端地址设置；为空时邮件只包含验证码
+}
+
+// StartLoginResponse 发起免密登录响应；账号不存在时同样返回登录会话ID，避免枚举
+type StartLoginResponse struct {
+	LoginID string                 `json:"login_id"`
+	Channel model.ChallengeChannel `json:"channel"`
+	Message string                 `json:"message"`
+}
+
+// VerifyLoginRequest 校验免密登录：Code 为邮箱/短信验证码，Token 为登录链接中的令牌，二选一
+type VerifyLoginRequest struct {
+	LoginID string `json:"login_id"`
+	Code    string `json:"code,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// VerifiedLogin 免密登录校验通过的账号与登录入口
+type VerifiedLogin struct {
+	UserID   uint
+	TenantID uint
+	Channel  model.ChallengeChannel
+	ReturnTo string
+}
+
+// PasswordlessEnabled 登录入口是否允许通过指定渠道免密登录：平台入口读取系统设置，租户入口读取租户登录设置
+func PasswordlessEnabled(tenantID uint, channel model.ChallengeChannel) (bool, error) {
+	if tenantID > 0 {
+		return tenantservice.IsTenantPasswordlessAllowed(tenantID, channel)
+	}
+	switch channel {
+	case model.ChallengeChannelEmail:
+		return settingssvc.GetBool("auth.passwordless.email_enabled", false), nil
+	case model.ChallengeChannelSMS:
+		return settingssvc.GetBool("auth.passwordless.sms_enabled", false), nil
+	default:
+		return false, nil
+	}
+}
+
+// StartLogin 发起免密登录：向账号的邮箱发送登录链接与验证码，或向已验证的手机号发送短信验证码。
+// 每次发起都签发新的登录会话，发送频率按接收方受冷却时间与每小时次数上限约束。
+func (s *Service) StartLogin(req StartLoginRequest) (*StartLoginResponse, error) {
+	// 1. 解析标识符与渠道
+	identifier := strings.TrimSpace(req.Identifier)
+	if identifier == "" {
+		return nil, errors.New("identifier required")
+	}
+	channel := req.Channel
+	if channel == "" {
+		channel = model.ChallengeChannelSMS
+		if strings.Contains(identifier, "@") {
+			channel = model.ChallengeChannelEmail
+		}
+	}
+	var target string
+	switch channel {
+	case model.ChallengeChannelEmail:
+		target = strings.ToLower(identifier)
+		if err := utils.NewEmailValidator().Validate(target); err != nil {
+			return nil, errors.New("invalid email format")
+		}
+	case model.ChallengeChannelSMS:
+		normalized, err := utils.NewPhoneValidator("+86").NormalizeToE164(identifier)
+		if err != nil {
+			return nil, fmt.Errorf("invalid phone number: %v", err)
+		}
+		target = normalized
+	default:
+		return nil, errors.New("unsupported channel")
+	}
+
+	// 2. 校验登录入口开关
+	if req.TenantID > 0 {
+		allowed, err := tenantservice.IsTenantLoginAllowed(req.TenantID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("tenant not found")
+			}
+			return nil, errors.New("failed to validate tenant login setting")
+		}
+		if !allowed {
+			return nil, ErrLoginDisabled
+		}
+	}
+	enabled, err := PasswordlessEnabled(req.TenantID, channel)
+	if err != nil {
+		return nil, errors.New("failed to validate tenant login setting")
+	}
+	if !enabled {
+		return nil, ErrPasswordlessDisabled
+	}
+
+	// 3. 查找账号：不存在、已封禁或手机号未验证时不发送，但返回同样的响应
+	loginID, err := s.generateSignupID()
+	if err != nil {
+		return nil, err
+	}
+	resp := &StartLoginResponse{
+		LoginID: loginID,
+		Channel: channel,
+		Message: "If an account matches, we sent a sign-in code.",
+	}
+	user, err := s.findLoginUser(req.TenantID, channel, target)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, nil
+		}
+		return nil, err
+	}
+
+	returnTo := utils.Truncate(req.ReturnTo, 512)
+	riskLevel := s.assessRisk(req.IP, req.UserAgent, target)
+	config := s.config.GetConfigByRiskLevel(riskLevel)
+
+	// 4. 按接收方限制发送频率：冷却期内或超过每小时上限时不发送，但返回同样的响应。
+	// 每次发起都创建新的登录挑战，从不返回已有挑战的登录会话ID，避免暴露账号是否存在或交出他人的登录会话
+	throttled, err := s.loginSendThrottled(channel, target, &config)
+	if err != nil {
+		return nil, err
+	}
+	if throttled {
+		return resp, nil
+	}
+
+	// 5. 创建新的登录挑战
+	code, err := s.generateCode(config.CodeLength, config.CodeCharset)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := s.generateSalt()
+	if err != nil {
+		return nil, err
+	}
+	challenge := &model.VerificationChallenge{
+		SignupID:     loginID,
+		Purpose:      model.ChallengePurposeLogin,
+		Channel:      channel,
+		Target:       target,
+		CodeHash:     s.hashCode(code, salt),
+		CodeSalt:     salt,
+		ExpiresAt:    time.Now().Add(config.GetTTLDuration()),
+		MaxAttempts:  config.MaxAttempts,
+		NextSendAt:   time.Now().Add(config.GetResendCooldownDuration()),
+		Status:       model.ChallengeStatusActive,
+		ResendCount:  1,
+		AttemptCount: 0,
+		UserID:       user.ID,
+		TenantID:     req.TenantID,
+		ReturnTo:     returnTo,
+	}
+	linkToken, err := s.issueLinkToken(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.DB().Create(challenge).Error; err != nil {
+		return nil, err
+	}
+	return resp, s.sendLoginChallenge(challenge, code, linkToken, req.LinkURL)
+}
+
+// VerifyLogin 校验免密登录验证码或登录链接令牌，成功后挑战立即失效（一次性）
+func (s *Service) VerifyLogin(req VerifyLoginRequest) (*VerifiedLogin, error) {
+	code := strings.TrimSpace(req.Code)
+	token := strings.TrimSpace(req.Token)
+	if req.LoginID == "" || (code == "" && token == "") {
+		return nil, ErrInvalidLoginCode
+	}
+
+	var challenge model.VerificationChallenge
+	if err := common.DB().Where("signup_id = ? AND purpose = ? AND status = ?",
+		req.LoginID, model.ChallengePurposeLogin, model.ChallengeStatusActive).
+		First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidLoginCode
+		}
+		return nil, err
+	}
+
+	// 检查是否可以尝试
+	if !challenge.CanAttempt() {
+		if challenge.IsExpired() {
+			common.DB().Model(&challenge).Update("status", model.ChallengeStatusExpired)
+			return nil, ErrInvalidLoginCode
+		}
+		return nil, ErrLoginLocked
+	}
+
+	// 增加尝试次数（无论成功失败）
+	challenge.AttemptCount++
+
+	var isValid bool
+	if token != "" {
+		isValid = challenge.LinkHash != "" && s.verifyCode(token, challenge.LinkHash, challenge.CodeSalt)
+	} else {
+		isValid = s.verifyCode(code, challenge.CodeHash, challenge.CodeSalt)
+	}
+
+	if !isValid {
+		if challenge.AttemptCount >= challenge.MaxAttempts {
+			lockUntil := time.Now().Add(GetLockDuration())
+			challenge.LockedUntil = &lockUntil
+		}
+		common.DB().Save(&challenge)
+
+		// 添加延迟防暴力破解
+		time.Sleep(time.Millisecond * time.Duration(300+mathrand.Intn(500)))
+		return nil, ErrInvalidLoginCode
+	}
+
+	// 以条件更新消费挑战，防止并发请求重复使用同一验证码
+	res := common.DB().Model(&model.VerificationChallenge{}).
+		Where("id = ? AND status = ?", challenge.ID, model.ChallengeStatusActive).
+		Updates(map[string]interface{}{
+			"status":        model.ChallengeStatusVerified,
+			"attempt_count": challenge.AttemptCount,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidLoginCode
+	}
+
+	// 通过邮箱完成登录即证明邮箱归属
+	if challenge.Channel == model.ChallengeChannelEmail {
+		now := time.Now()
+		if err := common.DB().Model(&model.User{}).
+			Where("id = ? AND email = ? AND email_verified = ?", challenge.UserID, challenge.Target, false).
+			Updates(map[string]interface{}{"email_verified": true, "email_verified_at": &now}).Error; err != nil {
+			log.Printf("[verification][warn] mark email verified for user %d failed: %v", challenge.UserID, err)
+		}
+	}
+
+	return &VerifiedLogin{
+		UserID:   challenge.UserID,
+		TenantID: challenge.TenantID,
+		Channel:  challenge.Channel,
+		ReturnTo: challenge.ReturnTo,
+	}, nil
+}
+
+// loginSendThrottled 接收方最近一次登录挑战仍在冷却期内，或一小时内发送次数已达上限时返回 true
+func (s *Service) loginSendThrottled(channel model.ChallengeChannel, target string, config *VerificationConfig) (bool, error) {
+	scope := common.DB().Model(&model.VerificationChallenge{}).
+		Where("purpose = ? AND channel = ? AND target = ?", model.ChallengePurposeLogin, channel, target)
+
+	var latest model.VerificationChallenge
+	err := scope.Session(&gorm.Session{}).Order("id DESC").First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if err == nil && time.Now().Before(latest.NextSendAt) {
+		return true, nil
+	}
+
+	var sent int64
+	if err := scope.Session(&gorm.Session{}).Where("created_at > ?", time.Now().Add(-time.Hour)).Count(&sent).Error; err != nil {
+		return false, err
+	}
+	return sent >= int64(config.MaxResendsPerHour), nil
+}
+
+// findLoginUser 按登录入口查找账号，规则与密码登录一致：
+// 平台入口仅限 tenant_id=0 的账号；租户入口优先租户账号，其次为该租户成员的全局账号
+func (s *Service) findLoginUser(tenantID uint, channel model.ChallengeChannel, target string) (*model.User, error) {
+	column := "email"
+	if channel == model.ChallengeChannelSMS {
+		column = "phone"
+	}
+	db := common.DB()
+
+	var user model.User
+	err := db.Where(column+" = ? AND tenant_id = ?", target, tenantID).First(&user).Error
+	if err != nil {
+		if tenantID == 0 || !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err := db.Where(column+" = ? AND tenant_id = 0", target).First(&user).Error; err != nil {
+			return nil, err
+		}
+		var membershipCount int64
+		if err := db.Model(&model.TenantUser{}).
+			Where("user_id = ? AND tenant_id = ?", user.ID, tenantID).
+			Count(&membershipCount).Error; err != nil {
+			return nil, err
+		}
+		if membershipCount == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+
+	// 封禁账号不发送；短信仅发送到已验证的手机号
+	if user.Banned || (channel == model.ChallengeChannelSMS && !user.PhoneVerified) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// issueLinkToken 为邮箱登录挑战生成登录链接令牌，哈希与验证码共用盐值；短信挑战没有登录链接
+func (s *Service) issueLinkToken(challenge *model.VerificationChallenge) (string, error) {
+	if challenge.Channel != model.ChallengeChannelEmail {
+		challenge.LinkHash = ""
+		return "", nil
+	}
+	token, err := s.generateSalt()
+	if err != nil {
+		return "", err
+	}
+	challenge.LinkHash = s.hashCode(token, challenge.CodeSalt)
+	return token, nil
+}
+
+// sendLoginChallenge 通过挑战对应的渠道发送免密登录验证码
+func (s *Service) sendLoginChallenge(challenge *model.VerificationChallenge, code, linkToken, linkURL string) error {
+	var err error
+	switch challenge.Channel {
+	case model.ChallengeChannelSMS:
+		if s.smsSvc == nil {
+			err = errors.New("sms service not configured")
+			break
+		}
+		minutes := int(time.Until(challenge.ExpiresAt).Minutes())
+		err = s.smsSvc.SendVerificationCode(challenge.Target, code, minutes)
+	case model.ChallengeChannelEmail:
+		link := ""
+		if linkURL != "" && linkToken != "" {
+			link = linkURL + "?" + url.Values{"login_id": {challenge.SignupID}, "token": {linkToken}}.Encode()
+		}
+		err = s.sendLoginEmail(challenge, code, link)
+	default:
+		err = errors.New("unsupported channel")
+	}
+	if err != nil {
+		log.Printf("[verification][error] send sign-in code to user %d via %s failed: %v", challenge.UserID, challenge.Channel, err)
+		return ErrDeliveryFailed
+	}
+	return nil
+}
+
+// sendLoginEmail 发送包含登录链接与验证码的邮件
+func (s *Service) sendLoginEmail(challenge *model.VerificationChallenge, code, link string) error {
+	if s.emailSvc == nil {
+		return errors.New("email service not configured")
+	}
+
+	minutes := int(time.Until(challenge.ExpiresAt).Minutes())
+
+	linkText := ""
+	linkHTML := ""
+	if link != "" {
+		linkText = fmt.Sprintf("Sign in with this link:\n%s\n\nOr ", link)
+		linkHTML = fmt.Sprintf(`<p style="margin:0 0 24px;"><a href="%s" style="display:inline-block;padding:14px 28px;border-radius:980px;background-color:#0071e3;color:#ffffff;font-size:16px;font-weight:600;text-decoration:none;">Sign in to BasaltPass</a></p>
+                <p style="margin:0 0 16px;font-size:14px;line-height:24px;color:#424245;">Or enter this code on the sign-in page:</p>`, html.EscapeString(link))
+	}
+
+	textBody := fmt.Sprintf(`
+Dear User,
+
+%senter this code on the sign-in page: %s
+
+The link and code expire in %d minutes and can only be used once.
+
+If you did not try to sign in, please safely ignore this email.
+
+Best regards,
+The BasaltPass Team
+`, linkText, code, minutes)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>BasaltPass Sign-in</title>
+</head>
+<body style="margin:0;padding:0;background-color:#f5f5f7;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;color:#1d1d1f;">
+    <div style="max-width:640px;margin:0 auto;padding:40px 20px;">
+        <div style="background-color:#ffffff;border-radius:28px;overflow:hidden;box-shadow:0 10px 34px rgba(0,0,0,0.035);">
+            <div style="padding:32px 36px 22px;">
+                <div style="font-size:12px;line-height:18px;font-weight:600;letter-spacing:0.12em;text-transform:uppercase;color:#86868b;">BasaltPass</div>
+                <h1 style="margin:14px 0 0;font-size:32px;line-height:38px;font-weight:600;letter-spacing:-0.02em;color:#1d1d1f;">Sign in</h1>
+            </div>
+            <div style="padding:8px 36px 36px;">
+                %s
+                <div style="margin:0 0 24px;padding:28px 24px;border-radius:24px;background:linear-gradient(180deg,#fbfbfd 0%%,#f4f4f6 100%%);text-align:center;">
+                    <div style="font-size:42px;line-height:46px;font-weight:600;letter-spacing:12px;color:#1d1d1f;font-family:ui-monospace,SFMono-Regular,Menlo,Monaco,Consolas,'Liberation Mono','Courier New',monospace;">%s</div>
+                </div>
+                <div style="margin:0 0 24px;padding:18px 20px;border-radius:18px;background-color:#f5f5f7;color:#424245;font-size:14px;line-height:24px;">
+                    The link and code expire in <strong style="color:#1d1d1f;font-weight:600;">%d minutes</strong> and can only be used once.
+                </div>
+                <p style="margin:0;font-size:13px;line-height:22px;color:#6e6e73;">
+                    If you did not try to sign in, you can safely ignore this email. Never share this code or link with anyone.
+                </p>
+            </div>
+            <div style="padding:20px 36px;background-color:#fbfbfd;">
+                <p style="margin:0;font-size:12px;line-height:20px;color:#8d8d92;">
+                    &copy; %d BasaltPass. This is an automated message, please do not reply.
+                </p>
+            </div>
+        </div>
+    </div>
+</body>
+</html>`, linkHTML, code, minutes, time.Now().Year())
+
+	msg := &emailservice.Message{
+		To:       []string{challenge.Target},
+		Subject:  "BasaltPass Sign-in Link",
+		TextBody: textBody,
+		HTMLBody: htmlBody,
+	}
+	userID := challenge.UserID
+	_, err := s.emailSvc.SendWithLogging(context.Background(), msg, &userID, "passwordless_login")
+	return err
+}
@@ -0,0 +1,176 @@
+package verification
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"basaltpass-backend/internal/common"
+	"basaltpass-backend/internal/model"
+	smsservice "basaltpass-backend/internal/service/sms"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const testPhone = "+8613800138000"
+
+func setupLoginTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Tenant{},
+		&model.TenantUser{},
+		&model.TenantAuthSetting{},
+		&model.User{},
+		&model.VerificationChallenge{},
+	); err != nil {
+		t.Fatalf("auto migrate failed: %v", err)
+	}
+	common.SetDBForTest(db)
+	return db
+}
+
+// newLoginTestService 创建允许短信免密登录的租户及一个手机号已验证的租户账号
+func newLoginTestService(t *testing.T) (*Service, *gorm.DB, uint, uint) {
+	t.Helper()
+	db := setupLoginTestDB(t)
+	tenant := model.Tenant{Name: "Acme", Code: "acme"}
+	if err := db.Create(&tenant).Error; err != nil {
+		t.Fatalf("create tenant failed: %v", err)
+	}
+	if err := db.Create(&model.TenantAuthSetting{TenantID: tenant.ID, AllowRegistration: true, AllowLogin: true, PasswordlessSMS: true}).Error; err != nil {
+		t.Fatalf("create auth setting failed: %v", err)
+	}
+	user := model.User{TenantID: tenant.ID, Email: "alice@example.com", Phone: testPhone, PhoneVerified: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user failed: %v", err)
+	}
+	return &Service{config: DefaultConfig(), smsSvc: smsservice.New()}, db, tenant.ID, user.ID
+}
+
+// setKnownCode 将挑战的验证码替换为已知值，便于校验
+func setKnownCode(t *testing.T, s *Service, db *gorm.DB, loginID, code string) {
+	t.Helper()
+	var challenge model.VerificationChallenge
+	if err := db.Where("signup_id = ?", loginID).First(&challenge).Error; err != nil {
+		t.Fatalf("load challenge failed: %v", err)
+	}
+	if err := db.Model(&challenge).Update("code_hash", s.hashCode(code, challenge.CodeSalt)).Error; err != nil {
+		t.Fatalf("update code failed: %v", err)
+	}
+}
+
+func TestPasswordlessLoginBySMS(t *testing.T) {
+	s, db, tenantID, userID := newLoginTestService(t)
+
+	resp, err := s.StartLogin(StartLoginRequest{Identifier: "13800138000", TenantID: tenantID, ReturnTo: "/oauth/authorize?client_id=app"})
+	if err != nil {
+		t.Fatalf("start login failed: %v", err)
+	}
+	if resp.LoginID == "" || resp.Channel != model.ChallengeChannelSMS {
+		t.Fatalf("unexpected start response: %+v", resp)
+	}
+	setKnownCode(t, s, db, resp.LoginID, "123456")
+
+	if _, err := s.VerifyLogin(VerifyLoginRequest{LoginID: resp.LoginID, Code: "000000"}); !errors.Is(err, ErrInvalidLoginCode) {
+		t.Fatalf("expected ErrInvalidLoginCode for wrong code, got %v", err)
+	}
+	verified, err := s.VerifyLogin(VerifyLoginRequest{LoginID: resp.LoginID, Code: "123456"})
+	if err != nil {
+		t.Fatalf("verify login failed: %v", err)
+	}
+	if verified.UserID != userID || verified.TenantID != tenantID || verified.ReturnTo != "/oauth/authorize?client_id=app" {
+		t.Fatalf("unexpected verified login: %+v", verified)
+	}
+
+	// 验证码只能使用一次
+	if _, err := s.VerifyLogin(VerifyLoginRequest{LoginID: resp.LoginID, Code: "123456"}); !errors.Is(err, ErrInvalidLoginCode) {
+		t.Fatalf("expected code to be single use, got %v", err)
+	}
+}
+
+func TestPasswordlessLoginIssuesFreshSessionPerStart(t *testing.T) {
+	s, db, tenantID, _ := newLoginTestService(t)
+
+	first, err := s.StartLogin(StartLoginRequest{Identifier: testPhone, TenantID: tenantID})
+	if err != nil {
+		t.Fatalf("start login failed: %v", err)
+	}
+	// 冷却期内再次发起：返回新的登录会话ID且不发送，已有挑战不受影响
+	var before model.VerificationChallenge
+	db.Where("signup_id = ?", first.LoginID).First(&before)
+	second, err := s.StartLogin(StartLoginRequest{Identifier: testPhone, TenantID: tenantID})
+	if err != nil {
+		t.Fatalf("restart login failed: %v", err)
+	}
+	if second.LoginID == first.LoginID {
+		t.Fatalf("expected a fresh login session instead of the active one")
+	}
+	var after model.VerificationChallenge
+	db.Where("signup_id = ?", first.LoginID).First(&after)
+	if after.CodeHash != before.CodeHash || after.Status != model.ChallengeStatusActive {
+		t.Fatalf("expected existing challenge to be left untouched")
+	}
+	countChallenges := func() int64 {
+		var count int64
+		db.Model(&model.VerificationChallenge{}).Where("purpose = ?", model.ChallengePurposeLogin).Count(&count)
+		return count
+	}
+	if n := countChallenges(); n != 1 {
+		t.Fatalf("expected send to be throttled during cooldown, got %d challenges", n)
+	}
+
+	// 冷却结束后每次发起都创建新挑战，直到达到每小时上限
+	limit := s.config.GetConfigByRiskLevel(s.assessRisk("", "", testPhone)).MaxResendsPerHour
+	for i := 1; i < limit+2; i++ {
+		db.Model(&model.VerificationChallenge{}).Where("1 = 1").Update("next_send_at", time.Now().Add(-time.Second))
+		resp, err := s.StartLogin(StartLoginRequest{Identifier: testPhone, TenantID: tenantID})
+		if err != nil {
+			t.Fatalf("start login failed: %v", err)
+		}
+		if resp.LoginID == first.LoginID {
+			t.Fatalf("expected a fresh login session")
+		}
+	}
+	if n := countChallenges(); n != int64(limit) {
+		t.Fatalf("expected sends to stop at the hourly limit %d, got %d", limit, n)
+	}
+}
+
+func TestPasswordlessLoginUniformResponse(t *testing.T) {
+	s, db, tenantID, userID := newLoginTestService(t)
+
+	// 未知号码与未验证手机号都返回登录会话ID，但不创建挑战
+	resp, err := s.StartLogin(StartLoginRequest{Identifier: "+8613900139000", TenantID: tenantID})
+	if err != nil || resp.LoginID == "" {
+		t.Fatalf("expected uniform response for unknown account, got %+v, %v", resp, err)
+	}
+	db.Model(&model.User{}).Where("id = ?", userID).Update("phone_verified", false)
+	if _, err := s.StartLogin(StartLoginRequest{Identifier: testPhone, TenantID: tenantID}); err != nil {
+		t.Fatalf("expected uniform response for unverified phone, got %v", err)
+	}
+	var count int64
+	db.Model(&model.VerificationChallenge{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no challenge to be created, got %d", count)
+	}
+	if _, err := s.VerifyLogin(VerifyLoginRequest{LoginID: resp.LoginID, Code: "123456"}); !errors.Is(err, ErrInvalidLoginCode) {
+		t.Fatalf("expected ErrInvalidLoginCode for unknown session, got %v", err)
+	}
+}
+
+func TestPasswordlessLoginRespectsTenantSettings(t *testing.T) {
+	s, db, tenantID, _ := newLoginTestService(t)
+
+	if _, err := s.StartLogin(StartLoginRequest{Identifier: "alice@example.com", TenantID: tenantID}); !errors.Is(err, ErrPasswordlessDisabled) {
+		t.Fatalf("expected email passwordless to be disabled, got %v", err)
+	}
+	db.Model(&model.TenantAuthSetting{}).Where("tenant_id = ?", tenantID).Update("allow_login", false)
+	if _, err := s.StartLogin(StartLoginRequest{Identifier: testPhone, TenantID: tenantID}); !errors.Is(err, ErrLoginDisabled) {
+		t.Fatalf("expected ErrLoginDisabled, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"basaltpass-backend/internal/model"
 	emailservice "basaltpass-backend/internal/service/email"
 	settingssvc "basaltpass-backend/internal/service/settings"
+	smsservice "basaltpass-backend/internal/service/sms"
 	tenantservice "basaltpass-backend/internal/service/tenant"
 	"basaltpass-backend/internal/service/wallet"
 	"basaltpass-backend/internal/utils"
@@ -33,6 +34,7 @@ const (
 type Service struct {
 	config   *RiskLevelConfig
 	emailSvc *emailservice.Service
+	smsSvc   *smsservice.Service
 }
 
 // NewService 创建验证码服务
@@ -45,6 +47,7 @@ func NewService() *Service {
 	return &Service{
 		config:   DefaultConfig(),
 		emailSvc: emailSvc,
+		smsSvc:   smsservice.New(),
 	}
 }
 
@@ -235,8 +238,8 @@ func (s *Service) SendVerificationCode(req SendVerificationRequest) error {
 func (s *Service) VerifyCode(req VerifyCodeRequest) error {
 	// 获取活跃的验证码挑战
 	var challenge model.VerificationChallenge
-	if err := common.DB().Where("signup_id = ? AND status = ?",
-		req.SignupID, model.ChallengeStatusActive).
+	if err := common.DB().Where("signup_id = ? AND purpose = ? AND status = ?",
+		req.SignupID, model.ChallengePurposeSignup, model.ChallengeStatusActive).
 		First(&challenge).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("verification code not found or expired")
@@ -587,9 +590,21 @@ func (s *Service) verifyCode(inputCode, storedHash, salt string) bool {
 
 // handleResend 处理重发逻辑
 func (s *Service) handleResend(challenge *model.VerificationChallenge, config *VerificationConfig) error {
+	newCode, err := s.rotateCode(challenge, config)
+	if err != nil || newCode == "" {
+		return err
+	}
+	if err := common.DB().Save(challenge).Error; err != nil {
+		return err
+	}
+	return s.sendVerificationEmail(challenge.Target, newCode, challenge.ExpiresAt)
+}
+
+// rotateCode 按重发规则为挑战签发新验证码（未保存），不满足重发条件时返回空串
+func (s *Service) rotateCode(challenge *model.VerificationChallenge, config *VerificationConfig) (string, error) {
 	// 检查重发条件
 	if !challenge.CanResend() {
-		return nil // 统一响应，不暴露具体原因
+		return "", nil // 统一响应，不暴露具体原因
 	}
 
 	// 检查重发次数限制
@@ -597,7 +612,7 @@ func (s *Service) handleResend(challenge *model.VerificationChallenge, config *V
 
 	// 检查小时限制（简化版，实际需要更精确的滑动窗口）
 	if challenge.ResendCount >= config.MaxResendsPerHour {
-		return nil
+		return "", nil
 	}
 
 	// 更新重发计数和下次发送时间
@@ -608,13 +623,13 @@ func (s *Service) handleResend(challenge *model.VerificationChallenge, config *V
 	// 因此重发时统一签发一个新码，避免接口返回成功但实际上没有任何邮件发送。
 	newCode, err := s.generateCode(config.CodeLength, config.CodeCharset)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// 生成新的盐值
 	salt, err := s.generateSalt()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	challenge.CodeHash = s.hashCode(newCode, salt)
@@ -637,11 +652,7 @@ func (s *Service) handleResend(challenge *model.VerificationChallenge, config *V
 		challenge.AttemptCount = 0
 		challenge.LockedUntil = nil
 	}
-
-	if err := common.DB().Save(challenge).Error; err != nil {
-		return err
-	}
-	return s.sendVerificationEmail(challenge.Target, newCode, challenge.ExpiresAt)
+	return newCode, nil
 }
 
 // createNewChallenge 创建新的验证码挑战
@@ -661,6 +672,7 @@ func (s *Service) createNewChallenge(signupID string, channel model.ChallengeCha
 	// 创建挑战
 	challenge := &model.VerificationChallenge{
 		SignupID:     signupID,
+		Purpose:      model.ChallengePurposeSignup,
 		Channel:      channel,
 		Target:       target,
 		CodeHash:     s.hashCode(code, salt),
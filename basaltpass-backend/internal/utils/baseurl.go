@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"basaltpass-backend/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestBaseURL 返回 API 的对外基础地址：优先使用配置的 server.public_url，
+// 未配置时才按当前请求的协议与 Host 头构建
+func RequestBaseURL(c *fiber.Ctx) string {
+	if base := config.PublicURL(); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Secure() {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Get("Host")
+}
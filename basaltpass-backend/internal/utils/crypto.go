@@ -3,9 +3,11 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -71,21 +73,11 @@ func EncryptTOTPSecret(plaintext string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("totp encrypt: key derivation failed: %w", err)
 	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("totp encrypt: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
+	sealed, err := sealAESGCM(key, []byte(plaintext))
 	if err != nil {
 		return "", fmt.Errorf("totp encrypt: %w", err)
 	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("totp encrypt: nonce: %w", err)
-	}
-	// Seal 将 nonce 放在 ciphertext 头部：nonce || ciphertext+tag
-	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return totpEncPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+	return totpEncPrefix + sealed, nil
 }
 
 // DecryptTOTPSecret 解密由 EncryptTOTPSecret 生成的密文。
@@ -102,25 +94,184 @@ func DecryptTOTPSecret(stored string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("totp decrypt: key derivation failed: %w", err)
 	}
-	data, err := base64.RawURLEncoding.DecodeString(stored[len(totpEncPrefix):])
+	plaintext, err := openAESGCM(key, stored[len(totpEncPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("totp decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// signingKeyEncryptionKey 返回用于加密签名私钥的 32 字节密钥。
+// 优先使用环境变量 SIGNING_KEY_ENCRYPTION_KEY（任意字符串经 SHA-256 得到 32 字节），
+// 否则从 JWT_SECRET 派生（与 TOTP 使用不同的派生标签）。
+func signingKeyEncryptionKey() ([]byte, error) {
+	if raw := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY"); raw != "" {
+		h := sha256.Sum256([]byte(raw))
+		return h[:], nil
+	}
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, errors.New("missing encryption key: set SIGNING_KEY_ENCRYPTION_KEY or JWT_SECRET")
+	}
+	h := sha256.Sum256([]byte(jwtSecret + ":signing_key_v1"))
+	return h[:], nil
+}
+
+const signingKeyEncPrefix = "enc:sk1:"
+
+// EncryptSigningKey 用 AES-256-GCM 加密签名私钥（PEM），返回带前缀的 base64url 字符串。
+func EncryptSigningKey(plaintext []byte) (string, error) {
+	key, err := signingKeyEncryptionKey()
+	if err != nil {
+		return "", fmt.Errorf("signing key encrypt: key derivation failed: %w", err)
+	}
+	sealed, err := sealAESGCM(key, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("signing key encrypt: %w", err)
+	}
+	return signingKeyEncPrefix + sealed, nil
+}
+
+// DecryptSigningKey 解密由 EncryptSigningKey 生成的密文。
+func DecryptSigningKey(stored string) ([]byte, error) {
+	if !strings.HasPrefix(stored, signingKeyEncPrefix) {
+		return nil, errors.New("signing key decrypt: unexpected format")
+	}
+	key, err := signingKeyEncryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("signing key decrypt: key derivation failed: %w", err)
+	}
+	plaintext, err := openAESGCM(key, stored[len(signingKeyEncPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("signing key decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// identityProviderSecretKey 返回用于加密上游身份提供方客户端密钥的 32 字节密钥。
+// 优先使用环境变量 IDP_SECRET_ENCRYPTION_KEY，否则从 JWT_SECRET 派生。
+func identityProviderSecretKey() ([]byte, error) {
+	if raw := os.Getenv("IDP_SECRET_ENCRYPTION_KEY"); raw != "" {
+		h := sha256.Sum256([]byte(raw))
+		return h[:], nil
+	}
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, errors.New("missing encryption key: set IDP_SECRET_ENCRYPTION_KEY or JWT_SECRET")
+	}
+	h := sha256.Sum256([]byte(jwtSecret + ":identity_provider_v1"))
+	return h[:], nil
+}
+
+const identityProviderSecretPrefix = "enc:ip1:"
+
+// EncryptIdentityProviderSecret 用 AES-256-GCM 加密上游客户端密钥；空字符串原样返回（公共客户端）。
+func EncryptIdentityProviderSecret(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	key, err := identityProviderSecretKey()
+	if err != nil {
+		return "", fmt.Errorf("identity provider secret encrypt: key derivation failed: %w", err)
+	}
+	sealed, err := sealAESGCM(key, []byte(plaintext))
+	if err != nil {
+		return "", fmt.Errorf("identity provider secret encrypt: %w", err)
+	}
+	return identityProviderSecretPrefix + sealed, nil
+}
+
+// DecryptIdentityProviderSecret 解密由 EncryptIdentityProviderSecret 生成的密文。
+func DecryptIdentityProviderSecret(stored string) (string, error) {
+	if stored == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(stored, identityProviderSecretPrefix) {
+		return "", errors.New("identity provider secret decrypt: unexpected format")
+	}
+	key, err := identityProviderSecretKey()
+	if err != nil {
+		return "", fmt.Errorf("identity provider secret decrypt: key derivation failed: %w", err)
+	}
+	plaintext, err := openAESGCM(key, stored[len(identityProviderSecretPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("identity provider secret decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// oauthTokenHashKey 返回 OAuth 令牌摘要使用的 HMAC 密钥。
+// 优先使用环境变量 OAUTH_TOKEN_HASH_KEY，否则从 JWT_SECRET 派生。
+// 服务启动时 JWT_SECRET 为必填项，两者都缺失只会出现在测试中。
+func oauthTokenHashKey() []byte {
+	if raw := os.Getenv("OAUTH_TOKEN_HASH_KEY"); raw != "" {
+		h := sha256.Sum256([]byte(raw))
+		return h[:]
+	}
+	h := sha256.Sum256([]byte(os.Getenv("JWT_SECRET") + ":oauth_token_v1"))
+	return h[:]
+}
+
+// OAuthTokenHashPrefix 标识已哈希的令牌/授权码，迁移时据此跳过已处理的记录
+const OAuthTokenHashPrefix = "h1:"
+
+// HashOAuthToken 计算 OAuth 令牌或授权码的存储值：HMAC-SHA256 十六进制摘要。
+// 数据库只保存该摘要，查找与撤销时对提交的明文做同样计算。
+func HashOAuthToken(raw string) string {
+	mac := hmac.New(sha256.New, oauthTokenHashKey())
+	mac.Write([]byte(raw))
+	return OAuthTokenHashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// OAuthTokenDisplayPrefix 返回令牌明文的前若干字符，用于管理界面展示
+func OAuthTokenDisplayPrefix(raw string) string {
+	const n = 8
+	if len(raw) <= n {
+		return raw
+	}
+	return raw[:n]
+}
+
+// sealAESGCM 加密并返回 base64url(nonce || ciphertext+tag)
+func sealAESGCM(key, plaintext []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("totp decrypt: base64: %w", err)
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+	// Seal 将 nonce 放在 ciphertext 头部：nonce || ciphertext+tag
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openAESGCM 解密 sealAESGCM 的输出
+func openAESGCM(key []byte, encoded string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("base64: %w", err)
 	}
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", fmt.Errorf("totp decrypt: %w", err)
+		return nil, err
 	}
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("totp decrypt: %w", err)
+		return nil, err
 	}
 	if len(data) < gcm.NonceSize() {
-		return "", errors.New("totp decrypt: ciphertext too short")
+		return nil, errors.New("ciphertext too short")
 	}
 	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return "", fmt.Errorf("totp decrypt: authentication failed: %w", err)
+		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
-	return string(plaintext), nil
+	return plaintext, nil
 }
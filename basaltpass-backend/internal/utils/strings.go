@@ -0,0 +1,14 @@
+package utils
+
+import "unicode/utf8"
+
+// Truncate 截断到不超过 max 字节，且不切断多字节字符
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
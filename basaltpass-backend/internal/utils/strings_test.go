@@ -0,0 +1,15 @@
+package utils
+
+import "testing"
+
+func TestTruncateKeepsRunesIntact(t *testing.T) {
+	if got := Truncate("张三丰", 7); got != "张三" {
+		t.Fatalf("expected truncation on a rune boundary, got %q", got)
+	}
+	if got := Truncate("alice", 3); got != "ali" {
+		t.Fatalf("unexpected ascii truncation: %q", got)
+	}
+	if got := Truncate("bob", 8); got != "bob" {
+		t.Fatalf("expected short string to be kept, got %q", got)
+	}
+}
@@ -72,6 +72,13 @@ function Login() {
     setPageTitle(t('auth.login.pageTitleAdmin'))
   }, [setPageTitle, t])
 
+  const loginHint = searchParams.get('login_hint') || ''
+  useEffect(() => {
+    if (loginHint) {
+      setIdentifier((current) => current || loginHint)
+    }
+  }, [loginHint, setIdentifier])
+
   useEffect(() => {
     const code = consumeSessionNotice()
     if (code === 'session_expired') {
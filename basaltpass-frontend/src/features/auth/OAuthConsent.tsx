@@ -30,6 +30,11 @@ export default function OAuthConsent() {
   const clientId = searchParams.get('client_id') || ''
   const codeChallenge = searchParams.get('code_challenge') || ''
   const codeChallengeMethod = searchParams.get('code_challenge_method') || ''
+  const nonce = searchParams.get('nonce') || ''
+  const requestUri = searchParams.get('request_uri') || ''
+  const acrValues = searchParams.get('acr_values') || ''
+  const claimsParam = searchParams.get('claims') || ''
+  const responseMode = searchParams.get('response_mode') || ''
   const privacyPolicyUrl = searchParams.get('privacy_policy_url') || ''
   const termsOfServiceUrl = searchParams.get('terms_of_service_url') || ''
   const isVerified = searchParams.get('is_verified') === 'true'
@@ -106,6 +111,11 @@ export default function OAuthConsent() {
     if (state) append('state', state)
     if (codeChallenge) append('code_challenge', codeChallenge)
     if (codeChallengeMethod) append('code_challenge_method', codeChallengeMethod)
+    if (nonce) append('nonce', nonce)
+    if (requestUri) append('request_uri', requestUri)
+    if (acrValues) append('acr_values', acrValues)
+    if (claimsParam) append('claims', claimsParam)
+    if (responseMode) append('response_mode', responseMode)
     if (opts?.selectedToken) append('selected_access_token', opts.selectedToken)
     if (opts?.joinTenant) append('join_tenant', 'true')
 
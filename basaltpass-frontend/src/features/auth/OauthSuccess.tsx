@@ -1,12 +1,15 @@
 import { useEffect } from 'react'
-import { useNavigate } from 'react-router-dom'
+import { useNavigate, useSearchParams } from 'react-router-dom'
 import { ROUTES } from '@constants'
 import client from '@api/client'
 import { useAuth } from '@contexts/AuthContext'
+import { getApiBase } from '../../shared/config/env'
+import { resolveSafeRedirectTarget } from '@utils/redirect'
 
 export default function OauthSuccess() {
   const navigate = useNavigate()
   const { login } = useAuth()
+  const [searchParams] = useSearchParams()
 
   useEffect(() => {
     const bootstrap = async () => {
@@ -17,13 +20,26 @@ export default function OauthSuccess() {
           throw new Error('missing access token')
         }
         await login(token)
+        // Federated sign-in may carry a return path, e.g. a pending /api/v1/oauth/authorize request.
+        const redirect = searchParams.get('redirect') || ''
+        if (redirect.startsWith('/api/')) {
+          const apiBase = String(client.defaults.baseURL || getApiBase()).replace(/\/$/, '')
+          const target = resolveSafeRedirectTarget(redirect, apiBase)
+          if (target) {
+            window.location.href = target
+            return
+          }
+        } else if (redirect.startsWith('/') && !redirect.startsWith('//')) {
+          navigate(redirect)
+          return
+        }
         navigate(ROUTES.user.profile)
       } catch {
         navigate(ROUTES.user.login)
       }
     }
     void bootstrap()
-  }, [login, navigate])
+  }, [login, navigate, searchParams])
 
   return null
 } 
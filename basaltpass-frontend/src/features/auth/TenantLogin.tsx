@@ -89,6 +89,7 @@ function TenantLogin() {
   })
 
   const { isResolvingTenantSession } = useTenantSessionBootstrap({
+    forceReauth: searchParams.get('prompt') === 'login',
     isAuthenticated,
     isAuthLoading,
     login,
@@ -97,6 +98,13 @@ function TenantLogin() {
     tenantInfo,
   })
 
+  const loginHint = searchParams.get('login_hint') || ''
+  useEffect(() => {
+    if (loginHint) {
+      setIdentifier((current) => current || loginHint)
+    }
+  }, [loginHint, setIdentifier])
+
   useEffect(() => {
     const code = consumeSessionNotice()
     if (code === 'session_expired') {
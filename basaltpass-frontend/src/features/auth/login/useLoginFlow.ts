@@ -1,7 +1,8 @@
-import { useRef, useState, type FormEvent } from 'react'
+import { useEffect, useRef, useState, type FormEvent } from 'react'
 import type { NavigateFunction } from 'react-router-dom'
 import client from '@api/client'
 import { loginWithPasskey2FAFlow } from '@api/oauth/passkey'
+import { takeFederatedSecondFactor } from '@api/public/federation'
 import { ROUTES } from '@constants'
 import { useI18n } from '@shared/i18n'
 import type { LoginTwoFactorMethod } from './types'
@@ -79,6 +80,18 @@ export function useLoginFlow({
   const [rememberMe, setRememberMe] = useState(false)
   const totpInputRef = useRef<HTMLInputElement>(null)
 
+  // Federated sign-in that still needs a second factor lands here with a pre_auth_token fragment.
+  useEffect(() => {
+    const federated = takeFederatedSecondFactor()
+    if (!federated) {
+      return
+    }
+    setPreAuthToken(federated.preAuthToken)
+    setTwoFAType(federated.twoFAType as LoginTwoFactorMethod)
+    setAvailable2FAMethods(federated.availableMethods as LoginTwoFactorMethod[])
+    setStep(2)
+  }, [])
+
   const resetTwoFactorState = () => {
     setPreAuthToken('')
     setTwoFAType('')
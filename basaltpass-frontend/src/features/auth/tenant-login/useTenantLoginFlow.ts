@@ -1,7 +1,8 @@
-import { useState, type FormEvent } from 'react'
+import { useEffect, useState, type FormEvent } from 'react'
 import type { NavigateFunction } from 'react-router-dom'
 import client from '@api/client'
 import { loginWithPasskey2FAFlow } from '@api/oauth/passkey'
+import { takeFederatedSecondFactor } from '@api/public/federation'
 import { ROUTES } from '@constants'
 import { useI18n } from '@shared/i18n'
 import type { TenantInfo, TwoFactorMethod } from './types'
@@ -80,6 +81,18 @@ export function useTenantLoginFlow({
   const [emailCode, setEmailCode] = useState('')
   const [rememberMe, setRememberMe] = useState(false)
 
+  // Federated sign-in that still needs a second factor lands here with a pre_auth_token fragment.
+  useEffect(() => {
+    const federated = takeFederatedSecondFactor()
+    if (!federated) {
+      return
+    }
+    setPreAuthToken(federated.preAuthToken)
+    setTwoFAType(federated.twoFAType as TwoFactorMethod)
+    setAvailable2FAMethods(federated.availableMethods as TwoFactorMethod[])
+    setStep(2)
+  }, [])
+
   const resetTwoFactorState = () => {
     setPreAuthToken('')
     setTwoFAType('')
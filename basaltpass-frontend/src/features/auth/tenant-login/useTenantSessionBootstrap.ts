@@ -7,6 +7,8 @@ import { pruneExpiredUserConsoleSessions, removeUserConsoleSessionByKey } from '
 import type { TenantInfo } from './types'
 
 interface UseTenantSessionBootstrapOptions {
+  // prompt=login：OAuth 客户端要求重新认证，不复用已有会话
+  forceReauth?: boolean
   isAuthenticated: boolean
   isAuthLoading: boolean
   login: (token: string) => Promise<void>
@@ -16,6 +18,7 @@ interface UseTenantSessionBootstrapOptions {
 }
 
 export function useTenantSessionBootstrap({
+  forceReauth = false,
   isAuthenticated,
   isAuthLoading,
   login,
@@ -36,6 +39,11 @@ export function useTenantSessionBootstrap({
       return
     }
 
+    if (forceReauth) {
+      setIsResolvingTenantSession(false)
+      return
+    }
+
     const targetTenantID = tenantInfo.id
     const activeToken = getAccessToken()
     const activeDecoded = activeToken ? decodeJWT(activeToken) : null
@@ -101,7 +109,7 @@ export function useTenantSessionBootstrap({
         inFlightSessionKeyRef.current = null
       }
     }
-  }, [isAuthenticated, isAuthLoading, login, navigate, redirectAfterLogin, tenantInfo])
+  }, [forceReauth, isAuthenticated, isAuthLoading, login, navigate, redirectAfterLogin, tenantInfo])
 
   return {
     isResolvingTenantSession,
@@ -110,11 +110,21 @@ export interface TenantAuthSettings {
   tenant_id: number;
   allow_registration: boolean;
   allow_login: boolean;
+  passwordless_email: boolean;
+  passwordless_sms: boolean;
+  lockout_mode: 'inherit' | 'enabled' | 'disabled';
+  lockout_max_attempts: number;
+  lockout_window_minutes: number;
 }
 
 export interface UpdateTenantAuthSettingsRequest {
   allow_registration?: boolean;
   allow_login?: boolean;
+  passwordless_email?: boolean;
+  passwordless_sms?: boolean;
+  lockout_mode?: 'inherit' | 'enabled' | 'disabled';
+  lockout_max_attempts?: number;
+  lockout_window_minutes?: number;
 }
 
 // tenantusermanagementtranslatedtype
@@ -11,6 +11,8 @@ export interface AdminUser {
   phone_verified: boolean
   two_fa_enabled: boolean
   banned: boolean
+  locked_until?: string
+  failed_login_attempts: number
   last_login_at?: string
   created_at: string
   updated_at: string
@@ -88,6 +90,7 @@ export interface UserStats {
   total_users: number
   active_users: number
   banned_users: number
+  locked_users: number
   verified_users: number
   two_fa_enabled_users: number
   new_users_today: number
@@ -99,7 +102,7 @@ export interface UserListParams {
   page?: number
   limit?: number
   search?: string
-  status?: 'all' | 'active' | 'banned' | 'verified' | 'unverified'
+  status?: 'all' | 'active' | 'banned' | 'locked' | 'verified' | 'unverified'
   tenant_id?: number
   unassigned_only?: boolean
   role?: string
@@ -124,6 +127,22 @@ export interface BanUserRequest {
   comment?: string
 }
 
+export interface ResetUserMFARequest {
+  // Operator re-verification: own password, or own TOTP code when the account has no local password
+  password?: string
+  code?: string
+  reason: string
+  remove_passkeys?: boolean
+  // Omit to reset every tenant
+  tenant_id?: number
+}
+
+export interface ResetUserMFAResult {
+  totp_disabled: number
+  recovery_codes_deleted: number
+  passkeys_removed: number
+}
+
 export interface AssignGlobalRoleRequest {
   role_id: number
 }
@@ -170,6 +189,18 @@ export const adminUserApi = {
     return response.data
   },
 
+  // 重置两步验证
+  async resetUserMFA(id: number, data: ResetUserMFARequest) {
+    const response = await client.post(`/api/v1/admin/users/${id}/mfa/reset`, data)
+    return response.data as { message: string; data: ResetUserMFAResult }
+  },
+
+  // unlock a user locked out by repeated failed logins
+  async unlockUser(id: number) {
+    const response = await client.post(`/api/v1/admin/users/${id}/unlock`)
+    return response.data as { message: string }
+  },
+
   // deleteuser
   async deleteUser(id: number) {
     const response = await client.delete(`/api/v1/admin/users/${id}`)
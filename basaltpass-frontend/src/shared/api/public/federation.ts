@@ -0,0 +1,47 @@
+import axios from 'axios'
+import { getApiBase, inferDefaultApiBase } from '../../config/env'
+
+export type FederationProvider = {
+  id: number
+  slug: string
+  name: string
+  preset: string
+}
+
+const apiBase = () => getApiBase() || inferDefaultApiBase()
+
+export async function fetchFederationProviders(tenantId = 0): Promise<FederationProvider[]> {
+  const res = await axios.get(`${apiBase()}/api/v1/auth/federation/providers`, {
+    params: { tenant_id: tenantId },
+    withCredentials: false,
+  })
+  return (res.data?.data || []) as FederationProvider[]
+}
+
+// Full-page navigation target that starts the upstream sign-in; the callback lands on /oauth-success.
+export function federationAuthorizeUrl(providerId: number, returnTo?: string): string {
+  const url = `${apiBase()}/api/v1/auth/federation/${providerId}/authorize`
+  return returnTo ? `${url}?return_to=${encodeURIComponent(returnTo)}` : url
+}
+
+export type FederatedSecondFactor = {
+  preAuthToken: string
+  twoFAType: string
+  availableMethods: string[]
+}
+
+// When the federated account has 2FA enabled, the callback returns to the login page with the
+// pre_auth_token in the URL fragment. Reads it once and strips it from the address bar.
+export function takeFederatedSecondFactor(): FederatedSecondFactor | null {
+  const params = new URLSearchParams(window.location.hash.replace(/^#/, ''))
+  const preAuthToken = params.get('pre_auth_token')
+  if (!preAuthToken) {
+    return null
+  }
+  window.history.replaceState(null, '', window.location.pathname + window.location.search)
+  return {
+    preAuthToken,
+    twoFAType: params.get('2fa_type') || '',
+    availableMethods: (params.get('available_2fa_methods') || '').split(',').filter(Boolean),
+  }
+}
@@ -0,0 +1,55 @@
+import axios from 'axios'
+import { getApiBase, inferDefaultApiBase } from '../../config/env'
+
+export type PasswordlessChannel = 'email' | 'sms'
+
+export type PasswordlessMethods = Record<PasswordlessChannel, boolean>
+
+export type PasswordlessStartResponse = {
+  login_id: string
+  channel: PasswordlessChannel
+  message: string
+}
+
+export type PasswordlessVerifyResponse = {
+  access_token?: string
+  redirect?: string
+  need_2fa?: boolean
+  '2fa_type'?: string
+  pre_auth_token?: string
+  available_2fa_methods?: string[]
+  data?: { token: string; user: { id: number } }
+}
+
+const apiBase = () => getApiBase() || inferDefaultApiBase()
+
+export async function fetchPasswordlessMethods(tenantId = 0): Promise<PasswordlessMethods> {
+  const res = await axios.get(`${apiBase()}/api/v1/auth/passwordless/methods`, {
+    params: { tenant_id: tenantId },
+    withCredentials: false,
+  })
+  return { email: false, sms: false, ...(res.data?.data || {}) }
+}
+
+// Sends a magic link + code (email) or a code (sms). The response is identical whether or not the account exists.
+export async function startPasswordlessLogin(params: {
+  identifier: string
+  channel?: PasswordlessChannel
+  tenant_id?: number
+  return_to?: string
+}): Promise<PasswordlessStartResponse> {
+  const res = await axios.post(`${apiBase()}/api/v1/auth/passwordless/start`, params)
+  return res.data as PasswordlessStartResponse
+}
+
+// Exchanges a code, or the token from the magic link landing on /auth/magic-link, for a session.
+export async function verifyPasswordlessLogin(
+  params: { login_id: string; code?: string; token?: string },
+  scope?: string,
+): Promise<PasswordlessVerifyResponse> {
+  const res = await axios.post(`${apiBase()}/api/v1/auth/passwordless/verify`, params, {
+    withCredentials: true,
+    headers: scope ? { 'X-Auth-Scope': scope } : undefined,
+  })
+  return res.data as PasswordlessVerifyResponse
+}
@@ -0,0 +1,10 @@
+import axios from 'axios'
+import { getApiBase, inferDefaultApiBase } from '../../config/env'
+
+const apiBase = () => getApiBase() || inferDefaultApiBase()
+
+// Redeems the single-use link from the account lockout email (/auth/unlock?token=...).
+export async function unlockAccount(token: string): Promise<{ message: string }> {
+  const res = await axios.post(`${apiBase()}/api/v1/auth/unlock`, { token })
+  return res.data
+}
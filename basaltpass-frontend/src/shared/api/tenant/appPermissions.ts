@@ -68,6 +68,29 @@ export interface UpdateRoleRequest {
   permission_ids?: number[]
 }
 
+export type ClaimMappingSource = 'tenant_roles' | 'app_roles' | 'app_permissions' | 'teams' | 'profile' | 'static'
+
+export interface ClaimMapping {
+  id: number
+  app_id: number
+  tenant_id: number
+  claim_name: string
+  source: ClaimMappingSource
+  value: string
+  scope: string
+  targets: string
+  created_at: string
+  updated_at: string
+}
+
+export interface ClaimMappingRequest {
+  claim_name: string
+  source: ClaimMappingSource
+  value?: string
+  scope?: string
+  targets?: string
+}
+
 function normalizeUserPermission(item: any, appId: string, userId: string): UserPermission {
   if (item?.permission) {
     return {
@@ -213,6 +236,28 @@ export const userPermissionsApi = {
   async deletePermission(appId: string, permissionId: number) {
     const response = await client.delete(`/api/v1/tenant/apps/${appId}/permissions/${permissionId}`)
     return response.data
+  },
+
+  // ==================== claim mappings ====================
+
+  async getClaimMappings(appId: string): Promise<{ claim_mappings: ClaimMapping[] }> {
+    const response = await client.get(`/api/v1/tenant/apps/${appId}/claim-mappings`)
+    return response.data
+  },
+
+  async createClaimMapping(appId: string, data: ClaimMappingRequest): Promise<ClaimMapping> {
+    const response = await client.post(`/api/v1/tenant/apps/${appId}/claim-mappings`, data)
+    return response.data
+  },
+
+  async updateClaimMapping(appId: string, mappingId: number, data: ClaimMappingRequest): Promise<ClaimMapping> {
+    const response = await client.put(`/api/v1/tenant/apps/${appId}/claim-mappings/${mappingId}`, data)
+    return response.data
+  },
+
+  async deleteClaimMapping(appId: string, mappingId: number) {
+    const response = await client.delete(`/api/v1/tenant/apps/${appId}/claim-mappings/${mappingId}`)
+    return response.data
   }
 }
 
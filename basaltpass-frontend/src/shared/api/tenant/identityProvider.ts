@@ -0,0 +1,73 @@
+import client from '../client'
+
+export interface IdentityProvider {
+  id: number
+  tenant_id: number
+  slug: string
+  name: string
+  type: 'oidc' | 'oauth2'
+  preset: string
+  issuer: string
+  authorization_endpoint: string
+  token_endpoint: string
+  userinfo_endpoint: string
+  jwks_uri: string
+  client_id: string
+  has_client_secret: boolean
+  scopes: string
+  subject_field: string
+  email_field: string
+  name_field: string
+  allow_provisioning: boolean
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface CreateIdentityProviderRequest {
+  slug?: string
+  name: string
+  type?: 'oidc' | 'oauth2'
+  preset?: string
+  issuer?: string
+  authorization_endpoint?: string
+  token_endpoint?: string
+  userinfo_endpoint?: string
+  jwks_uri?: string
+  client_id: string
+  client_secret?: string
+  scopes?: string
+  subject_field?: string
+  email_field?: string
+  name_field?: string
+  allow_provisioning?: boolean
+  enabled?: boolean
+}
+
+export type UpdateIdentityProviderRequest = Partial<Omit<CreateIdentityProviderRequest, 'slug' | 'type' | 'preset'>>
+
+export const identityProviderApi = {
+  async list() {
+    const response = await client.get<{
+      data: IdentityProvider[]
+      presets: string[]
+      callback_url: string
+    }>('/api/v1/tenant/identity-providers')
+    return response.data
+  },
+
+  async create(data: CreateIdentityProviderRequest) {
+    const response = await client.post<{ data: IdentityProvider }>('/api/v1/tenant/identity-providers', data)
+    return response.data.data
+  },
+
+  async update(id: number, data: UpdateIdentityProviderRequest) {
+    const response = await client.patch<{ data: IdentityProvider }>(`/api/v1/tenant/identity-providers/${id}`, data)
+    return response.data.data
+  },
+
+  async delete(id: number) {
+    const response = await client.delete<{ message: string }>(`/api/v1/tenant/identity-providers/${id}`)
+    return response.data
+  },
+}
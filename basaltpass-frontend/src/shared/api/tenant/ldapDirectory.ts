@@ -0,0 +1,95 @@
+import client from '../client'
+
+export interface LDAPGroupRoleMapping {
+  group: string
+  role: string
+}
+
+export interface LDAPDirectory {
+  id: number
+  tenant_id: number
+  url: string
+  start_tls: boolean
+  insecure_skip_verify: boolean
+  bind_dn: string
+  has_bind_password: boolean
+  base_dn: string
+  user_filter: string
+  uid_attribute: string
+  email_attribute: string
+  name_attribute: string
+  phone_attribute: string
+  group_attribute: string
+  group_base_dn: string
+  group_filter: string
+  group_role_mappings: LDAPGroupRoleMapping[]
+  allow_provisioning: boolean
+  sync_interval_minutes: number
+  last_sync_at?: string
+  last_sync_error: string
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface SaveLDAPDirectoryRequest {
+  url?: string
+  start_tls?: boolean
+  insecure_skip_verify?: boolean
+  bind_dn?: string
+  bind_password?: string
+  base_dn?: string
+  user_filter?: string
+  uid_attribute?: string
+  email_attribute?: string
+  name_attribute?: string
+  phone_attribute?: string
+  group_attribute?: string
+  group_base_dn?: string
+  group_filter?: string
+  group_role_mappings?: LDAPGroupRoleMapping[]
+  allow_provisioning?: boolean
+  sync_interval_minutes?: number
+  enabled?: boolean
+}
+
+export interface LDAPSyncResult {
+  synced: number
+  missing: number
+  failed: number
+}
+
+export const ldapDirectoryApi = {
+  async get() {
+    const response = await client.get<{ data: LDAPDirectory }>('/api/v1/tenant/ldap-directory')
+    return response.data.data
+  },
+
+  async save(data: SaveLDAPDirectoryRequest) {
+    const response = await client.put<{ data: LDAPDirectory }>('/api/v1/tenant/ldap-directory', data)
+    return response.data.data
+  },
+
+  async delete() {
+    const response = await client.delete<{ message: string }>('/api/v1/tenant/ldap-directory')
+    return response.data
+  },
+
+  async test() {
+    const response = await client.post<{ message: string }>('/api/v1/tenant/ldap-directory/test')
+    return response.data
+  },
+
+  async sync() {
+    const response = await client.post<{ data: LDAPSyncResult }>('/api/v1/tenant/ldap-directory/sync')
+    return response.data.data
+  },
+
+  async link(userId: number, username: string) {
+    const response = await client.post<{ message: string }>('/api/v1/tenant/ldap-directory/links', {
+      user_id: userId,
+      username,
+    })
+    return response.data
+  },
+}
@@ -66,11 +66,22 @@ export interface TenantAuthSettings {
   tenant_id: number
   allow_registration: boolean
   allow_login: boolean
+  passwordless_email: boolean
+  passwordless_sms: boolean
+  // 'inherit' follows the platform security.account_lockout settings; 0 means inherit for the numbers
+  lockout_mode: 'inherit' | 'enabled' | 'disabled'
+  lockout_max_attempts: number
+  lockout_window_minutes: number
 }
 
 export interface UpdateTenantAuthSettingsRequest {
   allow_registration?: boolean
   allow_login?: boolean
+  passwordless_email?: boolean
+  passwordless_sms?: boolean
+  lockout_mode?: 'inherit' | 'enabled' | 'disabled'
+  lockout_max_attempts?: number
+  lockout_window_minutes?: number
 }
 
 export interface CreateTenantRequest {
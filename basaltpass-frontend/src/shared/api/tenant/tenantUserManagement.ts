@@ -67,6 +67,20 @@ export interface AuthorizeGlobalUserRequest {
   role?: 'admin' | 'user'
 }
 
+export interface ResetUserMFARequest {
+  // Operator re-verification: own password, or own TOTP code when the account has no local password
+  password?: string
+  code?: string
+  reason: string
+  remove_passkeys?: boolean
+}
+
+export interface ResetUserMFAResult {
+  totp_disabled: number
+  recovery_codes_deleted: number
+  passkeys_removed: number
+}
+
 // tenantusermanagementAPI
 export const tenantUserManagementApi = {
   // gettenantuserlist
@@ -131,6 +145,12 @@ export const tenantUserManagementApi = {
   async authorizeGlobalUser(userId: number, data?: AuthorizeGlobalUserRequest) {
     const response = await client.post(`/api/v1/tenant/users/global-candidates/${userId}/authorize`, data || {})
     return response.data
+  },
+
+  // 重置用户在本租户下的两步验证
+  async resetUserMFA(userId: number, data: ResetUserMFARequest) {
+    const response = await client.post(`/api/v1/tenant/users/${userId}/mfa/reset`, data)
+    return response.data as { message: string; data: ResetUserMFAResult }
   }
 }
 
@@ -3,6 +3,7 @@ import client from '../client'
 export interface SecurityStatus {
   password_set: boolean
   two_fa_enabled: boolean
+  recovery_codes_remaining: number
   passkeys_count: number
   email: string
   phone?: string
@@ -16,6 +17,20 @@ export interface ChangePasswordRequest {
   confirm_password: string
 }
 
+export interface ConsoleSession {
+  session_id: string
+  scope: string
+  tenant_id: number
+  device: string
+  ip_address: string
+  user_agent: string
+  auth_time: string
+  last_seen_at: string
+  expires_at: string
+  created_at: string
+  current: boolean
+}
+
 export interface UpdateContactRequest {
   email?: string
   phone?: string
@@ -23,8 +38,12 @@ export interface UpdateContactRequest {
 
 // 2FAtranslated
 export const setup2FA = () => client.post('/api/v1/security/2fa/setup')
-export const verify2FA = (code: string) => client.post('/api/v1/security/2fa/verify', { code })
+// Enabling 2FA returns the recovery codes; they are shown only once
+export const verify2FA = (code: string): Promise<{ data: { recovery_codes: string[] } }> =>
+  client.post('/api/v1/security/2fa/verify', { code })
 export const disable2FA = (code: string) => client.post('/api/v1/security/2fa/disable', { code })
+export const regenerateRecoveryCodes = (code: string): Promise<{ data: { recovery_codes: string[] } }> =>
+  client.post('/api/v1/security/2fa/recovery-codes', { code })
 
 // getsecuritystatus
 export const getSecurityStatus = (): Promise<{ data: SecurityStatus }> => 
@@ -50,6 +69,45 @@ export const resendEmailVerification = () =>
 export const resendPhoneVerification = () => 
   client.post('/api/v1/security/phone/resend')
 
+// 登录会话
+export const listSessions = (): Promise<{ data: { data: ConsoleSession[] } }> =>
+  client.get('/api/v1/security/sessions')
+
+export const revokeSession = (sessionId: string) =>
+  client.delete(`/api/v1/security/sessions/${encodeURIComponent(sessionId)}`)
+
+export const revokeAllSessions = (includeCurrent = false) =>
+  client.delete('/api/v1/security/sessions', { params: { include_current: includeCurrent } })
+
+export interface IdentityLink {
+  id: number
+  provider_id: number
+  provider_name: string
+  preset: string
+  email: string
+  display_name: string
+  last_login_at?: string
+  created_at: string
+}
+
+export interface LinkableIdentityProvider {
+  id: number
+  slug: string
+  name: string
+  preset: string
+  linked: boolean
+}
+
+export const listIdentities = (): Promise<{ data: { data: { links: IdentityLink[]; providers: LinkableIdentityProvider[] } } }> =>
+  client.get('/api/v1/security/identities')
+
+// Returns the upstream authorization URL; the caller navigates the browser to it.
+export const linkIdentity = (providerId: number, returnTo?: string): Promise<{ data: { data: { authorization_url: string } } }> =>
+  client.post(`/api/v1/security/identities/${providerId}/link`, null, { params: returnTo ? { return_to: returnTo } : undefined })
+
+export const unlinkIdentity = (linkId: number) =>
+  client.delete(`/api/v1/security/identities/${linkId}`)
+
 // translatedsecuritytranslated
 
 // emailtranslated